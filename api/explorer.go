@@ -12,6 +12,16 @@ import (
 	"github.com/julienschmidt/httprouter"
 )
 
+const (
+	// explorerAddressDefaultLimit is the number of transactions returned by
+	// /explorer/addresses if no limit is provided.
+	explorerAddressDefaultLimit = 100
+
+	// explorerAddressMaxLimit is the maximum number of transactions that can
+	// be requested from /explorer/addresses at once.
+	explorerAddressMaxLimit = 1000
+)
+
 type (
 	// ExplorerBlock is a block with some extra information such as the id and
 	// height. This information is provided for programs that may not be
@@ -47,6 +57,26 @@ type (
 		SiafundClaimOutputIDs                    []types.SiacoinOutputID   `json:"siafundclaimoutputids"`
 	}
 
+	// ExplorerAddressTransaction is a transaction in the history of an
+	// address. If the transaction is the miner payouts of a block, the
+	// payouts are provided and the transaction ID is the ID of the block.
+	// Otherwise, the transaction is provided.
+	ExplorerAddressTransaction struct {
+		modules.UnlockHashTransaction
+
+		MinerPayouts []types.SiacoinOutput `json:"minerpayouts,omitempty"`
+		Transaction  *ExplorerTransaction  `json:"transaction,omitempty"`
+	}
+
+	// ExplorerAddressGET is the object returned as a response to a GET request
+	// to /explorer/addresses/:address. It contains a page of the transactions
+	// that involve the address, in the order in which they appear in the
+	// blockchain.
+	ExplorerAddressGET struct {
+		Total        uint64                       `json:"total"`
+		Transactions []ExplorerAddressTransaction `json:"transactions"`
+	}
+
 	// ExplorerGET is the object returned as a response to a GET request to
 	// /explorer.
 	ExplorerGET struct {
@@ -59,6 +89,45 @@ type (
 		Block ExplorerBlock `json:"block"`
 	}
 
+	// ExplorerIntegrityGET is the object returned as a response to a GET
+	// request to /explorer/integrity.
+	ExplorerIntegrityGET struct {
+		modules.ExplorerIntegrityReport
+	}
+
+	// ExplorerSearchGET is the object returned as a response to a GET request
+	// to /explorer/search. It contains the objects that the query was
+	// resolved to.
+	ExplorerSearchGET struct {
+		Results []modules.ExplorerSearchResult `json:"results"`
+	}
+
+	// ExplorerTimeSeriesGET is the object returned as a response to a GET
+	// request to /explorer/timeseries.
+	ExplorerTimeSeriesGET struct {
+		Points []modules.ExplorerTimeSeriesPoint `json:"points"`
+	}
+
+	// ExplorerUnconfirmedTransaction is a transaction in the transaction pool
+	// that has not been confirmed in a block yet.
+	ExplorerUnconfirmedTransaction struct {
+		ID          types.TransactionID `json:"id"`
+		Transaction types.Transaction   `json:"transaction"`
+	}
+
+	// ExplorerUnconfirmedAddressGET is the object returned as a response to a
+	// GET request to /explorer/unconfirmed/addresses/:address. It contains
+	// the unconfirmed transactions that involve the address.
+	ExplorerUnconfirmedAddressGET struct {
+		Transactions []ExplorerUnconfirmedTransaction `json:"transactions"`
+	}
+
+	// ExplorerUnconfirmedTransactionGET is the object returned as a response
+	// to a GET request to /explorer/unconfirmed/transactions/:id.
+	ExplorerUnconfirmedTransactionGET struct {
+		Transaction ExplorerUnconfirmedTransaction `json:"transaction"`
+	}
+
 	// ExplorerHashGET is the object returned as a response to a GET request to
 	// /explorer/hash. The HashType will indicate whether the hash corresponds
 	// to a block id, a transaction id, a siacoin output id, a file contract
@@ -218,6 +287,59 @@ func (api *API) explorerBlocksHandler(w http.ResponseWriter, req *http.Request,
 	})
 }
 
+// explorerAddressHandler handles GET requests to /explorer/addresses/:address.
+func (api *API) explorerAddressHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	addr, err := scanAddress(ps.ByName("address"))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	var offset uint64
+	if o := req.FormValue("offset"); o != "" {
+		if _, err := fmt.Sscan(o, &offset); err != nil {
+			WriteError(w, Error{"unable to parse offset: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	limit := uint64(explorerAddressDefaultLimit)
+	if l := req.FormValue("limit"); l != "" {
+		if _, err := fmt.Sscan(l, &limit); err != nil {
+			WriteError(w, Error{"unable to parse limit: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	if limit > explorerAddressMaxLimit {
+		WriteError(w, Error{fmt.Sprintf("limit cannot exceed %v", explorerAddressMaxLimit)}, http.StatusBadRequest)
+		return
+	}
+
+	history, total := api.explorer.UnlockHashHistory(addr, offset, limit)
+	txns := make([]ExplorerAddressTransaction, 0, len(history))
+	for _, uht := range history {
+		block, height, exists := api.explorer.Transaction(uht.TransactionID)
+		if !exists && build.DEBUG {
+			panic("explorer pointing to nonexistent txn")
+		}
+		eat := ExplorerAddressTransaction{UnlockHashTransaction: uht}
+		if types.TransactionID(block.ID()) == uht.TransactionID {
+			eat.MinerPayouts = block.MinerPayouts
+		} else {
+			for _, t := range block.Transactions {
+				if t.ID() == uht.TransactionID {
+					et := api.buildExplorerTransaction(height, block.ID(), t)
+					eat.Transaction = &et
+					break
+				}
+			}
+		}
+		txns = append(txns, eat)
+	}
+	WriteJSON(w, ExplorerAddressGET{
+		Total:        total,
+		Transactions: txns,
+	})
+}
+
 // buildTransactionSet returns the blocks and transactions that are associated
 // with a set of transaction ids.
 func (api *API) buildTransactionSet(txids []types.TransactionID) (txns []ExplorerTransaction, blocks []ExplorerBlock) {
@@ -358,3 +480,132 @@ func (api *API) explorerHandler(w http.ResponseWriter, req *http.Request, _ http
 		BlockFacts: facts,
 	})
 }
+
+// explorerIntegrityHandler handles GET requests to /explorer/integrity.
+func (api *API) explorerIntegrityHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	var start types.BlockHeight
+	if s := req.FormValue("start"); s != "" {
+		if _, err := fmt.Sscan(s, &start); err != nil {
+			WriteError(w, Error{"unable to parse start: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	end := api.explorer.LatestBlockFacts().Height
+	if e := req.FormValue("end"); e != "" {
+		if _, err := fmt.Sscan(e, &end); err != nil {
+			WriteError(w, Error{"unable to parse end: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	report, err := api.explorer.CheckIntegrity(start, end)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	if report.Problems == nil {
+		report.Problems = []string{}
+	}
+	WriteJSON(w, ExplorerIntegrityGET{report})
+}
+
+// explorerSearchHandler handles GET requests to /explorer/search.
+func (api *API) explorerSearchHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	results, err := api.explorer.Search(req.FormValue("query"))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	if results == nil {
+		results = []modules.ExplorerSearchResult{}
+	}
+	WriteJSON(w, ExplorerSearchGET{
+		Results: results,
+	})
+}
+
+// explorerTimeSeriesHandler handles GET requests to /explorer/timeseries.
+func (api *API) explorerTimeSeriesHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	interval := req.FormValue("interval")
+	if interval == "" {
+		interval = modules.ExplorerTimeSeriesDaily
+	}
+	var start types.Timestamp
+	if s := req.FormValue("start"); s != "" {
+		if _, err := fmt.Sscan(s, &start); err != nil {
+			WriteError(w, Error{"unable to parse start: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	end := types.CurrentTimestamp()
+	if e := req.FormValue("end"); e != "" {
+		if _, err := fmt.Sscan(e, &end); err != nil {
+			WriteError(w, Error{"unable to parse end: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	if start > end {
+		WriteError(w, Error{"start cannot be after end"}, http.StatusBadRequest)
+		return
+	}
+
+	points, err := api.explorer.TimeSeries(interval, start, end)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	if points == nil {
+		points = []modules.ExplorerTimeSeriesPoint{}
+	}
+	WriteJSON(w, ExplorerTimeSeriesGET{
+		Points: points,
+	})
+}
+
+// explorerUnconfirmedAddressHandler handles GET requests to
+// /explorer/unconfirmed/addresses/:address.
+func (api *API) explorerUnconfirmedAddressHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	addr, err := scanAddress(ps.ByName("address"))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	txids := api.explorer.UnconfirmedUnlockHash(addr)
+	txns := make([]ExplorerUnconfirmedTransaction, 0, len(txids))
+	for _, txid := range txids {
+		// The transaction may have been confirmed in the meantime.
+		txn, exists := api.explorer.UnconfirmedTransaction(txid)
+		if !exists {
+			continue
+		}
+		txns = append(txns, ExplorerUnconfirmedTransaction{
+			ID:          txid,
+			Transaction: txn,
+		})
+	}
+	WriteJSON(w, ExplorerUnconfirmedAddressGET{
+		Transactions: txns,
+	})
+}
+
+// explorerUnconfirmedTransactionHandler handles GET requests to
+// /explorer/unconfirmed/transactions/:id.
+func (api *API) explorerUnconfirmedTransactionHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	hash, err := scanHash(ps.ByName("id"))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	txid := types.TransactionID(hash)
+	txn, exists := api.explorer.UnconfirmedTransaction(txid)
+	if !exists {
+		WriteError(w, Error{"transaction is not in the transaction pool"}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, ExplorerUnconfirmedTransactionGET{
+		Transaction: ExplorerUnconfirmedTransaction{
+			ID:          txid,
+			Transaction: txn,
+		},
+	})
+}
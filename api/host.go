@@ -1,6 +1,7 @@
 package api
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -42,6 +43,22 @@ type (
 		ConversionRate float64        `json:"conversionrate"`
 	}
 
+	// HostBandwidthGET contains the bandwidth that renters used through the
+	// host's contracts.
+	HostBandwidthGET struct {
+		modules.HostBandwidthMetrics
+	}
+
+	// HostPricingGET contains the rules that adjust the host's prices.
+	HostPricingGET struct {
+		Rules []modules.HostPricingRule `json:"rules"`
+	}
+
+	// HostScrubGET contains the results of the host's sector scrubber.
+	HostScrubGET struct {
+		modules.HostScrubMetrics
+	}
+
 	// StorageGET contains the information that is returned after a GET request
 	// to /host/storage - a bunch of information about the status of storage
 	// management on the host.
@@ -280,6 +297,46 @@ func (api *API) hostAnnounceHandler(w http.ResponseWriter, req *http.Request, _
 	WriteSuccess(w)
 }
 
+// hostBandwidthHandlerGET handles the API call to report the bandwidth that
+// renters used through the host's contracts.
+func (api *API) hostBandwidthHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	WriteJSON(w, HostBandwidthGET{
+		HostBandwidthMetrics: api.host.BandwidthMetrics(),
+	})
+}
+
+// hostPricingHandlerGET handles the API call to list the host's pricing rules.
+func (api *API) hostPricingHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	WriteJSON(w, HostPricingGET{
+		Rules: api.host.PricingRules(),
+	})
+}
+
+// hostPricingHandlerPOST handles the API call to replace the host's pricing
+// rules.
+func (api *API) hostPricingHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	var rules []modules.HostPricingRule
+	if r := req.FormValue("rules"); r != "" {
+		if err := json.Unmarshal([]byte(r), &rules); err != nil {
+			WriteError(w, Error{"unable to parse rules: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	if err := api.host.SetPricingRules(rules); err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// hostScrubHandlerGET handles the API call to report the results of the
+// host's sector scrubber.
+func (api *API) hostScrubHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	WriteJSON(w, HostScrubGET{
+		HostScrubMetrics: api.host.ScrubMetrics(),
+	})
+}
+
 // storageHandler returns a bunch of information about storage management on
 // the host.
 func (api *API) storageHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
@@ -305,6 +362,35 @@ func (api *API) storageFoldersAddHandler(w http.ResponseWriter, req *http.Reques
 	WriteSuccess(w)
 }
 
+// storageFoldersMoveHandler moves a storage folder in the storage manager to
+// a new path.
+func (api *API) storageFoldersMoveHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	folderPath := req.FormValue("path")
+	if folderPath == "" {
+		WriteError(w, Error{"path parameter is required"}, http.StatusBadRequest)
+		return
+	}
+	newPath := req.FormValue("newpath")
+	if newPath == "" {
+		WriteError(w, Error{"newpath parameter is required"}, http.StatusBadRequest)
+		return
+	}
+
+	storageFolders := api.host.StorageFolders()
+	folderIndex, err := folderIndex(folderPath, storageFolders)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	err = api.host.MoveStorageFolder(uint16(folderIndex), newPath)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
 // storageFoldersResizeHandler resizes a storage folder in the storage manager.
 func (api *API) storageFoldersResizeHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	folderPath := req.FormValue("path")
@@ -4,7 +4,9 @@ package api
 // zeroing them out.
 
 import (
+	"bytes"
 	"fmt"
+	"math"
 	"net/http"
 	"path/filepath"
 	"sort"
@@ -76,6 +78,8 @@ type (
 
 	// RenterContract represents a contract formed by the renter.
 	RenterContract struct {
+		// Number of blocks until the file contract ends.
+		BlocksRemaining types.BlockHeight `json:"blocksremaining"`
 		// Amount of contract funds that have been spent on downloads.
 		DownloadSpending types.Currency `json:"downloadspending"`
 		// Block height that the file contract ends on.
@@ -84,6 +88,9 @@ type (
 		Fees types.Currency `json:"fees"`
 		// Public key of the host the contract was formed with.
 		HostPublicKey types.SiaPublicKey `json:"hostpublickey"`
+		// Whether the file contract is within the renew window and should
+		// be renewed shortly.
+		ExpiryWarning bool `json:"expirywarning"`
 		// ID of the file contract.
 		ID types.FileContractID `json:"id"`
 		// A signed transaction containing the most recent contract revision.
@@ -92,11 +99,17 @@ type (
 		NetAddress modules.NetAddress `json:"netaddress"`
 		// Remaining funds left for the renter to spend on uploads & downloads.
 		RenterFunds types.Currency `json:"renterfunds"`
+		// Revision number of the most recent contract revision, which is the
+		// number of times the contract has been revised.
+		RevisionNumber uint64 `json:"revisionnumber"`
 		// Size of the file contract, which is typically equal to the number of
 		// bytes that have been uploaded to the host.
 		Size uint64 `json:"size"`
 		// Block height that the file contract began on.
 		StartHeight types.BlockHeight `json:"startheight"`
+		// Whether the transaction that formed the contract is part of the
+		// blockchain.
+		Status modules.ContractStatus `json:"status"`
 		// Amount of contract funds that have been spent on storage.
 		StorageSpending types.Currency `json:"StorageSpending"`
 		// Total cost to the wallet of forming the file contract.
@@ -105,6 +118,12 @@ type (
 		UploadSpending types.Currency `json:"uploadspending"`
 	}
 
+	// RenterAlertsGET contains the contracts whose formation transaction is
+	// in danger of never confirming.
+	RenterAlertsGET struct {
+		Alerts []modules.ContractAlert `json:"alerts"`
+	}
+
 	// RenterContracts contains the renter's contracts.
 	RenterContracts struct {
 		Contracts []RenterContract `json:"contracts"`
@@ -131,6 +150,39 @@ type (
 		modules.RenterPriceEstimation
 	}
 
+	// RenterHealthGET summarizes the redundancy of the renter's files.
+	RenterHealthGET struct {
+		modules.RenterHealth
+	}
+
+	// RenterMemoryGET reports how the renter's memory is being used.
+	RenterMemoryGET struct {
+		modules.RenterMemoryBreakdown
+	}
+
+	// RenterBackupPOST contains the siapath of a metadata backup.
+	RenterBackupPOST struct {
+		SiaPath string `json:"siapath"`
+	}
+
+	// RenterBackupRestorePOST contains the siapaths of the files restored
+	// from a metadata backup.
+	RenterBackupRestorePOST struct {
+		SiaPaths []string `json:"siapaths"`
+	}
+
+	// RenterRecoverContractsPOST contains the number of contracts recovered
+	// from the blockchain.
+	RenterRecoverContractsPOST struct {
+		Recovered int `json:"recovered"`
+	}
+
+	// RenterUploadTracePOST contains the path of the file that an upload
+	// trace is written to.
+	RenterUploadTracePOST struct {
+		Path string `json:"path"`
+	}
+
 	// RenterShareASCII contains an ASCII-encoded .sia file.
 	RenterShareASCII struct {
 		ASCIIsia string `json:"asciisia"`
@@ -138,12 +190,13 @@ type (
 
 	// DownloadInfo contains all client-facing information of a file.
 	DownloadInfo struct {
-		SiaPath     string    `json:"siapath"`
-		Destination string    `json:"destination"`
-		Filesize    uint64    `json:"filesize"`
-		Received    uint64    `json:"received"`
-		StartTime   time.Time `json:"starttime"`
-		Error       string    `json:"error"`
+		SiaPath     string                        `json:"siapath"`
+		Destination string                        `json:"destination"`
+		Filesize    uint64                        `json:"filesize"`
+		Received    uint64                        `json:"received"`
+		StartTime   time.Time                     `json:"starttime"`
+		Error       string                        `json:"error"`
+		Chunks      []modules.DownloadChunkResult `json:"chunks"`
 	}
 )
 
@@ -207,15 +260,209 @@ func (api *API) renterHandlerPOST(w http.ResponseWriter, req *http.Request, _ ht
 		renewWindow = period / 2
 	}
 
+	// Start from the current settings so that any optional parameters which
+	// are not provided keep their current values.
+	settings := api.renter.Settings()
+	settings.Allowance = modules.Allowance{
+		Funds:       funds,
+		Hosts:       hosts,
+		Period:      period,
+		RenewWindow: renewWindow,
+
+		RenewFundsThreshold: settings.Allowance.RenewFundsThreshold,
+	}
+
+	// Scan the renew funds threshold. (optional parameter)
+	if req.FormValue("renewfundsthreshold") != "" {
+		_, err = fmt.Sscan(req.FormValue("renewfundsthreshold"), &settings.Allowance.RenewFundsThreshold)
+		if err != nil {
+			WriteError(w, Error{"unable to parse renewfundsthreshold: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Scan the repair throttle threshold. (optional parameter)
+	if req.FormValue("repairthrottlethreshold") != "" {
+		_, err = fmt.Sscan(req.FormValue("repairthrottlethreshold"), &settings.RepairThrottleThreshold)
+		if err != nil {
+			WriteError(w, Error{"unable to parse repairthrottlethreshold: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	// Scan the throttled repair bandwidth. (optional parameter)
+	if req.FormValue("repairthrottledbandwidth") != "" {
+		_, err = fmt.Sscan(req.FormValue("repairthrottledbandwidth"), &settings.RepairThrottledBandwidth)
+		if err != nil {
+			WriteError(w, Error{"unable to parse repairthrottledbandwidth: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Scan the maximum download speed. (optional parameter)
+	if req.FormValue("maxdownloadspeed") != "" {
+		_, err = fmt.Sscan(req.FormValue("maxdownloadspeed"), &settings.MaxDownloadSpeed)
+		if err != nil {
+			WriteError(w, Error{"unable to parse maxdownloadspeed: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	// Scan whether download bandwidth is divided fairly. (optional parameter)
+	if req.FormValue("downloadfairness") != "" {
+		_, err = fmt.Sscan(req.FormValue("downloadfairness"), &settings.DownloadFairness)
+		if err != nil {
+			WriteError(w, Error{"unable to parse downloadfairness: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Scan the maximum upload speed. (optional parameter)
+	if req.FormValue("maxuploadspeed") != "" {
+		_, err = fmt.Sscan(req.FormValue("maxuploadspeed"), &settings.MaxUploadSpeed)
+		if err != nil {
+			WriteError(w, Error{"unable to parse maxuploadspeed: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Scan the download cache size. (optional parameter)
+	if req.FormValue("downloadcachesize") != "" {
+		_, err = fmt.Sscan(req.FormValue("downloadcachesize"), &settings.DownloadCacheSize)
+		if err != nil {
+			WriteError(w, Error{"unable to parse downloadcachesize: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Scan the download cache disk size. (optional parameter)
+	if req.FormValue("downloadcachedisksize") != "" {
+		_, err = fmt.Sscan(req.FormValue("downloadcachedisksize"), &settings.DownloadCacheDiskSize)
+		if err != nil {
+			WriteError(w, Error{"unable to parse downloadcachedisksize: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Scan the download overdrive. (optional parameter)
+	if req.FormValue("downloadoverdrive") != "" {
+		_, err = fmt.Sscan(req.FormValue("downloadoverdrive"), &settings.DownloadOverdrive)
+		if err != nil {
+			WriteError(w, Error{"unable to parse downloadoverdrive: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Read the download verification level. (optional parameter)
+	if req.FormValue("downloadverification") != "" {
+		settings.DownloadVerification = modules.DownloadVerification(req.FormValue("downloadverification"))
+	}
+
+	// Scan the host grace period. (optional parameter)
+	if req.FormValue("hostgraceperiod") != "" {
+		_, err = fmt.Sscan(req.FormValue("hostgraceperiod"), &settings.HostGracePeriod)
+		if err != nil {
+			WriteError(w, Error{"unable to parse hostgraceperiod: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Scan whether hosts sharing a subnet are avoided. (optional parameter)
+	if req.FormValue("hostsubnetdiversity") != "" {
+		_, err = fmt.Sscan(req.FormValue("hostsubnetdiversity"), &settings.HostSubnetDiversity)
+		if err != nil {
+			WriteError(w, Error{"unable to parse hostsubnetdiversity: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Scan the number of workers per contract. (optional parameter)
+	if req.FormValue("workerspercontract") != "" {
+		_, err = fmt.Sscan(req.FormValue("workerspercontract"), &settings.WorkersPerContract)
+		if err != nil {
+			WriteError(w, Error{"unable to parse workerspercontract: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Scan the maximum number of revisions per contract. (optional parameter)
+	if req.FormValue("maxcontractrevisions") != "" {
+		_, err = fmt.Sscan(req.FormValue("maxcontractrevisions"), &settings.MaxContractRevisions)
+		if err != nil {
+			WriteError(w, Error{"unable to parse maxcontractrevisions: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Scan the maximum number of contracts replaced per period. (optional
+	// parameter)
+	if req.FormValue("maxcontractchurn") != "" {
+		_, err = fmt.Sscan(req.FormValue("maxcontractchurn"), &settings.MaxContractChurn)
+		if err != nil {
+			WriteError(w, Error{"unable to parse maxcontractchurn: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Scan the repair limits. (optional parameters)
+	if req.FormValue("maxrepairattempts") != "" {
+		_, err = fmt.Sscan(req.FormValue("maxrepairattempts"), &settings.MaxRepairAttempts)
+		if err != nil {
+			WriteError(w, Error{"unable to parse maxrepairattempts: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	if req.FormValue("maxrepairtime") != "" {
+		_, err = fmt.Sscan(req.FormValue("maxrepairtime"), &settings.MaxRepairTime)
+		if err != nil {
+			WriteError(w, Error{"unable to parse maxrepairtime: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Scan the log dedup window. (optional parameter)
+	if req.FormValue("logdedupwindow") != "" {
+		_, err = fmt.Sscan(req.FormValue("logdedupwindow"), &settings.LogDedupWindow)
+		if err != nil {
+			WriteError(w, Error{"unable to parse logdedupwindow: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Scan the structured logging flag. (optional parameter)
+	if req.FormValue("structuredlogging") != "" {
+		_, err = fmt.Sscan(req.FormValue("structuredlogging"), &settings.StructuredLogging)
+		if err != nil {
+			WriteError(w, Error{"unable to parse structuredlogging: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Scan the storage caps. (optional parameters)
+	if req.FormValue("maxstoredbytes") != "" {
+		_, err = fmt.Sscan(req.FormValue("maxstoredbytes"), &settings.MaxStoredBytes)
+		if err != nil {
+			WriteError(w, Error{"unable to parse maxstoredbytes: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	if req.FormValue("maxstoredredundantbytes") != "" {
+		_, err = fmt.Sscan(req.FormValue("maxstoredredundantbytes"), &settings.MaxStoredRedundantBytes)
+		if err != nil {
+			WriteError(w, Error{"unable to parse maxstoredredundantbytes: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Scan the memory limit. (optional parameter)
+	if req.FormValue("maxmemory") != "" {
+		_, err = fmt.Sscan(req.FormValue("maxmemory"), &settings.MaxMemory)
+		if err != nil {
+			WriteError(w, Error{"unable to parse maxmemory: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
 	// Set the settings in the renter.
-	err = api.renter.SetSettings(modules.RenterSettings{
-		Allowance: modules.Allowance{
-			Funds:       funds,
-			Hosts:       hosts,
-			Period:      period,
-			RenewWindow: renewWindow,
-		},
-	})
+	err = api.renter.SetSettings(settings)
 	if err != nil {
 		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
 		return
@@ -223,21 +470,34 @@ func (api *API) renterHandlerPOST(w http.ResponseWriter, req *http.Request, _ ht
 	WriteSuccess(w)
 }
 
+// renterAlertsHandler handles the API call to request the contracts whose
+// formation transaction is in danger of never confirming.
+func (api *API) renterAlertsHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	WriteJSON(w, RenterAlertsGET{
+		Alerts: api.renter.Alerts(),
+	})
+}
+
 // renterContractsHandler handles the API call to request the Renter's contracts.
 func (api *API) renterContractsHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
 	contracts := []RenterContract{}
+	expirations := api.renter.ContractExpirations()
 	for _, c := range api.renter.Contracts() {
 		contracts = append(contracts, RenterContract{
+			BlocksRemaining:  expirations[c.ID].BlocksRemaining,
 			DownloadSpending: c.DownloadSpending,
 			EndHeight:        c.EndHeight(),
+			ExpiryWarning:    expirations[c.ID].ExpiryWarning,
 			Fees:             c.TxnFee.Add(c.SiafundFee).Add(c.ContractFee),
 			HostPublicKey:    c.HostPublicKey,
 			ID:               c.ID,
 			LastTransaction:  c.LastRevisionTxn,
 			NetAddress:       c.NetAddress,
 			RenterFunds:      c.RenterFunds(),
+			RevisionNumber:   c.LastRevision.NewRevisionNumber,
 			Size:             c.LastRevision.NewFileSize,
 			StartHeight:      c.StartHeight,
+			Status:           c.Status,
 			StorageSpending:  c.StorageSpending,
 			TotalCost:        c.TotalCost,
 			UploadSpending:   c.UploadSpending,
@@ -259,6 +519,7 @@ func (api *API) renterDownloadsHandler(w http.ResponseWriter, _ *http.Request, _
 			StartTime:   d.StartTime,
 			Received:    d.Received,
 			Error:       d.Error,
+			Chunks:      d.Chunks,
 		})
 	}
 	// sort the downloads by newest first
@@ -309,6 +570,17 @@ func (api *API) renterRenameHandler(w http.ResponseWriter, req *http.Request, ps
 	WriteSuccess(w)
 }
 
+// renterRetryHandler handles the API call to retry the stuck chunks of a
+// file.
+func (api *API) renterRetryHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	err := api.renter.RetryStuckChunks(strings.TrimPrefix(ps.ByName("siapath"), "/"))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
 // renterFilesHandler handles the API call to list all of the files.
 func (api *API) renterFilesHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	WriteJSON(w, RenterFiles{
@@ -316,6 +588,87 @@ func (api *API) renterFilesHandler(w http.ResponseWriter, req *http.Request, _ h
 	})
 }
 
+// renterHealthHandler handles the API call to summarize the redundancy of the
+// renter's files.
+func (api *API) renterHealthHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	WriteJSON(w, RenterHealthGET{
+		RenterHealth: api.renter.Health(),
+	})
+}
+
+// renterMemoryHandler handles the API call to report how the renter's memory
+// is being used.
+func (api *API) renterMemoryHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	WriteJSON(w, RenterMemoryGET{
+		RenterMemoryBreakdown: api.renter.MemoryBreakdown(),
+	})
+}
+
+// renterMetricsHandler writes the metrics of the renter in the Prometheus text
+// exposition format, so that they can be scraped by monitoring tools.
+func (api *API) renterMetricsHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	var buf bytes.Buffer
+	if err := api.renter.WriteMetrics(&buf); err != nil {
+		WriteError(w, Error{"unable to collect metrics: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	buf.WriteTo(w)
+}
+
+// renterUploadTraceStartHandler handles the API call to start recording an
+// upload trace.
+func (api *API) renterUploadTraceStartHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	path, err := api.renter.StartUploadTrace()
+	if err != nil {
+		WriteError(w, Error{"unable to start upload trace: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, RenterUploadTracePOST{Path: path})
+}
+
+// renterUploadTraceStopHandler handles the API call to stop recording the
+// upload trace.
+func (api *API) renterUploadTraceStopHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	if err := api.renter.StopUploadTrace(); err != nil {
+		WriteError(w, Error{"unable to stop upload trace: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// renterBackupHandler handles the API call to create a metadata backup.
+func (api *API) renterBackupHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	siapath, err := api.renter.CreateBackup()
+	if err != nil {
+		WriteError(w, Error{"unable to create backup: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, RenterBackupPOST{SiaPath: siapath})
+}
+
+// renterBackupRestoreHandler handles the API call to restore the renter from
+// its most recent metadata backup.
+func (api *API) renterBackupRestoreHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	siapaths, err := api.renter.RestoreBackup()
+	if err != nil {
+		WriteError(w, Error{"unable to restore backup: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, RenterBackupRestorePOST{SiaPaths: siapaths})
+}
+
+// renterRecoverContractsHandler handles the API call to recover the contracts
+// formed with keys derived from the wallet seed.
+func (api *API) renterRecoverContractsHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	n, err := api.renter.RecoverContracts()
+	if err != nil {
+		WriteError(w, Error{"unable to recover contracts: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, RenterRecoverContractsPOST{Recovered: n})
+}
+
 // renterPricesHandler reports the expected costs of various actions given the
 // renter settings and the set of available hosts.
 func (api *API) renterPricesHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
@@ -435,6 +788,9 @@ func parseDownloadParameters(w http.ResponseWriter, req *http.Request, ps httpro
 		Length:      length,
 		Offset:      offset,
 		Siapath:     siapath,
+
+		IncompletePolicy: modules.DownloadIncompletePolicy(req.FormValue("incompletepolicy")),
+		Priority:         modules.DownloadPriority(req.FormValue("priority")),
 	}
 	if httpresp {
 		dp.Httpwriter = w
@@ -525,12 +881,63 @@ func (api *API) renterUploadHandler(w http.ResponseWriter, req *http.Request, ps
 		}
 	}
 
+	// Check whether the upload should ignore the storage caps.
+	var force bool
+	if req.FormValue("force") != "" {
+		_, err := fmt.Sscan(req.FormValue("force"), &force)
+		if err != nil {
+			WriteError(w, Error{"unable to parse force: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Parse the tags of the file. Every tag is supplied as a separate 'tag'
+	// parameter of the form key=value.
+	var tags map[string]string
+	for _, tag := range req.Form["tag"] {
+		kv := strings.SplitN(tag, "=", 2)
+		if len(kv) != 2 {
+			WriteError(w, Error{"unable to parse tag: expected key=value, got " + tag}, http.StatusBadRequest)
+			return
+		}
+		if tags == nil {
+			tags = make(map[string]string)
+		}
+		tags[kv[0]] = kv[1]
+	}
+
+	// Check whether the call should block until the file reaches a
+	// redundancy.
+	var waitRedundancy float64
+	switch req.FormValue("waitredundancy") {
+	case "":
+	case "recoverable":
+		waitRedundancy = 1
+	case "full":
+		waitRedundancy = math.Inf(1)
+	default:
+		_, err := fmt.Sscan(req.FormValue("waitredundancy"), &waitRedundancy)
+		if err != nil {
+			WriteError(w, Error{"unable to parse waitredundancy: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
 	// Call the renter to upload the file.
-	err := api.renter.Upload(modules.FileUploadParams{
-		Source:      source,
-		SiaPath:     strings.TrimPrefix(ps.ByName("siapath"), "/"),
-		ErasureCode: ec,
-	})
+	up := modules.FileUploadParams{
+		Source:          source,
+		SiaPath:         strings.TrimPrefix(ps.ByName("siapath"), "/"),
+		ErasureCode:     ec,
+		CollisionPolicy: modules.UploadCollisionPolicy(req.FormValue("collisionpolicy")),
+		Force:           force,
+		Tags:            tags,
+	}
+	var err error
+	if req.FormValue("waitredundancy") != "" {
+		err = api.renter.UploadWait(up, waitRedundancy, req.Context().Done())
+	} else {
+		err = api.renter.Upload(up)
+	}
 	if err != nil {
 		WriteError(w, Error{"upload failed: " + err.Error()}, http.StatusInternalServerError)
 		return
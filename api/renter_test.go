@@ -492,6 +492,202 @@ func TestRenterAsyncDownload(t *testing.T) {
 	}
 }
 
+// TestRenterMemoryBreakdown checks that memory held by pieces waiting to be
+// uploaded is reported in the upload sector category of the renter's memory
+// breakdown.
+func TestRenterMemoryBreakdown(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Start an upload that spans many chunks.
+	st, _ := setupTestDownload(t, 1e6, "test.dat", false)
+	defer st.server.panicClose()
+
+	// While the upload is in progress, the upload sector category should
+	// show nonzero usage.
+	observed := false
+	for start := time.Now(); time.Since(start) < 60*time.Second; time.Sleep(time.Millisecond) {
+		mb := st.renter.MemoryBreakdown()
+		if mb.UploadSectors > 0 {
+			observed = true
+			break
+		}
+		if mb.Available > mb.Total {
+			t.Fatal("renter reports more memory available than its total:", mb)
+		}
+	}
+	if !observed {
+		t.Fatal("upload sector memory was never in use during the upload")
+	}
+
+	// Once the upload has finished, all memory should be returned.
+	err := retry(600, 100*time.Millisecond, func() error {
+		var rf RenterFiles
+		st.getAPI("/renter/files", &rf)
+		if len(rf.Files) != 1 || !rf.Files[0].Available {
+			return errors.New("upload has not finished")
+		}
+		mb := st.renter.MemoryBreakdown()
+		if mb.Available != mb.Total || mb.Encoding != 0 || mb.UploadSectors != 0 || mb.DownloadSectors != 0 {
+			return fmt.Errorf("memory was not returned after the upload: %+v", mb)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestRenterDownloadToMirrors checks that a file can be downloaded to multiple
+// destinations at once, and that every destination receives the full file.
+func TestRenterDownloadToMirrors(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	st, path := setupTestDownload(t, 1e4, "test.dat", true)
+	defer st.server.panicClose()
+
+	var buf1, buf2 bytes.Buffer
+	err := st.renter.DownloadToMirrors("test.dat", []io.Writer{&buf1, &buf2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf1.Bytes(), orig) || !bytes.Equal(buf2.Bytes(), orig) {
+		t.Fatal("mirrored downloads do not match the original file")
+	}
+}
+
+// TestRenterDownloadSection checks that a section of a file can be downloaded
+// to an io.Writer, and that a failing writer aborts the download.
+func TestRenterDownloadSection(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	st, path := setupTestDownload(t, 1e4, "test.dat", true)
+	defer st.server.panicClose()
+	orig, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := st.renter.DownloadSection("test.dat", &buf, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), orig) {
+		t.Fatal("downloaded file does not match the original file")
+	}
+	buf.Reset()
+	if err := st.renter.DownloadSection("test.dat", &buf, 1234, 5678); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), orig[1234:1234+5678]) {
+		t.Fatal("downloaded section does not match the original file")
+	}
+
+	// Out of range sections should be rejected.
+	if err := st.renter.DownloadSection("test.dat", &buf, 1e4-1, 2); err == nil {
+		t.Fatal("expected out of range section to be rejected")
+	}
+
+	// The error of a failing writer should be returned.
+	errGone := errors.New("reader gone")
+	pr, pw := io.Pipe()
+	pr.CloseWithError(errGone)
+	if err := st.renter.DownloadSection("test.dat", pw, 0, 0); err != errGone {
+		t.Fatal("expected the writer's error, got", err)
+	}
+}
+
+// TestRenterDownloadResult checks that a multi-chunk download reports the
+// outcome of every chunk.
+func TestRenterDownloadResult(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// With one data piece, a file spanning several sectors is split into
+	// several chunks.
+	st, _ := setupTestDownload(t, int(3*modules.SectorSize), "test.dat", true)
+	defer st.server.panicClose()
+
+	destination := filepath.Join(build.SiaTestingDir, "api", t.Name(), "test.dat.download")
+	result, err := st.renter.DownloadWithResult(modules.RenterDownloadParameters{
+		Siapath:     "test.dat",
+		Destination: destination,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.SiaPath != "test.dat" || result.Duration == 0 {
+		t.Fatal("download result is missing its summary:", result)
+	}
+	if len(result.Chunks) < 3 {
+		t.Fatal("expected at least 3 chunk results, got", len(result.Chunks))
+	}
+	for i, chunk := range result.Chunks {
+		if chunk.Index != uint64(i) {
+			t.Fatalf("expected chunk %v, got chunk %v", i, chunk.Index)
+		}
+		if len(chunk.Hosts) != 1 {
+			t.Fatalf("expected chunk %v to come from 1 host, got %v", i, len(chunk.Hosts))
+		}
+		if chunk.Bytes < modules.SectorSize {
+			t.Fatalf("expected chunk %v to have fetched at least a sector, got %v bytes", i, chunk.Bytes)
+		}
+		if chunk.Duration == 0 || !chunk.Verified {
+			t.Fatalf("chunk %v was not reported as verified: %v", i, chunk)
+		}
+	}
+
+	// The same details should be visible in the download queue.
+	var rdq RenterDownloadQueue
+	err = st.getAPI("/renter/downloads", &rdq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rdq.Downloads) != 1 || len(rdq.Downloads[0].Chunks) != len(result.Chunks) {
+		t.Fatal("download queue does not report the chunk results:", rdq.Downloads)
+	}
+}
+
+// TestRenterSelfTest checks that the renter's self test passes when the renter
+// has a healthy host, and that the probe file is cleaned up afterwards.
+func TestRenterSelfTest(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	st, _ := setupTestDownload(t, 1e4, "test.dat", true)
+	defer st.server.panicClose()
+
+	report, err := st.renter.SelfTest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.UploadTime == 0 || report.DownloadTime == 0 {
+		t.Fatal("self test did not report its timing:", report)
+	}
+
+	// Only the user's file should remain.
+	files := st.renter.FileList()
+	if len(files) != 1 || files[0].SiaPath != "test.dat" {
+		t.Fatal("self test interfered with the user's files:", files)
+	}
+}
+
 // TestRenterPaths tests that the /renter routes handle path parameters
 // properly.
 func TestRenterPaths(t *testing.T) {
@@ -1154,6 +1350,28 @@ func TestRenterRelativePathErrorDownload(t *testing.T) {
 	}
 }
 
+// TestRenterAlertsHandler checks that /renter/alerts reports no alerts while
+// there are no contract transactions in danger of never confirming.
+func TestRenterAlertsHandler(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	st, err := createServerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.server.panicClose()
+
+	var rag RenterAlertsGET
+	if err := st.getAPI("/renter/alerts", &rag); err != nil {
+		t.Fatal(err)
+	}
+	if rag.Alerts == nil || len(rag.Alerts) != 0 {
+		t.Fatal("expected an empty list of alerts, got", rag.Alerts)
+	}
+}
+
 // TestRenterPricesHandler checks that the prices command returns reasonable
 // values given the settings of the hosts.
 func TestRenterPricesHandler(t *testing.T) {
@@ -27,8 +27,14 @@ func (api *API) buildHttpRoutes(requiredUserAgent string, requiredPassword strin
 	// Explorer API Calls
 	if api.explorer != nil {
 		router.GET("/explorer", api.explorerHandler)
+		router.GET("/explorer/addresses/:address", api.explorerAddressHandler)
 		router.GET("/explorer/blocks/:height", api.explorerBlocksHandler)
 		router.GET("/explorer/hashes/:hash", api.explorerHashHandler)
+		router.GET("/explorer/integrity", api.explorerIntegrityHandler)
+		router.GET("/explorer/search", api.explorerSearchHandler)
+		router.GET("/explorer/timeseries", api.explorerTimeSeriesHandler)
+		router.GET("/explorer/unconfirmed/addresses/:address", api.explorerUnconfirmedAddressHandler)
+		router.GET("/explorer/unconfirmed/transactions/:id", api.explorerUnconfirmedTransactionHandler)
 	}
 
 	// Gateway API Calls
@@ -45,10 +51,15 @@ func (api *API) buildHttpRoutes(requiredUserAgent string, requiredPassword strin
 		router.POST("/host", RequirePassword(api.hostHandlerPOST, requiredPassword))              // Change the settings of the host.
 		router.POST("/host/announce", RequirePassword(api.hostAnnounceHandler, requiredPassword)) // Announce the host to the network.
 		router.GET("/host/estimatescore", api.hostEstimateScoreGET)
+		router.GET("/host/bandwidth", api.hostBandwidthHandlerGET)
+		router.GET("/host/pricing", api.hostPricingHandlerGET)
+		router.POST("/host/pricing", RequirePassword(api.hostPricingHandlerPOST, requiredPassword))
 
 		// Calls pertaining to the storage manager that the host uses.
+		router.GET("/host/scrub", api.hostScrubHandlerGET)
 		router.GET("/host/storage", api.storageHandler)
 		router.POST("/host/storage/folders/add", RequirePassword(api.storageFoldersAddHandler, requiredPassword))
+		router.POST("/host/storage/folders/move", RequirePassword(api.storageFoldersMoveHandler, requiredPassword))
 		router.POST("/host/storage/folders/remove", RequirePassword(api.storageFoldersRemoveHandler, requiredPassword))
 		router.POST("/host/storage/folders/resize", RequirePassword(api.storageFoldersResizeHandler, requiredPassword))
 		router.POST("/host/storage/sectors/delete/:merkleroot", RequirePassword(api.storageSectorsDeleteHandler, requiredPassword))
@@ -67,9 +78,13 @@ func (api *API) buildHttpRoutes(requiredUserAgent string, requiredPassword strin
 	if api.renter != nil {
 		router.GET("/renter", api.renterHandlerGET)
 		router.POST("/renter", RequirePassword(api.renterHandlerPOST, requiredPassword))
+		router.GET("/renter/alerts", api.renterAlertsHandler)
 		router.GET("/renter/contracts", api.renterContractsHandler)
 		router.GET("/renter/downloads", api.renterDownloadsHandler)
 		router.GET("/renter/files", api.renterFilesHandler)
+		router.GET("/renter/health", api.renterHealthHandler)
+		router.GET("/renter/memory", api.renterMemoryHandler)
+		router.GET("/renter/metrics", api.renterMetricsHandler)
 		router.GET("/renter/prices", api.renterPricesHandler)
 
 		// TODO: re-enable these routes once the new .sia format has been
@@ -79,11 +94,17 @@ func (api *API) buildHttpRoutes(requiredUserAgent string, requiredPassword strin
 		// router.GET("/renter/share", RequirePassword(api.renterShareHandler, requiredPassword))
 		// router.GET("/renter/shareascii", RequirePassword(api.renterShareAsciiHandler, requiredPassword))
 
+		router.POST("/renter/backup", RequirePassword(api.renterBackupHandler, requiredPassword))
+		router.POST("/renter/backup/restore", RequirePassword(api.renterBackupRestoreHandler, requiredPassword))
+		router.POST("/renter/recovercontracts", RequirePassword(api.renterRecoverContractsHandler, requiredPassword))
 		router.POST("/renter/delete/*siapath", RequirePassword(api.renterDeleteHandler, requiredPassword))
 		router.GET("/renter/download/*siapath", RequirePassword(api.renterDownloadHandler, requiredPassword))
 		router.GET("/renter/downloadasync/*siapath", RequirePassword(api.renterDownloadAsyncHandler, requiredPassword))
 		router.POST("/renter/rename/*siapath", RequirePassword(api.renterRenameHandler, requiredPassword))
+		router.POST("/renter/retry/*siapath", RequirePassword(api.renterRetryHandler, requiredPassword))
 		router.POST("/renter/upload/*siapath", RequirePassword(api.renterUploadHandler, requiredPassword))
+		router.POST("/renter/uploadtrace/start", RequirePassword(api.renterUploadTraceStartHandler, requiredPassword))
+		router.POST("/renter/uploadtrace/stop", RequirePassword(api.renterUploadTraceStopHandler, requiredPassword))
 
 		// HostDB endpoints.
 		router.GET("/hostdb/active", api.hostdbActiveHandler)
@@ -108,9 +129,16 @@ func (api *API) buildHttpRoutes(requiredUserAgent string, requiredPassword strin
 		router.GET("/wallet/address", RequirePassword(api.walletAddressHandler, requiredPassword))
 		router.GET("/wallet/addresses", api.walletAddressesHandler)
 		router.GET("/wallet/backup", RequirePassword(api.walletBackupHandler, requiredPassword))
+		router.POST("/wallet/gaplimit", RequirePassword(api.walletGapLimitHandler, requiredPassword))
 		router.POST("/wallet/init", RequirePassword(api.walletInitHandler, requiredPassword))
 		router.POST("/wallet/init/seed", RequirePassword(api.walletInitSeedHandler, requiredPassword))
 		router.POST("/wallet/lock", RequirePassword(api.walletLockHandler, requiredPassword))
+		router.GET("/wallet/multisig", api.walletMultisigHandlerGET)
+		router.POST("/wallet/multisig", RequirePassword(api.walletMultisigHandlerPOST, requiredPassword))
+		router.POST("/wallet/multisig/sign", RequirePassword(api.walletMultisigSignHandler, requiredPassword))
+		router.POST("/wallet/multisig/transaction", RequirePassword(api.walletMultisigTransactionHandler, requiredPassword))
+		router.GET("/wallet/publickey", RequirePassword(api.walletPublicKeyHandler, requiredPassword))
+		router.GET("/wallet/rescan", api.walletRescanHandler)
 		router.POST("/wallet/seed", RequirePassword(api.walletSeedHandler, requiredPassword))
 		router.GET("/wallet/seeds", RequirePassword(api.walletSeedsHandler, requiredPassword))
 		router.POST("/wallet/siacoins", RequirePassword(api.walletSiacoinsHandler, requiredPassword))
@@ -118,9 +146,16 @@ func (api *API) buildHttpRoutes(requiredUserAgent string, requiredPassword strin
 		router.POST("/wallet/siagkey", RequirePassword(api.walletSiagkeyHandler, requiredPassword))
 		router.POST("/wallet/sweep/seed", RequirePassword(api.walletSweepSeedHandler, requiredPassword))
 		router.GET("/wallet/transaction/:id", api.walletTransactionHandler)
+		router.POST("/wallet/transactionfile/broadcast", RequirePassword(api.walletTransactionFileBroadcastHandler, requiredPassword))
+		router.POST("/wallet/transactionfile/create", RequirePassword(api.walletTransactionFileCreateHandler, requiredPassword))
+		router.POST("/wallet/transactionfile/sign", RequirePassword(api.walletTransactionFileSignHandler, requiredPassword))
 		router.GET("/wallet/transactions", api.walletTransactionsHandler)
 		router.GET("/wallet/transactions/:addr", api.walletTransactionsAddrHandler)
 		router.GET("/wallet/verify/address/:addr", api.walletVerifyAddressHandler)
+		router.GET("/wallet/watch", api.walletWatchHandlerGET)
+		router.POST("/wallet/watch", RequirePassword(api.walletWatchHandlerPOST, requiredPassword))
+		router.GET("/wallet/watch/transactions", api.walletWatchTransactionsHandler)
+		router.GET("/wallet/unspent", api.walletUnspentHandler)
 		router.POST("/wallet/unlock", RequirePassword(api.walletUnlockHandler, requiredPassword))
 		router.POST("/wallet/changepassword", RequirePassword(api.walletChangePasswordHandler, requiredPassword))
 	}
@@ -331,11 +331,15 @@ func assembleExplorerServerTester(testdir string) (*serverTester, error) {
 	if err != nil {
 		return nil, err
 	}
-	e, err := explorer.New(cs, filepath.Join(testdir, modules.ExplorerDir))
+	tp, err := transactionpool.New(cs, g, filepath.Join(testdir, modules.TransactionPoolDir))
+	if err != nil {
+		return nil, err
+	}
+	e, err := explorer.New(cs, tp, filepath.Join(testdir, modules.ExplorerDir))
 	if err != nil {
 		return nil, err
 	}
-	srv, err := NewServer("localhost:0", "", "", cs, e, g, nil, nil, nil, nil, nil)
+	srv, err := NewServer("localhost:0", "", "", cs, e, g, nil, nil, nil, tp, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -345,6 +349,7 @@ func assembleExplorerServerTester(testdir string) (*serverTester, error) {
 		cs:       cs,
 		explorer: e,
 		gateway:  g,
+		tpool:    tp,
 
 		server: srv,
 
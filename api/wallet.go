@@ -62,6 +62,37 @@ type (
 		TransactionIDs []types.TransactionID `json:"transactionids"`
 	}
 
+	// WalletMultisigGET contains the multisig accounts of the wallet
+	// returned by a GET call to /wallet/multisig.
+	WalletMultisigGET struct {
+		Accounts []modules.MultisigAccount `json:"accounts"`
+	}
+
+	// WalletMultisigPOST contains the multisig account created or added by
+	// a POST call to /wallet/multisig.
+	WalletMultisigPOST struct {
+		Account modules.MultisigAccount `json:"account"`
+	}
+
+	// WalletMultisigTransactionPOST contains the partially signed
+	// transaction returned by a POST call to /wallet/multisig/transaction or
+	// /wallet/multisig/sign.
+	WalletMultisigTransactionPOST struct {
+		Transaction types.Transaction `json:"transaction"`
+	}
+
+	// WalletPublicKeyGET contains a public key of the wallet returned by a
+	// GET call to /wallet/publickey.
+	WalletPublicKeyGET struct {
+		PublicKey types.SiaPublicKey `json:"publickey"`
+	}
+
+	// WalletRescanGET contains the progress of a rescan of the blockchain
+	// returned by a GET call to /wallet/rescan.
+	WalletRescanGET struct {
+		modules.WalletRescanStatus
+	}
+
 	// WalletSeedsGET contains the seeds used by the wallet.
 	WalletSeedsGET struct {
 		PrimarySeed        string   `json:"primaryseed"`
@@ -76,6 +107,12 @@ type (
 		Funds types.Currency `json:"funds"`
 	}
 
+	// WalletTransactionFileBroadcastPOST contains the ID of the transaction
+	// broadcast by a POST call to /wallet/transactionfile/broadcast.
+	WalletTransactionFileBroadcastPOST struct {
+		TransactionID types.TransactionID `json:"transactionid"`
+	}
+
 	// WalletTransactionGETid contains the transaction returned by a call to
 	// /wallet/transaction/:id
 	WalletTransactionGETid struct {
@@ -97,11 +134,30 @@ type (
 		UnconfirmedTransactions []modules.ProcessedTransaction `json:"unconfirmedtransactions"`
 	}
 
+	// WalletUnspentGET contains the confirmed siacoin outputs of the wallet
+	// returned by a GET call to /wallet/unspent.
+	WalletUnspentGET struct {
+		Outputs []modules.UnspentOutput `json:"outputs"`
+	}
+
 	// WalletVerifyAddressGET contains a bool indicating if the address passed to
 	// /wallet/verify/address/:addr is a valid address.
 	WalletVerifyAddressGET struct {
 		Valid bool `json:"valid"`
 	}
+
+	// WalletWatchGET contains the watch-only addresses of the wallet returned
+	// by a GET call to /wallet/watch.
+	WalletWatchGET struct {
+		Addresses []modules.WatchedAddress `json:"addresses"`
+	}
+
+	// WalletWatchTransactionsGET contains the confirmed transactions of the
+	// watch-only addresses returned by a GET call to
+	// /wallet/watch/transactions.
+	WalletWatchTransactionsGET struct {
+		Transactions []modules.ProcessedTransaction `json:"transactions"`
+	}
 )
 
 // encryptionKeys enumerates the possible encryption keys that can be derived
@@ -264,6 +320,169 @@ func (api *API) walletInitSeedHandler(w http.ResponseWriter, req *http.Request,
 	WriteSuccess(w)
 }
 
+// walletGapLimitHandler handles API calls to /wallet/gaplimit.
+func (api *API) walletGapLimitHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	gapLimit, err := strconv.ParseUint(req.FormValue("gaplimit"), 10, 64)
+	if err != nil {
+		WriteError(w, Error{"could not read 'gaplimit' from POST call to /wallet/gaplimit: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	err = api.wallet.SetGapLimit(gapLimit)
+	if err != nil {
+		WriteError(w, Error{"error when calling /wallet/gaplimit: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// walletMultisigHandlerGET handles GET calls to /wallet/multisig.
+func (api *API) walletMultisigHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	accounts, err := api.wallet.MultisigAccounts()
+	if err != nil {
+		WriteError(w, Error{"error when calling /wallet/multisig: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, WalletMultisigGET{
+		Accounts: accounts,
+	})
+}
+
+// walletMultisigHandlerPOST handles POST calls to /wallet/multisig.
+func (api *API) walletMultisigHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	var account modules.MultisigAccount
+	if req.FormValue("unlockconditions") != "" {
+		// add an account created by a cosigner
+		if req.FormValue("signaturesrequired") != "" || req.FormValue("publickeys") != "" {
+			WriteError(w, Error{"cannot supply both 'unlockconditions' and 'signaturesrequired'+'publickeys'"}, http.StatusBadRequest)
+			return
+		}
+		var uc types.UnlockConditions
+		err := json.Unmarshal([]byte(req.FormValue("unlockconditions")), &uc)
+		if err != nil {
+			WriteError(w, Error{"could not decode unlockconditions: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		account, err = api.wallet.AddMultisigAccount(uc)
+		if err != nil {
+			WriteError(w, Error{"error when calling /wallet/multisig: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	} else {
+		// create a new account from the public keys of the cosigners
+		signaturesRequired, err := strconv.ParseUint(req.FormValue("signaturesrequired"), 10, 64)
+		if err != nil {
+			WriteError(w, Error{"could not read 'signaturesrequired' from POST call to /wallet/multisig: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		var pks []types.SiaPublicKey
+		err = json.Unmarshal([]byte(req.FormValue("publickeys")), &pks)
+		if err != nil {
+			WriteError(w, Error{"could not decode publickeys: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		account, err = api.wallet.CreateMultisigAccount(signaturesRequired, pks)
+		if err != nil {
+			WriteError(w, Error{"error when calling /wallet/multisig: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	WriteJSON(w, WalletMultisigPOST{
+		Account: account,
+	})
+}
+
+// walletMultisigSignHandler handles API calls to /wallet/multisig/sign.
+func (api *API) walletMultisigSignHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	var txn types.Transaction
+	err := json.Unmarshal([]byte(req.FormValue("transaction")), &txn)
+	if err != nil {
+		WriteError(w, Error{"could not decode transaction: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	var broadcast bool
+	if req.FormValue("broadcast") != "" {
+		broadcast, err = strconv.ParseBool(req.FormValue("broadcast"))
+		if err != nil {
+			WriteError(w, Error{"unable to parse 'broadcast' arg: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	txn, err = api.wallet.AddSignature(txn)
+	if err != nil {
+		WriteError(w, Error{"error when calling /wallet/multisig/sign: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	if broadcast {
+		if api.tpool == nil {
+			WriteError(w, Error{"cannot broadcast transaction without a transaction pool"}, http.StatusBadRequest)
+			return
+		}
+		err = api.tpool.AcceptTransactionSet([]types.Transaction{txn})
+		if err != nil && err != modules.ErrDuplicateTransactionSet {
+			WriteError(w, Error{"error accepting transaction: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	WriteJSON(w, WalletMultisigTransactionPOST{
+		Transaction: txn,
+	})
+}
+
+// walletMultisigTransactionHandler handles API calls to
+// /wallet/multisig/transaction.
+func (api *API) walletMultisigTransactionHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	addr, err := scanAddress(req.FormValue("address"))
+	if err != nil {
+		WriteError(w, Error{"could not read address from POST call to /wallet/multisig/transaction"}, http.StatusBadRequest)
+		return
+	}
+	var outputs []types.SiacoinOutput
+	err = json.Unmarshal([]byte(req.FormValue("outputs")), &outputs)
+	if err != nil {
+		WriteError(w, Error{"could not decode outputs: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	var fee types.Currency
+	if req.FormValue("fee") != "" {
+		var ok bool
+		fee, ok = scanAmount(req.FormValue("fee"))
+		if !ok {
+			WriteError(w, Error{"could not read fee from POST call to /wallet/multisig/transaction"}, http.StatusBadRequest)
+			return
+		}
+	}
+	txn, err := api.wallet.BuildMultisigTransaction(addr, outputs, fee)
+	if err != nil {
+		WriteError(w, Error{"error when calling /wallet/multisig/transaction: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, WalletMultisigTransactionPOST{
+		Transaction: txn,
+	})
+}
+
+// walletPublicKeyHandler handles API calls to /wallet/publickey.
+func (api *API) walletPublicKeyHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	uc, err := api.wallet.NextAddress()
+	if err != nil {
+		WriteError(w, Error{"error when calling /wallet/publickey: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, WalletPublicKeyGET{
+		PublicKey: uc.PublicKeys[0],
+	})
+}
+
+// walletRescanHandler handles API calls to /wallet/rescan.
+func (api *API) walletRescanHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	status, err := api.wallet.RescanStatus()
+	if err != nil {
+		WriteError(w, Error{"error when calling /wallet/rescan: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, WalletRescanGET{status})
+}
+
 // walletSeedHandler handles API calls to /wallet/seed.
 func (api *API) walletSeedHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	// Get the seed using the ditionary + phrase
@@ -380,6 +599,10 @@ func (api *API) walletSiacoinsHandler(w http.ResponseWriter, req *http.Request,
 			WriteError(w, Error{"cannot supply both 'outputs' and single amount+destination pair"}, http.StatusInternalServerError)
 			return
 		}
+		if req.FormValue("outputids") != "" {
+			WriteError(w, Error{"cannot supply both 'outputs' and 'outputids'"}, http.StatusBadRequest)
+			return
+		}
 
 		var outputs []types.SiacoinOutput
 		err := json.Unmarshal([]byte(req.FormValue("outputs")), &outputs)
@@ -405,7 +628,18 @@ func (api *API) walletSiacoinsHandler(w http.ResponseWriter, req *http.Request,
 			return
 		}
 
-		txns, err = api.wallet.SendSiacoins(amount, dest)
+		if req.FormValue("outputids") != "" {
+			// spend exactly the selected outputs
+			var ids []types.SiacoinOutputID
+			err = json.Unmarshal([]byte(req.FormValue("outputids")), &ids)
+			if err != nil {
+				WriteError(w, Error{"could not decode outputids: " + err.Error()}, http.StatusBadRequest)
+				return
+			}
+			txns, err = api.wallet.SendSiacoinsFromOutputs(amount, dest, ids)
+		} else {
+			txns, err = api.wallet.SendSiacoins(amount, dest)
+		}
 		if err != nil {
 			WriteError(w, Error{"error when calling /wallet/siacoins: " + err.Error()}, http.StatusInternalServerError)
 			return
@@ -473,6 +707,79 @@ func (api *API) walletSweepSeedHandler(w http.ResponseWriter, req *http.Request,
 	})
 }
 
+// walletTransactionFileBroadcastHandler handles API calls to
+// /wallet/transactionfile/broadcast.
+func (api *API) walletTransactionFileBroadcastHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	source := req.FormValue("source")
+	// Check that the source is absolute.
+	if !filepath.IsAbs(source) {
+		WriteError(w, Error{"error when calling /wallet/transactionfile/broadcast: source must be an absolute path"}, http.StatusBadRequest)
+		return
+	}
+	txn, err := api.wallet.BroadcastTransactionFile(source)
+	if err != nil {
+		WriteError(w, Error{"error when calling /wallet/transactionfile/broadcast: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, WalletTransactionFileBroadcastPOST{
+		TransactionID: txn.ID(),
+	})
+}
+
+// walletTransactionFileCreateHandler handles API calls to
+// /wallet/transactionfile/create.
+func (api *API) walletTransactionFileCreateHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	destination := req.FormValue("destination")
+	// Check that the destination is absolute.
+	if !filepath.IsAbs(destination) {
+		WriteError(w, Error{"error when calling /wallet/transactionfile/create: destination must be an absolute path"}, http.StatusBadRequest)
+		return
+	}
+	addr, err := scanAddress(req.FormValue("address"))
+	if err != nil {
+		WriteError(w, Error{"could not read address from POST call to /wallet/transactionfile/create"}, http.StatusBadRequest)
+		return
+	}
+	var outputs []types.SiacoinOutput
+	err = json.Unmarshal([]byte(req.FormValue("outputs")), &outputs)
+	if err != nil {
+		WriteError(w, Error{"could not decode outputs: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	var fee types.Currency
+	if req.FormValue("fee") != "" {
+		var ok bool
+		fee, ok = scanAmount(req.FormValue("fee"))
+		if !ok {
+			WriteError(w, Error{"could not read fee from POST call to /wallet/transactionfile/create"}, http.StatusBadRequest)
+			return
+		}
+	}
+	err = api.wallet.CreateTransactionFile(destination, addr, outputs, fee)
+	if err != nil {
+		WriteError(w, Error{"error when calling /wallet/transactionfile/create: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// walletTransactionFileSignHandler handles API calls to
+// /wallet/transactionfile/sign.
+func (api *API) walletTransactionFileSignHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	source := req.FormValue("source")
+	// Check that the source is absolute.
+	if !filepath.IsAbs(source) {
+		WriteError(w, Error{"error when calling /wallet/transactionfile/sign: source must be an absolute path"}, http.StatusBadRequest)
+		return
+	}
+	err := api.wallet.SignTransactionFile(source)
+	if err != nil {
+		WriteError(w, Error{"error when calling /wallet/transactionfile/sign: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
 // walletTransactionHandler handles API calls to /wallet/transaction/:id.
 func (api *API) walletTransactionHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
 	// Parse the id from the url.
@@ -587,6 +894,18 @@ func (api *API) walletChangePasswordHandler(w http.ResponseWriter, req *http.Req
 	WriteError(w, Error{"error when calling /wallet/changepassword: " + modules.ErrBadEncryptionKey.Error()}, http.StatusBadRequest)
 }
 
+// walletUnspentHandler handles API calls to /wallet/unspent.
+func (api *API) walletUnspentHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	outputs, err := api.wallet.UnspentOutputs()
+	if err != nil {
+		WriteError(w, Error{"error when calling /wallet/unspent: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, WalletUnspentGET{
+		Outputs: outputs,
+	})
+}
+
 // walletVerifyAddressHandler handles API calls to /wallet/verify/address/:addr.
 func (api *API) walletVerifyAddressHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
 	addrString := ps.ByName("addr")
@@ -594,3 +913,71 @@ func (api *API) walletVerifyAddressHandler(w http.ResponseWriter, req *http.Requ
 	err := new(types.UnlockHash).LoadString(addrString)
 	WriteJSON(w, WalletVerifyAddressGET{Valid: err == nil})
 }
+
+// walletWatchHandlerGET handles GET calls to /wallet/watch.
+func (api *API) walletWatchHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	addrs, err := api.wallet.WatchedAddresses()
+	if err != nil {
+		WriteError(w, Error{"error when calling /wallet/watch: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, WalletWatchGET{
+		Addresses: addrs,
+	})
+}
+
+// walletWatchHandlerPOST handles POST calls to /wallet/watch.
+func (api *API) walletWatchHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	var addrs []types.UnlockHash
+	err := json.Unmarshal([]byte(req.FormValue("addresses")), &addrs)
+	if err != nil {
+		WriteError(w, Error{"could not decode addresses: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	var remove bool
+	if req.FormValue("remove") != "" {
+		remove, err = strconv.ParseBool(req.FormValue("remove"))
+		if err != nil {
+			WriteError(w, Error{"unable to parse 'remove' arg: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	if remove {
+		err = api.wallet.UnwatchAddresses(addrs)
+	} else {
+		err = api.wallet.WatchAddresses(addrs)
+	}
+	if err != nil {
+		WriteError(w, Error{"error when calling /wallet/watch: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// walletWatchTransactionsHandler handles API calls to
+// /wallet/watch/transactions.
+func (api *API) walletWatchTransactionsHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	startheightStr, endheightStr := req.FormValue("startheight"), req.FormValue("endheight")
+	if startheightStr == "" || endheightStr == "" {
+		WriteError(w, Error{"startheight and endheight must be provided to a /wallet/watch/transactions call."}, http.StatusBadRequest)
+		return
+	}
+	start, err := strconv.Atoi(startheightStr)
+	if err != nil {
+		WriteError(w, Error{"parsing integer value for parameter `startheight` failed: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	end, err := strconv.Atoi(endheightStr)
+	if err != nil {
+		WriteError(w, Error{"parsing integer value for parameter `endheight` failed: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	txns, err := api.wallet.WatchedTransactions(types.BlockHeight(start), types.BlockHeight(end))
+	if err != nil {
+		WriteError(w, Error{"error when calling /wallet/watch/transactions: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, WalletWatchTransactionsGET{
+		Transactions: txns,
+	})
+}
@@ -81,8 +81,17 @@ To configure the host to accept new contracts, set acceptingcontracts to true:
 
 	hostFolderCmd = &cobra.Command{
 		Use:   "folder",
-		Short: "Add, remove, or resize a storage folder",
-		Long:  "Add, remove, or resize a storage folder.",
+		Short: "Add, move, remove, or resize a storage folder",
+		Long:  "Add, move, remove, or resize a storage folder.",
+	}
+
+	hostFolderMoveCmd = &cobra.Command{
+		Use:   "move [path] [newpath]",
+		Short: "Move a storage folder to a new path",
+		Long: `Move a storage folder to a new path, for example to put it on another disk.
+The data is migrated while the host keeps serving it; run 'siac host' to
+follow the progress.`,
+		Run: wrap(hostfoldermovecmd),
 	}
 
 	hostFolderRemoveCmd = &cobra.Command{
@@ -298,7 +307,11 @@ RPC Stats:
 	for _, folder := range sg.Folders {
 		curSize := int64(folder.Capacity - folder.CapacityRemaining)
 		pctUsed := 100 * (float64(curSize) / float64(folder.Capacity))
-		fmt.Fprintf(w, "\t%s\t%s\t%.2f\t%s\n", filesizeUnits(curSize), filesizeUnits(int64(folder.Capacity)), pctUsed, folder.Path)
+		path := folder.Path
+		if folder.ProgressDenominator != 0 {
+			path += fmt.Sprintf(" (operation %.2f%% complete)", 100*float64(folder.ProgressNumerator)/float64(folder.ProgressDenominator))
+		}
+		fmt.Fprintf(w, "\t%s\t%s\t%.2f\t%s\n", filesizeUnits(curSize), filesizeUnits(int64(folder.Capacity)), pctUsed, path)
 	}
 	w.Flush()
 }
@@ -428,6 +441,15 @@ func hostfolderaddcmd(path, size string) {
 	fmt.Println("Added folder", path)
 }
 
+// hostfoldermovecmd moves a folder in the host to a new path.
+func hostfoldermovecmd(path, newpath string) {
+	err := post("/host/storage/folders/move", fmt.Sprintf("path=%s&newpath=%s", abs(path), abs(newpath)))
+	if err != nil {
+		die("Could not move folder:", err)
+	}
+	fmt.Printf("Moved folder %v to %v\n", path, newpath)
+}
+
 // hostfolderremovecmd removes a folder from the host.
 func hostfolderremovecmd(path string) {
 	err := post("/host/storage/folders/remove", "path="+abs(path))
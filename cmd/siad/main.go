@@ -106,6 +106,10 @@ Wallet (w):
 Renter (r):
 	The renter manages the user's files on the network.
 	The renter requires the consensus set, transaction pool, and wallet.
+	If the SIA_RENTER_PASSWORD environment variable is set, the renter
+	encrypts its file metadata, host database and contract journal with a
+	key derived from the password. The renter logs and the download cache
+	are not encrypted.
 	Example:
 		siad -M gctwr
 Host (h):
@@ -125,7 +129,8 @@ Explorer (e):
 	The explorer provides statistics about the blockchain and can be
 	queried for information about specific transactions or other objects on
 	the blockchain.
-	The explorer requires the consenus set.
+	The explorer requires the consenus set. If the transaction pool is
+	loaded as well, the explorer also indexes unconfirmed transactions.
 	Example:
 		siad -M gce`)
 }
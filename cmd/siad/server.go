@@ -478,16 +478,6 @@ func (srv *Server) loadModules() error {
 		}
 		srv.moduleClosers = append(srv.moduleClosers, moduleCloser{name: "consensus", Closer: cs})
 	}
-	var e modules.Explorer
-	if strings.Contains(srv.config.Siad.Modules, "e") {
-		i++
-		fmt.Printf("(%d/%d) Loading explorer...\n", i, len(srv.config.Siad.Modules))
-		e, err = explorer.New(cs, filepath.Join(srv.config.Siad.SiaDir, modules.ExplorerDir))
-		if err != nil {
-			return err
-		}
-		srv.moduleClosers = append(srv.moduleClosers, moduleCloser{name: "explorer", Closer: e})
-	}
 	var tpool modules.TransactionPool
 	if strings.Contains(srv.config.Siad.Modules, "t") {
 		i++
@@ -498,6 +488,16 @@ func (srv *Server) loadModules() error {
 		}
 		srv.moduleClosers = append(srv.moduleClosers, moduleCloser{name: "transaction pool", Closer: tpool})
 	}
+	var e modules.Explorer
+	if strings.Contains(srv.config.Siad.Modules, "e") {
+		i++
+		fmt.Printf("(%d/%d) Loading explorer...\n", i, len(srv.config.Siad.Modules))
+		e, err = explorer.New(cs, tpool, filepath.Join(srv.config.Siad.SiaDir, modules.ExplorerDir))
+		if err != nil {
+			return err
+		}
+		srv.moduleClosers = append(srv.moduleClosers, moduleCloser{name: "explorer", Closer: e})
+	}
 	var w modules.Wallet
 	if strings.Contains(srv.config.Siad.Modules, "w") {
 		i++
@@ -532,7 +532,13 @@ func (srv *Server) loadModules() error {
 	if strings.Contains(srv.config.Siad.Modules, "r") {
 		i++
 		fmt.Printf("(%d/%d) Loading renter...\n", i, len(srv.config.Siad.Modules))
-		r, err = renter.New(g, cs, w, tpool, filepath.Join(srv.config.Siad.SiaDir, modules.RenterDir))
+		renterDir := filepath.Join(srv.config.Siad.SiaDir, modules.RenterDir)
+		if password := os.Getenv("SIA_RENTER_PASSWORD"); password != "" {
+			fmt.Println("Using SIA_RENTER_PASSWORD environment variable to encrypt the renter metadata")
+			r, err = renter.NewWithMetadataKey(g, cs, w, tpool, renterDir, renter.MetadataKey(password))
+		} else {
+			r, err = renter.New(g, cs, w, tpool, renterDir)
+		}
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,209 @@
+// Package metrics provides a minimal registry of counters and gauges that can
+// be written in the Prometheus text exposition format, so that the modules can
+// be scraped by standard monitoring tools.
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The metric types that are written in the TYPE line of a metric.
+const (
+	typeCounter = "counter"
+	typeGauge   = "gauge"
+)
+
+type (
+	// Registry holds a set of metrics. The zero value is not usable, use
+	// NewRegistry instead. A Registry is safe for concurrent use.
+	Registry struct {
+		metrics map[string]*metric
+		mu      sync.Mutex
+	}
+
+	// metric is a single named metric. Every distinct set of labels has its
+	// own value, keyed by the formatted label set.
+	metric struct {
+		name   string
+		help   string
+		kind   string
+		values map[string]float64
+	}
+
+	// Counter is a metric that only ever increases, such as the number of
+	// chunks uploaded.
+	Counter struct {
+		m *metric
+		r *Registry
+	}
+
+	// Gauge is a metric that can go up and down, such as the number of
+	// chunks waiting for repair.
+	Gauge struct {
+		m *metric
+		r *Registry
+	}
+)
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		metrics: make(map[string]*metric),
+	}
+}
+
+// register returns the metric with the given name, creating it if it does not
+// exist yet. Registering the same name twice with a different type panics, as
+// that is a developer error.
+func (r *Registry) register(name, help, kind string) *metric {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if m, ok := r.metrics[name]; ok {
+		if m.kind != kind {
+			panic("metric " + name + " registered as both " + m.kind + " and " + kind)
+		}
+		return m
+	}
+	m := &metric{
+		name:   name,
+		help:   help,
+		kind:   kind,
+		values: make(map[string]float64),
+	}
+	r.metrics[name] = m
+	return m
+}
+
+// Counter returns the counter with the given name, registering it if
+// necessary.
+func (r *Registry) Counter(name, help string) *Counter {
+	return &Counter{m: r.register(name, help, typeCounter), r: r}
+}
+
+// Gauge returns the gauge with the given name, registering it if necessary.
+func (r *Registry) Gauge(name, help string) *Gauge {
+	return &Gauge{m: r.register(name, help, typeGauge), r: r}
+}
+
+// Add increases the counter by v. labels are alternating label names and
+// values. Negative values are ignored, as counters never decrease. Calling Add
+// on a nil Counter is a no-op, so that objects built without a registry in
+// tests do not need to register their metrics.
+func (c *Counter) Add(v float64, labels ...string) {
+	if c == nil || v < 0 {
+		return
+	}
+	key := formatLabels(labels)
+	c.r.mu.Lock()
+	c.m.values[key] += v
+	c.r.mu.Unlock()
+}
+
+// Inc increases the counter by one.
+func (c *Counter) Inc(labels ...string) {
+	c.Add(1, labels...)
+}
+
+// Set sets the gauge to v. labels are alternating label names and values.
+// Like for Counter, calling Set on a nil Gauge is a no-op.
+func (g *Gauge) Set(v float64, labels ...string) {
+	if g == nil {
+		return
+	}
+	key := formatLabels(labels)
+	g.r.mu.Lock()
+	g.m.values[key] = v
+	g.r.mu.Unlock()
+}
+
+// Add adds v to the gauge. v may be negative.
+func (g *Gauge) Add(v float64, labels ...string) {
+	if g == nil {
+		return
+	}
+	key := formatLabels(labels)
+	g.r.mu.Lock()
+	g.m.values[key] += v
+	g.r.mu.Unlock()
+}
+
+// WriteText writes all metrics of the registry to w in the Prometheus text
+// exposition format. Metrics are sorted by name and samples by their labels,
+// so that the output is stable between scrapes. A nil Registry writes nothing.
+func (r *Registry) WriteText(w io.Writer) error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Format the metrics into a buffer first, so that the lock is not held
+	// while writing to a potentially slow writer.
+	var buf bytes.Buffer
+	for _, name := range names {
+		m := r.metrics[name]
+		if len(m.values) == 0 {
+			continue
+		}
+		fmt.Fprintf(&buf, "# HELP %s %s\n", m.name, escapeHelp(m.help))
+		fmt.Fprintf(&buf, "# TYPE %s %s\n", m.name, m.kind)
+		keys := make([]string, 0, len(m.values))
+		for key := range m.values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Fprintf(&buf, "%s%s %s\n", m.name, key, strconv.FormatFloat(m.values[key], 'g', -1, 64))
+		}
+	}
+	r.mu.Unlock()
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// formatLabels formats alternating label names and values as a label set,
+// e.g. {host="ed25519:...",result="success"}. A trailing name without a value
+// is given an empty value.
+func formatLabels(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i := 0; i < len(labels); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		var value string
+		if i+1 < len(labels) {
+			value = labels[i+1]
+		}
+		b.WriteString(labels[i])
+		b.WriteString(`="`)
+		b.WriteString(escapeLabelValue(value))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// escapeLabelValue escapes backslashes, double quotes and line feeds in a
+// label value.
+func escapeLabelValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// escapeHelp escapes backslashes and line feeds in a help string.
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
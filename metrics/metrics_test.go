@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestRegistryWriteText checks that the registry writes its metrics in the
+// text exposition format, sorted by name and labels.
+func TestRegistryWriteText(t *testing.T) {
+	r := NewRegistry()
+	chunks := r.Counter("sia_test_chunks_total", "Chunks uploaded.")
+	bytesSent := r.Counter("sia_test_bytes_total", "Bytes uploaded per host.")
+	backlog := r.Gauge("sia_test_backlog", "Chunks waiting for repair.")
+	r.Gauge("sia_test_unused", "Never set, so never written.")
+
+	chunks.Inc()
+	chunks.Inc()
+	chunks.Add(-5) // ignored
+	bytesSent.Add(10, "host", "b")
+	bytesSent.Add(20, "host", "a")
+	bytesSent.Add(5, "host", "b")
+	backlog.Set(7)
+	backlog.Add(-3)
+
+	var buf bytes.Buffer
+	if err := r.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	exp := `# HELP sia_test_backlog Chunks waiting for repair.
+# TYPE sia_test_backlog gauge
+sia_test_backlog 4
+# HELP sia_test_bytes_total Bytes uploaded per host.
+# TYPE sia_test_bytes_total counter
+sia_test_bytes_total{host="a"} 20
+sia_test_bytes_total{host="b"} 15
+# HELP sia_test_chunks_total Chunks uploaded.
+# TYPE sia_test_chunks_total counter
+sia_test_chunks_total 2
+`
+	if buf.String() != exp {
+		t.Fatalf("unexpected output:\n%s\nexpected:\n%s", buf.String(), exp)
+	}
+
+	// Registering a metric again should return the same metric.
+	r.Counter("sia_test_chunks_total", "Chunks uploaded.").Inc()
+	buf.Reset()
+	if err := r.WriteText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("sia_test_chunks_total 3\n")) {
+		t.Fatal("re-registered counter does not share its value:", buf.String())
+	}
+}
+
+// TestFormatLabels checks that label values are escaped.
+func TestFormatLabels(t *testing.T) {
+	tests := []struct {
+		labels []string
+		exp    string
+	}{
+		{nil, ""},
+		{[]string{"a", "b"}, `{a="b"}`},
+		{[]string{"a", "b", "c", "d"}, `{a="b",c="d"}`},
+		{[]string{"a"}, `{a=""}`},
+		{[]string{"a", "q\"u\\o\nte"}, `{a="q\"u\\o\nte"}`},
+	}
+	for _, test := range tests {
+		if s := formatLabels(test.labels); s != test.exp {
+			t.Errorf("formatLabels(%q) = %s, expected %s", test.labels, s, test.exp)
+		}
+	}
+}
@@ -10,6 +10,22 @@ const (
 	ExplorerDir = "explorer"
 )
 
+// The types of objects that an explorer search can resolve a hash to.
+const (
+	ExplorerSearchBlockID         = "blockid"
+	ExplorerSearchFileContractID  = "filecontractid"
+	ExplorerSearchSiacoinOutputID = "siacoinoutputid"
+	ExplorerSearchSiafundOutputID = "siafundoutputid"
+	ExplorerSearchTransactionID   = "transactionid"
+	ExplorerSearchUnlockHash      = "unlockhash"
+)
+
+// The intervals of the explorer's time series.
+const (
+	ExplorerTimeSeriesDaily  = "daily"
+	ExplorerTimeSeriesWeekly = "weekly"
+)
+
 type (
 	// BlockFacts returns a bunch of statistics about the consensus set as they
 	// were at a specific block.
@@ -45,6 +61,60 @@ type (
 		TotalRevisionVolume types.Currency `json:"totalrevisionvolume"`
 	}
 
+	// ExplorerIntegrityReport is the result of comparing the explorer's
+	// indexes against the blocks in a height range. Missing entries are index
+	// entries of blocks in the range that do not exist, and stale entries are
+	// index entries that refer to blocks or transactions that are not in the
+	// blockchain. At most 100 problems are described.
+	ExplorerIntegrityReport struct {
+		StartHeight         types.BlockHeight `json:"startheight"`
+		EndHeight           types.BlockHeight `json:"endheight"`
+		CheckedBlocks       uint64            `json:"checkedblocks"`
+		CheckedTransactions uint64            `json:"checkedtransactions"`
+		MissingEntries      uint64            `json:"missingentries"`
+		StaleEntries        uint64            `json:"staleentries"`
+		Problems            []string          `json:"problems"`
+	}
+
+	// ExplorerSearchResult is an object that a search string was resolved
+	// to. For block and transaction IDs, Height is the height of the block
+	// that contains the object. For the other types, TransactionIDs are the
+	// transactions that involve the object.
+	ExplorerSearchResult struct {
+		Type           string                `json:"type"`
+		Height         types.BlockHeight     `json:"height"`
+		TransactionIDs []types.TransactionID `json:"transactionids"`
+	}
+
+	// ExplorerTimeSeriesPoint contains statistics about the blocks whose
+	// timestamps fall into a period of a time series. Timestamp is the start
+	// of the period. Difficulty, TotalCoins and the active contract fields are
+	// taken from the block facts of the highest block in the period, while the
+	// remaining fields are totals over all blocks in the period.
+	ExplorerTimeSeriesPoint struct {
+		Timestamp types.Timestamp   `json:"timestamp"`
+		Blocks    uint64            `json:"blocks"`
+		EndHeight types.BlockHeight `json:"endheight"`
+
+		Difficulty          types.Currency `json:"difficulty"`
+		TotalCoins          types.Currency `json:"totalcoins"`
+		ActiveContractCount uint64         `json:"activecontractcount"`
+		ActiveContractSize  types.Currency `json:"activecontractsize"`
+
+		TransactionCount uint64         `json:"transactioncount"`
+		SiacoinVolume    types.Currency `json:"siacoinvolume"`
+		MinerFees        types.Currency `json:"minerfees"`
+		AverageFee       types.Currency `json:"averagefee"`
+	}
+
+	// UnlockHashTransaction is a transaction in the history of an unlock
+	// hash. The miner payouts of a block appear as a transaction whose ID is
+	// the ID of the block.
+	UnlockHashTransaction struct {
+		Height        types.BlockHeight   `json:"height"`
+		TransactionID types.TransactionID `json:"transactionid"`
+	}
+
 	// Explorer tracks the blockchain and provides tools for gathering
 	// statistics and finding objects or patterns within the blockchain.
 	Explorer interface {
@@ -56,10 +126,26 @@ type (
 		// appeared at a given block.
 		BlockFacts(types.BlockHeight) (BlockFacts, bool)
 
+		// CheckIntegrity recomputes the index entries of the blocks between
+		// the start and end height and reports the entries that are missing
+		// from or stale in the explorer's database.
+		CheckIntegrity(start, end types.BlockHeight) (ExplorerIntegrityReport, error)
+
 		// LatestBlockFacts returns the block facts of the last block
 		// in the explorer's database.
 		LatestBlockFacts() BlockFacts
 
+		// Search resolves a hex string to the objects in the blockchain that
+		// it identifies, which can be blocks, transactions, file contracts,
+		// siacoin and siafund outputs, and unlock hashes. An error is returned
+		// if the string is not a hash or an unlock hash.
+		Search(string) ([]ExplorerSearchResult, error)
+
+		// TimeSeries returns the points of the daily or weekly time series
+		// whose periods start between start and end, inclusive. Periods
+		// without any blocks are omitted.
+		TimeSeries(interval string, start, end types.Timestamp) ([]ExplorerTimeSeriesPoint, error)
+
 		// Transaction returns the block that contains the input transaction
 		// id. The transaction itself is either the block (indicating the miner
 		// payouts are somehow involved), or it is a transaction inside of the
@@ -71,6 +157,22 @@ type (
 		// provided unlock hash.
 		UnlockHash(types.UnlockHash) []types.TransactionID
 
+		// UnlockHashHistory returns the transactions associated with the
+		// provided unlock hash in the order in which they appear in the
+		// blockchain, skipping the first offset transactions and returning at
+		// most limit transactions. The total number of transactions associated
+		// with the unlock hash is returned as well.
+		UnlockHashHistory(uh types.UnlockHash, offset, limit uint64) (txns []UnlockHashTransaction, total uint64)
+
+		// UnconfirmedTransaction returns the transaction in the transaction
+		// pool that matches the input transaction id. The bool indicates
+		// whether the transaction is in the transaction pool.
+		UnconfirmedTransaction(types.TransactionID) (types.Transaction, bool)
+
+		// UnconfirmedUnlockHash returns the ids of the transactions in the
+		// transaction pool that are associated with the provided unlock hash.
+		UnconfirmedUnlockHash(types.UnlockHash) []types.TransactionID
+
 		// SiacoinOutput will return the siacoin output associated with the
 		// input id.
 		SiacoinOutput(types.SiacoinOutputID) (types.SiacoinOutput, bool)
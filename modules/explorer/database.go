@@ -23,8 +23,15 @@ var (
 	bucketSiacoinOutputs   = []byte("SiacoinOutputs")
 	bucketSiafundOutputIDs = []byte("SiafundOutputIDs")
 	bucketSiafundOutputs   = []byte("SiafundOutputs")
+	// bucketTimeSeriesDaily and bucketTimeSeriesWeekly store the statistics
+	// of each period of the time series, see timeSeriesKey
+	bucketTimeSeriesDaily  = []byte("TimeSeriesDaily")
+	bucketTimeSeriesWeekly = []byte("TimeSeriesWeekly")
 	bucketTransactionIDs   = []byte("TransactionIDs")
-	bucketUnlockHashes     = []byte("UnlockHashes")
+	// bucketUnlockHashHistories stores the ordered transaction history of
+	// each unlock hash, see unlockHashHistoryKey
+	bucketUnlockHashHistories = []byte("UnlockHashHistories")
+	bucketUnlockHashes        = []byte("UnlockHashes")
 
 	errNotExist = errors.New("entry does not exist")
 
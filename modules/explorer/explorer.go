@@ -4,6 +4,7 @@ package explorer
 
 import (
 	"errors"
+	"sync"
 
 	"github.com/pachisi456/Sia/modules"
 	"github.com/pachisi456/Sia/persist"
@@ -47,12 +48,23 @@ type (
 		cs         modules.ConsensusSet
 		db         *persist.BoltDatabase
 		persistDir string
+		tpool      modules.TransactionPool
+
+		// The unconfirmed transactions of the transaction pool are indexed
+		// in memory, as the transaction pool is not persisted either. If the
+		// explorer has no transaction pool, the index stays empty.
+		unconfirmedSets         map[modules.TransactionSetID][]types.TransactionID
+		unconfirmedTxns         map[types.TransactionID]types.Transaction
+		unconfirmedUnlockHashes map[types.UnlockHash]map[types.TransactionID]struct{}
+		mu                      sync.RWMutex
 	}
 )
 
 // New creates the internal data structures, and subscribes to
-// consensus for changes to the blockchain
-func New(cs modules.ConsensusSet, persistDir string) (*Explorer, error) {
+// consensus for changes to the blockchain and to the transaction pool for
+// changes to the unconfirmed transactions. The transaction pool is optional;
+// without it, unconfirmed transactions are not indexed.
+func New(cs modules.ConsensusSet, tpool modules.TransactionPool, persistDir string) (*Explorer, error) {
 	// Check that input modules are non-nil
 	if cs == nil {
 		return nil, errNilCS
@@ -62,6 +74,11 @@ func New(cs modules.ConsensusSet, persistDir string) (*Explorer, error) {
 	e := &Explorer{
 		cs:         cs,
 		persistDir: persistDir,
+		tpool:      tpool,
+
+		unconfirmedSets:         make(map[modules.TransactionSetID][]types.TransactionID),
+		unconfirmedTxns:         make(map[types.TransactionID]types.Transaction),
+		unconfirmedUnlockHashes: make(map[types.UnlockHash]map[types.TransactionID]struct{}),
 	}
 
 	// Initialize the persistent structures, including the database.
@@ -82,11 +99,17 @@ func New(cs modules.ConsensusSet, persistDir string) (*Explorer, error) {
 		// TODO: restart from 0
 		return nil, errors.New("explorer subscription failed: " + err.Error())
 	}
+	if tpool != nil {
+		tpool.TransactionPoolSubscribe(e)
+	}
 
 	return e, nil
 }
 
 // Close closes the explorer.
 func (e *Explorer) Close() error {
+	if e.tpool != nil {
+		e.tpool.Unsubscribe(e)
+	}
 	return e.db.Close()
 }
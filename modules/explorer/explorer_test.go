@@ -67,7 +67,7 @@ func createExplorerTester(name string) (*explorerTester, error) {
 	if err != nil {
 		return nil, err
 	}
-	e, err := New(cs, filepath.Join(testdir, modules.ExplorerDir))
+	e, err := New(cs, tp, filepath.Join(testdir, modules.ExplorerDir))
 	if err != nil {
 		return nil, err
 	}
@@ -148,7 +148,7 @@ func (et *explorerTester) reorgToBlank() error {
 // TestNilExplorerDependencies tries to initialize an explorer with nil
 // dependencies, checks that the correct error is returned.
 func TestNilExplorerDependencies(t *testing.T) {
-	_, err := New(nil, "expdir")
+	_, err := New(nil, nil, "expdir")
 	if err != errNilCS {
 		t.Fatal("Expecting errNilCS")
 	}
@@ -168,9 +168,9 @@ func TestExplorerGenesisHeight(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	// Create the explorer - from the subscription only the genesis block will
-	// be received.
-	e, err := New(cs, testdir)
+	// Create the explorer without a transaction pool - from the subscription
+	// only the genesis block will be received.
+	e, err := New(cs, nil, testdir)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -1,7 +1,10 @@
 package explorer
 
 import (
+	"encoding/binary"
+
 	"github.com/pachisi456/Sia/build"
+	"github.com/pachisi456/Sia/encoding"
 	"github.com/pachisi456/Sia/modules"
 	"github.com/pachisi456/Sia/types"
 	"github.com/NebulousLabs/bolt"
@@ -81,6 +84,42 @@ func (e *Explorer) UnlockHash(uh types.UnlockHash) []types.TransactionID {
 	return ids
 }
 
+// UnlockHashHistory returns the transactions that contain the unlock hash,
+// ordered by their height and position in the block, starting at the offset'th
+// transaction and returning at most limit transactions. The total number of
+// transactions in the history is returned as well.
+func (e *Explorer) UnlockHashHistory(uh types.UnlockHash, offset, limit uint64) ([]modules.UnlockHashTransaction, uint64) {
+	var txns []modules.UnlockHashTransaction
+	var total uint64
+	err := e.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketUnlockHashHistories).Bucket(encoding.Marshal(uh))
+		if b == nil {
+			return errNotExist
+		}
+		total = uint64(b.Stats().KeyN)
+
+		c := b.Cursor()
+		k, v := c.First()
+		for i := uint64(0); i < offset && k != nil; i++ {
+			k, v = c.Next()
+		}
+		for ; k != nil && uint64(len(txns)) < limit; k, v = c.Next() {
+			txn := modules.UnlockHashTransaction{
+				Height: types.BlockHeight(binary.BigEndian.Uint64(k[:8])),
+			}
+			if err := encoding.Unmarshal(v, &txn.TransactionID); err != nil {
+				return err
+			}
+			txns = append(txns, txn)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0
+	}
+	return txns, total
+}
+
 // SiacoinOutput returns the siacoin output associated with the specified ID.
 func (e *Explorer) SiacoinOutput(id types.SiacoinOutputID) (types.SiacoinOutput, bool) {
 	var sco types.SiacoinOutput
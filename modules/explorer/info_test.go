@@ -259,3 +259,61 @@ func TestFileContractsPayoutValidProof(t *testing.T) {
 		t.Errorf("expected %v, got %v ", fc.MissedProofOutputs, outputs)
 	}
 }
+
+// TestUnlockHashHistory checks that the history of an unlock hash is ordered
+// and can be paged through.
+func TestUnlockHashHistory(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	et, err := createExplorerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Mine a few more blocks so that the payout addresses have a history.
+	for i := 0; i < 3; i++ {
+		if _, err := et.miner.AddBlock(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	block, exists := et.cs.BlockAtHeight(1)
+	if !exists {
+		t.Fatal("block at height 1 does not exist")
+	}
+	uh := block.MinerPayouts[0].UnlockHash
+	history, total := et.explorer.UnlockHashHistory(uh, 0, 1e6)
+	if total == 0 || uint64(len(history)) != total {
+		t.Fatal("history has the wrong length:", len(history), total)
+	}
+	if history[0].Height != 1 || history[0].TransactionID != types.TransactionID(block.ID()) {
+		t.Fatal("history does not start with the miner payout of block 1:", history[0])
+	}
+	for i := 1; i < len(history); i++ {
+		if history[i].Height < history[i-1].Height {
+			t.Fatal("history is not ordered by height")
+		}
+	}
+	if len(et.explorer.UnlockHash(uh)) != len(history) {
+		t.Fatal("history does not match the transactions of the unlock hash")
+	}
+
+	// Page through the history.
+	for offset := uint64(0); offset < total; offset++ {
+		page, pageTotal := et.explorer.UnlockHashHistory(uh, offset, 1)
+		if pageTotal != total || len(page) != 1 || page[0] != history[offset] {
+			t.Fatal("wrong page at offset", offset, page)
+		}
+	}
+	if page, _ := et.explorer.UnlockHashHistory(uh, total, 1); len(page) != 0 {
+		t.Fatal("page past the end of the history is not empty:", page)
+	}
+
+	// An unknown unlock hash has no history.
+	var unknown types.UnlockHash
+	fastrand.Read(unknown[:])
+	if history, total := et.explorer.UnlockHashHistory(unknown, 0, 10); len(history) != 0 || total != 0 {
+		t.Fatal("unknown unlock hash has a history:", history, total)
+	}
+}
@@ -0,0 +1,292 @@
+package explorer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/pachisi456/Sia/encoding"
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+const (
+	// integrityMaxProblems is the maximum number of problems that are
+	// described in an integrity report. All problems are counted.
+	integrityMaxProblems = 100
+)
+
+var (
+	// errInvalidHeightRange is returned if an integrity check is requested
+	// for a start height that is above the end height.
+	errInvalidHeightRange = errors.New("start height cannot be above end height")
+)
+
+// integrityCheck compares the indexes of the explorer's database against the
+// blocks of the consensus set.
+type integrityCheck struct {
+	tx     *bolt.Tx
+	report *modules.ExplorerIntegrityReport
+
+	// The blocks and transactions in the checked height range, including the
+	// miner payouts of each block, which are indexed as a transaction.
+	blocks map[types.BlockID]types.BlockHeight
+	txns   map[types.TransactionID]types.BlockHeight
+}
+
+// problem records a missing or stale index entry in the report.
+func (ic *integrityCheck) problem(stale bool, format string, args ...interface{}) {
+	if stale {
+		ic.report.StaleEntries++
+	} else {
+		ic.report.MissingEntries++
+	}
+	if len(ic.report.Problems) < integrityMaxProblems {
+		ic.report.Problems = append(ic.report.Problems, fmt.Sprintf(format, args...))
+	}
+}
+
+// checkHeight checks that key is mapped to height in the bucket.
+func (ic *integrityCheck) checkHeight(bucket []byte, key interface{}, height types.BlockHeight) {
+	var h types.BlockHeight
+	err := dbGetAndDecode(bucket, key, &h)(ic.tx)
+	if err != nil || h != height {
+		ic.problem(false, "%s %v at height %v is missing", bucket, key, height)
+	}
+}
+
+// checkExists checks that key has a value in the bucket.
+func (ic *integrityCheck) checkExists(bucket []byte, key interface{}) {
+	if ic.tx.Bucket(bucket).Get(encoding.Marshal(key)) == nil {
+		ic.problem(false, "%s %v is missing", bucket, key)
+	}
+}
+
+// checkSet checks that txid is in the set of transactions of key.
+func (ic *integrityCheck) checkSet(bucket []byte, key interface{}, txid types.TransactionID) {
+	set := ic.tx.Bucket(bucket).Bucket(encoding.Marshal(key))
+	if set == nil || set.Get(encoding.Marshal(txid)) == nil {
+		ic.problem(false, "transaction %v is missing from %s %v", txid, bucket, key)
+	}
+}
+
+// checkUnlockHash checks that txid is in the set of transactions and in the
+// history of the unlock hash.
+func (ic *integrityCheck) checkUnlockHash(uh types.UnlockHash, txid types.TransactionID, hk []byte) {
+	ic.checkSet(bucketUnlockHashes, uh, txid)
+	history := ic.tx.Bucket(bucketUnlockHashHistories).Bucket(encoding.Marshal(uh))
+	if history == nil || !bytes.Equal(history.Get(hk), encoding.Marshal(txid)) {
+		ic.problem(false, "transaction %v is missing from the history of unlock hash %v", txid, uh)
+	}
+}
+
+// checkBlock checks that all of the index entries that ProcessConsensusChange
+// adds for a block exist.
+func (ic *integrityCheck) checkBlock(block types.Block, height types.BlockHeight) {
+	bid := block.ID()
+	tbid := types.TransactionID(bid)
+	ic.blocks[bid] = height
+	ic.report.CheckedBlocks++
+
+	ic.checkHeight(bucketBlockIDs, bid, height)
+	ic.checkExists(bucketBlockFacts, bid)
+	if height != 0 {
+		// The genesis block has no target and no miner payouts.
+		ic.txns[tbid] = height
+		ic.checkHeight(bucketTransactionIDs, tbid, height)
+		ic.checkExists(bucketBlockTargets, bid)
+	}
+	for j, payout := range block.MinerPayouts {
+		ic.checkSet(bucketSiacoinOutputIDs, block.MinerPayoutID(uint64(j)), tbid)
+		ic.checkUnlockHash(payout.UnlockHash, tbid, unlockHashHistoryKey(height, 0))
+	}
+
+	for i, txn := range block.Transactions {
+		txid := txn.ID()
+		hk := unlockHashHistoryKey(height, i+1)
+		ic.txns[txid] = height
+		ic.report.CheckedTransactions++
+		ic.checkHeight(bucketTransactionIDs, txid, height)
+
+		for _, sci := range txn.SiacoinInputs {
+			ic.checkSet(bucketSiacoinOutputIDs, sci.ParentID, txid)
+			ic.checkUnlockHash(sci.UnlockConditions.UnlockHash(), txid, hk)
+		}
+		for j, sco := range txn.SiacoinOutputs {
+			ic.checkSet(bucketSiacoinOutputIDs, txn.SiacoinOutputID(uint64(j)), txid)
+			ic.checkUnlockHash(sco.UnlockHash, txid, hk)
+		}
+		for k, fc := range txn.FileContracts {
+			fcid := txn.FileContractID(uint64(k))
+			ic.checkSet(bucketFileContractIDs, fcid, txid)
+			ic.checkUnlockHash(fc.UnlockHash, txid, hk)
+			ic.checkExists(bucketFileContractHistories, fcid)
+			for l, sco := range fc.ValidProofOutputs {
+				ic.checkSet(bucketSiacoinOutputIDs, fcid.StorageProofOutputID(types.ProofValid, uint64(l)), txid)
+				ic.checkUnlockHash(sco.UnlockHash, txid, hk)
+			}
+			for l, sco := range fc.MissedProofOutputs {
+				ic.checkSet(bucketSiacoinOutputIDs, fcid.StorageProofOutputID(types.ProofMissed, uint64(l)), txid)
+				ic.checkUnlockHash(sco.UnlockHash, txid, hk)
+			}
+		}
+		for _, fcr := range txn.FileContractRevisions {
+			ic.checkSet(bucketFileContractIDs, fcr.ParentID, txid)
+			ic.checkUnlockHash(fcr.UnlockConditions.UnlockHash(), txid, hk)
+			ic.checkUnlockHash(fcr.NewUnlockHash, txid, hk)
+			for l, sco := range fcr.NewValidProofOutputs {
+				ic.checkSet(bucketSiacoinOutputIDs, fcr.ParentID.StorageProofOutputID(types.ProofValid, uint64(l)), txid)
+				ic.checkUnlockHash(sco.UnlockHash, txid, hk)
+			}
+			for l, sco := range fcr.NewMissedProofOutputs {
+				ic.checkSet(bucketSiacoinOutputIDs, fcr.ParentID.StorageProofOutputID(types.ProofMissed, uint64(l)), txid)
+				ic.checkUnlockHash(sco.UnlockHash, txid, hk)
+			}
+		}
+		for _, sp := range txn.StorageProofs {
+			ic.checkSet(bucketFileContractIDs, sp.ParentID, txid)
+		}
+		for _, sfi := range txn.SiafundInputs {
+			ic.checkSet(bucketSiafundOutputIDs, sfi.ParentID, txid)
+			ic.checkUnlockHash(sfi.UnlockConditions.UnlockHash(), txid, hk)
+			ic.checkUnlockHash(sfi.ClaimUnlockHash, txid, hk)
+		}
+		for k, sfo := range txn.SiafundOutputs {
+			ic.checkSet(bucketSiafundOutputIDs, txn.SiafundOutputID(uint64(k)), txid)
+			ic.checkUnlockHash(sfo.UnlockHash, txid, hk)
+		}
+	}
+}
+
+// inRange returns whether height is within the checked height range.
+func (ic *integrityCheck) inRange(height types.BlockHeight) bool {
+	return ic.report.StartHeight <= height && height <= ic.report.EndHeight
+}
+
+// checkStale checks for index entries of blocks and transactions in the
+// checked height range that are not in the blockchain, which are left behind
+// if a reverted block is not fully removed from the indexes. Transactions in
+// the sets of file contract IDs that are not in the blockchain at all are
+// reported as well.
+func (ic *integrityCheck) checkStale() error {
+	err := ic.tx.Bucket(bucketBlockIDs).ForEach(func(k, v []byte) error {
+		var id types.BlockID
+		var height types.BlockHeight
+		if err := encoding.Unmarshal(k, &id); err != nil {
+			return err
+		}
+		if err := encoding.Unmarshal(v, &height); err != nil {
+			return err
+		}
+		if h, exists := ic.blocks[id]; ic.inRange(height) && (!exists || h != height) {
+			ic.problem(true, "block %v at height %v is not in the blockchain", id, height)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = ic.tx.Bucket(bucketTransactionIDs).ForEach(func(k, v []byte) error {
+		var txid types.TransactionID
+		var height types.BlockHeight
+		if err := encoding.Unmarshal(k, &txid); err != nil {
+			return err
+		}
+		if err := encoding.Unmarshal(v, &height); err != nil {
+			return err
+		}
+		if h, exists := ic.txns[txid]; ic.inRange(height) && (!exists || h != height) {
+			ic.problem(true, "transaction %v at height %v is not in the blockchain", txid, height)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Check the histories of the unlock hashes, starting at the first key of
+	// the height range.
+	startKey := unlockHashHistoryKey(ic.report.StartHeight, 0)
+	histories := ic.tx.Bucket(bucketUnlockHashHistories)
+	err = histories.ForEach(func(uhBytes, _ []byte) error {
+		c := histories.Bucket(uhBytes).Cursor()
+		for hk, v := c.Seek(startKey); hk != nil; hk, v = c.Next() {
+			height := types.BlockHeight(binary.BigEndian.Uint64(hk[:8]))
+			if !ic.inRange(height) {
+				break
+			}
+			var txid types.TransactionID
+			if err := encoding.Unmarshal(v, &txid); err != nil {
+				return err
+			}
+			if h, exists := ic.txns[txid]; !exists || h != height {
+				var uh types.UnlockHash
+				encoding.Unmarshal(uhBytes, &uh)
+				ic.problem(true, "transaction %v in the history of unlock hash %v is not in the blockchain", txid, uh)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fcids := ic.tx.Bucket(bucketFileContractIDs)
+	return fcids.ForEach(func(fcidBytes, _ []byte) error {
+		return fcids.Bucket(fcidBytes).ForEach(func(txidBytes, _ []byte) error {
+			var txid types.TransactionID
+			if err := encoding.Unmarshal(txidBytes, &txid); err != nil {
+				return err
+			}
+			var height types.BlockHeight
+			err := dbGetAndDecode(bucketTransactionIDs, txid, &height)(ic.tx)
+			_, exists := ic.txns[txid]
+			if err == errNotExist || (err == nil && ic.inRange(height) && !exists) {
+				var fcid types.FileContractID
+				encoding.Unmarshal(fcidBytes, &fcid)
+				ic.problem(true, "transaction %v of file contract %v is not in the blockchain", txid, fcid)
+			}
+			return nil
+		})
+	})
+}
+
+// CheckIntegrity recomputes the index entries of the blocks between start and
+// end, inclusive, and compares them against the explorer's database. The end
+// height is capped at the height of the explorer.
+func (e *Explorer) CheckIntegrity(start, end types.BlockHeight) (modules.ExplorerIntegrityReport, error) {
+	var report modules.ExplorerIntegrityReport
+	err := e.db.View(func(tx *bolt.Tx) error {
+		var height types.BlockHeight
+		if err := dbGetInternal(internalBlockHeight, &height)(tx); err != nil {
+			return err
+		}
+		if end > height {
+			end = height
+		}
+		if start > end {
+			return errInvalidHeightRange
+		}
+		report.StartHeight, report.EndHeight = start, end
+
+		ic := &integrityCheck{
+			tx:     tx,
+			report: &report,
+			blocks: make(map[types.BlockID]types.BlockHeight),
+			txns:   make(map[types.TransactionID]types.BlockHeight),
+		}
+		for h := start; h <= end; h++ {
+			block, exists := e.cs.BlockAtHeight(h)
+			if !exists {
+				return fmt.Errorf("consensus set is missing the block at height %v", h)
+			}
+			ic.checkBlock(block, h)
+		}
+		return ic.checkStale()
+	})
+	return report, err
+}
@@ -0,0 +1,107 @@
+package explorer
+
+import (
+	"testing"
+
+	"github.com/pachisi456/Sia/encoding"
+	"github.com/pachisi456/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+// TestCheckIntegrity checks that the integrity check detects drift in the
+// explorer's indexes, and that no drift remains after a reorg.
+func TestCheckIntegrity(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	et, err := createExplorerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Add a block with a transaction to the blockchain.
+	uc, err := et.wallet.NextAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	txns, err := et.wallet.SendSiacoins(types.SiacoinPrecision, uc.UnlockHash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := et.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	height := et.explorer.LatestBlockFacts().Height
+
+	// checkClean checks that the indexes of the whole blockchain are intact.
+	checkClean := func() {
+		report, err := et.explorer.CheckIntegrity(0, height)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.MissingEntries != 0 || report.StaleEntries != 0 {
+			t.Fatal("indexes have drifted:", report.Problems)
+		}
+		if report.CheckedBlocks != uint64(height)+1 || report.CheckedTransactions == 0 {
+			t.Fatal("wrong number of blocks and transactions checked:", report)
+		}
+	}
+	checkClean()
+	if _, err := et.explorer.CheckIntegrity(height, height-1); err != errInvalidHeightRange {
+		t.Fatal("expected errInvalidHeightRange, got", err)
+	}
+
+	// Remove a transaction from the index of its unlock hash and add a block
+	// that is not in the blockchain.
+	txid := txns[len(txns)-1].ID()
+	var staleID types.BlockID
+	staleID[0] = 1
+	err = et.explorer.db.Update(func(tx *bolt.Tx) error {
+		set := tx.Bucket(bucketUnlockHashes).Bucket(encoding.Marshal(uc.UnlockHash()))
+		if err := set.Delete(encoding.Marshal(txid)); err != nil {
+			return err
+		}
+		return tx.Bucket(bucketBlockIDs).Put(encoding.Marshal(staleID), encoding.Marshal(height))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := et.explorer.CheckIntegrity(height, height)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.MissingEntries != 1 || report.StaleEntries != 1 || len(report.Problems) != 2 {
+		t.Fatal("drift was not detected:", report)
+	}
+
+	// Problems outside of the height range are not reported.
+	report, err = et.explorer.CheckIntegrity(0, height-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.MissingEntries != 0 || report.StaleEntries != 0 {
+		t.Fatal("problems outside of the height range were reported:", report.Problems)
+	}
+
+	// Repair the indexes and revert the transaction with a reorg.
+	err = et.explorer.db.Update(func(tx *bolt.Tx) error {
+		set := tx.Bucket(bucketUnlockHashes).Bucket(encoding.Marshal(uc.UnlockHash()))
+		if err := set.Put(encoding.Marshal(txid), nil); err != nil {
+			return err
+		}
+		return tx.Bucket(bucketBlockIDs).Delete(encoding.Marshal(staleID))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = et.reorgToBlank()
+	if err != nil {
+		t.Fatal(err)
+	}
+	height = et.explorer.LatestBlockFacts().Height
+	checkClean()
+	if txids := et.explorer.UnlockHash(uc.UnlockHash()); len(txids) != 0 {
+		t.Fatal("reverted transaction is still indexed:", txids)
+	}
+}
@@ -46,9 +46,28 @@ func (e *Explorer) initPersist() error {
 			bucketSiacoinOutputs,
 			bucketSiafundOutputIDs,
 			bucketSiafundOutputs,
+			bucketTimeSeriesDaily,
+			bucketTimeSeriesWeekly,
 			bucketTransactionIDs,
+			bucketUnlockHashHistories,
 			bucketUnlockHashes,
 		}
+
+		// Databases created before the unlock hash histories or the time
+		// series were added are rebuilt, so that they cover the whole
+		// blockchain.
+		outdated := tx.Bucket(bucketUnlockHashHistories) == nil || tx.Bucket(bucketTimeSeriesDaily) == nil
+		if tx.Bucket(bucketInternal) != nil && outdated {
+			for _, b := range buckets {
+				if tx.Bucket(b) == nil {
+					continue
+				}
+				if err := tx.DeleteBucket(b); err != nil {
+					return err
+				}
+			}
+		}
+
 		for _, b := range buckets {
 			_, err := tx.CreateBucketIfNotExists(b)
 			if err != nil {
@@ -0,0 +1,107 @@
+package explorer
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/pachisi456/Sia/crypto"
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+var (
+	// errInvalidSearch is returned if a search string is neither a hash nor
+	// an unlock hash.
+	errInvalidSearch = errors.New("search must be a hash of 64 hex characters or an address of 76 hex characters")
+
+	// errZeroHashSearch is returned if a search string is the zero hash,
+	// which is involved in too many transactions to be looked up.
+	errZeroHashSearch = errors.New("can't search for the empty hash")
+)
+
+// Search resolves a hex string to the objects in the blockchain that it
+// identifies. A string of 64 hex characters is resolved against block IDs,
+// transaction IDs, siacoin output IDs, file contract IDs, siafund output IDs,
+// and unlock hashes, in that order. A string of 76 hex characters is an
+// address, which is only resolved against unlock hashes after its checksum
+// has been verified. An empty set of results indicates that the string does
+// not appear in the blockchain.
+func (e *Explorer) Search(s string) ([]modules.ExplorerSearchResult, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	var hash crypto.Hash
+	isAddress := false
+	switch len(s) {
+	case crypto.HashSize * 2:
+		if err := hash.LoadString(s); err != nil {
+			return nil, err
+		}
+	case crypto.HashSize*2 + types.UnlockHashChecksumSize*2:
+		var uh types.UnlockHash
+		if err := uh.LoadString(s); err != nil {
+			return nil, err
+		}
+		hash = crypto.Hash(uh)
+		isAddress = true
+	default:
+		return nil, errInvalidSearch
+	}
+	if hash == (crypto.Hash{}) {
+		return nil, errZeroHashSearch
+	}
+
+	var results []modules.ExplorerSearchResult
+	err := e.db.View(func(tx *bolt.Tx) error {
+		if !isAddress {
+			// The miner payouts of a block are stored as a transaction with
+			// the ID of the block, which is only reported as a block.
+			var height types.BlockHeight
+			if dbGetAndDecode(bucketBlockIDs, types.BlockID(hash), &height)(tx) == nil {
+				results = append(results, modules.ExplorerSearchResult{
+					Type:   modules.ExplorerSearchBlockID,
+					Height: height,
+				})
+			} else if dbGetAndDecode(bucketTransactionIDs, types.TransactionID(hash), &height)(tx) == nil {
+				results = append(results, modules.ExplorerSearchResult{
+					Type:   modules.ExplorerSearchTransactionID,
+					Height: height,
+				})
+			}
+
+			idSets := []struct {
+				typ    string
+				bucket []byte
+				key    interface{}
+			}{
+				{modules.ExplorerSearchSiacoinOutputID, bucketSiacoinOutputIDs, types.SiacoinOutputID(hash)},
+				{modules.ExplorerSearchFileContractID, bucketFileContractIDs, types.FileContractID(hash)},
+				{modules.ExplorerSearchSiafundOutputID, bucketSiafundOutputIDs, types.SiafundOutputID(hash)},
+			}
+			for _, set := range idSets {
+				var txids []types.TransactionID
+				if dbGetTransactionIDSet(set.bucket, set.key, &txids)(tx) == nil && len(txids) != 0 {
+					results = append(results, modules.ExplorerSearchResult{
+						Type:           set.typ,
+						TransactionIDs: txids,
+					})
+				}
+			}
+		}
+
+		// Unlock hashes are resolved last, because anyone can create an
+		// unlock hash that collides with the ID of another object.
+		var txids []types.TransactionID
+		if dbGetTransactionIDSet(bucketUnlockHashes, types.UnlockHash(hash), &txids)(tx) == nil && len(txids) != 0 {
+			results = append(results, modules.ExplorerSearchResult{
+				Type:           modules.ExplorerSearchUnlockHash,
+				TransactionIDs: txids,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
@@ -0,0 +1,74 @@
+package explorer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pachisi456/Sia/crypto"
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/types"
+)
+
+// TestSearch checks that search strings are resolved to the objects that they
+// identify.
+func TestSearch(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	et, err := createExplorerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, exists := et.cs.BlockAtHeight(1)
+	if !exists {
+		t.Fatal("block at height 1 does not exist")
+	}
+	uh := block.MinerPayouts[0].UnlockHash
+	genesisTxnID := types.GenesisBlock.Transactions[0].ID()
+	tests := []struct {
+		query  string
+		typ    string
+		height types.BlockHeight
+	}{
+		{block.ID().String(), modules.ExplorerSearchBlockID, 1},
+		{strings.ToUpper(block.ID().String()), modules.ExplorerSearchBlockID, 1},
+		{genesisTxnID.String(), modules.ExplorerSearchTransactionID, 0},
+		{block.MinerPayoutID(0).String(), modules.ExplorerSearchSiacoinOutputID, 0},
+		{uh.String(), modules.ExplorerSearchUnlockHash, 0},
+		{crypto.Hash(uh).String(), modules.ExplorerSearchUnlockHash, 0},
+	}
+	for _, test := range tests {
+		results, err := et.explorer.Search(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || results[0].Type != test.typ || results[0].Height != test.height {
+			t.Fatalf("search for %v returned %v, expected a %v", test.query, results, test.typ)
+		}
+	}
+	results, err := et.explorer.Search(uh.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results[0].TransactionIDs) != len(et.explorer.UnlockHash(uh)) {
+		t.Fatal("search returned the wrong transactions for an unlock hash")
+	}
+
+	// Unknown hashes have no results.
+	results, err = et.explorer.Search(crypto.HashObject("unknown").String())
+	if err != nil || len(results) != 0 {
+		t.Fatal("search for an unknown hash returned", results, err)
+	}
+
+	// Invalid search strings are rejected.
+	badChecksum := uh.String()[:crypto.HashSize*2] + "00000000"
+	if badChecksum == uh.String() {
+		badChecksum = uh.String()[:crypto.HashSize*2] + "11111111"
+	}
+	for _, query := range []string{"", "1234", crypto.Hash{}.String(), badChecksum, strings.Repeat("z", crypto.HashSize*2)} {
+		if _, err := et.explorer.Search(query); err == nil {
+			t.Fatalf("search for %q did not return an error", query)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package explorer
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/pachisi456/Sia/encoding"
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+const (
+	// timeSeriesDay and timeSeriesWeek are the lengths of the periods of the
+	// time series in seconds. Periods are aligned to the Unix epoch.
+	timeSeriesDay  = 24 * 60 * 60
+	timeSeriesWeek = 7 * timeSeriesDay
+)
+
+var (
+	// errUnknownInterval is returned if a time series is requested for an
+	// interval that the explorer does not track.
+	errUnknownInterval = errors.New("interval must be either \"daily\" or \"weekly\"")
+
+	// timeSeries are the time series that are materialized by the explorer.
+	timeSeries = []struct {
+		interval string
+		bucket   []byte
+		period   types.Timestamp
+	}{
+		{modules.ExplorerTimeSeriesDaily, bucketTimeSeriesDaily, timeSeriesDay},
+		{modules.ExplorerTimeSeriesWeekly, bucketTimeSeriesWeekly, timeSeriesWeek},
+	}
+)
+
+// timeSeriesPoint is the part of a point in a time series that is stored in
+// the database. The remaining fields of a modules.ExplorerTimeSeriesPoint are
+// taken from the block facts of the block at EndHeight.
+type timeSeriesPoint struct {
+	Blocks           uint64
+	EndHeight        types.BlockHeight
+	TransactionCount uint64
+	SiacoinVolume    types.Currency
+	MinerFees        types.Currency
+}
+
+// timeSeriesKey returns the key of the period that contains the timestamp.
+// The key is big-endian, so that the points are ordered by time.
+func timeSeriesKey(t, period types.Timestamp) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(t-t%period))
+	return key
+}
+
+// blockVolume returns the total value of the siacoin outputs and the total
+// miner fees of the transactions in a block.
+func blockVolume(block types.Block) (volume, fees types.Currency) {
+	for _, txn := range block.Transactions {
+		for _, sco := range txn.SiacoinOutputs {
+			volume = volume.Add(sco.Value)
+		}
+		for _, fee := range txn.MinerFees {
+			fees = fees.Add(fee)
+		}
+	}
+	return volume, fees
+}
+
+// Add/Remove a block from the periods of the time series that contain its
+// timestamp. When the highest block of a period is removed, the period ends
+// at the previous height instead. Because block timestamps are not strictly
+// increasing, that block may belong to a neighbouring period, which can make
+// the statistics of a period slightly inaccurate after a reorg.
+func dbAddTimeSeriesBlock(tx *bolt.Tx, block types.Block, height types.BlockHeight) {
+	volume, fees := blockVolume(block)
+	for _, ts := range timeSeries {
+		b := tx.Bucket(ts.bucket)
+		key := timeSeriesKey(block.Timestamp, ts.period)
+		var p timeSeriesPoint
+		if pBytes := b.Get(key); pBytes != nil {
+			assertNil(encoding.Unmarshal(pBytes, &p))
+		}
+		p.Blocks++
+		if height > p.EndHeight {
+			p.EndHeight = height
+		}
+		p.TransactionCount += uint64(len(block.Transactions))
+		p.SiacoinVolume = p.SiacoinVolume.Add(volume)
+		p.MinerFees = p.MinerFees.Add(fees)
+		assertNil(b.Put(key, encoding.Marshal(p)))
+	}
+}
+func dbRemoveTimeSeriesBlock(tx *bolt.Tx, block types.Block, height types.BlockHeight) {
+	volume, fees := blockVolume(block)
+	for _, ts := range timeSeries {
+		b := tx.Bucket(ts.bucket)
+		key := timeSeriesKey(block.Timestamp, ts.period)
+		var p timeSeriesPoint
+		assertNil(encoding.Unmarshal(b.Get(key), &p))
+		if p.Blocks == 1 {
+			assertNil(b.Delete(key))
+			continue
+		}
+		p.Blocks--
+		if p.EndHeight == height {
+			p.EndHeight--
+		}
+		p.TransactionCount -= uint64(len(block.Transactions))
+		p.SiacoinVolume = p.SiacoinVolume.Sub(volume)
+		p.MinerFees = p.MinerFees.Sub(fees)
+		assertNil(b.Put(key, encoding.Marshal(p)))
+	}
+}
+
+// TimeSeries returns the points of the daily or weekly time series whose
+// periods start between start and end, inclusive.
+func (e *Explorer) TimeSeries(interval string, start, end types.Timestamp) ([]modules.ExplorerTimeSeriesPoint, error) {
+	var bucket []byte
+	for _, ts := range timeSeries {
+		if ts.interval == interval {
+			bucket = ts.bucket
+		}
+	}
+	if bucket == nil {
+		return nil, errUnknownInterval
+	}
+
+	var points []modules.ExplorerTimeSeriesPoint
+	err := e.db.View(func(tx *bolt.Tx) error {
+		startKey := make([]byte, 8)
+		binary.BigEndian.PutUint64(startKey, uint64(start))
+		c := tx.Bucket(bucket).Cursor()
+		for k, v := c.Seek(startKey); k != nil; k, v = c.Next() {
+			t := types.Timestamp(binary.BigEndian.Uint64(k))
+			if t > end {
+				break
+			}
+			var p timeSeriesPoint
+			if err := encoding.Unmarshal(v, &p); err != nil {
+				return err
+			}
+			var bf blockFacts
+			if err := e.dbGetBlockFacts(p.EndHeight, &bf)(tx); err != nil {
+				return err
+			}
+			point := modules.ExplorerTimeSeriesPoint{
+				Timestamp: t,
+				Blocks:    p.Blocks,
+				EndHeight: p.EndHeight,
+
+				Difficulty:          bf.Difficulty,
+				TotalCoins:          bf.TotalCoins,
+				ActiveContractCount: bf.ActiveContractCount,
+				ActiveContractSize:  bf.ActiveContractSize,
+
+				TransactionCount: p.TransactionCount,
+				SiacoinVolume:    p.SiacoinVolume,
+				MinerFees:        p.MinerFees,
+			}
+			if p.TransactionCount != 0 {
+				point.AverageFee = p.MinerFees.Div64(p.TransactionCount)
+			}
+			points = append(points, point)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return points, nil
+}
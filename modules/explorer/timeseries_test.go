@@ -0,0 +1,74 @@
+package explorer
+
+import (
+	"testing"
+
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/types"
+)
+
+// TestTimeSeries checks that the time series cover every block in the
+// blockchain, including after a reorg.
+func TestTimeSeries(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	et, err := createExplorerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// checkTimeSeries checks that the points of each time series add up to
+	// the latest block facts.
+	checkTimeSeries := func() {
+		facts := et.explorer.LatestBlockFacts()
+		for _, interval := range []string{modules.ExplorerTimeSeriesDaily, modules.ExplorerTimeSeriesWeekly} {
+			points, err := et.explorer.TimeSeries(interval, 0, types.CurrentTimestamp())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(points) == 0 {
+				t.Fatal("time series is empty:", interval)
+			}
+			var blocks, txns uint64
+			for i, p := range points {
+				if i > 0 && p.Timestamp <= points[i-1].Timestamp {
+					t.Fatal("time series is not ordered by time")
+				}
+				blocks += p.Blocks
+				txns += p.TransactionCount
+			}
+			if blocks != uint64(facts.Height)+1 || txns != facts.TransactionCount {
+				t.Fatalf("%v time series has %v blocks and %v transactions, expected %v and %v", interval, blocks, txns, facts.Height+1, facts.TransactionCount)
+			}
+			last := points[len(points)-1]
+			if last.EndHeight != facts.Height || last.TotalCoins.Cmp(facts.TotalCoins) != 0 {
+				t.Fatal("last point does not end at the latest block:", last)
+			}
+		}
+	}
+	checkTimeSeries()
+
+	// Range queries only return the periods that start in the range.
+	points, err := et.explorer.TimeSeries(modules.ExplorerTimeSeriesDaily, 0, types.CurrentTimestamp())
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := points[len(points)-1].Timestamp
+	if points, err := et.explorer.TimeSeries(modules.ExplorerTimeSeriesDaily, last, last); err != nil || len(points) != 1 {
+		t.Fatal("range query returned the wrong points:", points, err)
+	}
+	if points, err := et.explorer.TimeSeries(modules.ExplorerTimeSeriesDaily, last+1, types.CurrentTimestamp()); err != nil || len(points) != 0 {
+		t.Fatal("range query returned the wrong points:", points, err)
+	}
+	if _, err := et.explorer.TimeSeries("hourly", 0, types.CurrentTimestamp()); err != errUnknownInterval {
+		t.Fatal("expected errUnknownInterval, got", err)
+	}
+
+	// The reverted blocks are removed from the time series.
+	err = et.reorgToBlank()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTimeSeries()
+}
@@ -0,0 +1,105 @@
+package explorer
+
+import (
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/types"
+)
+
+// transactionUnlockHashes returns the unlock hashes that a transaction
+// involves, which are the same unlock hashes that ProcessConsensusChange
+// indexes for a confirmed transaction.
+func transactionUnlockHashes(txn types.Transaction) []types.UnlockHash {
+	var uhs []types.UnlockHash
+	for _, sci := range txn.SiacoinInputs {
+		uhs = append(uhs, sci.UnlockConditions.UnlockHash())
+	}
+	for _, sco := range txn.SiacoinOutputs {
+		uhs = append(uhs, sco.UnlockHash)
+	}
+	for _, fc := range txn.FileContracts {
+		uhs = append(uhs, fc.UnlockHash)
+		for _, sco := range fc.ValidProofOutputs {
+			uhs = append(uhs, sco.UnlockHash)
+		}
+		for _, sco := range fc.MissedProofOutputs {
+			uhs = append(uhs, sco.UnlockHash)
+		}
+	}
+	for _, fcr := range txn.FileContractRevisions {
+		uhs = append(uhs, fcr.UnlockConditions.UnlockHash(), fcr.NewUnlockHash)
+		for _, sco := range fcr.NewValidProofOutputs {
+			uhs = append(uhs, sco.UnlockHash)
+		}
+		for _, sco := range fcr.NewMissedProofOutputs {
+			uhs = append(uhs, sco.UnlockHash)
+		}
+	}
+	for _, sfi := range txn.SiafundInputs {
+		uhs = append(uhs, sfi.UnlockConditions.UnlockHash(), sfi.ClaimUnlockHash)
+	}
+	for _, sfo := range txn.SiafundOutputs {
+		uhs = append(uhs, sfo.UnlockHash)
+	}
+	return uhs
+}
+
+// ReceiveUpdatedUnconfirmedTransactions updates the explorer's index of
+// unconfirmed transactions. Transactions that are confirmed in a block are
+// reverted from the transaction pool, which removes them from the index.
+func (e *Explorer) ReceiveUpdatedUnconfirmedTransactions(diff *modules.TransactionPoolDiff) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, id := range diff.RevertedTransactions {
+		for _, txid := range e.unconfirmedSets[id] {
+			txn, exists := e.unconfirmedTxns[txid]
+			if !exists {
+				continue
+			}
+			for _, uh := range transactionUnlockHashes(txn) {
+				delete(e.unconfirmedUnlockHashes[uh], txid)
+				if len(e.unconfirmedUnlockHashes[uh]) == 0 {
+					delete(e.unconfirmedUnlockHashes, uh)
+				}
+			}
+			delete(e.unconfirmedTxns, txid)
+		}
+		delete(e.unconfirmedSets, id)
+	}
+
+	for _, set := range diff.AppliedTransactions {
+		e.unconfirmedSets[set.ID] = set.IDs
+		for i, txn := range set.Transactions {
+			txid := set.IDs[i]
+			e.unconfirmedTxns[txid] = txn
+			for _, uh := range transactionUnlockHashes(txn) {
+				if e.unconfirmedUnlockHashes[uh] == nil {
+					e.unconfirmedUnlockHashes[uh] = make(map[types.TransactionID]struct{})
+				}
+				e.unconfirmedUnlockHashes[uh][txid] = struct{}{}
+			}
+		}
+	}
+}
+
+// UnconfirmedTransaction returns the unconfirmed transaction with the
+// provided id. The bool indicates whether the transaction is in the
+// transaction pool.
+func (e *Explorer) UnconfirmedTransaction(id types.TransactionID) (types.Transaction, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	txn, exists := e.unconfirmedTxns[id]
+	return txn, exists
+}
+
+// UnconfirmedUnlockHash returns the ids of the unconfirmed transactions that
+// involve the provided unlock hash.
+func (e *Explorer) UnconfirmedUnlockHash(uh types.UnlockHash) []types.TransactionID {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	var ids []types.TransactionID
+	for txid := range e.unconfirmedUnlockHashes[uh] {
+		ids = append(ids, txid)
+	}
+	return ids
+}
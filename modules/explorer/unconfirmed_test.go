@@ -0,0 +1,54 @@
+package explorer
+
+import (
+	"testing"
+
+	"github.com/pachisi456/Sia/types"
+)
+
+// TestUnconfirmedTransactions checks that transactions in the transaction
+// pool are indexed until they are confirmed.
+func TestUnconfirmedTransactions(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	et, err := createExplorerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uc, err := et.wallet.NextAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	txns, err := et.wallet.SendSiacoins(types.SiacoinPrecision, uc.UnlockHash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	txid := txns[len(txns)-1].ID()
+	txn, exists := et.explorer.UnconfirmedTransaction(txid)
+	if !exists || txn.ID() != txid {
+		t.Fatal("unconfirmed transaction was not indexed")
+	}
+	txids := et.explorer.UnconfirmedUnlockHash(uc.UnlockHash())
+	if len(txids) != 1 || txids[0] != txid {
+		t.Fatal("unconfirmed transaction was not indexed for its unlock hash:", txids)
+	}
+	if len(et.explorer.UnlockHash(uc.UnlockHash())) != 0 {
+		t.Fatal("unconfirmed transaction was indexed as confirmed")
+	}
+
+	// Confirm the transaction.
+	if _, err := et.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := et.explorer.UnconfirmedTransaction(txid); exists {
+		t.Fatal("confirmed transaction is still indexed as unconfirmed")
+	}
+	if txids := et.explorer.UnconfirmedUnlockHash(uc.UnlockHash()); len(txids) != 0 {
+		t.Fatal("confirmed transaction is still indexed for its unlock hash:", txids)
+	}
+	if len(et.explorer.UnlockHash(uc.UnlockHash())) != 1 {
+		t.Fatal("confirmed transaction was not indexed")
+	}
+}
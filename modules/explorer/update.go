@@ -1,6 +1,7 @@
 package explorer
 
 import (
+	"encoding/binary"
 	"fmt"
 
 	"github.com/pachisi456/Sia/build"
@@ -38,6 +39,7 @@ func (e *Explorer) ProcessConsensusChange(cc modules.ConsensusChange) {
 			bid := block.ID()
 			tbid := types.TransactionID(bid)
 
+			height := blockheight
 			blockheight--
 			dbRemoveBlockID(tx, bid)
 			dbRemoveTransactionID(tx, tbid) // Miner payouts are a transaction
@@ -52,74 +54,82 @@ func (e *Explorer) ProcessConsensusChange(cc modules.ConsensusChange) {
 			for j, payout := range block.MinerPayouts {
 				scoid := block.MinerPayoutID(uint64(j))
 				dbRemoveSiacoinOutputID(tx, scoid, tbid)
-				dbRemoveUnlockHash(tx, payout.UnlockHash, tbid)
+				dbRemoveUnlockHash(tx, payout.UnlockHash, tbid, unlockHashHistoryKey(height, 0))
+				dbRemoveSiacoinOutput(tx, scoid)
 			}
 
-			// Remove transactions
-			for _, txn := range block.Transactions {
+			// Remove transactions in reverse order, so that revisions and
+			// storage proofs are removed before the file contracts that they
+			// refer to.
+			for i := len(block.Transactions) - 1; i >= 0; i-- {
+				txn := block.Transactions[i]
 				txid := txn.ID()
+				hk := unlockHashHistoryKey(height, i+1)
 				dbRemoveTransactionID(tx, txid)
 
 				for _, sci := range txn.SiacoinInputs {
 					dbRemoveSiacoinOutputID(tx, sci.ParentID, txid)
-					dbRemoveUnlockHash(tx, sci.UnlockConditions.UnlockHash(), txid)
+					dbRemoveUnlockHash(tx, sci.UnlockConditions.UnlockHash(), txid, hk)
 				}
 				for k, sco := range txn.SiacoinOutputs {
 					scoid := txn.SiacoinOutputID(uint64(k))
 					dbRemoveSiacoinOutputID(tx, scoid, txid)
-					dbRemoveUnlockHash(tx, sco.UnlockHash, txid)
+					dbRemoveUnlockHash(tx, sco.UnlockHash, txid, hk)
 					dbRemoveSiacoinOutput(tx, scoid)
 				}
 				for k, fc := range txn.FileContracts {
 					fcid := txn.FileContractID(uint64(k))
 					dbRemoveFileContractID(tx, fcid, txid)
-					dbRemoveUnlockHash(tx, fc.UnlockHash, txid)
+					dbRemoveUnlockHash(tx, fc.UnlockHash, txid, hk)
 					for l, sco := range fc.ValidProofOutputs {
 						scoid := fcid.StorageProofOutputID(types.ProofValid, uint64(l))
 						dbRemoveSiacoinOutputID(tx, scoid, txid)
-						dbRemoveUnlockHash(tx, sco.UnlockHash, txid)
+						dbRemoveUnlockHash(tx, sco.UnlockHash, txid, hk)
 					}
 					for l, sco := range fc.MissedProofOutputs {
 						scoid := fcid.StorageProofOutputID(types.ProofMissed, uint64(l))
 						dbRemoveSiacoinOutputID(tx, scoid, txid)
-						dbRemoveUnlockHash(tx, sco.UnlockHash, txid)
+						dbRemoveUnlockHash(tx, sco.UnlockHash, txid, hk)
 					}
 					dbRemoveFileContract(tx, fcid)
 				}
 				for _, fcr := range txn.FileContractRevisions {
 					dbRemoveFileContractID(tx, fcr.ParentID, txid)
-					dbRemoveUnlockHash(tx, fcr.UnlockConditions.UnlockHash(), txid)
-					dbRemoveUnlockHash(tx, fcr.NewUnlockHash, txid)
+					dbRemoveUnlockHash(tx, fcr.UnlockConditions.UnlockHash(), txid, hk)
+					dbRemoveUnlockHash(tx, fcr.NewUnlockHash, txid, hk)
 					for l, sco := range fcr.NewValidProofOutputs {
 						scoid := fcr.ParentID.StorageProofOutputID(types.ProofValid, uint64(l))
 						dbRemoveSiacoinOutputID(tx, scoid, txid)
-						dbRemoveUnlockHash(tx, sco.UnlockHash, txid)
+						dbRemoveUnlockHash(tx, sco.UnlockHash, txid, hk)
 					}
 					for l, sco := range fcr.NewMissedProofOutputs {
 						scoid := fcr.ParentID.StorageProofOutputID(types.ProofMissed, uint64(l))
 						dbRemoveSiacoinOutputID(tx, scoid, txid)
-						dbRemoveUnlockHash(tx, sco.UnlockHash, txid)
+						dbRemoveUnlockHash(tx, sco.UnlockHash, txid, hk)
 					}
 					// Remove the file contract revision from the revision chain.
 					dbRemoveFileContractRevision(tx, fcr.ParentID)
 				}
 				for _, sp := range txn.StorageProofs {
+					dbRemoveFileContractID(tx, sp.ParentID, txid)
 					dbRemoveStorageProof(tx, sp.ParentID)
 				}
 				for _, sfi := range txn.SiafundInputs {
 					dbRemoveSiafundOutputID(tx, sfi.ParentID, txid)
-					dbRemoveUnlockHash(tx, sfi.UnlockConditions.UnlockHash(), txid)
-					dbRemoveUnlockHash(tx, sfi.ClaimUnlockHash, txid)
+					dbRemoveUnlockHash(tx, sfi.UnlockConditions.UnlockHash(), txid, hk)
+					dbRemoveUnlockHash(tx, sfi.ClaimUnlockHash, txid, hk)
 				}
 				for k, sfo := range txn.SiafundOutputs {
 					sfoid := txn.SiafundOutputID(uint64(k))
 					dbRemoveSiafundOutputID(tx, sfoid, txid)
-					dbRemoveUnlockHash(tx, sfo.UnlockHash, txid)
+					dbRemoveUnlockHash(tx, sfo.UnlockHash, txid, hk)
+					dbRemoveSiafundOutput(tx, sfoid)
 				}
 			}
 
-			// remove the associated block facts
+			// remove the associated block facts and time series statistics
 			dbRemoveBlockFacts(tx, bid)
+			dbRemoveTimeSeriesBlock(tx, block, height)
 		}
 
 		// Update cumulative stats for applied blocks.
@@ -147,53 +157,54 @@ func (e *Explorer) ProcessConsensusChange(cc modules.ConsensusChange) {
 			for j, payout := range block.MinerPayouts {
 				scoid := block.MinerPayoutID(uint64(j))
 				dbAddSiacoinOutputID(tx, scoid, tbid)
-				dbAddUnlockHash(tx, payout.UnlockHash, tbid)
+				dbAddUnlockHash(tx, payout.UnlockHash, tbid, unlockHashHistoryKey(blockheight, 0))
 			}
 
 			// Update cumulative stats for applied transactions.
-			for _, txn := range block.Transactions {
+			for i, txn := range block.Transactions {
 				// Add the transaction to the list of active transactions.
 				txid := txn.ID()
+				hk := unlockHashHistoryKey(blockheight, i+1)
 				dbAddTransactionID(tx, txid, blockheight)
 
 				for _, sci := range txn.SiacoinInputs {
 					dbAddSiacoinOutputID(tx, sci.ParentID, txid)
-					dbAddUnlockHash(tx, sci.UnlockConditions.UnlockHash(), txid)
+					dbAddUnlockHash(tx, sci.UnlockConditions.UnlockHash(), txid, hk)
 				}
 				for j, sco := range txn.SiacoinOutputs {
 					scoid := txn.SiacoinOutputID(uint64(j))
 					dbAddSiacoinOutputID(tx, scoid, txid)
-					dbAddUnlockHash(tx, sco.UnlockHash, txid)
+					dbAddUnlockHash(tx, sco.UnlockHash, txid, hk)
 				}
 				for k, fc := range txn.FileContracts {
 					fcid := txn.FileContractID(uint64(k))
 					dbAddFileContractID(tx, fcid, txid)
-					dbAddUnlockHash(tx, fc.UnlockHash, txid)
+					dbAddUnlockHash(tx, fc.UnlockHash, txid, hk)
 					dbAddFileContract(tx, fcid, fc)
 					for l, sco := range fc.ValidProofOutputs {
 						scoid := fcid.StorageProofOutputID(types.ProofValid, uint64(l))
 						dbAddSiacoinOutputID(tx, scoid, txid)
-						dbAddUnlockHash(tx, sco.UnlockHash, txid)
+						dbAddUnlockHash(tx, sco.UnlockHash, txid, hk)
 					}
 					for l, sco := range fc.MissedProofOutputs {
 						scoid := fcid.StorageProofOutputID(types.ProofMissed, uint64(l))
 						dbAddSiacoinOutputID(tx, scoid, txid)
-						dbAddUnlockHash(tx, sco.UnlockHash, txid)
+						dbAddUnlockHash(tx, sco.UnlockHash, txid, hk)
 					}
 				}
 				for _, fcr := range txn.FileContractRevisions {
 					dbAddFileContractID(tx, fcr.ParentID, txid)
-					dbAddUnlockHash(tx, fcr.UnlockConditions.UnlockHash(), txid)
-					dbAddUnlockHash(tx, fcr.NewUnlockHash, txid)
+					dbAddUnlockHash(tx, fcr.UnlockConditions.UnlockHash(), txid, hk)
+					dbAddUnlockHash(tx, fcr.NewUnlockHash, txid, hk)
 					for l, sco := range fcr.NewValidProofOutputs {
 						scoid := fcr.ParentID.StorageProofOutputID(types.ProofValid, uint64(l))
 						dbAddSiacoinOutputID(tx, scoid, txid)
-						dbAddUnlockHash(tx, sco.UnlockHash, txid)
+						dbAddUnlockHash(tx, sco.UnlockHash, txid, hk)
 					}
 					for l, sco := range fcr.NewMissedProofOutputs {
 						scoid := fcr.ParentID.StorageProofOutputID(types.ProofMissed, uint64(l))
 						dbAddSiacoinOutputID(tx, scoid, txid)
-						dbAddUnlockHash(tx, sco.UnlockHash, txid)
+						dbAddUnlockHash(tx, sco.UnlockHash, txid, hk)
 					}
 					dbAddFileContractRevision(tx, fcr.ParentID, fcr)
 				}
@@ -203,13 +214,13 @@ func (e *Explorer) ProcessConsensusChange(cc modules.ConsensusChange) {
 				}
 				for _, sfi := range txn.SiafundInputs {
 					dbAddSiafundOutputID(tx, sfi.ParentID, txid)
-					dbAddUnlockHash(tx, sfi.UnlockConditions.UnlockHash(), txid)
-					dbAddUnlockHash(tx, sfi.ClaimUnlockHash, txid)
+					dbAddUnlockHash(tx, sfi.UnlockConditions.UnlockHash(), txid, hk)
+					dbAddUnlockHash(tx, sfi.ClaimUnlockHash, txid, hk)
 				}
 				for k, sfo := range txn.SiafundOutputs {
 					sfoid := txn.SiafundOutputID(uint64(k))
 					dbAddSiafundOutputID(tx, sfoid, txid)
-					dbAddUnlockHash(tx, sfo.UnlockHash, txid)
+					dbAddUnlockHash(tx, sfo.UnlockHash, txid, hk)
 				}
 			}
 
@@ -218,6 +229,7 @@ func (e *Explorer) ProcessConsensusChange(cc modules.ConsensusChange) {
 				facts := dbCalculateBlockFacts(tx, e.cs, block)
 				dbAddBlockFacts(tx, facts)
 			}
+			dbAddTimeSeriesBlock(tx, block, blockheight)
 		}
 
 		// Update stats according to SiacoinOutputDiffs
@@ -303,6 +315,21 @@ func bucketIsEmpty(bucket *bolt.Bucket) bool {
 	return k == nil
 }
 
+// mustDeleteFromSet removes a txid from the set of transactions that is
+// stored in the nested bucket of key, and removes the nested bucket once it is
+// empty. A transaction can refer to the same key multiple times, in which case
+// the set may already have been removed.
+func mustDeleteFromSet(bucket *bolt.Bucket, key interface{}, txid types.TransactionID) {
+	set := bucket.Bucket(encoding.Marshal(key))
+	if set == nil {
+		return
+	}
+	mustDelete(set, txid)
+	if bucketIsEmpty(set) {
+		assertNil(bucket.DeleteBucket(encoding.Marshal(key)))
+	}
+}
+
 // These functions panic on error. The panic will be caught by
 // ProcessConsensusChange.
 
@@ -346,11 +373,7 @@ func dbAddFileContractID(tx *bolt.Tx, id types.FileContractID, txid types.Transa
 	mustPutSet(b, txid)
 }
 func dbRemoveFileContractID(tx *bolt.Tx, id types.FileContractID, txid types.TransactionID) {
-	bucket := tx.Bucket(bucketFileContractIDs).Bucket(encoding.Marshal(id))
-	mustDelete(bucket, txid)
-	if bucketIsEmpty(bucket) {
-		tx.Bucket(bucketFileContractIDs).DeleteBucket(encoding.Marshal(id))
-	}
+	mustDeleteFromSet(tx.Bucket(bucketFileContractIDs), id, txid)
 }
 
 func dbAddFileContractRevision(tx *bolt.Tx, fcid types.FileContractID, fcr types.FileContractRevision) {
@@ -382,11 +405,7 @@ func dbAddSiacoinOutputID(tx *bolt.Tx, id types.SiacoinOutputID, txid types.Tran
 	mustPutSet(b, txid)
 }
 func dbRemoveSiacoinOutputID(tx *bolt.Tx, id types.SiacoinOutputID, txid types.TransactionID) {
-	bucket := tx.Bucket(bucketSiacoinOutputIDs).Bucket(encoding.Marshal(id))
-	mustDelete(bucket, txid)
-	if bucketIsEmpty(bucket) {
-		tx.Bucket(bucketSiacoinOutputIDs).DeleteBucket(encoding.Marshal(id))
-	}
+	mustDeleteFromSet(tx.Bucket(bucketSiacoinOutputIDs), id, txid)
 }
 
 // Add/Remove siafund output
@@ -404,11 +423,7 @@ func dbAddSiafundOutputID(tx *bolt.Tx, id types.SiafundOutputID, txid types.Tran
 	mustPutSet(b, txid)
 }
 func dbRemoveSiafundOutputID(tx *bolt.Tx, id types.SiafundOutputID, txid types.TransactionID) {
-	bucket := tx.Bucket(bucketSiafundOutputIDs).Bucket(encoding.Marshal(id))
-	mustDelete(bucket, txid)
-	if bucketIsEmpty(bucket) {
-		tx.Bucket(bucketSiafundOutputIDs).DeleteBucket(encoding.Marshal(id))
-	}
+	mustDeleteFromSet(tx.Bucket(bucketSiafundOutputIDs), id, txid)
 }
 
 // Add/Remove storage proof
@@ -430,17 +445,41 @@ func dbRemoveTransactionID(tx *bolt.Tx, id types.TransactionID) {
 	mustDelete(tx.Bucket(bucketTransactionIDs), id)
 }
 
-// Add/Remove txid from unlock hash bucket
-func dbAddUnlockHash(tx *bolt.Tx, uh types.UnlockHash, txid types.TransactionID) {
+// unlockHashHistoryKey returns the key of a transaction in the history of an
+// unlock hash. The position is the index of the transaction in its block,
+// where position 0 is used by the miner payouts and position 1 by the first
+// transaction. The key is big-endian, so that the history is ordered by height
+// and position.
+func unlockHashHistoryKey(height types.BlockHeight, position int) []byte {
+	key := make([]byte, 12)
+	binary.BigEndian.PutUint64(key[:8], uint64(height))
+	binary.BigEndian.PutUint32(key[8:], uint32(position))
+	return key
+}
+
+// Add/Remove txid from unlock hash bucket and the history of the unlock hash.
+// A transaction that involves an unlock hash multiple times only appears in
+// the history once.
+func dbAddUnlockHash(tx *bolt.Tx, uh types.UnlockHash, txid types.TransactionID, hk []byte) {
 	b, err := tx.Bucket(bucketUnlockHashes).CreateBucketIfNotExists(encoding.Marshal(uh))
 	assertNil(err)
 	mustPutSet(b, txid)
+
+	b, err = tx.Bucket(bucketUnlockHashHistories).CreateBucketIfNotExists(encoding.Marshal(uh))
+	assertNil(err)
+	assertNil(b.Put(hk, encoding.Marshal(txid)))
 }
-func dbRemoveUnlockHash(tx *bolt.Tx, uh types.UnlockHash, txid types.TransactionID) {
-	bucket := tx.Bucket(bucketUnlockHashes).Bucket(encoding.Marshal(uh))
-	mustDelete(bucket, txid)
-	if bucketIsEmpty(bucket) {
-		tx.Bucket(bucketUnlockHashes).DeleteBucket(encoding.Marshal(uh))
+func dbRemoveUnlockHash(tx *bolt.Tx, uh types.UnlockHash, txid types.TransactionID, hk []byte) {
+	mustDeleteFromSet(tx.Bucket(bucketUnlockHashes), uh, txid)
+
+	history := tx.Bucket(bucketUnlockHashHistories).Bucket(encoding.Marshal(uh))
+	if history == nil {
+		// The transaction was already removed from the history.
+		return
+	}
+	assertNil(history.Delete(hk))
+	if bucketIsEmpty(history) {
+		tx.Bucket(bucketUnlockHashHistories).DeleteBucket(encoding.Marshal(uh))
 	}
 }
 
@@ -529,11 +568,12 @@ func dbAddGenesisBlock(tx *bolt.Tx) {
 	id := types.GenesisID
 	dbAddBlockID(tx, id, 0)
 	txid := types.GenesisBlock.Transactions[0].ID()
+	hk := unlockHashHistoryKey(0, 1)
 	dbAddTransactionID(tx, txid, 0)
 	for i, sfo := range types.GenesisSiafundAllocation {
 		sfoid := types.GenesisBlock.Transactions[0].SiafundOutputID(uint64(i))
 		dbAddSiafundOutputID(tx, sfoid, txid)
-		dbAddUnlockHash(tx, sfo.UnlockHash, txid)
+		dbAddUnlockHash(tx, sfo.UnlockHash, txid, hk)
 		dbAddSiafundOutput(tx, sfoid, sfo)
 	}
 	dbAddBlockFacts(tx, blockFacts{
@@ -548,4 +588,5 @@ func dbAddGenesisBlock(tx *bolt.Tx) {
 		},
 		Timestamp: types.GenesisBlock.Timestamp,
 	})
+	dbAddTimeSeriesBlock(tx, types.GenesisBlock, 0)
 }
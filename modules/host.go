@@ -1,6 +1,9 @@
 package modules
 
 import (
+	"time"
+
+	"github.com/pachisi456/Sia/crypto"
 	"github.com/pachisi456/Sia/types"
 )
 
@@ -9,6 +12,15 @@ const (
 	HostDir = "host"
 )
 
+// The prices of the host's settings that can be adjusted by pricing rules.
+const (
+	HostPriceCollateral = "collateral"
+	HostPriceContract   = "contract"
+	HostPriceDownload   = "download"
+	HostPriceStorage    = "storage"
+	HostPriceUpload     = "upload"
+)
+
 var (
 	// BlockBytesPerMonthTerabyte is the conversion rate between block-bytes and month-TB.
 	BlockBytesPerMonthTerabyte = BytesPerTerabyte.Mul64(4320)
@@ -47,6 +59,36 @@ var (
 )
 
 type (
+	// HostBandwidthMetrics reports how much data renters uploaded to and
+	// downloaded from the host, in total, per contract, and per renter. The
+	// contracts and renters are sorted by their total bandwidth in descending
+	// order, so that heavy renters come first.
+	HostBandwidthMetrics struct {
+		UploadBytes   uint64                  `json:"uploadbytes"`
+		DownloadBytes uint64                  `json:"downloadbytes"`
+		Contracts     []HostContractBandwidth `json:"contracts"`
+		Renters       []HostRenterBandwidth   `json:"renters"`
+	}
+
+	// HostContractBandwidth reports the bandwidth used through a contract.
+	// RenterPublicKey is the key the renter signs the contract's revisions
+	// with; it is empty if the contract has not been revised yet.
+	HostContractBandwidth struct {
+		ContractID      types.FileContractID `json:"contractid"`
+		RenterPublicKey types.SiaPublicKey   `json:"renterpublickey"`
+		UploadBytes     uint64               `json:"uploadbytes"`
+		DownloadBytes   uint64               `json:"downloadbytes"`
+	}
+
+	// HostRenterBandwidth reports the bandwidth used through all contracts
+	// whose revisions are signed with the same renter key.
+	HostRenterBandwidth struct {
+		RenterPublicKey types.SiaPublicKey `json:"renterpublickey"`
+		Contracts       uint64             `json:"contracts"`
+		UploadBytes     uint64             `json:"uploadbytes"`
+		DownloadBytes   uint64             `json:"downloadbytes"`
+	}
+
 	// HostFinancialMetrics provides financial statistics for the host,
 	// including money that is locked in contracts. Though verbose, these
 	// statistics should provide a clear picture of where the host's money is
@@ -96,6 +138,28 @@ type (
 		MinUploadBandwidthPrice   types.Currency `json:"minuploadbandwidthprice"`
 	}
 
+	// HostPricingRule adjusts one of the host's prices while its conditions
+	// hold. All conditions must hold for the rule to be active, and a rule
+	// without conditions is always active.
+	HostPricingRule struct {
+		// Price names the price that the rule adjusts, e.g.
+		// HostPriceStorage. While the rule is active, the price set in the
+		// host's settings is multiplied by Multiplier. The multipliers of
+		// rules that adjust the same price are multiplied.
+		Price      string  `json:"price"`
+		Multiplier float64 `json:"multiplier"`
+
+		// RemainingStorageBelow, if not zero, limits the rule to times when
+		// the fraction of the host's storage that is unused is below it.
+		RemainingStorageBelow float64 `json:"remainingstoragebelow"`
+
+		// StartHour and EndHour, if they differ, limit the rule to the hours
+		// from StartHour up to EndHour in UTC. The hours may wrap around
+		// midnight.
+		StartHour int `json:"starthour"`
+		EndHour   int `json:"endhour"`
+	}
+
 	// HostNetworkMetrics reports the quantity of each type of RPC call that
 	// has been made to the host.
 	HostNetworkMetrics struct {
@@ -108,6 +172,20 @@ type (
 		UnrecognizedCalls uint64 `json:"unrecognizedcalls"`
 	}
 
+	// HostScrubMetrics reports the results of the host's sector scrubber,
+	// which periodically reads the stored sectors and verifies their Merkle
+	// roots. The counters are totals over all passes of the scrubber.
+	// Corrupted sectors are quarantined, meaning that they are removed from
+	// the storage manager until a renter uploads them again.
+	HostScrubMetrics struct {
+		ScrubbedSectors    uint64        `json:"scrubbedsectors"`
+		CorruptSectors     uint64        `json:"corruptsectors"`
+		UnreadableSectors  uint64        `json:"unreadablesectors"`
+		LastScrubStarted   time.Time     `json:"lastscrubstarted"`
+		LastScrubCompleted time.Time     `json:"lastscrubcompleted"`
+		QuarantinedSectors []crypto.Hash `json:"quarantinedsectors"`
+	}
+
 	// StorageObligation contains information about a storage obligation that
 	// the host has accepted.
 	StorageObligation struct {
@@ -139,6 +217,10 @@ type (
 		// AnnounceAddress submits an announcement using the given address.
 		AnnounceAddress(NetAddress) error
 
+		// BandwidthMetrics returns the bandwidth that renters used through the
+		// host's contracts.
+		BandwidthMetrics() HostBandwidthMetrics
+
 		// ExternalSettings returns the settings of the host as seen by an
 		// untrusted node querying the host for settings.
 		ExternalSettings() HostExternalSettings
@@ -154,12 +236,21 @@ type (
 		// have been made to the host.
 		NetworkMetrics() HostNetworkMetrics
 
+		// PricingRules returns the rules that adjust the host's prices.
+		PricingRules() []HostPricingRule
+
 		// PublicKey returns the public key of the host.
 		PublicKey() types.SiaPublicKey
 
+		// ScrubMetrics returns the results of the host's sector scrubber.
+		ScrubMetrics() HostScrubMetrics
+
 		// SetInternalSettings sets the hosting parameters of the host.
 		SetInternalSettings(HostInternalSettings) error
 
+		// SetPricingRules replaces the rules that adjust the host's prices.
+		SetPricingRules([]HostPricingRule) error
+
 		// StorageObligations returns the set of storage obligations held by
 		// the host.
 		StorageObligations() []StorageObligation
@@ -0,0 +1,64 @@
+package host
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/pachisi456/Sia/build"
+	"github.com/pachisi456/Sia/modules"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+// BandwidthMetrics returns the amount of data that renters uploaded to and
+// downloaded from the host through its contracts. The counters are stored
+// with the storage obligations, so they survive restarts and include
+// contracts that have ended.
+func (h *Host) BandwidthMetrics() modules.HostBandwidthMetrics {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var bm modules.HostBandwidthMetrics
+	renters := make(map[string]*modules.HostRenterBandwidth)
+	err := h.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketStorageObligations).ForEach(func(_, soBytes []byte) error {
+			var so storageObligation
+			if err := json.Unmarshal(soBytes, &so); err != nil {
+				return build.ExtendErr("unable to unmarshal storage obligation:", err)
+			}
+			cb := modules.HostContractBandwidth{
+				ContractID:      so.id(),
+				RenterPublicKey: so.renterPublicKey(),
+				UploadBytes:     so.UploadBytes,
+				DownloadBytes:   so.DownloadBytes,
+			}
+			bm.Contracts = append(bm.Contracts, cb)
+			bm.UploadBytes += cb.UploadBytes
+			bm.DownloadBytes += cb.DownloadBytes
+
+			rb, exists := renters[cb.RenterPublicKey.String()]
+			if !exists {
+				rb = &modules.HostRenterBandwidth{RenterPublicKey: cb.RenterPublicKey}
+				renters[cb.RenterPublicKey.String()] = rb
+			}
+			rb.Contracts++
+			rb.UploadBytes += cb.UploadBytes
+			rb.DownloadBytes += cb.DownloadBytes
+			return nil
+		})
+	})
+	if err != nil {
+		h.log.Println(build.ExtendErr("database failed to provide storage obligations:", err))
+	}
+
+	for _, rb := range renters {
+		bm.Renters = append(bm.Renters, *rb)
+	}
+	sort.Slice(bm.Contracts, func(i, j int) bool {
+		return bm.Contracts[i].UploadBytes+bm.Contracts[i].DownloadBytes > bm.Contracts[j].UploadBytes+bm.Contracts[j].DownloadBytes
+	})
+	sort.Slice(bm.Renters, func(i, j int) bool {
+		return bm.Renters[i].UploadBytes+bm.Renters[i].DownloadBytes > bm.Renters[j].UploadBytes+bm.Renters[j].DownloadBytes
+	})
+	return bm
+}
@@ -0,0 +1,74 @@
+package host
+
+import (
+	"testing"
+
+	"github.com/pachisi456/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+// TestBandwidthMetrics checks that the bandwidth of the host's storage
+// obligations is reported per contract and aggregated per renter.
+func TestBandwidthMetrics(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	ht, err := blankHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	// newObligation creates a storage obligation whose revision is signed by
+	// the renter key.
+	newObligation := func(seed byte, renterKey []byte, up, down uint64) storageObligation {
+		return storageObligation{
+			OriginTransactionSet: []types.Transaction{{
+				FileContracts: []types.FileContract{{FileSize: uint64(seed)}},
+			}},
+			RevisionTransactionSet: []types.Transaction{{
+				FileContractRevisions: []types.FileContractRevision{{
+					UnlockConditions: types.UnlockConditions{
+						PublicKeys: []types.SiaPublicKey{{Algorithm: types.SignatureEd25519, Key: renterKey}},
+					},
+				}},
+			}},
+			UploadBytes:   up,
+			DownloadBytes: down,
+		}
+	}
+	sos := []storageObligation{
+		newObligation(1, []byte{1}, 100, 10),
+		newObligation(2, []byte{1}, 200, 20),
+		newObligation(3, []byte{2}, 50, 1000),
+	}
+	err = ht.host.db.Update(func(tx *bolt.Tx) error {
+		for _, so := range sos {
+			if err := putStorageObligation(tx, so); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bm := ht.host.BandwidthMetrics()
+	if bm.UploadBytes != 350 || bm.DownloadBytes != 1030 {
+		t.Fatal("wrong totals:", bm.UploadBytes, bm.DownloadBytes)
+	}
+	if len(bm.Contracts) != 3 || bm.Contracts[0].ContractID != sos[2].id() {
+		t.Fatal("contracts are not sorted by bandwidth:", bm.Contracts)
+	}
+	if len(bm.Renters) != 2 {
+		t.Fatal("expected 2 renters, got", bm.Renters)
+	}
+	if r := bm.Renters[0]; r.Contracts != 1 || r.DownloadBytes != 1000 {
+		t.Fatal("wrong bandwidth of the heaviest renter:", r)
+	}
+	if r := bm.Renters[1]; r.Contracts != 2 || r.UploadBytes != 300 || r.DownloadBytes != 30 {
+		t.Fatal("bandwidth of a renter's contracts was not aggregated:", r)
+	}
+}
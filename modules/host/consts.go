@@ -180,6 +180,14 @@ var (
 		Testing:  time.Second * 3,
 	}).(time.Duration)
 
+	// pricingRulesInterval defines how often the host applies its pricing
+	// rules.
+	pricingRulesInterval = build.Select(build.Var{
+		Dev:      time.Minute,
+		Standard: time.Minute * 10,
+		Testing:  time.Second,
+	}).(time.Duration)
+
 	// revisionSubmissionBuffer describes the number of blocks ahead of time
 	// that the host will submit a file contract revision. The host will not
 	// accept any more revisions once inside the submission buffer.
@@ -197,6 +205,23 @@ var (
 		Testing:  time.Millisecond,
 	}).(time.Duration)
 
+	// scrubInterval defines how long the host waits between two passes of the
+	// sector scrubber.
+	scrubInterval = build.Select(build.Var{
+		Dev:      time.Minute * 10,
+		Standard: time.Hour * 24 * 7,
+		Testing:  time.Minute,
+	}).(time.Duration)
+
+	// scrubSectorSleep defines how long the sector scrubber sleeps after
+	// verifying a sector, so that scrubbing does not starve renters of disk
+	// throughput.
+	scrubSectorSleep = build.Select(build.Var{
+		Dev:      time.Millisecond * 10,
+		Standard: time.Millisecond * 100,
+		Testing:  time.Duration(0),
+	}).(time.Duration)
+
 	// workingStatusFirstCheck defines how frequently the Host's working status
 	// check runs
 	workingStatusFirstCheck = build.Select(build.Var{
@@ -327,45 +327,55 @@ func (wal *writeAheadLog) commitAddStorageFolder(ssf savedStorageFolder) {
 	wal.cm.storageFolders[sf.index] = sf
 }
 
-// AddStorageFolder adds a storage folder to the contract manager.
-func (cm *ContractManager) AddStorageFolder(path string, size uint64) error {
-	err := cm.tg.Add()
-	if err != nil {
-		return err
-	}
-	defer cm.tg.Done()
-
+// newStorageFolder checks that a storage folder with the provided path and
+// size can be added, and returns a storage folder object that can be added to
+// the WAL.
+func newStorageFolder(path string, size uint64) (*storageFolder, error) {
 	// Check that the storage folder being added meets the size requirements.
 	sectors := size / modules.SectorSize
 	if sectors > MaximumSectorsPerStorageFolder {
-		return ErrLargeStorageFolder
+		return nil, ErrLargeStorageFolder
 	}
 	if sectors < MinimumSectorsPerStorageFolder {
-		return ErrSmallStorageFolder
+		return nil, ErrSmallStorageFolder
 	}
 	if sectors%storageFolderGranularity != 0 {
-		return errStorageFolderGranularity
+		return nil, errStorageFolderGranularity
 	}
 	// Check that the path is an absolute path.
 	if !filepath.IsAbs(path) {
-		return errRelativePath
+		return nil, errRelativePath
 	}
 
 	// Check that the folder being linked to both exists and is a folder.
 	pathInfo, err := os.Stat(path)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if !pathInfo.Mode().IsDir() {
-		return errStorageFolderNotFolder
+		return nil, errStorageFolderNotFolder
 	}
 
-	// Create a storage folder object and add it to the WAL.
-	newSF := &storageFolder{
+	return &storageFolder{
 		path:  path,
 		usage: make([]uint64, sectors/64),
 
 		availableSectors: make(map[sectorID]uint32),
+	}, nil
+}
+
+// AddStorageFolder adds a storage folder to the contract manager.
+func (cm *ContractManager) AddStorageFolder(path string, size uint64) error {
+	err := cm.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer cm.tg.Done()
+
+	// Create a storage folder object and add it to the WAL.
+	newSF, err := newStorageFolder(path, size)
+	if err != nil {
+		return err
 	}
 	err = cm.wal.managedAddStorageFolder(newSF)
 	if err != nil {
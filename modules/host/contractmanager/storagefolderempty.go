@@ -2,10 +2,12 @@ package contractmanager
 
 import (
 	"errors"
+	"math/bits"
 	"sync"
 	"sync/atomic"
 
 	"github.com/pachisi456/Sia/build"
+	"github.com/pachisi456/Sia/modules"
 )
 
 var (
@@ -16,8 +18,9 @@ var (
 )
 
 // managedMoveSector will move a sector from its current storage folder to
-// another.
-func (wal *writeAheadLog) managedMoveSector(id sectorID) error {
+// another. If a destination is provided, the sector is only moved into the
+// destination, otherwise it is moved into any available storage folder.
+func (wal *writeAheadLog) managedMoveSector(id sectorID, destination *storageFolder) error {
 	wal.managedLockSector(id)
 	defer wal.managedUnlockSector(id)
 
@@ -51,6 +54,9 @@ func (wal *writeAheadLog) managedMoveSector(id sectorID) error {
 	wal.mu.Lock()
 	storageFolders := wal.cm.availableStorageFolders()
 	wal.mu.Unlock()
+	if destination != nil {
+		storageFolders = []*storageFolder{destination}
+	}
 	for len(storageFolders) >= 1 {
 		var storageFolderIndex int
 		err := func() error {
@@ -155,13 +161,17 @@ func (wal *writeAheadLog) managedMoveSector(id sectorID) error {
 // managedEmptyStorageFolder will empty out the storage folder with the
 // provided index starting with the 'startingPoint'th sector all the way to the
 // end of the storage folder, allowing the storage folder to be safely
-// truncated. If 'force' is set to true, the function will not give up when
-// there is no more space available, instead choosing to lose data.
+// truncated. If a destination is provided, all sectors are moved into the
+// destination.
+//
+// The progress of the storage folder is set to the amount of sector data that
+// needs to be moved, so that long migrations can be followed by the user.
+// Sectors can still be read while they are waiting to be moved.
 //
 // This function assumes that the storage folder has already been made
 // invisible to AddSector, and that this is the only thread that will be
 // interacting with the storage folder.
-func (wal *writeAheadLog) managedEmptyStorageFolder(sfIndex uint16, startingPoint uint32) (uint64, error) {
+func (wal *writeAheadLog) managedEmptyStorageFolder(sfIndex uint16, startingPoint uint32, destination *storageFolder) (uint64, error) {
 	// Grab the storage folder in question.
 	wal.mu.Lock()
 	sf, exists := wal.cm.storageFolders[sfIndex]
@@ -179,6 +189,19 @@ func (wal *writeAheadLog) managedEmptyStorageFolder(sfIndex uint16, startingPoin
 	}
 	atomic.AddUint64(&sf.atomicSuccessfulReads, 1)
 
+	// Report the amount of sector data that needs to be moved as the progress
+	// of the storage folder.
+	var sectors uint64
+	for _, usage := range sf.usage[startingPoint/storageFolderGranularity:] {
+		sectors += uint64(bits.OnesCount64(usage))
+	}
+	atomic.StoreUint64(&sf.atomicProgressNumerator, 0)
+	atomic.StoreUint64(&sf.atomicProgressDenominator, sectors*modules.SectorSize)
+	defer func() {
+		atomic.StoreUint64(&sf.atomicProgressNumerator, 0)
+		atomic.StoreUint64(&sf.atomicProgressDenominator, 0)
+	}()
+
 	// Before iterating through the sectors and moving them, set up a thread
 	// pool that can parallelize the transfers without spinning up 250,000
 	// goroutines per TB.
@@ -192,11 +215,12 @@ func (wal *writeAheadLog) managedEmptyStorageFolder(sfIndex uint16, startingPoin
 			for {
 				select {
 				case id := <-workChan:
-					err := wal.managedMoveSector(id)
+					err := wal.managedMoveSector(id, destination)
 					if err != nil {
 						atomic.AddUint64(&errCount, 1)
 						wal.cm.log.Println("Unable to write sector:", err)
 					}
+					atomic.AddUint64(&sf.atomicProgressNumerator, modules.SectorSize)
 					wg.Done()
 				case <-doneChan:
 					return
@@ -226,6 +250,9 @@ func (wal *writeAheadLog) managedEmptyStorageFolder(sfIndex uint16, startingPoin
 				if !exists {
 					// The sector has been deleted, but the usage has not been
 					// updated yet. Safe to ignore.
+					atomic.AddUint64(&sf.atomicProgressNumerator, modules.SectorSize)
+					readHead += sectorMetadataDiskSize
+					usageMask = usageMask << 1
 					continue
 				}
 
@@ -0,0 +1,56 @@
+package contractmanager
+
+import (
+	"sync/atomic"
+
+	"github.com/pachisi456/Sia/build"
+	"github.com/pachisi456/Sia/modules"
+)
+
+// MoveStorageFolder will move a storage folder to a new path, for example to
+// put it on another disk. A storage folder of the same size is created at the
+// new path, after which all of the sectors are migrated into it and the old
+// storage folder is removed. Sectors can be read from both storage folders
+// while the migration is in progress, and the progress is reported through
+// the old storage folder.
+//
+// If not all sectors can be migrated, an error is returned and both storage
+// folders are kept, so that no data is lost. Calling MoveStorageFolder again
+// is not possible in that case, instead the old storage folder can be removed
+// once the problem has been resolved.
+func (cm *ContractManager) MoveStorageFolder(index uint16, newPath string) error {
+	err := cm.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer cm.tg.Done()
+
+	cm.wal.mu.Lock()
+	sf, exists := cm.storageFolders[index]
+	cm.wal.mu.Unlock()
+	if !exists || atomic.LoadUint64(&sf.atomicUnavailable) == 1 {
+		return errStorageFolderNotFound
+	}
+
+	// Create the new storage folder.
+	size := uint64(len(sf.usage)) * storageFolderGranularity * modules.SectorSize
+	newSF, err := newStorageFolder(newPath, size)
+	if err != nil {
+		return err
+	}
+	err = cm.wal.managedAddStorageFolder(newSF)
+	if err != nil {
+		return err
+	}
+
+	// Lock the old storage folder for the rest of the operation, so that no
+	// new sectors are placed into it, and move its sectors.
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	_, err = cm.wal.managedEmptyStorageFolder(index, 0, newSF)
+	if err != nil {
+		return build.ExtendErr("unable to move all sectors to the new storage folder", err)
+	}
+	cm.wal.managedRemoveEmptyStorageFolder(sf)
+	return nil
+}
@@ -0,0 +1,110 @@
+package contractmanager
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pachisi456/Sia/crypto"
+	"github.com/pachisi456/Sia/modules"
+)
+
+// TestMoveStorageFolder moves a storage folder with sectors in it to a new
+// path, and checks that the sectors are still available afterwards.
+func TestMoveStorageFolder(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cmt, err := newContractManagerTester("TestMoveStorageFolder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	// Add a storage folder to the contract manager tester.
+	oldDir := filepath.Join(cmt.persistDir, "storageFolderOne")
+	newDir := filepath.Join(cmt.persistDir, "storageFolderTwo")
+	for _, dir := range []string{oldDir, newDir} {
+		err = os.MkdirAll(dir, 0700)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = cmt.cm.AddStorageFolder(oldDir, modules.SectorSize*storageFolderGranularity*2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Add sectors to the storage folder.
+	roots := make([]crypto.Hash, 10)
+	datas := make([][]byte, 10)
+	for i := range roots {
+		roots[i], datas[i] = randSector()
+		err = cmt.cm.AddSector(roots[i], datas[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Move the storage folder.
+	sfs := cmt.cm.StorageFolders()
+	if len(sfs) != 1 {
+		t.Fatal("there should be one storage folder in the contract manager")
+	}
+	err = cmt.cm.MoveStorageFolder(sfs[0].Index, oldDir)
+	if err != ErrRepeatFolder {
+		t.Fatal("expected ErrRepeatFolder, got", err)
+	}
+	err = cmt.cm.MoveStorageFolder(sfs[0].Index, newDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// checkMoved checks that the storage folder is only available at the new
+	// path and still holds all of the sectors.
+	checkMoved := func() {
+		sfs := cmt.cm.StorageFolders()
+		if len(sfs) != 1 {
+			t.Fatal("there should be one storage folder in the contract manager, got", len(sfs))
+		}
+		if sfs[0].Path != newDir {
+			t.Fatal("storage folder was not moved to the new path:", sfs[0].Path)
+		}
+		if sfs[0].Capacity != modules.SectorSize*storageFolderGranularity*2 {
+			t.Fatal("storage folder changed its capacity:", sfs[0].Capacity)
+		}
+		if sfs[0].CapacityRemaining != sfs[0].Capacity-modules.SectorSize*uint64(len(roots)) {
+			t.Fatal("storage folder does not hold all of the sectors")
+		}
+		if sfs[0].ProgressDenominator != 0 {
+			t.Fatal("ProgressDenominator is indicating that actions still remain")
+		}
+		for i, root := range roots {
+			data, err := cmt.cm.ReadSector(root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data, datas[i]) {
+				t.Fatal("moved sector has the wrong data")
+			}
+		}
+	}
+	checkMoved()
+	_, err = os.Stat(filepath.Join(oldDir, sectorFile))
+	if !os.IsNotExist(err) {
+		t.Fatal("sector file should have been removed")
+	}
+
+	// Restart the contract manager to see if the move persisted.
+	err = cmt.cm.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmt.cm, err = New(filepath.Join(cmt.persistDir, modules.ContractManagerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkMoved()
+}
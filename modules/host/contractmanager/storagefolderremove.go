@@ -65,31 +65,37 @@ func (cm *ContractManager) RemoveStorageFolder(index uint16, force bool) error {
 	defer sf.mu.Unlock()
 
 	// Clear out the sectors in the storage folder.
-	_, err := cm.wal.managedEmptyStorageFolder(index, 0)
+	_, err := cm.wal.managedEmptyStorageFolder(index, 0, nil)
 	if err != nil && !force {
 		return err
 	}
+	cm.wal.managedRemoveEmptyStorageFolder(sf)
+	return nil
+}
 
+// managedRemoveEmptyStorageFolder removes a storage folder whose sectors have
+// been moved to other storage folders, blocking until the removal has been
+// synchronized. The storage folder must be locked by the caller.
+func (wal *writeAheadLog) managedRemoveEmptyStorageFolder(sf *storageFolder) {
 	// Wait for a synchronize to confirm that all of the moves have succeeded
 	// in full.
-	cm.wal.mu.Lock()
-	syncChan := cm.wal.syncChan
-	cm.wal.mu.Unlock()
+	wal.mu.Lock()
+	syncChan := wal.syncChan
+	wal.mu.Unlock()
 	<-syncChan
 
 	// Submit a storage folder removal to the WAL and wait until the update is
 	// synced.
-	cm.wal.mu.Lock()
-	cm.wal.appendChange(stateChange{
+	wal.mu.Lock()
+	wal.appendChange(stateChange{
 		StorageFolderRemovals: []storageFolderRemoval{{
-			Index: index,
+			Index: sf.index,
 			Path:  sf.path,
 		}},
 	})
 
 	// Wait until the removal action has been synchronized.
-	syncChan = cm.wal.syncChan
-	cm.wal.mu.Unlock()
+	syncChan = wal.syncChan
+	wal.mu.Unlock()
 	<-syncChan
-	return nil
 }
@@ -71,7 +71,7 @@ func (wal *writeAheadLog) shrinkStorageFolder(index uint16, newSectorCount uint3
 	defer sf.mu.Unlock()
 
 	// Clear out the sectors in the storage folder.
-	_, err := wal.managedEmptyStorageFolder(index, newSectorCount)
+	_, err := wal.managedEmptyStorageFolder(index, newSectorCount, nil)
 	if err != nil && !force {
 		return err
 	}
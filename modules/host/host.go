@@ -69,6 +69,7 @@ import (
 	"net"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/pachisi456/Sia/build"
 	"github.com/pachisi456/Sia/crypto"
@@ -160,6 +161,17 @@ type Host struct {
 	workingStatus        modules.HostWorkingStatus
 	connectabilityStatus modules.HostConnectabilityStatus
 
+	// Pricing rules adjust the prices of the host's settings, see pricing.go.
+	// basePrices are the prices that the user set, while settings holds the
+	// prices after the rules were applied.
+	basePrices   hostPrices
+	pricingRules []modules.HostPricingRule
+
+	// The sector scrubber verifies the stored sectors and quarantines the
+	// ones that are corrupted, see scrub.go.
+	quarantinedSectors map[crypto.Hash]struct{}
+	scrubMetrics       modules.HostScrubMetrics
+
 	// A map of storage obligations that are currently being modified. Locks on
 	// storage obligations can be long-running, and each storage obligation can
 	// be locked separately.
@@ -223,6 +235,7 @@ func newHost(dependencies dependencies, cs modules.ConsensusSet, tpool modules.T
 		dependencies: dependencies,
 
 		lockedStorageObligations: make(map[types.FileContractID]*siasync.TryMutex),
+		quarantinedSectors:       make(map[crypto.Hash]struct{}),
 
 		persistDir: persistDir,
 	}
@@ -289,6 +302,20 @@ func newHost(dependencies dependencies, cs modules.ConsensusSet, tpool modules.T
 		h.log.Println("Could not initialize host networking:", err)
 		return nil, err
 	}
+
+	// Start applying the pricing rules.
+	threadedApplyPricingRulesClosedChan := make(chan struct{})
+	go h.threadedApplyPricingRules(threadedApplyPricingRulesClosedChan)
+	h.tg.OnStop(func() {
+		<-threadedApplyPricingRulesClosedChan
+	})
+
+	// Start scrubbing the sectors.
+	threadedScrubSectorsClosedChan := make(chan struct{})
+	go h.threadedScrubSectors(threadedScrubSectorsClosedChan)
+	h.tg.OnStop(func() {
+		<-threadedScrubSectorsClosedChan
+	})
 	return h, nil
 }
 
@@ -387,10 +414,20 @@ func (h *Host) SetInternalSettings(settings modules.HostInternalSettings) error
 		h.announced = false
 	}
 
+	// The prices of the settings are the base prices of the pricing rules.
+	h.updateBasePrices(settings)
+	h.effectivePrices(h.remainingStorage(), time.Now()).apply(&settings)
+	return h.updateSettings(settings)
+}
+
+// updateSettings replaces the host's settings and saves them. The revision
+// number is increased, so that renters learn about the new settings. The
+// host's lock must be held.
+func (h *Host) updateSettings(settings modules.HostInternalSettings) error {
 	h.settings = settings
 	h.revisionNumber++
 
-	err = h.saveSync()
+	err := h.saveSync()
 	if err != nil {
 		return errors.New("internal settings updated, but failed saving to disk: " + err.Error())
 	}
@@ -90,7 +90,9 @@ func (h *Host) managedDownloadIteration(conn net.Conn, so *storageObligation) er
 		// Load the sectors and build the data payload.
 		for _, request := range requests {
 			sectorData, err := h.ReadSector(request.MerkleRoot)
-			if err != nil {
+			if err != nil && h.managedSectorQuarantined(request.MerkleRoot) {
+				return extendErr("failed to load sector: ", ErrorInternal(errSectorQuarantined.Error()))
+			} else if err != nil {
 				return extendErr("failed to load sector: ", ErrorInternal(err.Error()))
 			}
 			payload = append(payload, sectorData[request.Offset:request.Offset+request.Length])
@@ -118,6 +120,9 @@ func (h *Host) managedDownloadIteration(conn net.Conn, so *storageObligation) er
 	// Update the storage obligation.
 	paymentTransfer := existingRevision.NewValidProofOutputs[0].Value.Sub(paymentRevision.NewValidProofOutputs[0].Value)
 	so.PotentialDownloadRevenue = so.PotentialDownloadRevenue.Add(paymentTransfer)
+	for _, data := range payload {
+		so.DownloadBytes += uint64(len(data))
+	}
 	so.RevisionTransactionSet = []types.Transaction{{
 		FileContractRevisions: []types.FileContractRevision{paymentRevision},
 		TransactionSignatures: []types.TransactionSignature{renterSignature, txn.TransactionSignatures[1]},
@@ -60,6 +60,7 @@ func (h *Host) managedRevisionIteration(conn net.Conn, so *storageObligation, fi
 	// with the ability to reverse them. Then verify the file contract revision
 	// correctly accounts for the changes.
 	var bandwidthRevenue types.Currency // Upload bandwidth.
+	var uploadBytes uint64
 	var storageRevenue types.Currency
 	var newCollateral types.Currency
 	var sectorsRemoved []crypto.Hash
@@ -97,6 +98,7 @@ func (h *Host) managedRevisionIteration(conn net.Conn, so *storageObligation, fi
 				blocksRemaining := so.proofDeadline() - blockHeight
 				blockBytesCurrency := types.NewCurrency64(uint64(blocksRemaining)).Mul64(modules.SectorSize)
 				bandwidthRevenue = bandwidthRevenue.Add(settings.MinUploadBandwidthPrice.Mul64(modules.SectorSize))
+				uploadBytes += modules.SectorSize
 				storageRevenue = storageRevenue.Add(settings.MinStoragePrice.Mul(blockBytesCurrency))
 				newCollateral = newCollateral.Add(settings.Collateral.Mul(blockBytesCurrency))
 
@@ -123,6 +125,7 @@ func (h *Host) managedRevisionIteration(conn net.Conn, so *storageObligation, fi
 
 				// Update finances.
 				bandwidthRevenue = bandwidthRevenue.Add(settings.MinUploadBandwidthPrice.Mul64(uint64(len(modification.Data))))
+				uploadBytes += uint64(len(modification.Data))
 
 				// Update the sectors removed and gained to indicate that the old
 				// sector has been replaced with a new sector.
@@ -164,6 +167,7 @@ func (h *Host) managedRevisionIteration(conn net.Conn, so *storageObligation, fi
 	so.PotentialStorageRevenue = so.PotentialStorageRevenue.Add(storageRevenue)
 	so.RiskedCollateral = so.RiskedCollateral.Add(newCollateral)
 	so.PotentialUploadRevenue = so.PotentialUploadRevenue.Add(bandwidthRevenue)
+	so.UploadBytes += uploadBytes
 	so.RevisionTransactionSet = []types.Transaction{txn}
 	h.mu.Lock()
 	err = h.modifyStorageObligation(*so, sectorsRemoved, sectorsGained, gainedSectorData)
@@ -29,6 +29,13 @@ type persistence struct {
 	SecretKey        crypto.SecretKey             `json:"secretkey"`
 	Settings         modules.HostInternalSettings `json:"settings"`
 	UnlockHash       types.UnlockHash             `json:"unlockhash"`
+
+	// Pricing.
+	BasePrices   hostPrices                `json:"baseprices"`
+	PricingRules []modules.HostPricingRule `json:"pricingrules"`
+
+	// Sector scrubbing.
+	ScrubMetrics modules.HostScrubMetrics `json:"scrubmetrics"`
 }
 
 // persistData returns the data in the Host that will be saved to disk.
@@ -47,6 +54,13 @@ func (h *Host) persistData() persistence {
 		SecretKey:        h.secretKey,
 		Settings:         h.settings,
 		UnlockHash:       h.unlockHash,
+
+		// Pricing.
+		BasePrices:   h.basePrices,
+		PricingRules: h.pricingRules,
+
+		// Sector scrubbing.
+		ScrubMetrics: h.scrubReport(),
 	}
 }
 
@@ -69,6 +83,7 @@ func (h *Host) establishDefaults() error {
 		MinDownloadBandwidthPrice: defaultDownloadBandwidthPrice,
 		MinUploadBandwidthPrice:   defaultUploadBandwidthPrice,
 	}
+	h.basePrices = pricesOf(h.settings)
 
 	// Generate signing key, for revising contracts.
 	sk, pk := crypto.GenerateKeyPair()
@@ -107,6 +122,21 @@ func (h *Host) loadPersistObject(p *persistence) {
 		h.settings.NetAddress = ""
 	}
 	h.unlockHash = p.UnlockHash
+
+	// Copy over the pricing rules. Without rules, the prices of the settings
+	// are the base prices, which also covers persist files that predate the
+	// pricing rules.
+	h.basePrices = p.BasePrices
+	h.pricingRules = p.PricingRules
+	if len(h.pricingRules) == 0 {
+		h.basePrices = pricesOf(h.settings)
+	}
+
+	// Copy over the results of the sector scrubber.
+	h.scrubMetrics = p.ScrubMetrics
+	for _, root := range p.ScrubMetrics.QuarantinedSectors {
+		h.quarantinedSectors[root] = struct{}{}
+	}
 }
 
 // initDB will check that the database has been initialized and if not, will
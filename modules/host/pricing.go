@@ -0,0 +1,238 @@
+package host
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/types"
+)
+
+var (
+	// errInvalidMultiplier is returned if a pricing rule has a multiplier
+	// that is not a positive number.
+	errInvalidMultiplier = errors.New("pricing rule multiplier must be a positive number")
+
+	// errInvalidPricingHours is returned if a pricing rule has a start or end
+	// hour outside of a day.
+	errInvalidPricingHours = errors.New("pricing rule hours must be between 0 and 23")
+
+	// errInvalidRemainingStorage is returned if the remaining storage
+	// condition of a pricing rule is not a fraction.
+	errInvalidRemainingStorage = errors.New("pricing rule remaining storage must be between 0 and 1")
+)
+
+// hostPriceNames lists the prices that pricing rules can adjust.
+var hostPriceNames = []string{
+	modules.HostPriceCollateral,
+	modules.HostPriceContract,
+	modules.HostPriceDownload,
+	modules.HostPriceStorage,
+	modules.HostPriceUpload,
+}
+
+// hostPrices are the prices of the host's settings that pricing rules can
+// adjust.
+type hostPrices struct {
+	Collateral                types.Currency `json:"collateral"`
+	MinContractPrice          types.Currency `json:"mincontractprice"`
+	MinDownloadBandwidthPrice types.Currency `json:"mindownloadbandwidthprice"`
+	MinStoragePrice           types.Currency `json:"minstorageprice"`
+	MinUploadBandwidthPrice   types.Currency `json:"minuploadbandwidthprice"`
+}
+
+// pricesOf returns the prices of the settings.
+func pricesOf(settings modules.HostInternalSettings) hostPrices {
+	return hostPrices{
+		Collateral:                settings.Collateral,
+		MinContractPrice:          settings.MinContractPrice,
+		MinDownloadBandwidthPrice: settings.MinDownloadBandwidthPrice,
+		MinStoragePrice:           settings.MinStoragePrice,
+		MinUploadBandwidthPrice:   settings.MinUploadBandwidthPrice,
+	}
+}
+
+// apply sets the prices of the settings to p.
+func (p hostPrices) apply(settings *modules.HostInternalSettings) {
+	settings.Collateral = p.Collateral
+	settings.MinContractPrice = p.MinContractPrice
+	settings.MinDownloadBandwidthPrice = p.MinDownloadBandwidthPrice
+	settings.MinStoragePrice = p.MinStoragePrice
+	settings.MinUploadBandwidthPrice = p.MinUploadBandwidthPrice
+}
+
+// price returns a pointer to the price with the given name, or nil if the
+// name is unknown.
+func (p *hostPrices) price(name string) *types.Currency {
+	switch name {
+	case modules.HostPriceCollateral:
+		return &p.Collateral
+	case modules.HostPriceContract:
+		return &p.MinContractPrice
+	case modules.HostPriceDownload:
+		return &p.MinDownloadBandwidthPrice
+	case modules.HostPriceStorage:
+		return &p.MinStoragePrice
+	case modules.HostPriceUpload:
+		return &p.MinUploadBandwidthPrice
+	}
+	return nil
+}
+
+// equals returns whether all prices of p and q are equal.
+func (p hostPrices) equals(q hostPrices) bool {
+	for _, name := range hostPriceNames {
+		if !p.price(name).Equals(*q.price(name)) {
+			return false
+		}
+	}
+	return true
+}
+
+// validatePricingRule checks that a pricing rule adjusts a known price by a
+// sensible amount, and that its conditions can be met.
+func validatePricingRule(rule modules.HostPricingRule) error {
+	if (&hostPrices{}).price(rule.Price) == nil {
+		return fmt.Errorf("unknown price %q", rule.Price)
+	}
+	if !(rule.Multiplier > 0) || math.IsInf(rule.Multiplier, 0) {
+		return errInvalidMultiplier
+	}
+	if rule.RemainingStorageBelow < 0 || rule.RemainingStorageBelow > 1 {
+		return errInvalidRemainingStorage
+	}
+	if rule.StartHour < 0 || rule.StartHour > 23 || rule.EndHour < 0 || rule.EndHour > 23 {
+		return errInvalidPricingHours
+	}
+	return nil
+}
+
+// pricingRuleActive returns whether the conditions of the rule hold, given
+// the fraction of the host's storage that is remaining and the current time.
+// A rule without conditions is always active.
+func pricingRuleActive(rule modules.HostPricingRule, remainingStorage float64, now time.Time) bool {
+	if rule.RemainingStorageBelow != 0 && remainingStorage >= rule.RemainingStorageBelow {
+		return false
+	}
+	if rule.StartHour != rule.EndHour {
+		hour := now.UTC().Hour()
+		if rule.StartHour < rule.EndHour && (hour < rule.StartHour || hour >= rule.EndHour) {
+			return false
+		}
+		// The hours wrap around midnight.
+		if rule.StartHour > rule.EndHour && hour < rule.StartHour && hour >= rule.EndHour {
+			return false
+		}
+	}
+	return true
+}
+
+// remainingStorage returns the fraction of the host's storage that is not
+// used yet. A host without storage is considered to have all of its storage
+// remaining, so that rules for scarce storage don't apply to it.
+func (h *Host) remainingStorage() float64 {
+	var capacity, remaining uint64
+	for _, sf := range h.StorageFolders() {
+		capacity += sf.Capacity
+		remaining += sf.CapacityRemaining
+	}
+	if capacity == 0 {
+		return 1
+	}
+	return float64(remaining) / float64(capacity)
+}
+
+// effectivePrices returns the base prices of the host, adjusted by the pricing
+// rules that are active. The multipliers of rules that adjust the same price
+// are multiplied. The host's lock must be held.
+func (h *Host) effectivePrices(remainingStorage float64, now time.Time) hostPrices {
+	prices := h.basePrices
+	for _, rule := range h.pricingRules {
+		if pricingRuleActive(rule, remainingStorage, now) {
+			price := prices.price(rule.Price)
+			*price = price.MulFloat(rule.Multiplier)
+		}
+	}
+	return prices
+}
+
+// updateBasePrices updates the base prices with the prices of new settings.
+// The current settings hold the adjusted prices, so only prices that differ
+// from them were changed by the user; the other prices keep their base
+// prices, which means that settings that were read from the host can be
+// written back without compounding the adjustments. The host's lock must be
+// held.
+func (h *Host) updateBasePrices(settings modules.HostInternalSettings) {
+	current, updated := pricesOf(h.settings), pricesOf(settings)
+	for _, name := range hostPriceNames {
+		if !current.price(name).Equals(*updated.price(name)) {
+			*h.basePrices.price(name) = *updated.price(name)
+		}
+	}
+}
+
+// managedApplyPricingRules updates the host's prices if the set of pricing
+// rules that are active has changed.
+func (h *Host) managedApplyPricingRules() {
+	remainingStorage := h.remainingStorage()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	prices := h.effectivePrices(remainingStorage, time.Now())
+	if prices.equals(pricesOf(h.settings)) {
+		return
+	}
+	settings := h.settings
+	prices.apply(&settings)
+	if err := h.updateSettings(settings); err != nil {
+		h.log.Println("Could not save the prices set by the pricing rules:", err)
+		return
+	}
+	h.log.Debugln("Pricing rules updated the host's prices")
+}
+
+// threadedApplyPricingRules periodically applies the host's pricing rules, so
+// that rules depending on the time or the remaining storage take effect.
+func (h *Host) threadedApplyPricingRules(closeChan chan struct{}) {
+	defer close(closeChan)
+	for {
+		h.managedApplyPricingRules()
+		select {
+		case <-h.tg.StopChan():
+			return
+		case <-time.After(pricingRulesInterval):
+		}
+	}
+}
+
+// PricingRules returns the host's pricing rules.
+func (h *Host) PricingRules() []modules.HostPricingRule {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return append([]modules.HostPricingRule(nil), h.pricingRules...)
+}
+
+// SetPricingRules replaces the host's pricing rules. The rules adjust the
+// prices set in the host's settings, and are evaluated every
+// pricingRulesInterval as well as whenever the rules or settings change.
+func (h *Host) SetPricingRules(rules []modules.HostPricingRule) error {
+	for i, rule := range rules {
+		if err := validatePricingRule(rule); err != nil {
+			return fmt.Errorf("invalid pricing rule %v: %v", i, err)
+		}
+	}
+	err := h.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer h.tg.Done()
+
+	remainingStorage := h.remainingStorage()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.pricingRules = append([]modules.HostPricingRule(nil), rules...)
+	settings := h.settings
+	h.effectivePrices(remainingStorage, time.Now()).apply(&settings)
+	return h.updateSettings(settings)
+}
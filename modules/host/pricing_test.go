@@ -0,0 +1,112 @@
+package host
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/types"
+)
+
+// TestPricingRuleActive checks the conditions of pricing rules.
+func TestPricingRuleActive(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2018, 1, 1, hour, 30, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		rule      modules.HostPricingRule
+		remaining float64
+		now       time.Time
+		active    bool
+	}{
+		{modules.HostPricingRule{}, 1, at(12), true},
+		{modules.HostPricingRule{RemainingStorageBelow: 0.2}, 0.1, at(12), true},
+		{modules.HostPricingRule{RemainingStorageBelow: 0.2}, 0.2, at(12), false},
+		{modules.HostPricingRule{StartHour: 8, EndHour: 18}, 1, at(8), true},
+		{modules.HostPricingRule{StartHour: 8, EndHour: 18}, 1, at(18), false},
+		{modules.HostPricingRule{StartHour: 22, EndHour: 6}, 1, at(23), true},
+		{modules.HostPricingRule{StartHour: 22, EndHour: 6}, 1, at(2), true},
+		{modules.HostPricingRule{StartHour: 22, EndHour: 6}, 1, at(12), false},
+		{modules.HostPricingRule{RemainingStorageBelow: 0.2, StartHour: 22, EndHour: 6}, 0.5, at(23), false},
+	}
+	for i, test := range tests {
+		if active := pricingRuleActive(test.rule, test.remaining, test.now); active != test.active {
+			t.Errorf("%v: expected active to be %v, got %v", i, test.active, active)
+		}
+	}
+
+	invalid := []modules.HostPricingRule{
+		{Price: "bandwidth", Multiplier: 1},
+		{Price: modules.HostPriceStorage},
+		{Price: modules.HostPriceStorage, Multiplier: -1},
+		{Price: modules.HostPriceStorage, Multiplier: 1, RemainingStorageBelow: 2},
+		{Price: modules.HostPriceStorage, Multiplier: 1, EndHour: 24},
+	}
+	for i, rule := range invalid {
+		if validatePricingRule(rule) == nil {
+			t.Errorf("%v: invalid rule was accepted", i)
+		}
+	}
+}
+
+// TestPricingRules checks that pricing rules adjust the host's prices, and
+// that settings can be updated while rules are active.
+func TestPricingRules(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	ht, err := blankHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	base := ht.host.InternalSettings().MinStoragePrice
+	rules := []modules.HostPricingRule{
+		{Price: modules.HostPriceStorage, Multiplier: 2},
+		// The host has no storage, so this rule does not apply.
+		{Price: modules.HostPriceStorage, Multiplier: 3, RemainingStorageBelow: 0.2},
+	}
+	if err := ht.host.SetPricingRules(rules); err != nil {
+		t.Fatal(err)
+	}
+	if len(ht.host.PricingRules()) != 2 {
+		t.Fatal("expected 2 pricing rules, got", ht.host.PricingRules())
+	}
+	if price := ht.host.InternalSettings().MinStoragePrice; !price.Equals(base.Mul64(2)) {
+		t.Fatal("storage price was not adjusted:", price)
+	}
+	if price := ht.host.ExternalSettings().StoragePrice; !price.Equals(base.Mul64(2)) {
+		t.Fatal("adjusted storage price is not announced:", price)
+	}
+
+	// Writing back the settings does not compound the adjustment.
+	if err := ht.host.SetInternalSettings(ht.host.InternalSettings()); err != nil {
+		t.Fatal(err)
+	}
+	if price := ht.host.InternalSettings().MinStoragePrice; !price.Equals(base.Mul64(2)) {
+		t.Fatal("adjustment was compounded:", price)
+	}
+
+	// A new price becomes the base price.
+	settings := ht.host.InternalSettings()
+	settings.MinStoragePrice = types.NewCurrency64(100)
+	if err := ht.host.SetInternalSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+	if price := ht.host.InternalSettings().MinStoragePrice; !price.Equals64(200) {
+		t.Fatal("new base price was not adjusted:", price)
+	}
+
+	// Removing the rules restores the base price.
+	if err := ht.host.SetPricingRules(nil); err != nil {
+		t.Fatal(err)
+	}
+	if price := ht.host.InternalSettings().MinStoragePrice; !price.Equals64(100) {
+		t.Fatal("base price was not restored:", price)
+	}
+
+	if err := ht.host.SetPricingRules([]modules.HostPricingRule{{Price: "bandwidth", Multiplier: 1}}); err == nil {
+		t.Fatal("invalid rule was accepted")
+	}
+}
@@ -0,0 +1,160 @@
+package host
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/pachisi456/Sia/build"
+	"github.com/pachisi456/Sia/crypto"
+	"github.com/pachisi456/Sia/modules"
+
+	"github.com/NebulousLabs/bolt"
+)
+
+var (
+	// errSectorQuarantined is returned if a renter requests a sector that was
+	// quarantined by the sector scrubber.
+	errSectorQuarantined = errors.New("sector was quarantined because its data was corrupted on disk")
+)
+
+// scrubRoots returns the Merkle roots of all sectors that the host's storage
+// obligations hold. Sectors that appear in multiple obligations are only
+// returned once.
+func (h *Host) scrubRoots() ([]crypto.Hash, error) {
+	var roots []crypto.Hash
+	seen := make(map[crypto.Hash]struct{})
+	err := h.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketStorageObligations).ForEach(func(_, soBytes []byte) error {
+			var so storageObligation
+			if err := json.Unmarshal(soBytes, &so); err != nil {
+				return build.ExtendErr("unable to unmarshal storage obligation:", err)
+			}
+			for _, root := range so.SectorRoots {
+				if _, exists := seen[root]; !exists {
+					seen[root] = struct{}{}
+					roots = append(roots, root)
+				}
+			}
+			return nil
+		})
+	})
+	return roots, err
+}
+
+// managedScrubSector reads a sector and verifies its Merkle root. A sector
+// whose data does not match its root is quarantined by deleting it from the
+// storage manager, which allows a renter to upload it again. Sectors that
+// cannot be read are only counted, as the storage folder may just be
+// temporarily unavailable.
+func (h *Host) managedScrubSector(root crypto.Hash) {
+	data, err := h.ReadSector(root)
+	corrupt := err == nil && crypto.MerkleRoot(data) != root
+	if corrupt {
+		h.log.Printf("WARN: sector %v is corrupted on disk and will be quarantined", root)
+		if err := h.DeleteSector(root); err != nil {
+			h.log.Println("Could not delete corrupted sector:", err)
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, quarantined := h.quarantinedSectors[root]; err != nil && quarantined {
+		// The sector was deleted when it was quarantined.
+		return
+	}
+	h.scrubMetrics.ScrubbedSectors++
+	if err != nil {
+		h.log.Debugln("Sector scrubber could not read sector:", err)
+		h.scrubMetrics.UnreadableSectors++
+		return
+	}
+	if corrupt {
+		h.scrubMetrics.CorruptSectors++
+		h.quarantinedSectors[root] = struct{}{}
+		return
+	}
+	// A quarantined sector that is intact again has been uploaded again.
+	delete(h.quarantinedSectors, root)
+}
+
+// managedScrubSectors performs a single pass of the sector scrubber over all
+// of the sectors held by the host's storage obligations. The pass is
+// interrupted if the host is stopped.
+func (h *Host) managedScrubSectors() {
+	h.mu.Lock()
+	h.scrubMetrics.LastScrubStarted = time.Now()
+	h.mu.Unlock()
+
+	roots, err := h.scrubRoots()
+	if err != nil {
+		h.log.Println("Sector scrubber could not load the sector roots:", err)
+		return
+	}
+	for _, root := range roots {
+		select {
+		case <-h.tg.StopChan():
+			return
+		default:
+		}
+		h.managedScrubSector(root)
+		if scrubSectorSleep > 0 {
+			select {
+			case <-h.tg.StopChan():
+				return
+			case <-time.After(scrubSectorSleep):
+			}
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.scrubMetrics.LastScrubCompleted = time.Now()
+	err = h.saveSync()
+	if err != nil {
+		h.log.Println("Could not save the results of the sector scrubber:", err)
+	}
+}
+
+// threadedScrubSectors periodically reads all of the host's sectors and
+// verifies their Merkle roots, so that silent disk corruption is detected
+// before a renter fails to download a sector or the host fails a storage
+// proof.
+func (h *Host) threadedScrubSectors(closeChan chan struct{}) {
+	defer close(closeChan)
+	for {
+		select {
+		case <-h.tg.StopChan():
+			return
+		case <-time.After(scrubInterval):
+		}
+		h.managedScrubSectors()
+	}
+}
+
+// managedSectorQuarantined returns whether the sector with the provided root
+// was quarantined by the sector scrubber.
+func (h *Host) managedSectorQuarantined(root crypto.Hash) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	_, exists := h.quarantinedSectors[root]
+	return exists
+}
+
+// scrubReport returns the results of the sector scrubber, including the
+// quarantined sectors. The host's lock must be held.
+func (h *Host) scrubReport() modules.HostScrubMetrics {
+	sm := h.scrubMetrics
+	sm.QuarantinedSectors = nil
+	for root := range h.quarantinedSectors {
+		sm.QuarantinedSectors = append(sm.QuarantinedSectors, root)
+	}
+	return sm
+}
+
+// ScrubMetrics returns the results of the host's sector scrubber.
+func (h *Host) ScrubMetrics() modules.HostScrubMetrics {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.scrubReport()
+}
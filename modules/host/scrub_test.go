@@ -0,0 +1,84 @@
+package host
+
+import (
+	"testing"
+
+	"github.com/pachisi456/Sia/crypto"
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/types"
+
+	"github.com/NebulousLabs/bolt"
+	"github.com/NebulousLabs/fastrand"
+)
+
+// TestScrubSectors checks that the sector scrubber quarantines corrupted
+// sectors, and releases them once they have been uploaded again.
+func TestScrubSectors(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	ht, err := newHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ht.Close()
+
+	// Store an intact sector and a sector whose data does not match its root.
+	goodData := fastrand.Bytes(int(modules.SectorSize))
+	goodRoot := crypto.MerkleRoot(goodData)
+	badData := fastrand.Bytes(int(modules.SectorSize))
+	var badRoot crypto.Hash
+	fastrand.Read(badRoot[:])
+	if err := ht.host.AddSector(goodRoot, goodData); err != nil {
+		t.Fatal(err)
+	}
+	if err := ht.host.AddSector(badRoot, badData); err != nil {
+		t.Fatal(err)
+	}
+	so := storageObligation{
+		SectorRoots: []crypto.Hash{goodRoot, badRoot},
+		OriginTransactionSet: []types.Transaction{{
+			FileContracts: []types.FileContract{{}},
+		}},
+	}
+	err = ht.host.db.Update(func(tx *bolt.Tx) error {
+		return putStorageObligation(tx, so)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ht.host.managedScrubSectors()
+	sm := ht.host.ScrubMetrics()
+	if sm.ScrubbedSectors != 2 || sm.CorruptSectors != 1 || sm.UnreadableSectors != 0 {
+		t.Fatal("wrong scrub metrics:", sm)
+	}
+	if sm.LastScrubCompleted.Before(sm.LastScrubStarted) {
+		t.Fatal("scrub was not completed")
+	}
+	if len(sm.QuarantinedSectors) != 1 || sm.QuarantinedSectors[0] != badRoot {
+		t.Fatal("corrupted sector was not quarantined:", sm.QuarantinedSectors)
+	}
+	if _, err := ht.host.ReadSector(badRoot); err == nil {
+		t.Fatal("quarantined sector can still be read")
+	}
+	if _, err := ht.host.ReadSector(goodRoot); err != nil {
+		t.Fatal(err)
+	}
+
+	// Scrubbing again does not count the quarantined sector.
+	ht.host.managedScrubSectors()
+	if sm := ht.host.ScrubMetrics(); sm.ScrubbedSectors != 3 || sm.UnreadableSectors != 0 {
+		t.Fatal("quarantined sector was scrubbed again:", sm)
+	}
+
+	// A quarantined sector that has been uploaded again is released from
+	// quarantine once it has been verified.
+	ht.host.mu.Lock()
+	ht.host.quarantinedSectors[goodRoot] = struct{}{}
+	ht.host.mu.Unlock()
+	ht.host.managedScrubSectors()
+	if !ht.host.managedSectorQuarantined(badRoot) || ht.host.managedSectorQuarantined(goodRoot) {
+		t.Fatal("intact sector was not released from quarantine:", ht.host.ScrubMetrics())
+	}
+}
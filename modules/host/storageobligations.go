@@ -127,6 +127,11 @@ type storageObligation struct {
 	RiskedCollateral         types.Currency
 	TransactionFeesAdded     types.Currency
 
+	// The amount of data that the renter uploaded to and downloaded from the
+	// host through the file contract, in bytes.
+	UploadBytes   uint64
+	DownloadBytes uint64
+
 	// The negotiation height specifies the block height at which the file
 	// contract was negotiated. If the origin transaction set is not accepted
 	// onto the blockchain quickly enough, the contract is pruned from the
@@ -193,6 +198,20 @@ func (so storageObligation) id() types.FileContractID {
 	return so.OriginTransactionSet[len(so.OriginTransactionSet)-1].FileContractID(0)
 }
 
+// renterPublicKey returns the public key that the renter uses to sign the
+// revisions of the storage obligation. An empty key is returned if the
+// obligation has no revision.
+func (so storageObligation) renterPublicKey() types.SiaPublicKey {
+	if len(so.RevisionTransactionSet) == 0 {
+		return types.SiaPublicKey{}
+	}
+	uc := so.RevisionTransactionSet[len(so.RevisionTransactionSet)-1].FileContractRevisions[0].UnlockConditions
+	if len(uc.PublicKeys) == 0 {
+		return types.SiaPublicKey{}
+	}
+	return uc.PublicKeys[0]
+}
+
 // isSane checks that required assumptions about the storage obligation are
 // correct.
 func (so storageObligation) isSane() error {
@@ -1,8 +1,10 @@
 package modules
 
 import (
+	"context"
 	"encoding/json"
 	"io"
+	"os"
 	"time"
 
 	"github.com/pachisi456/Sia/build"
@@ -39,22 +41,95 @@ type ErasureCoder interface {
 
 // An Allowance dictates how much the Renter is allowed to spend in a given
 // period. Note that funds are spent on both storage and bandwidth.
+//
+// Contracts are renewed once the current height is within RenewWindow blocks
+// of their end height. Contracts are also renewed early if the ratio of their
+// remaining funds to their total cost drops below RenewFundsThreshold. A
+// threshold of zero means that the contractor's default threshold is used.
 type Allowance struct {
 	Funds       types.Currency    `json:"funds"`
 	Hosts       uint64            `json:"hosts"`
 	Period      types.BlockHeight `json:"period"`
 	RenewWindow types.BlockHeight `json:"renewwindow"`
+
+	RenewFundsThreshold float64 `json:"renewfundsthreshold"`
+}
+
+// An AllowanceEstimate is the projected outcome of forming contracts for an
+// allowance against the current hostdb. Contracts is the number of contracts
+// that could be formed, each funded with FundsPerContract. The fees are totals
+// across all contracts, and StorageFunds is what remains for uploading and
+// storing data. Storage is the number of bytes that can be stored across all
+// contracts for the allowance period, before redundancy.
+type AllowanceEstimate struct {
+	Contracts        uint64         `json:"contracts"`
+	FundsPerContract types.Currency `json:"fundspercontract"`
+	ContractFees     types.Currency `json:"contractfees"`
+	TransactionFees  types.Currency `json:"transactionfees"`
+	SiafundFees      types.Currency `json:"siafundfees"`
+	StorageFunds     types.Currency `json:"storagefunds"`
+	Storage          uint64         `json:"storage"`
 }
 
 // DownloadInfo provides information about a file that has been requested for
 // download.
 type DownloadInfo struct {
-	SiaPath     string         `json:"siapath"`
-	Destination DownloadWriter `json:"destination"`
-	Filesize    uint64         `json:"filesize"`
-	Received    uint64         `json:"received"`
-	StartTime   time.Time      `json:"starttime"`
-	Error       string         `json:"error"`
+	SiaPath     string                `json:"siapath"`
+	Destination DownloadWriter        `json:"destination"`
+	Filesize    uint64                `json:"filesize"`
+	Received    uint64                `json:"received"`
+	StartTime   time.Time             `json:"starttime"`
+	Priority    DownloadPriority      `json:"priority"`
+	Error       string                `json:"error"`
+	Chunks      []DownloadChunkResult `json:"chunks"`
+}
+
+// DownloadChunkResult describes how a single chunk of a download was fetched.
+// Hosts lists the hosts that provided the pieces used to recover the chunk,
+// and FailedHosts lists the hosts whose pieces could not be fetched, causing
+// the download to fail over to another host. Bytes is the amount of piece data
+// fetched from the hosts. Verified is set once the pieces have been decrypted
+// and authenticated, and the chunk has been recovered. Cached is set if the
+// chunk was served from the renter's chunk cache without contacting any hosts.
+// Overdrive is the number of pieces that were requested beyond the minimum
+// needed to recover the chunk, and Stragglers is the number of requested
+// pieces that were still being fetched when the chunk was recovered, and were
+// cancelled.
+type DownloadChunkResult struct {
+	Index       uint64               `json:"index"`
+	Hosts       []types.SiaPublicKey `json:"hosts"`
+	FailedHosts []types.SiaPublicKey `json:"failedhosts"`
+	Bytes       uint64               `json:"bytes"`
+	Duration    time.Duration        `json:"duration"`
+	Verified    bool                 `json:"verified"`
+	Cached      bool                 `json:"cached"`
+	Overdrive   uint64               `json:"overdrive"`
+	Stragglers  uint64               `json:"stragglers"`
+}
+
+// DownloadResult contains the outcome of a download, broken down by chunk.
+type DownloadResult struct {
+	SiaPath  string                `json:"siapath"`
+	Duration time.Duration         `json:"duration"`
+	Chunks   []DownloadChunkResult `json:"chunks"`
+}
+
+// HostVerifyResult counts the pieces of a file that a single host provided
+// or failed to provide during verification.
+type HostVerifyResult struct {
+	HostPublicKey types.SiaPublicKey `json:"hostpublickey"`
+	PiecesFetched uint64             `json:"piecesfetched"`
+	PiecesFailed  uint64             `json:"piecesfailed"`
+}
+
+// VerifyReport contains the outcome of verifying that a file can be
+// downloaded. Verified is only set if every piece of every chunk was fetched
+// and authenticated.
+type VerifyReport struct {
+	SiaPath  string                `json:"siapath"`
+	Verified bool                  `json:"verified"`
+	Chunks   []DownloadChunkResult `json:"chunks"`
+	Hosts    []HostVerifyResult    `json:"hosts"`
 }
 
 // DownloadWriter provides an interface which all output writers have to implement.
@@ -64,12 +139,57 @@ type DownloadWriter interface {
 	Close() error
 }
 
+// UploadCollisionPolicy determines what happens when a file is uploaded to a
+// siapath that is already in use.
+type UploadCollisionPolicy string
+
+const (
+	// CollisionError rejects the upload. This is the default policy.
+	CollisionError UploadCollisionPolicy = "error"
+
+	// CollisionOverwrite deletes the existing file and uploads the new file
+	// in its place.
+	CollisionOverwrite UploadCollisionPolicy = "overwrite"
+
+	// CollisionVersion uploads the new file to the siapath with the first
+	// free numeric suffix appended, e.g. "foo_1", keeping the existing file.
+	CollisionVersion UploadCollisionPolicy = "version"
+)
+
+// DownloadVerification determines how many of the sectors fetched by a
+// download are checked against their Merkle roots.
+type DownloadVerification string
+
+const (
+	// VerifyFull checks every sector. This is the default level.
+	VerifyFull DownloadVerification = "full"
+
+	// VerifySampled checks a random subset of the sectors, which catches a
+	// host that corrupts data systematically while saving most of the work.
+	VerifySampled DownloadVerification = "sampled"
+
+	// VerifyNone does not check any sectors.
+	VerifyNone DownloadVerification = "none"
+)
+
 // FileUploadParams contains the information used by the Renter to upload a
 // file.
 type FileUploadParams struct {
 	Source      string
 	SiaPath     string
 	ErasureCode ErasureCoder
+
+	// CollisionPolicy determines what happens if a file already exists at
+	// SiaPath. An empty policy is the same as CollisionError.
+	CollisionPolicy UploadCollisionPolicy
+
+	// Force uploads the file even if it would exceed the storage caps set by
+	// RenterSettings.MaxStoredBytes and RenterSettings.MaxStoredRedundantBytes.
+	Force bool
+
+	// Tags are arbitrary key/value pairs that are stored with the file and
+	// returned in its FileInfo.
+	Tags map[string]string
 }
 
 // FileInfo provides information about a file.
@@ -82,6 +202,367 @@ type FileInfo struct {
 	Redundancy     float64           `json:"redundancy"`
 	UploadProgress float64           `json:"uploadprogress"`
 	Expiration     types.BlockHeight `json:"expiration"`
+
+	// Failed is set once the renter has given up on repairing the file, see
+	// RenterSettings.MaxRepairAttempts and RenterSettings.MaxRepairTime.
+	Failed bool `json:"failed"`
+
+	// Paused is set while the upload of the file is paused, see
+	// Renter.PauseUpload.
+	Paused bool `json:"paused"`
+
+	// StuckChunks is the number of chunks of the file that repeatedly failed
+	// to reach full redundancy and are only retried by the stuck loop, see
+	// Renter.RetryStuckChunks.
+	StuckChunks uint64 `json:"stuckchunks"`
+
+	// ModTime and Mode are the modification time and the mode of the source
+	// file at the time it was uploaded. ModTime is the zero time for files
+	// that were uploaded before it was recorded. Tags are the tags supplied
+	// when the file was uploaded.
+	ModTime time.Time         `json:"modtime"`
+	Mode    os.FileMode       `json:"mode"`
+	Tags    map[string]string `json:"tags"`
+}
+
+// TrackedFileInfo reports whether a file is tracked by the renter. Tracked
+// files are actively repaired by the renter.
+type TrackedFileInfo struct {
+	SiaPath string `json:"siapath"`
+	Tracked bool   `json:"tracked"`
+}
+
+// DirectoryInfo provides information about a directory of the renter. The
+// numbers cover every file below the directory, including the files of its
+// subdirectories.
+type DirectoryInfo struct {
+	SiaPath  string `json:"siapath"`
+	NumFiles uint64 `json:"numfiles"`
+	Filesize uint64 `json:"filesize"`
+
+	// Health is the redundancy of the least redundant file below the
+	// directory, or -1 if the directory contains no data.
+	Health float64 `json:"health"`
+}
+
+// WorkerStatus contains the state of a single worker of the renter's worker
+// pool. The queue depths count the chunks and sectors that are waiting for
+// the worker, and the throughputs are measured in bytes per second over the
+// recent past. A worker that is on cooldown is not given any work of that
+// kind until the cooldown has passed.
+type WorkerStatus struct {
+	HostPublicKey types.SiaPublicKey `json:"hostpublickey"`
+
+	UploadQueueDepth          int           `json:"uploadqueuedepth"`
+	UploadThroughput          uint64        `json:"uploadthroughput"`
+	UploadConsecutiveFailures int           `json:"uploadconsecutivefailures"`
+	UploadCooldownRemaining   time.Duration `json:"uploadcooldownremaining"`
+
+	DownloadQueueDepth        int           `json:"downloadqueuedepth"`
+	DownloadThroughput        uint64        `json:"downloadthroughput"`
+	DownloadCooldownRemaining time.Duration `json:"downloadcooldownremaining"`
+}
+
+// ContractDiagnostics collects the state of a single contract and its host
+// for troubleshooting. The upload and download counts include every attempt
+// made by the renter's workers since the renter was started, and the success
+// rates are zero if no attempts were made. BlocksToExpiry is zero once the
+// contract has expired.
+type ContractDiagnostics struct {
+	ID             types.FileContractID `json:"id"`
+	HostPublicKey  types.SiaPublicKey   `json:"hostpublickey"`
+	NetAddress     NetAddress           `json:"netaddress"`
+	RenterFunds    types.Currency       `json:"renterfunds"`
+	RevisionNumber uint64               `json:"revisionnumber"`
+	StoredBytes    uint64               `json:"storedbytes"`
+
+	Uploads             uint64  `json:"uploads"`
+	UploadFailures      uint64  `json:"uploadfailures"`
+	UploadSuccessRate   float64 `json:"uploadsuccessrate"`
+	Downloads           uint64  `json:"downloads"`
+	DownloadFailures    uint64  `json:"downloadfailures"`
+	DownloadSuccessRate float64 `json:"downloadsuccessrate"`
+
+	LastScan        time.Time     `json:"lastscan"`
+	LastScanSuccess bool          `json:"lastscansuccess"`
+	LastScanLatency time.Duration `json:"lastscanlatency"`
+	Offline         bool          `json:"offline"`
+
+	BlocksToExpiry types.BlockHeight `json:"blockstoexpiry"`
+}
+
+// FileRedundancy compares the redundancy that a file's erasure code aims for
+// with the redundancy it currently has on online hosts. Redundancy is -1 for
+// empty files.
+type FileRedundancy struct {
+	SiaPath          string  `json:"siapath"`
+	TargetRedundancy float64 `json:"targetredundancy"`
+	Redundancy       float64 `json:"redundancy"`
+}
+
+// RenterHealth summarizes the redundancy of the renter's files. Every file is
+// counted as either fully redundant, degraded if it is below its target
+// redundancy but can still be recovered, or unrecoverable. StuckChunks is the
+// number of chunks that are stuck, see FileInfo.StuckChunks, plus the
+// incomplete chunks of files that the renter gave up on repairing, and
+// OldestRepairAge is how long the file that has been waiting longest for
+// repair has been incomplete.
+type RenterHealth struct {
+	FullRedundancyFiles uint64        `json:"fullredundancyfiles"`
+	DegradedFiles       uint64        `json:"degradedfiles"`
+	UnrecoverableFiles  uint64        `json:"unrecoverablefiles"`
+	StuckChunks         uint64        `json:"stuckchunks"`
+	OldestRepairAge     time.Duration `json:"oldestrepairage"`
+}
+
+// RepairStats describes the activity of the renter's repair loop. Passes is
+// the number of completed repair passes, and the remaining fields describe the
+// most recent pass: how many chunks it enqueued for repair, how long it took
+// to work through them, and how long ago it finished.
+type RepairStats struct {
+	Passes            uint64        `json:"passes"`
+	LastPassChunks    uint64        `json:"lastpasschunks"`
+	LastPassDuration  time.Duration `json:"lastpassduration"`
+	TimeSinceLastPass time.Duration `json:"timesincelastpass"`
+}
+
+// DedupStats counts how often a worker found that its host already stores a
+// sector identical to the piece it was about to upload. Hits are pieces whose
+// upload was skipped, Misses are pieces that were uploaded, and BytesSaved is
+// the amount of upload traffic avoided by the hits.
+type DedupStats struct {
+	Hits       uint64 `json:"hits"`
+	Misses     uint64 `json:"misses"`
+	BytesSaved uint64 `json:"bytessaved"`
+}
+
+// ChunkUploadMetrics contains the time that the upload of a single chunk spent
+// in each stage of the upload pipeline. ErasureCodingTime and EncryptionTime
+// cover the processing of the chunk's data, while NegotiationTime (acquiring
+// an editing connection to a host) and TransferTime (sending a piece and
+// revising the contract) are summed over all pieces that were sent to hosts.
+type ChunkUploadMetrics struct {
+	SiaPath        string    `json:"siapath"`
+	ChunkIndex     uint64    `json:"chunkindex"`
+	Repair         bool      `json:"repair"`
+	PiecesUploaded int       `json:"piecesuploaded"`
+	Completed      time.Time `json:"completed"`
+
+	ErasureCodingTime time.Duration `json:"erasurecodingtime"`
+	EncryptionTime    time.Duration `json:"encryptiontime"`
+	NegotiationTime   time.Duration `json:"negotiationtime"`
+	TransferTime      time.Duration `json:"transfertime"`
+}
+
+// RenterMemoryBreakdown reports how the renter's memory is being used. All
+// values are in bytes.
+type RenterMemoryBreakdown struct {
+	// Total is the amount of memory that the renter is allowed to consume,
+	// and Available is the amount that has not been allocated yet.
+	Total     uint64 `json:"total"`
+	Available uint64 `json:"available"`
+
+	// Encoding is memory held by chunks that are being read from disk,
+	// erasure coded, and encrypted. UploadSectors is memory held by encrypted
+	// pieces that are waiting to be uploaded to hosts. DownloadSectors is
+	// memory held by chunks that are being downloaded from hosts.
+	Encoding        uint64 `json:"encoding"`
+	UploadSectors   uint64 `json:"uploadsectors"`
+	DownloadSectors uint64 `json:"downloadsectors"`
+
+	// ChunkCache is memory held by the chunk cache. It is given up whenever
+	// the memory is needed for anything else.
+	ChunkCache uint64 `json:"chunkcache"`
+
+	// UserUploads, UserDownloads and Repairs break the memory in use down by
+	// the priority class it was allocated for. Each class has a reserved
+	// minimum that the other classes can't allocate. The chunk cache counts
+	// towards UserDownloads.
+	UserUploads   MemoryClassUsage `json:"useruploads"`
+	UserDownloads MemoryClassUsage `json:"userdownloads"`
+	Repairs       MemoryClassUsage `json:"repairs"`
+}
+
+// MemoryClassUsage reports the memory in use by a priority class of the
+// renter's memory, and the amount of memory reserved for the class.
+type MemoryClassUsage struct {
+	InUse    uint64 `json:"inuse"`
+	Reserved uint64 `json:"reserved"`
+}
+
+// FormationFailureReason categorizes why a contract could not be formed with a
+// host.
+type FormationFailureReason string
+
+const (
+	// FormationFailureTooExpensive means that the host's prices exceed the
+	// renter's maximums.
+	FormationFailureTooExpensive FormationFailureReason = "too expensive"
+
+	// FormationFailureOffline means that the host could not be reached.
+	FormationFailureOffline FormationFailureReason = "offline"
+
+	// FormationFailureInsufficientCapacity means that the host is not
+	// accepting new contracts, typically because it is full.
+	FormationFailureInsufficientCapacity FormationFailureReason = "insufficient capacity"
+
+	// FormationFailureHandshake means that the host was reached, but the
+	// contract negotiation failed.
+	FormationFailureHandshake FormationFailureReason = "handshake failed"
+
+	// FormationFailureOther means that the contract could not be formed for
+	// a reason unrelated to the host, such as the renter's wallet being
+	// unable to fund the contract.
+	FormationFailureOther FormationFailureReason = "other"
+)
+
+// FormationFailure describes the most recent failure to form a contract with
+// a host.
+type FormationFailure struct {
+	HostPublicKey types.SiaPublicKey     `json:"hostpublickey"`
+	NetAddress    NetAddress             `json:"netaddress"`
+	Reason        FormationFailureReason `json:"reason"`
+	Error         string                 `json:"error"`
+	Time          time.Time              `json:"time"`
+}
+
+// ContractStatus describes whether the transaction that formed a contract
+// has confirmed on the blockchain.
+type ContractStatus string
+
+const (
+	// ContractStatusUnconfirmed means that the formation transaction has
+	// been broadcast, but has not confirmed yet.
+	ContractStatusUnconfirmed ContractStatus = "unconfirmed"
+
+	// ContractStatusConfirmed means that the formation transaction has
+	// confirmed.
+	ContractStatusConfirmed ContractStatus = "confirmed"
+
+	// ContractStatusReorged means that the block containing the formation
+	// transaction was reverted. The contract can be used again once the
+	// transaction confirms again.
+	ContractStatusReorged ContractStatus = "reorged"
+
+	// ContractStatusDoubleSpent means that another transaction spent the
+	// outputs funding the contract, so the contract will never confirm.
+	ContractStatusDoubleSpent ContractStatus = "double-spent"
+)
+
+// Usable reports whether data may be uploaded to and downloaded from a
+// contract with the status. Contracts whose formation transaction is not part
+// of the blockchain are not usable, since the host may not honor them.
+func (s ContractStatus) Usable() bool {
+	return s != ContractStatusReorged && s != ContractStatusDoubleSpent
+}
+
+// A ContractAlert warns that the transaction forming a contract is in danger
+// of never confirming on the blockchain. Until the transaction confirms, the
+// host is not paid and may delete the renter's data.
+type ContractAlert struct {
+	ContractID    types.FileContractID `json:"contractid"`
+	HostPublicKey types.SiaPublicKey   `json:"hostpublickey"`
+	NetAddress    NetAddress           `json:"netaddress"`
+
+	// BroadcastHeight is the height at which the transaction was first
+	// broadcast, and Broadcasts is the number of times it was broadcast
+	// since.
+	BroadcastHeight types.BlockHeight `json:"broadcastheight"`
+	Broadcasts      uint64            `json:"broadcasts"`
+
+	Msg string `json:"msg"`
+}
+
+// HostFilterMode determines which hosts the renter may form contracts with.
+type HostFilterMode string
+
+const (
+	// HostFilterModeDisable allows contracts with any host.
+	HostFilterModeDisable HostFilterMode = "disable"
+
+	// HostFilterModeBlacklist forbids contracts with the filtered hosts.
+	HostFilterModeBlacklist HostFilterMode = "blacklist"
+
+	// HostFilterModeWhitelist only allows contracts with the filtered hosts.
+	HostFilterModeWhitelist HostFilterMode = "whitelist"
+)
+
+// UploadFailure describes a file that the renter stopped repairing because it
+// could not be brought to full redundancy.
+type UploadFailure struct {
+	SiaPath        string    `json:"siapath"`
+	RepairAttempts uint64    `json:"repairattempts"`
+	Time           time.Time `json:"time"`
+}
+
+// UploadProgress describes the progress of a file's upload at the moment that
+// one of its chunks finished uploading. PiecesStored counts the pieces of that
+// chunk that are stored on hosts, while BytesUploaded, UploadProgress and
+// Redundancy describe the whole file, in the same way as FileInfo.
+type UploadProgress struct {
+	SiaPath        string    `json:"siapath"`
+	ChunkIndex     uint64    `json:"chunkindex"`
+	PiecesStored   int       `json:"piecesstored"`
+	BytesUploaded  uint64    `json:"bytesuploaded"`
+	UploadProgress float64   `json:"uploadprogress"`
+	Redundancy     float64   `json:"redundancy"`
+	Time           time.Time `json:"time"`
+}
+
+// ContractExpiry describes how soon a contract expires, as of the most recent
+// block. ExpiryWarning is set once the contract has entered the allowance's
+// renew window and should be renewed shortly.
+type ContractExpiry struct {
+	BlocksRemaining types.BlockHeight `json:"blocksremaining"`
+	ExpiryWarning   bool              `json:"expirywarning"`
+}
+
+// HostBenchmark contains the results of benchmarking the raw bandwidth of a
+// single host. Latency is the time taken to open a connection to the host,
+// and the throughputs are measured in bytes per second.
+type HostBenchmark struct {
+	Bytes              uint64        `json:"bytes"`
+	UploadThroughput   uint64        `json:"uploadthroughput"`
+	DownloadThroughput uint64        `json:"downloadthroughput"`
+	Latency            time.Duration `json:"latency"`
+}
+
+// UploadBenchmark contains the results of uploading random data through the
+// renter's full upload pipeline. Time covers reading, erasure coding,
+// encrypting and uploading all of the data, and Throughput is the number of
+// bytes of data uploaded per second. Hosts breaks the upload down by host.
+type UploadBenchmark struct {
+	Bytes      uint64                `json:"bytes"`
+	Time       time.Duration         `json:"time"`
+	Throughput uint64                `json:"throughput"`
+	Hosts      []HostUploadBenchmark `json:"hosts"`
+}
+
+// HostUploadBenchmark contains the part of an UploadBenchmark that was
+// uploaded to a single host. Latency is the average time taken to acquire an
+// editing connection to the host, and Throughput is the number of bytes per
+// second that were transferred once the connection was established.
+type HostUploadBenchmark struct {
+	HostPublicKey types.SiaPublicKey `json:"hostpublickey"`
+	Pieces        uint64             `json:"pieces"`
+	Latency       time.Duration      `json:"latency"`
+	Throughput    uint64             `json:"throughput"`
+}
+
+// HostRoundTrip contains the results of measuring how long a single host
+// takes to store data and return it. RoundTripTime covers uploading every
+// probe sector and immediately downloading and verifying it again, and
+// Throughput is measured in bytes per second over the whole round trip.
+type HostRoundTrip struct {
+	Bytes         uint64        `json:"bytes"`
+	RoundTripTime time.Duration `json:"roundtriptime"`
+	Throughput    uint64        `json:"throughput"`
+}
+
+// RenterSelfTestReport contains the timing of a successful renter self test.
+type RenterSelfTestReport struct {
+	UploadTime   time.Duration `json:"uploadtime"`
+	DownloadTime time.Duration `json:"downloadtime"`
 }
 
 // A HostDBEntry represents one host entry in the Renter's host DB. It
@@ -99,6 +580,14 @@ type HostDBEntry struct {
 	HistoricUptime   time.Duration `json:"historicuptime"`
 	ScanHistory      HostDBScans   `json:"scanhistory"`
 
+	// LastScanLatency is the time it took to connect to the host during the
+	// most recent scan. It is zero if the scan failed.
+	LastScanLatency time.Duration `json:"lastscanlatency"`
+
+	// Subnet is the /24 (IPv4) or /64 (IPv6) subnet of the IP address that
+	// the host was reached at during the most recent successful scan.
+	Subnet string `json:"subnet"`
+
 	HistoricFailedInteractions     float64 `json:"historicfailedinteractions"`
 	HistoricSuccessfulInteractions float64 `json:"historicsuccessfulinteractions"`
 	RecentFailedInteractions       float64 `json:"recentfailedinteractions"`
@@ -117,6 +606,36 @@ type HostDBScan struct {
 	Success   bool      `json:"success"`
 }
 
+// HostDBQuery selects hosts from the hostdb. A host has to match every
+// criterion that is set; criteria with their zero value match all hosts.
+type HostDBQuery struct {
+	AcceptingContracts  bool           `json:"acceptingcontracts"`
+	MaxStoragePrice     types.Currency `json:"maxstorageprice"`
+	MinRemainingStorage uint64         `json:"minremainingstorage"`
+	MinVersion          string         `json:"minversion"`
+
+	// Limit is the maximum number of hosts returned. Zero means no limit.
+	Limit int `json:"limit"`
+}
+
+// HostDBScanStatus describes the state of the hostdb's scan queue.
+type HostDBScanStatus struct {
+	// Queued is the number of hosts waiting to be scanned, and Scanning is the
+	// number of threads that are currently scanning hosts.
+	Queued   int `json:"queued"`
+	Scanning int `json:"scanning"`
+
+	// BurstScan is set while the scan of all hosts that is performed at
+	// startup is in progress.
+	BurstScan bool `json:"burstscan"`
+
+	// PriorityHosts is the number of hosts the renter has contracts with,
+	// which are scanned more often. BackedOffHosts is the number of hosts
+	// that failed several scans in a row and are scanned less often.
+	PriorityHosts  int `json:"priorityhosts"`
+	BackedOffHosts int `json:"backedoffhosts"`
+}
+
 // HostScoreBreakdown provides a piece-by-piece explanation of why a host has
 // the score that they do.
 //
@@ -133,6 +652,7 @@ type HostScoreBreakdown struct {
 	CollateralAdjustment       float64 `json:"collateraladjustment"`
 	InteractionAdjustment      float64 `json:"interactionadjustment"`
 	PriceAdjustment            float64 `json:"pricesmultiplier"`
+	ScanSuccessAdjustment      float64 `json:"scansuccessadjustment"`
 	StorageRemainingAdjustment float64 `json:"storageremainingadjustment"`
 	UptimeAdjustment           float64 `json:"uptimeadjustment"`
 	VersionAdjustment          float64 `json:"versionadjustment"`
@@ -147,17 +667,166 @@ type RenterPriceEstimation struct {
 	// The cost of forming a set of contracts using the defaults.
 	FormContracts types.Currency `json:"formcontracts"`
 
-	// The cost of storing 1 TB for a month, including redundancy.
+	// The cost of storing 1 TB for a month, including the redundancy of the
+	// renter's files.
 	StorageTerabyteMonth types.Currency `json:"storageterabytemonth"`
 
 	// The cost of consuming 1 TB of upload bandwidth from the host, including
-	// redundancy.
+	// the redundancy of the renter's files.
 	UploadTerabyte types.Currency `json:"uploadterabyte"`
 }
 
+// MonthlyCostProjection is the estimated cost of the renter's current
+// contracts and files over one month. ContractCost is the share of the
+// contract fees that falls on one month, StorageCost is the cost of storing
+// all files at full redundancy, and UploadCost is the cost of uploading the
+// pieces that are still missing.
+type MonthlyCostProjection struct {
+	ContractCost types.Currency `json:"contractcost"`
+	StorageCost  types.Currency `json:"storagecost"`
+	UploadCost   types.Currency `json:"uploadcost"`
+	Total        types.Currency `json:"total"`
+}
+
+// DownloadCostEstimate is the estimated cost of downloading an entire file.
+// UncostableChunks is the number of chunks that do not have enough pieces on
+// online hosts with a known price. These chunks are not included in Cost, so
+// a nonzero value means that the actual cost may be higher, or that the file
+// may not be downloadable at all.
+type DownloadCostEstimate struct {
+	Cost             types.Currency `json:"cost"`
+	UncostableChunks uint64         `json:"uncostablechunks"`
+}
+
+// FileSpending attributes the spending of the renter's contracts to a single
+// file. Each contract's storage and upload spending and fees are split in
+// proportion to the number of its sectors that hold pieces of the file.
+// MonthlyCost is what storing the file's pieces costs per month at the
+// current storage prices of their hosts.
+type FileSpending struct {
+	StorageSpending types.Currency `json:"storagespending"`
+	UploadSpending  types.Currency `json:"uploadspending"`
+	FeeSpending     types.Currency `json:"feespending"`
+	Total           types.Currency `json:"total"`
+	MonthlyCost     types.Currency `json:"monthlycost"`
+}
+
 // RenterSettings control the behavior of the Renter.
 type RenterSettings struct {
 	Allowance Allowance `json:"allowance"`
+
+	// MaxDownloadSpeed is the maximum combined bandwidth, in bytes per
+	// second, of all downloads. A value of zero means that downloads are not
+	// limited. If DownloadFairness is set, each active download receives an
+	// equal share of MaxDownloadSpeed, and the share of a download that is
+	// not transferring data is redistributed among the other downloads.
+	MaxDownloadSpeed uint64 `json:"maxdownloadspeed"`
+	DownloadFairness bool   `json:"downloadfairness"`
+
+	// MaxUploadSpeed is the maximum combined bandwidth, in bytes per second,
+	// of all uploads, including repairs. A value of zero means that uploads
+	// are not limited.
+	MaxUploadSpeed uint64 `json:"maxuploadspeed"`
+
+	// DownloadCacheSize is the amount of memory, in bytes, that the renter
+	// uses to cache recently downloaded chunks. A value of zero disables the
+	// cache. Downloads of cached chunks are served without contacting any
+	// hosts.
+	//
+	// DownloadCacheDiskSize is the amount of disk space, in bytes, that holds
+	// chunks evicted from the memory cache. A value of zero disables the disk
+	// tier. The disk tier stores decrypted file data in the renter's persist
+	// directory, and is cleared when the renter starts.
+	DownloadCacheSize     uint64 `json:"downloadcachesize"`
+	DownloadCacheDiskSize uint64 `json:"downloadcachedisksize"`
+
+	// DownloadOverdrive is the number of pieces that downloads request per
+	// chunk beyond the minimum needed to recover the chunk. A chunk is
+	// recovered as soon as enough pieces have arrived, and the remaining
+	// requests are cancelled, so that slow hosts do not hold up the download.
+	// Overdrive costs extra download bandwidth. A value of zero requests only
+	// the minimum number of pieces.
+	DownloadOverdrive uint64 `json:"downloadoverdrive"`
+
+	// DownloadVerification determines how many of the sectors fetched by
+	// downloads are checked against their Merkle roots. Sectors fetched for
+	// repairs are always checked. An empty value is the same as VerifyFull.
+	DownloadVerification DownloadVerification `json:"downloadverification"`
+
+	// HostGracePeriod is the amount of time, in seconds, over which a host
+	// needs to have been scanned successfully several times before the renter
+	// uploads to it. A value of zero means that new hosts are used right
+	// away.
+	HostGracePeriod uint64 `json:"hostgraceperiod"`
+
+	// HostSubnetDiversity prevents contracts from being formed with hosts
+	// that share a /24 (IPv4) or /64 (IPv6) subnet with the host of another
+	// contract, so that a single operator running many hosts cannot end up
+	// storing a large share of the renter's data.
+	HostSubnetDiversity bool `json:"hostsubnetdiversity"`
+
+	// WorkersPerContract is the number of workers that upload to each host
+	// in parallel. Revisions of a contract are always serialized, regardless
+	// of the number of workers. A value of zero is treated as one.
+	WorkersPerContract uint64 `json:"workerspercontract"`
+
+	// MaxContractRevisions is the maximum number of times a contract may be
+	// revised. Contracts approaching the limit are renewed before further
+	// data is uploaded to them. A value of zero means that there is no
+	// limit.
+	MaxContractRevisions uint64 `json:"maxcontractrevisions"`
+
+	// MaxContractChurn is the maximum number of contracts that may be
+	// dropped in favor of better hosts per period. Once the limit is
+	// reached, contracts are kept and renewed even if their hosts score
+	// poorly, so that their data does not have to be uploaded again. Hosts
+	// that are offline or excluded by the host filter are always dropped. A
+	// value of zero means that there is no limit.
+	MaxContractChurn uint64 `json:"maxcontractchurn"`
+
+	// MaxRepairAttempts and MaxRepairTime limit how long the renter keeps
+	// repairing a file that never reaches full redundancy. Once a file has
+	// been incomplete for MaxRepairAttempts consecutive repair passes, or for
+	// MaxRepairTime seconds, it is marked as failed and no longer repaired. A
+	// value of zero disables the respective limit.
+	MaxRepairAttempts uint64 `json:"maxrepairattempts"`
+	MaxRepairTime     uint64 `json:"maxrepairtime"`
+
+	// MaxStoredBytes caps the combined size of all files known to the
+	// renter. MaxStoredRedundantBytes caps the amount of data that the files
+	// occupy on hosts, including redundancy. Uploads that would exceed either
+	// cap are rejected unless they are forced. A value of zero disables the
+	// respective cap.
+	MaxStoredBytes          uint64 `json:"maxstoredbytes"`
+	MaxStoredRedundantBytes uint64 `json:"maxstoredredundantbytes"`
+
+	// RepairThrottleThreshold is the amount of foreground upload and download
+	// traffic, in bytes per second, at which the renter limits repair traffic
+	// to RepairThrottledBandwidth bytes per second. Repair is allowed more
+	// bandwidth as the foreground traffic drops below the threshold, and is
+	// not limited at all while the foreground is idle. A threshold of zero
+	// disables repair throttling.
+	RepairThrottleThreshold  uint64 `json:"repairthrottlethreshold"`
+	RepairThrottledBandwidth uint64 `json:"repairthrottledbandwidth"`
+
+	// LogDedupWindow is the amount of time, in seconds, for which repeated
+	// identical log messages, such as errors caused by an offline host, are
+	// collapsed into a single entry. A value of zero disables deduplication.
+	LogDedupWindow uint64 `json:"logdedupwindow"`
+
+	// StructuredLogging switches the renter's log to structured mode, in
+	// which every entry is written as a JSON object on a single line. Upload
+	// events carry the fields module, contractID, siapath, chunkIndex and
+	// durationMs, and the upload of every piece is logged as well.
+	StructuredLogging bool `json:"structuredlogging"`
+
+	// MaxMemory is the amount of memory, in bytes, that the renter may use
+	// to hold chunk data while uploading and repairing. It should be large
+	// enough to hold at least one chunk of every file. Lowering it does not
+	// interrupt transfers that are in progress, but no new memory is handed
+	// out until usage has dropped below the new limit. A value of zero means
+	// that the default is used.
+	MaxMemory uint64 `json:"maxmemory"`
 }
 
 // HostDBScans represents a sortable slice of scans.
@@ -249,6 +918,11 @@ type RenterContract struct {
 	GoodForRenew  bool
 	GoodForUpload bool
 
+	// Status indicates whether the transaction that formed the contract is
+	// part of the blockchain. It is set by the contractor whenever the
+	// contract is returned.
+	Status ContractStatus `json:"status"`
+
 	// PreviousContracts contains the list of contracts which were previously
 	// rewned **for the same billing cylce**. This is not a full history of the
 	// contract line, but only a history within the billing cycle. The primary
@@ -267,6 +941,32 @@ type ContractorSpending struct {
 	Unspent          types.Currency `json:"unspent"`
 }
 
+// ContractUtilization describes how much of a contract is used.
+type ContractUtilization struct {
+	ID               types.FileContractID `json:"id"`
+	HostPublicKey    types.SiaPublicKey   `json:"hostpublickey"`
+	NetAddress       NetAddress           `json:"netaddress"`
+	EndHeight        types.BlockHeight    `json:"endheight"`
+	StoredBytes      uint64               `json:"storedbytes"`
+	RemainingFunds   types.Currency       `json:"remainingfunds"`
+	TotalCost        types.Currency       `json:"totalcost"`
+	DownloadSpending types.Currency       `json:"downloadspending"`
+	StorageSpending  types.Currency       `json:"storagespending"`
+	UploadSpending   types.Currency       `json:"uploadspending"`
+	GoodForUpload    bool                 `json:"goodforupload"`
+	GoodForRenew     bool                 `json:"goodforrenew"`
+}
+
+// ContractUtilizationReport contains the utilization of every contract, and
+// how many contracts were replaced during the current period.
+// ReplacedContracts counts the contracts that were dropped in favor of better
+// hosts, and is limited by the MaxContractChurn setting.
+type ContractUtilizationReport struct {
+	Contracts         []ContractUtilization `json:"contracts"`
+	ReplacedContracts uint64                `json:"replacedcontracts"`
+	MaxContractChurn  uint64                `json:"maxcontractchurn"`
+}
+
 // EndHeight returns the height at which the host is no longer obligated to
 // store contract data.
 func (rc *RenterContract) EndHeight() types.BlockHeight {
@@ -290,15 +990,73 @@ type Renter interface {
 	// sorted by preference.
 	ActiveHosts() []HostDBEntry
 
+	// AddHosts inserts hosts into the hostdb, making them candidates for
+	// contract formation without waiting for their announcements. Each
+	// entry's public key and net address are validated.
+	AddHosts(entries []HostDBEntry) error
+
 	// AllHosts returns the full list of hosts known to the renter.
 	AllHosts() []HostDBEntry
 
+	// QueryHosts returns the hosts that match the query, sorted by
+	// preference.
+	QueryHosts(HostDBQuery) ([]HostDBEntry, error)
+
+	// Benchmark uploads 'size' bytes of random data through the full upload
+	// pipeline to 'hosts' hosts, and reports the end-to-end throughput and
+	// the latency and throughput of each host. The data is deleted from the
+	// hosts afterwards.
+	Benchmark(size uint64, hosts int) (UploadBenchmark, error)
+
+	// BenchmarkHost uploads and downloads raw random data to a single host,
+	// bypassing erasure coding and file tracking, and reports the measured
+	// throughput and latency. The data is deleted from the host afterwards.
+	BenchmarkHost(spk types.SiaPublicKey, bytes uint64) (HostBenchmark, error)
+
+	// MeasureHostRoundTrip uploads probe sectors to a single host, downloading
+	// and verifying each one right after it was stored, and reports the
+	// total round-trip time. The data is deleted from the host afterwards.
+	MeasureHostRoundTrip(spk types.SiaPublicKey, bytes uint64) (HostRoundTrip, error)
+
 	// Close closes the Renter.
 	Close() error
 
 	// Contracts returns the contracts formed by the renter.
 	Contracts() []RenterContract
 
+	// ContractExpirations returns how soon each of the renter's contracts
+	// expires. The values are updated whenever a block is added or removed.
+	ContractExpirations() map[types.FileContractID]ContractExpiry
+
+	// ContractUtilization reports how much data and money each of the
+	// renter's contracts holds, and how many contracts were replaced during
+	// the current period.
+	ContractUtilization() ContractUtilizationReport
+
+	// EstimateAllowance projects the fees and storage capacity of forming
+	// contracts for the allowance, without spending any money.
+	EstimateAllowance(Allowance) (AllowanceEstimate, error)
+
+	// UncontractedHosts returns the active hosts that the renter does not
+	// have a contract with.
+	UncontractedHosts() []HostDBEntry
+
+	// HostDBScanStatus returns the state of the hostdb's scan queue.
+	HostDBScanStatus() HostDBScanStatus
+
+	// WorkerPoolStatus returns the state of every worker in the worker pool,
+	// keyed by the ID of the worker's contract.
+	WorkerPoolStatus() map[types.FileContractID][]WorkerStatus
+
+	// ContractDiagnostics returns the state of the contract with the given
+	// ID, following renewals, together with the state of its host.
+	ContractDiagnostics(id types.FileContractID) (ContractDiagnostics, error)
+
+	// ContractsByHealth returns the renter's contracts ordered from least to
+	// most healthy, based on their remaining funds, the uptime of their hosts
+	// and how soon they expire.
+	ContractsByHealth() []RenterContract
+
 	// CurrentPeriod returns the height at which the current allowance period
 	// began.
 	CurrentPeriod() types.BlockHeight
@@ -307,13 +1065,60 @@ type Renter interface {
 	// billing period.
 	PeriodSpending() ContractorSpending
 
-	// DeleteFile deletes a file entry from the renter.
+	// DeleteFile deletes a file entry from the renter, along with the sectors
+	// of the file that are not used by any other file.
 	DeleteFile(path string) error
 
+	// CreateDir creates an empty directory. Directories that contain files
+	// exist implicitly and don't have to be created.
+	CreateDir(path string) error
+
+	// RenameDir moves a directory, including all of its files and
+	// subdirectories.
+	RenameDir(path, newPath string) error
+
+	// ListDir returns the subdirectories and the files directly inside a
+	// directory. The empty path is the root directory.
+	ListDir(path string) ([]DirectoryInfo, []FileInfo, error)
+
 	// Download performs a download according to the parameters passed, including
 	// downloads of `offset` and `length` type.
 	Download(params RenterDownloadParameters) error
 
+	// DownloadContext performs a download in the same way as Download, but
+	// cancels the download once ctx is done.
+	DownloadContext(ctx context.Context, params RenterDownloadParameters) error
+
+	// DownloadWithResult performs a download in the same way as Download,
+	// and additionally returns the per-chunk outcome of the download.
+	DownloadWithResult(params RenterDownloadParameters) (DownloadResult, error)
+
+	// DownloadWithResultContext performs a download in the same way as
+	// DownloadWithResult, but cancels the download once ctx is done.
+	DownloadWithResultContext(ctx context.Context, params RenterDownloadParameters) (DownloadResult, error)
+
+	// DownloadCachedOnly writes a file to dst using only chunks from the
+	// download cache. An error is returned if any chunk is not cached.
+	DownloadCachedOnly(path string, dst io.Writer) error
+
+	// DownloadToMirrors downloads a file once and writes it to every
+	// destination. A failing destination does not abort the download for the
+	// other destinations.
+	DownloadToMirrors(path string, dsts []io.Writer) error
+
+	// DownloadSection downloads 'length' bytes of a file, starting at
+	// 'offset', and writes them to w in order. A length of zero downloads the
+	// remainder of the file.
+	DownloadSection(path string, w io.Writer, offset, length uint64) error
+
+	// DownloadCostEstimate estimates the cost of downloading an entire file
+	// based on the prices of the hosts that store its pieces.
+	DownloadCostEstimate(path string) (DownloadCostEstimate, error)
+
+	// FileSpending attributes the spending of the renter's contracts to the
+	// file, based on the number of its pieces stored under each contract.
+	FileSpending(path string) (FileSpending, error)
+
 	// DownloadQueue lists all the files that have been scheduled for download.
 	DownloadQueue() []DownloadInfo
 
@@ -323,14 +1128,76 @@ type Renter interface {
 	// Host provides the DB entry and score breakdown for the requested host.
 	Host(pk types.SiaPublicKey) (HostDBEntry, bool)
 
+	// ListFiles returns the path of every file known to the renter, along
+	// with whether the renter is tracking (and therefore repairing) the file.
+	ListFiles() []TrackedFileInfo
+
 	// LoadSharedFiles loads a '.sia' file into the renter. A .sia file may
 	// contain multiple files. The paths of the added files are returned.
+	// The files can be downloaded once the renter has contracts with the
+	// hosts named in the .sia file, even if it did not upload them.
 	LoadSharedFiles(source string) ([]string, error)
 
 	// LoadSharedFilesAscii loads an ASCII-encoded '.sia' file into the
 	// renter.
 	LoadSharedFilesAscii(asciiSia string) ([]string, error)
 
+	// MemoryBreakdown reports how the renter's memory is being used.
+	MemoryBreakdown() RenterMemoryBreakdown
+
+	// RedundancyStatus returns the target and the achieved redundancy of
+	// every file, sorted by siapath.
+	RedundancyStatus() []FileRedundancy
+
+	// Health summarizes the redundancy of the renter's files.
+	Health() RenterHealth
+
+	// RetryStuckChunks resets the stuck chunks of a file, so that they are
+	// repaired by the repair scan again, and queues the file for repair.
+	RetryStuckChunks(siapath string) error
+
+	// RepairStats reports how often the repair loop runs and how much work
+	// it found during its most recent pass.
+	RepairStats() RepairStats
+
+	// DedupStats reports how many piece uploads were skipped because the
+	// host already stored an identical sector.
+	DedupStats() DedupStats
+
+	// UploadMetrics returns the per-stage timings of the most recently
+	// finished chunk uploads, oldest first.
+	UploadMetrics() []ChunkUploadMetrics
+
+	// WriteMetrics writes the counters and gauges of the renter, its
+	// contractor and its hostdb to w in the Prometheus text exposition
+	// format.
+	WriteMetrics(w io.Writer) error
+
+	// StartUploadTrace starts recording an execution trace of the upload
+	// pipeline that can be viewed with 'go tool trace'. It returns the path
+	// of the trace file.
+	StartUploadTrace() (string, error)
+
+	// StopUploadTrace stops recording the upload trace.
+	StopUploadTrace() error
+
+	// CreateBackup uploads a backup of the renter's metadata, encrypted with
+	// the wallet seed, and returns its siapath.
+	CreateBackup() (string, error)
+
+	// RestoreBackup restores the renter from the most recent metadata backup
+	// of the wallet seed and returns the siapaths of the restored files.
+	RestoreBackup() ([]string, error)
+
+	// RecoverContracts recovers the contracts that were formed with keys
+	// derived from the wallet seed and returns the number of contracts
+	// recovered.
+	RecoverContracts() (int, error)
+
+	// MonthlyCostProjection estimates the monthly cost of the renter's
+	// contracts and files based on the prices of its hosts.
+	MonthlyCostProjection() MonthlyCostProjection
+
 	// PriceEstimation estimates the cost in siacoins of performing various
 	// storage and data operations.
 	PriceEstimation() RenterPriceEstimation
@@ -338,6 +1205,11 @@ type Renter interface {
 	// RenameFile changes the path of a file.
 	RenameFile(path, newPath string) error
 
+	// EvacuateContract moves the pieces stored in a contract to other hosts,
+	// regenerating them from the local file or from the other pieces of the
+	// file.
+	EvacuateContract(id types.FileContractID) error
+
 	// EstimateHostScore will return the score for a host with the provided
 	// settings, assuming perfect age and uptime adjustments
 	EstimateHostScore(entry HostDBEntry) HostScoreBreakdown
@@ -349,19 +1221,115 @@ type Renter interface {
 	// Settings returns the Renter's current settings.
 	Settings() RenterSettings
 
-	// SetSettings sets the Renter's settings.
+	// SetSettings replaces all of the Renter's settings. Fields that are left
+	// at their zero value are reset, so callers that only change some
+	// settings should start from Settings(). If any setting is invalid, none
+	// of them are changed.
 	SetSettings(RenterSettings) error
 
-	// ShareFiles creates a '.sia' file that can be shared with others.
+	// SelfTest uploads a small probe file, downloads it, verifies its
+	// contents, and deletes it again, reporting how long each step took.
+	SelfTest() (RenterSelfTestReport, error)
+
+	// FormationFailures returns the most recent contract formation failure
+	// of every host that the renter could not form a contract with.
+	FormationFailures() []FormationFailure
+
+	// Alerts returns a warning for every contract whose formation
+	// transaction is in danger of never confirming on the blockchain.
+	Alerts() []ContractAlert
+
+	// HostFilter returns the renter's host filter mode and the filtered
+	// hosts.
+	HostFilter() (HostFilterMode, []types.SiaPublicKey)
+
+	// SetHostFilter sets the hosts that the renter may form contracts with.
+	// In blacklist mode, no contracts are formed with the given hosts; in
+	// whitelist mode, contracts are only formed with them. Data stored with
+	// hosts that are no longer allowed is moved to other hosts.
+	SetHostFilter(mode HostFilterMode, hosts []types.SiaPublicKey) error
+
+	// SubscribeUploadFailures returns a channel that receives an UploadFailure
+	// whenever the renter gives up on repairing a file. The channel is closed
+	// once cancel is closed or the renter shuts down.
+	SubscribeUploadFailures(cancel <-chan struct{}) <-chan UploadFailure
+
+	// SubscribeUploadProgress returns a channel that receives an
+	// UploadProgress whenever a chunk of the file at path finishes uploading.
+	// The channel is closed once cancel is closed or the renter shuts down.
+	SubscribeUploadProgress(path string, cancel <-chan struct{}) <-chan UploadProgress
+
+	// IsShuttingDown returns true once the renter has started shutting down.
+	IsShuttingDown() bool
+
+	// ShareFiles creates a '.sia' file that can be shared with others. The
+	// .sia file names the hosts storing each piece, along with the pieces'
+	// Merkle roots, the erasure coding parameters and the encryption key.
 	ShareFiles(paths []string, shareDest string) error
 
 	// ShareFilesAscii creates an ASCII-encoded '.sia' file.
 	ShareFilesAscii(paths []string) (asciiSia string, err error)
 
+	// PauseUpload stops uploading and repairing a tracked file until
+	// ResumeUpload is called. The pause survives restarts.
+	PauseUpload(path string) error
+
+	// ResumeUpload resumes uploading and repairing a paused file.
+	ResumeUpload(path string) error
+
+	// TrackFile starts tracking a file that was loaded from a '.sia' file,
+	// repairing it using the data found at repairPath.
+	TrackFile(path, repairPath string) error
+
 	// Upload uploads a file using the input parameters.
 	Upload(FileUploadParams) error
+
+	// UploadWait uploads a file like Upload, but blocks until the file
+	// reaches the given redundancy or cancel is closed.
+	UploadWait(up FileUploadParams, redundancy float64, cancel <-chan struct{}) error
+
+	// UploadStream uploads the data read from r to a new file at siapath,
+	// without staging it on disk. It returns once the data has been
+	// uploaded. A nil erasure coder selects the default erasure code.
+	UploadStream(siapath string, r io.Reader, ec ErasureCoder) error
+
+	// VerifyDownloadable fetches and authenticates every piece of a file
+	// without storing the data, and reports which chunks and hosts passed.
+	VerifyDownloadable(path string) (VerifyReport, error)
 }
 
+// DownloadIncompletePolicy determines what happens when a file is downloaded
+// before enough of its pieces have been uploaded to recover it.
+type DownloadIncompletePolicy string
+
+const (
+	// IncompleteWait blocks the download until every requested chunk has
+	// enough pieces. This is the default policy.
+	IncompleteWait DownloadIncompletePolicy = "wait"
+
+	// IncompleteFail rejects the download immediately.
+	IncompleteFail DownloadIncompletePolicy = "fail"
+
+	// IncompleteBestEffort downloads the chunks that can be recovered and
+	// skips the others, leaving gaps in the destination.
+	IncompleteBestEffort DownloadIncompletePolicy = "besteffort"
+)
+
+// DownloadPriority determines the order in which the chunks of concurrent
+// downloads are fetched.
+type DownloadPriority string
+
+const (
+	// PriorityInteractive is the priority of downloads requested by the user.
+	// Their chunks are fetched before those of background downloads. This is
+	// the default priority.
+	PriorityInteractive DownloadPriority = "interactive"
+
+	// PriorityBackground is the priority of downloads that nobody is waiting
+	// for, such as the downloads of the repair loop.
+	PriorityBackground DownloadPriority = "background"
+)
+
 // RenterDownloadParameters defines the parameters passed to the Renter's
 // Download method.
 type RenterDownloadParameters struct {
@@ -371,4 +1339,12 @@ type RenterDownloadParameters struct {
 	Offset      uint64
 	Siapath     string
 	Destination string
+
+	// IncompletePolicy determines what happens if the file has not finished
+	// uploading. An empty policy is the same as IncompleteWait.
+	IncompletePolicy DownloadIncompletePolicy
+
+	// Priority determines whether the download is served before or after
+	// other downloads. An empty priority is the same as PriorityInteractive.
+	Priority DownloadPriority
 }
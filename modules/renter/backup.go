@@ -0,0 +1,366 @@
+package renter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pachisi456/Sia/crypto"
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/persist"
+	"github.com/pachisi456/Sia/types"
+)
+
+const (
+	// backupDir is the directory of the siapaths reserved for metadata
+	// backups. Every backup is uploaded to a new siapath in this directory,
+	// named after the time the backup was created.
+	backupDir = "__metadatabackup__"
+
+	// backupIndexOverhead is the space reserved for the inputs, outputs and
+	// signatures of the transaction that publishes a backup index.
+	backupIndexOverhead = 2e3
+)
+
+var (
+	backupMetadata = persist.Metadata{
+		Header:  "Renter Metadata Backup",
+		Version: "1.0",
+	}
+
+	// backupIndexPrefix is the prefix of the arbitrary data that publishes
+	// the index of a metadata backup on the blockchain.
+	backupIndexPrefix = types.Specifier{'R', 'e', 'n', 't', 'e', 'r', 'B', 'a', 'c', 'k', 'u', 'p'}
+
+	errBackupIndexTooLarge = errors.New("the index of the metadata backup does not fit into a transaction")
+	errBadBackup           = errors.New("the metadata backup can't be decrypted with the wallet seed")
+	errNoBackup            = errors.New("no metadata backup of the wallet seed was found on the blockchain")
+	errNoWallet            = errors.New("the renter has no wallet to derive the backup key from")
+)
+
+// metadataBackup is the data stored in a metadata backup. Files holds the
+// .sia data of all of the renter's files, as written by shareFiles.
+type metadataBackup struct {
+	persist.Metadata
+	Files     []byte                   `json:"files"`
+	Contracts []modules.RenterContract `json:"contracts"`
+}
+
+// backupKey derives the key that encrypts metadata backups and their indexes
+// from a wallet seed.
+func backupKey(seed modules.Seed) crypto.TwofishKey {
+	return crypto.TwofishKey(crypto.HashAll("renter metadata backup", seed))
+}
+
+// backupID derives the identifier of the backup indexes of a wallet seed. It
+// allows the renter to find its indexes on the blockchain without revealing
+// the seed.
+func backupID(seed modules.Seed) crypto.Hash {
+	return crypto.HashAll("renter metadata backup id", seed)
+}
+
+// isBackupSiapath returns whether siapath is reserved for metadata backups.
+func isBackupSiapath(siapath string) bool {
+	return siapath == backupDir || strings.HasPrefix(siapath, backupDir+"/")
+}
+
+// encodeBackupIndex returns the arbitrary data that publishes the index of a
+// metadata backup, i.e. the .sia data of the file holding the backup.
+func encodeBackupIndex(seed modules.Seed, backupFile *file) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := shareFiles([]*file{backupFile}, buf); err != nil {
+		return nil, err
+	}
+	key, id := backupKey(seed), backupID(seed)
+	arb := append(backupIndexPrefix[:], id[:]...)
+	return append(arb, key.EncryptBytes(buf.Bytes())...), nil
+}
+
+// decodeBackupIndex returns the file holding the metadata backup that is
+// published by arb, or false if arb is not an index of seed.
+func decodeBackupIndex(seed modules.Seed, arb []byte) (*file, bool) {
+	id := backupID(seed)
+	header := append(backupIndexPrefix[:], id[:]...)
+	if !bytes.HasPrefix(arb, header) {
+		return nil, false
+	}
+	key := backupKey(seed)
+	plaintext, err := key.DecryptBytes(arb[len(header):])
+	if err != nil {
+		return nil, false
+	}
+	files, err := decodeSharedFiles(bytes.NewReader(plaintext))
+	if err != nil || len(files) != 1 {
+		return nil, false
+	}
+	return files[0], true
+}
+
+// backupIndexScanner scans the blockchain for the indexes of the metadata
+// backups of a wallet seed. indexes holds the arbitrary data of the indexes
+// in the order in which they were published.
+type backupIndexScanner struct {
+	header  []byte
+	indexes [][]byte
+}
+
+// ProcessConsensusChange implements modules.ConsensusSetSubscriber.
+func (s *backupIndexScanner) ProcessConsensusChange(cc modules.ConsensusChange) {
+	for _, block := range cc.RevertedBlocks {
+		for _, txn := range block.Transactions {
+			for _, arb := range txn.ArbitraryData {
+				if !bytes.HasPrefix(arb, s.header) {
+					continue
+				}
+				for i := len(s.indexes) - 1; i >= 0; i-- {
+					if bytes.Equal(s.indexes[i], arb) {
+						s.indexes = append(s.indexes[:i], s.indexes[i+1:]...)
+						break
+					}
+				}
+			}
+		}
+	}
+	for _, block := range cc.AppliedBlocks {
+		for _, txn := range block.Transactions {
+			for _, arb := range txn.ArbitraryData {
+				if bytes.HasPrefix(arb, s.header) {
+					s.indexes = append(s.indexes, arb)
+				}
+			}
+		}
+	}
+}
+
+// managedWalletSeed returns the primary seed of the renter's wallet.
+func (r *Renter) managedWalletSeed() (modules.Seed, error) {
+	if r.wallet == nil {
+		return modules.Seed{}, errNoWallet
+	}
+	seed, _, err := r.wallet.PrimarySeed()
+	return seed, err
+}
+
+// managedPublishBackupIndex publishes arb in a transaction, so that the backup
+// can be found using only the wallet seed.
+func (r *Renter) managedPublishBackupIndex(arb []byte) error {
+	if len(arb)+backupIndexOverhead > modules.TransactionSizeLimit {
+		return errBackupIndexTooLarge
+	}
+	_, maxFee := r.tpool.FeeEstimation()
+	fee := maxFee.Mul64(uint64(len(arb) + backupIndexOverhead))
+
+	txnBuilder := r.wallet.StartTransaction()
+	if err := txnBuilder.FundSiacoins(fee); err != nil {
+		txnBuilder.Drop()
+		return err
+	}
+	txnBuilder.AddMinerFee(fee)
+	txnBuilder.AddArbitraryData(arb)
+	txnSet, err := txnBuilder.Sign(true)
+	if err != nil {
+		txnBuilder.Drop()
+		return err
+	}
+	if err := r.tpool.AcceptTransactionSet(txnSet); err != nil {
+		txnBuilder.Drop()
+		return err
+	}
+	return nil
+}
+
+// CreateBackup uploads a backup of the metadata of all files and of all
+// contracts of the renter, encrypted with a key derived from the wallet seed.
+// The index of the backup is published on the blockchain, so that the renter
+// can be restored on another machine with only the seed, see RestoreBackup.
+// Older backups are deleted once the new backup is published. The siapath of
+// the backup is returned.
+func (r *Renter) CreateBackup() (string, error) {
+	if err := r.tg.Add(); err != nil {
+		return "", ErrRenterShutdown
+	}
+	defer r.tg.Done()
+
+	seed, err := r.managedWalletSeed()
+	if err != nil {
+		return "", err
+	}
+
+	// Collect the metadata. Backups themselves are not part of the backup.
+	backup := metadataBackup{
+		Metadata:  backupMetadata,
+		Contracts: r.hostContractor.Contracts(),
+	}
+	var oldBackups []string
+	id := r.mu.RLock()
+	var files []*file
+	for name, f := range r.files {
+		if isBackupSiapath(name) {
+			oldBackups = append(oldBackups, name)
+			continue
+		} else if isReservedSiapath(name) {
+			continue
+		}
+		f.mu.Lock()
+		r.recordHostKeys(f)
+		f.mu.Unlock()
+		files = append(files, f)
+	}
+	buf := new(bytes.Buffer)
+	err = shareFiles(files, buf)
+	r.mu.RUnlock(id)
+	if err != nil {
+		return "", err
+	}
+	backup.Files = buf.Bytes()
+	plaintext, err := json.Marshal(backup)
+	if err != nil {
+		return "", err
+	}
+
+	// Upload the backup and publish its index.
+	key := backupKey(seed)
+	siapath := backupDir + "/" + time.Now().UTC().Format("20060102T150405")
+	err = r.managedUploadStream(siapath, bytes.NewReader(key.EncryptBytes(plaintext)), nil)
+	if err != nil {
+		return "", err
+	}
+	id = r.mu.RLock()
+	backupFile := r.files[siapath]
+	r.mu.RUnlock(id)
+	backupFile.mu.RLock()
+	arb, err := encodeBackupIndex(seed, backupFile)
+	backupFile.mu.RUnlock()
+	if err == nil {
+		err = r.managedPublishBackupIndex(arb)
+	}
+	if err != nil {
+		r.DeleteFile(siapath)
+		return "", err
+	}
+	r.log.Printf("Created metadata backup %v of %v files and %v contracts", siapath, len(files), len(backup.Contracts))
+
+	for _, name := range oldBackups {
+		if err := r.DeleteFile(name); err != nil && err != ErrUnknownPath {
+			r.log.Println("WARN: couldn't delete old metadata backup:", err)
+		}
+	}
+	return siapath, nil
+}
+
+// managedLatestBackupFile scans the blockchain for the most recent metadata
+// backup of seed and returns the file holding it.
+func (r *Renter) managedLatestBackupFile(seed modules.Seed) (*file, error) {
+	id := backupID(seed)
+	scanner := &backupIndexScanner{header: append(backupIndexPrefix[:], id[:]...)}
+	err := r.cs.ConsensusSetSubscribe(scanner, modules.ConsensusChangeBeginning, r.tg.StopChan())
+	r.cs.Unsubscribe(scanner)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(scanner.indexes) - 1; i >= 0; i-- {
+		if f, ok := decodeBackupIndex(seed, scanner.indexes[i]); ok {
+			return f, nil
+		}
+	}
+	return nil, errNoBackup
+}
+
+// RestoreBackup restores the renter from the most recent metadata backup of
+// the wallet seed. The contracts in the backup are imported into the
+// contractor, and the files are added to the renter and repaired using data
+// downloaded from their hosts. The backup is downloaded through the renter's
+// current contracts, so the renter needs contracts with some of the hosts
+// storing the backup, e.g. by setting an allowance first. The siapaths of the
+// restored files are returned; like with .sia files, files that conflict with
+// existing files are given a suffixed siapath.
+func (r *Renter) RestoreBackup() ([]string, error) {
+	if err := r.tg.Add(); err != nil {
+		return nil, ErrRenterShutdown
+	}
+	defer r.tg.Done()
+
+	seed, err := r.managedWalletSeed()
+	if err != nil {
+		return nil, err
+	}
+	backupFile, err := r.managedLatestBackupFile(seed)
+	if err != nil {
+		return nil, err
+	}
+
+	// Download the backup through the renter's own contracts with the hosts
+	// storing it.
+	byHost := contractsByHost(r.hostContractor.Contracts())
+	id := r.mu.Lock()
+	backupFile.mu.Lock()
+	r.adoptForeignContracts(backupFile, byHost)
+	backupFile.mu.Unlock()
+	r.mu.Unlock(id)
+	buf := NewDownloadBufferWriter(backupFile.size, 0)
+	d := r.newSectionDownload(backupFile, buf, 0, backupFile.size)
+	select {
+	case r.newDownloads <- d:
+	case <-r.tg.StopChan():
+		return nil, ErrRenterShutdown
+	}
+	select {
+	case <-d.downloadFinished:
+	case <-r.tg.StopChan():
+		return nil, ErrRenterShutdown
+	}
+	if err := d.Err(); err != nil {
+		return nil, err
+	}
+
+	// Decrypt and decode the backup.
+	key := backupKey(seed)
+	plaintext, err := key.DecryptBytes(buf.Bytes())
+	if err != nil {
+		return nil, errBadBackup
+	}
+	var backup metadataBackup
+	if err := json.Unmarshal(plaintext, &backup); err != nil {
+		return nil, err
+	} else if backup.Header != backupMetadata.Header {
+		return nil, persist.ErrBadHeader
+	} else if backup.Version != backupMetadata.Version {
+		return nil, persist.ErrBadVersion
+	}
+
+	// Import the contracts first, so that the pieces stored in them are not
+	// treated as foreign when the files are loaded.
+	imported, err := r.hostContractor.ImportContracts(backup.Contracts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Add the files and the backup itself to the renter. Restored files are
+	// tracked without a repair path, so they are repaired from their hosts.
+	id = r.mu.Lock()
+	defer r.mu.Unlock(id)
+	names, err := r.loadSharedFiles(bytes.NewReader(backup.Files))
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		r.tracking[name] = trackedFile{}
+	}
+	if _, exists := r.files[backupFile.name]; !exists {
+		r.files[backupFile.name] = backupFile
+		r.addStorage(storedBytes(backupFile))
+		r.tracking[backupFile.name] = trackedFile{}
+		if err := r.saveFile(backupFile); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.saveSync(); err != nil {
+		return nil, err
+	}
+	r.log.Printf("Restored %v files and %v contracts from metadata backup %v", len(names), imported, backupFile.name)
+	sort.Strings(names)
+	return names, nil
+}
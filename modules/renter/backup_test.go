@@ -0,0 +1,70 @@
+package renter
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/types"
+
+	"github.com/NebulousLabs/fastrand"
+)
+
+// TestBackupIndex checks that backup indexes can only be decoded with the
+// seed that they were encoded with, and that the scanner keeps track of the
+// indexes of a seed as blocks are applied and reverted.
+func TestBackupIndex(t *testing.T) {
+	var seed, otherSeed modules.Seed
+	fastrand.Read(seed[:])
+	fastrand.Read(otherSeed[:])
+	f := newTestingFile()
+	f.name = backupDir + "/20180101T120000"
+	arb, err := encodeBackupIndex(seed, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, ok := decodeBackupIndex(seed, arb)
+	if !ok {
+		t.Fatal("couldn't decode backup index")
+	} else if err := equalFiles(f, decoded); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := decodeBackupIndex(otherSeed, arb); ok {
+		t.Fatal("backup index was decoded with the wrong seed")
+	}
+
+	id := backupID(seed)
+	scanner := &backupIndexScanner{header: append(backupIndexPrefix[:], id[:]...)}
+	otherArb, err := encodeBackupIndex(otherSeed, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := func(arbs ...[]byte) types.Block {
+		return types.Block{Transactions: []types.Transaction{{ArbitraryData: arbs}}}
+	}
+	scanner.ProcessConsensusChange(modules.ConsensusChange{
+		AppliedBlocks: []types.Block{block(arb, otherArb), block([]byte("foo"))},
+	})
+	if len(scanner.indexes) != 1 || !bytes.Equal(scanner.indexes[0], arb) {
+		t.Fatal("scanner found the wrong indexes:", len(scanner.indexes))
+	}
+	scanner.ProcessConsensusChange(modules.ConsensusChange{
+		RevertedBlocks: []types.Block{block(arb)},
+	})
+	if len(scanner.indexes) != 0 {
+		t.Fatal("reverted index was not removed")
+	}
+}
+
+// TestBackupSiapathReserved checks that users can't upload to the siapaths of
+// metadata backups.
+func TestBackupSiapathReserved(t *testing.T) {
+	for _, siapath := range []string{backupDir, backupDir + "/foo"} {
+		if !isReservedSiapath(siapath) {
+			t.Error("backup siapath is not reserved:", siapath)
+		}
+	}
+	if isReservedSiapath(backupDir + "foo") {
+		t.Error("siapath outside of the backup directory is reserved")
+	}
+}
@@ -0,0 +1,369 @@
+package renter
+
+// The renter distinguishes between foreground traffic, which is traffic that
+// the user is actively waiting on (user-initiated downloads and the first
+// upload of a file), and repair traffic, which restores redundancy to files
+// that are already recoverable from the network. Repair traffic is throttled
+// whenever the foreground traffic is high, so that repair does not compete
+// with the user for bandwidth.
+//
+// Separately, all download traffic is subject to the MaxDownloadSpeed cap.
+// When fairness is enabled, the cap is divided evenly between the downloads
+// that are actively transferring data, so that a single large download cannot
+// starve the others. A download that stops transferring data gives up its
+// share, which is redistributed among the remaining downloads.
+//
+// Likewise, all upload traffic, including repairs, is subject to the
+// MaxUploadSpeed cap, which is shared by every worker in the pool.
+
+import (
+	"sync"
+	"time"
+)
+
+type (
+	// bandwidthMeter measures throughput over a sliding window of time.
+	bandwidthMeter struct {
+		samples []bandwidthSample
+		window  time.Duration
+		mu      sync.Mutex
+	}
+
+	// bandwidthSample is a single measurement taken by a bandwidthMeter.
+	bandwidthSample struct {
+		bytes     uint64
+		timestamp time.Time
+	}
+
+	// repairThrottle limits the bandwidth used by repair traffic based on the
+	// amount of foreground traffic that has been measured recently.
+	//
+	// When the foreground throughput is at or above 'threshold', repair is
+	// limited to 'throttledBandwidth' bytes per second. As foreground traffic
+	// decreases, the repair limit increases proportionally, until the
+	// foreground is idle and repair is no longer limited at all. A threshold
+	// of zero disables throttling.
+	repairThrottle struct {
+		threshold          uint64
+		throttledBandwidth uint64
+
+		foreground bandwidthMeter
+
+		// nextTransfer is the earliest time at which the next repair transfer
+		// may begin.
+		nextTransfer time.Time
+		mu           sync.Mutex
+	}
+
+	// downloadLimiter limits the combined bandwidth of all downloads to
+	// 'maxSpeed' bytes per second. A maxSpeed of zero means that downloads are
+	// not limited.
+	downloadLimiter struct {
+		fair     bool
+		maxSpeed uint64
+
+		// nextTransfer is the earliest time at which the next transfer may
+		// begin when fairness is disabled. When fairness is enabled, each
+		// download keeps track of its own share of the bandwidth instead.
+		nextTransfer time.Time
+		shares       map[*download]*downloadShare
+		mu           sync.Mutex
+	}
+
+	// uploadLimiter limits the combined bandwidth of all uploads to
+	// 'maxSpeed' bytes per second. A maxSpeed of zero means that uploads are
+	// not limited.
+	uploadLimiter struct {
+		maxSpeed uint64
+
+		// nextTransfer is the earliest time at which the next upload may
+		// begin.
+		nextTransfer time.Time
+		mu           sync.Mutex
+	}
+
+	// downloadShare tracks the bandwidth reserved by a single download.
+	downloadShare struct {
+		nextTransfer time.Time
+	}
+)
+
+// newBandwidthMeter returns a bandwidthMeter that measures throughput over the
+// provided window.
+func newBandwidthMeter(window time.Duration) bandwidthMeter {
+	return bandwidthMeter{
+		window: window,
+	}
+}
+
+// newRepairThrottle returns a repairThrottle that uses the default thresholds.
+func newRepairThrottle() *repairThrottle {
+	return &repairThrottle{
+		threshold:          defaultRepairThrottleThreshold,
+		throttledBandwidth: defaultRepairThrottledBandwidth,
+
+		foreground: newBandwidthMeter(bandwidthMeasurementWindow),
+	}
+}
+
+// newDownloadLimiter returns a downloadLimiter that does not limit downloads
+// until a maximum speed is set.
+func newDownloadLimiter() *downloadLimiter {
+	return &downloadLimiter{
+		fair:   defaultDownloadFairness,
+		shares: make(map[*download]*downloadShare),
+	}
+}
+
+// newUploadLimiter returns an uploadLimiter that does not limit uploads until
+// a maximum speed is set.
+func newUploadLimiter() *uploadLimiter {
+	return &uploadLimiter{}
+}
+
+// prune drops all samples that have fallen out of the measurement window.
+func (bm *bandwidthMeter) prune(now time.Time) {
+	i := 0
+	for i < len(bm.samples) && now.Sub(bm.samples[i].timestamp) > bm.window {
+		i++
+	}
+	bm.samples = bm.samples[i:]
+}
+
+// managedRecord adds a measurement of 'n' bytes to the meter.
+func (bm *bandwidthMeter) managedRecord(n uint64) {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	now := time.Now()
+	bm.prune(now)
+	bm.samples = append(bm.samples, bandwidthSample{
+		bytes:     n,
+		timestamp: now,
+	})
+}
+
+// managedRate returns the measured throughput in bytes per second.
+func (bm *bandwidthMeter) managedRate() uint64 {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	bm.prune(time.Now())
+	var total uint64
+	for _, s := range bm.samples {
+		total += s.bytes
+	}
+	return uint64(float64(total) / bm.window.Seconds())
+}
+
+// limit returns the number of bytes per second that repair is allowed to
+// consume given the current amount of foreground traffic. A return value of
+// zero means that repair is not limited.
+func (rt *repairThrottle) limit() uint64 {
+	if rt.threshold == 0 || rt.throttledBandwidth == 0 {
+		return 0
+	}
+	foreground := rt.foreground.managedRate()
+	if foreground == 0 {
+		return 0
+	}
+	if foreground >= rt.threshold {
+		return rt.throttledBandwidth
+	}
+	// Scale the repair bandwidth up as the foreground traffic drops below the
+	// threshold.
+	return uint64(float64(rt.throttledBandwidth) * float64(rt.threshold) / float64(foreground))
+}
+
+// managedReserve reserves bandwidth for a repair transfer of 'n' bytes,
+// returning the amount of time that the caller needs to wait before starting
+// the transfer.
+func (rt *repairThrottle) managedReserve(n uint64) time.Duration {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	limit := rt.limit()
+	now := time.Now()
+	if limit == 0 {
+		// Repair is not being throttled, any previous reservations are void.
+		rt.nextTransfer = now
+		return 0
+	}
+	if rt.nextTransfer.Before(now) {
+		rt.nextTransfer = now
+	}
+	wait := rt.nextTransfer.Sub(now)
+	rt.nextTransfer = rt.nextTransfer.Add(time.Duration(float64(n) / float64(limit) * float64(time.Second)))
+	return wait
+}
+
+// managedSetThresholds updates the thresholds of the throttle.
+func (rt *repairThrottle) managedSetThresholds(threshold, throttledBandwidth uint64) {
+	rt.mu.Lock()
+	rt.threshold = threshold
+	rt.throttledBandwidth = throttledBandwidth
+	rt.mu.Unlock()
+}
+
+// managedThresholds returns the current thresholds of the throttle.
+func (rt *repairThrottle) managedThresholds() (threshold, throttledBandwidth uint64) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return rt.threshold, rt.throttledBandwidth
+}
+
+// pruneShares drops the shares of all downloads that are no longer consuming
+// bandwidth. A download is considered active until it has gone
+// downloadShareIdleTimeout without reserved bandwidth, at which point its
+// share is redistributed among the other downloads.
+func (dl *downloadLimiter) pruneShares(now time.Time) {
+	for d, share := range dl.shares {
+		if now.After(share.nextTransfer.Add(downloadShareIdleTimeout)) {
+			delete(dl.shares, d)
+		}
+	}
+}
+
+// managedReserve reserves bandwidth for a transfer of 'n' bytes on behalf of
+// the download 'd', returning the amount of time that the caller needs to
+// wait before starting the transfer.
+func (dl *downloadLimiter) managedReserve(d *download, n uint64) time.Duration {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+
+	now := time.Now()
+	if dl.maxSpeed == 0 {
+		// Downloads are not being limited.
+		return 0
+	}
+
+	// Without fairness, all downloads draw from the same budget in the order
+	// that they request bandwidth.
+	if !dl.fair {
+		if dl.nextTransfer.Before(now) {
+			dl.nextTransfer = now
+		}
+		wait := dl.nextTransfer.Sub(now)
+		dl.nextTransfer = dl.nextTransfer.Add(time.Duration(float64(n) / float64(dl.maxSpeed) * float64(time.Second)))
+		return wait
+	}
+
+	// With fairness, each active download receives an equal share of the
+	// budget.
+	dl.pruneShares(now)
+	share, exists := dl.shares[d]
+	if !exists {
+		share = &downloadShare{nextTransfer: now}
+		dl.shares[d] = share
+	}
+	rate := float64(dl.maxSpeed) / float64(len(dl.shares))
+	if share.nextTransfer.Before(now) {
+		share.nextTransfer = now
+	}
+	wait := share.nextTransfer.Sub(now)
+	share.nextTransfer = share.nextTransfer.Add(time.Duration(float64(n) / rate * float64(time.Second)))
+	return wait
+}
+
+// managedSetLimits updates the maximum download speed and whether the
+// bandwidth is divided fairly between downloads.
+func (dl *downloadLimiter) managedSetLimits(maxSpeed uint64, fair bool) {
+	dl.mu.Lock()
+	dl.maxSpeed = maxSpeed
+	dl.fair = fair
+	dl.mu.Unlock()
+}
+
+// managedLimits returns the maximum download speed and whether the bandwidth
+// is divided fairly between downloads.
+func (dl *downloadLimiter) managedLimits() (maxSpeed uint64, fair bool) {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	return dl.maxSpeed, dl.fair
+}
+
+// managedReserve reserves bandwidth for an upload of 'n' bytes, returning the
+// amount of time that the caller needs to wait before starting the transfer.
+func (ul *uploadLimiter) managedReserve(n uint64) time.Duration {
+	ul.mu.Lock()
+	defer ul.mu.Unlock()
+
+	now := time.Now()
+	if ul.maxSpeed == 0 {
+		// Uploads are not being limited, any previous reservations are void.
+		ul.nextTransfer = now
+		return 0
+	}
+	if ul.nextTransfer.Before(now) {
+		ul.nextTransfer = now
+	}
+	wait := ul.nextTransfer.Sub(now)
+	ul.nextTransfer = ul.nextTransfer.Add(time.Duration(float64(n) / float64(ul.maxSpeed) * float64(time.Second)))
+	return wait
+}
+
+// managedSetMaxSpeed updates the maximum upload speed.
+func (ul *uploadLimiter) managedSetMaxSpeed(maxSpeed uint64) {
+	ul.mu.Lock()
+	ul.maxSpeed = maxSpeed
+	ul.mu.Unlock()
+}
+
+// managedMaxSpeed returns the maximum upload speed.
+func (ul *uploadLimiter) managedMaxSpeed() uint64 {
+	ul.mu.Lock()
+	defer ul.mu.Unlock()
+	return ul.maxSpeed
+}
+
+// managedRecordForeground informs the renter that 'n' bytes of foreground
+// traffic have been transferred.
+func (r *Renter) managedRecordForeground(n uint64) {
+	r.repairThrottle.foreground.managedRecord(n)
+}
+
+// managedThrottleRepair blocks until the repair throttle allows a repair
+// transfer of 'n' bytes to proceed. False is returned if the renter was shut
+// down while waiting.
+func (r *Renter) managedThrottleRepair(n uint64) bool {
+	wait := r.repairThrottle.managedReserve(n)
+	if wait == 0 {
+		return true
+	}
+	select {
+	case <-time.After(wait):
+		return true
+	case <-r.tg.StopChan():
+		return false
+	}
+}
+
+// managedThrottleDownload blocks until the download limiter allows 'd' to
+// transfer 'n' bytes. False is returned if the renter was shut down while
+// waiting.
+func (r *Renter) managedThrottleDownload(d *download, n uint64) bool {
+	wait := r.downloadLimiter.managedReserve(d, n)
+	if wait == 0 {
+		return true
+	}
+	select {
+	case <-time.After(wait):
+		return true
+	case <-r.tg.StopChan():
+		return false
+	}
+}
+
+// managedThrottleUpload blocks until the upload limiter allows a transfer of
+// 'n' bytes to proceed. False is returned if the renter was shut down while
+// waiting.
+func (r *Renter) managedThrottleUpload(n uint64) bool {
+	wait := r.uploadLimiter.managedReserve(n)
+	if wait == 0 {
+		return true
+	}
+	select {
+	case <-time.After(wait):
+		return true
+	case <-r.tg.StopChan():
+		return false
+	}
+}
@@ -0,0 +1,195 @@
+package renter
+
+import (
+	"testing"
+	"time"
+)
+
+// TestBandwidthMeter checks that the bandwidth meter reports the throughput
+// of the samples within its window.
+func TestBandwidthMeter(t *testing.T) {
+	bm := newBandwidthMeter(time.Second)
+	if rate := bm.managedRate(); rate != 0 {
+		t.Fatal("empty meter should report zero throughput, got", rate)
+	}
+	bm.managedRecord(500)
+	bm.managedRecord(500)
+	if rate := bm.managedRate(); rate != 1000 {
+		t.Fatal("expected a rate of 1000 bytes per second, got", rate)
+	}
+
+	// Samples outside of the window should be dropped.
+	bm.samples[0].timestamp = time.Now().Add(-2 * time.Second)
+	if rate := bm.managedRate(); rate != 500 {
+		t.Fatal("expected a rate of 500 bytes per second, got", rate)
+	}
+}
+
+// TestRepairThrottleForegroundLoad checks that simulated foreground load
+// reduces the throughput of repair traffic, and that repair ramps back up once
+// the foreground is idle.
+func TestRepairThrottleForegroundLoad(t *testing.T) {
+	throttle := newRepairThrottle()
+	throttle.foreground = newBandwidthMeter(time.Second)
+	throttle.managedSetThresholds(1000, 100)
+
+	// repairTime returns the amount of time that the last of 10 repair
+	// transfers of 100 bytes each has to wait before it may begin.
+	repairTime := func() time.Duration {
+		throttle.nextTransfer = time.Time{}
+		var wait time.Duration
+		for i := 0; i < 10; i++ {
+			wait = throttle.managedReserve(100)
+		}
+		return wait
+	}
+
+	// Without any foreground traffic, repair should not be limited.
+	if limit := throttle.limit(); limit != 0 {
+		t.Fatal("repair should be unlimited while the foreground is idle, got", limit)
+	}
+	if d := repairTime(); d != 0 {
+		t.Fatal("idle repair should not have to wait, waited", d)
+	}
+
+	// Simulate foreground traffic at the threshold. Repair should be limited
+	// to the throttled bandwidth, meaning 1000 bytes of repair take about 9
+	// seconds before the final transfer may begin.
+	throttle.foreground.managedRecord(1000)
+	if limit := throttle.limit(); limit != 100 {
+		t.Fatal("expected repair to be throttled to 100 bytes per second, got", limit)
+	}
+	if d := repairTime(); d < 8*time.Second {
+		t.Fatal("repair under foreground load should have been throttled, waited", d)
+	}
+
+	// Simulate foreground traffic at half of the threshold. Repair should
+	// receive twice the throttled bandwidth.
+	throttle.foreground = newBandwidthMeter(time.Second)
+	throttle.foreground.managedRecord(500)
+	if limit := throttle.limit(); limit != 200 {
+		t.Fatal("expected repair to be throttled to 200 bytes per second, got", limit)
+	}
+
+	// Once the foreground traffic has left the measurement window, repair
+	// should ramp back up to full speed.
+	throttle.foreground.samples[0].timestamp = time.Now().Add(-2 * time.Second)
+	if d := repairTime(); d != 0 {
+		t.Fatal("repair should be unlimited once the foreground is idle, waited", d)
+	}
+
+	// A threshold of zero disables throttling entirely.
+	throttle.managedSetThresholds(0, 100)
+	throttle.foreground.managedRecord(1e6)
+	if d := repairTime(); d != 0 {
+		t.Fatal("repair should not be throttled when throttling is disabled, waited", d)
+	}
+}
+
+// TestDownloadLimiterFairness checks that two concurrent downloads under a
+// bandwidth cap each receive roughly half of the bandwidth when fairness is
+// enabled, and that a greedy download can starve others when it is disabled.
+func TestDownloadLimiterFairness(t *testing.T) {
+	d1, d2 := new(download), new(download)
+
+	// reserve records the amount of time that download 'd' has to wait
+	// before it may begin a transfer of 100 bytes.
+	var waits map[*download][]time.Duration
+	reserve := func(dl *downloadLimiter, d *download) {
+		waits[d] = append(waits[d], dl.managedReserve(d, 100))
+	}
+
+	// Without a cap, downloads are not limited.
+	dl := newDownloadLimiter()
+	waits = make(map[*download][]time.Duration)
+	for i := 0; i < 10; i++ {
+		reserve(dl, d1)
+	}
+	if d := waits[d1][9]; d != 0 {
+		t.Fatal("uncapped download should not have to wait, waited", d)
+	}
+
+	// With fairness enabled, interleaved downloads each receive half of the
+	// cap. The tenth transfer of each download should begin after about 1.8
+	// seconds.
+	dl.managedSetLimits(1000, true)
+	waits = make(map[*download][]time.Duration)
+	for i := 0; i < 10; i++ {
+		reserve(dl, d1)
+		reserve(dl, d2)
+	}
+	for _, d := range []*download{d1, d2} {
+		if w := waits[d][9]; w < 1600*time.Millisecond || w > 2*time.Second {
+			t.Fatal("expected each download to receive half of the bandwidth, waited", w)
+		}
+	}
+
+	// A new download should not have to wait behind the reservations of
+	// the existing downloads.
+	d3 := new(download)
+	if w := dl.managedReserve(d3, 100); w != 0 {
+		t.Fatal("new download should not be starved by existing downloads, waited", w)
+	}
+
+	// Once the other downloads go idle, their share is redistributed and the
+	// remaining download receives the full cap.
+	dl = newDownloadLimiter()
+	dl.managedSetLimits(1000, true)
+	dl.managedReserve(d1, 100)
+	dl.managedReserve(d2, 100)
+	dl.shares[d2].nextTransfer = time.Now().Add(-2 * downloadShareIdleTimeout)
+	waits = make(map[*download][]time.Duration)
+	for i := 0; i < 10; i++ {
+		reserve(dl, d1)
+	}
+	if w := waits[d1][9]; w < 900*time.Millisecond || w > 1100*time.Millisecond {
+		t.Fatal("expected the remaining download to receive the full bandwidth, waited", w)
+	}
+
+	// With fairness disabled, a greedy download consumes the whole budget
+	// before the other download may begin.
+	dl = newDownloadLimiter()
+	dl.managedSetLimits(1000, false)
+	for i := 0; i < 20; i++ {
+		dl.managedReserve(d1, 100)
+	}
+	if w := dl.managedReserve(d2, 100); w < 1900*time.Millisecond {
+		t.Fatal("expected the second download to wait behind the first, waited", w)
+	}
+}
+
+// TestUploadLimiter checks that the upload limiter spaces out uploads
+// according to the cap, and that changing the cap takes effect immediately.
+func TestUploadLimiter(t *testing.T) {
+	// Without a cap, uploads are not limited.
+	ul := newUploadLimiter()
+	for i := 0; i < 10; i++ {
+		if w := ul.managedReserve(100); w != 0 {
+			t.Fatal("uncapped upload should not have to wait, waited", w)
+		}
+	}
+
+	// With a cap of 1000 bytes per second, the tenth transfer of 100 bytes
+	// should begin after about 0.9 seconds.
+	ul.managedSetMaxSpeed(1000)
+	var w time.Duration
+	for i := 0; i < 10; i++ {
+		w = ul.managedReserve(100)
+	}
+	if w < 800*time.Millisecond || w > 1000*time.Millisecond {
+		t.Fatal("expected uploads to be limited to the cap, waited", w)
+	}
+	if ul.managedMaxSpeed() != 1000 {
+		t.Fatal("wrong max speed:", ul.managedMaxSpeed())
+	}
+
+	// Removing the cap voids the outstanding reservations.
+	ul.managedSetMaxSpeed(0)
+	if w := ul.managedReserve(100); w != 0 {
+		t.Fatal("upload should not have to wait after the cap was removed, waited", w)
+	}
+	ul.managedSetMaxSpeed(1000)
+	if w := ul.managedReserve(100); w != 0 {
+		t.Fatal("reservations made while uncapped should not be counted, waited", w)
+	}
+}
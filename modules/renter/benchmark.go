@@ -0,0 +1,302 @@
+package renter
+
+import (
+	"errors"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/pachisi456/Sia/crypto"
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/types"
+
+	"github.com/NebulousLabs/fastrand"
+)
+
+// benchmarkSiaPath is the siapath reserved for the data uploaded by
+// Benchmark. Users cannot upload files to this siapath.
+const benchmarkSiaPath = "__benchmark__"
+
+var (
+	errBenchmarkInProgress  = errors.New("benchmark failed: another benchmark is in progress")
+	errBenchmarkMismatch    = errors.New("benchmark failed: downloaded sector does not match the uploaded sector")
+	errBenchmarkNoContract  = errors.New("benchmark failed: the renter has no contract with that host")
+	errBenchmarkTooFewHosts = errors.New("benchmark failed: at least two hosts are needed")
+	errBenchmarkZeroBytes   = errors.New("benchmark failed: cannot benchmark a host with zero bytes")
+)
+
+// Benchmark measures the upload performance of the renter by uploading 'size'
+// bytes of random data through the full upload pipeline, the same way that
+// UploadStream does. The data is erasure coded into one piece per host, using
+// the ratio of data to parity pieces of the default erasure code. The data is
+// never added to the renter's files, and is deleted from the hosts afterwards.
+func (r *Renter) Benchmark(size uint64, hosts int) (modules.UploadBenchmark, error) {
+	var report modules.UploadBenchmark
+	if err := r.tg.Add(); err != nil {
+		return report, ErrRenterShutdown
+	}
+	defer r.tg.Done()
+
+	if size == 0 {
+		return report, errBenchmarkZeroBytes
+	}
+	if hosts < 2 {
+		return report, errBenchmarkTooFewHosts
+	}
+	dataPieces := hosts * defaultDataPieces / (defaultDataPieces + defaultParityPieces)
+	if dataPieces < 1 {
+		dataPieces = 1
+	}
+	ec, err := NewRSCode(dataPieces, hosts-dataPieces)
+	if err != nil {
+		return report, err
+	}
+	if err := r.checkUploadContracts(ec); err != nil {
+		return report, err
+	}
+
+	// Reserve the siapath for the duration of the benchmark, as well as the
+	// space of the empty file. The space is reserved as the file grows, and
+	// released once the file is removed again.
+	f := newFile(benchmarkSiaPath, ec, pieceSize, 0)
+	logical, redundant := storedBytes(f)
+	id := r.mu.Lock()
+	if r.siapathInUse(benchmarkSiaPath) {
+		r.mu.Unlock(id)
+		return report, errBenchmarkInProgress
+	}
+	if err := r.reserveStorage(logical, redundant, "", false); err != nil {
+		r.mu.Unlock(id)
+		return report, err
+	}
+	r.streamingUploads[benchmarkSiaPath] = struct{}{}
+	r.mu.Unlock(id)
+	defer func() {
+		id := r.mu.Lock()
+		delete(r.streamingUploads, benchmarkSiaPath)
+		r.mu.Unlock(id)
+	}()
+
+	// Upload the data. Everything that reached the hosts is cleaned up, even
+	// if the benchmark fails.
+	defer func() {
+		r.managedRemoveStreamFile(f)
+		f.mu.RLock()
+		contracts := make(map[types.FileContractID][]crypto.Hash)
+		for _, fc := range f.contracts {
+			for _, p := range fc.Pieces {
+				contracts[fc.ID] = append(contracts[fc.ID], p.MerkleRoot)
+			}
+		}
+		f.mu.RUnlock()
+		for id, roots := range contracts {
+			r.managedDeleteBenchmarkSectors(id, roots)
+		}
+	}()
+	start := time.Now()
+	chunks, err := r.managedUploadStreamChunks(f, io.LimitReader(fastrand.Reader, int64(size)))
+	if err = r.managedWaitForStreamChunks(chunks, err); err != nil {
+		return report, err
+	}
+	report.Bytes = size
+	report.Time = time.Since(start)
+	report.Throughput = benchmarkThroughput(size, report.Time)
+
+	// Break the upload down by host.
+	timings := make(map[types.FileContractID][]pieceUploadTiming)
+	for _, uc := range chunks {
+		uc.mu.Lock()
+		for _, pt := range uc.pieceTimings {
+			timings[pt.contract] = append(timings[pt.contract], pt)
+		}
+		uc.mu.Unlock()
+	}
+	for id, pts := range timings {
+		var bytes uint64
+		var negotiationTime, transferTime time.Duration
+		for _, pt := range pts {
+			bytes += pt.bytes
+			negotiationTime += pt.negotiationTime
+			transferTime += pt.transferTime
+		}
+		host := modules.HostUploadBenchmark{
+			Pieces:     uint64(len(pts)),
+			Latency:    negotiationTime / time.Duration(len(pts)),
+			Throughput: benchmarkThroughput(bytes, transferTime),
+		}
+		if c, exists := r.hostContractor.ContractByID(id); exists {
+			host.HostPublicKey = c.HostPublicKey
+		}
+		report.Hosts = append(report.Hosts, host)
+	}
+	sort.Slice(report.Hosts, func(i, j int) bool {
+		return report.Hosts[i].HostPublicKey.String() < report.Hosts[j].HostPublicKey.String()
+	})
+	return report, nil
+}
+
+// BenchmarkHost measures the raw bandwidth of a single host by uploading
+// random sectors to it and downloading them again. Erasure coding and file
+// tracking are bypassed, so the results only reflect the network and the
+// host. The amount of data transferred is rounded up to a whole number of
+// sectors, and the sectors are deleted from the host afterwards.
+func (r *Renter) BenchmarkHost(spk types.SiaPublicKey, bytes uint64) (modules.HostBenchmark, error) {
+	var bench modules.HostBenchmark
+	if err := r.tg.Add(); err != nil {
+		return bench, ErrRenterShutdown
+	}
+	defer r.tg.Done()
+
+	if bytes == 0 {
+		return bench, errBenchmarkZeroBytes
+	}
+	contract, found := r.contractWithHost(spk)
+	if !found {
+		return bench, errBenchmarkNoContract
+	}
+	numSectors := benchmarkSectors(bytes)
+	bench.Bytes = numSectors * modules.SectorSize
+
+	// Upload the sectors. The roots are recorded as soon as each upload
+	// completes so that every sector that reached the host is cleaned up,
+	// even if the benchmark fails partway through.
+	var roots []crypto.Hash
+	defer func() {
+		r.managedDeleteBenchmarkSectors(contract.ID, roots)
+	}()
+	editor, err := r.hostContractor.Editor(contract.ID, r.tg.StopChan())
+	if err != nil {
+		return bench, err
+	}
+	start := time.Now()
+	for i := uint64(0); i < numSectors; i++ {
+		root, err := editor.Upload(fastrand.Bytes(int(modules.SectorSize)))
+		if err != nil {
+			editor.Close()
+			return bench, err
+		}
+		roots = append(roots, root)
+	}
+	bench.UploadThroughput = benchmarkThroughput(bench.Bytes, time.Since(start))
+	editor.Close()
+
+	// Download the sectors again, verifying each one against its root.
+	start = time.Now()
+	downloader, err := r.hostContractor.Downloader(contract.ID, r.tg.StopChan())
+	if err != nil {
+		return bench, err
+	}
+	defer downloader.Close()
+	bench.Latency = time.Since(start)
+	start = time.Now()
+	for _, root := range roots {
+		sector, err := downloader.Sector(root)
+		if err != nil {
+			return bench, err
+		}
+		if crypto.MerkleRoot(sector) != root {
+			return bench, errBenchmarkMismatch
+		}
+	}
+	bench.DownloadThroughput = benchmarkThroughput(bench.Bytes, time.Since(start))
+	return bench, nil
+}
+
+// MeasureHostRoundTrip measures how long a single host takes to store data
+// and return it. Each probe sector is uploaded and then immediately
+// downloaded and verified before the next one is uploaded, so that the result
+// reflects the combined store and retrieve path, including opening the
+// connections to the host. The amount of data transferred is rounded up to a
+// whole number of sectors, and the sectors are deleted from the host
+// afterwards.
+func (r *Renter) MeasureHostRoundTrip(spk types.SiaPublicKey, bytes uint64) (modules.HostRoundTrip, error) {
+	var rt modules.HostRoundTrip
+	if err := r.tg.Add(); err != nil {
+		return rt, ErrRenterShutdown
+	}
+	defer r.tg.Done()
+
+	if bytes == 0 {
+		return rt, errBenchmarkZeroBytes
+	}
+	contract, found := r.contractWithHost(spk)
+	if !found {
+		return rt, errBenchmarkNoContract
+	}
+	numSectors := benchmarkSectors(bytes)
+	rt.Bytes = numSectors * modules.SectorSize
+
+	var roots []crypto.Hash
+	defer func() {
+		r.managedDeleteBenchmarkSectors(contract.ID, roots)
+	}()
+	start := time.Now()
+	for i := uint64(0); i < numSectors; i++ {
+		// The editor has to be closed before the downloader is opened, as
+		// only one of them may revise the contract at a time.
+		editor, err := r.hostContractor.Editor(contract.ID, r.tg.StopChan())
+		if err != nil {
+			return rt, err
+		}
+		root, err := editor.Upload(fastrand.Bytes(int(modules.SectorSize)))
+		editor.Close()
+		if err != nil {
+			return rt, err
+		}
+		roots = append(roots, root)
+
+		downloader, err := r.hostContractor.Downloader(contract.ID, r.tg.StopChan())
+		if err != nil {
+			return rt, err
+		}
+		sector, err := downloader.Sector(root)
+		downloader.Close()
+		if err != nil {
+			return rt, err
+		}
+		if crypto.MerkleRoot(sector) != root {
+			return rt, errBenchmarkMismatch
+		}
+	}
+	rt.RoundTripTime = time.Since(start)
+	rt.Throughput = benchmarkThroughput(rt.Bytes, rt.RoundTripTime)
+	return rt, nil
+}
+
+// contractWithHost returns the renter's contract with the host with the given
+// public key.
+func (r *Renter) contractWithHost(spk types.SiaPublicKey) (modules.RenterContract, bool) {
+	for _, c := range r.hostContractor.Contracts() {
+		if c.HostPublicKey.String() == spk.String() {
+			return c, true
+		}
+	}
+	return modules.RenterContract{}, false
+}
+
+// managedDeleteBenchmarkSectors deletes the sectors uploaded by a benchmark
+// from the host of the given contract. Failures are logged, as the benchmark
+// has already completed.
+func (r *Renter) managedDeleteBenchmarkSectors(id types.FileContractID, roots []crypto.Hash) {
+	if err := r.managedDeleteSectors(id, roots); err != nil {
+		r.log.Println("WARN: unable to delete benchmark data from host:", err)
+	}
+}
+
+// benchmarkSectors returns the number of sectors needed to transfer 'bytes'
+// bytes.
+func benchmarkSectors(bytes uint64) uint64 {
+	numSectors := bytes / modules.SectorSize
+	if bytes%modules.SectorSize != 0 {
+		numSectors++
+	}
+	return numSectors
+}
+
+// benchmarkThroughput returns the number of bytes transferred per second.
+func benchmarkThroughput(bytes uint64, d time.Duration) uint64 {
+	if d <= 0 {
+		d = time.Nanosecond
+	}
+	return uint64(float64(bytes) / d.Seconds())
+}
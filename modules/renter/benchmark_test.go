@@ -0,0 +1,240 @@
+package renter
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pachisi456/Sia/crypto"
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/modules/renter/contractor"
+	"github.com/pachisi456/Sia/types"
+)
+
+// mockHost is a contractor.Editor and contractor.Downloader that stores
+// sectors in memory, taking a short time for each transfer.
+type mockHost struct {
+	sectors map[crypto.Hash][]byte
+	mu      sync.Mutex
+}
+
+func (mh *mockHost) Upload(data []byte) (crypto.Hash, error) {
+	time.Sleep(10 * time.Millisecond)
+	mh.mu.Lock()
+	defer mh.mu.Unlock()
+	root := crypto.MerkleRoot(data)
+	mh.sectors[root] = data
+	return root, nil
+}
+func (mh *mockHost) Sector(root crypto.Hash) ([]byte, error) {
+	time.Sleep(10 * time.Millisecond)
+	mh.mu.Lock()
+	defer mh.mu.Unlock()
+	sector, ok := mh.sectors[root]
+	if !ok {
+		return nil, errors.New("no such sector")
+	}
+	return sector, nil
+}
+func (mh *mockHost) Delete(root crypto.Hash) error {
+	mh.mu.Lock()
+	defer mh.mu.Unlock()
+	delete(mh.sectors, root)
+	return nil
+}
+func (mh *mockHost) Modify(crypto.Hash, crypto.Hash, uint64, []byte) error { return nil }
+func (mh *mockHost) Address() modules.NetAddress                           { return "" }
+func (mh *mockHost) ContractID() types.FileContractID                      { return types.FileContractID{} }
+func (mh *mockHost) EndHeight() types.BlockHeight                          { return 0 }
+func (mh *mockHost) Close() error                                          { return nil }
+
+// benchmarkContractor is a hostContractor with a single contract with a
+// mockHost.
+type benchmarkContractor struct {
+	hostContractor
+	contract modules.RenterContract
+	host     *mockHost
+}
+
+func (bc *benchmarkContractor) Contracts() []modules.RenterContract {
+	return []modules.RenterContract{bc.contract}
+}
+func (bc *benchmarkContractor) Editor(types.FileContractID, <-chan struct{}) (contractor.Editor, error) {
+	return bc.host, nil
+}
+func (bc *benchmarkContractor) Downloader(types.FileContractID, <-chan struct{}) (contractor.Downloader, error) {
+	return bc.host, nil
+}
+
+// TestBenchmarkHost checks that benchmarking a host reports nonzero
+// throughput and removes the benchmark data from the host.
+func TestBenchmarkHost(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	bc := &benchmarkContractor{
+		hostContractor: rt.renter.hostContractor,
+		contract: modules.RenterContract{
+			ID:            types.FileContractID{1},
+			HostPublicKey: types.SiaPublicKey{Key: []byte("foo")},
+		},
+		host: &mockHost{sectors: make(map[crypto.Hash][]byte)},
+	}
+	id := rt.renter.mu.Lock()
+	rt.renter.hostContractor = bc
+	rt.renter.mu.Unlock(id)
+
+	// Benchmark with an amount of data that is not a multiple of the sector
+	// size.
+	bench, err := rt.renter.BenchmarkHost(bc.contract.HostPublicKey, 2*modules.SectorSize+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bench.Bytes != 3*modules.SectorSize {
+		t.Fatal("expected the benchmark to transfer 3 sectors, got", bench.Bytes, "bytes")
+	}
+	if bench.UploadThroughput == 0 || bench.DownloadThroughput == 0 {
+		t.Fatal("expected nonzero throughput, got", bench)
+	}
+	bc.host.mu.Lock()
+	remaining := len(bc.host.sectors)
+	bc.host.mu.Unlock()
+	if remaining != 0 {
+		t.Fatal("benchmark left", remaining, "sectors on the host")
+	}
+
+	// Hosts without a contract and empty benchmarks are rejected.
+	if _, err := rt.renter.BenchmarkHost(types.SiaPublicKey{Key: []byte("bar")}, modules.SectorSize); err != errBenchmarkNoContract {
+		t.Fatal("expected errBenchmarkNoContract, got", err)
+	}
+	if _, err := rt.renter.BenchmarkHost(bc.contract.HostPublicKey, 0); err != errBenchmarkZeroBytes {
+		t.Fatal("expected errBenchmarkZeroBytes, got", err)
+	}
+}
+
+// TestMeasureHostRoundTrip checks that measuring the round trip to a host
+// reports nonzero timing and removes the probe data from the host.
+func TestMeasureHostRoundTrip(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	bc := &benchmarkContractor{
+		hostContractor: rt.renter.hostContractor,
+		contract: modules.RenterContract{
+			ID:            types.FileContractID{1},
+			HostPublicKey: types.SiaPublicKey{Key: []byte("foo")},
+		},
+		host: &mockHost{sectors: make(map[crypto.Hash][]byte)},
+	}
+	id := rt.renter.mu.Lock()
+	rt.renter.hostContractor = bc
+	rt.renter.mu.Unlock(id)
+
+	roundTrip, err := rt.renter.MeasureHostRoundTrip(bc.contract.HostPublicKey, modules.SectorSize+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if roundTrip.Bytes != 2*modules.SectorSize {
+		t.Fatal("expected the round trip to transfer 2 sectors, got", roundTrip.Bytes, "bytes")
+	}
+	if roundTrip.RoundTripTime == 0 || roundTrip.Throughput == 0 {
+		t.Fatal("expected nonzero timing, got", roundTrip)
+	}
+	bc.host.mu.Lock()
+	remaining := len(bc.host.sectors)
+	bc.host.mu.Unlock()
+	if remaining != 0 {
+		t.Fatal("round trip left", remaining, "sectors on the host")
+	}
+
+	// Hosts without a contract are rejected.
+	if _, err := rt.renter.MeasureHostRoundTrip(types.SiaPublicKey{Key: []byte("bar")}, modules.SectorSize); err != errBenchmarkNoContract {
+		t.Fatal("expected errBenchmarkNoContract, got", err)
+	}
+}
+
+// TestBenchmark checks that the upload benchmark reports the throughput of
+// the upload and of each host, and that it leaves no data behind.
+func TestBenchmark(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	sc := streamContractor{&dedupContractor{
+		hostContractor: rt.renter.hostContractor,
+		contract: modules.RenterContract{
+			ID:            types.FileContractID{1},
+			HostPublicKey: types.SiaPublicKey{Key: []byte("foo")},
+			GoodForUpload: true,
+		},
+		host: &mockHost{sectors: make(map[crypto.Hash][]byte)},
+	}}
+	id := rt.renter.mu.Lock()
+	rt.renter.hostContractor = sc
+	rt.renter.mu.Unlock(id)
+
+	// Upload one and a half chunks. Only one of the two pieces of each chunk
+	// can be stored, as there is only one host.
+	report, err := rt.renter.Benchmark(pieceSize*3/2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Bytes != pieceSize*3/2 || report.Time == 0 || report.Throughput == 0 {
+		t.Fatal("benchmark report is missing its summary:", report)
+	}
+	if len(report.Hosts) != 1 {
+		t.Fatal("expected 1 host in the report, got", len(report.Hosts))
+	}
+	host := report.Hosts[0]
+	if host.HostPublicKey.String() != sc.contract.HostPublicKey.String() || host.Pieces != 2 || host.Throughput == 0 {
+		t.Fatal("wrong host report:", host)
+	}
+
+	// The benchmark data should be gone.
+	sc.host.mu.Lock()
+	remaining := len(sc.host.sectors)
+	sc.host.mu.Unlock()
+	if remaining != 0 {
+		t.Fatal("benchmark left", remaining, "sectors on the host")
+	}
+	if len(rt.renter.FileList()) != 0 {
+		t.Fatal("benchmark data was added to the renter's files")
+	}
+	if _, err := os.Stat(filepath.Join(rt.renter.persistDir, benchmarkSiaPath+ShareExtension)); !os.IsNotExist(err) {
+		t.Fatal("benchmark left its .sia file behind:", err)
+	}
+	id = rt.renter.mu.RLock()
+	stored, storedRedundant := rt.renter.storedBytes, rt.renter.storedRedundantBytes
+	rt.renter.mu.RUnlock(id)
+	if stored != 0 || storedRedundant != 0 {
+		t.Fatal("benchmark did not release its storage:", stored, storedRedundant)
+	}
+
+	// Invalid benchmarks are rejected.
+	if _, err := rt.renter.Benchmark(0, 2); err != errBenchmarkZeroBytes {
+		t.Fatal("expected errBenchmarkZeroBytes, got", err)
+	}
+	if _, err := rt.renter.Benchmark(pieceSize, 1); err != errBenchmarkTooFewHosts {
+		t.Fatal("expected errBenchmarkTooFewHosts, got", err)
+	}
+}
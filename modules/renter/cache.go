@@ -0,0 +1,404 @@
+package renter
+
+// The renter keeps a cache of recently recovered chunks. Every chunk that is
+// recovered by the download loop is added to the cache, and downloads of
+// cached chunks are served from the cache without contacting any hosts. The
+// least recently used chunks are evicted once the cache exceeds its capacity.
+// The cache is disabled by default, and can be enabled by setting
+// DownloadCacheSize.
+//
+// Cached chunks are accounted for by the renter's memory manager. The cache
+// only grows while memory is available, and gives up its least recently used
+// chunks when the memory is needed for uploads or repairs.
+//
+// Optionally, chunks that are evicted from memory are moved to a second tier
+// on disk, which is enabled by setting DownloadCacheDiskSize. The disk tier
+// holds decrypted file data, and is cleared when the renter starts.
+
+import (
+	"container/list"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pachisi456/Sia/crypto"
+)
+
+const (
+	// chunkCacheDir is the directory within the renter's persist directory
+	// that holds the disk tier of the chunk cache.
+	chunkCacheDir = "chunkcache"
+)
+
+type (
+	// chunkCacheKey identifies a chunk in the chunk cache. The master key of a
+	// file is unique to that file, so it is used to identify the file instead
+	// of the siapath, which may change.
+	chunkCacheKey struct {
+		masterKey crypto.TwofishKey
+		index     uint64
+	}
+
+	// chunkCacheEntry is a single chunk stored in the chunk cache.
+	chunkCacheEntry struct {
+		key  chunkCacheKey
+		data []byte
+	}
+
+	// chunkCache is a least-recently-used cache of recovered chunks. The
+	// total size of the cached chunks never exceeds 'capacity' bytes.
+	chunkCache struct {
+		capacity uint64
+		size     uint64
+		entries  map[chunkCacheKey]*list.Element
+		lru      *list.List
+
+		// reserve and release account the memory of cached chunks with the
+		// renter's memory manager. If reserve returns false, the chunk is not
+		// cached. Either may be nil, in which case memory is not accounted.
+		reserve func(uint64) bool
+		release func(uint64)
+
+		// disk receives the chunks that are evicted from memory. It may be
+		// nil.
+		disk *diskChunkCache
+
+		mu sync.Mutex
+	}
+
+	// diskChunkCache is a least-recently-used cache of recovered chunks that
+	// are stored as files in 'dir'. The entries map to the size of each
+	// chunk. The total size of the cached chunks never exceeds 'capacity'
+	// bytes.
+	diskChunkCache struct {
+		dir      string
+		capacity uint64
+		size     uint64
+		entries  map[chunkCacheKey]*list.Element
+		lru      *list.List
+		mu       sync.Mutex
+	}
+
+	// diskChunkCacheEntry is a single chunk stored in the disk tier.
+	diskChunkCacheEntry struct {
+		key  chunkCacheKey
+		size uint64
+	}
+
+	// errChunksNotCached is returned by DownloadCachedOnly when some of the
+	// chunks of a file are not in the cache. It lists the missing chunks.
+	errChunksNotCached []uint64
+)
+
+// Error implements the error interface.
+func (e errChunksNotCached) Error() string {
+	return fmt.Sprintf("chunks not found in cache: %v", []uint64(e))
+}
+
+// newChunkCache returns an empty chunk cache with a capacity of zero, meaning
+// that no chunks are cached.
+func newChunkCache() *chunkCache {
+	return &chunkCache{
+		entries: make(map[chunkCacheKey]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// newDiskChunkCache returns an empty disk tier that stores its chunks in dir.
+// Its capacity is zero, meaning that no chunks are stored.
+func newDiskChunkCache(dir string) *diskChunkCache {
+	return &diskChunkCache{
+		dir:     dir,
+		entries: make(map[chunkCacheKey]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// evict removes chunks, starting with the least recently used, until the
+// cache holds at most 'target' bytes. The evicted chunks are returned, so
+// that they can be handed to the disk tier once the lock is released.
+func (cc *chunkCache) evict(target uint64) []*chunkCacheEntry {
+	var evicted []*chunkCacheEntry
+	for cc.size > target {
+		e := cc.lru.Back()
+		entry := e.Value.(*chunkCacheEntry)
+		cc.lru.Remove(e)
+		delete(cc.entries, entry.key)
+		cc.size -= uint64(len(entry.data))
+		evicted = append(evicted, entry)
+	}
+	return evicted
+}
+
+// managedRetire moves evicted chunks to the disk tier and releases their
+// memory. The cache's lock must not be held.
+func (cc *chunkCache) managedRetire(evicted []*chunkCacheEntry) {
+	var freed uint64
+	for _, entry := range evicted {
+		if cc.disk != nil {
+			cc.disk.managedAdd(entry.key, entry.data)
+		}
+		freed += uint64(len(entry.data))
+	}
+	if freed > 0 && cc.release != nil {
+		cc.release(freed)
+	}
+}
+
+// managedAdd adds a chunk to the cache. Chunks that are larger than the
+// capacity of the cache, or that the memory manager has no memory for, are
+// not added.
+func (cc *chunkCache) managedAdd(key chunkCacheKey, data []byte) {
+	size := uint64(len(data))
+	if size > cc.managedCapacity() {
+		return
+	}
+	if cc.reserve != nil && !cc.reserve(size) {
+		return
+	}
+
+	cc.mu.Lock()
+	var evicted []*chunkCacheEntry
+	if e, exists := cc.entries[key]; exists {
+		// The memory of the old data is released with the evicted chunks.
+		entry := e.Value.(*chunkCacheEntry)
+		cc.size -= uint64(len(entry.data))
+		evicted = append(evicted, &chunkCacheEntry{key: key, data: entry.data})
+		entry.data = data
+		cc.size += size
+		cc.lru.MoveToFront(e)
+	} else {
+		cc.entries[key] = cc.lru.PushFront(&chunkCacheEntry{key: key, data: data})
+		cc.size += size
+	}
+	evicted = append(evicted, cc.evict(cc.capacity)...)
+	cc.mu.Unlock()
+	cc.managedRetire(evicted)
+}
+
+// managedGet returns the cached data of a chunk, marking the chunk as recently
+// used. Chunks that are only found in the disk tier are moved back into
+// memory if possible.
+func (cc *chunkCache) managedGet(key chunkCacheKey) ([]byte, bool) {
+	cc.mu.Lock()
+	e, exists := cc.entries[key]
+	if exists {
+		cc.lru.MoveToFront(e)
+		data := e.Value.(*chunkCacheEntry).data
+		cc.mu.Unlock()
+		return data, true
+	}
+	cc.mu.Unlock()
+
+	if cc.disk == nil {
+		return nil, false
+	}
+	data, exists := cc.disk.managedGet(key)
+	if !exists {
+		return nil, false
+	}
+	cc.managedAdd(key, data)
+	return data, true
+}
+
+// managedReclaim evicts the least recently used chunks from memory until at
+// least 'amt' bytes have been released, or the cache is empty.
+func (cc *chunkCache) managedReclaim(amt uint64) {
+	cc.mu.Lock()
+	target := uint64(0)
+	if cc.size > amt {
+		target = cc.size - amt
+	}
+	evicted := cc.evict(target)
+	cc.mu.Unlock()
+	cc.managedRetire(evicted)
+}
+
+// managedCapacity returns the capacity of the cache in bytes.
+func (cc *chunkCache) managedCapacity() uint64 {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return cc.capacity
+}
+
+// managedSetCapacity sets the capacity of the cache in bytes, evicting chunks
+// if the cache no longer fits. A capacity of zero disables the cache.
+func (cc *chunkCache) managedSetCapacity(capacity uint64) {
+	cc.mu.Lock()
+	cc.capacity = capacity
+	evicted := cc.evict(capacity)
+	cc.mu.Unlock()
+	cc.managedRetire(evicted)
+}
+
+// managedDiskCapacity returns the capacity of the disk tier in bytes.
+func (cc *chunkCache) managedDiskCapacity() uint64 {
+	if cc.disk == nil {
+		return 0
+	}
+	return cc.disk.managedCapacity()
+}
+
+// managedValidateDiskCapacity returns the error that managedSetDiskCapacity
+// would return for 'capacity', without changing the capacity.
+func (cc *chunkCache) managedValidateDiskCapacity(capacity uint64) error {
+	if cc.disk == nil || capacity == 0 {
+		return nil
+	}
+	return os.MkdirAll(cc.disk.dir, 0700)
+}
+
+// managedSetDiskCapacity sets the capacity of the disk tier in bytes. A
+// capacity of zero disables the disk tier.
+func (cc *chunkCache) managedSetDiskCapacity(capacity uint64) error {
+	if cc.disk == nil {
+		return nil
+	}
+	return cc.disk.managedSetCapacity(capacity)
+}
+
+// path returns the path of the file that holds a chunk. The file name is
+// derived from a hash of the key, so that the master key of the file is not
+// stored on disk.
+func (dc *diskChunkCache) path(key chunkCacheKey) string {
+	return filepath.Join(dc.dir, crypto.HashAll(key.masterKey, key.index).String())
+}
+
+// evict removes chunks, starting with the least recently used, until the
+// disk tier fits within its capacity.
+func (dc *diskChunkCache) evict() {
+	for dc.size > dc.capacity {
+		e := dc.lru.Back()
+		entry := e.Value.(*diskChunkCacheEntry)
+		dc.lru.Remove(e)
+		delete(dc.entries, entry.key)
+		dc.size -= entry.size
+		os.Remove(dc.path(entry.key))
+	}
+}
+
+// managedAdd writes a chunk to the disk tier. Chunks that are larger than the
+// capacity of the disk tier are not added.
+func (dc *diskChunkCache) managedAdd(key chunkCacheKey, data []byte) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	if uint64(len(data)) > dc.capacity {
+		return
+	}
+	if e, exists := dc.entries[key]; exists {
+		// The data of a chunk never changes, so it does not need to be
+		// written again.
+		dc.lru.MoveToFront(e)
+		return
+	}
+	if err := ioutil.WriteFile(dc.path(key), data, 0600); err != nil {
+		os.Remove(dc.path(key))
+		return
+	}
+	dc.entries[key] = dc.lru.PushFront(&diskChunkCacheEntry{key: key, size: uint64(len(data))})
+	dc.size += uint64(len(data))
+	dc.evict()
+}
+
+// managedGet reads a chunk from the disk tier, marking the chunk as recently
+// used. Chunks that cannot be read are removed from the disk tier.
+func (dc *diskChunkCache) managedGet(key chunkCacheKey) ([]byte, bool) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	e, exists := dc.entries[key]
+	if !exists {
+		return nil, false
+	}
+	entry := e.Value.(*diskChunkCacheEntry)
+	data, err := ioutil.ReadFile(dc.path(key))
+	if err != nil || uint64(len(data)) != entry.size {
+		dc.lru.Remove(e)
+		delete(dc.entries, key)
+		dc.size -= entry.size
+		os.Remove(dc.path(key))
+		return nil, false
+	}
+	dc.lru.MoveToFront(e)
+	return data, true
+}
+
+// managedCapacity returns the capacity of the disk tier in bytes.
+func (dc *diskChunkCache) managedCapacity() uint64 {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	return dc.capacity
+}
+
+// managedSetCapacity sets the capacity of the disk tier in bytes, removing
+// chunks if the disk tier no longer fits.
+func (dc *diskChunkCache) managedSetCapacity(capacity uint64) error {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	if capacity > 0 {
+		if err := os.MkdirAll(dc.dir, 0700); err != nil {
+			return err
+		}
+	}
+	dc.capacity = capacity
+	dc.evict()
+	return nil
+}
+
+// DownloadCachedOnly writes a file to dst using only chunks from the renter's
+// chunk cache, without contacting any hosts. If any chunk of the file is not
+// cached, nothing is written and an error naming the missing chunks is
+// returned.
+func (r *Renter) DownloadCachedOnly(nickname string, dst io.Writer) error {
+	if err := r.tg.Add(); err != nil {
+		return ErrRenterShutdown
+	}
+	defer r.tg.Done()
+
+	lockID := r.mu.RLock()
+	f, exists := r.files[nickname]
+	r.mu.RUnlock(lockID)
+	if !exists {
+		return ErrUnknownPath
+	}
+	f.mu.RLock()
+	masterKey, size, numChunks, chunkSize := f.masterKey, f.size, f.numChunks(), f.chunkSize()
+	f.mu.RUnlock()
+	if size == 0 {
+		return nil
+	}
+
+	// Grab every chunk before writing anything, so that a file with missing
+	// chunks leaves dst untouched.
+	var missing errChunksNotCached
+	chunks := make([][]byte, numChunks)
+	for i := range chunks {
+		data, exists := r.chunkCache.managedGet(chunkCacheKey{masterKey, uint64(i)})
+		if !exists {
+			missing = append(missing, uint64(i))
+			continue
+		}
+		chunks[i] = data
+	}
+	if len(missing) > 0 {
+		return missing
+	}
+
+	// Write the chunks, trimming the last chunk to the size of the file.
+	remaining := size
+	for _, data := range chunks {
+		if uint64(len(data)) > remaining {
+			data = data[:remaining]
+		}
+		if uint64(len(data)) < remaining && uint64(len(data)) < chunkSize {
+			return fmt.Errorf("cached chunk is too small: %v bytes", len(data))
+		}
+		if _, err := dst.Write(data); err != nil {
+			return err
+		}
+		remaining -= uint64(len(data))
+	}
+	return nil
+}
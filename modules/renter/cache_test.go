@@ -0,0 +1,268 @@
+package renter
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pachisi456/Sia/build"
+	"github.com/pachisi456/Sia/crypto"
+	"github.com/pachisi456/Sia/modules"
+
+	"github.com/NebulousLabs/fastrand"
+)
+
+// TestChunkCache checks that the chunk cache evicts the least recently used
+// chunks once it exceeds its capacity.
+func TestChunkCache(t *testing.T) {
+	cc := newChunkCache()
+	key := crypto.GenerateTwofishKey()
+
+	// A disabled cache should not store anything.
+	cc.managedAdd(chunkCacheKey{key, 0}, make([]byte, 10))
+	if _, exists := cc.managedGet(chunkCacheKey{key, 0}); exists {
+		t.Fatal("disabled cache stored a chunk")
+	}
+
+	// Fill the cache, then use the first chunk so that the second chunk is
+	// the least recently used.
+	cc.managedSetCapacity(30)
+	for i := uint64(0); i < 3; i++ {
+		cc.managedAdd(chunkCacheKey{key, i}, make([]byte, 10))
+	}
+	if _, exists := cc.managedGet(chunkCacheKey{key, 0}); !exists {
+		t.Fatal("chunk 0 should be cached")
+	}
+	cc.managedAdd(chunkCacheKey{key, 3}, make([]byte, 10))
+	if _, exists := cc.managedGet(chunkCacheKey{key, 1}); exists {
+		t.Fatal("least recently used chunk was not evicted")
+	}
+	for _, i := range []uint64{0, 2, 3} {
+		if _, exists := cc.managedGet(chunkCacheKey{key, i}); !exists {
+			t.Fatal("chunk should be cached:", i)
+		}
+	}
+
+	// Shrinking the cache should evict chunks.
+	cc.managedSetCapacity(10)
+	if cc.size != 10 || len(cc.entries) != 1 || cc.lru.Len() != 1 {
+		t.Fatal("cache was not shrunk:", cc.size, len(cc.entries), cc.lru.Len())
+	}
+}
+
+// TestRenterDownloadCachedOnly checks that a fully cached file can be
+// downloaded without contacting any hosts, and that a partially cached file
+// returns an error naming the missing chunks.
+func TestRenterDownloadCachedOnly(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Add a file spanning three chunks to the renter. The renter has no
+	// hosts, so the file can only be served from the cache.
+	rsc, _ := NewRSCode(1, 1)
+	f := newFile("cached", rsc, 64, 150)
+	id := rt.renter.mu.Lock()
+	rt.renter.files[f.name] = f
+	rt.renter.mu.Unlock(id)
+	data := fastrand.Bytes(int(f.size))
+
+	settings := rt.renter.Settings()
+	settings.DownloadCacheSize = 1 << 20
+	if err := rt.renter.SetSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+
+	// Cache only the first two chunks.
+	rt.renter.chunkCache.managedAdd(chunkCacheKey{f.masterKey, 0}, data[:64])
+	rt.renter.chunkCache.managedAdd(chunkCacheKey{f.masterKey, 1}, data[64:128])
+	var buf bytes.Buffer
+	err = rt.renter.DownloadCachedOnly("cached", &buf)
+	missing, ok := err.(errChunksNotCached)
+	if !ok || len(missing) != 1 || missing[0] != 2 {
+		t.Fatal("expected chunk 2 to be reported as missing, got", err)
+	}
+	if buf.Len() != 0 {
+		t.Fatal("partially cached download wrote data")
+	}
+
+	// Cache the last chunk. The chunk is padded like a recovered chunk would
+	// be, and should be trimmed to the size of the file.
+	rt.renter.chunkCache.managedAdd(chunkCacheKey{f.masterKey, 2}, append(append([]byte(nil), data[128:]...), make([]byte, 42)...))
+	if err := rt.renter.DownloadCachedOnly("cached", &buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Fatal("cached download does not match the original data")
+	}
+}
+
+// TestChunkCacheMemory checks that cached chunks are accounted for by the
+// memory manager, and that the cache gives up its memory when it is needed
+// elsewhere.
+func TestChunkCacheMemory(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+	r := rt.renter
+	total := r.MemoryBreakdown().Total
+	r.chunkCache.managedSetCapacity(total)
+
+	key := crypto.GenerateTwofishKey()
+	r.chunkCache.managedAdd(chunkCacheKey{key, 0}, make([]byte, 1000))
+	if mb := r.MemoryBreakdown(); mb.ChunkCache != 1000 || mb.Available != total-1000 {
+		t.Fatal("cached chunk was not accounted for:", mb)
+	}
+
+	// Memory held by the cache counts as available, and is reclaimed once it
+	// is allocated.
+	if avail := r.managedMemoryAvailableGet(memoryClassUserUpload); avail != total {
+		t.Fatal("expected all memory to be available, got", avail)
+	}
+	r.managedMemoryAvailableSub(total, memoryEncoding, memoryClassUserUpload)
+	if _, exists := r.chunkCache.managedGet(chunkCacheKey{key, 0}); exists {
+		t.Fatal("chunk was not evicted when its memory was needed")
+	}
+	if mb := r.MemoryBreakdown(); mb.ChunkCache != 0 || mb.Encoding != total || mb.Available != 0 {
+		t.Fatal("memory was not reclaimed from the cache:", mb)
+	}
+
+	// Without available memory, chunks are not cached.
+	r.chunkCache.managedAdd(chunkCacheKey{key, 1}, make([]byte, 1000))
+	if _, exists := r.chunkCache.managedGet(chunkCacheKey{key, 1}); exists {
+		t.Fatal("chunk was cached without available memory")
+	}
+	r.managedMemoryAvailableAdd(total, memoryEncoding, memoryClassUserUpload)
+}
+
+// TestChunkCacheDisk checks that chunks evicted from memory are moved to the
+// disk tier, and can be read back from it.
+func TestChunkCacheDisk(t *testing.T) {
+	dir := build.TempDir("renter", t.Name())
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	cc := newChunkCache()
+	cc.disk = newDiskChunkCache(dir)
+	cc.managedSetCapacity(10)
+	if err := cc.managedSetDiskCapacity(20); err != nil {
+		t.Fatal(err)
+	}
+
+	// Add three chunks. Only the last one fits in memory, and the others are
+	// moved to disk.
+	key := crypto.GenerateTwofishKey()
+	chunks := [][]byte{fastrand.Bytes(10), fastrand.Bytes(10), fastrand.Bytes(10)}
+	for i, data := range chunks {
+		cc.managedAdd(chunkCacheKey{key, uint64(i)}, data)
+	}
+	if len(cc.entries) != 1 || len(cc.disk.entries) != 2 {
+		t.Fatal("wrong number of chunks in memory and on disk:", len(cc.entries), len(cc.disk.entries))
+	}
+	// Read the chunk in memory, then a chunk from disk, which moves it back
+	// into memory.
+	for _, i := range []uint64{2, 1} {
+		cached, exists := cc.managedGet(chunkCacheKey{key, i})
+		if !exists || !bytes.Equal(cached, chunks[i]) {
+			t.Fatal("chunk was not cached:", i)
+		}
+	}
+	if _, exists := cc.entries[chunkCacheKey{key, 1}]; !exists {
+		t.Fatal("chunk read from disk was not moved into memory")
+	}
+
+	// Disabling the disk tier should remove its files.
+	if err := cc.managedSetDiskCapacity(0); err != nil {
+		t.Fatal(err)
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 0 || len(cc.disk.entries) != 0 {
+		t.Fatal("disk tier was not cleared:", len(files), len(cc.disk.entries))
+	}
+}
+
+// TestDownloadFromChunkCache checks that repeated downloads of a file are
+// served from the chunk cache without contacting any hosts.
+func TestDownloadFromChunkCache(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	rsc, _ := NewRSCode(1, 1)
+	f := newFile("foo", rsc, 64, 64*4)
+	cc := &countingContractor{verifyContractor: newVerifyContractor(rt.renter.hostContractor, f)}
+	for chunk := uint64(0); chunk < f.numChunks(); chunk++ {
+		cc.uploadChunk(t, f, chunk, fastrand.Bytes(int(f.chunkSize())))
+	}
+	id := rt.renter.mu.Lock()
+	rt.renter.hostContractor = cc
+	rt.renter.files[f.name] = f
+	rt.renter.mu.Unlock(id)
+
+	settings := rt.renter.Settings()
+	settings.DownloadCacheSize = 1 << 20
+	if err := rt.renter.SetSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+
+	// download downloads the file and returns the number of sectors that were
+	// fetched from the hosts.
+	download := func(name string) (modules.DownloadResult, uint64) {
+		atomic.StoreUint64(&cc.verified, 0)
+		atomic.StoreUint64(&cc.unverified, 0)
+		result, err := rt.renter.DownloadWithResult(modules.RenterDownloadParameters{
+			Siapath:     f.name,
+			Destination: build.TempDir("renter", t.Name(), name),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result, atomic.LoadUint64(&cc.verified) + atomic.LoadUint64(&cc.unverified)
+	}
+	if _, fetched := download("first"); fetched == 0 {
+		t.Fatal("first download did not contact any hosts")
+	}
+	result, fetched := download("second")
+	if fetched != 0 {
+		t.Fatal("second download fetched", fetched, "sectors from hosts")
+	}
+	if len(result.Chunks) != int(f.numChunks()) {
+		t.Fatal("wrong number of chunk results:", len(result.Chunks))
+	}
+	for _, cr := range result.Chunks {
+		if !cr.Cached || len(cr.Hosts) != 0 {
+			t.Fatal("chunk was not served from the cache:", cr)
+		}
+	}
+	first, err := ioutil.ReadFile(build.TempDir("renter", t.Name(), "first"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := ioutil.ReadFile(build.TempDir("renter", t.Name(), "second"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first, second) {
+		t.Fatal("cached download does not match the original download")
+	}
+}
@@ -6,7 +6,37 @@ import (
 	"github.com/pachisi456/Sia/build"
 )
 
+const (
+	// blocksPerMonth is the expected number of blocks in a month.
+	blocksPerMonth = 4320
+
+	// defaultDownloadFairness determines whether the MaxDownloadSpeed is
+	// divided evenly between concurrent downloads by default.
+	defaultDownloadFairness = true
+
+	// defaultWorkersPerContract is the number of workers that upload to each
+	// contract by default.
+	defaultWorkersPerContract = 1
+
+	// slowHostThroughputRatio determines which hosts are considered slow by
+	// the upload loop. A host is slow if its recent upload throughput is less
+	// than this fraction of the throughput of the fastest host.
+	slowHostThroughputRatio = 0.5
+
+	// sampledVerificationRate is the inverse of the fraction of sectors that
+	// are verified when the download verification level is VerifySampled.
+	sampledVerificationRate = 8
+)
+
 var (
+	// bandwidthMeasurementWindow is the amount of time over which foreground
+	// traffic is averaged when deciding whether to throttle repair traffic.
+	bandwidthMeasurementWindow = build.Select(build.Var{
+		Dev:      10 * time.Second,
+		Standard: 30 * time.Second,
+		Testing:  3 * time.Second,
+	}).(time.Duration)
+
 	// chunkDownloadTimeout defines the maximum amount of time to wait for a
 	// chunk download to finish before returning in the download-to-upload repair
 	// loop
@@ -26,6 +56,58 @@ var (
 		Testing:  uint64(1 << 17),     // 128 KiB - 4 KiB sector size, need to test memory exhaustion
 	}).(uint64)
 
+	// defaultRepairThrottleThreshold is the default amount of foreground
+	// traffic, in bytes per second, at which repair traffic is throttled down
+	// to defaultRepairThrottledBandwidth.
+	defaultRepairThrottleThreshold = build.Select(build.Var{
+		Dev:      uint64(1 << 20), // 1 MiB/s
+		Standard: uint64(1 << 22), // 4 MiB/s
+		Testing:  uint64(1 << 16), // 64 KiB/s
+	}).(uint64)
+
+	// defaultRepairThrottledBandwidth is the default amount of bandwidth, in
+	// bytes per second, that repair is allowed to consume while the
+	// foreground traffic is at or above the repair throttle threshold.
+	defaultRepairThrottledBandwidth = build.Select(build.Var{
+		Dev:      uint64(1 << 18), // 256 KiB/s
+		Standard: uint64(1 << 20), // 1 MiB/s
+		Testing:  uint64(1 << 14), // 16 KiB/s
+	}).(uint64)
+
+	// defaultLogDedupWindow is the default amount of time for which repeated
+	// identical log messages are collapsed into a single entry.
+	defaultLogDedupWindow = build.Select(build.Var{
+		Dev:      10 * time.Second,
+		Standard: time.Minute,
+		Testing:  time.Second,
+	}).(time.Duration)
+
+	// downloadIncompletePollInterval is how often a download that is waiting
+	// for a file to finish uploading checks whether enough pieces exist.
+	downloadIncompletePollInterval = build.Select(build.Var{
+		Dev:      5 * time.Second,
+		Standard: 30 * time.Second,
+		Testing:  100 * time.Millisecond,
+	}).(time.Duration)
+
+	// downloadMemoryPollInterval is how often the download loop checks
+	// whether memory has become available for the next chunk when it has
+	// nothing else to wait on.
+	downloadMemoryPollInterval = build.Select(build.Var{
+		Dev:      100 * time.Millisecond,
+		Standard: 250 * time.Millisecond,
+		Testing:  10 * time.Millisecond,
+	}).(time.Duration)
+
+	// downloadShareIdleTimeout is the amount of time that a download may go
+	// without reserving download bandwidth before its share of the
+	// MaxDownloadSpeed is redistributed among the other downloads.
+	downloadShareIdleTimeout = build.Select(build.Var{
+		Dev:      1 * time.Second,
+		Standard: 3 * time.Second,
+		Testing:  250 * time.Millisecond,
+	}).(time.Duration)
+
 	// Limit the number of doublings to prevent overflows.
 	maxConsecutivePenalty = build.Select(build.Var{
 		Dev:      4,
@@ -34,8 +116,8 @@ var (
 	}).(int)
 
 	// maxScheduledDownloads specifies the number of chunks that can be downloaded
-	// for auto repair at once. If the limit is reached new ones will only be scheduled
-	// once old ones are scheduled for upload
+	// for auto repair at once. If the limit is reached new ones will only be
+	// downloaded once old ones have finished downloading.
 	maxScheduledDownloads = build.Select(build.Var{
 		Dev:      5,
 		Standard: 10,
@@ -50,10 +132,85 @@ var (
 		Testing:  3 * time.Second,
 	}).(time.Duration)
 
+	// repairCheckpointSaveInterval is the minimum amount of time between two
+	// saves of the repair checkpoints. Chunks that are repaired in between
+	// are only checkpointed in memory until the next save.
+	repairCheckpointSaveInterval = build.Select(build.Var{
+		Dev:      30 * time.Second,
+		Standard: 5 * time.Minute,
+		Testing:  time.Minute,
+	}).(time.Duration)
+
+	// selfTestTimeout is the amount of time that the self test waits for its
+	// probe file to be uploaded and downloaded before giving up.
+	selfTestTimeout = build.Select(build.Var{
+		Dev:      2 * time.Minute,
+		Standard: 10 * time.Minute,
+		Testing:  time.Minute,
+	}).(time.Duration)
+
+	// shutdownDrainTimeout is the maximum amount of time that Close waits for
+	// the chunks that are being uploaded to finish before the workers are
+	// stopped.
+	shutdownDrainTimeout = build.Select(build.Var{
+		Dev:      10 * time.Second,
+		Standard: time.Minute,
+		Testing:  500 * time.Millisecond,
+	}).(time.Duration)
+
+	// slowHostDeferral is the amount of time for which slow hosts leave the
+	// pieces of a new chunk to faster hosts. Afterwards, slow hosts upload any
+	// pieces that are still needed.
+	slowHostDeferral = build.Select(build.Var{
+		Dev:      5 * time.Second,
+		Standard: 30 * time.Second,
+		Testing:  2 * time.Second,
+	}).(time.Duration)
+
+	// stuckChunkFailures is the number of consecutive uploads of a chunk that
+	// have to end below full redundancy before the chunk is marked as stuck.
+	stuckChunkFailures = build.Select(build.Var{
+		Dev:      3,
+		Standard: 3,
+		Testing:  2,
+	}).(int)
+
+	// stuckLoopInterval defines how long the stuck loop sleeps between
+	// retries of stuck chunks.
+	stuckLoopInterval = build.Select(build.Var{
+		Dev:      5 * time.Minute,
+		Standard: time.Hour,
+		Testing:  5 * time.Second,
+	}).(time.Duration)
+
+	// stuckChunksPerRetry is the maximum number of stuck chunks that the stuck
+	// loop retries at once.
+	stuckChunksPerRetry = build.Select(build.Var{
+		Dev:      5,
+		Standard: 10,
+		Testing:  5,
+	}).(int)
+
 	// Prime to avoid intersecting with regular events.
 	uploadFailureCooldown = build.Select(build.Var{
 		Dev:      time.Second * 7,
 		Standard: time.Second * 61,
 		Testing:  time.Second,
 	}).(time.Duration)
+
+	// uploadMetricsHistory is the number of recently finished chunk uploads
+	// for which the renter keeps per-stage timings.
+	uploadMetricsHistory = build.Select(build.Var{
+		Dev:      1000,
+		Standard: 1000,
+		Testing:  10,
+	}).(int)
+
+	// uploadWaitPollInterval is how often UploadWait checks whether the file
+	// reached the redundancy that the caller waits for.
+	uploadWaitPollInterval = build.Select(build.Var{
+		Dev:      time.Second,
+		Standard: 5 * time.Second,
+		Testing:  100 * time.Millisecond,
+	}).(time.Duration)
 )
@@ -0,0 +1,57 @@
+package renter
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/types"
+)
+
+// contractHealth returns a score between 0 and 1 describing the health of a
+// contract at the given height. The score is the product of the fraction of
+// the contract's funds that remain, the historic uptime of the host, and the
+// fraction of the contract's duration that remains. Contracts with hosts that
+// are not in the hostdb have a score of 0.
+func contractHealth(c modules.RenterContract, host modules.HostDBEntry, hostKnown bool, height types.BlockHeight) float64 {
+	if !hostKnown || c.TotalCost.IsZero() || c.EndHeight() <= height || c.EndHeight() <= c.StartHeight {
+		return 0
+	}
+	funds, _ := big.NewRat(0, 1).SetFrac(c.RenterFunds().Big(), c.TotalCost.Big()).Float64()
+	if funds > 1 {
+		funds = 1
+	}
+	uptime := float64(1)
+	if total := host.HistoricUptime + host.HistoricDowntime; total > 0 {
+		uptime = float64(host.HistoricUptime) / float64(total)
+	}
+	expiry := float64(c.EndHeight()-height) / float64(c.EndHeight()-c.StartHeight)
+	if expiry > 1 {
+		expiry = 1
+	}
+	return funds * uptime * expiry
+}
+
+// ContractsByHealth returns the renter's contracts ordered from least to most
+// healthy. Contracts with the same health are ordered by ID.
+func (r *Renter) ContractsByHealth() []modules.RenterContract {
+	contracts := r.hostContractor.Contracts()
+	id := r.mu.RLock()
+	height := r.blockHeight
+	r.mu.RUnlock(id)
+
+	health := make(map[types.FileContractID]float64, len(contracts))
+	for _, c := range contracts {
+		host, known := r.hostDB.Host(c.HostPublicKey)
+		health[c.ID] = contractHealth(c, host, known, height)
+	}
+	sort.Slice(contracts, func(i, j int) bool {
+		hi, hj := health[contracts[i].ID], health[contracts[j].ID]
+		if hi != hj {
+			return hi < hj
+		}
+		return bytes.Compare(contracts[i].ID[:], contracts[j].ID[:]) < 0
+	})
+	return contracts
+}
@@ -0,0 +1,98 @@
+package renter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/types"
+)
+
+// TestContractsByHealth checks that contracts are ordered by their health
+// scores, with ties broken by contract ID.
+func TestContractsByHealth(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// newContract creates a contract with the provided host, funds and
+	// lifetime.
+	newContract := func(id byte, host string, funds uint64, start, end types.BlockHeight) modules.RenterContract {
+		c := modules.RenterContract{
+			ID:            types.FileContractID{id},
+			HostPublicKey: types.SiaPublicKey{Key: []byte(host)},
+			StartHeight:   start,
+			TotalCost:     types.NewCurrency64(100),
+		}
+		c.LastRevision.NewWindowStart = end
+		c.LastRevision.NewValidProofOutputs = []types.SiacoinOutput{
+			{Value: types.NewCurrency64(funds)},
+			{Value: types.ZeroCurrency},
+		}
+		return c
+	}
+	const height = 100
+	contracts := []modules.RenterContract{
+		// Half of the funds and half of the duration remain: 0.5 * 1 * 0.5.
+		newContract(1, "reliable", 50, 0, 200),
+		// All funds and duration remain, but the host has 75% uptime.
+		newContract(2, "flaky", 100, 100, 200),
+		// The host is not in the hostdb.
+		newContract(3, "unknown", 100, 100, 200),
+		// The same health as the first contract.
+		newContract(4, "reliable", 50, 0, 200),
+	}
+	expected := map[types.FileContractID]float64{
+		{1}: 0.25,
+		{2}: 0.75,
+		{3}: 0,
+		{4}: 0.25,
+	}
+
+	hdb := costHostDB{hosts: make(map[string]modules.HostDBEntry)}
+	for _, host := range []string{"reliable", "flaky"} {
+		var h modules.HostDBEntry
+		h.PublicKey = types.SiaPublicKey{Key: []byte(host)}
+		if host == "flaky" {
+			h.HistoricUptime = 3 * time.Hour
+			h.HistoricDowntime = time.Hour
+		}
+		hdb.hosts[h.PublicKey.String()] = h
+	}
+
+	for _, c := range contracts {
+		host, known := hdb.Host(c.HostPublicKey)
+		if health := contractHealth(c, host, known, height); health != expected[c.ID] {
+			t.Errorf("contract %v: expected health %v, got %v", c.ID, expected[c.ID], health)
+		}
+	}
+
+	cc := costContractor{
+		hostContractor: rt.renter.hostContractor,
+		contracts:      make(map[types.FileContractID]modules.RenterContract),
+	}
+	for _, c := range contracts {
+		cc.contracts[c.ID] = c
+	}
+	id := rt.renter.mu.Lock()
+	rt.renter.hostContractor = cc
+	rt.renter.hostDB = hdb
+	rt.renter.blockHeight = height
+	rt.renter.mu.Unlock(id)
+
+	sorted := rt.renter.ContractsByHealth()
+	order := []types.FileContractID{{3}, {1}, {4}, {2}}
+	if len(sorted) != len(order) {
+		t.Fatal("expected", len(order), "contracts, got", len(sorted))
+	}
+	for i, c := range sorted {
+		if c.ID != order[i] {
+			t.Fatalf("contract %v is out of order: expected %v", c.ID, order[i])
+		}
+	}
+}
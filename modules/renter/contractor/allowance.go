@@ -9,10 +9,11 @@ import (
 )
 
 var (
-	errAllowanceNoHosts    = errors.New("hosts must be non-zero")
-	errAllowanceNotSynced  = errors.New("you must be synced to set an allowance")
-	errAllowanceWindowSize = errors.New("renew window must be less than period")
-	errAllowanceZeroPeriod = errors.New("period must be non-zero")
+	errAllowanceFundsThreshold = errors.New("renew funds threshold must be at least 0 and less than 1")
+	errAllowanceNoHosts        = errors.New("hosts must be non-zero")
+	errAllowanceNotSynced      = errors.New("you must be synced to set an allowance")
+	errAllowanceWindowSize     = errors.New("renew window must be less than period")
+	errAllowanceZeroPeriod     = errors.New("period must be non-zero")
 
 	// ErrAllowanceZeroWindow is returned when the caller requests a
 	// zero-length renewal window. This will happen if the caller sets the
@@ -20,6 +21,51 @@ var (
 	ErrAllowanceZeroWindow = errors.New("renew window must be non-zero")
 )
 
+// validateAllowance checks that the allowance a is well-formed.
+func validateAllowance(a modules.Allowance) error {
+	if a.Hosts == 0 {
+		return errAllowanceNoHosts
+	} else if a.Period == 0 {
+		return errAllowanceZeroPeriod
+	} else if a.RenewWindow == 0 {
+		return ErrAllowanceZeroWindow
+	} else if a.RenewWindow >= a.Period {
+		return errAllowanceWindowSize
+	} else if a.RenewFundsThreshold < 0 || a.RenewFundsThreshold >= 1 {
+		return errAllowanceFundsThreshold
+	}
+	return nil
+}
+
+// ValidateAllowance returns the error that SetAllowance would return for a,
+// without setting it. The empty allowance is always valid.
+func (c *Contractor) ValidateAllowance(a modules.Allowance) error {
+	if reflect.DeepEqual(a, modules.Allowance{}) {
+		return nil
+	}
+
+	// sanity checks
+	if err := validateAllowance(a); err != nil {
+		return err
+	} else if !c.cs.Synced() {
+		return errAllowanceNotSynced
+	}
+
+	// calculate the maximum sectors this allowance can store
+	max, err := maxSectors(a, c.hdb, c.tpool)
+	if err != nil {
+		return err
+	}
+	// Only allocate half as many sectors as the max. This leaves some leeway
+	// for replacing contracts, transaction fees, etc.
+	numSectors := max / 2
+	// check that this is sufficient to store at least one sector
+	if numSectors == 0 {
+		return ErrInsufficientAllowance
+	}
+	return nil
+}
+
 // SetAllowance sets the amount of money the Contractor is allowed to spend on
 // contracts over a given time period, divided among the number of hosts
 // specified. Note that Contractor can start forming contracts as soon as
@@ -44,32 +90,9 @@ func (c *Contractor) SetAllowance(a modules.Allowance) error {
 	if reflect.DeepEqual(a, modules.Allowance{}) {
 		return c.managedCancelAllowance(a)
 	}
-
-	// sanity checks
-	if a.Hosts == 0 {
-		return errAllowanceNoHosts
-	} else if a.Period == 0 {
-		return errAllowanceZeroPeriod
-	} else if a.RenewWindow == 0 {
-		return ErrAllowanceZeroWindow
-	} else if a.RenewWindow >= a.Period {
-		return errAllowanceWindowSize
-	} else if !c.cs.Synced() {
-		return errAllowanceNotSynced
-	}
-
-	// calculate the maximum sectors this allowance can store
-	max, err := maxSectors(a, c.hdb, c.tpool)
-	if err != nil {
+	if err := c.ValidateAllowance(a); err != nil {
 		return err
 	}
-	// Only allocate half as many sectors as the max. This leaves some leeway
-	// for replacing contracts, transaction fees, etc.
-	numSectors := max / 2
-	// check that this is sufficient to store at least one sector
-	if numSectors == 0 {
-		return ErrInsufficientAllowance
-	}
 
 	c.log.Println("INFO: setting allowance to", a)
 	c.mu.Lock()
@@ -79,7 +102,7 @@ func (c *Contractor) SetAllowance(a modules.Allowance) error {
 		c.currentPeriod = c.blockHeight
 	}
 	c.allowance = a
-	err = c.saveSync()
+	err := c.saveSync()
 	c.mu.Unlock()
 	if err != nil {
 		c.log.Println("Unable to save contractor after setting allowance:", err)
@@ -136,3 +159,73 @@ func (c *Contractor) managedCancelAllowance(a modules.Allowance) error {
 	c.mu.Unlock()
 	return err
 }
+
+// EstimateAllowance simulates forming contracts for the allowance a against
+// the current hostdb, without forming any contracts or spending any money.
+// The allowance funds are split evenly among a.Hosts contracts. For each
+// contract, the host's contract price, the transaction fee and the siafund
+// fee are deducted from the funding, and the remainder is spent on uploading
+// and storing data for a.Period blocks at the host's prices.
+//
+// Hosts that are too expensive or that cannot be paid with the funds of a
+// single contract are skipped, so the estimate may cover fewer than a.Hosts
+// contracts.
+func (c *Contractor) EstimateAllowance(a modules.Allowance) (modules.AllowanceEstimate, error) {
+	if err := validateAllowance(a); err != nil {
+		return modules.AllowanceEstimate{}, err
+	}
+
+	c.mu.RLock()
+	startHeight := c.blockHeight
+	c.mu.RUnlock()
+	_, maxFee := c.tpool.FeeEstimation()
+	txnFee := maxFee.Mul64(estimatedFileContractTransactionSize)
+
+	est := modules.AllowanceEstimate{
+		FundsPerContract: a.Funds.Div64(a.Hosts),
+	}
+	for _, host := range c.managedRandomHosts(int(a.Hosts)*2+10, nil) {
+		if est.Contracts == a.Hosts {
+			break
+		}
+		if host.StoragePrice.Cmp(maxStoragePrice) > 0 {
+			continue
+		}
+		if est.FundsPerContract.Cmp(host.ContractPrice.Add(txnFee)) <= 0 {
+			continue
+		}
+		if host.StoragePrice.IsZero() {
+			host.StoragePrice = types.NewCurrency64(1)
+		}
+		if host.MaxCollateral.Cmp(maxCollateral) > 0 {
+			host.MaxCollateral = maxCollateral
+		}
+
+		// Calculate the payouts the same way proto.FormContract does.
+		renterPayout := est.FundsPerContract.Sub(host.ContractPrice).Sub(txnFee)
+		hostCollateral := renterPayout.Div(host.StoragePrice).Mul(host.Collateral)
+		if hostCollateral.Cmp(host.MaxCollateral) > 0 {
+			hostCollateral = host.MaxCollateral
+		}
+		siafundFee := types.Tax(startHeight, renterPayout.Add(hostCollateral).Add(host.ContractPrice))
+		if siafundFee.Cmp(renterPayout) >= 0 {
+			continue
+		}
+		storageFunds := renterPayout.Sub(siafundFee)
+
+		// Each byte is uploaded once and stored for the whole period.
+		bytePrice := host.StoragePrice.Mul64(uint64(a.Period)).Add(host.UploadBandwidthPrice)
+		storage, err := storageFunds.Div(bytePrice).Uint64()
+		if err != nil {
+			continue
+		}
+
+		est.Contracts++
+		est.ContractFees = est.ContractFees.Add(host.ContractPrice)
+		est.TransactionFees = est.TransactionFees.Add(txnFee)
+		est.SiafundFees = est.SiafundFees.Add(siafundFee)
+		est.StorageFunds = est.StorageFunds.Add(storageFunds)
+		est.Storage += storage
+	}
+	return est, nil
+}
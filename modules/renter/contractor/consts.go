@@ -25,11 +25,27 @@ var (
 		Testing:  10 * time.Millisecond,
 	}).(time.Duration)
 
+	// sessionIdleTimeout is how long an Editor or Downloader keeps its
+	// connection to the host open after its last client closed it, so that
+	// the next upload or download can reuse the session instead of
+	// performing a new handshake. Hosts keep idle sessions open for
+	// modules.NegotiateFileContractRevisionTime.
+	sessionIdleTimeout = build.Select(build.Var{
+		Dev:      30 * time.Second,
+		Standard: 2 * time.Minute,
+		Testing:  time.Second,
+	}).(time.Duration)
+
 	// minContractFundRenewalThreshold defines the ratio of remaining funds to
 	// total contract cost below which the contractor will prematurely renew a
-	// contract.
+	// contract, unless the allowance specifies a RenewFundsThreshold.
 	minContractFundRenewalThreshold = float64(0.03) // 3%
 
+	// maxRevisionRenewalThreshold defines the ratio of a contract's revision
+	// number to the maximum number of revisions per contract above which the
+	// contractor will prematurely renew the contract.
+	maxRevisionRenewalThreshold = float64(0.9) // 90%
+
 	// minHostsForEstimations describes the minimum number of hosts that
 	// are needed to make broad estimations such as the number of sectors
 	// that you can store on the network for a given allowance.
@@ -76,3 +92,28 @@ var (
 	// host is allowed to have before being marked as !GoodForUpload.
 	scoreLeeway = types.NewCurrency64(25)
 )
+
+// Constants related to watching contract transactions until they confirm.
+var (
+	// txnRebroadcastInterval is the number of blocks that the contractor
+	// waits for a contract transaction to confirm before broadcasting it
+	// again with an additional fee.
+	txnRebroadcastInterval = build.Select(build.Var{
+		Dev:      types.BlockHeight(3),
+		Standard: types.BlockHeight(6),
+		Testing:  types.BlockHeight(2),
+	}).(types.BlockHeight)
+
+	// txnAlertThreshold is the number of blocks after which an unconfirmed
+	// contract transaction is reported as being in danger of never
+	// confirming.
+	txnAlertThreshold = build.Select(build.Var{
+		Dev:      types.BlockHeight(12),
+		Standard: types.BlockHeight(36),
+		Testing:  types.BlockHeight(6),
+	}).(types.BlockHeight)
+
+	// maxTxnFeeBumps is the maximum number of times that the fee of a
+	// contract transaction is increased.
+	maxTxnFeeBumps = 3
+)
@@ -14,6 +14,8 @@ import (
 	"path/filepath"
 	"sync"
 
+	"github.com/pachisi456/Sia/crypto"
+	"github.com/pachisi456/Sia/metrics"
 	"github.com/pachisi456/Sia/modules"
 	"github.com/pachisi456/Sia/persist"
 	siasync "github.com/pachisi456/Sia/sync"
@@ -56,6 +58,10 @@ type Contractor struct {
 	// Only one thread should be performing contract maintenance at a time.
 	maintenanceLock siasync.TryMutex
 
+	// Only one thread should be rebroadcasting contract transactions at a
+	// time, so that the fee of a transaction is not increased twice.
+	rebroadcastLock siasync.TryMutex
+
 	allowance     modules.Allowance
 	blockHeight   types.BlockHeight
 	currentPeriod types.BlockHeight
@@ -70,6 +76,54 @@ type Contractor struct {
 	contracts       map[types.FileContractID]modules.RenterContract
 	oldContracts    map[types.FileContractID]modules.RenterContract
 	renewedIDs      map[types.FileContractID]types.FileContractID
+
+	// sectorRoots indexes the Merkle roots of contracts, see HasSector. A
+	// contract is indexed the first time it is queried, and its index is
+	// dropped whenever its roots change in a way that the index does not
+	// track.
+	sectorRoots map[types.FileContractID]map[crypto.Hash]struct{}
+
+	// formationFailures contains the most recent contract formation failure
+	// of each host, keyed by the host's public key. Hosts are removed once a
+	// contract is formed with them.
+	formationFailures map[string]modules.FormationFailure
+
+	// maxRevisions is the maximum number of revisions per contract. Contracts
+	// approaching the limit are renewed, and no further revisions are made
+	// to them until then. A value of zero means that there is no limit.
+	maxRevisions uint64
+
+	// maxChurn is the maximum number of contracts that may be dropped in
+	// favor of better hosts per period, and churn is the number of contracts
+	// that were dropped in the current period. A maxChurn of zero means that
+	// there is no limit.
+	maxChurn uint64
+	churn    uint64
+
+	// filterMode and filteredHosts restrict the hosts that contracts are
+	// formed with, see SetHostFilter. filteredHosts is keyed by the String()
+	// representation of the hosts' public keys.
+	filterMode    modules.HostFilterMode
+	filteredHosts map[string]types.SiaPublicKey
+
+	// subnetDiversity prevents contracts from being formed with hosts that
+	// share a subnet with the host of another contract, see
+	// SetSubnetDiversity.
+	subnetDiversity bool
+
+	// watchedTxns contains the transactions that formed contracts and have
+	// not confirmed yet, keyed by contract ID. They are broadcast again until
+	// they confirm, see threadedRebroadcastContractTxns.
+	watchedTxns map[types.FileContractID]watchedTxn
+
+	// contractStatus contains the status of every contract whose formation
+	// transaction is not confirmed, see withStatus.
+	contractStatus map[types.FileContractID]modules.ContractStatus
+
+	// metrics and metricsRegistry contain the metrics exported by the
+	// contractor, see Metrics.
+	metrics         contractorMetrics
+	metricsRegistry *metrics.Registry
 }
 
 // resolveID returns the ID of the most recent renewal of id.
@@ -93,9 +147,9 @@ func (c *Contractor) Allowance() modules.Allowance {
 func (c *Contractor) Contract(hostAddr modules.NetAddress) (modules.RenterContract, bool) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	for _, c := range c.contracts {
-		if c.NetAddress == hostAddr {
-			return c, true
+	for _, contract := range c.contracts {
+		if contract.NetAddress == hostAddr {
+			return c.withStatus(contract), true
 		}
 	}
 	return modules.RenterContract{}, false
@@ -131,7 +185,34 @@ func (c *Contractor) ContractByID(id types.FileContractID) (modules.RenterContra
 	defer c.mu.RUnlock()
 
 	contract, exists := c.contracts[id]
-	return contract, exists
+	return c.withStatus(contract), exists
+}
+
+// HasSector reports whether the contract with the id specified stores a
+// sector with the provided Merkle root. Unlike ContractByID, it neither copies
+// the contract nor scans its roots.
+func (c *Contractor) HasSector(id types.FileContractID, root crypto.Hash) bool {
+	c.mu.RLock()
+	roots, indexed := c.sectorRoots[id]
+	_, stored := roots[root]
+	c.mu.RUnlock()
+	if indexed {
+		return stored
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	contract, exists := c.contracts[id]
+	if !exists {
+		return false
+	}
+	roots = make(map[crypto.Hash]struct{}, len(contract.MerkleRoots))
+	for _, r := range contract.MerkleRoots {
+		roots[r] = struct{}{}
+	}
+	c.sectorRoots[id] = roots
+	_, stored = roots[root]
+	return stored
 }
 
 // Contracts returns the contracts formed by the contractor in the current
@@ -142,7 +223,7 @@ func (c *Contractor) Contracts() []modules.RenterContract {
 	defer c.mu.RUnlock()
 	cs := make([]modules.RenterContract, 0, len(c.contracts))
 	for _, contract := range c.contracts {
-		cs = append(cs, contract)
+		cs = append(cs, c.withStatus(contract))
 	}
 	return cs
 }
@@ -153,7 +234,7 @@ func (c *Contractor) AllContracts() (cs []modules.RenterContract) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	for _, contract := range c.contracts {
-		cs = append(cs, contract)
+		cs = append(cs, c.withStatus(contract))
 	}
 	// COMPATv1.0.4-lts
 	// also return the special metrics contract (see persist.go)
@@ -192,7 +273,79 @@ func (c *Contractor) ResolveContract(id types.FileContractID) (contract modules.
 		newID, exists = c.renewedIDs[id]
 	}
 	contract, exists = c.contracts[id]
-	return contract, exists
+	return c.withStatus(contract), exists
+}
+
+// FormationFailures returns the most recent contract formation failure of
+// every host that the contractor failed to form a contract with.
+func (c *Contractor) FormationFailures() []modules.FormationFailure {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	failures := make([]modules.FormationFailure, 0, len(c.formationFailures))
+	for _, f := range c.formationFailures {
+		failures = append(failures, f)
+	}
+	return failures
+}
+
+// MaxRevisions returns the maximum number of revisions per contract.
+func (c *Contractor) MaxRevisions() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.maxRevisions
+}
+
+// SetMaxRevisions sets the maximum number of revisions per contract. A value
+// of zero removes the limit.
+func (c *Contractor) SetMaxRevisions(maxRevisions uint64) error {
+	c.mu.Lock()
+	c.maxRevisions = maxRevisions
+	err := c.saveSync()
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// Renew any contracts that are now close to the limit.
+	go c.threadedContractMaintenance()
+	return nil
+}
+
+// MaxChurn returns the maximum number of contracts that may be replaced per
+// period.
+func (c *Contractor) MaxChurn() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.maxChurn
+}
+
+// SetMaxChurn sets the maximum number of contracts that may be dropped in
+// favor of better hosts per period. A value of zero removes the limit.
+func (c *Contractor) SetMaxChurn(maxChurn uint64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.maxChurn = maxChurn
+	return c.saveSync()
+}
+
+// SubnetDiversity returns whether the contractor avoids forming contracts with
+// hosts that share a subnet.
+func (c *Contractor) SubnetDiversity() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.subnetDiversity
+}
+
+// SetSubnetDiversity sets whether the contractor avoids forming contracts with
+// hosts that share a /24 (IPv4) or /64 (IPv6) subnet with the host of another
+// contract, so that a single operator running many hosts cannot end up
+// storing a large share of the renter's data. Existing contracts are not
+// affected.
+func (c *Contractor) SetSubnetDiversity(diverse bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.subnetDiversity = diverse
+	return c.saveSync()
 }
 
 // Close closes the Contractor.
@@ -202,6 +355,19 @@ func (c *Contractor) Close() error {
 
 // New returns a new Contractor.
 func New(cs consensusSet, wallet walletShim, tpool transactionPool, hdb hostDB, persistDir string) (*Contractor, error) {
+	return newWithMetadataKey(cs, wallet, tpool, hdb, persistDir, nil)
+}
+
+// NewWithMetadataKey returns a new Contractor that encrypts its journal with
+// key. A journal that was written without a key is encrypted when the
+// Contractor starts.
+func NewWithMetadataKey(cs consensusSet, wallet walletShim, tpool transactionPool, hdb hostDB, persistDir string, key crypto.TwofishKey) (*Contractor, error) {
+	return newWithMetadataKey(cs, wallet, tpool, hdb, persistDir, &key)
+}
+
+// newWithMetadataKey returns a new Contractor using production dependencies.
+// The journal is encrypted if key is not nil.
+func newWithMetadataKey(cs consensusSet, wallet walletShim, tpool transactionPool, hdb hostDB, persistDir string, key *crypto.TwofishKey) (*Contractor, error) {
 	// Check for nil inputs.
 	if cs == nil {
 		return nil, errNilCS
@@ -225,7 +391,11 @@ func New(cs consensusSet, wallet walletShim, tpool transactionPool, hdb hostDB,
 	}
 
 	// Create Contractor using production dependencies.
-	return newContractor(cs, &walletBridge{w: wallet}, tpool, hdb, newPersist(persistDir), logger)
+	p := newPersist(persistDir)
+	if key != nil {
+		p = newEncryptedPersist(persistDir, *key)
+	}
+	return newContractor(cs, &walletBridge{w: wallet}, tpool, hdb, p, logger)
 }
 
 // newContractor creates a Contractor using the provided dependencies.
@@ -239,15 +409,24 @@ func newContractor(cs consensusSet, w wallet, tp transactionPool, hdb hostDB, p
 		tpool:   tp,
 		wallet:  w,
 
-		cachedRevisions: make(map[types.FileContractID]cachedRevision),
-		contracts:       make(map[types.FileContractID]modules.RenterContract),
-		downloaders:     make(map[types.FileContractID]*hostDownloader),
-		editors:         make(map[types.FileContractID]*hostEditor),
-		oldContracts:    make(map[types.FileContractID]modules.RenterContract),
-		renewedIDs:      make(map[types.FileContractID]types.FileContractID),
-		renewing:        make(map[types.FileContractID]bool),
-		revising:        make(map[types.FileContractID]bool),
+		cachedRevisions:   make(map[types.FileContractID]cachedRevision),
+		contractStatus:    make(map[types.FileContractID]modules.ContractStatus),
+		contracts:         make(map[types.FileContractID]modules.RenterContract),
+		downloaders:       make(map[types.FileContractID]*hostDownloader),
+		editors:           make(map[types.FileContractID]*hostEditor),
+		filterMode:        modules.HostFilterModeDisable,
+		filteredHosts:     make(map[string]types.SiaPublicKey),
+		formationFailures: make(map[string]modules.FormationFailure),
+		oldContracts:      make(map[types.FileContractID]modules.RenterContract),
+		renewedIDs:        make(map[types.FileContractID]types.FileContractID),
+		renewing:          make(map[types.FileContractID]bool),
+		revising:          make(map[types.FileContractID]bool),
+		sectorRoots:       make(map[types.FileContractID]map[crypto.Hash]struct{}),
+		watchedTxns:       make(map[types.FileContractID]watchedTxn),
+
+		metricsRegistry: metrics.NewRegistry(),
 	}
+	c.metrics = newContractorMetrics(c.metricsRegistry)
 
 	// Close the logger (provided as a dependency) upon shutdown.
 	c.tg.AfterStop(func() {
@@ -283,6 +462,10 @@ func newContractor(cs consensusSet, w wallet, tp transactionPool, hdb hostDB, p
 	c.tg.OnStop(func() {
 		cs.Unsubscribe(c)
 	})
+	// Close the idle host sessions upon shutdown.
+	c.tg.OnStop(func() {
+		c.managedCloseIdleSessions()
+	})
 
 	// We may have upgraded persist or resubscribed. Save now so that we don't
 	// lose our work.
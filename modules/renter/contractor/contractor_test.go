@@ -2,13 +2,16 @@ package contractor
 
 import (
 	"errors"
+	"net"
 	"os"
 	"reflect"
 	"testing"
 	"time"
 
 	"github.com/pachisi456/Sia/build"
+	"github.com/pachisi456/Sia/crypto"
 	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/modules/renter/proto"
 	"github.com/pachisi456/Sia/types"
 )
 
@@ -25,6 +28,7 @@ func (newStub) Unsubscribe(modules.ConsensusSetSubscriber) { return }
 
 // wallet stubs
 func (newStub) NextAddress() (uc types.UnlockConditions, err error) { return }
+func (newStub) PrimarySeed() (s modules.Seed, n uint64, err error)  { return }
 func (newStub) StartTransaction() modules.TransactionBuilder        { return nil }
 
 // transaction pool stubs
@@ -38,6 +42,9 @@ func (newStub) Host(types.SiaPublicKey) (settings modules.HostDBEntry, ok bool)
 func (newStub) IncrementSuccessfulInteractions(key types.SiaPublicKey)          { return }
 func (newStub) IncrementFailedInteractions(key types.SiaPublicKey)              { return }
 func (newStub) RandomHosts(int, []types.SiaPublicKey) []modules.HostDBEntry     { return nil }
+func (newStub) RandomDiverseHosts(int, []types.SiaPublicKey, []types.SiaPublicKey) []modules.HostDBEntry {
+	return nil
+}
 func (newStub) ScoreBreakdown(modules.HostDBEntry) modules.HostScoreBreakdown {
 	return modules.HostScoreBreakdown{}
 }
@@ -139,6 +146,43 @@ func TestContracts(t *testing.T) {
 	}
 }
 
+// TestHasSector tests the HasSector method.
+func TestHasSector(t *testing.T) {
+	c := &Contractor{
+		contracts: map[types.FileContractID]modules.RenterContract{
+			{1}: {ID: types.FileContractID{1}, MerkleRoots: []crypto.Hash{{1}, {2}}},
+		},
+		sectorRoots: make(map[types.FileContractID]map[crypto.Hash]struct{}),
+	}
+	tests := []struct {
+		id     types.FileContractID
+		root   crypto.Hash
+		stored bool
+	}{
+		{types.FileContractID{1}, crypto.Hash{1}, true},
+		{types.FileContractID{1}, crypto.Hash{2}, true},
+		{types.FileContractID{1}, crypto.Hash{3}, false},
+		{types.FileContractID{2}, crypto.Hash{1}, false},
+	}
+	for _, test := range tests {
+		if stored := c.HasSector(test.id, test.root); stored != test.stored {
+			t.Errorf("expected HasSector(%v, %v) to be %v, got %v", test.id, test.root, test.stored, stored)
+		}
+	}
+	if len(c.sectorRoots) != 1 || len(c.sectorRoots[types.FileContractID{1}]) != 2 {
+		t.Fatal("expected the roots of the contract to be indexed, got", c.sectorRoots)
+	}
+
+	// The index is rebuilt once it is dropped.
+	contract := c.contracts[types.FileContractID{1}]
+	contract.MerkleRoots = append(contract.MerkleRoots, crypto.Hash{3})
+	c.contracts[contract.ID] = contract
+	delete(c.sectorRoots, contract.ID)
+	if !c.HasSector(contract.ID, crypto.Hash{3}) {
+		t.Fatal("expected the new root to be indexed")
+	}
+}
+
 // TestResolveID tests the ResolveID method.
 func TestResolveID(t *testing.T) {
 	c := &Contractor{
@@ -184,6 +228,61 @@ func TestAllowance(t *testing.T) {
 	}
 }
 
+// TestEstimateAllowance checks that EstimateAllowance deducts the fees of each
+// contract from its funding, and skips hosts that no contract can be formed
+// with.
+func TestEstimateAllowance(t *testing.T) {
+	cheap := modules.HostDBEntry{PublicKey: types.SiaPublicKey{Key: []byte("cheap")}}
+	cheap.ContractPrice = types.SiacoinPrecision.Div64(10)
+	cheap.StoragePrice = types.NewCurrency64(100)
+	cheap.UploadBandwidthPrice = types.NewCurrency64(1000)
+	expensive := modules.HostDBEntry{PublicKey: types.SiaPublicKey{Key: []byte("expensive")}}
+	expensive.StoragePrice = maxStoragePrice.Mul64(2)
+	overpriced := modules.HostDBEntry{PublicKey: types.SiaPublicKey{Key: []byte("overpriced")}}
+	overpriced.ContractPrice = types.SiacoinPrecision.Mul64(2)
+	overpriced.StoragePrice = types.NewCurrency64(1)
+
+	c := &Contractor{
+		hdb:           filterHostDB{hosts: []modules.HostDBEntry{cheap, expensive, overpriced}},
+		tpool:         newStub{},
+		filterMode:    modules.HostFilterModeDisable,
+		filteredHosts: make(map[string]types.SiaPublicKey),
+	}
+	a := modules.Allowance{
+		Funds:       types.SiacoinPrecision.Mul64(3),
+		Hosts:       3,
+		Period:      100,
+		RenewWindow: 10,
+	}
+	est, err := c.EstimateAllowance(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if est.Contracts != 1 {
+		t.Fatal("expected only the cheap host to be usable, got", est.Contracts)
+	}
+	if est.FundsPerContract.Cmp(types.SiacoinPrecision) != 0 {
+		t.Fatal("wrong funds per contract:", est.FundsPerContract)
+	}
+	if est.ContractFees.Cmp(cheap.ContractPrice) != 0 || est.SiafundFees.IsZero() {
+		t.Fatal("wrong fees:", est.ContractFees, est.SiafundFees)
+	}
+	spent := est.ContractFees.Add(est.TransactionFees).Add(est.SiafundFees).Add(est.StorageFunds)
+	if spent.Cmp(est.FundsPerContract) != 0 {
+		t.Fatal("funds were not fully accounted for:", spent)
+	}
+	bytePrice := cheap.StoragePrice.Mul64(uint64(a.Period)).Add(cheap.UploadBandwidthPrice)
+	if storage, _ := est.StorageFunds.Div(bytePrice).Uint64(); est.Storage != storage {
+		t.Fatalf("expected %v bytes of storage, got %v", storage, est.Storage)
+	}
+
+	// Invalid allowances are rejected.
+	a.Hosts = 0
+	if _, err := c.EstimateAllowance(a); err != errAllowanceNoHosts {
+		t.Fatal("expected errAllowanceNoHosts, got", err)
+	}
+}
+
 // stubHostDB mocks the hostDB dependency using zero-valued implementations of
 // its methods.
 type stubHostDB struct{}
@@ -195,6 +294,9 @@ func (stubHostDB) IncrementSuccessfulInteractions(key types.SiaPublicKey)
 func (stubHostDB) IncrementFailedInteractions(key types.SiaPublicKey)               { return }
 func (stubHostDB) PublicKey() (spk types.SiaPublicKey)                              { return }
 func (stubHostDB) RandomHosts(int, []types.SiaPublicKey) (hs []modules.HostDBEntry) { return }
+func (stubHostDB) RandomDiverseHosts(int, []types.SiaPublicKey, []types.SiaPublicKey) (hs []modules.HostDBEntry) {
+	return
+}
 func (stubHostDB) ScoreBreakdown(modules.HostDBEntry) modules.HostScoreBreakdown {
 	return modules.HostScoreBreakdown{}
 }
@@ -458,10 +560,18 @@ func TestIntegrationSetAllowance(t *testing.T) {
 	if err != errAllowanceWindowSize {
 		t.Errorf("expected %q, got %q", errAllowanceWindowSize, err)
 	}
+	a.RenewWindow = 10
+	for _, threshold := range []float64{-0.1, 1} {
+		a.RenewFundsThreshold = threshold
+		err = c.SetAllowance(a)
+		if err != errAllowanceFundsThreshold {
+			t.Errorf("expected %q, got %q", errAllowanceFundsThreshold, err)
+		}
+	}
+	a.RenewFundsThreshold = 0
 
 	// reasonable values; should succeed
 	a.Funds = types.SiacoinPrecision.Mul64(100)
-	a.RenewWindow = 10
 	err = c.SetAllowance(a)
 	if err != nil {
 		t.Fatal(err)
@@ -583,6 +693,9 @@ func (ws *testWalletShim) NextAddress() (types.UnlockConditions, error) {
 	ws.nextAddressCalled = true
 	return types.UnlockConditions{}, nil
 }
+func (ws *testWalletShim) PrimarySeed() (modules.Seed, uint64, error) {
+	return modules.Seed{}, 0, nil
+}
 func (ws *testWalletShim) StartTransaction() modules.TransactionBuilder {
 	ws.startTxnCalled = true
 	return nil
@@ -601,3 +714,57 @@ func TestWalletBridge(t *testing.T) {
 		t.Error("StartTransaction was not called on the shim")
 	}
 }
+
+// TestFormationFailures checks that contract formation failures are recorded
+// with the correct reason.
+func TestFormationFailures(t *testing.T) {
+	// Obtain a genuine dial error by dialing a port that nothing listens on.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	_, dialErr := net.Dial("tcp", addr)
+	if dialErr == nil {
+		t.Fatal("expected dialing a closed port to fail")
+	}
+
+	tests := []struct {
+		err    error
+		reason modules.FormationFailureReason
+	}{
+		{dialErr, modules.FormationFailureOffline},
+		{proto.ErrHostNotAcceptingContracts, modules.FormationFailureInsufficientCapacity},
+		{proto.FundingError{Err: errors.New("insufficient balance")}, modules.FormationFailureOther},
+		{errors.New("host did not accept our signatures"), modules.FormationFailureHandshake},
+	}
+	for _, test := range tests {
+		if reason := formationFailureReason(test.err); reason != test.reason {
+			t.Errorf("expected %q for %v, got %q", test.reason, test.err, reason)
+		}
+	}
+
+	// Attempting to form a contract with an expensive host should record the
+	// failure as too expensive.
+	c := &Contractor{
+		formationFailures: make(map[string]modules.FormationFailure),
+	}
+	host := modules.HostDBEntry{
+		PublicKey: types.SiaPublicKey{Key: []byte{1}},
+	}
+	host.StoragePrice = maxStoragePrice.Add(types.NewCurrency64(1))
+	if _, err := c.managedNewContract(host, types.SiacoinPrecision, 100); err != errTooExpensive {
+		t.Fatal("expected errTooExpensive, got", err)
+	}
+	failures := c.FormationFailures()
+	if len(failures) != 1 {
+		t.Fatal("expected 1 formation failure, got", len(failures))
+	}
+	if failures[0].Reason != modules.FormationFailureTooExpensive {
+		t.Fatal("expected failure to be too expensive, got", failures[0].Reason)
+	}
+	if failures[0].HostPublicKey.String() != host.PublicKey.String() {
+		t.Fatal("failure recorded for the wrong host")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"net"
 	"time"
 
 	"github.com/pachisi456/Sia/build"
@@ -85,6 +86,16 @@ func (c *Contractor) contractEndHeight() types.BlockHeight {
 	return endHeight
 }
 
+// renewFundsThreshold returns the ratio of remaining funds to total contract
+// cost below which a contract is renewed early. The contractor's lock must be
+// held.
+func (c *Contractor) renewFundsThreshold() float64 {
+	if c.allowance.RenewFundsThreshold == 0 {
+		return minContractFundRenewalThreshold
+	}
+	return c.allowance.RenewFundsThreshold
+}
+
 // managedMarkContractsUtility checks every active contract in the contractor and
 // figures out whether the contract is useful for uploading, and whehter the
 // contract should be renewed.
@@ -126,6 +137,7 @@ func (c *Contractor) managedMarkContractsUtility() {
 	// Go through and figure out if the utility fields need to be changed.
 	for i := 0; i < len(contracts); i++ {
 		// Start the contract in good standing.
+		wasGoodForRenew := contracts[i].GoodForRenew
 		contracts[i].GoodForUpload = true
 		contracts[i].GoodForRenew = true
 
@@ -136,8 +148,34 @@ func (c *Contractor) managedMarkContractsUtility() {
 			contracts[i].GoodForRenew = false
 			continue
 		}
-		// Contract has no utility if the score is poor.
-		if c.hdb.ScoreBreakdown(host).Score.Cmp(minScore) < 0 {
+		// Contract has no utility if the host is excluded by the host
+		// filter.
+		c.mu.RLock()
+		allowed := c.allowedHost(contracts[i].HostPublicKey)
+		c.mu.RUnlock()
+		if !allowed {
+			contracts[i].GoodForUpload = false
+			contracts[i].GoodForRenew = false
+			continue
+		}
+		// Contract has no utility if its formation transaction was
+		// double-spent. If it was reorged out, it is kept until the
+		// transaction confirms again, but not uploaded to.
+		c.mu.RLock()
+		status := c.withStatus(contracts[i]).Status
+		c.mu.RUnlock()
+		if status == modules.ContractStatusDoubleSpent {
+			contracts[i].GoodForUpload = false
+			contracts[i].GoodForRenew = false
+			continue
+		} else if status == modules.ContractStatusReorged {
+			contracts[i].GoodForUpload = false
+			continue
+		}
+		// Contract has no utility if the score is poor, unless the churn
+		// budget of the period is used up. Dropping the contract means that
+		// its data has to be uploaded to another host.
+		if c.hdb.ScoreBreakdown(host).Score.Cmp(minScore) < 0 && c.managedChurn(wasGoodForRenew) {
 			contracts[i].GoodForUpload = false
 			contracts[i].GoodForRenew = false
 			continue
@@ -164,6 +202,14 @@ func (c *Contractor) managedMarkContractsUtility() {
 			continue
 		}
 
+		// Contract should not be used for upload if its Merkle roots are
+		// unknown, e.g. because it was recovered from the blockchain, as the
+		// Merkle root of the contract can't be updated without them.
+		if uint64(len(contracts[i].MerkleRoots))*modules.SectorSize != contracts[i].LastRevision.NewFileSize {
+			contracts[i].GoodForUpload = false
+			continue
+		}
+
 		// Contract should not be used for upload if the number of Merkle roots
 		// exceeds 25e3 - this is in place because the current hosts do not
 		// really perform well beyond this number of sectors in a single
@@ -200,8 +246,16 @@ func (c *Contractor) managedMarkContractsUtility() {
 // managedNewContract negotiates an initial file contract with the specified
 // host, saves it, and returns it.
 func (c *Contractor) managedNewContract(host modules.HostDBEntry, contractFunding types.Currency, endHeight types.BlockHeight) (modules.RenterContract, error) {
+	// reject hosts that are excluded by the host filter
+	c.mu.RLock()
+	allowed := c.allowedHost(host.PublicKey)
+	c.mu.RUnlock()
+	if !allowed {
+		return modules.RenterContract{}, errHostFiltered
+	}
 	// reject hosts that are too expensive
 	if host.StoragePrice.Cmp(maxStoragePrice) > 0 {
+		c.managedRecordFormationFailure(host, modules.FormationFailureTooExpensive, errTooExpensive)
 		return modules.RenterContract{}, errTooExpensive
 	}
 	// cap host.MaxCollateral
@@ -212,6 +266,14 @@ func (c *Contractor) managedNewContract(host modules.HostDBEntry, contractFundin
 	// get an address to use for negotiation
 	uc, err := c.wallet.NextAddress()
 	if err != nil {
+		c.managedRecordFormationFailure(host, modules.FormationFailureOther, err)
+		return modules.RenterContract{}, err
+	}
+	// derive the renter's key of the contract from the wallet seed, so that
+	// the contract can be recovered, see RecoverContracts
+	seed, _, err := c.wallet.PrimarySeed()
+	if err != nil {
+		c.managedRecordFormationFailure(host, modules.FormationFailureOther, err)
 		return modules.RenterContract{}, err
 	}
 
@@ -223,6 +285,7 @@ func (c *Contractor) managedNewContract(host modules.HostDBEntry, contractFundin
 		StartHeight:   c.blockHeight,
 		EndHeight:     endHeight,
 		RefundAddress: uc.UnlockHash(),
+		SecretKey:     contractKey(seed, host.PublicKey),
 	}
 	c.mu.RUnlock()
 
@@ -232,14 +295,61 @@ func (c *Contractor) managedNewContract(host modules.HostDBEntry, contractFundin
 	contract, err := proto.FormContract(params, txnBuilder, c.tpool, c.hdb, c.tg.StopChan())
 	if err != nil {
 		txnBuilder.Drop()
+		c.managedRecordFormationFailure(host, formationFailureReason(err), err)
 		return modules.RenterContract{}, err
 	}
+	c.mu.Lock()
+	delete(c.formationFailures, host.PublicKey.String())
+	c.mu.Unlock()
+	txn, parents := txnBuilder.View()
+	c.managedWatchContractTxn(contract, append(parents, txn))
 
+	c.metrics.contractsFormed.Inc()
 	contractValue := contract.RenterFunds()
 	c.log.Printf("Formed contract with %v for %v", host.NetAddress, contractValue.HumanString())
 	return contract, nil
 }
 
+// nearRevisionLimit reports whether the contract has been revised often enough
+// that it should be renewed before it reaches maxRevisions. A maxRevisions of
+// zero means that there is no limit.
+func nearRevisionLimit(contract modules.RenterContract, maxRevisions uint64) bool {
+	if maxRevisions == 0 {
+		return false
+	}
+	threshold := uint64(float64(maxRevisions) * maxRevisionRenewalThreshold)
+	return contract.LastRevision.NewRevisionNumber >= threshold
+}
+
+// formationFailureReason categorizes an error returned by proto.FormContract.
+func formationFailureReason(err error) modules.FormationFailureReason {
+	if err == proto.ErrHostNotAcceptingContracts {
+		return modules.FormationFailureInsufficientCapacity
+	}
+	if _, ok := err.(proto.FundingError); ok {
+		return modules.FormationFailureOther
+	}
+	if opErr, ok := err.(*net.OpError); ok && opErr.Op == "dial" {
+		return modules.FormationFailureOffline
+	}
+	return modules.FormationFailureHandshake
+}
+
+// managedRecordFormationFailure records that a contract could not be formed
+// with a host, replacing any previous failure of that host.
+func (c *Contractor) managedRecordFormationFailure(host modules.HostDBEntry, reason modules.FormationFailureReason, err error) {
+	c.metrics.formationFailures.Inc("reason", string(reason))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.formationFailures[host.PublicKey.String()] = modules.FormationFailure{
+		HostPublicKey: host.PublicKey,
+		NetAddress:    host.NetAddress,
+		Reason:        reason,
+		Error:         err.Error(),
+		Time:          time.Now(),
+	}
+}
+
 // managedRenew negotiates a new contract for data already stored with a host.
 // It returns the new contract. This is a blocking call that performs network
 // I/O.
@@ -306,6 +416,8 @@ func (c *Contractor) managedRenew(contract modules.RenterContract, contractFundi
 		txnBuilder.Drop() // return unused outputs to wallet
 		return modules.RenterContract{}, err
 	}
+	txn, parents := txnBuilder.View()
+	c.managedWatchContractTxn(newContract, append(parents, txn))
 
 	return newContract, nil
 }
@@ -469,6 +581,20 @@ func (c *Contractor) threadedContractMaintenance() {
 					id:     contract.ID,
 					amount: renewAmount,
 				})
+			} else if nearRevisionLimit(contract, c.maxRevisions) {
+				// The contract has been revised so many times that it will
+				// soon reach the revision limit. Refresh it with the same
+				// amount of funds so that uploads can continue.
+				refreshAmount := contract.TotalCost
+				if refreshAmount.Cmp(fundsAvailable) < 0 {
+					refreshSet[contract.ID] = struct{}{}
+					renewSet = append(renewSet, renewal{
+						id:     contract.ID,
+						amount: refreshAmount,
+					})
+				} else {
+					c.log.Println("WARN: cannot refresh contract near the revision limit due to low allowance.")
+				}
 			} else {
 				// check if the contract has exhausted its funding and requires premature renewal.
 				c.mu.RUnlock()
@@ -486,7 +612,7 @@ func (c *Contractor) threadedContractMaintenance() {
 				sectorBandwidthPrice := host.UploadBandwidthPrice.Mul64(modules.SectorSize)
 				sectorPrice := sectorStoragePrice.Add(sectorBandwidthPrice)
 				percentRemaining, _ := big.NewRat(0, 1).SetFrac(contract.RenterFunds().Big(), contract.TotalCost.Big()).Float64()
-				if contract.RenterFunds().Cmp(sectorPrice.Mul64(3)) < 0 || percentRemaining < minContractFundRenewalThreshold {
+				if contract.RenterFunds().Cmp(sectorPrice.Mul64(3)) < 0 || percentRemaining < c.renewFundsThreshold() {
 					// This contract does need to be refreshed. Make sure there
 					// are enough funds available to perform the refresh, and
 					// then execute.
@@ -552,8 +678,10 @@ func (c *Contractor) threadedContractMaintenance() {
 			newContract, err := c.managedRenew(oldContract, amount, endHeight)
 			if err != nil {
 				c.log.Printf("WARN: failed to renew contract %v with %v: %v\n", id, oldContract.NetAddress, err)
+				c.metrics.renewalFailures.Inc()
 				return
 			}
+			c.metrics.contractsRenewed.Inc()
 			c.log.Printf("Renewed contract %v with %v\n", id, oldContract.NetAddress)
 			// Update the utility values for the new contract, and for the old
 			// contract.
@@ -581,6 +709,7 @@ func (c *Contractor) threadedContractMaintenance() {
 			if exists {
 				c.oldContracts[oldContract.ID] = oldContract
 				delete(c.contracts, oldContract.ID)
+				delete(c.sectorRoots, oldContract.ID)
 			}
 
 			// Add the new contract, including a mapping from the old
@@ -639,7 +768,7 @@ func (c *Contractor) threadedContractMaintenance() {
 	}
 	initialContractFunds := c.allowance.Funds.Div64(c.allowance.Hosts).Div64(3)
 	c.mu.RUnlock()
-	hosts := c.hdb.RandomHosts(neededContracts*2+10, exclude)
+	hosts := c.managedRandomHosts(neededContracts*2+10, exclude)
 
 	// Form contracts with the hosts one at a time, until we have enough
 	// contracts.
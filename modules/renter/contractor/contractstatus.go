@@ -0,0 +1,87 @@
+package contractor
+
+import (
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/types"
+)
+
+// withStatus returns the contract with its Status field set. Contracts
+// without a recorded status have confirmed, either recently or before their
+// status was tracked. The contractor's lock must be held.
+func (c *Contractor) withStatus(contract modules.RenterContract) modules.RenterContract {
+	contract.Status = modules.ContractStatusConfirmed
+	if status, exists := c.contractStatus[contract.ID]; exists {
+		contract.Status = status
+	}
+	return contract
+}
+
+// updateContractStatus updates the status of the contracts affected by a
+// consensus change. Contracts whose formation transaction is reverted are
+// marked as reorged, and unconfirmed contracts whose funding outputs are
+// spent by another transaction are marked as double-spent. The contractor's
+// lock must be held.
+func (c *Contractor) updateContractStatus(cc modules.ConsensusChange) {
+	for _, block := range cc.RevertedBlocks {
+		for _, txn := range block.Transactions {
+			for i := range txn.FileContracts {
+				id := txn.FileContractID(uint64(i))
+				if _, exists := c.contracts[id]; exists {
+					c.contractStatus[id] = modules.ContractStatusReorged
+					c.log.Println("WARN: the formation transaction of contract", id, "was reverted")
+				}
+			}
+		}
+	}
+
+	// spent maps the outputs spent by the unconfirmed formation transactions
+	// to the contracts they form. The transactions themselves are ignored
+	// when looking for double spends.
+	spent := make(map[types.SiacoinOutputID]types.FileContractID)
+	formationTxns := make(map[types.TransactionID]struct{})
+	for id, wt := range c.watchedTxns {
+		for _, txn := range wt.formationTxns() {
+			formationTxns[txn.ID()] = struct{}{}
+			for _, sci := range txn.SiacoinInputs {
+				spent[sci.ParentID] = id
+			}
+		}
+	}
+	for _, block := range cc.AppliedBlocks {
+		for _, txn := range block.Transactions {
+			for i := range txn.FileContracts {
+				id := txn.FileContractID(uint64(i))
+				delete(c.watchedTxns, id)
+				delete(c.contractStatus, id)
+			}
+			if _, exists := formationTxns[txn.ID()]; exists {
+				continue
+			}
+			for _, sci := range txn.SiacoinInputs {
+				id, exists := spent[sci.ParentID]
+				if !exists {
+					continue
+				}
+				delete(c.watchedTxns, id)
+				c.contractStatus[id] = modules.ContractStatusDoubleSpent
+				c.log.Println("WARN: the formation transaction of contract", id, "was double-spent")
+			}
+		}
+	}
+
+	// Forget the contracts that have ended. Transactions of reverted blocks
+	// are returned to the transaction pool by the pool itself, so reorged
+	// contracts are not watched again.
+	for id, wt := range c.watchedTxns {
+		if c.blockHeight > wt.EndHeight {
+			delete(c.watchedTxns, id)
+		}
+	}
+	for id := range c.contractStatus {
+		_, active := c.contracts[id]
+		_, watched := c.watchedTxns[id]
+		if !active && !watched {
+			delete(c.contractStatus, id)
+		}
+	}
+}
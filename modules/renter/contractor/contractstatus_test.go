@@ -0,0 +1,92 @@
+package contractor
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/persist"
+	"github.com/pachisi456/Sia/types"
+)
+
+// TestContractStatus checks that contracts whose formation transaction is
+// reverted or double-spent are marked accordingly.
+func TestContractStatus(t *testing.T) {
+	c := &Contractor{
+		log:         persist.NewLogger(ioutil.Discard),
+		persist:     new(memPersist),
+		contracts:   make(map[types.FileContractID]modules.RenterContract),
+		watchedTxns: make(map[types.FileContractID]watchedTxn),
+
+		contractStatus: make(map[types.FileContractID]modules.ContractStatus),
+	}
+	status := func(id types.FileContractID) modules.ContractStatus {
+		contract, _ := c.ContractByID(id)
+		return contract.Status
+	}
+
+	// Form two contracts, each funded by a different output.
+	var contracts []modules.RenterContract
+	var txns []types.Transaction
+	for i := 0; i < 2; i++ {
+		txn := types.Transaction{
+			SiacoinInputs: []types.SiacoinInput{{ParentID: types.SiacoinOutputID{byte(i)}}},
+			FileContracts: []types.FileContract{{WindowStart: 1000}},
+		}
+		contract := modules.RenterContract{
+			ID:            txn.FileContractID(0),
+			HostPublicKey: types.SiaPublicKey{Key: []byte{byte(i)}},
+		}
+		contract.LastRevision.NewWindowStart = 1000
+		c.managedWatchContractTxn(contract, []types.Transaction{txn})
+		c.contracts[contract.ID] = contract
+		contracts = append(contracts, contract)
+		txns = append(txns, txn)
+	}
+	for _, contract := range c.Contracts() {
+		if contract.Status != modules.ContractStatusUnconfirmed || !contract.Status.Usable() {
+			t.Fatal("new contract should be unconfirmed, got", contract.Status)
+		}
+	}
+
+	// Confirm the first contract, and double-spend the output funding the
+	// second one.
+	doubleSpend := types.Transaction{SiacoinInputs: txns[1].SiacoinInputs}
+	block := types.Block{Transactions: []types.Transaction{txns[0], doubleSpend}}
+	c.ProcessConsensusChange(modules.ConsensusChange{AppliedBlocks: []types.Block{block}})
+	if s := status(contracts[0].ID); s != modules.ContractStatusConfirmed {
+		t.Fatal("expected the contract to be confirmed, got", s)
+	}
+	if s := status(contracts[1].ID); s != modules.ContractStatusDoubleSpent || s.Usable() {
+		t.Fatal("expected the contract to be double-spent, got", s)
+	}
+	if len(c.watchedTxns) != 0 {
+		t.Fatal("transactions are still watched")
+	}
+
+	// The status is persisted.
+	if err := c.save(); err != nil {
+		t.Fatal(err)
+	}
+	c.contractStatus = make(map[types.FileContractID]modules.ContractStatus)
+	c.cachedRevisions = make(map[types.FileContractID]cachedRevision)
+	c.oldContracts = make(map[types.FileContractID]modules.RenterContract)
+	c.renewedIDs = make(map[types.FileContractID]types.FileContractID)
+	c.filteredHosts = make(map[string]types.SiaPublicKey)
+	if err := c.load(); err != nil {
+		t.Fatal(err)
+	}
+	if s := status(contracts[1].ID); s != modules.ContractStatusDoubleSpent {
+		t.Fatal("status was not persisted, got", s)
+	}
+
+	// Revert the block, and apply it again.
+	c.ProcessConsensusChange(modules.ConsensusChange{RevertedBlocks: []types.Block{block}})
+	if s := status(contracts[0].ID); s != modules.ContractStatusReorged || s.Usable() {
+		t.Fatal("expected the contract to be reorged, got", s)
+	}
+	c.ProcessConsensusChange(modules.ConsensusChange{AppliedBlocks: []types.Block{block}})
+	if s := status(contracts[0].ID); s != modules.ContractStatusConfirmed {
+		t.Fatal("expected the contract to be confirmed again, got", s)
+	}
+}
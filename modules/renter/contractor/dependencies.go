@@ -3,7 +3,9 @@ package contractor
 import (
 	"path/filepath"
 
+	"github.com/pachisi456/Sia/crypto"
 	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/persist"
 	"github.com/pachisi456/Sia/types"
 )
 
@@ -20,10 +22,12 @@ type (
 	// transactionBuilder.
 	walletShim interface {
 		NextAddress() (types.UnlockConditions, error)
+		PrimarySeed() (modules.Seed, uint64, error)
 		StartTransaction() modules.TransactionBuilder
 	}
 	wallet interface {
 		NextAddress() (types.UnlockConditions, error)
+		PrimarySeed() (modules.Seed, uint64, error)
 		StartTransaction() transactionBuilder
 	}
 	transactionBuilder interface {
@@ -51,6 +55,7 @@ type (
 		Host(types.SiaPublicKey) (modules.HostDBEntry, bool)
 		IncrementSuccessfulInteractions(key types.SiaPublicKey)
 		IncrementFailedInteractions(key types.SiaPublicKey)
+		RandomDiverseHosts(n int, exclude, occupied []types.SiaPublicKey) []modules.HostDBEntry
 		RandomHosts(n int, exclude []types.SiaPublicKey) []modules.HostDBEntry
 		ScoreBreakdown(modules.HostDBEntry) modules.HostScoreBreakdown
 	}
@@ -70,19 +75,22 @@ type walletBridge struct {
 }
 
 func (ws *walletBridge) NextAddress() (types.UnlockConditions, error) { return ws.w.NextAddress() }
+func (ws *walletBridge) PrimarySeed() (modules.Seed, uint64, error)   { return ws.w.PrimarySeed() }
 func (ws *walletBridge) StartTransaction() transactionBuilder         { return ws.w.StartTransaction() }
 
 // stdPersist implements the persister interface via the journal type. The
-// filename required by these functions is internal to stdPersist.
+// filename required by these functions is internal to stdPersist. If key is
+// not nil, the journal is encrypted with it.
 type stdPersist struct {
 	journal  *journal
 	filename string
+	key      *crypto.TwofishKey
 }
 
 func (p *stdPersist) save(data contractorPersist) error {
 	if p.journal == nil {
 		var err error
-		p.journal, err = newJournal(p.filename, data)
+		p.journal, err = newJournalWithKey(p.filename, p.key, data)
 		return err
 	}
 	return p.journal.checkpoint(data)
@@ -94,16 +102,25 @@ func (p *stdPersist) update(us ...journalUpdate) error {
 
 func (p *stdPersist) load(data *contractorPersist) error {
 	var err error
-	p.journal, err = openJournal(p.filename, data)
-	if err != nil {
+	p.journal, err = openJournalWithKey(p.filename, p.key, data)
+	if err == persist.ErrNoEncryptionKey || err == persist.ErrBadEncryptionKey {
+		// Don't overwrite a journal that can't be decrypted.
+		return err
+	} else if err != nil {
 		// Try loading old persist.
 		err = loadv110persist(filepath.Dir(p.filename), data)
 		if err != nil {
 			return err
 		}
-		p.journal, err = newJournal(p.filename, *data)
+		p.journal, err = newJournalWithKey(p.filename, p.key, *data)
+		return err
 	}
-	return err
+	if p.key != nil && p.journal.key == nil {
+		// Encrypt a journal that was written without a key.
+		p.journal.key = p.key
+		return p.journal.checkpoint(*data)
+	}
+	return nil
 }
 
 func (p stdPersist) Close() error {
@@ -115,3 +132,12 @@ func newPersist(dir string) *stdPersist {
 		filename: filepath.Join(dir, "contractor.journal"),
 	}
 }
+
+// newEncryptedPersist returns a stdPersist whose journal is encrypted with
+// key.
+func newEncryptedPersist(dir string, key crypto.TwofishKey) *stdPersist {
+	return &stdPersist{
+		filename: filepath.Join(dir, "contractor.journal"),
+		key:      &key,
+	}
+}
@@ -1,8 +1,10 @@
 package contractor
 
 import (
+	"context"
 	"errors"
 	"sync"
+	"time"
 
 	"github.com/pachisi456/Sia/build"
 	"github.com/pachisi456/Sia/crypto"
@@ -29,14 +31,19 @@ type Downloader interface {
 // A hostDownloader retrieves sectors by calling the download RPC on a host.
 // It implements the Downloader interface. hostDownloaders are safe for use by
 // multiple goroutines.
+//
+// Like a hostEditor, a hostDownloader keeps its connection to the host open
+// for sessionIdleTimeout after its last client closed it.
 type hostDownloader struct {
 	clients      int // safe to Close when 0
 	contractID   types.FileContractID
 	contractor   *Contractor
 	downloader   *proto.Downloader
+	failed       bool // true if the last download failed
 	hostSettings modules.HostExternalSettings
-	invalid      bool   // true if invalidate has been called
-	speed        uint64 // Bytes per second.
+	idleTimer    *time.Timer // closes the idle hostDownloader
+	invalid      bool        // true if invalidate has been called
+	speed        uint64      // Bytes per second.
 	mu           sync.Mutex
 }
 
@@ -69,12 +76,29 @@ func (hd *hostDownloader) HostSettings() modules.HostExternalSettings {
 // the underlying contract to pay the host proportionally to the data
 // retrieve.
 func (hd *hostDownloader) Sector(root crypto.Hash) ([]byte, error) {
+	return hd.sector(root, true)
+}
+
+// UnverifiedSector is like Sector, but does not check that the data sent by
+// the host matches the Merkle root.
+func (hd *hostDownloader) UnverifiedSector(root crypto.Hash) ([]byte, error) {
+	return hd.sector(root, false)
+}
+
+// sector retrieves the sector with the specified Merkle root, checking the
+// data against the root if verify is set.
+func (hd *hostDownloader) sector(root crypto.Hash, verify bool) ([]byte, error) {
 	hd.mu.Lock()
 	defer hd.mu.Unlock()
 	if hd.invalid {
 		return nil, errInvalidDownloader
 	}
-	contract, sector, err := hd.downloader.Sector(root)
+	download := hd.downloader.Sector
+	if !verify {
+		download = hd.downloader.UnverifiedSector
+	}
+	contract, sector, err := download(root)
+	hd.failed = err != nil
 	if err != nil {
 		return nil, err
 	}
@@ -90,8 +114,10 @@ func (hd *hostDownloader) Sector(root crypto.Hash) ([]byte, error) {
 	return sector, nil
 }
 
-// Close cleanly terminates the download loop with the host and closes the
-// connection.
+// Close releases the hostDownloader. Once the last client has released it,
+// the download loop with the host is terminated after sessionIdleTimeout,
+// unless the hostDownloader is reused in the meantime. If the last download
+// failed, the connection is closed right away.
 func (hd *hostDownloader) Close() error {
 	hd.mu.Lock()
 	defer hd.mu.Unlock()
@@ -101,6 +127,27 @@ func (hd *hostDownloader) Close() error {
 	if hd.invalid || hd.clients > 0 {
 		return nil
 	}
+	if hd.failed {
+		return hd.close()
+	}
+	hd.idleTimer = time.AfterFunc(sessionIdleTimeout, func() { hd.closeIdle() })
+	return nil
+}
+
+// closeIdle terminates the download loop with the host if the
+// hostDownloader has no clients.
+func (hd *hostDownloader) closeIdle() error {
+	hd.mu.Lock()
+	defer hd.mu.Unlock()
+	if hd.invalid || hd.clients > 0 {
+		return nil
+	}
+	return hd.close()
+}
+
+// close terminates the download loop with the host and removes the
+// hostDownloader from the contractor. hd.mu must be held.
+func (hd *hostDownloader) close() error {
 	hd.invalid = true
 	hd.contractor.mu.Lock()
 	delete(hd.contractor.downloaders, hd.contractID)
@@ -125,11 +172,18 @@ func (c *Contractor) Downloader(id types.FileContractID, cancel <-chan struct{})
 	}
 
 	if haveDownloader {
-		// increment number of clients and return
+		// increment number of clients and return, unless the downloader was
+		// closed in the meantime
 		cachedDownloader.mu.Lock()
-		cachedDownloader.clients++
+		if !cachedDownloader.invalid {
+			cachedDownloader.clients++
+			if cachedDownloader.idleTimer != nil {
+				cachedDownloader.idleTimer.Stop()
+			}
+			cachedDownloader.mu.Unlock()
+			return cachedDownloader, nil
+		}
 		cachedDownloader.mu.Unlock()
-		return cachedDownloader, nil
 	}
 
 	host, haveHost := c.hdb.Host(contract.HostPublicKey)
@@ -145,6 +199,10 @@ func (c *Contractor) Downloader(id types.FileContractID, cancel <-chan struct{})
 	// Update the contract to the most recent net address for the host.
 	contract.NetAddress = host.NetAddress
 
+	// An idle editor of the contract is closed, so that the contract can be
+	// revised.
+	c.managedCloseIdleEditor(contract.ID)
+
 	// acquire revising lock
 	c.mu.Lock()
 	alreadyRevising := c.revising[contract.ID]
@@ -216,3 +274,9 @@ func (c *Contractor) Downloader(id types.FileContractID, cancel <-chan struct{})
 
 	return hd, nil
 }
+
+// DownloaderContext returns a Downloader in the same way as Downloader, but
+// aborts connecting to the host once ctx is cancelled or its deadline passes.
+func (c *Contractor) DownloaderContext(ctx context.Context, id types.FileContractID) (Downloader, error) {
+	return c.Downloader(id, ctx.Done())
+}
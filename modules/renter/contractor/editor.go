@@ -3,6 +3,7 @@ package contractor
 import (
 	"errors"
 	"sync"
+	"time"
 
 	"github.com/pachisi456/Sia/build"
 	"github.com/pachisi456/Sia/crypto"
@@ -11,7 +12,10 @@ import (
 	"github.com/pachisi456/Sia/types"
 )
 
-var errInvalidEditor = errors.New("editor has been invalidated because its contract is being renewed")
+var (
+	errInvalidEditor = errors.New("editor has been invalidated because its contract is being renewed")
+	errRevisionLimit = errors.New("contract is near the revision limit and must be renewed")
+)
 
 // the contractor will cap host's MaxCollateral setting to this value
 var maxUploadCollateral = types.SiacoinPrecision.Mul64(1e3).Div(modules.BlockBytesPerMonthTerabyte) // 1k SC / TB / Month
@@ -48,12 +52,18 @@ type Editor interface {
 // A hostEditor modifies a Contract by calling the revise RPC on a host. It
 // implements the Editor interface. hostEditors are safe for use by
 // multiple goroutines.
+//
+// Once its last client closes it, a hostEditor keeps its connection to the
+// host open for sessionIdleTimeout, so that the next upload can reuse the
+// session instead of performing a new handshake.
 type hostEditor struct {
 	clients    int // safe to Close when 0
 	contract   modules.RenterContract
 	contractor *Contractor
 	editor     *proto.Editor
-	invalid    bool // true if invalidate has been called
+	failed     bool        // true if the last revision failed
+	idleTimer  *time.Timer // closes the idle hostEditor
+	invalid    bool        // true if invalidate has been called
 	mu         sync.Mutex
 }
 
@@ -84,8 +94,10 @@ func (he *hostEditor) ContractID() types.FileContractID { return he.contract.ID
 // store the file.
 func (he *hostEditor) EndHeight() types.BlockHeight { return he.contract.EndHeight() }
 
-// Close cleanly terminates the revision loop with the host and closes the
-// connection.
+// Close releases the hostEditor. Once the last client has released it, the
+// revision loop with the host is terminated after sessionIdleTimeout, unless
+// the hostEditor is reused in the meantime. If the last revision failed, the
+// connection is closed right away.
 func (he *hostEditor) Close() error {
 	he.mu.Lock()
 	defer he.mu.Unlock()
@@ -95,6 +107,27 @@ func (he *hostEditor) Close() error {
 	if he.invalid || he.clients > 0 {
 		return nil
 	}
+	if he.failed {
+		return he.close()
+	}
+	he.idleTimer = time.AfterFunc(sessionIdleTimeout, func() { he.closeIdle() })
+	return nil
+}
+
+// closeIdle terminates the revision loop with the host if the hostEditor has
+// no clients.
+func (he *hostEditor) closeIdle() error {
+	he.mu.Lock()
+	defer he.mu.Unlock()
+	if he.invalid || he.clients > 0 {
+		return nil
+	}
+	return he.close()
+}
+
+// close terminates the revision loop with the host and removes the hostEditor
+// from the contractor. he.mu must be held.
+func (he *hostEditor) close() error {
 	he.invalid = true
 	he.contractor.mu.Lock()
 	delete(he.contractor.editors, he.contract.ID)
@@ -107,14 +140,31 @@ func (he *hostEditor) Close() error {
 func (he *hostEditor) Upload(data []byte) (_ crypto.Hash, err error) {
 	he.mu.Lock()
 	defer he.mu.Unlock()
+	defer func() { he.failed = err != nil }()
 	if he.invalid {
 		return crypto.Hash{}, errInvalidEditor
 	}
+	he.contractor.mu.RLock()
+	maxRevisions := he.contractor.maxRevisions
+	he.contractor.mu.RUnlock()
+	if nearRevisionLimit(he.contract, maxRevisions) {
+		go he.contractor.threadedContractMaintenance()
+		return crypto.Hash{}, errRevisionLimit
+	}
 	contract, sectorRoot, err := he.editor.Upload(data)
 	if err != nil {
 		return crypto.Hash{}, err
 	}
 	he.contractor.mu.Lock()
+	// Add the new root to the index of the contract. If the contract gained
+	// more than one root, e.g. because the editor was created from a cached
+	// revision, the index is rebuilt by the next call to HasSector instead.
+	oldRoots := len(he.contractor.contracts[contract.ID].MerkleRoots)
+	if roots, indexed := he.contractor.sectorRoots[contract.ID]; indexed && len(contract.MerkleRoots) == oldRoots+1 {
+		roots[sectorRoot] = struct{}{}
+	} else {
+		delete(he.contractor.sectorRoots, contract.ID)
+	}
 	he.contractor.contracts[contract.ID] = contract
 	he.contractor.persist.update(updateUploadRevision{
 		NewRevisionTxn:     contract.LastRevisionTxn,
@@ -133,6 +183,7 @@ func (he *hostEditor) Upload(data []byte) (_ crypto.Hash, err error) {
 func (he *hostEditor) Delete(root crypto.Hash) (err error) {
 	he.mu.Lock()
 	defer he.mu.Unlock()
+	defer func() { he.failed = err != nil }()
 	if he.invalid {
 		return errInvalidEditor
 	}
@@ -143,6 +194,7 @@ func (he *hostEditor) Delete(root crypto.Hash) (err error) {
 
 	he.contractor.mu.Lock()
 	he.contractor.contracts[contract.ID] = contract
+	delete(he.contractor.sectorRoots, contract.ID)
 	he.contractor.saveSync()
 	he.contractor.mu.Unlock()
 	he.contract = contract
@@ -154,6 +206,7 @@ func (he *hostEditor) Delete(root crypto.Hash) (err error) {
 func (he *hostEditor) Modify(oldRoot, newRoot crypto.Hash, offset uint64, newData []byte) (err error) {
 	he.mu.Lock()
 	defer he.mu.Unlock()
+	defer func() { he.failed = err != nil }()
 	if he.invalid {
 		return errInvalidEditor
 	}
@@ -163,6 +216,7 @@ func (he *hostEditor) Modify(oldRoot, newRoot crypto.Hash, offset uint64, newDat
 	}
 	he.contractor.mu.Lock()
 	he.contractor.contracts[contract.ID] = contract
+	delete(he.contractor.sectorRoots, contract.ID)
 	he.contractor.saveSync()
 	he.contractor.mu.Unlock()
 	he.contract = contract
@@ -179,18 +233,29 @@ func (c *Contractor) Editor(id types.FileContractID, cancel <-chan struct{}) (_
 	height := c.blockHeight
 	contract, haveContract := c.contracts[id]
 	renewing := c.renewing[id]
+	maxRevisions := c.maxRevisions
 	c.mu.RUnlock()
 
 	if renewing {
 		return nil, errors.New("currently renewing that contract")
+	} else if haveContract && nearRevisionLimit(contract, maxRevisions) {
+		go c.threadedContractMaintenance()
+		return nil, errRevisionLimit
 	}
 
 	if haveEditor {
-		// increment number of clients and return
+		// increment number of clients and return, unless the editor was
+		// closed in the meantime
 		cachedEditor.mu.Lock()
-		cachedEditor.clients++
+		if !cachedEditor.invalid {
+			cachedEditor.clients++
+			if cachedEditor.idleTimer != nil {
+				cachedEditor.idleTimer.Stop()
+			}
+			cachedEditor.mu.Unlock()
+			return cachedEditor, nil
+		}
 		cachedEditor.mu.Unlock()
-		return cachedEditor, nil
 	}
 
 	host, haveHost := c.hdb.Host(contract.HostPublicKey)
@@ -212,6 +277,10 @@ func (c *Contractor) Editor(id types.FileContractID, cancel <-chan struct{}) (_
 	}
 	contract.NetAddress = host.NetAddress
 
+	// An idle downloader of the contract is closed, so that the contract can
+	// be revised.
+	c.managedCloseIdleDownloader(contract.ID)
+
 	// acquire revising lock
 	c.mu.Lock()
 	alreadyRevising := c.revising[contract.ID]
@@ -311,6 +311,93 @@ func TestIntegrationUploadDownload(t *testing.T) {
 	}
 }
 
+// TestIntegrationSessionReuse tests that the contractor keeps host sessions
+// open after they are closed, and reuses them.
+func TestIntegrationSessionReuse(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	// create testing trio
+	h, c, _, err := newTestingTrio(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	defer c.Close()
+
+	// get the host's entry from the db
+	hostEntry, ok := c.hdb.Host(h.PublicKey())
+	if !ok {
+		t.Fatal("no entry for host in db")
+	}
+
+	// form a contract with the host
+	contract, err := c.managedNewContract(hostEntry, types.SiacoinPrecision.Mul64(50), c.blockHeight+100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.mu.Lock()
+	c.contracts[contract.ID] = contract
+	c.mu.Unlock()
+
+	// upload twice; the second editor should reuse the first one's session
+	editor, err := c.Editor(contract.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := fastrand.Bytes(int(modules.SectorSize))
+	root, err := editor.Upload(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := editor.Close(); err != nil {
+		t.Fatal(err)
+	}
+	editor2, err := c.Editor(contract.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if editor2 != editor {
+		t.Fatal("editor session was not reused")
+	}
+	if _, err := editor2.Upload(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := editor2.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the idle editor is closed to make room for a downloader
+	downloader, err := c.Downloader(contract.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	retrieved, err := downloader.Sector(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, retrieved) {
+		t.Fatal("downloaded data does not match original")
+	}
+	if err := downloader.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the idle downloader is closed after sessionIdleTimeout
+	err = build.Retry(50, sessionIdleTimeout/10, func() error {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+		if len(c.downloaders) != 0 || len(c.revising) != 0 {
+			return errors.New("idle downloader was not closed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 // TestIntegrationDelete tests that the contractor can delete a sector from a
 // contract previously formed with a host.
 func TestIntegrationDelete(t *testing.T) {
@@ -0,0 +1,130 @@
+package contractor
+
+import (
+	"errors"
+
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/types"
+)
+
+var (
+	errHostFiltered          = errors.New("host is excluded by the host filter")
+	errUnknownHostFilterMode = errors.New("unknown host filter mode")
+)
+
+// allowedHost reports whether the host filter allows contracts with the
+// specified host. The contractor's lock must be held.
+func (c *Contractor) allowedHost(pk types.SiaPublicKey) bool {
+	_, filtered := c.filteredHosts[pk.String()]
+	switch c.filterMode {
+	case modules.HostFilterModeBlacklist:
+		return !filtered
+	case modules.HostFilterModeWhitelist:
+		return filtered
+	default:
+		return true
+	}
+}
+
+// HostFilter returns the host filter mode and the filtered hosts.
+func (c *Contractor) HostFilter() (modules.HostFilterMode, []types.SiaPublicKey) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	hosts := make([]types.SiaPublicKey, 0, len(c.filteredHosts))
+	for _, host := range c.filteredHosts {
+		hosts = append(hosts, host)
+	}
+	return c.filterMode, hosts
+}
+
+// SetHostFilter sets the hosts that contracts may be formed with. In blacklist
+// mode, no contracts are formed with the given hosts; in whitelist mode,
+// contracts are only formed with them. Existing contracts with hosts that are
+// no longer allowed lose their utility, so that they are not renewed and the
+// renter moves their data to other hosts.
+func (c *Contractor) SetHostFilter(mode modules.HostFilterMode, hosts []types.SiaPublicKey) error {
+	switch mode {
+	case modules.HostFilterModeDisable, modules.HostFilterModeBlacklist, modules.HostFilterModeWhitelist:
+	default:
+		return errUnknownHostFilterMode
+	}
+	filteredHosts := make(map[string]types.SiaPublicKey, len(hosts))
+	for _, host := range hosts {
+		filteredHosts[host.String()] = host
+	}
+
+	c.mu.Lock()
+	c.filterMode = mode
+	c.filteredHosts = filteredHosts
+	for id, contract := range c.contracts {
+		if !c.allowedHost(contract.HostPublicKey) {
+			contract.GoodForUpload = false
+			contract.GoodForRenew = false
+			c.contracts[id] = contract
+		}
+	}
+	err := c.saveSync()
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// Replace the contracts that lost their utility.
+	go c.threadedContractMaintenance()
+	return nil
+}
+
+// managedRandomHosts returns up to n random hosts that the host filter allows
+// contracts with, excluding the specified hosts. In whitelist mode, the hosts
+// are drawn from the whitelist instead of the hostdb's random selection.
+// Otherwise, if subnet diversity is enabled, no two hosts share a subnet, and
+// no host shares a subnet with the host of an existing contract.
+func (c *Contractor) managedRandomHosts(n int, exclude []types.SiaPublicKey) []modules.HostDBEntry {
+	c.mu.RLock()
+	mode := c.filterMode
+	filtered := make([]types.SiaPublicKey, 0, len(c.filteredHosts))
+	for _, host := range c.filteredHosts {
+		filtered = append(filtered, host)
+	}
+	var occupied []types.SiaPublicKey
+	if c.subnetDiversity {
+		occupied = make([]types.SiaPublicKey, 0, len(c.contracts))
+		for _, contract := range c.contracts {
+			occupied = append(occupied, contract.HostPublicKey)
+		}
+	}
+	c.mu.RUnlock()
+
+	randomHosts := c.hdb.RandomHosts
+	if occupied != nil {
+		randomHosts = func(n int, exclude []types.SiaPublicKey) []modules.HostDBEntry {
+			return c.hdb.RandomDiverseHosts(n, exclude, occupied)
+		}
+	}
+
+	switch mode {
+	case modules.HostFilterModeBlacklist:
+		return randomHosts(n, append(exclude, filtered...))
+	case modules.HostFilterModeWhitelist:
+		excluded := make(map[string]struct{}, len(exclude))
+		for _, pk := range exclude {
+			excluded[pk.String()] = struct{}{}
+		}
+		var hosts []modules.HostDBEntry
+		for _, pk := range filtered {
+			if len(hosts) >= n {
+				break
+			}
+			if _, ok := excluded[pk.String()]; ok {
+				continue
+			}
+			host, exists := c.hdb.Host(pk)
+			if exists && host.AcceptingContracts {
+				hosts = append(hosts, host)
+			}
+		}
+		return hosts
+	default:
+		return randomHosts(n, exclude)
+	}
+}
@@ -0,0 +1,199 @@
+package contractor
+
+import (
+	"testing"
+
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/types"
+)
+
+// filterHostDB is a hostDB that knows a fixed set of hosts. RandomHosts
+// returns every host that is not excluded.
+type filterHostDB struct {
+	stubHostDB
+	hosts []modules.HostDBEntry
+}
+
+func (hdb filterHostDB) Host(pk types.SiaPublicKey) (modules.HostDBEntry, bool) {
+	for _, host := range hdb.hosts {
+		if host.PublicKey.String() == pk.String() {
+			return host, true
+		}
+	}
+	return modules.HostDBEntry{}, false
+}
+
+func (hdb filterHostDB) RandomHosts(n int, exclude []types.SiaPublicKey) []modules.HostDBEntry {
+	var hosts []modules.HostDBEntry
+outer:
+	for _, host := range hdb.hosts {
+		for _, pk := range exclude {
+			if pk.String() == host.PublicKey.String() {
+				continue outer
+			}
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts
+}
+
+// RandomDiverseHosts returns every host that is not excluded, treating the
+// host part of the NetAddress as the subnet.
+func (hdb filterHostDB) RandomDiverseHosts(n int, exclude, occupied []types.SiaPublicKey) []modules.HostDBEntry {
+	subnets := make(map[string]struct{})
+	for _, pk := range occupied {
+		if host, exists := hdb.Host(pk); exists {
+			subnets[host.NetAddress.Host()] = struct{}{}
+		}
+	}
+	var hosts []modules.HostDBEntry
+	for _, host := range hdb.RandomHosts(n, exclude) {
+		if _, exists := subnets[host.NetAddress.Host()]; !exists {
+			subnets[host.NetAddress.Host()] = struct{}{}
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// TestHostFilter probes the SetHostFilter method of the contractor.
+func TestHostFilter(t *testing.T) {
+	foo := types.SiaPublicKey{Key: []byte("foo")}
+	bar := types.SiaPublicKey{Key: []byte("bar")}
+	baz := types.SiaPublicKey{Key: []byte("baz")}
+	var hdb filterHostDB
+	for _, pk := range []types.SiaPublicKey{foo, bar, baz} {
+		host := modules.HostDBEntry{PublicKey: pk}
+		host.AcceptingContracts = true
+		hdb.hosts = append(hdb.hosts, host)
+	}
+	c := &Contractor{
+		hdb:     hdb,
+		persist: new(memPersist),
+
+		contracts: map[types.FileContractID]modules.RenterContract{
+			{0}: {ID: types.FileContractID{0}, HostPublicKey: foo, GoodForUpload: true, GoodForRenew: true},
+			{1}: {ID: types.FileContractID{1}, HostPublicKey: bar, GoodForUpload: true, GoodForRenew: true},
+		},
+		filterMode:    modules.HostFilterModeDisable,
+		filteredHosts: make(map[string]types.SiaPublicKey),
+	}
+	// randomHosts returns the String() of the hosts that contracts could be
+	// formed with.
+	randomHosts := func(exclude ...types.SiaPublicKey) map[string]bool {
+		hosts := make(map[string]bool)
+		for _, host := range c.managedRandomHosts(10, exclude) {
+			hosts[host.PublicKey.String()] = true
+		}
+		return hosts
+	}
+
+	if err := c.SetHostFilter("invalid", nil); err != errUnknownHostFilterMode {
+		t.Fatal("expected errUnknownHostFilterMode, got", err)
+	}
+	if hosts := randomHosts(); len(hosts) != 3 {
+		t.Fatal("expected all hosts without a filter, got", hosts)
+	}
+
+	// Blacklist a host.
+	if err := c.SetHostFilter(modules.HostFilterModeBlacklist, []types.SiaPublicKey{foo}); err != nil {
+		t.Fatal(err)
+	}
+	if contract := c.contracts[types.FileContractID{0}]; contract.GoodForUpload || contract.GoodForRenew {
+		t.Fatal("contract with a blacklisted host kept its utility")
+	}
+	if contract := c.contracts[types.FileContractID{1}]; !contract.GoodForUpload || !contract.GoodForRenew {
+		t.Fatal("contract with an allowed host lost its utility")
+	}
+	if hosts := randomHosts(); len(hosts) != 2 || hosts[foo.String()] {
+		t.Fatal("blacklisted host was selected:", hosts)
+	}
+	if _, err := c.managedNewContract(hdb.hosts[0], types.SiacoinPrecision, 100); err != errHostFiltered {
+		t.Fatal("expected errHostFiltered, got", err)
+	}
+
+	// The filter should be persisted.
+	c.filterMode = modules.HostFilterModeDisable
+	c.filteredHosts = make(map[string]types.SiaPublicKey)
+	c.contracts = make(map[types.FileContractID]modules.RenterContract)
+	c.cachedRevisions = make(map[types.FileContractID]cachedRevision)
+	c.oldContracts = make(map[types.FileContractID]modules.RenterContract)
+	c.renewedIDs = make(map[types.FileContractID]types.FileContractID)
+	if err := c.load(); err != nil {
+		t.Fatal(err)
+	}
+	mode, hosts := c.HostFilter()
+	if mode != modules.HostFilterModeBlacklist || len(hosts) != 1 || hosts[0].String() != foo.String() {
+		t.Fatal("host filter was not persisted:", mode, hosts)
+	}
+
+	// Whitelist a host. Contracts are only formed with it, and only if there
+	// is no contract with it yet.
+	if err := c.SetHostFilter(modules.HostFilterModeWhitelist, []types.SiaPublicKey{baz}); err != nil {
+		t.Fatal(err)
+	}
+	if contract := c.contracts[types.FileContractID{1}]; contract.GoodForUpload || contract.GoodForRenew {
+		t.Fatal("contract with a host that is not whitelisted kept its utility")
+	}
+	if hosts := randomHosts(); len(hosts) != 1 || !hosts[baz.String()] {
+		t.Fatal("expected only the whitelisted host, got", hosts)
+	}
+	if hosts := randomHosts(baz); len(hosts) != 0 {
+		t.Fatal("excluded host was selected:", hosts)
+	}
+}
+
+// TestSubnetDiversity checks that the contractor avoids hosts sharing a
+// subnet with each other or with the host of an existing contract once subnet
+// diversity is enabled.
+func TestSubnetDiversity(t *testing.T) {
+	foo := types.SiaPublicKey{Key: []byte("foo")}
+	bar := types.SiaPublicKey{Key: []byte("bar")}
+	baz := types.SiaPublicKey{Key: []byte("baz")}
+	qux := types.SiaPublicKey{Key: []byte("qux")}
+	hdb := filterHostDB{hosts: []modules.HostDBEntry{
+		{PublicKey: foo, NetAddress: "1.1.1.1:9982"},
+		{PublicKey: bar, NetAddress: "1.1.1.1:9983"},
+		{PublicKey: baz, NetAddress: "2.2.2.2:9982"},
+		{PublicKey: qux, NetAddress: "2.2.2.2:9983"},
+	}}
+	c := &Contractor{
+		hdb:     hdb,
+		persist: new(memPersist),
+
+		contracts: map[types.FileContractID]modules.RenterContract{
+			{0}: {ID: types.FileContractID{0}, HostPublicKey: foo},
+		},
+		filterMode:    modules.HostFilterModeDisable,
+		filteredHosts: make(map[string]types.SiaPublicKey),
+	}
+	exclude := []types.SiaPublicKey{foo}
+
+	if hosts := c.managedRandomHosts(10, exclude); len(hosts) != 3 {
+		t.Fatal("expected all other hosts without subnet diversity, got", hosts)
+	}
+	if err := c.SetSubnetDiversity(true); err != nil {
+		t.Fatal(err)
+	}
+	if !c.SubnetDiversity() {
+		t.Fatal("subnet diversity was not enabled")
+	}
+	hosts := c.managedRandomHosts(10, exclude)
+	if len(hosts) != 1 || hosts[0].NetAddress.Host() != "2.2.2.2" {
+		t.Fatal("expected a single host outside of the occupied subnet, got", hosts)
+	}
+
+	// The setting is persisted.
+	c.subnetDiversity = false
+	c.cachedRevisions = make(map[types.FileContractID]cachedRevision)
+	c.oldContracts = make(map[types.FileContractID]modules.RenterContract)
+	c.renewedIDs = make(map[types.FileContractID]types.FileContractID)
+	c.contractStatus = make(map[types.FileContractID]modules.ContractStatus)
+	c.watchedTxns = make(map[types.FileContractID]watchedTxn)
+	if err := c.load(); err != nil {
+		t.Fatal(err)
+	}
+	if !c.subnetDiversity {
+		t.Fatal("subnet diversity was not persisted")
+	}
+}
@@ -0,0 +1,33 @@
+package contractor
+
+import (
+	"github.com/pachisi456/Sia/modules"
+)
+
+// ImportContracts adds contracts that were formed by another contractor with
+// the same wallet, e.g. contracts restored from a metadata backup, and returns
+// the number of contracts that were added. Contracts that are already known,
+// that were renewed, or that have ended are skipped. The imported contracts
+// are used like the contractor's own; if the other contractor revised a
+// contract after it was exported, revising it fails and it is replaced during
+// contract maintenance.
+func (c *Contractor) ImportContracts(contracts []modules.RenterContract) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var imported int
+	for _, contract := range contracts {
+		if _, exists := c.contracts[contract.ID]; exists {
+			continue
+		} else if _, renewed := c.renewedIDs[contract.ID]; renewed {
+			continue
+		} else if contract.EndHeight() <= c.blockHeight {
+			continue
+		}
+		c.contracts[contract.ID] = contract
+		imported++
+	}
+	if imported == 0 {
+		return 0, nil
+	}
+	return imported, c.saveSync()
+}
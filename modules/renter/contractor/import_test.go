@@ -0,0 +1,46 @@
+package contractor
+
+import (
+	"testing"
+
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/types"
+)
+
+// TestImportContracts checks that only unknown contracts that have not ended
+// are imported.
+func TestImportContracts(t *testing.T) {
+	c := &Contractor{
+		persist:     new(memPersist),
+		blockHeight: 10,
+		contracts: map[types.FileContractID]modules.RenterContract{
+			{1}: {ID: types.FileContractID{1}},
+		},
+		renewedIDs: map[types.FileContractID]types.FileContractID{
+			{2}: {1},
+		},
+	}
+	contract := func(id byte, endHeight types.BlockHeight) modules.RenterContract {
+		var rc modules.RenterContract
+		rc.ID = types.FileContractID{id}
+		rc.LastRevision.NewWindowStart = endHeight
+		return rc
+	}
+	n, err := c.ImportContracts([]modules.RenterContract{
+		contract(1, 20), // known
+		contract(2, 20), // renewed
+		contract(3, 5),  // ended
+		contract(4, 20),
+	})
+	if err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatal("expected 1 imported contract, got", n)
+	}
+	if _, exists := c.contracts[types.FileContractID{4}]; !exists || len(c.contracts) != 2 {
+		t.Fatal("wrong contracts after import:", c.contracts)
+	}
+	if _, saved := c.persist.(*memPersist).Contracts[types.FileContractID{4}.String()]; !saved {
+		t.Fatal("imported contract was not saved")
+	}
+}
@@ -17,6 +17,10 @@ package contractor
 // In the event of power failure or other serious disruption, the most recent
 // update set may be only partially written. Partially written update sets are
 // simply ignored when reading the journal.
+//
+// If the journal has a key, the initial object and each update set are
+// encrypted with it and written as base64-encoded JSON strings. The metadata
+// is not encrypted, and identifies the journal as encrypted.
 
 import (
 	"encoding/json"
@@ -39,16 +43,59 @@ var journalMeta = persist.Metadata{
 	Version: "1.1.1",
 }
 
+// encryptedJournalMeta is the metadata of a journal that is encrypted.
+var encryptedJournalMeta = persist.Metadata{
+	Header:  "Encrypted Contractor Journal",
+	Version: "1.1.1",
+}
+
 // A journal is a log of updates to a JSON object.
 type journal struct {
 	f        *os.File
 	filename string
+	key      *crypto.TwofishKey
+}
+
+// journalMetadata returns the metadata of a journal with the given key.
+func journalMetadata(key *crypto.TwofishKey) persist.Metadata {
+	if key != nil {
+		return encryptedJournalMeta
+	}
+	return journalMeta
+}
+
+// encodeObject writes v to enc, encrypting it if key is not nil.
+func encodeObject(enc *json.Encoder, key *crypto.TwofishKey, v interface{}) error {
+	if key == nil {
+		return enc.Encode(v)
+	}
+	plaintext, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return enc.Encode([]byte(key.EncryptBytes(plaintext)))
+}
+
+// decodeObject reads v from dec, decrypting it if key is not nil.
+func decodeObject(dec *json.Decoder, key *crypto.TwofishKey, v interface{}) error {
+	if key == nil {
+		return dec.Decode(v)
+	}
+	var ciphertext []byte
+	if err := dec.Decode(&ciphertext); err != nil {
+		return err
+	}
+	plaintext, err := key.DecryptBytes(ciphertext)
+	if err != nil {
+		return persist.ErrBadEncryptionKey
+	}
+	return json.Unmarshal(plaintext, v)
 }
 
 // update applies the updateSet atomically to j. It syncs the underlying file
 // before returning.
 func (j *journal) update(us updateSet) error {
-	if err := json.NewEncoder(j.f).Encode(us); err != nil {
+	if err := encodeObject(json.NewEncoder(j.f), j.key, us); err != nil {
 		return err
 	}
 	return j.f.Sync()
@@ -61,7 +108,7 @@ func (j *journal) checkpoint(data contractorPersist) error {
 		// Sanity check - applying the updates to the initial object should
 		// result in a contractorPersist that matches data.
 		var data2 contractorPersist
-		j2, err := openJournal(j.filename, &data2)
+		j2, err := openJournalWithKey(j.filename, j.key, &data2)
 		if err != nil {
 			panic("could not open journal for sanity check: " + err.Error())
 		}
@@ -92,10 +139,10 @@ func (j *journal) checkpoint(data contractorPersist) error {
 		return err
 	}
 	enc := json.NewEncoder(tmp)
-	if err := enc.Encode(journalMeta); err != nil {
+	if err := enc.Encode(journalMetadata(j.key)); err != nil {
 		return err
 	}
-	if err := enc.Encode(data); err != nil {
+	if err := encodeObject(enc, j.key, data); err != nil {
 		return err
 	}
 	if err := tmp.Sync(); err != nil {
@@ -131,28 +178,42 @@ func (j *journal) Close() error {
 
 // newJournal creates a new journal, using data as the initial object.
 func newJournal(filename string, data contractorPersist) (*journal, error) {
+	return newJournalWithKey(filename, nil, data)
+}
+
+// newJournalWithKey creates a new journal that is encrypted with key, using
+// data as the initial object. The journal is not encrypted if key is nil.
+func newJournalWithKey(filename string, key *crypto.TwofishKey, data contractorPersist) (*journal, error) {
 	// safely create the journal
 	f, err := os.Create(filename)
 	if err != nil {
 		return nil, err
 	}
 	enc := json.NewEncoder(f)
-	if err := enc.Encode(journalMeta); err != nil {
+	if err := enc.Encode(journalMetadata(key)); err != nil {
 		return nil, err
 	}
-	if err := enc.Encode(data); err != nil {
+	if err := encodeObject(enc, key, data); err != nil {
 		return nil, err
 	}
 	if err := f.Sync(); err != nil {
 		return nil, err
 	}
 
-	return &journal{f: f, filename: filename}, nil
+	return &journal{f: f, filename: filename, key: key}, nil
 }
 
 // openJournal opens the supplied journal and decodes the reconstructed
 // contractorPersist into data.
 func openJournal(filename string, data *contractorPersist) (*journal, error) {
+	return openJournalWithKey(filename, nil, data)
+}
+
+// openJournalWithKey opens the supplied journal and decodes the reconstructed
+// contractorPersist into data, decrypting the journal with key if it is
+// encrypted. A plaintext journal can be opened with a key; the returned
+// journal stays unencrypted until its next checkpoint.
+func openJournalWithKey(filename string, key *crypto.TwofishKey, data *contractorPersist) (*journal, error) {
 	// Open file handle for reading and writing.
 	f, err := os.OpenFile(filename, os.O_RDWR, 0)
 	if err != nil {
@@ -164,14 +225,20 @@ func openJournal(filename string, data *contractorPersist) (*journal, error) {
 	var meta persist.Metadata
 	if err = dec.Decode(&meta); err != nil {
 		return nil, err
-	} else if meta.Header != journalMeta.Header {
+	} else if meta.Header == encryptedJournalMeta.Header && key == nil {
+		return nil, persist.ErrNoEncryptionKey
+	} else if meta.Header == journalMeta.Header {
+		// the journal was written without a key
+		key = nil
+	} else if meta.Header != encryptedJournalMeta.Header {
 		return nil, fmt.Errorf("expected header %q, got %q", journalMeta.Header, meta.Header)
-	} else if meta.Version != journalMeta.Version {
+	}
+	if meta.Version != journalMeta.Version {
 		return nil, fmt.Errorf("journal version (%s) is incompatible with the current version (%s)", meta.Version, journalMeta.Version)
 	}
 
 	// Decode the initial object.
-	if err = dec.Decode(data); err != nil {
+	if err = decodeObject(dec, key, data); err != nil {
 		return nil, err
 	}
 
@@ -189,7 +256,7 @@ func openJournal(filename string, data *contractorPersist) (*journal, error) {
 	// Decode each set of updates and apply them to data.
 	for {
 		var set updateSet
-		if err = dec.Decode(&set); err == io.EOF || err == io.ErrUnexpectedEOF {
+		if err = decodeObject(dec, key, &set); err == io.EOF || err == io.ErrUnexpectedEOF {
 			// unexpected EOF means the last update was corrupted; skip it
 			break
 		} else if err != nil {
@@ -204,6 +271,7 @@ func openJournal(filename string, data *contractorPersist) (*journal, error) {
 	return &journal{
 		f:        f,
 		filename: filename,
+		key:      key,
 	}, nil
 }
 
@@ -11,6 +11,8 @@ import (
 
 	"github.com/pachisi456/Sia/build"
 	"github.com/pachisi456/Sia/crypto"
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/persist"
 	"github.com/pachisi456/Sia/types"
 )
 
@@ -67,6 +69,73 @@ func TestJournal(t *testing.T) {
 	}
 }
 
+// TestEncryptedJournal checks that a plaintext journal is encrypted when it is
+// loaded with a key, and that it can only be loaded with that key afterwards.
+func TestEncryptedJournal(t *testing.T) {
+	dir := build.TempDir("contractor", t.Name())
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	var id types.FileContractID
+	id[0] = 1
+	sk, _ := crypto.GenerateKeyPair()
+	contract := modules.RenterContract{ID: id, SecretKey: sk}
+	initial := contractorPersist{
+		Contracts: map[string]modules.RenterContract{id.String(): contract},
+	}
+
+	// Write a plaintext journal.
+	p := newPersist(dir)
+	if err := p.save(initial); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.update(updateCachedDownloadRevision{Revision: types.FileContractRevision{ParentID: id}}); err != nil {
+		t.Fatal(err)
+	}
+	p.Close()
+
+	// Loading the journal with a key encrypts it.
+	key := crypto.GenerateTwofishKey()
+	p = newEncryptedPersist(dir, key)
+	var data contractorPersist
+	if err := p.load(&data); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.update(updateCachedDownloadRevision{Revision: types.FileContractRevision{ParentID: id, NewRevisionNumber: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	p.Close()
+	contents, err := ioutil.ReadFile(p.filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(contents, []byte(id.String())) {
+		t.Fatal("journal was not encrypted")
+	}
+
+	// The journal can only be loaded with the key.
+	p = newPersist(dir)
+	if err := p.load(&data); err != persist.ErrNoEncryptionKey {
+		t.Fatal("expected ErrNoEncryptionKey, got", err)
+	}
+	p = newEncryptedPersist(dir, crypto.GenerateTwofishKey())
+	if err := p.load(&data); err != persist.ErrBadEncryptionKey {
+		t.Fatal("expected ErrBadEncryptionKey, got", err)
+	}
+	p = newEncryptedPersist(dir, key)
+	data = contractorPersist{}
+	if err := p.load(&data); err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	if !reflect.DeepEqual(data.Contracts[id.String()].SecretKey, contract.SecretKey) {
+		t.Fatal("contract was not loaded from the encrypted journal")
+	}
+	if data.CachedRevisions[id.String()].Revision.NewRevisionNumber != 2 {
+		t.Fatal("updates were not loaded from the encrypted journal")
+	}
+}
+
 func TestJournalCheckpoint(t *testing.T) {
 	j, cleanup := tempJournal(t)
 	defer cleanup()
@@ -0,0 +1,29 @@
+package contractor
+
+import (
+	"github.com/pachisi456/Sia/metrics"
+)
+
+// contractorMetrics contains the counters that the contractor exports through
+// its metrics registry.
+type contractorMetrics struct {
+	contractsFormed   *metrics.Counter
+	contractsRenewed  *metrics.Counter
+	formationFailures *metrics.Counter
+	renewalFailures   *metrics.Counter
+}
+
+// newContractorMetrics registers the contractor's metrics with r.
+func newContractorMetrics(r *metrics.Registry) contractorMetrics {
+	return contractorMetrics{
+		contractsFormed:   r.Counter("sia_contractor_contracts_formed_total", "Number of contracts formed with hosts."),
+		contractsRenewed:  r.Counter("sia_contractor_contracts_renewed_total", "Number of contracts renewed with hosts."),
+		formationFailures: r.Counter("sia_contractor_formation_failures_total", "Number of failed contract negotiations, by reason."),
+		renewalFailures:   r.Counter("sia_contractor_renewal_failures_total", "Number of failed contract renewals."),
+	}
+}
+
+// Metrics returns the registry containing the contractor's metrics.
+func (c *Contractor) Metrics() *metrics.Registry {
+	return c.metricsRegistry
+}
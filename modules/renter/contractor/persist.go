@@ -14,11 +14,19 @@ type contractorPersist struct {
 	Allowance       modules.Allowance                 `json:"allowance"`
 	BlockHeight     types.BlockHeight                 `json:"blockheight"`
 	CachedRevisions map[string]cachedRevision         `json:"cachedrevisions"`
+	Churn           uint64                            `json:"churn"`
+	ContractStatus  map[string]modules.ContractStatus `json:"contractstatus"`
 	Contracts       map[string]modules.RenterContract `json:"contracts"`
 	CurrentPeriod   types.BlockHeight                 `json:"currentperiod"`
+	FilterMode      modules.HostFilterMode            `json:"filtermode"`
+	FilteredHosts   []types.SiaPublicKey              `json:"filteredhosts"`
 	LastChange      modules.ConsensusChangeID         `json:"lastchange"`
+	MaxChurn        uint64                            `json:"maxchurn"`
+	MaxRevisions    uint64                            `json:"maxrevisions"`
 	OldContracts    []modules.RenterContract          `json:"oldcontracts"`
 	RenewedIDs      map[string]string                 `json:"renewedids"`
+	SubnetDiversity bool                              `json:"subnetdiversity"`
+	WatchedTxns     []watchedTxn                      `json:"watchedtxns"`
 }
 
 // persistData returns the data in the Contractor that will be saved to disk.
@@ -27,17 +35,29 @@ func (c *Contractor) persistData() contractorPersist {
 		Allowance:       c.allowance,
 		BlockHeight:     c.blockHeight,
 		CachedRevisions: make(map[string]cachedRevision),
+		Churn:           c.churn,
+		ContractStatus:  make(map[string]modules.ContractStatus),
 		Contracts:       make(map[string]modules.RenterContract),
 		CurrentPeriod:   c.currentPeriod,
+		FilterMode:      c.filterMode,
 		LastChange:      c.lastChange,
+		MaxChurn:        c.maxChurn,
+		MaxRevisions:    c.maxRevisions,
 		RenewedIDs:      make(map[string]string),
+		SubnetDiversity: c.subnetDiversity,
 	}
 	for _, rev := range c.cachedRevisions {
 		data.CachedRevisions[rev.Revision.ParentID.String()] = rev
 	}
+	for id, status := range c.contractStatus {
+		data.ContractStatus[id.String()] = status
+	}
 	for _, contract := range c.contracts {
 		data.Contracts[contract.ID.String()] = contract
 	}
+	for _, host := range c.filteredHosts {
+		data.FilteredHosts = append(data.FilteredHosts, host)
+	}
 	for _, contract := range c.oldContracts {
 		contract.MerkleRoots = []crypto.Hash{} // prevent roots from being saved to disk twice
 		data.OldContracts = append(data.OldContracts, contract)
@@ -45,6 +65,9 @@ func (c *Contractor) persistData() contractorPersist {
 	for oldID, newID := range c.renewedIDs {
 		data.RenewedIDs[oldID.String()] = newID.String()
 	}
+	for _, wt := range c.watchedTxns {
+		data.WatchedTxns = append(data.WatchedTxns, wt)
+	}
 	return data
 }
 
@@ -106,6 +129,16 @@ func (c *Contractor) load() error {
 	}
 
 	c.lastChange = data.LastChange
+	c.maxRevisions = data.MaxRevisions
+	c.maxChurn = data.MaxChurn
+	c.churn = data.Churn
+	c.subnetDiversity = data.SubnetDiversity
+	if data.FilterMode != "" {
+		c.filterMode = data.FilterMode
+	}
+	for _, host := range data.FilteredHosts {
+		c.filteredHosts[host.String()] = host
+	}
 	for _, contract := range data.OldContracts {
 		c.oldContracts[contract.ID] = contract
 	}
@@ -115,6 +148,14 @@ func (c *Contractor) load() error {
 		newHash.LoadString(newString)
 		c.renewedIDs[types.FileContractID(oldHash)] = types.FileContractID(newHash)
 	}
+	for _, wt := range data.WatchedTxns {
+		c.watchedTxns[wt.ContractID] = wt
+	}
+	for idString, status := range data.ContractStatus {
+		var id crypto.Hash
+		id.LoadString(idString)
+		c.contractStatus[types.FileContractID(id)] = status
+	}
 
 	return nil
 }
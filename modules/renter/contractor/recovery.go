@@ -0,0 +1,170 @@
+package contractor
+
+import (
+	"errors"
+
+	"github.com/pachisi456/Sia/crypto"
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/modules/renter/proto"
+	"github.com/pachisi456/Sia/types"
+)
+
+var errNoRecoverableContracts = errors.New("no contracts formed with the wallet seed were found on the blockchain")
+
+// contractKey derives the renter's key of the contracts with a host from the
+// wallet seed. Contracts are formed with derived keys so that they can be
+// found on the blockchain and recovered using only the seed.
+func contractKey(seed modules.Seed, hostKey types.SiaPublicKey) crypto.SecretKey {
+	sk, _ := crypto.GenerateKeyPairDeterministic(crypto.HashAll("renter contract key", seed, hostKey))
+	return sk
+}
+
+// contractUnlockConditions returns the unlock conditions of a contract
+// between the renter key sk and the host.
+func contractUnlockConditions(sk crypto.SecretKey, hostKey types.SiaPublicKey) types.UnlockConditions {
+	return types.UnlockConditions{
+		PublicKeys: []types.SiaPublicKey{
+			types.Ed25519PublicKey(sk.PublicKey()),
+			hostKey,
+		},
+		SignaturesRequired: 2,
+	}
+}
+
+// recoverableContract is a contract that was found on the blockchain by a
+// contractScanner.
+type recoverableContract struct {
+	id          types.FileContractID
+	contract    types.FileContract
+	host        types.SiaPublicKey
+	startHeight types.BlockHeight
+}
+
+// contractScanner scans the blockchain for contracts whose unlock hash is
+// one of hosts. It keeps the contract with the latest end height of each
+// host, which is the most recent renewal.
+type contractScanner struct {
+	hosts     map[types.UnlockHash]types.SiaPublicKey
+	height    types.BlockHeight
+	contracts map[string]recoverableContract
+}
+
+// ProcessConsensusChange implements modules.ConsensusSetSubscriber.
+func (cs *contractScanner) ProcessConsensusChange(cc modules.ConsensusChange) {
+	for _, block := range cc.RevertedBlocks {
+		if block.ID() != types.GenesisID {
+			cs.height--
+		}
+		for _, txn := range block.Transactions {
+			for i := range txn.FileContracts {
+				id := txn.FileContractID(uint64(i))
+				for host, rc := range cs.contracts {
+					if rc.id == id {
+						delete(cs.contracts, host)
+					}
+				}
+			}
+		}
+	}
+	for _, block := range cc.AppliedBlocks {
+		if block.ID() != types.GenesisID {
+			cs.height++
+		}
+		for _, txn := range block.Transactions {
+			for i, fc := range txn.FileContracts {
+				host, exists := cs.hosts[fc.UnlockHash]
+				if !exists {
+					continue
+				}
+				if rc, exists := cs.contracts[host.String()]; exists && rc.contract.WindowStart >= fc.WindowStart {
+					continue
+				}
+				cs.contracts[host.String()] = recoverableContract{
+					id:          txn.FileContractID(uint64(i)),
+					contract:    fc,
+					host:        host,
+					startHeight: cs.height,
+				}
+			}
+		}
+	}
+}
+
+// RecoverContracts scans the blockchain for contracts that were formed with
+// keys derived from the wallet seed, e.g. by a renter whose persist directory
+// was lost, and adds the ones that have not ended yet. The most recent
+// revision of each contract is requested from its host. It returns the number
+// of contracts that were recovered.
+//
+// The Merkle roots of the sectors stored in recovered contracts are unknown,
+// so recovered contracts are only used to download and are not uploaded to.
+// Only contracts with hosts that are known to the hostdb can be found.
+func (c *Contractor) RecoverContracts() (int, error) {
+	if err := c.tg.Add(); err != nil {
+		return 0, err
+	}
+	defer c.tg.Done()
+
+	seed, _, err := c.wallet.PrimarySeed()
+	if err != nil {
+		return 0, err
+	}
+
+	// Find the contracts.
+	scanner := &contractScanner{
+		hosts:     make(map[types.UnlockHash]types.SiaPublicKey),
+		contracts: make(map[string]recoverableContract),
+	}
+	for _, host := range c.hdb.AllHosts() {
+		uc := contractUnlockConditions(contractKey(seed, host.PublicKey), host.PublicKey)
+		scanner.hosts[uc.UnlockHash()] = host.PublicKey
+	}
+	err = c.cs.ConsensusSetSubscribe(scanner, modules.ConsensusChangeBeginning, c.tg.StopChan())
+	c.cs.Unsubscribe(scanner)
+	if err != nil {
+		return 0, err
+	}
+	if len(scanner.contracts) == 0 {
+		return 0, errNoRecoverableContracts
+	}
+
+	// Fetch the most recent revision of each contract that is not known
+	// already.
+	var recovered []modules.RenterContract
+	for _, rc := range scanner.contracts {
+		c.mu.RLock()
+		_, known := c.contracts[rc.id]
+		_, renewed := c.renewedIDs[rc.id]
+		ended := rc.contract.WindowStart <= c.blockHeight
+		c.mu.RUnlock()
+		if known || renewed || ended {
+			continue
+		}
+		host, exists := c.hdb.Host(rc.host)
+		if !exists {
+			continue
+		}
+		sk := contractKey(seed, rc.host)
+		txn, err := proto.RecentRevision(host, rc.id, sk, c.tg.StopChan())
+		if err != nil {
+			c.log.Printf("WARN: unable to fetch the revision of recovered contract %v from %v: %v", rc.id, host.NetAddress, err)
+			continue
+		}
+		recovered = append(recovered, modules.RenterContract{
+			FileContract:    rc.contract,
+			HostPublicKey:   rc.host,
+			ID:              rc.id,
+			LastRevision:    txn.FileContractRevisions[0],
+			LastRevisionTxn: txn,
+			NetAddress:      host.NetAddress,
+			SecretKey:       sk,
+			StartHeight:     rc.startHeight,
+		})
+	}
+	n, err := c.ImportContracts(recovered)
+	if err != nil {
+		return 0, err
+	}
+	c.log.Printf("Recovered %v of %v contracts found on the blockchain", n, len(scanner.contracts))
+	return n, nil
+}
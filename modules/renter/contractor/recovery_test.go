@@ -0,0 +1,75 @@
+package contractor
+
+import (
+	"testing"
+
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/types"
+)
+
+// TestContractKey checks that contract keys are derived deterministically
+// and differ between hosts.
+func TestContractKey(t *testing.T) {
+	var seed modules.Seed
+	seed[0] = 1
+	host1 := types.SiaPublicKey{Algorithm: types.SignatureEd25519, Key: []byte{1}}
+	host2 := types.SiaPublicKey{Algorithm: types.SignatureEd25519, Key: []byte{2}}
+
+	if contractKey(seed, host1) != contractKey(seed, host1) {
+		t.Fatal("contract key is not deterministic")
+	}
+	if contractKey(seed, host1) == contractKey(seed, host2) {
+		t.Fatal("contract keys of different hosts are equal")
+	}
+	var otherSeed modules.Seed
+	if contractKey(seed, host1) == contractKey(otherSeed, host1) {
+		t.Fatal("contract keys of different seeds are equal")
+	}
+}
+
+// TestContractScanner checks that the contractScanner finds the most recent
+// contract with each host and forgets contracts that are reverted.
+func TestContractScanner(t *testing.T) {
+	var seed modules.Seed
+	host := types.SiaPublicKey{Algorithm: types.SignatureEd25519, Key: []byte{1}}
+	uh := contractUnlockConditions(contractKey(seed, host), host).UnlockHash()
+	scanner := &contractScanner{
+		hosts:     map[types.UnlockHash]types.SiaPublicKey{uh: host},
+		contracts: make(map[string]recoverableContract),
+	}
+
+	block := func(ts types.Timestamp, fcs ...types.FileContract) types.Block {
+		return types.Block{
+			Timestamp:    ts,
+			Transactions: []types.Transaction{{FileContracts: fcs}},
+		}
+	}
+	b1 := block(1, types.FileContract{UnlockHash: uh, WindowStart: 100})
+	b2 := block(2, types.FileContract{UnlockHash: uh, WindowStart: 200}, types.FileContract{WindowStart: 300})
+	b3 := block(3, types.FileContract{UnlockHash: uh, WindowStart: 150})
+
+	scanner.ProcessConsensusChange(modules.ConsensusChange{
+		AppliedBlocks: []types.Block{b1, b2, b3},
+	})
+	rc, exists := scanner.contracts[host.String()]
+	if !exists || len(scanner.contracts) != 1 {
+		t.Fatal("expected one contract, got", scanner.contracts)
+	}
+	if rc.id != b2.Transactions[0].FileContractID(0) || rc.contract.WindowStart != 200 {
+		t.Fatal("scanner did not keep the most recent contract:", rc)
+	}
+	if rc.startHeight != 2 {
+		t.Fatal("wrong start height:", rc.startHeight)
+	}
+
+	// Reverting the block of the contract should forget it.
+	scanner.ProcessConsensusChange(modules.ConsensusChange{
+		RevertedBlocks: []types.Block{b3, b2},
+	})
+	if len(scanner.contracts) != 0 {
+		t.Fatal("reverted contract was not forgotten:", scanner.contracts)
+	}
+	if scanner.height != 1 {
+		t.Fatal("wrong height after revert:", scanner.height)
+	}
+}
@@ -0,0 +1,49 @@
+package contractor
+
+import (
+	"github.com/pachisi456/Sia/types"
+)
+
+// managedCloseIdleEditor closes the editor of the contract if it is cached
+// but not used by any client.
+func (c *Contractor) managedCloseIdleEditor(id types.FileContractID) {
+	c.mu.RLock()
+	he, exists := c.editors[id]
+	c.mu.RUnlock()
+	if exists {
+		he.closeIdle()
+	}
+}
+
+// managedCloseIdleDownloader closes the downloader of the contract if it is
+// cached but not used by any client.
+func (c *Contractor) managedCloseIdleDownloader(id types.FileContractID) {
+	c.mu.RLock()
+	hd, exists := c.downloaders[id]
+	c.mu.RUnlock()
+	if exists {
+		hd.closeIdle()
+	}
+}
+
+// managedCloseIdleSessions closes all cached editors and downloaders that are
+// not used by any client.
+func (c *Contractor) managedCloseIdleSessions() {
+	c.mu.RLock()
+	editors := make([]*hostEditor, 0, len(c.editors))
+	for _, he := range c.editors {
+		editors = append(editors, he)
+	}
+	downloaders := make([]*hostDownloader, 0, len(c.downloaders))
+	for _, hd := range c.downloaders {
+		downloaders = append(downloaders, hd)
+	}
+	c.mu.RUnlock()
+
+	for _, he := range editors {
+		he.closeIdle()
+	}
+	for _, hd := range downloaders {
+		hd.closeIdle()
+	}
+}
@@ -20,6 +20,9 @@ func (c *Contractor) ProcessConsensusChange(cc modules.ConsensusChange) {
 		}
 	}
 
+	// track whether the formation transactions of the contracts confirmed
+	c.updateContractStatus(cc)
+
 	// archive expired contracts
 	var expired []types.FileContractID
 	for id, contract := range c.contracts {
@@ -35,6 +38,7 @@ func (c *Contractor) ProcessConsensusChange(cc modules.ConsensusChange) {
 	// delete expired contracts (can't delete while iterating)
 	for _, id := range expired {
 		delete(c.contracts, id)
+		delete(c.sectorRoots, id)
 		c.log.Println("INFO: archived expired contract", id)
 	}
 
@@ -45,6 +49,7 @@ func (c *Contractor) ProcessConsensusChange(cc modules.ConsensusChange) {
 	cycleLen := c.allowance.Period - c.allowance.RenewWindow
 	if c.blockHeight > c.currentPeriod+cycleLen {
 		c.currentPeriod += cycleLen
+		c.churn = 0
 		// COMPATv1.0.4-lts
 		// if we were storing a special metrics contract, it will be invalid
 		// after we enter the next period.
@@ -64,5 +69,6 @@ func (c *Contractor) ProcessConsensusChange(cc modules.ConsensusChange) {
 	if cc.Synced {
 		// Perform the contract maintenance in a separate thread.
 		go c.threadedContractMaintenance()
+		go c.threadedRebroadcastContractTxns()
 	}
 }
@@ -233,3 +233,108 @@ func TestIntegrationRenewInvalidate(t *testing.T) {
 	}
 	downloader.Close()
 }
+
+// TestIntegrationRevisionLimitRenew tests that a contract approaching the
+// maximum number of revisions is renewed, and that it is not revised further
+// until then.
+func TestIntegrationRevisionLimitRenew(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	// create testing trio
+	h, c, _, err := newTestingTrio(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	defer c.Close()
+
+	// form a contract with the host
+	a := modules.Allowance{
+		Funds:       types.SiacoinPrecision.Mul64(100), // 100 SC
+		Hosts:       1,
+		Period:      50,
+		RenewWindow: 10,
+	}
+	err = c.SetAllowance(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(50, 100*time.Millisecond, func() error {
+		if len(c.Contracts()) == 0 {
+			return errors.New("contracts were not formed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	contract := c.Contracts()[0]
+
+	// Set a revision limit such that only a few more uploads are possible.
+	const maxRevisions = 10
+	err = c.SetMaxRevisions(maxRevisions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.maintenanceLock.Lock()
+	c.maintenanceLock.Unlock()
+
+	// Upload until the editor refuses to revise the contract further.
+	editor, err := c.Editor(contract.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var roots []crypto.Hash
+	for i := 0; i < maxRevisions; i++ {
+		root, err := editor.Upload(fastrand.Bytes(int(modules.SectorSize)))
+		if err == errRevisionLimit {
+			break
+		} else if err == errInvalidEditor {
+			// the contract is already being renewed
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, root)
+	}
+	editor.Close()
+	if len(roots) == 0 || len(roots) >= maxRevisions {
+		t.Fatal("expected the revision limit to stop uploads, uploaded", len(roots), "sectors")
+	}
+
+	// The contract should be renewed, keeping the uploaded data.
+	err = build.Retry(50, 100*time.Millisecond, func() error {
+		c.maintenanceLock.Lock()
+		c.maintenanceLock.Unlock()
+		contracts := c.Contracts()
+		if len(contracts) != 1 {
+			return errors.New("expected 1 contract")
+		} else if contracts[0].ID == contract.ID {
+			return errors.New("contract was not renewed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	renewed := c.Contracts()[0]
+	if nearRevisionLimit(renewed, maxRevisions) {
+		t.Fatal("renewed contract is still near the revision limit:", renewed.LastRevision.NewRevisionNumber)
+	} else if renewed.LastRevision.NewFileSize != uint64(len(roots))*modules.SectorSize {
+		t.Fatal("wrong file size:", renewed.LastRevision.NewFileSize)
+	} else if c.ResolveID(contract.ID) != renewed.ID {
+		t.Fatal("old contract ID does not resolve to the renewed contract")
+	}
+
+	// The renewed contract can be revised again.
+	editor, err = c.Editor(renewed.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer editor.Close()
+	if _, err := editor.Upload(fastrand.Bytes(int(modules.SectorSize))); err != nil {
+		t.Fatal(err)
+	}
+}
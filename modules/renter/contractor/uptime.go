@@ -32,6 +32,42 @@ func (c *Contractor) IsOffline(id types.FileContractID) bool {
 	return c.isOffline(id)
 }
 
+// InGracePeriod indicates whether a contract's host is too new to be trusted
+// with uploads. A host leaves the grace period once it has at least
+// uptimeMinScans successful scans spanning 'gracePeriod'. A grace period of
+// zero means that no host is considered new.
+func (c *Contractor) InGracePeriod(id types.FileContractID, gracePeriod time.Duration) bool {
+	if gracePeriod == 0 {
+		return false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	id = c.resolveID(id)
+	contract, ok := c.contracts[id]
+	if !ok {
+		return true
+	}
+	host, ok := c.hdb.Host(contract.HostPublicKey)
+	if !ok {
+		return true
+	}
+	// Find the number of successful scans and the span of time that they
+	// cover.
+	var successes int
+	var first, last time.Time
+	for _, scan := range host.ScanHistory {
+		if !scan.Success {
+			continue
+		}
+		if successes == 0 {
+			first = scan.Timestamp
+		}
+		last = scan.Timestamp
+		successes++
+	}
+	return successes < uptimeMinScans || last.Sub(first) < gracePeriod
+}
+
 // isOffline indicates whether a contract's host should be considered offline,
 // based on its scan metrics.
 func (c *Contractor) isOffline(id types.FileContractID) bool {
@@ -54,7 +90,33 @@ func (c *Contractor) isOffline(id types.FileContractID) bool {
 		// No scan history, assume offline.
 		return true
 	}
-	// Return 'true' if the most recent scan of the host failed, false
-	// otherwise.
-	return !host.ScanHistory[len(host.ScanHistory)-1].Success
+	// Only consider the scans inside the uptime window. If there are too few
+	// of them, e.g. because the host has not been scanned recently, fall back
+	// to the latest uptimeMinScans scans regardless of their age. A host with
+	// fewer scans than that is not judged, so that a single failed scan does
+	// not take a new host offline.
+	if len(host.ScanHistory) < uptimeMinScans {
+		return false
+	}
+	var recentScans, recentSuccesses int
+	now := time.Now()
+	for _, scan := range host.ScanHistory {
+		if now.Sub(scan.Timestamp) > uptimeWindow {
+			continue
+		}
+		recentScans++
+		if scan.Success {
+			recentSuccesses++
+		}
+	}
+	if recentScans < uptimeMinScans {
+		recentSuccesses = 0
+		for _, scan := range host.ScanHistory[len(host.ScanHistory)-uptimeMinScans:] {
+			if scan.Success {
+				recentSuccesses++
+			}
+		}
+	}
+	// Return 'true' if all recent scans of the host failed, false otherwise.
+	return recentSuccesses == 0
 }
@@ -148,16 +148,21 @@ func TestIsOffline(t *testing.T) {
 		{nil, true},
 		// not enough data
 		{[]modules.HostDBScan{oldBadScan, newGoodScan}, false},
-		// data covers small range
+		{[]modules.HostDBScan{newBadScan, currentBadScan}, false},
+		// not enough scans inside the window, so the latest scans are used
 		{[]modules.HostDBScan{oldBadScan, oldBadScan, oldBadScan}, true},
-		// data covers large range, but at least 1 scan succeeded
-		{[]modules.HostDBScan{oldBadScan, newGoodScan, currentBadScan}, true},
-		// data covers large range, no scans succeeded
+		{[]modules.HostDBScan{oldGoodScan, oldBadScan, oldBadScan}, false},
+		{[]modules.HostDBScan{oldGoodScan, oldBadScan, oldBadScan, oldBadScan}, true},
+		{[]modules.HostDBScan{oldBadScan, newGoodScan, currentBadScan}, false},
 		{[]modules.HostDBScan{oldBadScan, newBadScan, currentBadScan}, true},
 		// old scan was good, recent scans are bad.
 		{[]modules.HostDBScan{oldGoodScan, newBadScan, newBadScan, currentBadScan}, true},
+		// all scans inside the window failed
+		{[]modules.HostDBScan{newBadScan, newBadScan, currentBadScan}, true},
+		// a single transient failure does not take the host offline
+		{[]modules.HostDBScan{newGoodScan, newGoodScan, currentBadScan}, false},
 		// recent scan was good, with many recent bad scans.
-		{[]modules.HostDBScan{oldBadScan, newGoodScan, newBadScan, currentBadScan, currentBadScan}, true},
+		{[]modules.HostDBScan{oldBadScan, newGoodScan, newBadScan, currentBadScan, currentBadScan}, false},
 		// recent scan was good, old scans were bad.
 		{[]modules.HostDBScan{oldBadScan, newBadScan, currentBadScan, currentGoodScan}, false},
 	}
@@ -187,3 +192,48 @@ func TestIsOffline(t *testing.T) {
 		t.Fatal("IsOffline returned false for a nonexistent contract id")
 	}
 }
+
+// TestInGracePeriod tests that a newly-seen host is not used for uploads until
+// it has accumulated enough successful scans over the grace period.
+func TestInGracePeriod(t *testing.T) {
+	now := time.Now()
+	gracePeriod := time.Hour
+	scan := func(age time.Duration, success bool) modules.HostDBScan {
+		return modules.HostDBScan{Timestamp: now.Add(-age), Success: success}
+	}
+
+	tests := []struct {
+		scans []modules.HostDBScan
+		new   bool
+	}{
+		// no data
+		{nil, true},
+		// freshly seen host with a single successful scan
+		{[]modules.HostDBScan{scan(0, true)}, true},
+		// enough scans, but they do not span the grace period
+		{[]modules.HostDBScan{scan(time.Minute*2, true), scan(time.Minute, true), scan(0, true)}, true},
+		// scans span the grace period, but not enough of them succeeded
+		{[]modules.HostDBScan{scan(gracePeriod*2, true), scan(gracePeriod, false), scan(0, true)}, true},
+		// enough successful scans spanning the grace period
+		{[]modules.HostDBScan{scan(gracePeriod*2, true), scan(gracePeriod, true), scan(0, true)}, false},
+	}
+	for i, test := range tests {
+		c := &Contractor{
+			contracts: map[types.FileContractID]modules.RenterContract{
+				{1}: {HostPublicKey: types.SiaPublicKey{Key: []byte("foo")}},
+			},
+			hdb: mapHostDB{
+				hosts: map[string]modules.HostDBEntry{
+					"foo": {ScanHistory: test.scans},
+				},
+			},
+		}
+		if isNew := c.InGracePeriod(types.FileContractID{1}, gracePeriod); isNew != test.new {
+			t.Errorf("InGracePeriod(%v) = %v, expected %v", i, isNew, test.new)
+		}
+		// Without a grace period, no host should be considered new.
+		if c.InGracePeriod(types.FileContractID{1}, 0) {
+			t.Errorf("InGracePeriod(%v) returned true without a grace period", i)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package contractor
+
+import (
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/types"
+)
+
+// managedChurn reports whether a contract may be dropped in favor of better
+// hosts, counting the drop against the churn budget of the current period.
+// Contracts that were already dropped do not count against the budget again.
+func (c *Contractor) managedChurn(wasGoodForRenew bool) bool {
+	if !wasGoodForRenew {
+		return true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.maxChurn != 0 && c.churn >= c.maxChurn {
+		c.log.Println("INFO: keeping a contract with a poorly scoring host, the churn budget of the period is used up")
+		return false
+	}
+	c.churn++
+	return true
+}
+
+// ContractUtilization reports how much data and money each contract holds,
+// and how many contracts were dropped in favor of better hosts during the
+// current period.
+func (c *Contractor) ContractUtilization() modules.ContractUtilizationReport {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	report := modules.ContractUtilizationReport{
+		Contracts:         make([]modules.ContractUtilization, 0, len(c.contracts)),
+		ReplacedContracts: c.churn,
+		MaxContractChurn:  c.maxChurn,
+	}
+	for _, contract := range c.contracts {
+		remaining := types.ZeroCurrency
+		if len(contract.LastRevision.NewValidProofOutputs) >= 2 {
+			remaining = contract.RenterFunds()
+		}
+		report.Contracts = append(report.Contracts, modules.ContractUtilization{
+			ID:               contract.ID,
+			HostPublicKey:    contract.HostPublicKey,
+			NetAddress:       contract.NetAddress,
+			EndHeight:        contract.EndHeight(),
+			StoredBytes:      uint64(len(contract.MerkleRoots)) * modules.SectorSize,
+			RemainingFunds:   remaining,
+			TotalCost:        contract.TotalCost,
+			DownloadSpending: contract.DownloadSpending,
+			StorageSpending:  contract.StorageSpending,
+			UploadSpending:   contract.UploadSpending,
+			GoodForUpload:    contract.GoodForUpload,
+			GoodForRenew:     contract.GoodForRenew,
+		})
+	}
+	return report
+}
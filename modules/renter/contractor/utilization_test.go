@@ -0,0 +1,95 @@
+package contractor
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/pachisi456/Sia/crypto"
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/persist"
+	"github.com/pachisi456/Sia/types"
+)
+
+// TestChurnBudget checks that contracts can only be dropped in favor of better
+// hosts until the churn budget of the period is used up.
+func TestChurnBudget(t *testing.T) {
+	c := &Contractor{
+		log:      persist.NewLogger(ioutil.Discard),
+		maxChurn: 2,
+	}
+	for i := 0; i < 2; i++ {
+		if !c.managedChurn(true) {
+			t.Fatal("contract could not be dropped within the churn budget")
+		}
+	}
+	if c.managedChurn(true) {
+		t.Fatal("contract was dropped although the churn budget is used up")
+	}
+	if !c.managedChurn(false) {
+		t.Fatal("contract that was already dropped was kept")
+	}
+	if c.churn != 2 {
+		t.Fatal("expected a churn of 2, got", c.churn)
+	}
+
+	// The budget is reset in the next period.
+	c.allowance = modules.Allowance{Period: 10, RenewWindow: 5}
+	c.persist = new(memPersist)
+	c.contracts = make(map[types.FileContractID]modules.RenterContract)
+	c.ProcessConsensusChange(modules.ConsensusChange{
+		AppliedBlocks: make([]types.Block, 6),
+	})
+	if c.churn != 0 {
+		t.Fatal("churn was not reset in the next period")
+	}
+
+	// A budget of zero means that there is no limit.
+	c.maxChurn = 0
+	for i := 0; i < 10; i++ {
+		if !c.managedChurn(true) {
+			t.Fatal("contract could not be dropped without a churn budget")
+		}
+	}
+}
+
+// TestContractUtilization checks the contract utilization report.
+func TestContractUtilization(t *testing.T) {
+	contract := modules.RenterContract{
+		ID:            types.FileContractID{1},
+		HostPublicKey: types.SiaPublicKey{Key: []byte("foo")},
+		MerkleRoots:   modules.MerkleRootSet{crypto.Hash{1}, crypto.Hash{2}},
+		TotalCost:     types.NewCurrency64(100),
+		GoodForRenew:  true,
+	}
+	contract.LastRevision.NewValidProofOutputs = []types.SiacoinOutput{
+		{Value: types.NewCurrency64(40)},
+		{Value: types.NewCurrency64(0)},
+	}
+	contract.LastRevision.NewWindowStart = 50
+	c := &Contractor{
+		contracts: map[types.FileContractID]modules.RenterContract{contract.ID: contract},
+		churn:     1,
+		maxChurn:  3,
+	}
+	report := c.ContractUtilization()
+	if report.ReplacedContracts != 1 || report.MaxContractChurn != 3 || len(report.Contracts) != 1 {
+		t.Fatal("wrong report:", report)
+	}
+	u := report.Contracts[0]
+	if u.ID != contract.ID || u.StoredBytes != 2*modules.SectorSize || !u.RemainingFunds.Equals64(40) || !u.TotalCost.Equals64(100) || u.EndHeight != 50 || u.GoodForUpload || !u.GoodForRenew {
+		t.Fatal("wrong contract utilization:", u)
+	}
+}
+
+// TestRenewFundsThreshold checks that the allowance's renew funds threshold
+// overrides the default threshold.
+func TestRenewFundsThreshold(t *testing.T) {
+	c := new(Contractor)
+	if threshold := c.renewFundsThreshold(); threshold != minContractFundRenewalThreshold {
+		t.Fatal("expected the default threshold, got", threshold)
+	}
+	c.allowance.RenewFundsThreshold = 0.2
+	if threshold := c.renewFundsThreshold(); threshold != 0.2 {
+		t.Fatal("expected the allowance's threshold, got", threshold)
+	}
+}
@@ -0,0 +1,181 @@
+package contractor
+
+import (
+	"fmt"
+
+	"github.com/pachisi456/Sia/encoding"
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/types"
+)
+
+// A watchedTxn is the transaction set that formed a contract. The contractor
+// broadcasts it again until it confirms. Revisions are not watched: the
+// renter never broadcasts them itself, the host submits the final revision
+// when it proves storage.
+type watchedTxn struct {
+	ContractID    types.FileContractID `json:"contractid"`
+	HostPublicKey types.SiaPublicKey   `json:"hostpublickey"`
+	NetAddress    modules.NetAddress   `json:"netaddress"`
+	EndHeight     types.BlockHeight    `json:"endheight"`
+	TxnSet        []types.Transaction  `json:"txnset"`
+
+	BroadcastHeight     types.BlockHeight `json:"broadcastheight"`
+	LastBroadcastHeight types.BlockHeight `json:"lastbroadcastheight"`
+	Broadcasts          uint64            `json:"broadcasts"`
+	FeeBumps            int               `json:"feebumps"`
+
+	// Alert explains why the transaction is in danger of never confirming.
+	// It is empty as long as there is no reason for concern.
+	Alert string `json:"alert"`
+}
+
+// managedWatchContractTxn starts watching the transaction set that formed
+// the contract.
+func (c *Contractor) managedWatchContractTxn(contract modules.RenterContract, txnSet []types.Transaction) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.contractStatus[contract.ID] = modules.ContractStatusUnconfirmed
+	c.watchedTxns[contract.ID] = watchedTxn{
+		ContractID:    contract.ID,
+		HostPublicKey: contract.HostPublicKey,
+		NetAddress:    contract.NetAddress,
+		EndHeight:     contract.EndHeight(),
+		TxnSet:        txnSet,
+
+		BroadcastHeight:     c.blockHeight,
+		LastBroadcastHeight: c.blockHeight,
+		Broadcasts:          1,
+	}
+}
+
+// formationTxns returns the transactions of the set up to the one that forms
+// the contract, leaving out the transactions that only pay additional fees.
+func (wt watchedTxn) formationTxns() []types.Transaction {
+	for i, txn := range wt.TxnSet {
+		for j := range txn.FileContracts {
+			if txn.FileContractID(uint64(j)) == wt.ContractID {
+				return wt.TxnSet[:i+1]
+			}
+		}
+	}
+	return wt.TxnSet
+}
+
+// threadedRebroadcastContractTxns broadcasts the watched transactions again
+// that have not confirmed within txnRebroadcastInterval blocks of their last
+// broadcast. Calls made while a rebroadcast is in progress return right away.
+func (c *Contractor) threadedRebroadcastContractTxns() {
+	if err := c.tg.Add(); err != nil {
+		return
+	}
+	defer c.tg.Done()
+	if !c.rebroadcastLock.TryLock() {
+		return
+	}
+	defer c.rebroadcastLock.Unlock()
+
+	c.mu.RLock()
+	height := c.blockHeight
+	var due []watchedTxn
+	for _, wt := range c.watchedTxns {
+		if height >= wt.LastBroadcastHeight+txnRebroadcastInterval {
+			due = append(due, wt)
+		}
+	}
+	c.mu.RUnlock()
+
+	for _, wt := range due {
+		wt = c.managedRebroadcast(wt, height)
+		c.mu.Lock()
+		// The transaction may have confirmed in the meantime.
+		if _, exists := c.watchedTxns[wt.ContractID]; exists {
+			c.watchedTxns[wt.ContractID] = wt
+		}
+		c.mu.Unlock()
+	}
+}
+
+// managedRebroadcast broadcasts a watched transaction set again and returns
+// the updated watchedTxn. Unless the fee was already increased
+// maxTxnFeeBumps times, a transaction paying an additional fee is added to
+// the set, which makes it more attractive to miners.
+func (c *Contractor) managedRebroadcast(wt watchedTxn, height types.BlockHeight) watchedTxn {
+	txnSet := wt.TxnSet
+	var feeBuilder transactionBuilder
+	if wt.FeeBumps < maxTxnFeeBumps {
+		feeTxns, builder, err := c.managedFeeTxns(txnSet)
+		if err != nil {
+			c.log.Printf("WARN: unable to increase the fee of the transaction of contract %v: %v", wt.ContractID, err)
+		} else {
+			txnSet = append(append([]types.Transaction(nil), txnSet...), feeTxns...)
+			feeBuilder = builder
+		}
+	}
+
+	err := c.tpool.AcceptTransactionSet(txnSet)
+	if err == modules.ErrDuplicateTransactionSet {
+		err = nil
+	}
+	wt.LastBroadcastHeight = height
+	wt.Alert = ""
+	if err != nil {
+		if feeBuilder != nil {
+			feeBuilder.Drop() // return the funds of the fee to the wallet
+		}
+		c.log.Printf("WARN: unable to broadcast the transaction of contract %v: %v", wt.ContractID, err)
+		wt.Alert = fmt.Sprintf("transaction could not be broadcast: %v", err)
+		return wt
+	}
+	if feeBuilder != nil {
+		wt.TxnSet = txnSet
+		wt.FeeBumps++
+	}
+	wt.Broadcasts++
+	if height >= wt.BroadcastHeight+txnAlertThreshold {
+		wt.Alert = fmt.Sprintf("transaction has not confirmed within %v blocks", height-wt.BroadcastHeight)
+	}
+	return wt
+}
+
+// managedFeeTxns creates a transaction that pays an additional fee for the
+// given transaction set, based on the fee estimation of the transaction
+// pool. The returned transactionBuilder must be dropped if the transaction
+// is not broadcast.
+func (c *Contractor) managedFeeTxns(txnSet []types.Transaction) ([]types.Transaction, transactionBuilder, error) {
+	_, maxFee := c.tpool.FeeEstimation()
+	fee := maxFee.Mul64(uint64(len(encoding.Marshal(txnSet))))
+	builder := c.wallet.StartTransaction()
+	if err := builder.FundSiacoins(fee); err != nil {
+		builder.Drop()
+		return nil, nil, err
+	}
+	builder.AddMinerFee(fee)
+	txns, err := builder.Sign(true)
+	if err != nil {
+		builder.Drop()
+		return nil, nil, err
+	}
+	return txns, builder, nil
+}
+
+// Alerts returns a ContractAlert for every watched contract transaction that
+// is in danger of never confirming.
+func (c *Contractor) Alerts() []modules.ContractAlert {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	alerts := make([]modules.ContractAlert, 0)
+	for _, wt := range c.watchedTxns {
+		if wt.Alert == "" {
+			continue
+		}
+		alerts = append(alerts, modules.ContractAlert{
+			ContractID:      wt.ContractID,
+			HostPublicKey:   wt.HostPublicKey,
+			NetAddress:      wt.NetAddress,
+			BroadcastHeight: wt.BroadcastHeight,
+			Broadcasts:      wt.Broadcasts,
+			Msg:             wt.Alert,
+		})
+	}
+	return alerts
+}
@@ -0,0 +1,175 @@
+package contractor
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/persist"
+	"github.com/pachisi456/Sia/types"
+)
+
+// watchTpool is a transactionPool that records the transaction sets it
+// accepts, or rejects them with err.
+type watchTpool struct {
+	sets [][]types.Transaction
+	err  error
+}
+
+func (tp *watchTpool) AcceptTransactionSet(set []types.Transaction) error {
+	if tp.err != nil {
+		return tp.err
+	}
+	tp.sets = append(tp.sets, set)
+	return nil
+}
+func (tp *watchTpool) FeeEstimation() (types.Currency, types.Currency) {
+	return types.NewCurrency64(1), types.NewCurrency64(2)
+}
+
+// feeTxnBuilder is a transactionBuilder that creates a single transaction
+// paying a miner fee.
+type feeTxnBuilder struct {
+	transactionBuilder
+	txn     types.Transaction
+	dropped *int
+}
+
+func (tb *feeTxnBuilder) FundSiacoins(types.Currency) error { return nil }
+func (tb *feeTxnBuilder) AddMinerFee(fee types.Currency) uint64 {
+	tb.txn.MinerFees = append(tb.txn.MinerFees, fee)
+	return 0
+}
+func (tb *feeTxnBuilder) Sign(bool) ([]types.Transaction, error) {
+	return []types.Transaction{tb.txn}, nil
+}
+func (tb *feeTxnBuilder) Drop() { *tb.dropped++ }
+
+// feeWallet is a wallet that funds every transaction.
+type feeWallet struct {
+	dropped int
+}
+
+func (w *feeWallet) NextAddress() (types.UnlockConditions, error) {
+	return types.UnlockConditions{}, nil
+}
+func (w *feeWallet) PrimarySeed() (modules.Seed, uint64, error) {
+	return modules.Seed{}, 0, nil
+}
+func (w *feeWallet) StartTransaction() transactionBuilder {
+	return &feeTxnBuilder{dropped: &w.dropped}
+}
+
+// TestWatchContractTxns checks that unconfirmed contract transactions are
+// broadcast again with an additional fee, and that an alert is raised if
+// they do not confirm.
+func TestWatchContractTxns(t *testing.T) {
+	tp := new(watchTpool)
+	w := new(feeWallet)
+	c := &Contractor{
+		log:         persist.NewLogger(ioutil.Discard),
+		persist:     new(memPersist),
+		tpool:       tp,
+		wallet:      w,
+		contracts:   make(map[types.FileContractID]modules.RenterContract),
+		watchedTxns: make(map[types.FileContractID]watchedTxn),
+
+		contractStatus: make(map[types.FileContractID]modules.ContractStatus),
+	}
+	formationTxn := types.Transaction{
+		FileContracts: []types.FileContract{{WindowStart: 1000}},
+	}
+	contract := modules.RenterContract{
+		ID:            formationTxn.FileContractID(0),
+		HostPublicKey: types.SiaPublicKey{Key: []byte("foo")},
+	}
+	contract.LastRevision.NewWindowStart = 1000
+	c.managedWatchContractTxn(contract, []types.Transaction{formationTxn})
+
+	// The transaction is not broadcast again before txnRebroadcastInterval
+	// blocks have passed.
+	c.threadedRebroadcastContractTxns()
+	if len(tp.sets) != 0 {
+		t.Fatal("transaction was broadcast too early")
+	}
+
+	// The transaction is not broadcast while another rebroadcast is in
+	// progress.
+	c.blockHeight += txnRebroadcastInterval
+	c.rebroadcastLock.Lock()
+	c.threadedRebroadcastContractTxns()
+	c.rebroadcastLock.Unlock()
+	if len(tp.sets) != 0 {
+		t.Fatal("transaction was broadcast during another rebroadcast")
+	}
+	c.blockHeight -= txnRebroadcastInterval
+
+	// Rebroadcast until the fee was bumped maxTxnFeeBumps times.
+	for i := 1; i <= maxTxnFeeBumps+1; i++ {
+		c.blockHeight += txnRebroadcastInterval
+		c.threadedRebroadcastContractTxns()
+		if len(tp.sets) != i {
+			t.Fatal("transaction was not broadcast again")
+		}
+		set := tp.sets[i-1]
+		if set[0].ID() != formationTxn.ID() {
+			t.Fatal("wrong transaction set was broadcast")
+		}
+		expected := i
+		if expected > maxTxnFeeBumps {
+			expected = maxTxnFeeBumps
+		}
+		if bumps := len(set) - 1; bumps != expected {
+			t.Fatalf("expected %v fee transactions, got %v", expected, bumps)
+		}
+	}
+	wt := c.watchedTxns[contract.ID]
+	if wt.FeeBumps != maxTxnFeeBumps || wt.Broadcasts != uint64(maxTxnFeeBumps+2) {
+		t.Fatal("wrong watched transaction:", wt.FeeBumps, wt.Broadcasts)
+	}
+	if c.blockHeight < txnAlertThreshold {
+		t.Fatal("test did not wait long enough for an alert")
+	}
+	if alerts := c.Alerts(); len(alerts) != 1 || alerts[0].ContractID != contract.ID {
+		t.Fatal("expected an alert, got", alerts)
+	}
+
+	// A rejected transaction raises an alert, and the fee is returned to the
+	// wallet.
+	c.watchedTxns[contract.ID] = watchedTxn{ContractID: contract.ID, EndHeight: 1000, TxnSet: []types.Transaction{formationTxn}}
+	tp.err = errors.New("rejected")
+	c.threadedRebroadcastContractTxns()
+	if alerts := c.Alerts(); len(alerts) != 1 || alerts[0].Msg != "transaction could not be broadcast: rejected" {
+		t.Fatal("expected an alert, got", alerts)
+	}
+	if w.dropped != 1 {
+		t.Fatal("fee transaction was not dropped")
+	}
+
+	// The watched transactions are persisted.
+	c.blockHeight++
+	if err := c.save(); err != nil {
+		t.Fatal(err)
+	}
+	c.watchedTxns = make(map[types.FileContractID]watchedTxn)
+	c.contractStatus = make(map[types.FileContractID]modules.ContractStatus)
+	c.cachedRevisions = make(map[types.FileContractID]cachedRevision)
+	c.oldContracts = make(map[types.FileContractID]modules.RenterContract)
+	c.renewedIDs = make(map[types.FileContractID]types.FileContractID)
+	c.filteredHosts = make(map[string]types.SiaPublicKey)
+	if err := c.load(); err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := c.watchedTxns[contract.ID]; !exists {
+		t.Fatal("watched transaction was not persisted")
+	}
+
+	// The transaction is no longer watched once it confirms.
+	c.ProcessConsensusChange(modules.ConsensusChange{
+		AppliedBlocks: []types.Block{{Transactions: []types.Transaction{formationTxn}}},
+	})
+	if len(c.watchedTxns) != 0 || len(c.Alerts()) != 0 {
+		t.Fatal("confirmed transaction is still watched")
+	}
+}
@@ -0,0 +1,99 @@
+package renter
+
+import (
+	"errors"
+
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/types"
+)
+
+var errDiagnosticsUnknownContract = errors.New("the renter has no contract with that id")
+
+// contractActivity counts the upload and download attempts made by the
+// workers of a contract.
+type contractActivity struct {
+	uploads          uint64
+	uploadFailures   uint64
+	downloads        uint64
+	downloadFailures uint64
+}
+
+// managedRecordContractActivity records an upload or download attempt on the
+// contract with the given id.
+func (r *Renter) managedRecordContractActivity(id types.FileContractID, upload bool, success bool) {
+	lockID := r.mu.Lock()
+	defer r.mu.Unlock(lockID)
+	activity := r.contractActivity[id]
+	switch {
+	case upload && success:
+		activity.uploads++
+	case upload:
+		activity.uploads++
+		activity.uploadFailures++
+	case success:
+		activity.downloads++
+	default:
+		activity.downloads++
+		activity.downloadFailures++
+	}
+	r.contractActivity[id] = activity
+}
+
+// successRate returns the fraction of 'attempts' that did not fail, or zero if
+// there were no attempts.
+func successRate(attempts, failures uint64) float64 {
+	if attempts == 0 {
+		return 0
+	}
+	return float64(attempts-failures) / float64(attempts)
+}
+
+// ContractDiagnostics returns the state of the contract with the given id and
+// of its host. If the contract has been renewed, the diagnostics of the
+// current contract are returned.
+func (r *Renter) ContractDiagnostics(id types.FileContractID) (modules.ContractDiagnostics, error) {
+	if err := r.tg.Add(); err != nil {
+		return modules.ContractDiagnostics{}, ErrRenterShutdown
+	}
+	defer r.tg.Done()
+
+	id = r.hostContractor.ResolveID(id)
+	contract, exists := r.hostContractor.ContractByID(id)
+	if !exists {
+		return modules.ContractDiagnostics{}, errDiagnosticsUnknownContract
+	}
+	lockID := r.mu.RLock()
+	activity := r.contractActivity[contract.ID]
+	height := r.blockHeight
+	r.mu.RUnlock(lockID)
+
+	diag := modules.ContractDiagnostics{
+		ID:             contract.ID,
+		HostPublicKey:  contract.HostPublicKey,
+		NetAddress:     contract.NetAddress,
+		RenterFunds:    contract.RenterFunds(),
+		RevisionNumber: contract.LastRevision.NewRevisionNumber,
+		StoredBytes:    uint64(len(contract.MerkleRoots)) * modules.SectorSize,
+
+		Uploads:             activity.uploads,
+		UploadFailures:      activity.uploadFailures,
+		UploadSuccessRate:   successRate(activity.uploads, activity.uploadFailures),
+		Downloads:           activity.downloads,
+		DownloadFailures:    activity.downloadFailures,
+		DownloadSuccessRate: successRate(activity.downloads, activity.downloadFailures),
+
+		Offline: r.hostContractor.IsOffline(contract.ID),
+	}
+	if host, known := r.hostDB.Host(contract.HostPublicKey); known {
+		if len(host.ScanHistory) > 0 {
+			lastScan := host.ScanHistory[len(host.ScanHistory)-1]
+			diag.LastScan = lastScan.Timestamp
+			diag.LastScanSuccess = lastScan.Success
+		}
+		diag.LastScanLatency = host.LastScanLatency
+	}
+	if endHeight := contract.EndHeight(); endHeight > height {
+		diag.BlocksToExpiry = endHeight - height
+	}
+	return diag, nil
+}
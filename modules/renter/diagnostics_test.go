@@ -0,0 +1,106 @@
+package renter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pachisi456/Sia/crypto"
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/types"
+)
+
+// renewedContractor is a costContractor in which some contracts have been
+// renewed. ResolveID maps the IDs of renewed contracts to their successors.
+type renewedContractor struct {
+	costContractor
+	renewedTo map[types.FileContractID]types.FileContractID
+}
+
+func (rc renewedContractor) ResolveID(id types.FileContractID) types.FileContractID {
+	if newID, ok := rc.renewedTo[id]; ok {
+		return newID
+	}
+	return id
+}
+func (rc renewedContractor) ContractByID(id types.FileContractID) (modules.RenterContract, bool) {
+	c, ok := rc.contracts[id]
+	return c, ok
+}
+
+// TestContractDiagnostics checks that the diagnostics of a synthetic contract
+// contain all of its fields, and that renewed contracts are resolved.
+func TestContractDiagnostics(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Create a contract storing two sectors that was renewed from contract 1.
+	spk := types.SiaPublicKey{Key: []byte("host")}
+	c := modules.RenterContract{
+		ID:            types.FileContractID{2},
+		HostPublicKey: spk,
+		NetAddress:    "host.com:9982",
+		MerkleRoots:   modules.MerkleRootSet{crypto.Hash{1}, crypto.Hash{2}},
+	}
+	c.LastRevision.NewRevisionNumber = 7
+	c.LastRevision.NewWindowStart = 150
+	c.LastRevision.NewValidProofOutputs = []types.SiacoinOutput{
+		{Value: types.NewCurrency64(50)},
+		{Value: types.ZeroCurrency},
+	}
+	rc := renewedContractor{
+		costContractor: costContractor{
+			hostContractor: rt.renter.hostContractor,
+			contracts:      map[types.FileContractID]modules.RenterContract{c.ID: c},
+			offline:        map[types.FileContractID]bool{c.ID: true},
+		},
+		renewedTo: map[types.FileContractID]types.FileContractID{{1}: c.ID},
+	}
+	var h modules.HostDBEntry
+	h.PublicKey = spk
+	h.LastScanLatency = 40 * time.Millisecond
+	h.ScanHistory = modules.HostDBScans{
+		{Timestamp: time.Now().Add(-time.Hour), Success: true},
+		{Timestamp: time.Now(), Success: true},
+	}
+	hdb := costHostDB{hosts: map[string]modules.HostDBEntry{spk.String(): h}}
+	id := rt.renter.mu.Lock()
+	rt.renter.hostContractor = rc
+	rt.renter.hostDB = hdb
+	rt.renter.blockHeight = 100
+	rt.renter.mu.Unlock(id)
+
+	// Record three uploads, one of which failed, and a download.
+	rt.renter.managedRecordContractActivity(c.ID, true, true)
+	rt.renter.managedRecordContractActivity(c.ID, true, true)
+	rt.renter.managedRecordContractActivity(c.ID, true, false)
+	rt.renter.managedRecordContractActivity(c.ID, false, true)
+
+	diag, err := rt.renter.ContractDiagnostics(types.FileContractID{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	switch {
+	case diag.ID != c.ID || diag.HostPublicKey.String() != spk.String() || diag.NetAddress != c.NetAddress:
+		t.Fatal("diagnostics describe the wrong contract:", diag)
+	case !diag.RenterFunds.Equals64(50) || diag.RevisionNumber != 7 || diag.StoredBytes != 2*modules.SectorSize:
+		t.Fatal("wrong contract state:", diag)
+	case diag.Uploads != 3 || diag.UploadFailures != 1 || diag.UploadSuccessRate != 2.0/3:
+		t.Fatal("wrong upload activity:", diag)
+	case diag.Downloads != 1 || diag.DownloadFailures != 0 || diag.DownloadSuccessRate != 1:
+		t.Fatal("wrong download activity:", diag)
+	case !diag.LastScan.Equal(h.ScanHistory[1].Timestamp) || !diag.LastScanSuccess || diag.LastScanLatency != h.LastScanLatency:
+		t.Fatal("wrong scan results:", diag)
+	case !diag.Offline || diag.BlocksToExpiry != 50:
+		t.Fatal("wrong offline verdict or expiry:", diag)
+	}
+
+	if _, err := rt.renter.ContractDiagnostics(types.FileContractID{3}); err != errDiagnosticsUnknownContract {
+		t.Fatal("expected errDiagnosticsUnknownContract, got", err)
+	}
+}
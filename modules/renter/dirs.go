@@ -0,0 +1,354 @@
+package renter
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pachisi456/Sia/modules"
+)
+
+var (
+	// errMoveDirIntoItself is returned when a directory would be moved into
+	// one of its own subdirectories.
+	errMoveDirIntoItself = errors.New("cannot move a directory into itself")
+
+	// errRenameRootDir is returned when the root directory would be renamed.
+	errRenameRootDir = errors.New("cannot rename the root directory")
+
+	// errStreamingDir is returned when a directory is renamed while a file
+	// inside of it is still being uploaded from a stream.
+	errStreamingDir = errors.New("directory contains a file that is still being uploaded")
+)
+
+type (
+	// dirNode is a directory of the renter's file tree. The tree is built
+	// from the siapaths of the renter's files, with '/' as separator.
+	dirNode struct {
+		siapath string
+		dirs    map[string]*dirNode
+		files   []dirFile
+
+		// numFiles, size and health cover every file below the directory.
+		// health is the redundancy of the least redundant file, or -1 if
+		// the directory contains no data.
+		numFiles uint64
+		size     uint64
+		health   float64
+	}
+
+	// dirFile is a file of the renter's file tree, along with its
+	// redundancy.
+	dirFile struct {
+		file       *file
+		redundancy float64
+	}
+)
+
+// inDir reports whether siapath is below the directory dir. Every siapath is
+// below the root directory, which has the empty siapath.
+func inDir(siapath, dir string) bool {
+	return dir == "" || strings.HasPrefix(siapath, dir+"/")
+}
+
+// child returns the subdirectory of n with the given name, creating it if it
+// doesn't exist.
+func (n *dirNode) child(name string) *dirNode {
+	c, exists := n.dirs[name]
+	if !exists {
+		siapath := name
+		if n.siapath != "" {
+			siapath = n.siapath + "/" + name
+		}
+		c = &dirNode{siapath: siapath, dirs: make(map[string]*dirNode)}
+		n.dirs[name] = c
+	}
+	return c
+}
+
+// lookup returns the directory with the given siapath, or nil if there is
+// no such directory below n.
+func (n *dirNode) lookup(siapath string) *dirNode {
+	if siapath == "" {
+		return n
+	}
+	for _, name := range strings.Split(siapath, "/") {
+		if n = n.dirs[name]; n == nil {
+			return nil
+		}
+	}
+	return n
+}
+
+// aggregate computes the number of files, the size and the health of n and
+// all of its subdirectories.
+func (n *dirNode) aggregate() {
+	n.numFiles, n.size, n.health = 0, 0, -1
+	merge := func(health float64) {
+		if health != -1 && (n.health == -1 || health < n.health) {
+			n.health = health
+		}
+	}
+	for _, df := range n.files {
+		n.numFiles++
+		n.size += df.file.size
+		merge(df.redundancy)
+	}
+	for _, c := range n.dirs {
+		c.aggregate()
+		n.numFiles += c.numFiles
+		n.size += c.size
+		merge(c.health)
+	}
+}
+
+// info returns the DirectoryInfo of n.
+func (n *dirNode) info() modules.DirectoryInfo {
+	return modules.DirectoryInfo{
+		SiaPath:  n.siapath,
+		NumFiles: n.numFiles,
+		Filesize: n.size,
+		Health:   n.health,
+	}
+}
+
+// sortedDirs returns the subdirectories of n, least healthy first. Empty
+// directories come last, and ties are broken by siapath.
+func (n *dirNode) sortedDirs() []*dirNode {
+	dirs := make([]*dirNode, 0, len(n.dirs))
+	for _, c := range n.dirs {
+		dirs = append(dirs, c)
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		hi, hj := dirs[i].health, dirs[j].health
+		if hi != hj && (hi == -1 || hj == -1) {
+			return hj == -1
+		} else if hi != hj {
+			return hi < hj
+		}
+		return dirs[i].siapath < dirs[j].siapath
+	})
+	return dirs
+}
+
+// repairOrder returns the files below n in the order in which they should be
+// repaired. The least healthy directory is visited first, recursively, and
+// the files directly inside a directory come before its subdirectories,
+// ordered by redundancy.
+func (n *dirNode) repairOrder() []*file {
+	files := make([]dirFile, len(n.files))
+	copy(files, n.files)
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].redundancy < files[j].redundancy
+	})
+	order := make([]*file, 0, n.numFiles)
+	for _, df := range files {
+		order = append(order, df.file)
+	}
+	for _, c := range n.sortedDirs() {
+		order = append(order, c.repairOrder()...)
+	}
+	return order
+}
+
+// buildDirTree builds the renter's file tree, including the directories that
+// were created with CreateDir. The renter's lock must be held.
+func (r *Renter) buildDirTree() *dirNode {
+	root := &dirNode{dirs: make(map[string]*dirNode)}
+	for dir := range r.dirs {
+		n := root
+		for _, name := range strings.Split(dir, "/") {
+			n = n.child(name)
+		}
+	}
+	for _, f := range r.files {
+		f.mu.RLock()
+		siapath := f.name
+		redundancy := f.redundancy(r.isOffline)
+		f.mu.RUnlock()
+
+		n := root
+		names := strings.Split(siapath, "/")
+		for _, name := range names[:len(names)-1] {
+			n = n.child(name)
+		}
+		n.files = append(n.files, dirFile{file: f, redundancy: redundancy})
+	}
+	root.aggregate()
+	return root
+}
+
+// dirExists reports whether there is a directory with the given siapath. The
+// renter's lock must be held.
+func (r *Renter) dirExists(siapath string) bool {
+	if siapath == "" {
+		return true
+	}
+	for dir := range r.dirs {
+		if dir == siapath || inDir(dir, siapath) {
+			return true
+		}
+	}
+	for name := range r.files {
+		if inDir(name, siapath) {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateDir creates an empty directory. Directories that contain files exist
+// implicitly and don't have to be created.
+func (r *Renter) CreateDir(siapath string) error {
+	if err := r.tg.Add(); err != nil {
+		return ErrRenterShutdown
+	}
+	defer r.tg.Done()
+
+	siapath = strings.TrimSuffix(siapath, "/")
+	if err := validateSiapath(siapath); err != nil {
+		return err
+	}
+	if isReservedSiapath(siapath) {
+		return errReservedSiapath
+	}
+
+	id := r.mu.Lock()
+	defer r.mu.Unlock(id)
+	if r.siapathInUse(siapath) {
+		return ErrPathOverload
+	}
+	r.dirs[siapath] = struct{}{}
+	return r.saveSync()
+}
+
+// RenameDir moves a directory, including all of its files and
+// subdirectories.
+func (r *Renter) RenameDir(currentPath, newPath string) error {
+	if err := r.tg.Add(); err != nil {
+		return ErrRenterShutdown
+	}
+	defer r.tg.Done()
+
+	currentPath = strings.TrimSuffix(currentPath, "/")
+	newPath = strings.TrimSuffix(newPath, "/")
+	if currentPath == "" {
+		return errRenameRootDir
+	}
+	if err := validateSiapath(newPath); err != nil {
+		return err
+	}
+	if isReservedSiapath(newPath) {
+		return errReservedSiapath
+	}
+	if newPath == currentPath || inDir(newPath, currentPath) {
+		return errMoveDirIntoItself
+	}
+
+	id := r.mu.Lock()
+	defer r.mu.Unlock(id)
+	if !r.dirExists(currentPath) {
+		return ErrUnknownPath
+	}
+	if r.siapathInUse(newPath) {
+		return ErrPathOverload
+	}
+	for siapath := range r.streamingUploads {
+		if inDir(siapath, currentPath) {
+			return errStreamingDir
+		}
+	}
+
+	// Move the files and the created directories.
+	var oldNames, oldDirs []string
+	for name := range r.files {
+		if inDir(name, currentPath) {
+			oldNames = append(oldNames, name)
+		}
+	}
+	for dir := range r.dirs {
+		if dir == currentPath || inDir(dir, currentPath) {
+			oldDirs = append(oldDirs, dir)
+		}
+	}
+	//
+	// If any step fails, the files that were already moved are moved back, so
+	// that the directory is either renamed completely or not at all.
+	newName := func(name string) string {
+		return newPath + strings.TrimPrefix(name, currentPath)
+	}
+	undo := func(renamed []string) {
+		for _, name := range renamed {
+			r.undoRenameFile(r.files[newName(name)], name, newName(name))
+		}
+	}
+	for i, name := range oldNames {
+		if err := r.renameFile(r.files[name], name, newName(name)); err != nil {
+			undo(oldNames[:i])
+			return err
+		}
+	}
+	for _, dir := range oldDirs {
+		delete(r.dirs, dir)
+		r.dirs[newName(dir)] = struct{}{}
+	}
+	if err := r.saveSync(); err != nil {
+		for _, dir := range oldDirs {
+			delete(r.dirs, newName(dir))
+			r.dirs[dir] = struct{}{}
+		}
+		undo(oldNames)
+		return err
+	}
+
+	// Delete the old .sia files, along with the folders that are left empty.
+	for _, name := range oldNames {
+		oldPath := r.siaFilePath(name)
+		if err := os.RemoveAll(oldPath); err != nil {
+			return err
+		}
+		for dir := path.Dir(name); dir == currentPath || inDir(dir, currentPath); dir = path.Dir(dir) {
+			os.Remove(filepath.Join(r.persistDir, dir))
+		}
+	}
+	return nil
+}
+
+// ListDir returns the subdirectories and the files directly inside a
+// directory. The empty siapath is the root directory. Subdirectories are
+// sorted by siapath, as are files.
+func (r *Renter) ListDir(siapath string) ([]modules.DirectoryInfo, []modules.FileInfo, error) {
+	if err := r.tg.Add(); err != nil {
+		return nil, nil, ErrRenterShutdown
+	}
+	defer r.tg.Done()
+
+	siapath = strings.TrimSuffix(siapath, "/")
+	id := r.mu.RLock()
+	defer r.mu.RUnlock(id)
+	n := r.buildDirTree().lookup(siapath)
+	if n == nil {
+		return nil, nil, ErrUnknownPath
+	}
+
+	dirs := make([]modules.DirectoryInfo, 0, len(n.dirs))
+	for _, c := range n.dirs {
+		dirs = append(dirs, c.info())
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		return dirs[i].SiaPath < dirs[j].SiaPath
+	})
+	files := make([]modules.FileInfo, 0, len(n.files))
+	for _, df := range n.files {
+		df.file.mu.RLock()
+		files = append(files, r.fileInfo(df.file))
+		df.file.mu.RUnlock()
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].SiaPath < files[j].SiaPath
+	})
+	return dirs, files, nil
+}
@@ -0,0 +1,218 @@
+package renter
+
+import (
+	"container/heap"
+	"strings"
+	"testing"
+
+	"github.com/pachisi456/Sia/modules"
+)
+
+// newTestDirTree returns a file tree with files of size 10 at the given
+// siapaths, with the given redundancies.
+func newTestDirTree(files map[string]float64) *dirNode {
+	root := &dirNode{dirs: make(map[string]*dirNode)}
+	for siapath, redundancy := range files {
+		n := root
+		names := strings.Split(siapath, "/")
+		for _, name := range names[:len(names)-1] {
+			n = n.child(name)
+		}
+		n.files = append(n.files, dirFile{file: &file{name: siapath, size: 10}, redundancy: redundancy})
+	}
+	root.aggregate()
+	return root
+}
+
+// TestDirTreeAggregate checks that the number of files, the size and the
+// health of a directory cover all of its subdirectories.
+func TestDirTreeAggregate(t *testing.T) {
+	root := newTestDirTree(map[string]float64{
+		"a":       3,
+		"b/c":     2.5,
+		"b/d/e":   1.5,
+		"b/d/f":   -1,
+		"g/h/i/j": -1,
+	})
+	tests := []struct {
+		siapath  string
+		numFiles uint64
+		health   float64
+	}{
+		{"", 5, 1.5},
+		{"b", 3, 1.5},
+		{"b/d", 2, 1.5},
+		{"g", 1, -1},
+		{"g/h/i", 1, -1},
+	}
+	for _, test := range tests {
+		n := root.lookup(test.siapath)
+		if n == nil {
+			t.Fatal("directory not found:", test.siapath)
+		}
+		info := n.info()
+		if info.SiaPath != test.siapath || info.NumFiles != test.numFiles || info.Filesize != 10*test.numFiles || info.Health != test.health {
+			t.Errorf("wrong info for %q: %+v", test.siapath, info)
+		}
+	}
+	if root.lookup("b/c") != nil || root.lookup("x") != nil {
+		t.Fatal("lookup found a directory that doesn't exist")
+	}
+}
+
+// TestDirTreeRepairOrder checks that files are repaired least healthy
+// directory first.
+func TestDirTreeRepairOrder(t *testing.T) {
+	root := newTestDirTree(map[string]float64{
+		"a":     1,
+		"b":     0.5,
+		"c/d":   2.5,
+		"c/e/f": 3,
+		"g/h":   1.5,
+		"g/i/j": 2,
+		"k/l":   -1,
+	})
+	var order []string
+	for _, f := range root.repairOrder() {
+		order = append(order, f.name)
+	}
+	exp := []string{"b", "a", "g/h", "g/i/j", "c/d", "c/e/f", "k/l"}
+	if strings.Join(order, " ") != strings.Join(exp, " ") {
+		t.Fatalf("expected repair order %v, got %v", exp, order)
+	}
+}
+
+// TestChunkHeapPriority checks that equally complete chunks are popped in the
+// order of their priority.
+func TestChunkHeapPriority(t *testing.T) {
+	ch := new(chunkHeap)
+	heap.Init(ch)
+	chunks := []*unfinishedChunk{
+		{piecesCompleted: 1, piecesNeeded: 2, priority: 0},
+		{piecesCompleted: 1, piecesNeeded: 4, priority: 2},
+		{piecesCompleted: 1, piecesNeeded: 4, priority: 1},
+		{piecesCompleted: 0, piecesNeeded: 4, priority: 3},
+	}
+	for _, uc := range chunks {
+		heap.Push(ch, uc)
+	}
+	for _, exp := range []*unfinishedChunk{chunks[3], chunks[2], chunks[1], chunks[0]} {
+		if uc := heap.Pop(ch).(*unfinishedChunk); uc != exp {
+			t.Fatalf("expected chunk with priority %v, got %v", exp.priority, uc.priority)
+		}
+	}
+}
+
+// TestRenterDirs probes the CreateDir, RenameDir and ListDir methods of the
+// renter.
+func TestRenterDirs(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	for _, siapath := range []string{"a/b/c", "a/d", "e"} {
+		f := newTestingFile()
+		f.name = siapath
+		id := rt.renter.mu.Lock()
+		rt.renter.files[siapath] = f
+		err := rt.renter.saveFile(f)
+		rt.renter.mu.Unlock(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Create an empty directory, and try to create directories that already
+	// exist.
+	if err := rt.renter.CreateDir("f/g"); err != nil {
+		t.Fatal(err)
+	}
+	for _, siapath := range []string{"a", "a/b", "e", "f/g", "f"} {
+		if err := rt.renter.CreateDir(siapath); err != ErrPathOverload {
+			t.Errorf("expected ErrPathOverload when creating %q, got %v", siapath, err)
+		}
+	}
+
+	// List the root directory.
+	listDir := func(siapath string) ([]string, []string) {
+		dirs, files, err := rt.renter.ListDir(siapath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var dirNames, fileNames []string
+		for _, d := range dirs {
+			dirNames = append(dirNames, d.SiaPath)
+		}
+		for _, f := range files {
+			fileNames = append(fileNames, f.SiaPath)
+		}
+		return dirNames, fileNames
+	}
+	dirs, files := listDir("")
+	if strings.Join(dirs, " ") != "a f" || strings.Join(files, " ") != "e" {
+		t.Fatal("wrong root directory listing:", dirs, files)
+	}
+	subdirs, _, err := rt.renter.ListDir("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The file has no pieces, so its redundancy is 0 unless it is empty.
+	exp := modules.DirectoryInfo{SiaPath: "a/b", NumFiles: 1, Filesize: rt.renter.files["a/b/c"].size, Health: -1}
+	if exp.Filesize != 0 {
+		exp.Health = 0
+	}
+	if len(subdirs) != 1 || subdirs[0] != exp {
+		t.Fatal("wrong subdirectory info:", subdirs)
+	}
+	if _, _, err := rt.renter.ListDir("x"); err != ErrUnknownPath {
+		t.Fatal("expected ErrUnknownPath, got", err)
+	}
+
+	// Rename a directory into the empty directory.
+	if err := rt.renter.RenameDir("a", "a/x"); err != errMoveDirIntoItself {
+		t.Fatal("expected errMoveDirIntoItself, got", err)
+	}
+	if err := rt.renter.RenameDir("a", "e"); err != ErrPathOverload {
+		t.Fatal("expected ErrPathOverload, got", err)
+	}
+	if err := rt.renter.RenameDir("x", "y"); err != ErrUnknownPath {
+		t.Fatal("expected ErrUnknownPath, got", err)
+	}
+	if err := rt.renter.RenameDir("a", "f/g/h"); err != nil {
+		t.Fatal(err)
+	}
+	dirs, files = listDir("f/g/h")
+	if strings.Join(dirs, " ") != "f/g/h/b" || strings.Join(files, " ") != "f/g/h/d" {
+		t.Fatal("wrong listing of the renamed directory:", dirs, files)
+	}
+	if _, _, err := rt.renter.ListDir("a"); err != ErrUnknownPath {
+		t.Fatal("expected the old directory to be gone, got", err)
+	}
+
+	// Rename the created directory and check that the directories are
+	// loaded from disk again.
+	if err := rt.renter.RenameDir("f", "i"); err != nil {
+		t.Fatal(err)
+	}
+	id := rt.renter.mu.Lock()
+	rt.renter.files = make(map[string]*file)
+	rt.renter.dirs = make(map[string]struct{})
+	err = rt.renter.load()
+	rt.renter.mu.Unlock(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dirs, files = listDir("")
+	if strings.Join(dirs, " ") != "i" || strings.Join(files, " ") != "e" {
+		t.Fatal("wrong root directory listing after loading:", dirs, files)
+	}
+	dirs, files = listDir("i/g/h")
+	if strings.Join(dirs, " ") != "i/g/h/b" || strings.Join(files, " ") != "i/g/h/d" {
+		t.Fatal("wrong listing of the renamed directory after loading:", dirs, files)
+	}
+}
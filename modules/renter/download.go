@@ -6,9 +6,12 @@ package renter
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"io"
 	"os"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -25,9 +28,12 @@ const (
 )
 
 var (
-	errInsufficientHosts  = errors.New("insufficient hosts to recover file")
-	errInsufficientPieces = errors.New("couldn't fetch enough pieces to recover data")
-	errPrevErr            = errors.New("download could not be completed due to a previous error")
+	errChunkFinished       = errors.New("chunk was recovered without this piece")
+	errInsufficientHosts   = errors.New("insufficient hosts to recover file")
+	errInsufficientPieces  = errors.New("couldn't fetch enough pieces to recover data")
+	errPrevErr             = errors.New("download could not be completed due to a previous error")
+	errUnknownPriority     = errors.New("unknown download priority")
+	errUnknownVerification = errors.New("unknown download verification level")
 
 	// maxActiveDownloadPieces determines the maximum number of pieces that are
 	// allowed to be concurrently downloading. More pieces means more
@@ -56,6 +62,28 @@ type (
 		// have tried to fetch a piece of the chunk.
 		completedPieces map[uint64][]byte
 		workerAttempts  map[types.FileContractID]bool
+
+		// overdrive is the number of pieces requested beyond the minimum
+		// needed to recover the chunk, and piecesInFlight is the number of
+		// pieces that workers are currently fetching. finished is closed once
+		// recovery of the chunk has been attempted, which cancels the pieces
+		// that are still in flight.
+		overdrive      int
+		piecesInFlight int
+		finished       chan struct{}
+
+		// startTime is the time at which the chunk was scheduled for
+		// download.
+		startTime time.Time
+
+		// seq orders the chunks of equal priority in the chunk queue.
+		seq uint64
+
+		// memoryReserved is the memory that was allocated from the user
+		// download class for the pieces of the chunk, and that has not been
+		// released yet. Repair downloads use the memory of the chunk that is
+		// being repaired instead.
+		memoryReserved uint64
 	}
 
 	// A download is a file download that has been queued by the renter.
@@ -68,6 +96,10 @@ type (
 		offset             uint64
 		length             uint64
 
+		// chunkResults tracks the outcome of every chunk that has been
+		// scheduled for download.
+		chunkResults map[uint64]*modules.DownloadChunkResult
+
 		// Timestamp information.
 		completeTime time.Time
 		startTime    time.Time
@@ -80,6 +112,25 @@ type (
 		masterKey   crypto.TwofishKey
 		numChunks   uint64
 
+		// repair indicates that the download is fetching data for the repair
+		// loop rather than for the user. Repair downloads are subject to the
+		// renter's repair throttle.
+		repair bool
+
+		// priority determines the order in which the chunks of concurrent
+		// downloads are fetched.
+		priority modules.DownloadPriority
+
+		// cache is the renter's chunk cache, which receives every chunk that
+		// is recovered by the download.
+		cache *chunkCache
+
+		// cancel is closed once the download's context is done or the renter
+		// shuts down, aborting the download's host operations. Downloads
+		// without a context leave cancel nil and use the renter's stop
+		// channel instead.
+		cancel <-chan struct{}
+
 		// pieceSet contains a sparse map of the chunk indices to be downloaded to
 		// their piece data.
 		pieceSet          map[uint64]map[types.FileContractID]pieceData
@@ -114,11 +165,15 @@ type (
 		//
 		// resultChan is the channel that is used to receive completed worker
 		// downloads.
+		//
+		// memoryBlocked indicates that the next chunk in the queue is waiting
+		// for memory.
 		activePieces     int
 		activeWorkers    map[types.FileContractID]struct{}
 		availableWorkers []*worker
 		incompleteChunks []*chunkDownload
 		resultChan       chan finishedDownload
+		memoryBlocked    bool
 	}
 )
 
@@ -135,6 +190,7 @@ func (r *Renter) newSectionDownload(f *file, destination modules.DownloadWriter,
 	// Settings specific to a chunk download.
 	d.offset = offset
 	d.length = length
+	d.cache = r.chunkCache
 
 	// Calculate chunks to download.
 	minChunk := offset / f.chunkSize()
@@ -160,7 +216,9 @@ func newDownload(f *file, destination modules.DownloadWriter) *download {
 		masterKey:        f.masterKey,
 		numChunks:        f.numChunks(),
 		siapath:          f.name,
+		priority:         modules.PriorityInteractive,
 		downloadFinished: make(chan struct{}),
+		chunkResults:     make(map[uint64]*modules.DownloadChunkResult),
 		finishedChunks:   make(map[uint64]bool),
 	}
 }
@@ -207,6 +265,83 @@ func (d *download) Err() error {
 	return d.downloadErr
 }
 
+// chunkResult returns the result of the chunk at 'index', creating it if it
+// does not exist yet. The download's lock must be held.
+func (d *download) chunkResult(index uint64) *modules.DownloadChunkResult {
+	cr, exists := d.chunkResults[index]
+	if !exists {
+		cr = &modules.DownloadChunkResult{Index: index}
+		d.chunkResults[index] = cr
+	}
+	return cr
+}
+
+// Result returns the outcome of the download so far, with the chunks ordered
+// by index.
+func (d *download) Result() modules.DownloadResult {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	result := modules.DownloadResult{
+		SiaPath:  d.siapath,
+		Duration: time.Since(d.startTime),
+	}
+	if d.downloadComplete {
+		result.Duration = d.completeTime.Sub(d.startTime)
+	}
+	indices := make([]uint64, 0, len(d.chunkResults))
+	for index := range d.chunkResults {
+		indices = append(indices, index)
+	}
+	sort.Slice(indices, func(i, j int) bool { return indices[i] < indices[j] })
+	for _, index := range indices {
+		cr := *d.chunkResults[index]
+		cr.Hosts = append([]types.SiaPublicKey(nil), cr.Hosts...)
+		cr.FailedHosts = append([]types.SiaPublicKey(nil), cr.FailedHosts...)
+		result.Chunks = append(result.Chunks, cr)
+	}
+	return result
+}
+
+// managedWatchContext fails the download with the context's error once ctx
+// is done, and sets up the download's cancel channel.
+func (r *Renter) managedWatchContext(ctx context.Context, d *download) {
+	if ctx.Done() == nil {
+		return
+	}
+	cancel := make(chan struct{})
+	d.cancel = cancel
+	go func() {
+		select {
+		case <-ctx.Done():
+			d.mu.Lock()
+			d.fail(ctx.Err())
+			d.mu.Unlock()
+		case <-r.tg.StopChan():
+		case <-d.downloadFinished:
+			return
+		}
+		close(cancel)
+	}()
+}
+
+// skipChunks removes chunks from the download, so that the download completes
+// without them. The skipped chunks are reported as unverified. If no chunks
+// remain, the download fails with ErrUploadIncomplete.
+func (d *download) skipChunks(indices []uint64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, index := range indices {
+		delete(d.finishedChunks, index)
+		delete(d.pieceSet, index)
+		d.chunkResult(index)
+	}
+	if len(d.finishedChunks) == 0 {
+		d.fail(ErrUploadIncomplete)
+	}
+}
+
 // fail will mark the download as complete, but with the provided error.
 func (d *download) fail(err error) {
 	if d.downloadComplete {
@@ -216,6 +351,7 @@ func (d *download) fail(err error) {
 
 	d.downloadComplete = true
 	d.downloadErr = err
+	d.completeTime = time.Now()
 	close(d.downloadFinished)
 	// TODO: log the error from Close().
 	d.destination.Close()
@@ -267,52 +403,47 @@ func (cd *chunkDownload) recoverChunk() error {
 	}
 
 	result := recoverWriter.Bytes()
-
-	// Calculate the offset. If the offset is within the chunk, the
-	// requested offset is passed, otherwise the offset of the chunk
-	// within the overall file is passed.
-	chunkBaseAddress := cd.index * cd.download.chunkSize
-	chunkTopAddress := chunkBaseAddress + cd.download.chunkSize - 1
-	off := chunkBaseAddress
-	lowerBound := 0
-	if cd.download.offset >= chunkBaseAddress && cd.download.offset <= chunkTopAddress {
-		off = cd.download.offset
-		offsetInBlock := off - chunkBaseAddress
-		lowerBound = int(offsetInBlock) // If the offset is within the block, part of the block will be ignored
+	if cd.download.cache != nil {
+		cd.download.cache.managedAdd(chunkCacheKey{cd.download.masterKey, cd.index}, result)
 	}
+	return cd.download.managedWriteChunk(cd.index, result, cd.startTime, false)
+}
 
-	// Truncate b if writing the whole buffer at the specified offset would
-	// exceed the maximum file size.
-	upperBound := cd.download.chunkSize
-	if chunkTopAddress > cd.download.length+cd.download.offset {
-		diff := chunkTopAddress - (cd.download.length + cd.download.offset)
-		upperBound -= diff + 1
-	}
-	if upperBound > uint64(len(result)) {
-		upperBound = uint64(len(result))
+// managedWriteChunk writes the part of the recovered chunk 'index' that
+// overlaps the requested section to the download's destination, and marks the
+// chunk as finished. 'cached' indicates that the chunk was served from the
+// chunk cache.
+func (d *download) managedWriteChunk(index uint64, data []byte, start time.Time, cached bool) error {
+	// Trim the chunk to the part that overlaps the requested section.
+	off, lowerBound, upperBound := d.chunkSection(index)
+	if upperBound > uint64(len(data)) {
+		upperBound = uint64(len(data))
 	}
-
-	result = result[lowerBound:upperBound]
+	data = data[lowerBound:upperBound]
 
 	// Write the bytes to the requested output.
-	_, err = cd.download.destination.WriteAt(result, int64(off))
+	_, err := d.destination.WriteAt(data, int64(off))
 	if err != nil {
 		return build.ExtendErr("unable to write to download destination", err)
 	}
 
-	cd.download.mu.Lock()
-	defer cd.download.mu.Unlock()
+	d.mu.Lock()
+	defer d.mu.Unlock()
 
 	// Update the download to signal that this chunk has completed. Only update
 	// after the sync, so that durability is maintained.
-	if cd.download.finishedChunks[cd.index] {
+	if d.finishedChunks[index] {
 		build.Critical("recovering chunk when the chunk has already finished downloading")
 	}
-	cd.download.finishedChunks[cd.index] = true
+	d.finishedChunks[index] = true
+	cr := d.chunkResult(index)
+	cr.Duration = time.Since(start)
+	cr.Verified = true
+	cr.Cached = cached
 
 	// Determine whether the download is complete.
 	nowComplete := true
-	for _, chunkComplete := range cd.download.finishedChunks {
+	for _, chunkComplete := range d.finishedChunks {
 		if !chunkComplete {
 			nowComplete = false
 			break
@@ -320,9 +451,10 @@ func (cd *chunkDownload) recoverChunk() error {
 	}
 	if nowComplete {
 		// Signal that the download is complete.
-		cd.download.downloadComplete = true
-		close(cd.download.downloadFinished)
-		err = cd.download.destination.Close()
+		d.downloadComplete = true
+		d.completeTime = time.Now()
+		close(d.downloadFinished)
+		err = d.destination.Close()
 		if err != nil {
 			return err
 		}
@@ -330,44 +462,83 @@ func (cd *chunkDownload) recoverChunk() error {
 	return nil
 }
 
+// chunkSection returns the part of chunk 'index' that overlaps the section
+// [offset, offset+length) requested by the download. 'off' is the position
+// of the part within the file, and 'lower' and 'upper' are its bounds within
+// the chunk.
+func (d *download) chunkSection(index uint64) (off, lower, upper uint64) {
+	chunkStart := index * d.chunkSize
+	chunkEnd := chunkStart + d.chunkSize
+	sectionEnd := d.offset + d.length
+
+	off = chunkStart
+	if d.offset > chunkStart {
+		off = d.offset
+	}
+	upper = d.chunkSize
+	if sectionEnd < chunkEnd {
+		upper = sectionEnd - chunkStart
+	}
+	return off, off - chunkStart, upper
+}
+
 // addDownloadToChunkQueue takes a file and adds all incomplete work from the file
-// to the renter's chunk queue.
+// to the renter's chunk queue. Chunks that are in the chunk cache are written
+// to the download's destination right away instead.
 func (r *Renter) addDownloadToChunkQueue(d *download) {
 	d.mu.Lock()
-	defer d.mu.Unlock()
-
 	// Skip this file if it has already errored out or has already finished
 	// downloading.
 	if d.downloadComplete {
+		d.mu.Unlock()
 		return
 	}
+	var unfinished []uint64
+	for i, isChunkFinished := range d.finishedChunks {
+		if !isChunkFinished {
+			unfinished = append(unfinished, i)
+		}
+	}
+	d.mu.Unlock()
 
 	// Add the unfinished chunks one at a time.
-	for i, isChunkFinished := range d.finishedChunks {
-		// Skip chunks that have already finished downloading.
-		if isChunkFinished {
-			continue
+	for _, i := range unfinished {
+		if d.cache != nil {
+			if data, cached := d.cache.managedGet(chunkCacheKey{d.masterKey, i}); cached {
+				if err := d.managedWriteChunk(i, data, time.Now(), true); err != nil {
+					r.dedupLog.Println("Download failed - could not write a cached chunk:", err)
+					d.mu.Lock()
+					d.fail(err)
+					d.mu.Unlock()
+					return
+				}
+				atomic.AddUint64(&d.atomicDataReceived, d.reportedPieceSize*uint64(d.erasureCode.MinPieces()))
+				continue
+			}
 		}
 
 		// Add this chunk to the chunk queue.
 		cd := &chunkDownload{
 			download: d,
-			index:    uint64(i),
+			index:    i,
 
 			completedPieces: make(map[uint64][]byte),
 			workerAttempts:  make(map[types.FileContractID]bool),
+			finished:        make(chan struct{}),
 		}
+		d.mu.Lock()
 		for fcid := range d.pieceSet[i] {
 			cd.workerAttempts[fcid] = false
 		}
-		r.chunkQueue = append(r.chunkQueue, cd)
+		d.mu.Unlock()
+		r.chunkQueue.push(cd)
 	}
 }
 
 // downloadIteration performs one iteration of the download loop.
 func (r *Renter) managedDownloadIteration(ds *downloadState) {
 	// Check for sleep and break conditions.
-	if len(ds.incompleteChunks) == 0 && len(ds.activeWorkers) == 0 && len(r.chunkQueue) == 0 {
+	if len(ds.incompleteChunks) == 0 && len(ds.activeWorkers) == 0 && r.chunkQueue.len() == 0 {
 		// If the above conditions are true, it should also be the case that
 		// the number of active pieces is zero.
 		if ds.activePieces != 0 {
@@ -388,16 +559,27 @@ func (r *Renter) managedDownloadIteration(ds *downloadState) {
 	// Update the set of workers to include everyone in the worker pool.
 	r.managedUpdateWorkerPool()
 	id := r.mu.Lock()
-	ds.availableWorkers = make([]*worker, 0, len(r.workerPool))
-	for _, worker := range r.workerPool {
+	pool := make([]*worker, 0, len(r.workerPool))
+	for _, workers := range r.workerPool {
+		// Only the first worker of each contract performs downloads.
+		pool = append(pool, workers[0])
+	}
+	r.mu.Unlock(id)
+	ds.availableWorkers = make([]*worker, 0, len(pool))
+	for _, worker := range pool {
 		// Ignore workers that are already in the active set of workers.
 		_, exists := ds.activeWorkers[worker.contract.ID]
 		if exists {
 			continue
 		}
 
-		// Ignore workers that have a download failure recently.
-		if time.Since(worker.downloadRecentFailure) < downloadFailureCooldown {
+		// Ignore workers that have a download failure recently. The failure
+		// is read under the worker's lock, which must not be acquired while
+		// holding the renter's lock.
+		worker.mu.Lock()
+		recentFailure := worker.downloadRecentFailure
+		worker.mu.Unlock()
+		if time.Since(recentFailure) < downloadFailureCooldown {
 			continue
 		}
 
@@ -407,7 +589,6 @@ func (r *Renter) managedDownloadIteration(ds *downloadState) {
 
 		ds.availableWorkers = append(ds.availableWorkers, worker)
 	}
-	r.mu.Unlock(id)
 
 	// Add new chunks to the extent that resources allow.
 	r.managedScheduleNewChunks(ds)
@@ -424,6 +605,13 @@ func (r *Renter) managedDownloadIteration(ds *downloadState) {
 // managedScheduleIncompleteChunks also checks whether a chunk is unable to be
 // completed.
 func (r *Renter) managedScheduleIncompleteChunks(ds *downloadState) {
+	// Serve interactive downloads first, so that they are not held up by
+	// background downloads that were scheduled earlier.
+	sort.SliceStable(ds.incompleteChunks, func(i, j int) bool {
+		return ds.incompleteChunks[i].download.priority != modules.PriorityBackground &&
+			ds.incompleteChunks[j].download.priority == modules.PriorityBackground
+	})
+
 	var newIncompleteChunks []*chunkDownload
 loop:
 	for _, incompleteChunk := range ds.incompleteChunks {
@@ -434,6 +622,7 @@ loop:
 		if downloadComplete {
 			// The download has most likely failed. No need to complete this
 			// chunk.
+			r.managedReleaseChunkMemory(incompleteChunk)
 			ds.activePieces--                                       // For the current incomplete chunk.
 			ds.activePieces -= len(incompleteChunk.completedPieces) // For all completed pieces.
 
@@ -443,6 +632,14 @@ loop:
 			continue
 		}
 
+		// Drop this piece if the chunk was recovered without it.
+		select {
+		case <-incompleteChunk.finished:
+			ds.activePieces--
+			continue
+		default:
+		}
+
 		// Try to find a worker that is able to pick up the slack on the
 		// incomplete download from the set of available workers.
 		for i, worker := range ds.availableWorkers {
@@ -466,6 +663,7 @@ loop:
 				resultChan:    ds.resultChan,
 			}
 			incompleteChunk.workerAttempts[worker.contract.ID] = true
+			incompleteChunk.piecesInFlight++
 			ds.availableWorkers = append(ds.availableWorkers[:i], ds.availableWorkers[i+1:]...)
 			ds.activeWorkers[worker.contract.ID] = struct{}{}
 			select {
@@ -496,10 +694,19 @@ loop:
 		// or the active set is able to pick up the slack. Verify that they are
 		// safe to be scheduled, and then schedule them if so.
 
+		// If the chunk was requested with overdrive, the pieces that are
+		// still in flight may be enough to recover it. Only this piece is
+		// given up.
+		if len(incompleteChunk.completedPieces)+incompleteChunk.piecesInFlight >= incompleteChunk.download.erasureCode.MinPieces() {
+			ds.activePieces--
+			continue
+		}
+
 		// Cannot find workers to complete this download, fail the download
 		// connected to this chunk.
-		r.log.Println("Not enough workers to finish download:", errInsufficientHosts)
+		r.dedupLog.Println("Not enough workers to finish download:", errInsufficientHosts)
 		incompleteChunk.download.fail(errInsufficientHosts)
+		r.managedReleaseChunkMemory(incompleteChunk)
 
 		// Clear out the piece burden for this chunk.
 		ds.activePieces--                                       // for the current incomplete chunk
@@ -514,26 +721,58 @@ loop:
 // managedScheduleNewChunks uses the set of available workers to schedule new
 // chunks if there are resources available to begin downloading them.
 func (r *Renter) managedScheduleNewChunks(ds *downloadState) {
+	id := r.mu.RLock()
+	overdrive := r.downloadOverdrive
+	r.mu.RUnlock(id)
+
 	// Keep adding chunks until a break condition is hit.
+	ds.memoryBlocked = false
 	for {
-		chunkQueueLen := len(r.chunkQueue)
-		if chunkQueueLen == 0 {
+		// View the next chunk.
+		nextChunk := r.chunkQueue.peek()
+		if nextChunk == nil {
 			// There are no more chunks to initiate, return.
 			return
 		}
 
-		// View the next chunk.
-		nextChunk := r.chunkQueue[0]
+		// Request extra pieces as configured, as long as there are enough
+		// hosts holding a piece of the chunk. Overdrive never makes a chunk
+		// exceed the limit of active pieces on its own.
+		minPieces := nextChunk.download.erasureCode.MinPieces()
+		nextChunk.overdrive = overdrive
+		if extra := len(nextChunk.workerAttempts) - minPieces; nextChunk.overdrive > extra {
+			nextChunk.overdrive = extra
+		}
+		if extra := maxActiveDownloadPieces - minPieces; nextChunk.overdrive > extra {
+			nextChunk.overdrive = extra
+		}
+		if nextChunk.overdrive < 0 {
+			nextChunk.overdrive = 0
+		}
+		pieces := minPieces + nextChunk.overdrive
 
 		// Check whether there are enough resources to perform the download.
-		if ds.activePieces+nextChunk.download.erasureCode.MinPieces() > maxActiveDownloadPieces {
+		if ds.activePieces+pieces > maxActiveDownloadPieces {
 			// There is a limited amount of RAM available, and scheduling the
 			// next piece would consume too much RAM.
 			return
 		}
 
+		// User downloads hold the memory of their pieces until the chunk is
+		// recovered. The memory is allocated from the user download class, so
+		// that repairs can't starve user downloads.
+		if !nextChunk.download.repair {
+			memoryNeeded := uint64(pieces) * modules.SectorSize
+			if !r.managedMemoryTryReserve(memoryNeeded, memoryDownloadSectors, memoryClassUserDownload) {
+				ds.memoryBlocked = true
+				return
+			}
+			nextChunk.memoryReserved = memoryNeeded
+		}
+
 		// Chunk is set to be downloaded. Clear it from the queue.
-		r.chunkQueue = r.chunkQueue[1:]
+		r.chunkQueue.pop()
+		nextChunk.startTime = time.Now()
 
 		// Check if the download has already completed. If it has, it's because
 		// the download failed.
@@ -542,22 +781,37 @@ func (r *Renter) managedScheduleNewChunks(ds *downloadState) {
 		nextChunk.download.mu.Unlock()
 		if downloadComplete {
 			// Download has already failed.
+			r.managedReleaseChunkMemory(nextChunk)
 			continue
 		}
 
 		// Add an incomplete chunk entry for every piece of the download.
-		for i := 0; i < nextChunk.download.erasureCode.MinPieces(); i++ {
+		for i := 0; i < pieces; i++ {
 			ds.incompleteChunks = append(ds.incompleteChunks, nextChunk)
 		}
-		ds.activePieces += nextChunk.download.erasureCode.MinPieces()
+		ds.activePieces += pieces
+		nextChunk.download.mu.Lock()
+		nextChunk.download.chunkResult(nextChunk.index).Overdrive = uint64(nextChunk.overdrive)
+		nextChunk.download.mu.Unlock()
 	}
 }
 
 // managedWaitOnDownloadWork will wait for workers to return after attempting to
 // download a piece.
 func (r *Renter) managedWaitOnDownloadWork(ds *downloadState) {
-	// If there are no workers performing work, return early.
+	// If there are no workers performing work, return early. If the next
+	// chunk is waiting for memory that is held by uploads, wait a moment
+	// before trying again.
 	if len(ds.activeWorkers) == 0 {
+		if !ds.memoryBlocked {
+			return
+		}
+		select {
+		case <-r.tg.StopChan():
+		case d := <-r.newDownloads:
+			r.addDownloadToChunkQueue(d)
+		case <-time.After(downloadMemoryPollInterval):
+		}
 		return
 	}
 
@@ -576,22 +830,47 @@ func (r *Renter) managedWaitOnDownloadWork(ds *downloadState) {
 	// Prepare the piece.
 	workerID := finishedDownload.workerID
 	delete(ds.activeWorkers, workerID)
+	cd := finishedDownload.chunkDownload
+	cd.piecesInFlight--
+
+	// Discard pieces that arrive after the chunk was recovered. They were
+	// requested for overdrive, and are no longer needed.
+	select {
+	case <-cd.finished:
+		ds.activePieces--
+		return
+	default:
+	}
 
 	// Fetch the corresponding worker.
 	id := r.mu.RLock()
-	worker, exists := r.workerPool[workerID]
+	workers, exists := r.workerPool[workerID]
 	r.mu.RUnlock(id)
 	if !exists {
-		ds.incompleteChunks = append(ds.incompleteChunks, finishedDownload.chunkDownload)
+		ds.incompleteChunks = append(ds.incompleteChunks, cd)
 		return
 	}
+	worker := workers[0]
 
-	// Check for an error.
-	cd := finishedDownload.chunkDownload
+	// Check for an error. Errors of downloads that have already failed, for
+	// example because they were cancelled, are not held against the worker.
+	cd.download.mu.Lock()
+	downloadComplete := cd.download.downloadComplete
+	cd.download.mu.Unlock()
+	if finishedDownload.err != nil && downloadComplete {
+		ds.incompleteChunks = append(ds.incompleteChunks, cd)
+		return
+	}
 	if finishedDownload.err != nil {
-		r.log.Debugln("Error when downloading a piece:", finishedDownload.err)
+		r.dedupLog.Debugln("Error when downloading a piece:", finishedDownload.err)
+		worker.mu.Lock()
 		worker.downloadRecentFailure = time.Now()
+		worker.mu.Unlock()
 		ds.incompleteChunks = append(ds.incompleteChunks, cd)
+		cd.download.mu.Lock()
+		cr := cd.download.chunkResult(cd.index)
+		cr.FailedHosts = append(cr.FailedHosts, worker.hostPubKey)
+		cd.download.mu.Unlock()
 		return
 	}
 
@@ -603,14 +882,25 @@ func (r *Renter) managedWaitOnDownloadWork(ds *downloadState) {
 	}
 	cd.completedPieces[finishedDownload.pieceIndex] = finishedDownload.data
 	atomic.AddUint64(&cd.download.atomicDataReceived, cd.download.reportedPieceSize)
+	cd.download.mu.Lock()
+	cr := cd.download.chunkResult(cd.index)
+	cr.Hosts = append(cr.Hosts, worker.hostPubKey)
+	cr.Bytes += uint64(len(finishedDownload.data))
+	cd.download.mu.Unlock()
 
-	// If the chunk has completed, perform chunk recovery.
+	// If the chunk has completed, perform chunk recovery. The pieces that are
+	// still in flight are cancelled.
 	if len(cd.completedPieces) == cd.download.erasureCode.MinPieces() {
+		cd.download.mu.Lock()
+		cd.download.chunkResult(cd.index).Stragglers = uint64(cd.piecesInFlight)
+		cd.download.mu.Unlock()
+		close(cd.finished)
 		err := cd.recoverChunk()
 		ds.activePieces -= len(cd.completedPieces)
 		cd.completedPieces = make(map[uint64][]byte)
+		r.managedReleaseChunkMemory(cd)
 		if err != nil {
-			r.log.Println("Download failed - could not recover a chunk:", err)
+			r.dedupLog.Println("Download failed - could not recover a chunk:", err)
 			cd.download.mu.Lock()
 			cd.download.fail(err)
 			cd.download.mu.Unlock()
@@ -618,14 +908,24 @@ func (r *Renter) managedWaitOnDownloadWork(ds *downloadState) {
 	}
 }
 
+// managedReleaseChunkMemory releases the memory that was reserved for the
+// pieces of a user download chunk. It is safe to call multiple times.
+func (r *Renter) managedReleaseChunkMemory(cd *chunkDownload) {
+	if cd.memoryReserved == 0 {
+		return
+	}
+	r.managedMemoryAvailableAdd(cd.memoryReserved, memoryDownloadSectors, memoryClassUserDownload)
+	cd.memoryReserved = 0
+}
+
 // threadedDownloadLoop utilizes the worker pool to make progress on any queued
 // downloads.
 func (r *Renter) threadedDownloadLoop() {
 	// Compile the set of available workers.
 	id := r.mu.RLock()
 	availableWorkers := make([]*worker, 0, len(r.workerPool))
-	for _, worker := range r.workerPool {
-		availableWorkers = append(availableWorkers, worker)
+	for _, workers := range r.workerPool {
+		availableWorkers = append(availableWorkers, workers[0])
 	}
 	r.mu.RUnlock(id)
 
@@ -802,3 +1102,84 @@ func (dw *DownloadHttpWriter) WriteAt(b []byte, off int64) (int, error) {
 
 	return totalDataSent, nil
 }
+
+// DownloadMirrorWriter is an implementation of DownloadWriter that writes the
+// downloaded data to multiple io.Writers. Like the DownloadHttpWriter, data
+// that arrives out of order is buffered until it can be written sequentially.
+// A destination that returns an error is skipped for the remainder of the
+// download, while the other destinations continue to receive data.
+type DownloadMirrorWriter struct {
+	dsts           []io.Writer
+	errs           []error
+	offset         int            // The number of bytes written to the destinations so far.
+	firstByteIndex int            // The index of the first byte in the original file.
+	buffer         map[int][]byte // Buffer used for storing the chunks until they can be written.
+}
+
+// NewDownloadMirrorWriter creates a new DownloadWriter that writes to all of
+// the provided destinations.
+func NewDownloadMirrorWriter(dsts []io.Writer, offset uint64) *DownloadMirrorWriter {
+	return &DownloadMirrorWriter{
+		dsts:           dsts,
+		errs:           make([]error, len(dsts)),
+		firstByteIndex: int(offset),
+		buffer:         make(map[int][]byte),
+	}
+}
+
+// Destination implements the Destination method of the DownloadWriter
+// interface and informs callers where this download writer is
+// being written to.
+func (dw *DownloadMirrorWriter) Destination() string {
+	return "mirrors"
+}
+
+// Close implements DownloadWriter's Close method.
+func (dw *DownloadMirrorWriter) Close() error {
+	return nil
+}
+
+// Err returns the errors encountered by the destinations, composed into a
+// single error. nil is returned if every destination succeeded.
+func (dw *DownloadMirrorWriter) Err() error {
+	var errs []error
+	for i, err := range dw.errs {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("mirror %v: %v", i, err))
+		}
+	}
+	return build.ComposeErrors(errs...)
+}
+
+// WriteAt buffers parts of the file and writes all data that can be written
+// sequentially to every destination that has not failed. An error is only
+// returned once every destination has failed.
+func (dw *DownloadMirrorWriter) WriteAt(b []byte, off int64) (int, error) {
+	dw.buffer[int(off)-dw.firstByteIndex] = b
+
+	totalDataSent := 0
+	for {
+		data, exists := dw.buffer[dw.offset]
+		if !exists {
+			break
+		}
+		for i, dst := range dw.dsts {
+			if dw.errs[i] != nil {
+				continue
+			}
+			if _, err := dst.Write(data); err != nil {
+				dw.errs[i] = err
+			}
+		}
+		delete(dw.buffer, dw.offset)
+		dw.offset += len(data)
+		totalDataSent += len(data)
+	}
+
+	for _, err := range dw.errs {
+		if err == nil {
+			return totalDataSent, nil
+		}
+	}
+	return totalDataSent, errors.New("all mirror destinations failed")
+}
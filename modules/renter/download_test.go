@@ -1,11 +1,25 @@
 package renter
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/pachisi456/Sia/build"
+	"github.com/pachisi456/Sia/crypto"
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/modules/renter/contractor"
+	"github.com/pachisi456/Sia/types"
+
+	"github.com/NebulousLabs/fastrand"
 )
 
 // TestRenterDownloadFileWriter verifies that the renter's DownloadFileWriter
@@ -50,3 +64,426 @@ func TestRenterDownloadFileWriter(t *testing.T) {
 		t.Fatal("expected read to return file already closed, got", err, "instead.")
 	}
 }
+
+// failingWriter is an io.Writer that always returns an error.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("write failed") }
+
+// TestDownloadChunkSection checks that only the requested byte range of each
+// chunk is written by a ranged download.
+func TestDownloadChunkSection(t *testing.T) {
+	d := &download{chunkSize: 10}
+	tests := []struct {
+		offset, length    uint64
+		index             uint64
+		off, lower, upper uint64
+	}{
+		{0, 30, 1, 10, 0, 10},  // chunk fully inside the section
+		{3, 4, 0, 3, 3, 7},     // section inside a single chunk
+		{0, 9, 0, 0, 0, 9},     // section ends one byte before the chunk
+		{5, 10, 0, 5, 5, 10},   // section starts within the first chunk
+		{5, 10, 1, 10, 0, 5},   // and ends within the second
+		{10, 10, 1, 10, 0, 10}, // section aligned to a chunk
+	}
+	for _, test := range tests {
+		d.offset, d.length = test.offset, test.length
+		off, lower, upper := d.chunkSection(test.index)
+		if off != test.off || lower != test.lower || upper != test.upper {
+			t.Errorf("section [%v, %v) of chunk %v: expected (%v, %v, %v), got (%v, %v, %v)",
+				test.offset, test.offset+test.length, test.index, test.off, test.lower, test.upper, off, lower, upper)
+		}
+	}
+}
+
+// TestDownloadMirrorWriter checks that the DownloadMirrorWriter writes data to
+// every destination in order, and that a failing destination does not prevent
+// the other destinations from receiving the data.
+func TestDownloadMirrorWriter(t *testing.T) {
+	var buf1, buf2 bytes.Buffer
+	dw := NewDownloadMirrorWriter([]io.Writer{&buf1, failingWriter{}, &buf2}, 10)
+
+	// Write the data out of order.
+	if _, err := dw.WriteAt([]byte("world"), 15); err != nil {
+		t.Fatal(err)
+	}
+	if buf1.Len() != 0 {
+		t.Fatal("data was written before the preceding data arrived")
+	}
+	if _, err := dw.WriteAt([]byte("hello"), 10); err != nil {
+		t.Fatal(err)
+	}
+	for _, buf := range []*bytes.Buffer{&buf1, &buf2} {
+		if buf.String() != "helloworld" {
+			t.Fatal("destination has wrong contents:", buf.String())
+		}
+	}
+
+	// The failed destination should be reported.
+	if dw.Err() == nil {
+		t.Fatal("expected the failing destination to be reported")
+	}
+
+	// Once every destination has failed, WriteAt should return an error.
+	dw = NewDownloadMirrorWriter([]io.Writer{failingWriter{}}, 0)
+	if _, err := dw.WriteAt([]byte("hello"), 0); err == nil {
+		t.Fatal("expected an error when every destination has failed")
+	}
+}
+
+// newVerifyContractor returns a verifyContractor with a contract and host for
+// every piece of f's chunks. The contracts are added to f.
+func newVerifyContractor(hc hostContractor, f *file) *verifyContractor {
+	vc := &verifyContractor{
+		hostContractor: hc,
+		hosts:          make(map[types.FileContractID]*mockHost),
+	}
+	for i := 0; i < f.erasureCode.NumPieces(); i++ {
+		contract := modules.RenterContract{
+			ID:            types.FileContractID{byte(i + 1)},
+			HostPublicKey: types.SiaPublicKey{Key: []byte{byte(i + 1)}},
+		}
+		vc.contracts = append(vc.contracts, contract)
+		vc.hosts[contract.ID] = &mockHost{sectors: make(map[crypto.Hash][]byte)}
+		f.contracts[contract.ID] = fileContract{ID: contract.ID}
+	}
+	return vc
+}
+
+// uploadChunk erasure codes and encrypts data as chunk 'chunk' of f, and
+// stores piece i on the host of the i'th contract.
+func (vc *verifyContractor) uploadChunk(t *testing.T, f *file, chunk uint64, data []byte) {
+	pieces, err := f.erasureCode.Encode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for piece, data := range pieces {
+		key := deriveKey(f.masterKey, chunk, uint64(piece))
+		root, _ := vc.hosts[vc.contracts[piece].ID].Upload(key.EncryptBytes(data))
+		fc := f.contracts[vc.contracts[piece].ID]
+		fc.Pieces = append(fc.Pieces, pieceData{Chunk: chunk, Piece: uint64(piece), MerkleRoot: root})
+		f.contracts[fc.ID] = fc
+	}
+}
+
+// TestDownloadIncompletePolicy checks that downloading a file that has not
+// finished uploading behaves according to the download's IncompletePolicy.
+func TestDownloadIncompletePolicy(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Create a file with two chunks, of which only the first has been
+	// uploaded.
+	rsc, _ := NewRSCode(1, 1)
+	f := newFile("foo", rsc, 64, 128)
+	data := fastrand.Bytes(int(f.size))
+	vc := newVerifyContractor(rt.renter.hostContractor, f)
+	vc.uploadChunk(t, f, 0, data[:f.chunkSize()])
+	id := rt.renter.mu.Lock()
+	rt.renter.hostContractor = vc
+	rt.renter.files[f.name] = f
+	rt.renter.tracking[f.name] = trackedFile{RepairPath: "/does/not/exist"}
+	rt.renter.mu.Unlock(id)
+
+	download := func(policy modules.DownloadIncompletePolicy) (modules.DownloadResult, []byte, error) {
+		dst := build.TempDir("renter", t.Name(), string(policy))
+		result, err := rt.renter.DownloadWithResult(modules.RenterDownloadParameters{
+			Siapath:          f.name,
+			Destination:      dst,
+			IncompletePolicy: policy,
+		})
+		downloaded, _ := ioutil.ReadFile(dst)
+		return result, downloaded, err
+	}
+
+	// The fail policy rejects the download.
+	if _, _, err := download(modules.IncompleteFail); err != ErrUploadIncomplete {
+		t.Fatal("expected ErrUploadIncomplete, got", err)
+	}
+
+	// The best-effort policy downloads the first chunk only.
+	result, downloaded, err := download(modules.IncompleteBestEffort)
+	if err != ErrUploadIncomplete {
+		t.Fatal("expected ErrUploadIncomplete, got", err)
+	}
+	if len(result.Chunks) != 2 || !result.Chunks[0].Verified || result.Chunks[1].Verified {
+		t.Fatal("expected only the first chunk to be downloaded, got", result.Chunks)
+	}
+	if len(downloaded) < int(f.chunkSize()) || !bytes.Equal(downloaded[:f.chunkSize()], data[:f.chunkSize()]) {
+		t.Fatal("best-effort download did not write the first chunk")
+	}
+
+	// The wait policy blocks until the second chunk has been uploaded.
+	errChan := make(chan error, 1)
+	go func() {
+		_, downloaded, err := download(modules.IncompleteWait)
+		if err == nil && !bytes.Equal(downloaded, data) {
+			err = errors.New("downloaded data does not match the file")
+		}
+		errChan <- err
+	}()
+	select {
+	case err := <-errChan:
+		t.Fatal("download did not wait for the upload to finish:", err)
+	case <-time.After(5 * downloadIncompletePollInterval):
+	}
+	vc.uploadChunk(t, f, 1, data[f.chunkSize():])
+	select {
+	case err := <-errChan:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("download did not finish after the upload completed")
+	}
+}
+
+// blockingHost is a mockHost whose downloads block until release is closed.
+type blockingHost struct {
+	*mockHost
+	release chan struct{}
+}
+
+func (bh blockingHost) Sector(root crypto.Hash) ([]byte, error) {
+	<-bh.release
+	return bh.mockHost.Sector(root)
+}
+
+// blockingContractor is a verifyContractor whose downloads block until
+// release is closed.
+type blockingContractor struct {
+	*verifyContractor
+	release chan struct{}
+}
+
+func (bc blockingContractor) Downloader(id types.FileContractID, _ <-chan struct{}) (contractor.Downloader, error) {
+	return blockingHost{bc.hosts[id], bc.release}, nil
+}
+
+// TestDownloadContextDeadline checks that a download is cancelled promptly
+// once the deadline of its context passes, even while pieces are still being
+// fetched.
+func TestDownloadContextDeadline(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	rsc, _ := NewRSCode(1, 1)
+	f := newFile("foo", rsc, 64, 128)
+	vc := newVerifyContractor(rt.renter.hostContractor, f)
+	for chunk := uint64(0); chunk < f.numChunks(); chunk++ {
+		vc.uploadChunk(t, f, chunk, fastrand.Bytes(int(f.chunkSize())))
+	}
+	bc := blockingContractor{verifyContractor: vc, release: make(chan struct{})}
+	defer close(bc.release)
+	id := rt.renter.mu.Lock()
+	rt.renter.hostContractor = bc
+	rt.renter.files[f.name] = f
+	rt.renter.mu.Unlock(id)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = rt.renter.DownloadContext(ctx, modules.RenterDownloadParameters{
+		Siapath:     f.name,
+		Destination: build.TempDir("renter", t.Name(), "foo"),
+	})
+	if err != context.DeadlineExceeded {
+		t.Fatal("expected context.DeadlineExceeded, got", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatal("download took", elapsed, "to be cancelled")
+	}
+}
+
+// overdriveContractor is a verifyContractor whose first host blocks its
+// downloads until release is closed.
+type overdriveContractor struct {
+	*verifyContractor
+	release chan struct{}
+}
+
+func (oc overdriveContractor) Downloader(id types.FileContractID, _ <-chan struct{}) (contractor.Downloader, error) {
+	if id == oc.contracts[0].ID {
+		return blockingHost{oc.hosts[id], oc.release}, nil
+	}
+	return oc.hosts[id], nil
+}
+
+// TestDownloadOverdrive checks that a download with overdrive completes its
+// chunks from the fast hosts, without waiting for a host that does not
+// respond.
+func TestDownloadOverdrive(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	rsc, _ := NewRSCode(1, 1)
+	f := newFile("foo", rsc, 64, 128)
+	vc := newVerifyContractor(rt.renter.hostContractor, f)
+	data := fastrand.Bytes(int(f.size))
+	for chunk := uint64(0); chunk < f.numChunks(); chunk++ {
+		vc.uploadChunk(t, f, chunk, data[chunk*f.chunkSize():(chunk+1)*f.chunkSize()])
+	}
+	oc := overdriveContractor{verifyContractor: vc, release: make(chan struct{})}
+	defer close(oc.release)
+	id := rt.renter.mu.Lock()
+	rt.renter.hostContractor = oc
+	rt.renter.files[f.name] = f
+	rt.renter.mu.Unlock(id)
+
+	settings := rt.renter.Settings()
+	settings.DownloadOverdrive = 1
+	if err := rt.renter.SetSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := build.TempDir("renter", t.Name(), "foo")
+	result, err := rt.renter.DownloadWithResult(modules.RenterDownloadParameters{
+		Siapath:     f.name,
+		Destination: dst,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	downloaded, err := ioutil.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(downloaded, data) {
+		t.Fatal("downloaded data does not match the original data")
+	}
+
+	// Every chunk should have been requested from both hosts, and recovered
+	// from the fast host.
+	if len(result.Chunks) != int(f.numChunks()) {
+		t.Fatal("wrong number of chunk results:", len(result.Chunks))
+	}
+	var stragglers uint64
+	for _, cr := range result.Chunks {
+		if cr.Overdrive != 1 {
+			t.Fatal("chunk was not requested with overdrive:", cr)
+		}
+		if len(cr.Hosts) != 1 || cr.Hosts[0].String() != vc.contracts[1].HostPublicKey.String() {
+			t.Fatal("chunk was not recovered from the fast host:", cr)
+		}
+		stragglers += cr.Stragglers
+	}
+
+	// The blocking host is busy with the piece of the first chunk that was
+	// scheduled, which is the only straggler.
+	if stragglers != 1 {
+		t.Fatal("expected 1 straggler, got", stragglers)
+	}
+}
+
+// countingHost is a mockHost that counts how many of its sectors were
+// downloaded with and without verification.
+type countingHost struct {
+	*mockHost
+	verified   *uint64
+	unverified *uint64
+}
+
+func (ch countingHost) Sector(root crypto.Hash) ([]byte, error) {
+	atomic.AddUint64(ch.verified, 1)
+	return ch.mockHost.Sector(root)
+}
+func (ch countingHost) UnverifiedSector(root crypto.Hash) ([]byte, error) {
+	atomic.AddUint64(ch.unverified, 1)
+	return ch.mockHost.Sector(root)
+}
+
+// countingContractor is a verifyContractor whose downloaders are
+// countingHosts.
+type countingContractor struct {
+	*verifyContractor
+	verified   uint64
+	unverified uint64
+}
+
+func (cc *countingContractor) Downloader(id types.FileContractID, _ <-chan struct{}) (contractor.Downloader, error) {
+	return countingHost{cc.hosts[id], &cc.verified, &cc.unverified}, nil
+}
+
+// TestDownloadVerification checks that each download verification level
+// verifies the expected share of the downloaded sectors.
+func TestDownloadVerification(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Create a file with enough chunks that sampled verification is very
+	// unlikely to verify either none or all of them.
+	rsc, _ := NewRSCode(1, 1)
+	f := newFile("foo", rsc, 64, 64*128)
+	cc := &countingContractor{verifyContractor: newVerifyContractor(rt.renter.hostContractor, f)}
+	for chunk := uint64(0); chunk < f.numChunks(); chunk++ {
+		cc.uploadChunk(t, f, chunk, fastrand.Bytes(int(f.chunkSize())))
+	}
+	id := rt.renter.mu.Lock()
+	rt.renter.hostContractor = cc
+	rt.renter.files[f.name] = f
+	rt.renter.mu.Unlock(id)
+
+	// download downloads the file using the given verification level, and
+	// returns the number of verified and unverified sectors.
+	download := func(level modules.DownloadVerification) (verified, unverified uint64) {
+		settings := rt.renter.Settings()
+		settings.DownloadVerification = level
+		settings.DownloadCacheSize = 0
+		if err := rt.renter.SetSettings(settings); err != nil {
+			t.Fatal(err)
+		}
+		atomic.StoreUint64(&cc.verified, 0)
+		atomic.StoreUint64(&cc.unverified, 0)
+		err := rt.renter.Download(modules.RenterDownloadParameters{
+			Siapath:     f.name,
+			Destination: build.TempDir("renter", t.Name(), string(level)),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return atomic.LoadUint64(&cc.verified), atomic.LoadUint64(&cc.unverified)
+	}
+
+	if verified, unverified := download(modules.VerifyFull); verified == 0 || unverified != 0 {
+		t.Fatal("full verification skipped sectors:", verified, "verified,", unverified, "unverified")
+	}
+	if verified, unverified := download(modules.VerifyNone); verified != 0 || unverified == 0 {
+		t.Fatal("verification level none verified sectors:", verified, "verified,", unverified, "unverified")
+	}
+	if verified, unverified := download(modules.VerifySampled); verified == 0 || unverified == 0 {
+		t.Fatal("sampled verification did not verify a subset:", verified, "verified,", unverified, "unverified")
+	}
+
+	// Unknown verification levels are rejected.
+	settings := rt.renter.Settings()
+	settings.DownloadVerification = "bogus"
+	if err := rt.renter.SetSettings(settings); err != errUnknownVerification {
+		t.Fatal("expected errUnknownVerification, got", err)
+	}
+}
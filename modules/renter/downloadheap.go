@@ -0,0 +1,66 @@
+package renter
+
+import (
+	"container/heap"
+
+	"github.com/pachisi456/Sia/modules"
+)
+
+type (
+	// downloadChunkHeap is a priority queue of the chunks that are waiting to
+	// be downloaded. Chunks of interactive downloads are downloaded before
+	// chunks of background downloads, and chunks of equal priority are
+	// downloaded in the order in which they were queued. The heap is only
+	// accessed by the download loop thread, and has no lock.
+	downloadChunkHeap struct {
+		chunks  chunkDownloadHeap
+		nextSeq uint64
+	}
+
+	// chunkDownloadHeap implements heap.Interface for downloadChunkHeap.
+	chunkDownloadHeap []*chunkDownload
+)
+
+func (ch chunkDownloadHeap) Len() int { return len(ch) }
+func (ch chunkDownloadHeap) Less(i, j int) bool {
+	bi := ch[i].download.priority == modules.PriorityBackground
+	bj := ch[j].download.priority == modules.PriorityBackground
+	if bi != bj {
+		return bj
+	}
+	return ch[i].seq < ch[j].seq
+}
+func (ch chunkDownloadHeap) Swap(i, j int)       { ch[i], ch[j] = ch[j], ch[i] }
+func (ch *chunkDownloadHeap) Push(x interface{}) { *ch = append(*ch, x.(*chunkDownload)) }
+func (ch *chunkDownloadHeap) Pop() interface{} {
+	old := *ch
+	n := len(old)
+	x := old[n-1]
+	*ch = old[0 : n-1]
+	return x
+}
+
+// len returns the number of chunks in the heap.
+func (dh *downloadChunkHeap) len() int { return len(dh.chunks) }
+
+// push adds a chunk to the heap, behind the chunks of equal priority that are
+// already queued.
+func (dh *downloadChunkHeap) push(cd *chunkDownload) {
+	cd.seq = dh.nextSeq
+	dh.nextSeq++
+	heap.Push(&dh.chunks, cd)
+}
+
+// peek returns the chunk that should be downloaded next without removing it,
+// or nil if the heap is empty.
+func (dh *downloadChunkHeap) peek() *chunkDownload {
+	if len(dh.chunks) == 0 {
+		return nil
+	}
+	return dh.chunks[0]
+}
+
+// pop removes and returns the chunk that should be downloaded next.
+func (dh *downloadChunkHeap) pop() *chunkDownload {
+	return heap.Pop(&dh.chunks).(*chunkDownload)
+}
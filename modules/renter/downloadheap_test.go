@@ -0,0 +1,37 @@
+package renter
+
+import (
+	"testing"
+
+	"github.com/pachisi456/Sia/modules"
+)
+
+// TestDownloadChunkHeap checks that the chunks of interactive downloads are
+// popped before the chunks of background downloads, and that chunks of equal
+// priority are popped in the order in which they were pushed.
+func TestDownloadChunkHeap(t *testing.T) {
+	interactive := &download{priority: modules.PriorityInteractive}
+	background := &download{priority: modules.PriorityBackground}
+
+	var dh downloadChunkHeap
+	if dh.peek() != nil {
+		t.Fatal("empty heap returned a chunk")
+	}
+	dh.push(&chunkDownload{download: background, index: 0})
+	dh.push(&chunkDownload{download: background, index: 1})
+	dh.push(&chunkDownload{download: interactive, index: 2})
+	dh.push(&chunkDownload{download: interactive, index: 3})
+	dh.push(&chunkDownload{download: background, index: 4})
+
+	for _, index := range []uint64{2, 3, 0, 1, 4} {
+		if dh.peek().index != index {
+			t.Fatal("expected chunk", index, "to be next, got", dh.peek().index)
+		}
+		if cd := dh.pop(); cd.index != index {
+			t.Fatal("expected chunk", index, "got", cd.index)
+		}
+	}
+	if dh.len() != 0 {
+		t.Fatal("heap should be empty, has", dh.len(), "chunks")
+	}
+}
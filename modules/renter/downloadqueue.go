@@ -1,41 +1,73 @@
 package renter
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"path/filepath"
 	"sync/atomic"
+	"time"
 
+	"github.com/pachisi456/Sia/build"
 	"github.com/pachisi456/Sia/modules"
 )
 
 // Download performs a file download using the passed parameters.
 func (r *Renter) Download(p modules.RenterDownloadParameters) error {
+	_, err := r.DownloadWithResult(p)
+	return err
+}
+
+// DownloadContext performs a file download in the same way as Download, but
+// cancels the download once ctx is done.
+func (r *Renter) DownloadContext(ctx context.Context, p modules.RenterDownloadParameters) error {
+	_, err := r.DownloadWithResultContext(ctx, p)
+	return err
+}
+
+// DownloadWithResult performs a file download using the passed parameters and
+// returns the per-chunk outcome of the download. The result is returned even
+// if the download failed, covering the chunks that were attempted.
+func (r *Renter) DownloadWithResult(p modules.RenterDownloadParameters) (modules.DownloadResult, error) {
+	return r.DownloadWithResultContext(context.Background(), p)
+}
+
+// DownloadWithResultContext performs a file download in the same way as
+// DownloadWithResult, but cancels the download once ctx is done. Connections
+// to hosts that are still being established are aborted, and the download
+// returns the context's error.
+func (r *Renter) DownloadWithResultContext(ctx context.Context, p modules.RenterDownloadParameters) (modules.DownloadResult, error) {
+	if err := r.tg.Add(); err != nil {
+		return modules.DownloadResult{}, ErrRenterShutdown
+	}
+	defer r.tg.Done()
+
 	// lookup the file associated with the nickname.
 	lockID := r.mu.RLock()
 	file, exists := r.files[p.Siapath]
 	r.mu.RUnlock(lockID)
 	if !exists {
-		return errors.New(fmt.Sprintf("no file with that path: %s", p.Siapath))
+		return modules.DownloadResult{}, errors.New(fmt.Sprintf("no file with that path: %s", p.Siapath))
 	}
 
 	isHttpResp := p.Httpwriter != nil
 
 	// validate download parameters
 	if p.Async && isHttpResp {
-		return errors.New("cannot async download to http response")
+		return modules.DownloadResult{}, errors.New("cannot async download to http response")
 	}
 	if isHttpResp && p.Destination != "" {
-		return errors.New("destination cannot be specified when downloading to http response")
+		return modules.DownloadResult{}, errors.New("destination cannot be specified when downloading to http response")
 	}
 	if !isHttpResp && p.Destination == "" {
-		return errors.New("destination not supplied")
+		return modules.DownloadResult{}, errors.New("destination not supplied")
 	}
 	if p.Destination != "" && !filepath.IsAbs(p.Destination) {
-		return errors.New("destination must be an absolute path")
+		return modules.DownloadResult{}, errors.New("destination must be an absolute path")
 	}
 	if p.Offset == file.size {
-		return errors.New("offset equals filesize")
+		return modules.DownloadResult{}, errors.New("offset equals filesize")
 	}
 	// sentinel: if length == 0, download the entire file
 	if p.Length == 0 {
@@ -43,7 +75,41 @@ func (r *Renter) Download(p modules.RenterDownloadParameters) error {
 	}
 	// Check whether offset and length is valid.
 	if p.Offset < 0 || p.Offset+p.Length > file.size {
-		return fmt.Errorf("offset and length combination invalid, max byte is at index %d", file.size-1)
+		return modules.DownloadResult{}, fmt.Errorf("offset and length combination invalid, max byte is at index %d", file.size-1)
+	}
+	switch p.IncompletePolicy {
+	case "", modules.IncompleteWait, modules.IncompleteFail:
+	case modules.IncompleteBestEffort:
+		if isHttpResp {
+			return modules.DownloadResult{}, errors.New("best-effort downloads cannot be written to an http response")
+		}
+	default:
+		return modules.DownloadResult{}, errors.New("unknown incomplete download policy")
+	}
+	switch p.Priority {
+	case "":
+		p.Priority = modules.PriorityInteractive
+	case modules.PriorityInteractive, modules.PriorityBackground:
+	default:
+		return modules.DownloadResult{}, errUnknownPriority
+	}
+
+	// Handle chunks that do not have enough pieces yet according to the
+	// download's policy.
+	file.mu.RLock()
+	missing := file.unrecoverableChunks(p.Offset, p.Length)
+	file.mu.RUnlock()
+	if len(missing) > 0 {
+		switch p.IncompletePolicy {
+		case modules.IncompleteFail:
+			return modules.DownloadResult{}, ErrUploadIncomplete
+		case modules.IncompleteBestEffort:
+		default:
+			if err := r.managedWaitForChunks(ctx, file, p.Offset, p.Length); err != nil {
+				return modules.DownloadResult{}, err
+			}
+			missing = nil
+		}
 	}
 
 	// Instantiate the correct DownloadWriter implementation
@@ -54,18 +120,29 @@ func (r *Renter) Download(p modules.RenterDownloadParameters) error {
 	} else {
 		dfw, err := NewDownloadFileWriter(p.Destination, p.Offset, p.Length)
 		if err != nil {
-			return err
+			return modules.DownloadResult{}, err
 		}
 		dw = dfw
 	}
 
 	// Create the download object and add it to the queue.
 	d := r.newSectionDownload(file, dw, p.Offset, p.Length)
+	d.priority = p.Priority
+	if len(missing) > 0 {
+		d.skipChunks(missing)
+	}
+	r.managedWatchContext(ctx, d)
 
 	lockID = r.mu.Lock()
 	r.downloadQueue = append(r.downloadQueue, d)
 	r.mu.Unlock(lockID)
-	r.newDownloads <- d
+	select {
+	case r.newDownloads <- d:
+	case <-ctx.Done():
+		return d.Result(), ctx.Err()
+	case <-r.tg.StopChan():
+		return modules.DownloadResult{}, ErrRenterShutdown
+	}
 
 	// Block until the download has completed.
 	//
@@ -73,10 +150,155 @@ func (r *Renter) Download(p modules.RenterDownloadParameters) error {
 	// error itself.
 	select {
 	case <-d.downloadFinished:
-		return d.Err()
 	case <-r.tg.StopChan():
-		return errors.New("download interrupted by shutdown")
+		return d.Result(), ErrRenterShutdown
+	}
+	if err := d.Err(); err != nil {
+		return d.Result(), err
+	}
+	// A best-effort download writes the recoverable chunks, but still reports
+	// that the file was incomplete.
+	if len(missing) > 0 {
+		return d.Result(), ErrUploadIncomplete
+	}
+	return d.Result(), nil
+}
+
+// managedWaitForChunks blocks until every chunk overlapping the byte range
+// [offset, offset+length) of a file has enough pieces to be recovered.
+// ErrUploadIncomplete is returned if the file stops being uploaded before
+// that happens, and the context's error if ctx is done first.
+func (r *Renter) managedWaitForChunks(ctx context.Context, f *file, offset, length uint64) error {
+	for {
+		f.mu.RLock()
+		missing := f.unrecoverableChunks(offset, length)
+		f.mu.RUnlock()
+		if len(missing) == 0 {
+			return nil
+		}
+
+		// Only files that are still being uploaded can gain pieces.
+		id := r.mu.RLock()
+		tf, tracked := r.tracking[f.name]
+		r.mu.RUnlock(id)
+		if !tracked || tf.Failed {
+			return ErrUploadIncomplete
+		}
+
+		select {
+		case <-time.After(downloadIncompletePollInterval):
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-r.tg.StopChan():
+			return ErrRenterShutdown
+		}
+	}
+}
+
+// DownloadToMirrors downloads a file and writes it to every destination in
+// dsts. Each chunk is fetched from the hosts only once. If a destination fails,
+// the download continues for the remaining destinations and the failure is
+// reported in the returned error.
+func (r *Renter) DownloadToMirrors(nickname string, dsts []io.Writer) error {
+	if err := r.tg.Add(); err != nil {
+		return ErrRenterShutdown
+	}
+	defer r.tg.Done()
+
+	lockID := r.mu.RLock()
+	file, exists := r.files[nickname]
+	r.mu.RUnlock(lockID)
+	if !exists {
+		return fmt.Errorf("no file with that path: %s", nickname)
+	}
+	if len(dsts) == 0 {
+		return errors.New("no destinations supplied")
+	}
+	if file.size == 0 {
+		return nil
+	}
+
+	// Create the download object and add it to the queue.
+	dw := NewDownloadMirrorWriter(dsts, 0)
+	d := r.newSectionDownload(file, dw, 0, file.size)
+
+	lockID = r.mu.Lock()
+	r.downloadQueue = append(r.downloadQueue, d)
+	r.mu.Unlock(lockID)
+	select {
+	case r.newDownloads <- d:
+	case <-r.tg.StopChan():
+		return ErrRenterShutdown
+	}
+
+	// Block until the download has completed, then report the status of each
+	// destination.
+	select {
+	case <-d.downloadFinished:
+	case <-r.tg.StopChan():
+		return ErrRenterShutdown
+	}
+	if err := d.Err(); err != nil {
+		return build.ComposeErrors(err, dw.Err())
+	}
+	return dw.Err()
+}
+
+// DownloadSection downloads 'length' bytes of a file, starting at 'offset',
+// and writes them to w in order. A length of zero downloads the remainder of
+// the file. Chunks that have not finished uploading are waited on. If w
+// returns an error, the download is aborted and that error is returned.
+func (r *Renter) DownloadSection(nickname string, w io.Writer, offset, length uint64) error {
+	if err := r.tg.Add(); err != nil {
+		return ErrRenterShutdown
+	}
+	defer r.tg.Done()
+
+	lockID := r.mu.RLock()
+	file, exists := r.files[nickname]
+	r.mu.RUnlock(lockID)
+	if !exists {
+		return fmt.Errorf("no file with that path: %s", nickname)
+	}
+	if offset > file.size {
+		return fmt.Errorf("offset exceeds filesize of %d", file.size)
+	}
+	if length == 0 {
+		length = file.size - offset
+	}
+	if offset+length > file.size {
+		return fmt.Errorf("offset and length combination invalid, max byte is at index %d", file.size-1)
+	}
+	if length == 0 {
+		return nil
+	}
+	if err := r.managedWaitForChunks(context.Background(), file, offset, length); err != nil {
+		return err
+	}
+
+	// Create the download object and add it to the queue. The mirror writer
+	// aborts the download as soon as its only destination fails.
+	dw := NewDownloadMirrorWriter([]io.Writer{w}, offset)
+	d := r.newSectionDownload(file, dw, offset, length)
+
+	lockID = r.mu.Lock()
+	r.downloadQueue = append(r.downloadQueue, d)
+	r.mu.Unlock(lockID)
+	select {
+	case r.newDownloads <- d:
+	case <-r.tg.StopChan():
+		return ErrRenterShutdown
+	}
+
+	select {
+	case <-d.downloadFinished:
+	case <-r.tg.StopChan():
+		return ErrRenterShutdown
+	}
+	if dw.errs[0] != nil {
+		return dw.errs[0]
 	}
+	return d.Err()
 }
 
 // DownloadQueue returns the list of downloads in the queue.
@@ -94,8 +316,10 @@ func (r *Renter) DownloadQueue() []modules.DownloadInfo {
 			Destination: d.destination,
 			Filesize:    d.length,
 			StartTime:   d.startTime,
+			Priority:    d.priority,
 		}
 		downloads[i].Received = atomic.LoadUint64(&d.atomicDataReceived)
+		downloads[i].Chunks = d.Result().Chunks
 
 		if err := d.Err(); err != nil {
 			downloads[i].Error = err.Error()
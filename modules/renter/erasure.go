@@ -1,13 +1,47 @@
 package renter
 
 import (
+	"errors"
 	"io"
+	"sync"
 
 	"github.com/klauspost/reedsolomon"
 
 	"github.com/pachisi456/Sia/modules"
 )
 
+// rsCodeBackend is the name of the built-in Reed-Solomon backend. It is also
+// the name under which Reed-Solomon coded files have always been persisted.
+const rsCodeBackend = "Reed-Solomon"
+
+var (
+	// ErrUnknownErasureCoder is returned when an erasure coder is requested
+	// from a backend that has not been registered.
+	ErrUnknownErasureCoder = errors.New("erasure code backend is not registered")
+
+	errBackendRegistered = errors.New("erasure code backend is already registered")
+
+	// erasureBackends holds the registered erasure code backends by name.
+	erasureBackends = map[string]ErasureCoderBackend{
+		rsCodeBackend: NewRSCode,
+	}
+	erasureBackendsMu sync.RWMutex
+)
+
+// An ErasureCoderBackend creates erasure coders that split data into nData
+// data pieces and nParity parity pieces.
+type ErasureCoderBackend func(nData, nParity int) (modules.ErasureCoder, error)
+
+// backendCode is an erasure coder created by a registered backend. It records
+// the backend and its parameters so that files using it can be persisted and
+// decoded with the same backend later.
+type backendCode struct {
+	modules.ErasureCoder
+	backend      string
+	dataPieces   int
+	parityPieces int
+}
+
 // rsCode is a Reed-Solomon encoder/decoder. It implements the
 // modules.ErasureCoder interface.
 type rsCode struct {
@@ -64,3 +98,40 @@ func NewRSCode(nData, nParity int) (modules.ErasureCoder, error) {
 		dataPieces: nData,
 	}, nil
 }
+
+// RegisterErasureCoderBackend makes an erasure code backend available under
+// name. Files record the name of the backend that encoded them, so a backend
+// must be registered under the same name before files using it are loaded.
+func RegisterErasureCoderBackend(name string, backend ErasureCoderBackend) error {
+	erasureBackendsMu.Lock()
+	defer erasureBackendsMu.Unlock()
+	if _, exists := erasureBackends[name]; exists {
+		return errBackendRegistered
+	}
+	erasureBackends[name] = backend
+	return nil
+}
+
+// NewErasureCoder creates an erasure coder using the backend registered under
+// name.
+func NewErasureCoder(name string, nData, nParity int) (modules.ErasureCoder, error) {
+	if name == rsCodeBackend {
+		return NewRSCode(nData, nParity)
+	}
+	erasureBackendsMu.RLock()
+	backend, exists := erasureBackends[name]
+	erasureBackendsMu.RUnlock()
+	if !exists {
+		return nil, ErrUnknownErasureCoder
+	}
+	ec, err := backend(nData, nParity)
+	if err != nil {
+		return nil, err
+	}
+	return &backendCode{
+		ErasureCoder: ec,
+		backend:      name,
+		dataPieces:   nData,
+		parityPieces: nParity,
+	}, nil
+}
@@ -2,9 +2,14 @@ package renter
 
 import (
 	"bytes"
+	"errors"
+	"io"
 	"io/ioutil"
+	"strings"
 	"testing"
 
+	"github.com/pachisi456/Sia/modules"
+
 	"github.com/NebulousLabs/fastrand"
 )
 
@@ -57,6 +62,99 @@ func TestRSEncode(t *testing.T) {
 	}
 }
 
+// mirrorCode is an erasure coder that stores a full copy of the data in every
+// piece.
+type mirrorCode struct {
+	numPieces int
+}
+
+func (mc mirrorCode) NumPieces() int { return mc.numPieces }
+func (mc mirrorCode) MinPieces() int { return 1 }
+func (mc mirrorCode) Encode(data []byte) ([][]byte, error) {
+	pieces := make([][]byte, mc.numPieces)
+	for i := range pieces {
+		pieces[i] = append([]byte(nil), data...)
+	}
+	return pieces, nil
+}
+func (mc mirrorCode) Recover(pieces [][]byte, n uint64, w io.Writer) error {
+	for _, piece := range pieces {
+		if piece != nil {
+			_, err := w.Write(piece[:n])
+			return err
+		}
+	}
+	return errors.New("no pieces")
+}
+
+// newMirrorCode is an ErasureCoderBackend for mirrorCodes.
+func newMirrorCode(nData, nParity int) (modules.ErasureCoder, error) {
+	if nData != 1 || nParity < 0 {
+		return nil, errors.New("mirror code requires a single data piece")
+	}
+	return mirrorCode{numPieces: nData + nParity}, nil
+}
+
+// TestErasureCoderBackend checks that a file encoded with a custom backend
+// can be persisted, loaded and decoded, and that files using an unregistered
+// backend cannot be loaded.
+func TestErasureCoderBackend(t *testing.T) {
+	if err := RegisterErasureCoderBackend("mirror", newMirrorCode); err != nil && err != errBackendRegistered {
+		t.Fatal(err)
+	}
+	if err := RegisterErasureCoderBackend(rsCodeBackend, newMirrorCode); err != errBackendRegistered {
+		t.Fatal("expected errBackendRegistered, got", err)
+	}
+	if _, err := NewErasureCoder("missing", 1, 1); err != ErrUnknownErasureCoder {
+		t.Fatal("expected ErrUnknownErasureCoder, got", err)
+	}
+
+	// Encode data with a file using the mirror backend, then persist and load
+	// the file.
+	ec, err := NewErasureCoder("mirror", 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := newFile("foo", ec, 64, 64)
+	buf := new(bytes.Buffer)
+	if err := f.MarshalSia(buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded := new(file)
+	if err := loaded.UnmarshalSia(buf); err != nil {
+		t.Fatal(err)
+	}
+	if bc, ok := loaded.erasureCode.(*backendCode); !ok || bc.backend != "mirror" {
+		t.Fatal("loaded file does not use the mirror backend:", loaded.erasureCode)
+	}
+
+	// The loaded file decodes data encoded by the original file.
+	data := fastrand.Bytes(64)
+	pieces, err := f.erasureCode.Encode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pieces[0], pieces[1] = nil, nil
+	recovered := new(bytes.Buffer)
+	if err := loaded.erasureCode.Recover(pieces, uint64(len(data)), recovered); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(recovered.Bytes(), data) {
+		t.Fatal("recovered data does not match original")
+	}
+
+	// A file that was encoded with a backend that is no longer registered
+	// cannot be loaded.
+	f.erasureCode = &backendCode{ErasureCoder: ec, backend: "missing", dataPieces: 1, parityPieces: 2}
+	buf.Reset()
+	if err := f.MarshalSia(buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := new(file).UnmarshalSia(buf); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Fatal("expected an error naming the missing backend, got", err)
+	}
+}
+
 func BenchmarkRSEncode(b *testing.B) {
 	rsc, err := NewRSCode(80, 20)
 	if err != nil {
@@ -0,0 +1,112 @@
+package renter
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pachisi456/Sia/types"
+)
+
+var errEvacuateUnknownContract = errors.New("cannot evacuate a contract that the renter does not have")
+
+// EvacuateContract moves the data stored in a contract to other hosts, so
+// that the redundancy of the renter's files survives the contract lapsing.
+// Every chunk with a piece in the contract is regenerated, either from the
+// local copy of the file or by downloading it from the network, and the
+// pieces held by the contract are uploaded to other hosts. Because the
+// download falls back to the other hosts of the chunk, contracts whose host
+// is already offline can be evacuated as well. EvacuateContract returns once
+// every chunk has been handed to the workers.
+func (r *Renter) EvacuateContract(id types.FileContractID) error {
+	if err := r.tg.Add(); err != nil {
+		return ErrRenterShutdown
+	}
+	defer r.tg.Done()
+
+	contract, exists := r.hostContractor.ResolveContract(id)
+	if !exists {
+		return errEvacuateUnknownContract
+	}
+	id = contract.ID
+
+	// Build the chunks without the contract's host, so that the pieces it
+	// stores count as missing and no replacements are uploaded to it.
+	hosts := r.managedRefreshHostsAndWorkers()
+	delete(hosts, contract.HostPublicKey.String())
+	lockID := r.mu.Lock()
+	var chunks []*unfinishedChunk
+	for _, f := range r.files {
+		stored := r.evacuationChunks(f, id)
+		if len(stored) == 0 {
+			continue
+		}
+		for _, chunk := range r.buildUnfinishedChunks(f, hosts) {
+			if _, ok := stored[chunk.index]; ok {
+				chunk.evacuation = true
+				chunks = append(chunks, chunk)
+			}
+		}
+	}
+	r.mu.Unlock(lockID)
+
+	var failed int
+	for _, chunk := range chunks {
+		if !r.managedEvacuateChunk(chunk) {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("unable to evacuate %v of %v chunks", failed, len(chunks))
+	}
+	return nil
+}
+
+// evacuationChunks returns the set of chunks of f that have a piece stored in
+// the contract with the given id.
+func (r *Renter) evacuationChunks(f *file, id types.FileContractID) map[uint64]struct{} {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	chunks := make(map[uint64]struct{})
+	for fcid, fc := range f.contracts {
+		if r.hostContractor.ResolveID(fcid) != id {
+			continue
+		}
+		for _, piece := range fc.Pieces {
+			chunks[piece.Chunk] = struct{}{}
+		}
+	}
+	return chunks
+}
+
+// managedEvacuateChunk waits until enough memory is available for the chunk,
+// and then fetches, encodes and distributes it in the same way as the repair
+// loop. The returned bool indicates whether the chunk was distributed to the
+// workers.
+func (r *Renter) managedEvacuateChunk(chunk *unfinishedChunk) bool {
+	if r.isDraining() {
+		return false
+	}
+	waitStart := time.Now()
+	memoryAvailable := r.managedMemoryAvailableGet(chunk.memoryClass())
+	for chunk.memoryNeeded > memoryAvailable {
+		select {
+		case <-r.newMemory:
+			memoryAvailable = r.managedMemoryAvailableGet(chunk.memoryClass())
+		case <-r.drainChan:
+			return false
+		case <-r.tg.StopChan():
+			return false
+		}
+	}
+	r.metrics.memoryWaitSeconds.Add(time.Since(waitStart).Seconds())
+	chunk.traceEvent("memory acquired")
+	r.managedMemoryAvailableSub(chunk.memoryNeeded, memoryEncoding, chunk.memoryClass())
+	r.heapWG.Add(1)
+	workDistributed := r.managedFetchAndRepairChunk(chunk)
+	r.heapWG.Done()
+	if !workDistributed {
+		r.managedMemoryAvailableAdd(chunk.memoryNeeded-chunk.memoryReleased, memoryEncoding, chunk.memoryClass())
+	}
+	return workDistributed
+}
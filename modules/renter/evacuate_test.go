@@ -0,0 +1,139 @@
+package renter
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pachisi456/Sia/crypto"
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/modules/renter/contractor"
+	"github.com/pachisi456/Sia/types"
+
+	"github.com/NebulousLabs/fastrand"
+)
+
+// evacuateContractor is a verifyContractor that also supports uploads, and
+// whose hosts can be taken offline.
+type evacuateContractor struct {
+	*verifyContractor
+	offline map[types.FileContractID]bool
+}
+
+func (ec evacuateContractor) ContractByID(id types.FileContractID) (modules.RenterContract, bool) {
+	for _, c := range ec.contracts {
+		if c.ID == id {
+			return c, true
+		}
+	}
+	return modules.RenterContract{}, false
+}
+func (ec evacuateContractor) ResolveContract(id types.FileContractID) (modules.RenterContract, bool) {
+	return ec.ContractByID(id)
+}
+func (ec evacuateContractor) InGracePeriod(types.FileContractID, time.Duration) bool { return false }
+func (ec evacuateContractor) Editor(id types.FileContractID, _ <-chan struct{}) (contractor.Editor, error) {
+	return ec.hosts[id], nil
+}
+func (ec evacuateContractor) Downloader(id types.FileContractID, _ <-chan struct{}) (contractor.Downloader, error) {
+	if ec.offline[id] {
+		return nil, errors.New("host is offline")
+	}
+	return ec.hosts[id], nil
+}
+
+// TestEvacuateContract checks that the pieces of a near-expiry contract whose
+// host is offline are regenerated from the other pieces and uploaded to a
+// different host.
+func TestEvacuateContract(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Create a file with two chunks, storing one piece of each chunk on
+	// each of two hosts. The file is not available locally.
+	rsc, _ := NewRSCode(1, 1)
+	f := newFile("foo", rsc, 64, 128)
+	vc := newVerifyContractor(rt.renter.hostContractor, f)
+	data := make([][]byte, f.numChunks())
+	for chunk := range data {
+		data[chunk] = fastrand.Bytes(int(f.chunkSize()))
+		vc.uploadChunk(t, f, uint64(chunk), data[chunk])
+	}
+
+	// Add a third host that does not store any pieces yet. The contract
+	// with the second host is about to expire, and the host is offline.
+	spare := modules.RenterContract{
+		ID:            types.FileContractID{byte(len(vc.contracts) + 1)},
+		HostPublicKey: types.SiaPublicKey{Key: []byte{byte(len(vc.contracts) + 1)}},
+	}
+	vc.contracts = append(vc.contracts, spare)
+	vc.hosts[spare.ID] = &mockHost{sectors: make(map[crypto.Hash][]byte)}
+	for i := range vc.contracts {
+		vc.contracts[i].GoodForUpload = true
+		vc.contracts[i].LastRevision.NewWindowStart = 1000
+	}
+	expiring := &vc.contracts[1]
+	expiring.LastRevision.NewWindowStart = 5
+	ec := evacuateContractor{
+		verifyContractor: vc,
+		offline:          map[types.FileContractID]bool{expiring.ID: true},
+	}
+
+	id := rt.renter.mu.Lock()
+	rt.renter.hostContractor = ec
+	rt.renter.files[f.name] = f
+	rt.renter.tracking[f.name] = trackedFile{RepairPath: "/does/not/exist"}
+	rt.renter.mu.Unlock(id)
+
+	if err := rt.renter.EvacuateContract(types.FileContractID{0xff}); err != errEvacuateUnknownContract {
+		t.Fatal("expected errEvacuateUnknownContract, got", err)
+	}
+	if err := rt.renter.EvacuateContract(expiring.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Wait for the spare host to receive a piece of every chunk.
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		f.mu.RLock()
+		uploaded := len(f.contracts[spare.ID].Pieces)
+		f.mu.RUnlock()
+		if uploaded == int(f.numChunks()) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("spare host received", uploaded, "pieces, expected", f.numChunks())
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// The spare host stores the pieces that were held by the expiring
+	// contract.
+	f.mu.RLock()
+	pieces := f.contracts[spare.ID].Pieces
+	f.mu.RUnlock()
+	for _, piece := range pieces {
+		if piece.Piece != 1 {
+			t.Fatal("spare host received the wrong piece:", piece.Piece)
+		}
+		sector, err := vc.hosts[spare.ID].Sector(piece.MerkleRoot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decrypted, err := deriveKey(f.masterKey, piece.Chunk, piece.Piece).DecryptBytes(sector)
+		if err != nil {
+			t.Fatal(err)
+		}
+		encoded, _ := rsc.Encode(data[piece.Chunk])
+		if !bytes.Equal(decrypted, encoded[piece.Piece]) {
+			t.Fatal("spare host stores the wrong data for chunk", piece.Chunk)
+		}
+	}
+}
@@ -1,11 +1,14 @@
 package renter
 
 import (
+	"bytes"
 	"errors"
+	"io"
 	"math"
 	"os"
-	"path/filepath"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/pachisi456/Sia/build"
 	"github.com/pachisi456/Sia/crypto"
@@ -18,6 +21,18 @@ var (
 	ErrEmptyFilename = errors.New("filename must be a nonempty string")
 	ErrPathOverload  = errors.New("a file already exists at that location")
 	ErrUnknownPath   = errors.New("no file known with that path")
+
+	// ErrUploadIncomplete is returned when a download requests chunks of a
+	// file that do not have enough pieces uploaded to be recovered.
+	ErrUploadIncomplete = errors.New("file has not finished uploading")
+
+	// ErrStorageCapExceeded is returned when an upload would exceed the
+	// storage caps set in the renter settings.
+	ErrStorageCapExceeded = errors.New("upload would exceed the renter's storage cap")
+
+	errFileAlreadyTracked = errors.New("file is already being tracked")
+	errFileNotTracked     = errors.New("file is not being tracked")
+	errLocalFileMismatch  = errors.New("local file does not match the renter's file")
 )
 
 // A file is a single file that has been uploaded to the network. Files are
@@ -34,17 +49,30 @@ type file struct {
 	pieceSize   uint64               // Static - can be accessed without lock.
 	mode        uint32               // actually an os.FileMode
 
+	// modTime and tags are the modification time of the source file and the
+	// user tags supplied when the file was uploaded. They are returned by
+	// FileList so that backup tools can restore the file's attributes. Both
+	// are static once the file has been created.
+	modTime time.Time
+	tags    map[string]string
+
 	mu sync.RWMutex
 }
 
 // A fileContract is a contract covering an arbitrary number of file pieces.
 // Chunk/Piece metadata is used to split the raw contract data appropriately.
+//
+// HostPublicKey identifies the host storing the pieces, so that a renter that
+// did not form the contract can still find the pieces. It is empty for
+// contracts of files saved before version 0.5 of the .sia format.
 type fileContract struct {
 	ID     types.FileContractID
 	IP     modules.NetAddress
 	Pieces []pieceData
 
 	WindowStart types.BlockHeight
+
+	HostPublicKey types.SiaPublicKey
 }
 
 // pieceData contains the metadata necessary to request a piece from a
@@ -58,6 +86,12 @@ type pieceData struct {
 	MerkleRoot crypto.Hash // the Merkle root of the piece
 }
 
+// fileTag is a user tag of a file as it is stored in .sia files.
+type fileTag struct {
+	Key   string
+	Value string
+}
+
 // deriveKey derives the key used to encrypt and decrypt a specific file piece.
 func deriveKey(masterKey crypto.TwofishKey, chunkIndex, pieceIndex uint64) crypto.TwofishKey {
 	return crypto.TwofishKey(crypto.HashAll(masterKey, chunkIndex, pieceIndex))
@@ -101,14 +135,48 @@ func (f *file) available(isOffline func(types.FileContractID) bool) bool {
 	return true
 }
 
-// uploadProgress indicates what percentage of the file (plus redundancy) has
-// been uploaded. Note that a file may be Available long before UploadProgress
-// reaches 100%, and UploadProgress may report a value greater than 100%.
-func (f *file) uploadProgress() float64 {
+// unrecoverableChunks returns the indices of the chunks overlapping the byte
+// range [offset, offset+length) that do not have enough pieces uploaded to be
+// recovered.
+func (f *file) unrecoverableChunks(offset, length uint64) []uint64 {
+	minChunk := offset / f.chunkSize()
+	maxChunk := (offset + length - 1) / f.chunkSize()
+	chunkPieces := make(map[uint64]map[uint64]struct{})
+	for _, fc := range f.contracts {
+		for _, p := range fc.Pieces {
+			if p.Chunk < minChunk || p.Chunk > maxChunk {
+				continue
+			}
+			if chunkPieces[p.Chunk] == nil {
+				chunkPieces[p.Chunk] = make(map[uint64]struct{})
+			}
+			chunkPieces[p.Chunk][p.Piece] = struct{}{}
+		}
+	}
+	var missing []uint64
+	for i := minChunk; i <= maxChunk; i++ {
+		if len(chunkPieces[i]) < f.erasureCode.MinPieces() {
+			missing = append(missing, i)
+		}
+	}
+	return missing
+}
+
+// uploadedBytes returns the total size of the pieces of the file that are
+// stored on hosts.
+func (f *file) uploadedBytes() uint64 {
 	var uploaded uint64
 	for _, fc := range f.contracts {
 		uploaded += uint64(len(fc.Pieces)) * f.pieceSize
 	}
+	return uploaded
+}
+
+// uploadProgress indicates what percentage of the file (plus redundancy) has
+// been uploaded. Note that a file may be Available long before UploadProgress
+// reaches 100%, and UploadProgress may report a value greater than 100%.
+func (f *file) uploadProgress() float64 {
+	uploaded := f.uploadedBytes()
 	desired := f.pieceSize * uint64(f.erasureCode.NumPieces()) * f.numChunks()
 
 	return math.Min(100*(float64(uploaded)/float64(desired)), 100)
@@ -122,7 +190,7 @@ func (f *file) redundancy(isOffline func(types.FileContractID) bool) float64 {
 	if f.size == 0 {
 		return -1
 	}
-	piecesPerChunk := make([]int, f.numChunks())
+	piecesPerChunk := f.piecesPerChunk(isOffline)
 	// If the file has non-0 size then the number of chunks should also be
 	// non-0. Therefore the f.size == 0 conditional block above must appear
 	// before this check.
@@ -130,6 +198,19 @@ func (f *file) redundancy(isOffline func(types.FileContractID) bool) float64 {
 		build.Critical("cannot get redundancy of a file with 0 chunks")
 		return -1
 	}
+	minPieces := piecesPerChunk[0]
+	for _, numPieces := range piecesPerChunk {
+		if numPieces < minPieces {
+			minPieces = numPieces
+		}
+	}
+	return float64(minPieces) / float64(f.erasureCode.MinPieces())
+}
+
+// piecesPerChunk returns the number of pieces of each chunk that are stored
+// on contracts that are not offline.
+func (f *file) piecesPerChunk(isOffline func(types.FileContractID) bool) []int {
+	piecesPerChunk := make([]int, f.numChunks())
 	for _, fc := range f.contracts {
 		// do not count pieces from the contract if the contract is offline
 		if isOffline(fc.ID) {
@@ -139,13 +220,7 @@ func (f *file) redundancy(isOffline func(types.FileContractID) bool) float64 {
 			piecesPerChunk[p.Chunk]++
 		}
 	}
-	minPieces := piecesPerChunk[0]
-	for _, numPieces := range piecesPerChunk {
-		if numPieces < minPieces {
-			minPieces = numPieces
-		}
-	}
-	return float64(minPieces) / float64(f.erasureCode.MinPieces())
+	return piecesPerChunk
 }
 
 // expiration returns the lowest height at which any of the file's contracts
@@ -175,12 +250,29 @@ func newFile(name string, code modules.ErasureCoder, pieceSize, fileSize uint64)
 	}
 }
 
+// isOffline reports whether the contract with the provided id should be
+// considered offline when determining the health of a file.
+func (r *Renter) isOffline(id types.FileContractID) bool {
+	id = r.hostContractor.ResolveID(id)
+	offline := r.hostContractor.IsOffline(id)
+	contract, exists := r.hostContractor.ContractByID(id)
+	if !exists {
+		return true
+	}
+	return offline || !contract.GoodForRenew
+}
+
 // DeleteFile removes a file entry from the renter and deletes its data from
 // the hosts it is stored on.
 //
 // TODO: The data is not cleared from any contracts where the host is not
 // immediately online.
 func (r *Renter) DeleteFile(nickname string) error {
+	if err := r.tg.Add(); err != nil {
+		return ErrRenterShutdown
+	}
+	defer r.tg.Done()
+
 	lockID := r.mu.Lock()
 	f, exists := r.files[nickname]
 	if !exists {
@@ -189,21 +281,97 @@ func (r *Renter) DeleteFile(nickname string) error {
 	}
 	delete(r.files, nickname)
 	delete(r.tracking, nickname)
+	delete(r.repairCheckpoints, nickname)
+	r.releaseStorage(storedBytes(f))
 
-	err := persist.RemoveFile(filepath.Join(r.persistDir, f.name+ShareExtension))
+	err := persist.RemoveFile(r.siaFilePath(f.name))
 	if err != nil {
 		r.log.Println("WARN: couldn't remove file :", err)
 	}
 
+	roots := r.unreferencedSectors(f)
 	r.saveSync()
 	r.mu.Unlock(lockID)
 
-	// delete the file's associated contract data.
-	f.mu.Lock()
-	defer f.mu.Unlock()
+	// Delete the file's sectors from the hosts so that they stop counting
+	// against the allowance with the next revision. The file is already gone
+	// from the renter, so failures are only logged.
+	var wg sync.WaitGroup
+	for id, contractRoots := range roots {
+		wg.Add(1)
+		go func(id types.FileContractID, contractRoots []crypto.Hash) {
+			defer wg.Done()
+			if err := r.managedDeleteSectors(id, contractRoots); err != nil {
+				r.log.Printf("WARN: unable to delete sectors of %v from contract %v: %v", nickname, id, err)
+			}
+		}(id, contractRoots)
+	}
+	wg.Wait()
+	return nil
+}
 
-	// TODO: delete the sectors of the file as well.
+// unreferencedSectors returns the Merkle roots of the pieces of 'f', grouped
+// by contract, that are not also used by another file of the renter. Pieces
+// can be shared between files when the host already stored an identical
+// sector, and deleting those would damage the other file.
+func (r *Renter) unreferencedSectors(f *file) map[types.FileContractID][]crypto.Hash {
+	referenced := make(map[types.FileContractID]map[crypto.Hash]struct{})
+	for _, other := range r.files {
+		if other == f {
+			continue
+		}
+		other.mu.RLock()
+		for _, fc := range other.contracts {
+			id := r.hostContractor.ResolveID(fc.ID)
+			if referenced[id] == nil {
+				referenced[id] = make(map[crypto.Hash]struct{})
+			}
+			for _, p := range fc.Pieces {
+				referenced[id][p.MerkleRoot] = struct{}{}
+			}
+		}
+		other.mu.RUnlock()
+	}
 
+	roots := make(map[types.FileContractID][]crypto.Hash)
+	type sector struct {
+		id   types.FileContractID
+		root crypto.Hash
+	}
+	seen := make(map[sector]struct{})
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for _, fc := range f.contracts {
+		id := r.hostContractor.ResolveID(fc.ID)
+		for _, p := range fc.Pieces {
+			if _, ok := referenced[id][p.MerkleRoot]; ok {
+				continue
+			} else if _, ok := seen[sector{id, p.MerkleRoot}]; ok {
+				continue
+			}
+			seen[sector{id, p.MerkleRoot}] = struct{}{}
+			roots[id] = append(roots[id], p.MerkleRoot)
+		}
+	}
+	return roots
+}
+
+// managedDeleteSectors deletes the sectors with the given Merkle roots from
+// the host of the given contract.
+func (r *Renter) managedDeleteSectors(id types.FileContractID, roots []crypto.Hash) error {
+	if len(roots) == 0 {
+		return nil
+	}
+	editor, err := r.hostContractor.Editor(id, r.tg.StopChan())
+	if err != nil {
+		return err
+	}
+	defer editor.Close()
+	for _, root := range roots {
+		if err := editor.Delete(root); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -216,50 +384,274 @@ func (r *Renter) FileList() []modules.FileInfo {
 	}
 	r.mu.RUnlock(lockID)
 
-	isOffline := func(id types.FileContractID) bool {
-		id = r.hostContractor.ResolveID(id)
-		offline := r.hostContractor.IsOffline(id)
-		contract, exists := r.hostContractor.ContractByID(id)
-		if !exists {
-			return true
-		}
-		return offline || !contract.GoodForRenew
+	var fileList []modules.FileInfo
+	for _, f := range files {
+		f.mu.RLock()
+		fileList = append(fileList, r.fileInfo(f))
+		f.mu.RUnlock()
 	}
+	return fileList
+}
 
-	var fileList []modules.FileInfo
+// fileInfo returns the FileInfo of f. The file's lock must be held.
+func (r *Renter) fileInfo(f *file) modules.FileInfo {
+	renewing := true
+	var localPath string
+	tf, exists := r.tracking[f.name]
+	if exists {
+		localPath = tf.RepairPath
+	}
+	return modules.FileInfo{
+		SiaPath:        f.name,
+		LocalPath:      localPath,
+		Filesize:       f.size,
+		Renewing:       renewing,
+		Available:      f.available(r.isOffline),
+		Redundancy:     f.redundancy(r.isOffline),
+		UploadProgress: f.uploadProgress(),
+		Expiration:     f.expiration(),
+		Failed:         tf.Failed,
+		Paused:         tf.Paused,
+		StuckChunks:    tf.stuckChunks(),
+		ModTime:        f.modTime,
+		Mode:           os.FileMode(f.mode),
+		Tags:           f.tags,
+	}
+}
+
+// RedundancyStatus returns the target and the achieved redundancy of every
+// file, sorted by siapath. The achieved redundancy only counts pieces stored
+// on online hosts, in the same way as FileList.
+func (r *Renter) RedundancyStatus() []modules.FileRedundancy {
+	var files []*file
+	lockID := r.mu.RLock()
+	for _, f := range r.files {
+		files = append(files, f)
+	}
+	r.mu.RUnlock(lockID)
+
+	status := make([]modules.FileRedundancy, 0, len(files))
 	for _, f := range files {
 		f.mu.RLock()
-		renewing := true
-		var localPath string
-		tf, exists := r.tracking[f.name]
-		if exists {
-			localPath = tf.RepairPath
-		}
-		fileList = append(fileList, modules.FileInfo{
-			SiaPath:        f.name,
-			LocalPath:      localPath,
-			Filesize:       f.size,
-			Renewing:       renewing,
-			Available:      f.available(isOffline),
-			Redundancy:     f.redundancy(isOffline),
-			UploadProgress: f.uploadProgress(),
-			Expiration:     f.expiration(),
+		status = append(status, modules.FileRedundancy{
+			SiaPath:          f.name,
+			TargetRedundancy: float64(f.erasureCode.NumPieces()) / float64(f.erasureCode.MinPieces()),
+			Redundancy:       f.redundancy(r.isOffline),
 		})
 		f.mu.RUnlock()
 	}
-	return fileList
+	sort.Slice(status, func(i, j int) bool {
+		return status[i].SiaPath < status[j].SiaPath
+	})
+	return status
+}
+
+// ListFiles returns the nickname of every file that the renter has, along with
+// whether the file is being tracked. Tracked files are actively repaired by the
+// renter, while untracked files (such as files loaded from a .sia file) are
+// not.
+func (r *Renter) ListFiles() []modules.TrackedFileInfo {
+	lockID := r.mu.RLock()
+	defer r.mu.RUnlock(lockID)
+
+	files := make([]modules.TrackedFileInfo, 0, len(r.files))
+	for nickname := range r.files {
+		_, tracked := r.tracking[nickname]
+		files = append(files, modules.TrackedFileInfo{
+			SiaPath: nickname,
+			Tracked: tracked,
+		})
+	}
+	return files
+}
+
+// TrackFile starts tracking a file that is known to the renter but is not yet
+// being tracked, such as a file that was loaded from a .sia file. Once tracked,
+// the file will be repaired using the data found at repairPath. The data at
+// repairPath must match the file: the size must be equal, and if the file is
+// available on the network, the data of the first chunk is downloaded and
+// compared against the local data.
+func (r *Renter) TrackFile(nickname, repairPath string) error {
+	if err := r.tg.Add(); err != nil {
+		return ErrRenterShutdown
+	}
+	defer r.tg.Done()
+
+	lockID := r.mu.RLock()
+	f, exists := r.files[nickname]
+	_, tracked := r.tracking[nickname]
+	r.mu.RUnlock(lockID)
+	if !exists {
+		return ErrUnknownPath
+	}
+	if tracked {
+		return errFileAlreadyTracked
+	}
+
+	// Check that the local file matches the renter's file.
+	if err := validateSource(repairPath); err != nil {
+		return err
+	}
+	if err := r.managedVerifyLocalFile(f, repairPath); err != nil {
+		return err
+	}
+
+	// Add the file to the tracking set. The file may have been deleted or
+	// renamed while its data was being verified.
+	lockID = r.mu.Lock()
+	if r.files[nickname] != f {
+		r.mu.Unlock(lockID)
+		return ErrUnknownPath
+	}
+	r.tracking[nickname] = trackedFile{
+		RepairPath: repairPath,
+	}
+	err := r.saveSync()
+	r.mu.Unlock(lockID)
+	if err != nil {
+		return err
+	}
+
+	// Send the file to the repair loop.
+	select {
+	case r.newUploads <- f:
+	case <-r.tg.StopChan():
+	}
+	return nil
+}
+
+// PauseUpload stops the renter from uploading and repairing a tracked file
+// until ResumeUpload is called. Pieces that are already being uploaded are
+// finished. The pause is persisted.
+func (r *Renter) PauseUpload(nickname string) error {
+	if err := r.tg.Add(); err != nil {
+		return ErrRenterShutdown
+	}
+	defer r.tg.Done()
+	_, err := r.managedSetPaused(nickname, true)
+	return err
+}
+
+// ResumeUpload resumes uploading and repairing a file that was paused with
+// PauseUpload.
+func (r *Renter) ResumeUpload(nickname string) error {
+	if err := r.tg.Add(); err != nil {
+		return ErrRenterShutdown
+	}
+	defer r.tg.Done()
+	f, err := r.managedSetPaused(nickname, false)
+	if err != nil {
+		return err
+	}
+
+	// Send the file to the repair loop.
+	select {
+	case r.newUploads <- f:
+	case <-r.tg.StopChan():
+	}
+	return nil
+}
+
+// managedSetPaused sets whether a tracked file is paused and returns the file.
+func (r *Renter) managedSetPaused(nickname string, paused bool) (*file, error) {
+	lockID := r.mu.Lock()
+	defer r.mu.Unlock(lockID)
+	f, exists := r.files[nickname]
+	if !exists {
+		return nil, ErrUnknownPath
+	}
+	tf, tracked := r.tracking[nickname]
+	if !tracked {
+		return nil, errFileNotTracked
+	}
+	tf.Paused = paused
+	r.tracking[nickname] = tf
+	return f, r.saveSync()
+}
+
+// managedUploadPaused reports whether uploads of f are paused.
+func (r *Renter) managedUploadPaused(f *file) bool {
+	lockID := r.mu.RLock()
+	defer r.mu.RUnlock(lockID)
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return r.tracking[f.name].Paused
+}
+
+// managedVerifyLocalFile checks that the data at localPath matches the file.
+// The sizes of the files are always compared. If the file is available on the
+// network, the first chunk is also downloaded and compared to the local data.
+func (r *Renter) managedVerifyLocalFile(f *file, localPath string) error {
+	fileInfo, err := os.Stat(localPath)
+	if err != nil {
+		return err
+	}
+	if uint64(fileInfo.Size()) != f.size {
+		return errLocalFileMismatch
+	}
+	f.mu.RLock()
+	available := f.available(r.isOffline)
+	f.mu.RUnlock()
+	if f.size == 0 || !available {
+		return nil
+	}
+
+	// Download the first chunk of the file.
+	length := f.chunkSize()
+	if length > f.size {
+		length = f.size
+	}
+	buf := NewDownloadBufferWriter(length, 0)
+	d := r.newSectionDownload(f, buf, 0, length)
+	select {
+	case r.newDownloads <- d:
+	case <-r.tg.StopChan():
+		return ErrRenterShutdown
+	}
+	select {
+	case <-d.downloadFinished:
+	case <-r.tg.StopChan():
+		return ErrRenterShutdown
+	}
+	if err := d.Err(); err != nil {
+		return err
+	}
+
+	// Compare the downloaded data to the local data.
+	localFile, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer localFile.Close()
+	localData := make([]byte, length)
+	if _, err := io.ReadFull(localFile, localData); err != nil {
+		return err
+	}
+	if !bytes.Equal(localData, buf.Bytes()) {
+		return errLocalFileMismatch
+	}
+	return nil
 }
 
 // RenameFile takes an existing file and changes the nickname. The original
 // file must exist, and there must not be any file that already has the
-// replacement nickname.
+// replacement nickname. The rename is atomic: if it fails, the file keeps its
+// original nickname, both in memory and on disk.
 func (r *Renter) RenameFile(currentName, newName string) error {
+	if err := r.tg.Add(); err != nil {
+		return ErrRenterShutdown
+	}
+	defer r.tg.Done()
+
 	lockID := r.mu.Lock()
 	defer r.mu.Unlock(lockID)
 
-	// Check that newName is nonempty.
-	if newName == "" {
-		return ErrEmptyFilename
+	// Check that newName is valid and not reserved.
+	if err := validateSiapath(newName); err != nil {
+		return err
+	}
+	if isReservedSiapath(newName) {
+		return errReservedSiapath
 	}
 
 	// Check that currentName exists and newName doesn't.
@@ -267,33 +659,68 @@ func (r *Renter) RenameFile(currentName, newName string) error {
 	if !exists {
 		return ErrUnknownPath
 	}
-	_, exists = r.files[newName]
-	if exists {
+	if r.siapathInUse(newName) {
 		return ErrPathOverload
 	}
 
-	// Modify the file and save it to disk.
-	file.mu.Lock()
-	file.name = newName
-	err := r.saveFile(file)
-	file.mu.Unlock()
+	if err := r.renameFile(file, currentName, newName); err != nil {
+		return err
+	}
+	err := r.saveSync()
 	if err != nil {
+		r.undoRenameFile(file, currentName, newName)
 		return err
 	}
 
-	// Update the entries in the renter.
-	delete(r.files, currentName)
-	r.files[newName] = file
-	if t, ok := r.tracking[currentName]; ok {
-		delete(r.tracking, currentName)
-		r.tracking[newName] = t
+	// Delete the old .sia file.
+	oldPath := r.siaFilePath(currentName)
+	return os.RemoveAll(oldPath)
+}
+
+// renameFile changes the siapath of f, saves it under the new siapath, and
+// updates the entries of the renter. The old .sia file is left on disk. If
+// the file can't be saved, nothing is changed. The renter's lock must be held.
+func (r *Renter) renameFile(f *file, currentName, newName string) error {
+	f.mu.Lock()
+	f.name = newName
+	err := r.saveFile(f)
+	if err != nil {
+		f.name = currentName
 	}
-	err = r.saveSync()
+	f.mu.Unlock()
 	if err != nil {
 		return err
 	}
+	r.moveFileEntries(currentName, newName)
+	return nil
+}
 
-	// Delete the old .sia file.
-	oldPath := filepath.Join(r.persistDir, currentName+ShareExtension)
-	return os.RemoveAll(oldPath)
+// undoRenameFile reverts a successful call to renameFile whose result could
+// not be persisted, deleting the new .sia file. The renter's lock must be
+// held.
+func (r *Renter) undoRenameFile(f *file, currentName, newName string) {
+	f.mu.Lock()
+	f.name = currentName
+	f.mu.Unlock()
+	r.moveFileEntries(newName, currentName)
+	if err := os.RemoveAll(r.siaFilePath(newName)); err != nil {
+		r.log.Println("WARN: couldn't remove the .sia file of a failed rename:", err)
+	}
+}
+
+// moveFileEntries moves the entries of a file in the renter's maps from one
+// siapath to another. The renter's lock must be held.
+func (r *Renter) moveFileEntries(currentName, newName string) {
+	if f, ok := r.files[currentName]; ok {
+		delete(r.files, currentName)
+		r.files[newName] = f
+	}
+	if t, ok := r.tracking[currentName]; ok {
+		delete(r.tracking, currentName)
+		r.tracking[newName] = t
+	}
+	if cp, ok := r.repairCheckpoints[currentName]; ok {
+		delete(r.repairCheckpoints, currentName)
+		r.repairCheckpoints[newName] = cp
+	}
 }
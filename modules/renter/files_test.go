@@ -1,12 +1,17 @@
 package renter
 
 import (
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/pachisi456/Sia/build"
+	"github.com/pachisi456/Sia/crypto"
 	"github.com/pachisi456/Sia/modules"
 	"github.com/pachisi456/Sia/types"
+
+	"github.com/NebulousLabs/fastrand"
 )
 
 // TestFileNumChunks checks the numChunks method of the file type.
@@ -326,6 +331,76 @@ func TestRenterDeleteFile(t *testing.T) {
 	}
 }
 
+// TestRenterDeleteFileSectors checks that deleting a file deletes its sectors
+// from the hosts, except for sectors that are shared with another file.
+func TestRenterDeleteFileSectors(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	sc := streamContractor{&dedupContractor{
+		hostContractor: rt.renter.hostContractor,
+		contract:       modules.RenterContract{ID: types.FileContractID{1}},
+		host:           &mockHost{sectors: make(map[crypto.Hash][]byte)},
+	}}
+	id := rt.renter.mu.Lock()
+	rt.renter.hostContractor = sc
+	rt.renter.mu.Unlock(id)
+
+	// Store three sectors on the host. The first belongs to "foo", the
+	// second to both files and the third to "bar".
+	var roots []crypto.Hash
+	for i := 0; i < 3; i++ {
+		root, err := sc.host.Upload(fastrand.Bytes(64))
+		if err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, root)
+	}
+	addFile := func(name string, roots ...crypto.Hash) {
+		f := newTestingFile()
+		f.name = name
+		fc := fileContract{ID: sc.contract.ID}
+		for i, root := range roots {
+			fc.Pieces = append(fc.Pieces, pieceData{Chunk: uint64(i), MerkleRoot: root})
+		}
+		f.contracts = map[types.FileContractID]fileContract{fc.ID: fc}
+		id := rt.renter.mu.Lock()
+		rt.renter.files[name] = f
+		rt.renter.mu.Unlock(id)
+	}
+	addFile("foo", roots[0], roots[1])
+	addFile("bar", roots[1], roots[2])
+
+	if err := rt.renter.DeleteFile("foo"); err != nil {
+		t.Fatal(err)
+	}
+	sc.host.mu.Lock()
+	_, deleted := sc.host.sectors[roots[0]]
+	_, shared := sc.host.sectors[roots[1]]
+	_, other := sc.host.sectors[roots[2]]
+	sc.host.mu.Unlock()
+	if deleted || !shared || !other {
+		t.Fatal("expected only the sector used by no other file to be deleted:", deleted, shared, other)
+	}
+
+	// Once the last file using them is deleted, all sectors are gone.
+	if err := rt.renter.DeleteFile("bar"); err != nil {
+		t.Fatal(err)
+	}
+	sc.host.mu.Lock()
+	numSectors := len(sc.host.sectors)
+	sc.host.mu.Unlock()
+	if numSectors != 0 {
+		t.Fatal("expected all sectors to be deleted, got", numSectors)
+	}
+}
+
 // TestRenterFileList probes the FileList method of the renter type.
 func TestRenterFileList(t *testing.T) {
 	if testing.Short() {
@@ -422,7 +497,7 @@ func TestRenterRenameFile(t *testing.T) {
 	}
 
 	// Renaming should also update the tracking set
-	rt.renter.tracking["1"] = trackedFile{"foo"}
+	rt.renter.tracking["1"] = trackedFile{RepairPath: "foo"}
 	err = rt.renter.RenameFile("1", "1b")
 	if err != nil {
 		t.Fatal(err)
@@ -433,3 +508,357 @@ func TestRenterRenameFile(t *testing.T) {
 		t.Error("renaming should have updated the entry in the tracking set")
 	}
 }
+
+// TestRenterRenameFileAtomic checks that a failed rename leaves the file
+// untouched, and that a successful rename is persisted along with the
+// tracking metadata.
+func TestRenterRenameFileAtomic(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	f := newTestingFile()
+	f.name = "1"
+	id := rt.renter.mu.Lock()
+	rt.renter.files["1"] = f
+	rt.renter.tracking["1"] = trackedFile{RepairPath: "foo"}
+	err = rt.renter.saveFile(f)
+	rt.renter.mu.Unlock(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Invalid names are rejected.
+	for _, name := range []string{"", "/1", "../1", "a/./1"} {
+		if err := rt.renter.RenameFile("1", name); err == nil {
+			t.Errorf("expected renaming to %q to fail", name)
+		}
+	}
+
+	// Make saving the file under the new name fail by putting a regular file
+	// where its folder would have to be created.
+	err = ioutil.WriteFile(filepath.Join(rt.renter.persistDir, "blocked"), nil, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.renter.RenameFile("1", "blocked/1"); err == nil {
+		t.Fatal("expected the rename to fail")
+	}
+	id = rt.renter.mu.Lock()
+	_, oldExists := rt.renter.files["1"]
+	_, newExists := rt.renter.files["blocked/1"]
+	_, tracked := rt.renter.tracking["1"]
+	rt.renter.mu.Unlock(id)
+	if f.name != "1" || !oldExists || newExists || !tracked {
+		t.Fatal("failed rename changed the file")
+	}
+
+	// Rename the file and check that the new name is loaded from disk.
+	if err := rt.renter.RenameFile("1", "2"); err != nil {
+		t.Fatal(err)
+	}
+	id = rt.renter.mu.Lock()
+	rt.renter.files = make(map[string]*file)
+	rt.renter.tracking = make(map[string]trackedFile)
+	err = rt.renter.load()
+	_, oldExists = rt.renter.files["1"]
+	_, newExists = rt.renter.files["2"]
+	tf, tracked := rt.renter.tracking["2"]
+	rt.renter.mu.Unlock(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if oldExists || !newExists || !tracked || tf.RepairPath != "foo" {
+		t.Fatal("rename was not persisted")
+	}
+}
+
+// TestRenterListFilesTracked checks that ListFiles reports uploaded files as
+// tracked and files loaded from a .sia file as untracked.
+func TestRenterListFilesTracked(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Upload a file.
+	source := build.TempDir("renter", t.Name(), "source")
+	err = ioutil.WriteFile(source, fastrand.Bytes(100), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rt.renter.Upload(modules.FileUploadParams{
+		Source:  source,
+		SiaPath: "uploaded",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Share the uploaded file and load it back into the renter. The loaded
+	// copy does not conflict with the original, and is not tracked.
+	shared := build.TempDir("renter", t.Name(), "shared.sia")
+	err = rt.renter.ShareFiles([]string{"uploaded"}, shared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, err := rt.renter.LoadSharedFiles(shared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[0] == "uploaded" {
+		t.Fatal("shared file was not loaded under a new name:", names)
+	}
+
+	files := rt.renter.ListFiles()
+	if len(files) != 2 {
+		t.Fatal("expected 2 files, got", len(files))
+	}
+	for _, f := range files {
+		switch f.SiaPath {
+		case "uploaded":
+			if !f.Tracked {
+				t.Error("uploaded file should be tracked")
+			}
+		case names[0]:
+			if f.Tracked {
+				t.Error("loaded file should not be tracked")
+			}
+		default:
+			t.Error("unexpected file:", f.SiaPath)
+		}
+	}
+}
+
+// TestRenterTrackFile checks that a file loaded from a .sia file can be
+// converted to a tracked file, after which it participates in repair.
+func TestRenterTrackFile(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Upload a file, then share it and load it back under a new name.
+	source := build.TempDir("renter", t.Name(), "source")
+	err = ioutil.WriteFile(source, fastrand.Bytes(100), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rt.renter.Upload(modules.FileUploadParams{
+		Source:  source,
+		SiaPath: "uploaded",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared := build.TempDir("renter", t.Name(), "shared.sia")
+	err = rt.renter.ShareFiles([]string{"uploaded"}, shared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, err := rt.renter.LoadSharedFiles(shared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 {
+		t.Fatal("expected 1 loaded file, got", names)
+	}
+	loaded := names[0]
+
+	// The loaded file is not tracked, and should not be repaired.
+	id := rt.renter.mu.Lock()
+	f := rt.renter.files[loaded]
+	chunks := rt.renter.buildUnfinishedChunks(f, make(map[string]struct{}))
+	rt.renter.mu.Unlock(id)
+	if len(chunks) != 0 {
+		t.Fatal("untracked file should not be repaired")
+	}
+
+	// Tracking files that don't exist or don't match should fail.
+	if err := rt.renter.TrackFile("nonexistent", source); err != ErrUnknownPath {
+		t.Fatal("expected ErrUnknownPath, got", err)
+	}
+	if err := rt.renter.TrackFile("uploaded", source); err != errFileAlreadyTracked {
+		t.Fatal("expected errFileAlreadyTracked, got", err)
+	}
+	mismatched := build.TempDir("renter", t.Name(), "mismatched")
+	err = ioutil.WriteFile(mismatched, fastrand.Bytes(50), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.renter.TrackFile(loaded, mismatched); err != errLocalFileMismatch {
+		t.Fatal("expected errLocalFileMismatch, got", err)
+	}
+
+	// Track the loaded file using the original source.
+	if err := rt.renter.TrackFile(loaded, source); err != nil {
+		t.Fatal(err)
+	}
+	for _, fi := range rt.renter.ListFiles() {
+		if fi.SiaPath == loaded && !fi.Tracked {
+			t.Fatal("file should be tracked after calling TrackFile")
+		}
+	}
+
+	// The file should now participate in repair.
+	id = rt.renter.mu.Lock()
+	chunks = rt.renter.buildUnfinishedChunks(f, make(map[string]struct{}))
+	rt.renter.mu.Unlock(id)
+	if len(chunks) == 0 {
+		t.Fatal("tracked file should be repaired")
+	}
+}
+
+// redundancyContractor is a hostContractor with a fixed set of contracts,
+// some of which may be offline.
+type redundancyContractor struct {
+	hostContractor
+	contracts map[types.FileContractID]modules.RenterContract
+	offline   map[types.FileContractID]bool
+}
+
+func (rc redundancyContractor) ResolveID(id types.FileContractID) types.FileContractID { return id }
+func (rc redundancyContractor) IsOffline(id types.FileContractID) bool                 { return rc.offline[id] }
+func (rc redundancyContractor) ContractByID(id types.FileContractID) (modules.RenterContract, bool) {
+	c, ok := rc.contracts[id]
+	return c, ok
+}
+
+// TestRenterRedundancyStatus checks that a degraded file reports less than its
+// target redundancy, and that a healthy file reaches its target.
+func TestRenterRedundancyStatus(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Create three contracts, of which the third is offline.
+	rc := redundancyContractor{
+		hostContractor: rt.renter.hostContractor,
+		contracts:      make(map[types.FileContractID]modules.RenterContract),
+		offline:        map[types.FileContractID]bool{{3}: true},
+	}
+	for i := byte(1); i <= 3; i++ {
+		rc.contracts[types.FileContractID{i}] = modules.RenterContract{
+			ID:           types.FileContractID{i},
+			GoodForRenew: true,
+		}
+	}
+
+	// Store both pieces of the healthy file on online hosts, and one piece of
+	// the degraded file on the offline host.
+	rsc, _ := NewRSCode(1, 1)
+	newStoredFile := func(name string, ids ...types.FileContractID) *file {
+		f := newFile(name, rsc, 64, 64)
+		for piece, id := range ids {
+			f.contracts[id] = fileContract{
+				ID:     id,
+				Pieces: []pieceData{{Chunk: 0, Piece: uint64(piece)}},
+			}
+		}
+		return f
+	}
+	healthy := newStoredFile("healthy", types.FileContractID{1}, types.FileContractID{2})
+	degraded := newStoredFile("degraded", types.FileContractID{1}, types.FileContractID{3})
+	id := rt.renter.mu.Lock()
+	rt.renter.hostContractor = rc
+	rt.renter.files[healthy.name] = healthy
+	rt.renter.files[degraded.name] = degraded
+	rt.renter.mu.Unlock(id)
+
+	status := rt.renter.RedundancyStatus()
+	if len(status) != 2 || status[0].SiaPath != degraded.name || status[1].SiaPath != healthy.name {
+		t.Fatal("expected the status of both files sorted by siapath, got", status)
+	}
+	for _, fr := range status {
+		if fr.TargetRedundancy != 2 {
+			t.Fatal("expected a target redundancy of 2, got", fr)
+		}
+	}
+	if status[0].Redundancy >= status[0].TargetRedundancy {
+		t.Fatal("degraded file reached its target redundancy:", status[0])
+	}
+	if status[1].Redundancy < status[1].TargetRedundancy {
+		t.Fatal("healthy file did not reach its target redundancy:", status[1])
+	}
+}
+
+// TestRenterPauseUpload checks that paused files are not scheduled for
+// repair, and that the pause is persisted.
+func TestRenterPauseUpload(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Track a file that needs repairs, and add a file that is not tracked.
+	rsc, _ := NewRSCode(1, 1)
+	f := newFile("foo", rsc, modules.SectorSize, modules.SectorSize)
+	g := newFile("bar", rsc, modules.SectorSize, modules.SectorSize)
+	id := rt.renter.mu.Lock()
+	rt.renter.files[f.name] = f
+	rt.renter.files[g.name] = g
+	rt.renter.tracking[f.name] = trackedFile{RepairPath: "/does/not/exist"}
+	rt.renter.mu.Unlock(id)
+
+	if err := rt.renter.PauseUpload(f.name); err != nil {
+		t.Fatal(err)
+	}
+	if ch := rt.renter.managedBuildChunkHeap(nil); ch.Len() != 0 {
+		t.Fatal("paused file was scheduled for repair")
+	}
+	for _, fi := range rt.renter.FileList() {
+		if fi.Paused != (fi.SiaPath == f.name) {
+			t.Fatal("unexpected pause state:", fi)
+		}
+	}
+
+	// The pause should survive a reload.
+	id = rt.renter.mu.Lock()
+	rt.renter.tracking = make(map[string]trackedFile)
+	err = rt.renter.load()
+	paused := rt.renter.tracking[f.name].Paused
+	rt.renter.mu.Unlock(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !paused {
+		t.Fatal("pause was not persisted")
+	}
+
+	if err := rt.renter.ResumeUpload(f.name); err != nil {
+		t.Fatal(err)
+	}
+	if ch := rt.renter.managedBuildChunkHeap(nil); ch.Len() == 0 {
+		t.Fatal("resumed file was not scheduled for repair")
+	}
+
+	// Only tracked files can be paused.
+	if err := rt.renter.PauseUpload(g.name); err != errFileNotTracked {
+		t.Fatal("expected errFileNotTracked, got", err)
+	}
+	if err := rt.renter.PauseUpload("baz"); err != ErrUnknownPath {
+		t.Fatal("expected ErrUnknownPath, got", err)
+	}
+}
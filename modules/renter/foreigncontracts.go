@@ -0,0 +1,91 @@
+package renter
+
+import (
+	"github.com/pachisi456/Sia/modules"
+)
+
+// recordHostKeys fills in the host public keys of the contracts of f that were
+// saved before the .sia format stored them, so that they are included when f
+// is exported. The file's lock must be held for writing.
+func (r *Renter) recordHostKeys(f *file) {
+	for id, fc := range f.contracts {
+		if len(fc.HostPublicKey.Key) != 0 {
+			continue
+		}
+		if contract, exists := r.hostContractor.ResolveContract(id); exists {
+			fc.HostPublicKey = contract.HostPublicKey
+			f.contracts[id] = fc
+		}
+	}
+}
+
+// contractsByHost returns the given contracts, keyed by the String()
+// representation of their host's public key.
+func contractsByHost(contracts []modules.RenterContract) map[string]modules.RenterContract {
+	byHost := make(map[string]modules.RenterContract, len(contracts))
+	for _, contract := range contracts {
+		byHost[contract.HostPublicKey.String()] = contract
+	}
+	return byHost
+}
+
+// adoptForeignContracts moves the pieces of f that are stored in contracts the
+// renter doesn't know, e.g. because f was exported by another renter, to the
+// renter's own contract with the same host. Hosts serve sectors by their
+// Merkle root, so the pieces can be downloaded through any contract with the
+// host. Foreign contracts that have expired are left alone, as the host is no
+// longer obliged to store their pieces. adoptForeignContracts reports whether
+// f was changed. The renter's lock and the file's lock must be held for
+// writing.
+func (r *Renter) adoptForeignContracts(f *file, contracts map[string]modules.RenterContract) bool {
+	changed := false
+	for id, fc := range f.contracts {
+		if len(fc.HostPublicKey.Key) == 0 || fc.WindowStart <= r.blockHeight {
+			continue
+		}
+		if _, known := r.hostContractor.ResolveContract(id); known {
+			continue
+		}
+		contract, exists := contracts[fc.HostPublicKey.String()]
+		if !exists {
+			continue
+		}
+
+		// The pieces are only guaranteed to be stored until the foreign
+		// contract expires.
+		own, exists := f.contracts[contract.ID]
+		if !exists {
+			own = fileContract{
+				ID:            contract.ID,
+				IP:            contract.NetAddress,
+				WindowStart:   contract.EndHeight(),
+				HostPublicKey: contract.HostPublicKey,
+			}
+		}
+		if fc.WindowStart < own.WindowStart {
+			own.WindowStart = fc.WindowStart
+		}
+		own.Pieces = append(own.Pieces, fc.Pieces...)
+		f.contracts[contract.ID] = own
+		delete(f.contracts, id)
+		changed = true
+	}
+	return changed
+}
+
+// managedAdoptForeignContracts adopts the foreign contracts of every file
+// whose hosts the renter has a contract with, see adoptForeignContracts.
+func (r *Renter) managedAdoptForeignContracts(contracts []modules.RenterContract) {
+	byHost := contractsByHost(contracts)
+	id := r.mu.Lock()
+	defer r.mu.Unlock(id)
+	for _, f := range r.files {
+		f.mu.Lock()
+		if r.adoptForeignContracts(f, byHost) {
+			if err := r.saveFile(f); err != nil {
+				r.log.Println("WARN: could not save file after adopting its contracts:", err)
+			}
+		}
+		f.mu.Unlock()
+	}
+}
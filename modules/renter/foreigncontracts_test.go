@@ -0,0 +1,118 @@
+package renter
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pachisi456/Sia/crypto"
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/types"
+)
+
+// foreignContractor is a hostContractor that knows a fixed set of contracts.
+type foreignContractor struct {
+	hostContractor
+	contracts map[types.FileContractID]modules.RenterContract
+}
+
+func (fc foreignContractor) ResolveContract(id types.FileContractID) (modules.RenterContract, bool) {
+	contract, exists := fc.contracts[id]
+	return contract, exists
+}
+
+// TestShareFilesHostKeys checks that the host public keys of a file's
+// contracts survive sharing, and that .sia files without host keys can still
+// be loaded.
+func TestShareFilesHostKeys(t *testing.T) {
+	f := newTestingFile()
+	f.contracts = map[types.FileContractID]fileContract{
+		{1}: {
+			ID:            types.FileContractID{1},
+			Pieces:        []pieceData{{Chunk: 0, Piece: 1, MerkleRoot: crypto.Hash{2}}},
+			WindowStart:   3,
+			HostPublicKey: types.SiaPublicKey{Key: []byte("foo")},
+		},
+	}
+	buf := new(bytes.Buffer)
+	if err := shareFiles([]*file{f}, buf); err != nil {
+		t.Fatal(err)
+	}
+	files, err := decodeSharedFiles(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatal("expected 1 file, got", len(files))
+	}
+	if err := equalFiles(f, files[0]); err != nil {
+		t.Fatal(err)
+	}
+	fc := files[0].contracts[types.FileContractID{1}]
+	if string(fc.HostPublicKey.Key) != "foo" || len(fc.Pieces) != 1 || fc.Pieces[0] != f.contracts[fc.ID].Pieces[0] || fc.WindowStart != 3 {
+		t.Fatal("contract was not shared correctly:", fc)
+	}
+
+	// Load a .sia file of version 0.4, which doesn't store host keys.
+	file, err := os.Open(filepath.Join("..", "..", "compatibility", "siafile_v0.4.8.sia"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	files, err = decodeSharedFiles(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].name != "testfile-183" {
+		t.Fatal("compatibility file was not loaded correctly")
+	}
+}
+
+// TestAdoptForeignContracts checks that the pieces of foreign contracts are
+// moved to the renter's contracts with the same hosts.
+func TestAdoptForeignContracts(t *testing.T) {
+	own := modules.RenterContract{
+		ID:            types.FileContractID{1},
+		HostPublicKey: types.SiaPublicKey{Key: []byte("foo")},
+	}
+	own.LastRevision.NewWindowStart = 100
+	r := &Renter{
+		hostContractor: foreignContractor{contracts: map[types.FileContractID]modules.RenterContract{own.ID: own}},
+		blockHeight:    10,
+	}
+
+	piece := func(i uint64) []pieceData {
+		return []pieceData{{Chunk: 0, Piece: i, MerkleRoot: crypto.Hash{byte(i)}}}
+	}
+	f := newTestingFile()
+	f.contracts = map[types.FileContractID]fileContract{
+		// A contract of the renter, which is left alone.
+		{1}: {ID: types.FileContractID{1}, Pieces: piece(1), WindowStart: 100, HostPublicKey: own.HostPublicKey},
+		// A foreign contract with the same host, which is adopted.
+		{2}: {ID: types.FileContractID{2}, Pieces: piece(2), WindowStart: 50, HostPublicKey: own.HostPublicKey},
+		// A foreign contract that has expired.
+		{3}: {ID: types.FileContractID{3}, Pieces: piece(3), WindowStart: 10, HostPublicKey: own.HostPublicKey},
+		// A foreign contract with a host that the renter has no contract with.
+		{4}: {ID: types.FileContractID{4}, Pieces: piece(4), WindowStart: 50, HostPublicKey: types.SiaPublicKey{Key: []byte("bar")}},
+		// A foreign contract without a host key.
+		{5}: {ID: types.FileContractID{5}, Pieces: piece(5), WindowStart: 50},
+	}
+
+	if !r.adoptForeignContracts(f, contractsByHost([]modules.RenterContract{own})) {
+		t.Fatal("expected the file to change")
+	}
+	if len(f.contracts) != 4 {
+		t.Fatal("expected 4 contracts, got", len(f.contracts))
+	}
+	if _, exists := f.contracts[types.FileContractID{2}]; exists {
+		t.Fatal("foreign contract was not adopted")
+	}
+	fc := f.contracts[own.ID]
+	if len(fc.Pieces) != 2 || fc.Pieces[1].Piece != 2 || fc.WindowStart != 50 {
+		t.Fatal("pieces were not moved correctly:", fc)
+	}
+	if r.adoptForeignContracts(f, contractsByHost([]modules.RenterContract{own})) {
+		t.Fatal("expected nothing left to adopt")
+	}
+}
@@ -0,0 +1,77 @@
+package renter
+
+import (
+	"time"
+
+	"github.com/pachisi456/Sia/modules"
+)
+
+// Health summarizes the redundancy of the renter's files, so that monitoring
+// systems can alert before files become unrecoverable. Like RedundancyStatus,
+// only pieces stored on online hosts are counted. Empty files count as fully
+// redundant.
+func (r *Renter) Health() modules.RenterHealth {
+	lockID := r.mu.RLock()
+	files := make([]*file, 0, len(r.files))
+	tracking := make(map[string]trackedFile, len(r.tracking))
+	stuck := make(map[string]uint64)
+	for name, f := range r.files {
+		files = append(files, f)
+		if tf, exists := r.tracking[name]; exists {
+			tracking[name] = tf
+			stuck[name] = tf.stuckChunks()
+		}
+	}
+	r.mu.RUnlock(lockID)
+
+	var health modules.RenterHealth
+	for _, f := range files {
+		f.mu.RLock()
+		if f.size == 0 {
+			f.mu.RUnlock()
+			health.FullRedundancyFiles++
+			continue
+		}
+		minPieces, numPieces := f.erasureCode.MinPieces(), f.erasureCode.NumPieces()
+		piecesPerChunk := f.piecesPerChunk(r.isOffline)
+		name := f.name
+		f.mu.RUnlock()
+
+		lowest, incomplete := numPieces, uint64(0)
+		for _, pieces := range piecesPerChunk {
+			if pieces < lowest {
+				lowest = pieces
+			}
+			if pieces < numPieces {
+				incomplete++
+			}
+		}
+		switch {
+		case lowest < minPieces:
+			health.UnrecoverableFiles++
+		case lowest < numPieces:
+			health.DegradedFiles++
+		default:
+			health.FullRedundancyFiles++
+		}
+
+		// The incomplete chunks of files that the renter gave up on are
+		// stuck as well, as they are not repaired anymore. Other incomplete
+		// files are waiting for repair, unless they are paused.
+		tf, tracked := tracking[name]
+		if !tracked || incomplete == 0 {
+			continue
+		}
+		if tf.Failed {
+			health.StuckChunks += incomplete
+			continue
+		}
+		health.StuckChunks += stuck[name]
+		if !tf.Paused && !tf.FirstIncomplete.IsZero() {
+			if age := time.Since(tf.FirstIncomplete); age > health.OldestRepairAge {
+				health.OldestRepairAge = age
+			}
+		}
+	}
+	return health
+}
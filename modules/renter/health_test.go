@@ -0,0 +1,75 @@
+package renter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/types"
+)
+
+// TestRenterHealth checks that files are counted by their redundancy, and that
+// stuck chunks and the age of the oldest pending repair are reported.
+func TestRenterHealth(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Create three contracts, of which the third is offline.
+	rc := redundancyContractor{
+		hostContractor: rt.renter.hostContractor,
+		contracts:      make(map[types.FileContractID]modules.RenterContract),
+		offline:        map[types.FileContractID]bool{{3}: true},
+	}
+	for i := byte(1); i <= 3; i++ {
+		rc.contracts[types.FileContractID{i}] = modules.RenterContract{
+			ID:           types.FileContractID{i},
+			GoodForRenew: true,
+		}
+	}
+
+	rsc, _ := NewRSCode(1, 1)
+	newStoredFile := func(name string, ids ...types.FileContractID) *file {
+		f := newFile(name, rsc, 64, 64)
+		for piece, id := range ids {
+			f.contracts[id] = fileContract{
+				ID:     id,
+				Pieces: []pieceData{{Chunk: 0, Piece: uint64(piece)}},
+			}
+		}
+		return f
+	}
+	files := []*file{
+		newStoredFile("healthy", types.FileContractID{1}, types.FileContractID{2}),
+		newStoredFile("degraded", types.FileContractID{1}, types.FileContractID{3}),
+		newStoredFile("failed", types.FileContractID{2}, types.FileContractID{3}),
+		newStoredFile("unrecoverable", types.FileContractID{3}),
+		newFile("empty", rsc, 64, 0),
+	}
+	id := rt.renter.mu.Lock()
+	rt.renter.hostContractor = rc
+	for _, f := range files {
+		rt.renter.files[f.name] = f
+	}
+	rt.renter.tracking["degraded"] = trackedFile{RepairAttempts: 1, FirstIncomplete: time.Now().Add(-time.Hour)}
+	rt.renter.tracking["failed"] = trackedFile{RepairAttempts: 5, FirstIncomplete: time.Now().Add(-48 * time.Hour), Failed: true}
+	rt.renter.mu.Unlock(id)
+
+	health := rt.renter.Health()
+	if health.FullRedundancyFiles != 2 || health.DegradedFiles != 2 || health.UnrecoverableFiles != 1 {
+		t.Fatal("wrong file counts:", health)
+	}
+	if health.StuckChunks != 1 {
+		t.Fatal("expected 1 stuck chunk, got", health.StuckChunks)
+	}
+	// The failed file is not waiting for repair anymore, so the oldest
+	// pending repair is the one of the degraded file.
+	if health.OldestRepairAge < time.Hour || health.OldestRepairAge > 2*time.Hour {
+		t.Fatal("wrong oldest repair age:", health.OldestRepairAge)
+	}
+}
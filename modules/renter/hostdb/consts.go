@@ -67,14 +67,6 @@ var (
 )
 
 var (
-	// defaultScanSleep is the amount of time that the hostdb will sleep if it
-	// cannot successfully get a random number.
-	defaultScanSleep = build.Select(build.Var{
-		Standard: time.Hour + time.Minute*37,
-		Dev:      time.Minute * 5,
-		Testing:  time.Second * 3,
-	}).(time.Duration)
-
 	// maxScanSleep is the maximum amount of time that the hostdb will sleep
 	// between performing scans of the hosts.
 	maxScanSleep = build.Select(build.Var{
@@ -90,4 +82,29 @@ var (
 		Dev:      time.Minute * 3,
 		Testing:  time.Second * 1,
 	}).(time.Duration)
+
+	// maxOfflineScanInterval caps the exponential backoff between the scans of
+	// a host that keeps failing its scans.
+	maxOfflineScanInterval = build.Select(build.Var{
+		Standard: time.Hour * 48,
+		Dev:      time.Minute * 30,
+		Testing:  time.Second * 20,
+	}).(time.Duration)
+
+	// priorityScanInterval is the interval at which the hosts that the renter
+	// has contracts with are scanned. It is also how often the scan loop
+	// checks for hosts that are due for a scan.
+	priorityScanInterval = build.Select(build.Var{
+		Standard: time.Minute * 20,
+		Dev:      time.Minute,
+		Testing:  time.Second / 2,
+	}).(time.Duration)
+
+	// scanSuccessHalfLife is the age at which a scan counts half as much as a
+	// fresh scan when computing the rate of successful scans of a host.
+	scanSuccessHalfLife = build.Select(build.Var{
+		Standard: time.Hour * 72,
+		Dev:      time.Hour,
+		Testing:  time.Minute,
+	}).(time.Duration)
 )
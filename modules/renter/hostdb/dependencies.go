@@ -2,8 +2,10 @@ package hostdb
 
 import (
 	"net"
+	"strings"
 	"time"
 
+	"github.com/pachisi456/Sia/crypto"
 	"github.com/pachisi456/Sia/modules"
 	"github.com/pachisi456/Sia/persist"
 )
@@ -37,3 +39,25 @@ func (prodDependencies) saveFileSync(meta persist.Metadata, data interface{}, fi
 }
 
 func (prodDependencies) sleep(d time.Duration) { time.Sleep(d) }
+
+// encryptedDependencies are the production dependencies of a HostDB that
+// encrypts its persist data with key. The encrypted data is saved next to the
+// plaintext file, with the extension ".enc" instead of ".json".
+type encryptedDependencies struct {
+	prodDependencies
+	key crypto.TwofishKey
+}
+
+// encryptedFilename returns the name of the encrypted counterpart of the
+// plaintext persist file filename.
+func encryptedFilename(filename string) string {
+	return strings.TrimSuffix(filename, ".json") + ".enc"
+}
+
+func (d encryptedDependencies) loadFile(meta persist.Metadata, data interface{}, filename string) error {
+	return persist.LoadEncryptedJSON(meta, data, filename, encryptedFilename(filename), &d.key)
+}
+
+func (d encryptedDependencies) saveFileSync(meta persist.Metadata, data interface{}, filename string) error {
+	return persist.SaveEncryptedJSON(meta, data, filename, encryptedFilename(filename), &d.key)
+}
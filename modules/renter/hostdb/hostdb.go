@@ -10,8 +10,11 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/pachisi456/Sia/build"
+	"github.com/pachisi456/Sia/crypto"
+	"github.com/pachisi456/Sia/metrics"
 	"github.com/pachisi456/Sia/modules"
 	"github.com/pachisi456/Sia/modules/renter/hostdb/hosttree"
 	"github.com/pachisi456/Sia/persist"
@@ -22,6 +25,8 @@ import (
 var (
 	errNilCS      = errors.New("cannot create hostdb with nil consensus set")
 	errNilGateway = errors.New("cannot create hostdb with nil gateway")
+
+	errInvalidMinVersion = errors.New("minimum host version is not a valid version")
 )
 
 // The HostDB is a database of potential hosts. It assigns a weight to each
@@ -52,8 +57,19 @@ type HostDB struct {
 	online          bool
 	scanningThreads int
 
+	// priorityHosts are the hosts that the renter has contracts with. They are
+	// scanned every priorityScanInterval. burstScan is set while the scan of
+	// all hosts that is performed at startup is in progress.
+	priorityHosts map[string]types.SiaPublicKey
+	burstScan     bool
+
 	blockHeight types.BlockHeight
 	lastChange  modules.ConsensusChangeID
+
+	// metrics and metricsRegistry contain the metrics exported by the hostdb,
+	// see Metrics.
+	metrics         hostdbMetrics
+	metricsRegistry *metrics.Registry
 }
 
 // New returns a new HostDB.
@@ -69,6 +85,20 @@ func New(g modules.Gateway, cs modules.ConsensusSet, persistDir string) (*HostDB
 	return newHostDB(g, cs, persistDir, prodDependencies{})
 }
 
+// NewWithMetadataKey returns a new HostDB that encrypts its persist data with
+// key. Plaintext persist data, e.g. of a HostDB that was previously started
+// without a key, is encrypted by the next save.
+func NewWithMetadataKey(g modules.Gateway, cs modules.ConsensusSet, persistDir string, key crypto.TwofishKey) (*HostDB, error) {
+	// Check for nil inputs.
+	if g == nil {
+		return nil, errNilGateway
+	}
+	if cs == nil {
+		return nil, errNilCS
+	}
+	return newHostDB(g, cs, persistDir, encryptedDependencies{key: key})
+}
+
 // newHostDB creates a HostDB using the provided dependencies. It loads the old
 // persistence data, spawns the HostDB's scanning threads, and subscribes it to
 // the consensusSet.
@@ -80,8 +110,12 @@ func newHostDB(g modules.Gateway, cs modules.ConsensusSet, persistDir string, de
 		gateway:    g,
 		persistDir: persistDir,
 
-		scanMap: make(map[string]struct{}),
+		priorityHosts: make(map[string]types.SiaPublicKey),
+		scanMap:       make(map[string]struct{}),
+
+		metricsRegistry: metrics.NewRegistry(),
 	}
+	hdb.metrics = newHostDBMetrics(hdb.metricsRegistry)
 
 	// Create the persist directory if it does not yet exist.
 	err := os.MkdirAll(persistDir, 0700)
@@ -198,6 +232,49 @@ func (hdb *HostDB) ActiveHosts() (activeHosts []modules.HostDBEntry) {
 	return activeHosts
 }
 
+// AddHosts inserts the provided entries into the hostdb, for example to seed
+// the hostdb of a private network without waiting for host announcements. The
+// entries are validated first, and no entry is inserted if any of them is
+// invalid. Hosts that already exist have their NetAddress updated. Every
+// added host is queued for a scan; hosts whose entries already include a
+// successful scan and that accept contracts can be selected right away.
+func (hdb *HostDB) AddHosts(entries []modules.HostDBEntry) error {
+	if err := hdb.tg.Add(); err != nil {
+		return err
+	}
+	defer hdb.tg.Done()
+
+	for _, entry := range entries {
+		if entry.PublicKey.Algorithm != types.SignatureEd25519 || len(entry.PublicKey.Key) != crypto.PublicKeySize {
+			return fmt.Errorf("host %v has an invalid public key", entry.PublicKey.String())
+		}
+		if err := entry.NetAddress.IsValid(); err != nil {
+			return fmt.Errorf("host %v has an invalid NetAddress: %v", entry.PublicKey.String(), err)
+		}
+	}
+
+	hdb.mu.Lock()
+	defer hdb.mu.Unlock()
+	for _, entry := range entries {
+		oldEntry, exists := hdb.hostTree.Select(entry.PublicKey)
+		if exists {
+			oldEntry.NetAddress = entry.NetAddress
+			if err := hdb.hostTree.Modify(oldEntry); err != nil {
+				return err
+			}
+		} else {
+			if entry.FirstSeen == 0 {
+				entry.FirstSeen = hdb.blockHeight
+			}
+			if err := hdb.hostTree.Insert(entry); err != nil {
+				return err
+			}
+		}
+		hdb.queueScan(entry)
+	}
+	return hdb.saveSync()
+}
+
 // AllHosts returns all of the hosts known to the hostdb, including the
 // inactive ones.
 func (hdb *HostDB) AllHosts() (allHosts []modules.HostDBEntry) {
@@ -235,9 +312,98 @@ func (hdb *HostDB) Host(spk types.SiaPublicKey) (modules.HostDBEntry, bool) {
 	return host, exists
 }
 
+// scannedSuccessfully returns whether any scan of the host in its scan
+// history succeeded, i.e. whether its settings are known.
+func scannedSuccessfully(entry modules.HostDBEntry) bool {
+	for _, scan := range entry.ScanHistory {
+		if scan.Success {
+			return true
+		}
+	}
+	return false
+}
+
+// QueryHosts returns the hosts that match all criteria of the query, sorted by
+// weight, highest first. Hosts that have never been scanned successfully have
+// no known settings and never match a query that restricts the settings.
+func (hdb *HostDB) QueryHosts(q modules.HostDBQuery) ([]modules.HostDBEntry, error) {
+	if q.MinVersion != "" && !build.IsVersion(q.MinVersion) {
+		return nil, errInvalidMinVersion
+	}
+	restrictsSettings := q.AcceptingContracts || !q.MaxStoragePrice.IsZero() ||
+		q.MinRemainingStorage > 0 || q.MinVersion != ""
+
+	allHosts := hdb.hostTree.All()
+	hosts := make([]modules.HostDBEntry, 0, len(allHosts))
+	for i := len(allHosts) - 1; i >= 0; i-- {
+		if q.Limit > 0 && len(hosts) >= q.Limit {
+			break
+		}
+		host := allHosts[i]
+		if restrictsSettings && !scannedSuccessfully(host) {
+			continue
+		}
+		if q.AcceptingContracts && !host.AcceptingContracts {
+			continue
+		}
+		if !q.MaxStoragePrice.IsZero() && host.StoragePrice.Cmp(q.MaxStoragePrice) > 0 {
+			continue
+		}
+		if host.RemainingStorage < q.MinRemainingStorage {
+			continue
+		}
+		if q.MinVersion != "" && (!build.IsVersion(host.Version) || build.VersionCmp(host.Version, q.MinVersion) < 0) {
+			continue
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts, nil
+}
+
 // RandomHosts implements the HostDB interface's RandomHosts() method. It takes
 // a number of hosts to return, and a slice of netaddresses to ignore, and
 // returns a slice of entries.
 func (hdb *HostDB) RandomHosts(n int, excludeKeys []types.SiaPublicKey) []modules.HostDBEntry {
 	return hdb.hostTree.SelectRandom(n, excludeKeys)
 }
+
+// RandomDiverseHosts works like RandomHosts, but returns at most one host per
+// /24 (IPv4) or /64 (IPv6) subnet, and no host that shares a subnet with one
+// of the occupied hosts. This prevents a single operator running many hosts
+// from being selected many times.
+func (hdb *HostDB) RandomDiverseHosts(n int, excludeKeys, occupiedKeys []types.SiaPublicKey) []modules.HostDBEntry {
+	return hdb.hostTree.SelectRandomDiverse(n, excludeKeys, occupiedKeys)
+}
+
+// ScanStatus returns the state of the hostdb's scan queue.
+func (hdb *HostDB) ScanStatus() modules.HostDBScanStatus {
+	hdb.mu.RLock()
+	defer hdb.mu.RUnlock()
+	status := modules.HostDBScanStatus{
+		Queued:        len(hdb.scanList),
+		Scanning:      hdb.scanningThreads,
+		BurstScan:     hdb.burstScan,
+		PriorityHosts: len(hdb.priorityHosts),
+	}
+	now := time.Now()
+	for _, host := range hdb.hostTree.All() {
+		if _, priority := hdb.priorityHosts[host.PublicKey.String()]; priority {
+			continue
+		}
+		if consecutiveScanFailures(host) >= 2 && !hdb.scanDue(host, now) {
+			status.BackedOffHosts++
+		}
+	}
+	return status
+}
+
+// SetPriorityHosts sets the hosts that the renter has contracts with, which
+// are scanned more often than the other hosts.
+func (hdb *HostDB) SetPriorityHosts(keys []types.SiaPublicKey) {
+	hdb.mu.Lock()
+	defer hdb.mu.Unlock()
+	hdb.priorityHosts = make(map[string]types.SiaPublicKey, len(keys))
+	for _, key := range keys {
+		hdb.priorityHosts[key.String()] = key
+	}
+}
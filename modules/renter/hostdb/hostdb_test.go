@@ -5,6 +5,7 @@ import (
 	"math"
 	"os"
 	"path/filepath"
+	"strconv"
 	"testing"
 	"time"
 
@@ -556,3 +557,147 @@ func TestUpdateHistoricInteractions(t *testing.T) {
 			host.HistoricFailedInteractions, host.HistoricSuccessfulInteractions)
 	}
 }
+
+// TestAddHosts tests that hosts added through AddHosts appear in the hostdb
+// and can be selected, and that invalid entries are rejected.
+func TestAddHosts(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	hdbt, err := newHDBTesterDeps(t.Name(), disableScanLoopDeps{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Invalid entries are rejected, and no host of the batch is added.
+	valid := makeHostDBEntry()
+	valid.NetAddress = "127.0.0.1:9981"
+	badKey := makeHostDBEntry()
+	badKey.NetAddress = "127.0.0.1:9982"
+	badKey.PublicKey.Key = badKey.PublicKey.Key[:16]
+	badAddr := makeHostDBEntry()
+	badAddr.NetAddress = "not an address"
+	for _, entries := range [][]modules.HostDBEntry{{valid, badKey}, {valid, badAddr}} {
+		if err := hdbt.hdb.AddHosts(entries); err == nil {
+			t.Fatal("expected an invalid entry to be rejected")
+		}
+	}
+	if len(hdbt.hdb.AllHosts()) != 0 {
+		t.Fatal("hosts were added despite an invalid entry")
+	}
+
+	// Add a batch of valid hosts.
+	entries := make(map[string]modules.HostDBEntry)
+	var batch []modules.HostDBEntry
+	for i := 0; i < 5; i++ {
+		entry := makeHostDBEntry()
+		entry.NetAddress = modules.NetAddress("127.0.0.1:" + strconv.Itoa(10000+i))
+		entries[entry.PublicKey.String()] = entry
+		batch = append(batch, entry)
+	}
+	if err := hdbt.hdb.AddHosts(batch); err != nil {
+		t.Fatal(err)
+	}
+
+	// The hosts should be known to the hostdb and selectable.
+	all := hdbt.hdb.AllHosts()
+	if len(all) != len(entries) {
+		t.Fatalf("expected %v hosts, got %v", len(entries), len(all))
+	}
+	for _, host := range all {
+		if _, exists := entries[host.PublicKey.String()]; !exists {
+			t.Fatal("hostdb contains a host that was not added")
+		}
+	}
+	random := hdbt.hdb.RandomHosts(len(entries), nil)
+	if len(random) != len(entries) {
+		t.Fatalf("expected %v selectable hosts, got %v", len(entries), len(random))
+	}
+
+	// Adding a known host again updates its NetAddress.
+	updated := batch[0]
+	updated.NetAddress = "127.0.0.1:20000"
+	if err := hdbt.hdb.AddHosts([]modules.HostDBEntry{updated}); err != nil {
+		t.Fatal(err)
+	}
+	host, exists := hdbt.hdb.Host(updated.PublicKey)
+	if !exists || host.NetAddress != updated.NetAddress {
+		t.Fatal("NetAddress of the host was not updated:", host.NetAddress)
+	}
+	if len(hdbt.hdb.AllHosts()) != len(entries) {
+		t.Fatal("re-adding a host created a duplicate entry")
+	}
+}
+
+// TestQueryHosts checks that QueryHosts returns the hosts matching every
+// criterion of the query, highest weight first.
+func TestQueryHosts(t *testing.T) {
+	hdb := bareHostDB()
+
+	cheap := makeHostDBEntry()
+	cheap.StoragePrice = types.NewCurrency64(10)
+	cheap.RemainingStorage = 1e12
+	cheap.Version = "1.3.1"
+	expensive := makeHostDBEntry()
+	expensive.StoragePrice = types.NewCurrency64(1000)
+	expensive.RemainingStorage = 1e12
+	expensive.Version = "1.3.1"
+	full := makeHostDBEntry()
+	full.StoragePrice = types.NewCurrency64(10)
+	full.RemainingStorage = 1e6
+	full.Version = "1.3.1"
+	old := makeHostDBEntry()
+	old.StoragePrice = types.NewCurrency64(10)
+	old.RemainingStorage = 1e12
+	old.Version = "1.2.0"
+	closed := makeHostDBEntry()
+	closed.AcceptingContracts = false
+	unscanned := makeHostDBEntry()
+	unscanned.ScanHistory = nil
+	for _, entry := range []modules.HostDBEntry{cheap, expensive, full, old, closed, unscanned} {
+		if err := hdb.hostTree.Insert(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	query := func(q modules.HostDBQuery) []modules.HostDBEntry {
+		hosts, err := hdb.QueryHosts(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hosts
+	}
+
+	// An empty query returns all hosts, sorted by weight.
+	hosts := query(modules.HostDBQuery{})
+	if len(hosts) != 6 {
+		t.Fatal("expected all hosts, got", len(hosts))
+	}
+	for i := 1; i < len(hosts); i++ {
+		if hdb.calculateHostWeight(hosts[i-1]).Cmp(hdb.calculateHostWeight(hosts[i])) < 0 {
+			t.Fatal("hosts are not sorted by weight")
+		}
+	}
+	if hosts := query(modules.HostDBQuery{Limit: 2}); len(hosts) != 2 {
+		t.Fatal("limit was not applied:", len(hosts))
+	}
+
+	// Each criterion filters out the corresponding host. The unscanned host
+	// never matches a query on settings.
+	hosts = query(modules.HostDBQuery{
+		AcceptingContracts:  true,
+		MaxStoragePrice:     types.NewCurrency64(100),
+		MinRemainingStorage: 1e9,
+		MinVersion:          "1.3.0",
+	})
+	if len(hosts) != 1 || hosts[0].PublicKey.String() != cheap.PublicKey.String() {
+		t.Fatal("expected only the cheap host, got", hosts)
+	}
+	if hosts := query(modules.HostDBQuery{AcceptingContracts: true}); len(hosts) != 4 {
+		t.Fatal("expected 4 hosts accepting contracts, got", len(hosts))
+	}
+
+	if _, err := hdb.QueryHosts(modules.HostDBQuery{MinVersion: "one"}); err != errInvalidMinVersion {
+		t.Fatal("expected errInvalidMinVersion, got", err)
+	}
+}
@@ -2,6 +2,7 @@ package hosttree
 
 import (
 	"errors"
+	"net"
 	"sort"
 	"sync"
 
@@ -280,12 +281,35 @@ func (ht *HostTree) Select(spk types.SiaPublicKey) (modules.HostDBEntry, bool) {
 // The hosts that are returned first have the higher priority. Hosts passed to
 // 'ignore' will not be considered; pass `nil` if no blacklist is desired.
 func (ht *HostTree) SelectRandom(n int, ignore []types.SiaPublicKey) []modules.HostDBEntry {
+	return ht.selectRandom(n, ignore, nil, false)
+}
+
+// SelectRandomDiverse works like SelectRandom, but returns at most one host
+// per subnet, and no host that shares a subnet with one of the occupied
+// hosts. Hosts whose subnet is unknown are not restricted.
+func (ht *HostTree) SelectRandomDiverse(n int, ignore, occupied []types.SiaPublicKey) []modules.HostDBEntry {
+	return ht.selectRandom(n, ignore, occupied, true)
+}
+
+// selectRandom implements SelectRandom and SelectRandomDiverse.
+func (ht *HostTree) selectRandom(n int, ignore, occupied []types.SiaPublicKey, diverse bool) []modules.HostDBEntry {
 	ht.mu.Lock()
 	defer ht.mu.Unlock()
 
 	var hosts []modules.HostDBEntry
 	var removedEntries []*hostEntry
 
+	usedSubnets := make(map[string]struct{})
+	for _, pubkey := range occupied {
+		node, exists := ht.hosts[string(pubkey.Key)]
+		if !exists {
+			continue
+		}
+		if subnet := hostSubnet(node.entry.HostDBEntry); subnet != "" {
+			usedSubnets[subnet] = struct{}{}
+		}
+	}
+
 	for _, pubkey := range ignore {
 		node, exists := ht.hosts[string(pubkey.Key)]
 		if !exists {
@@ -305,7 +329,13 @@ func (ht *HostTree) SelectRandom(n int, ignore []types.SiaPublicKey) []modules.H
 			node.entry.ScanHistory[len(node.entry.ScanHistory)-1].Success {
 			// The host must be online and accepting contracts to be returned
 			// by the random function.
-			hosts = append(hosts, node.entry.HostDBEntry)
+			subnet := hostSubnet(node.entry.HostDBEntry)
+			if _, used := usedSubnets[subnet]; !diverse || subnet == "" || !used {
+				hosts = append(hosts, node.entry.HostDBEntry)
+				if diverse && subnet != "" {
+					usedSubnets[subnet] = struct{}{}
+				}
+			}
 		}
 
 		removedEntries = append(removedEntries, node.entry)
@@ -320,3 +350,25 @@ func (ht *HostTree) SelectRandom(n int, ignore []types.SiaPublicKey) []modules.H
 
 	return hosts
 }
+
+// Subnet returns the subnet that hosts are grouped by when selecting diverse
+// hosts: the /24 of an IPv4 address, or the /64 of an IPv6 address.
+func Subnet(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return (&net.IPNet{IP: ip4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+	return (&net.IPNet{IP: ip.Mask(net.CIDRMask(64, 128)), Mask: net.CIDRMask(64, 128)}).String()
+}
+
+// hostSubnet returns the subnet of the host. If the host has not been reached
+// yet, the subnet is derived from its NetAddress, provided that it is an IP
+// address. Otherwise the subnet is unknown and the empty string is returned.
+func hostSubnet(entry modules.HostDBEntry) string {
+	if entry.Subnet != "" {
+		return entry.Subnet
+	}
+	if ip := net.ParseIP(entry.NetAddress.Host()); ip != nil {
+		return Subnet(ip)
+	}
+	return ""
+}
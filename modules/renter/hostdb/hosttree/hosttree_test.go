@@ -3,6 +3,7 @@ package hosttree
 import (
 	"errors"
 	"fmt"
+	"net"
 	"strconv"
 	"sync"
 	"testing"
@@ -473,3 +474,66 @@ func TestRandomHosts(t *testing.T) {
 		t.Error("doubled up")
 	}
 }
+
+// TestSelectRandomDiverse checks that SelectRandomDiverse returns at most one
+// host per subnet, and no host sharing a subnet with an occupied host.
+func TestSelectRandomDiverse(t *testing.T) {
+	tree := New(func(modules.HostDBEntry) types.Currency {
+		return types.NewCurrency64(10)
+	})
+
+	// Three hosts in 10.0.0.0/24, one of them only known by the address it
+	// was reached at, two hosts in 10.0.1.0/24 and one host whose subnet is
+	// unknown.
+	addrs := []modules.NetAddress{"10.0.0.1:9982", "10.0.0.2:9982", "example.com:9982", "10.0.1.1:9982", "10.0.1.2:9982", "example.org:9982"}
+	var entries []modules.HostDBEntry
+	for _, addr := range addrs {
+		entry := makeHostDBEntry()
+		entry.NetAddress = addr
+		if addr == "example.com:9982" {
+			entry.Subnet = Subnet(net.ParseIP("10.0.0.3"))
+		}
+		if err := tree.Insert(entry); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, entry)
+	}
+	if subnet := hostSubnet(entries[0]); subnet != "10.0.0.0/24" {
+		t.Fatal("wrong subnet:", subnet)
+	}
+	if subnet := Subnet(net.ParseIP("2001:db8::1")); subnet != "2001:db8::/64" {
+		t.Fatal("wrong subnet:", subnet)
+	}
+
+	for i := 0; i < 20; i++ {
+		hosts := tree.SelectRandomDiverse(len(entries), nil, nil)
+		if len(hosts) != 3 {
+			t.Fatal("expected one host per subnet and the unknown host, got", len(hosts))
+		}
+		subnets := make(map[string]struct{})
+		for _, host := range hosts {
+			subnet := hostSubnet(host)
+			if _, exists := subnets[subnet]; exists {
+				t.Fatal("two hosts in the same subnet were selected:", subnet)
+			}
+			subnets[subnet] = struct{}{}
+		}
+
+		// Occupying 10.0.1.0/24 leaves one host of 10.0.0.0/24 and the host
+		// with an unknown subnet.
+		hosts = tree.SelectRandomDiverse(len(entries), nil, []types.SiaPublicKey{entries[3].PublicKey})
+		if len(hosts) != 2 {
+			t.Fatal("expected two hosts, got", len(hosts))
+		}
+		for _, host := range hosts {
+			if hostSubnet(host) == "10.0.1.0/24" {
+				t.Fatal("host in an occupied subnet was selected")
+			}
+		}
+	}
+
+	// SelectRandom is not restricted.
+	if hosts := tree.SelectRandom(len(entries), nil); len(hosts) != len(entries) {
+		t.Fatal("expected all hosts, got", len(hosts))
+	}
+}
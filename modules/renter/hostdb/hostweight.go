@@ -3,6 +3,7 @@ package hostdb
 import (
 	"math"
 	"math/big"
+	"time"
 
 	"github.com/pachisi456/Sia/build"
 	"github.com/pachisi456/Sia/modules"
@@ -382,6 +383,48 @@ func (hdb *HostDB) uptimeAdjustments(entry modules.HostDBEntry) float64 {
 	return math.Pow(uptimeRatio, exp)
 }
 
+// scanSuccessAdjustments penalizes the host for failing a large share of its
+// recent scans. Each scan is weighted by its age, halving every
+// scanSuccessHalfLife, so that a host which is flaky right now is penalized
+// even if it has a long history of good uptime.
+func scanSuccessAdjustments(entry modules.HostDBEntry) float64 {
+	// New hosts are already handled by uptimeAdjustments.
+	if len(entry.ScanHistory) < 3 {
+		return 1
+	}
+
+	var successes, total float64
+	now := time.Now()
+	for _, scan := range entry.ScanHistory {
+		age := now.Sub(scan.Timestamp)
+		if age < 0 {
+			age = 0
+		}
+		weight := math.Pow(0.5, float64(age)/float64(scanSuccessHalfLife))
+		total += weight
+		if scan.Success {
+			successes += weight
+		}
+	}
+	if total == 0 {
+		return 1
+	}
+
+	// Hosts that succeed at 90% of their recent scans or more are not
+	// penalized. Below that the penalty grows quickly.
+	//
+	// 90% success = 1
+	// 80% success = 0.62
+	// 70% success = 0.37
+	// 50% success = 0.10
+	// 25% success = 0.006
+	successRate := successes / total
+	if successRate >= 0.9 {
+		return 1
+	}
+	return math.Pow(successRate/0.9, 4)
+}
+
 // calculateHostWeight returns the weight of a host according to the settings of
 // the host database entry.
 func (hdb *HostDB) calculateHostWeight(entry modules.HostDBEntry) types.Currency {
@@ -389,13 +432,15 @@ func (hdb *HostDB) calculateHostWeight(entry modules.HostDBEntry) types.Currency
 	interactionPenalty := hdb.interactionAdjustments(entry)
 	lifetimePenalty := hdb.lifetimeAdjustments(entry)
 	pricePenalty := hdb.priceAdjustments(entry)
+	scanSuccessPenalty := scanSuccessAdjustments(entry)
 	storageRemainingPenalty := storageRemainingAdjustments(entry)
 	uptimePenalty := hdb.uptimeAdjustments(entry)
 	versionPenalty := versionAdjustments(entry)
 
 	// Combine the adjustments.
 	fullPenalty := collateralReward * interactionPenalty * lifetimePenalty *
-		pricePenalty * storageRemainingPenalty * uptimePenalty * versionPenalty
+		pricePenalty * scanSuccessPenalty * storageRemainingPenalty * uptimePenalty *
+		versionPenalty
 
 	// Return a types.Currency.
 	weight := baseWeight.MulFloat(fullPenalty)
@@ -427,7 +472,7 @@ func (hdb *HostDB) calculateConversionRate(score types.Currency) float64 {
 // EstimateHostScore takes a HostExternalSettings and returns the estimated
 // score of that host in the hostdb, assuming no penalties for age or uptime.
 func (hdb *HostDB) EstimateHostScore(entry modules.HostDBEntry) modules.HostScoreBreakdown {
-	// Grab the adjustments. Age, and uptime penalties are set to '1', to
+	// Grab the adjustments. Age, uptime and scan success penalties are set to '1', to
 	// assume best behavior from the host.
 	collateralReward := hdb.collateralAdjustments(entry)
 	pricePenalty := hdb.priceAdjustments(entry)
@@ -451,6 +496,7 @@ func (hdb *HostDB) EstimateHostScore(entry modules.HostDBEntry) modules.HostScor
 		BurnAdjustment:             1,
 		CollateralAdjustment:       collateralReward,
 		PriceAdjustment:            pricePenalty,
+		ScanSuccessAdjustment:      1,
 		StorageRemainingAdjustment: storageRemainingPenalty,
 		UptimeAdjustment:           1,
 		VersionAdjustment:          versionPenalty,
@@ -473,6 +519,7 @@ func (hdb *HostDB) ScoreBreakdown(entry modules.HostDBEntry) modules.HostScoreBr
 		CollateralAdjustment:       hdb.collateralAdjustments(entry),
 		InteractionAdjustment:      hdb.interactionAdjustments(entry),
 		PriceAdjustment:            hdb.priceAdjustments(entry),
+		ScanSuccessAdjustment:      scanSuccessAdjustments(entry),
 		StorageRemainingAdjustment: storageRemainingAdjustments(entry),
 		UptimeAdjustment:           hdb.uptimeAdjustments(entry),
 		VersionAdjustment:          versionAdjustments(entry),
@@ -284,3 +284,42 @@ func TestHostWeightUptimeDifferences4(t *testing.T) {
 		t.Error("Been around longer should have more weight")
 	}
 }
+
+// TestScanSuccessAdjustments checks that hosts failing many of their recent
+// scans are penalized, and that recent scans count more than old ones.
+func TestScanSuccessAdjustments(t *testing.T) {
+	scans := func(successes ...bool) modules.HostDBScans {
+		var history modules.HostDBScans
+		start := time.Now().Add(-scanSuccessHalfLife * time.Duration(len(successes)))
+		for i, success := range successes {
+			history = append(history, modules.HostDBScan{
+				Timestamp: start.Add(scanSuccessHalfLife * time.Duration(i)),
+				Success:   success,
+			})
+		}
+		return history
+	}
+
+	var steady, flaky, recovered, degraded modules.HostDBEntry
+	steady.ScanHistory = scans(true, true, true, true, true, true)
+	flaky.ScanHistory = scans(true, false, true, false, true, false)
+	recovered.ScanHistory = scans(false, false, false, true, true, true)
+	degraded.ScanHistory = scans(true, true, true, false, false, false)
+
+	if adj := scanSuccessAdjustments(steady); adj != 1 {
+		t.Error("steady host should not be penalized:", adj)
+	}
+	if adj := scanSuccessAdjustments(flaky); adj >= 1 {
+		t.Error("flaky host should be penalized:", adj)
+	}
+	if scanSuccessAdjustments(recovered) <= scanSuccessAdjustments(degraded) {
+		t.Error("recent failures should be penalized more than old ones")
+	}
+
+	// Hosts with few scans are left to uptimeAdjustments.
+	var fresh modules.HostDBEntry
+	fresh.ScanHistory = scans(false, false)
+	if adj := scanSuccessAdjustments(fresh); adj != 1 {
+		t.Error("new host should not be penalized:", adj)
+	}
+}
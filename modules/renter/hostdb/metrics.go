@@ -0,0 +1,30 @@
+package hostdb
+
+import (
+	"github.com/pachisi456/Sia/metrics"
+)
+
+// hostdbMetrics contains the metrics that the hostdb exports through its
+// metrics registry.
+type hostdbMetrics struct {
+	activeHosts *metrics.Gauge
+	knownHosts  *metrics.Gauge
+	scans       *metrics.Counter
+}
+
+// newHostDBMetrics registers the hostdb's metrics with r.
+func newHostDBMetrics(r *metrics.Registry) hostdbMetrics {
+	return hostdbMetrics{
+		activeHosts: r.Gauge("sia_hostdb_active_hosts", "Number of hosts that are online and accepting contracts."),
+		knownHosts:  r.Gauge("sia_hostdb_hosts", "Number of hosts in the hostdb."),
+		scans:       r.Counter("sia_hostdb_scans_total", "Number of host scans, by result."),
+	}
+}
+
+// Metrics updates the host count gauges and returns the registry containing
+// the hostdb's metrics.
+func (hdb *HostDB) Metrics() *metrics.Registry {
+	hdb.metrics.knownHosts.Set(float64(len(hdb.AllHosts())))
+	hdb.metrics.activeHosts.Set(float64(len(hdb.ActiveHosts())))
+	return hdb.metricsRegistry
+}
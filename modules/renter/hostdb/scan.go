@@ -12,6 +12,7 @@ import (
 	"github.com/pachisi456/Sia/crypto"
 	"github.com/pachisi456/Sia/encoding"
 	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/modules/renter/hostdb/hosttree"
 	"github.com/NebulousLabs/fastrand"
 )
 
@@ -110,11 +111,20 @@ func (hdb *HostDB) updateEntry(entry modules.HostDBEntry, netErr error) {
 	if netErr != nil && !hdb.online {
 		return
 	}
+	if netErr == nil {
+		hdb.metrics.scans.Inc("result", "success")
+	} else {
+		hdb.metrics.scans.Inc("result", "failure")
+	}
 
 	// Grab the host from the host tree, and update it with the neew settings.
 	newEntry, exists := hdb.hostTree.Select(entry.PublicKey)
 	if exists {
 		newEntry.HostExternalSettings = entry.HostExternalSettings
+		newEntry.LastScanLatency = entry.LastScanLatency
+		if entry.Subnet != "" {
+			newEntry.Subnet = entry.Subnet
+		}
 	} else {
 		newEntry = entry
 	}
@@ -227,15 +237,22 @@ func (hdb *HostDB) managedScanHost(entry modules.HostDBEntry) {
 	hdb.mu.RUnlock()
 
 	var settings modules.HostExternalSettings
+	var subnet string
+	entry.LastScanLatency = 0
 	err := func() error {
 		dialer := &net.Dialer{
 			Cancel:  hdb.tg.StopChan(),
 			Timeout: hostRequestTimeout,
 		}
+		start := time.Now()
 		conn, err := dialer.Dial("tcp", string(netAddr))
 		if err != nil {
 			return err
 		}
+		latency := time.Since(start)
+		if addr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+			subnet = hosttree.Subnet(addr.IP)
+		}
 		connCloseChan := make(chan struct{})
 		go func() {
 			select {
@@ -253,7 +270,12 @@ func (hdb *HostDB) managedScanHost(entry modules.HostDBEntry) {
 		}
 		var pubkey crypto.PublicKey
 		copy(pubkey[:], pubKey.Key)
-		return crypto.ReadSignedObject(conn, &settings, maxSettingsLen, pubkey)
+		err = crypto.ReadSignedObject(conn, &settings, maxSettingsLen, pubkey)
+		if err != nil {
+			return err
+		}
+		entry.LastScanLatency = latency
+		return nil
 	}()
 	if err != nil {
 		hdb.log.Debugf("Scan of host at %v failed: %v", netAddr, err)
@@ -261,6 +283,7 @@ func (hdb *HostDB) managedScanHost(entry modules.HostDBEntry) {
 	} else {
 		hdb.log.Debugf("Scan of host at %v succeeded.", netAddr)
 		entry.HostExternalSettings = settings
+		entry.Subnet = subnet
 	}
 
 	// Update the host tree to have a new entry, including the new error. Then
@@ -300,8 +323,114 @@ func (hdb *HostDB) threadedProbeHosts(scanPool <-chan modules.HostDBEntry) {
 	}
 }
 
+// consecutiveScanFailures returns the number of scans that the host has
+// failed since its last successful scan.
+func consecutiveScanFailures(entry modules.HostDBEntry) int {
+	failures := 0
+	for i := len(entry.ScanHistory) - 1; i >= 0 && !entry.ScanHistory[i].Success; i-- {
+		failures++
+	}
+	return failures
+}
+
+// scanInterval returns how long the hostdb waits after a scan of the host
+// before scanning it again. Hosts that the renter has contracts with are
+// scanned every priorityScanInterval. Hosts that failed more than one scan in
+// a row are backed off exponentially, starting at minScanSleep, up to
+// maxOfflineScanInterval. All other hosts are eligible in every scan round.
+func (hdb *HostDB) scanInterval(entry modules.HostDBEntry) time.Duration {
+	if _, priority := hdb.priorityHosts[entry.PublicKey.String()]; priority {
+		return priorityScanInterval
+	}
+	failures := consecutiveScanFailures(entry)
+	if failures < 2 {
+		return 0
+	}
+	interval := minScanSleep
+	for i := 2; i < failures && interval < maxOfflineScanInterval; i++ {
+		interval *= 2
+	}
+	if interval > maxOfflineScanInterval {
+		interval = maxOfflineScanInterval
+	}
+	return interval
+}
+
+// scanDue returns whether the host is due for another scan.
+func (hdb *HostDB) scanDue(entry modules.HostDBEntry, now time.Time) bool {
+	if len(entry.ScanHistory) == 0 {
+		return true
+	}
+	lastScan := entry.ScanHistory[len(entry.ScanHistory)-1].Timestamp
+	return now.Sub(lastScan) >= hdb.scanInterval(entry)
+}
+
+// managedQueueScanRound queues a regular round of scans for the
+// hostCheckupQuanity most valuable online and offline hosts that are due for
+// a scan. Hosts that fail their scans will be docked significantly, pushing
+// them further back in the hierarchy, ensuring that for the most part only
+// online hosts are getting scanned unless there are fewer than
+// hostCheckupQuantity of them.
+func (hdb *HostDB) managedQueueScanRound() {
+	// Grab a set of hosts to scan, grab hosts that are active, inactive,
+	// and offline to get high diversity.
+	hdb.mu.Lock()
+	defer hdb.mu.Unlock()
+	now := time.Now()
+	var onlineHosts, offlineHosts []modules.HostDBEntry
+	allHosts := hdb.hostTree.All()
+	for i := len(allHosts) - 1; i >= 0; i-- {
+		if len(onlineHosts) >= hostCheckupQuantity && len(offlineHosts) >= hostCheckupQuantity {
+			break
+		}
+
+		// Skip the hosts that were scanned recently enough.
+		host := allHosts[i]
+		if !hdb.scanDue(host, now) {
+			continue
+		}
+
+		// Figure out if the host is online or offline.
+		online := len(host.ScanHistory) > 0 && host.ScanHistory[len(host.ScanHistory)-1].Success
+		if online && len(onlineHosts) < hostCheckupQuantity {
+			onlineHosts = append(onlineHosts, host)
+		} else if !online && len(offlineHosts) < hostCheckupQuantity {
+			offlineHosts = append(offlineHosts, host)
+		}
+	}
+
+	// Queue the scans for each host.
+	hdb.log.Println("Performing scan on", len(onlineHosts), "online hosts and", len(offlineHosts), "offline hosts.")
+	for _, host := range onlineHosts {
+		hdb.queueScan(host)
+	}
+	for _, host := range offlineHosts {
+		hdb.queueScan(host)
+	}
+}
+
+// managedQueuePriorityScans queues a scan for each of the priority hosts that
+// is due for one. It also ends the burst scan once all of its scans are done.
+func (hdb *HostDB) managedQueuePriorityScans() {
+	hdb.mu.Lock()
+	defer hdb.mu.Unlock()
+	if hdb.burstScan && len(hdb.scanList) == 0 && !hdb.scanWait && hdb.scanningThreads == 0 {
+		hdb.burstScan = false
+		hdb.log.Println("Finished the startup scan of all hosts.")
+	}
+	now := time.Now()
+	for _, spk := range hdb.priorityHosts {
+		host, exists := hdb.hostTree.Select(spk)
+		if exists && hdb.scanDue(host, now) {
+			hdb.queueScan(host)
+		}
+	}
+}
+
 // threadedScan is an ongoing function which will query the full set of hosts
-// every few hours to see who is online and available for uploading.
+// after startup, and then the most valuable hosts every few hours to see who
+// is online and available for uploading. In between, the hosts that the
+// renter has contracts with are scanned every priorityScanInterval.
 func (hdb *HostDB) threadedScan() {
 	err := hdb.tg.Add()
 	if err != nil {
@@ -309,56 +438,39 @@ func (hdb *HostDB) threadedScan() {
 	}
 	defer hdb.tg.Done()
 
-	for {
-		// Set up a scan for the hostCheckupQuanity most valuable hosts in the
-		// hostdb. Hosts that fail their scans will be docked significantly,
-		// pushing them further back in the hierarchy, ensuring that for the
-		// most part only online hosts are getting scanned unless there are
-		// fewer than hostCheckupQuantity of them.
-
-		// Grab a set of hosts to scan, grab hosts that are active, inactive,
-		// and offline to get high diversity.
-		var onlineHosts, offlineHosts []modules.HostDBEntry
-		allHosts := hdb.hostTree.All()
-		for i := len(allHosts) - 1; i >= 0; i-- {
-			if len(onlineHosts) >= hostCheckupQuantity && len(offlineHosts) >= hostCheckupQuantity {
-				break
-			}
+	// Scan every host right after startup, so that the renter does not have to
+	// rely on scan results that may be days old.
+	hdb.mu.Lock()
+	hdb.burstScan = true
+	allHosts := hdb.hostTree.All()
+	hdb.log.Println("Performing startup scan on", len(allHosts), "hosts.")
+	for _, host := range allHosts {
+		hdb.queueScan(host)
+	}
+	hdb.mu.Unlock()
 
-			// Figure out if the host is online or offline.
-			host := allHosts[i]
-			online := len(host.ScanHistory) > 0 && host.ScanHistory[len(host.ScanHistory)-1].Success
-			if online && len(onlineHosts) < hostCheckupQuantity {
-				onlineHosts = append(onlineHosts, host)
-			} else if !online && len(offlineHosts) < hostCheckupQuantity {
-				offlineHosts = append(offlineHosts, host)
-			}
+	var nextRound time.Time
+	for {
+		select {
+		case <-hdb.tg.StopChan():
+			return
+		case <-time.After(priorityScanInterval):
 		}
 
-		// Queue the scans for each host.
-		hdb.log.Println("Performing scan on", len(onlineHosts), "online hosts and", len(offlineHosts), "offline hosts.")
-		hdb.mu.Lock()
-		for _, host := range onlineHosts {
-			hdb.queueScan(host)
+		hdb.managedQueuePriorityScans()
+		hdb.mu.RLock()
+		burstScan := hdb.burstScan
+		hdb.mu.RUnlock()
+		if burstScan || time.Now().Before(nextRound) {
+			continue
 		}
-		for _, host := range offlineHosts {
-			hdb.queueScan(host)
-		}
-		hdb.mu.Unlock()
+		hdb.managedQueueScanRound()
 
-		// Sleep for a random amount of time before doing another round of
+		// Wait a random amount of time before doing another round of
 		// scanning. The minimums and maximums keep the scan time reasonable,
 		// while the randomness prevents the scanning from always happening at
 		// the same time of day or week.
-		sleepTime := defaultScanSleep
 		sleepRange := int(maxScanSleep - minScanSleep)
-		sleepTime = minScanSleep + time.Duration(fastrand.Intn(sleepRange))
-
-		// Sleep until it's time for the next scan cycle.
-		select {
-		case <-hdb.tg.StopChan():
-			return
-		case <-time.After(sleepTime):
-		}
+		nextRound = time.Now().Add(minScanSleep + time.Duration(fastrand.Intn(sleepRange)))
 	}
 }
@@ -182,3 +182,65 @@ func TestUpdateEntry(t *testing.T) {
 		t.Error("host not reporting historic uptime?")
 	}
 }
+
+// TestScanSchedule checks that priority hosts are scanned more often, and
+// that hosts failing their scans are scanned exponentially less often.
+func TestScanSchedule(t *testing.T) {
+	hdb := bareHostDB()
+	now := time.Now()
+	scans := func(successes ...bool) modules.HostDBScans {
+		var history modules.HostDBScans
+		for i, success := range successes {
+			history = append(history, modules.HostDBScan{
+				Timestamp: now.Add(time.Duration(i-len(successes)) * time.Second),
+				Success:   success,
+			})
+		}
+		return history
+	}
+
+	// Hosts that were never scanned, and online hosts, are due right away.
+	entry := makeHostDBEntry()
+	entry.ScanHistory = nil
+	if !hdb.scanDue(entry, now) {
+		t.Error("unscanned host should be due for a scan")
+	}
+	entry.ScanHistory = scans(true, true, false)
+	if !hdb.scanDue(entry, now) {
+		t.Error("host that failed a single scan should be due for a scan")
+	}
+
+	// Each additional failure doubles the interval, up to
+	// maxOfflineScanInterval.
+	entry.ScanHistory = scans(true, false, false)
+	if interval := hdb.scanInterval(entry); interval != minScanSleep {
+		t.Fatal("wrong interval after two failures:", interval)
+	}
+	entry.ScanHistory = scans(true, false, false, false)
+	if interval := hdb.scanInterval(entry); interval != 2*minScanSleep {
+		t.Fatal("wrong interval after three failures:", interval)
+	}
+	entry.ScanHistory = scans(false, false, false, false, false, false, false, false, false, false, false, false)
+	if interval := hdb.scanInterval(entry); interval != maxOfflineScanInterval {
+		t.Fatal("interval should be capped:", interval)
+	}
+	if hdb.scanDue(entry, now) || !hdb.scanDue(entry, now.Add(maxOfflineScanInterval)) {
+		t.Error("offline host should be backed off")
+	}
+	if err := hdb.hostTree.Insert(entry); err != nil {
+		t.Fatal(err)
+	}
+	if status := hdb.ScanStatus(); status.BackedOffHosts != 1 {
+		t.Error("expected one backed off host, got", status.BackedOffHosts)
+	}
+
+	// Priority hosts are scanned every priorityScanInterval, whether they are
+	// online or not.
+	hdb.SetPriorityHosts([]types.SiaPublicKey{entry.PublicKey})
+	if interval := hdb.scanInterval(entry); interval != priorityScanInterval {
+		t.Fatal("wrong interval for priority host:", interval)
+	}
+	if status := hdb.ScanStatus(); status.PriorityHosts != 1 || status.BackedOffHosts != 0 {
+		t.Error("wrong scan status:", status)
+	}
+}
@@ -0,0 +1,99 @@
+package renter
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pachisi456/Sia/build"
+	"github.com/pachisi456/Sia/persist"
+)
+
+type (
+	// A dedupLogger wraps the renter's logger for messages that may be logged
+	// in bursts, e.g. while many hosts are offline. Identical messages logged
+	// within the dedup window of the first occurrence are suppressed, and are
+	// collapsed into a single entry reporting how often they were repeated
+	// once the window has passed. Distinct messages are never collapsed
+	// together.
+	dedupLogger struct {
+		log *persist.Logger
+
+		recent map[string]*dedupEntry
+		window time.Duration
+		mu     sync.Mutex
+	}
+
+	// dedupEntry tracks a message that was logged within the dedup window.
+	dedupEntry struct {
+		start      time.Time
+		suppressed int
+	}
+)
+
+// newDedupLogger returns a dedupLogger that writes to log.
+func newDedupLogger(log *persist.Logger, window time.Duration) *dedupLogger {
+	return &dedupLogger{
+		log:    log,
+		recent: make(map[string]*dedupEntry),
+		window: window,
+	}
+}
+
+// managedSetWindow sets the dedup window. A window of zero disables
+// deduplication.
+func (dl *dedupLogger) managedSetWindow(window time.Duration) {
+	dl.mu.Lock()
+	dl.window = window
+	dl.mu.Unlock()
+}
+
+// managedWindow returns the dedup window.
+func (dl *dedupLogger) managedWindow() time.Duration {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	return dl.window
+}
+
+// Println is equivalent to persist.Logger.Println, but suppresses repeated
+// messages.
+func (dl *dedupLogger) Println(v ...interface{}) {
+	dl.managedOutput(fmt.Sprintln(v...))
+}
+
+// Debugln is equivalent to persist.Logger.Debugln, but suppresses repeated
+// messages.
+func (dl *dedupLogger) Debugln(v ...interface{}) {
+	if build.DEBUG {
+		dl.managedOutput("[DEBUG] " + fmt.Sprintln(v...))
+	}
+}
+
+// managedOutput writes msg to the log unless it was already logged within the
+// dedup window. Messages whose window has passed are removed, and a summary is
+// written for any that were suppressed.
+func (dl *dedupLogger) managedOutput(msg string) {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+
+	// The call depth of 4 attributes the entry to the caller of Println or
+	// Debugln.
+	now := time.Now()
+	for m, e := range dl.recent {
+		if now.Sub(e.start) < dl.window {
+			continue
+		}
+		if e.suppressed > 0 {
+			dl.log.Output(4, fmt.Sprintf("Previous message repeated %v times: %v", e.suppressed, m))
+		}
+		delete(dl.recent, m)
+	}
+	if dl.window > 0 {
+		if e, exists := dl.recent[msg]; exists {
+			e.suppressed++
+			return
+		}
+		dl.recent[msg] = &dedupEntry{start: now}
+	}
+	dl.log.Output(4, msg)
+}
@@ -0,0 +1,52 @@
+package renter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pachisi456/Sia/persist"
+)
+
+// TestDedupLogger checks that identical messages logged within the dedup
+// window are collapsed into a single entry, that distinct messages are logged
+// separately, and that the number of suppressed messages is reported once
+// the window has passed.
+func TestDedupLogger(t *testing.T) {
+	buf := new(bytes.Buffer)
+	dl := newDedupLogger(persist.NewLogger(buf), 100*time.Millisecond)
+	count := func(s string) int { return strings.Count(buf.String(), s) }
+
+	for i := 0; i < 5; i++ {
+		dl.Println("host foo offline")
+	}
+	dl.Println("host bar offline")
+	if n := count("host foo offline"); n != 1 {
+		t.Fatal("expected the repeated message to be logged once, got", n)
+	}
+	if n := count("host bar offline"); n != 1 {
+		t.Fatal("expected the distinct message to be logged once, got", n)
+	}
+
+	// After the window has passed, the next message reports the suppressed
+	// repetitions, and the message is logged again.
+	time.Sleep(150 * time.Millisecond)
+	dl.Println("host foo offline")
+	if n := count("repeated 4 times: host foo offline"); n != 1 {
+		t.Fatal("expected a summary of the 4 suppressed messages, got", buf.String())
+	}
+	if n := count("repeated"); n != 1 {
+		t.Fatal("expected a single summary, got", buf.String())
+	}
+
+	// A window of zero disables deduplication.
+	buf.Reset()
+	dl.managedSetWindow(0)
+	for i := 0; i < 3; i++ {
+		dl.Println("host baz offline")
+	}
+	if n := count("host baz offline"); n != 3 {
+		t.Fatal("expected every message to be logged without a window, got", n)
+	}
+}
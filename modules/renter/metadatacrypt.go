@@ -0,0 +1,120 @@
+package renter
+
+import (
+	"bytes"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+
+	"github.com/pachisi456/Sia/crypto"
+	"github.com/pachisi456/Sia/persist"
+)
+
+const (
+	// encryptedFilesDir is the folder of the persist directory that holds the
+	// .sia files when the renter's metadata is encrypted. The files are named
+	// after a keyed hash of their siapath, so that the siapaths are not
+	// revealed by the file names.
+	encryptedFilesDir = "encrypted"
+
+	// encryptedPersistFilename is the name of the encrypted counterpart of
+	// PersistFilename.
+	encryptedPersistFilename = "renter.enc"
+)
+
+var (
+	// errMetadataEncrypted is returned when the renter's metadata is
+	// encrypted, but the renter was started without a key.
+	errMetadataEncrypted = persist.ErrNoEncryptionKey
+
+	// errBadMetadataKey is returned when the renter's metadata can't be
+	// decrypted with the supplied key.
+	errBadMetadataKey = persist.ErrBadEncryptionKey
+
+	// encryptedFileHeader is prepended to encrypted .sia files.
+	encryptedFileHeader = []byte("Sia Encrypted File")
+)
+
+// MetadataKey derives the key for encrypting the renter's metadata from a
+// password.
+func MetadataKey(password string) crypto.TwofishKey {
+	return crypto.TwofishKey(crypto.HashAll("renter metadata", password))
+}
+
+// siaFilePath returns the path of the .sia file of the file with the given
+// siapath.
+func (r *Renter) siaFilePath(siapath string) string {
+	if r.metadataKey == nil {
+		return filepath.Join(r.persistDir, siapath+ShareExtension)
+	}
+	h := crypto.HashAll(*r.metadataKey, siapath)
+	return filepath.Join(r.persistDir, encryptedFilesDir, hex.EncodeToString(h[:])+ShareExtension)
+}
+
+// encryptFile returns the contents of an encrypted .sia file holding data.
+func (r *Renter) encryptFile(data []byte) []byte {
+	return append(append([]byte(nil), encryptedFileHeader...), r.metadataKey.EncryptBytes(data)...)
+}
+
+// decryptFile returns the data held by the .sia file with the given contents.
+// Plaintext .sia files are returned unchanged, and the second return value
+// reports whether the file was encrypted.
+func (r *Renter) decryptFile(contents []byte) ([]byte, bool, error) {
+	if !bytes.HasPrefix(contents, encryptedFileHeader) {
+		return contents, false, nil
+	}
+	if r.metadataKey == nil {
+		return nil, true, errMetadataEncrypted
+	}
+	data, err := r.metadataKey.DecryptBytes(contents[len(encryptedFileHeader):])
+	if err != nil {
+		return nil, true, errBadMetadataKey
+	}
+	return data, true, nil
+}
+
+// saveJSON saves the renter's persist data, encrypting it if the renter has a
+// metadata key.
+func (r *Renter) saveJSON(data interface{}) error {
+	return persist.SaveEncryptedJSON(saveMetadata, data, filepath.Join(r.persistDir, PersistFilename), filepath.Join(r.persistDir, encryptedPersistFilename), r.metadataKey)
+}
+
+// loadJSON loads the renter's persist data into data. If the renter has a
+// metadata key but only plaintext data exists, the plaintext data is loaded;
+// it is encrypted by the next save.
+func (r *Renter) loadJSON(data interface{}) error {
+	return persist.LoadEncryptedJSON(saveMetadata, data, filepath.Join(r.persistDir, PersistFilename), filepath.Join(r.persistDir, encryptedPersistFilename), r.metadataKey)
+}
+
+// migrateMetadata encrypts the renter's plaintext metadata after it was
+// loaded with a metadata key. plaintextFiles are the paths of the plaintext
+// .sia files that were loaded. The renter's lock must be held.
+func (r *Renter) migrateMetadata(plaintextFiles []string) error {
+	_, err := os.Stat(filepath.Join(r.persistDir, PersistFilename))
+	plaintextPersist := err == nil
+	if len(plaintextFiles) == 0 && !plaintextPersist {
+		return nil
+	}
+	r.log.Println("Encrypting the metadata of", len(plaintextFiles), "files")
+
+	// Save the encrypted copies before deleting the plaintext files. If the
+	// migration is interrupted, the plaintext copies of files that were
+	// already encrypted are skipped during the next load.
+	for _, f := range r.files {
+		f.mu.RLock()
+		err := r.saveFile(f)
+		f.mu.RUnlock()
+		if err != nil {
+			return err
+		}
+	}
+	for _, path := range plaintextFiles {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		for dir := filepath.Dir(path); dir != r.persistDir && len(dir) > len(r.persistDir); dir = filepath.Dir(dir) {
+			os.Remove(dir)
+		}
+	}
+	return r.saveSync()
+}
@@ -0,0 +1,114 @@
+package renter
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pachisi456/Sia/crypto"
+)
+
+// TestRenterMetadataEncryption checks that plaintext metadata is encrypted
+// once the renter has a metadata key, and that it can only be loaded again
+// with that key.
+func TestRenterMetadataEncryption(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Save a file and its tracking metadata in plaintext.
+	f := newTestingFile()
+	f.name = "secret/name"
+	id := rt.renter.mu.Lock()
+	rt.renter.files[f.name] = f
+	rt.renter.tracking[f.name] = trackedFile{RepairPath: "/secret/path"}
+	err = rt.renter.saveFile(f)
+	if err == nil {
+		err = rt.renter.saveSync()
+	}
+	rt.renter.mu.Unlock(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// reload loads the renter's metadata from disk with the given key.
+	reload := func(key *crypto.TwofishKey) error {
+		id := rt.renter.mu.Lock()
+		defer rt.renter.mu.Unlock(id)
+		rt.renter.metadataKey = key
+		rt.renter.files = make(map[string]*file)
+		rt.renter.tracking = make(map[string]trackedFile)
+		return rt.renter.load()
+	}
+	// loaded reports whether the file and its tracking metadata were loaded.
+	loaded := func() bool {
+		id := rt.renter.mu.RLock()
+		defer rt.renter.mu.RUnlock(id)
+		g, exists := rt.renter.files[f.name]
+		return exists && equalFiles(f, g) == nil && rt.renter.tracking[f.name].RepairPath == "/secret/path"
+	}
+	// secretsOnDisk reports whether the siapath or the repair path can be
+	// found in the renter's persist directory.
+	secretsOnDisk := func() bool {
+		var found bool
+		filepath.Walk(rt.renter.persistDir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			} else if strings.Contains(path, "secret") {
+				found = true
+				return nil
+			} else if info.IsDir() || filepath.Ext(path) == ".log" {
+				return nil
+			}
+			contents, err := ioutil.ReadFile(path)
+			if err == nil && strings.Contains(string(contents), "secret") {
+				found = true
+			}
+			return nil
+		})
+		return found
+	}
+	if !secretsOnDisk() {
+		t.Fatal("expected the plaintext metadata to be found on disk")
+	}
+
+	// Loading the metadata with a key encrypts it.
+	key := MetadataKey("foo")
+	if err := reload(&key); err != nil {
+		t.Fatal(err)
+	}
+	if !loaded() {
+		t.Fatal("plaintext metadata was not loaded")
+	}
+	if secretsOnDisk() {
+		t.Fatal("metadata was not encrypted")
+	}
+	if err := reload(&key); err != nil {
+		t.Fatal(err)
+	}
+	if !loaded() {
+		t.Fatal("encrypted metadata was not loaded")
+	}
+
+	// The metadata can't be loaded without the key.
+	if err := reload(nil); err != errMetadataEncrypted {
+		t.Fatal("expected errMetadataEncrypted, got", err)
+	}
+	wrongKey := MetadataKey("bar")
+	if err := reload(&wrongKey); err != errBadMetadataKey {
+		t.Fatal("expected errBadMetadataKey, got", err)
+	}
+	if err := reload(&key); err != nil {
+		t.Fatal(err)
+	}
+	if !loaded() {
+		t.Fatal("encrypted metadata was not loaded")
+	}
+}
@@ -0,0 +1,62 @@
+package renter
+
+import (
+	"io"
+
+	"github.com/pachisi456/Sia/metrics"
+)
+
+// renterMetrics contains the metrics that the renter exports through its
+// metrics registry.
+type renterMetrics struct {
+	chunksUploaded      *metrics.Counter
+	downloadBytes       *metrics.Counter
+	downloadFailures    *metrics.Counter
+	memoryWaitSeconds   *metrics.Counter
+	negotiationFailures *metrics.Counter
+	uploadBytes         *metrics.Counter
+	uploadFailures      *metrics.Counter
+
+	memoryAvailable *metrics.Gauge
+	repairBacklog   *metrics.Gauge
+	uploadsActive   *metrics.Gauge
+}
+
+// newRenterMetrics registers the renter's metrics with r.
+func newRenterMetrics(r *metrics.Registry) renterMetrics {
+	return renterMetrics{
+		chunksUploaded:      r.Counter("sia_renter_chunks_uploaded_total", "Number of chunks that finished uploading, by kind."),
+		downloadBytes:       r.Counter("sia_renter_download_bytes_total", "Number of bytes downloaded from each host."),
+		downloadFailures:    r.Counter("sia_renter_download_failures_total", "Number of failed piece downloads from each host."),
+		memoryWaitSeconds:   r.Counter("sia_renter_memory_wait_seconds_total", "Time that chunks spent waiting for memory before being uploaded."),
+		negotiationFailures: r.Counter("sia_renter_negotiation_failures_total", "Number of failed attempts to start a session with each host, by operation."),
+		uploadBytes:         r.Counter("sia_renter_upload_bytes_total", "Number of bytes uploaded to each host."),
+		uploadFailures:      r.Counter("sia_renter_upload_failures_total", "Number of failed piece uploads to each host."),
+
+		memoryAvailable: r.Gauge("sia_renter_memory_available_bytes", "Memory that is available to each class of uploads and downloads."),
+		repairBacklog:   r.Gauge("sia_renter_repair_backlog_chunks", "Number of chunks that are waiting in the upload heap."),
+		uploadsActive:   r.Gauge("sia_renter_uploads_active_chunks", "Number of chunks that are waiting or being uploaded."),
+	}
+}
+
+// WriteMetrics writes the metrics of the renter, its contractor and its hostdb
+// to w in the Prometheus text exposition format.
+func (r *Renter) WriteMetrics(w io.Writer) error {
+	waiting, active := r.uploadHeap.managedLen()
+	r.metrics.repairBacklog.Set(float64(waiting))
+	r.metrics.uploadsActive.Set(float64(active))
+	for class := memoryClass(0); class < numMemoryClasses; class++ {
+		r.metrics.memoryAvailable.Set(float64(r.managedMemoryAvailableGet(class)), "class", class.String())
+	}
+
+	if err := r.metricsRegistry.WriteText(w); err != nil {
+		return err
+	}
+	if err := r.hostContractor.Metrics().WriteText(w); err != nil {
+		return err
+	}
+	if r.hostDB == nil {
+		return nil
+	}
+	return r.hostDB.Metrics().WriteText(w)
+}
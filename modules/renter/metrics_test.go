@@ -0,0 +1,76 @@
+package renter
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/pachisi456/Sia/crypto"
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/types"
+
+	"github.com/NebulousLabs/fastrand"
+)
+
+// TestWriteMetrics checks that uploading a chunk is reflected in the metrics
+// written by the renter, and that the hostdb's metrics are included.
+func TestWriteMetrics(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	dc := &dedupContractor{
+		hostContractor: rt.renter.hostContractor,
+		contract: modules.RenterContract{
+			ID:            types.FileContractID{1},
+			HostPublicKey: types.SiaPublicKey{Key: []byte("foo")},
+			GoodForUpload: true,
+		},
+		host: &mockHost{sectors: make(map[crypto.Hash][]byte)},
+	}
+	id := rt.renter.mu.Lock()
+	rt.renter.hostContractor = dc
+	rt.renter.mu.Unlock(id)
+	rt.renter.managedUpdateWorkerPool()
+
+	rsc, _ := NewRSCode(1, 1)
+	f := newFile("foo", rsc, modules.SectorSize, modules.SectorSize)
+	data := fastrand.Bytes(int(modules.SectorSize))
+	rt.renter.managedMemoryAvailableSub(uint64(len(data)), memoryUploadSectors, memoryClassUserUpload)
+	uc := &unfinishedChunk{
+		renterFile:        f,
+		memoryNeeded:      uint64(len(data)),
+		minimumPieces:     1,
+		piecesNeeded:      1,
+		physicalChunkData: [][]byte{data},
+		pieceUsage:        make([]bool, 1),
+		unusedHosts: map[string]struct{}{
+			dc.contract.HostPublicKey.String(): {},
+		},
+	}
+	rt.renter.managedDistributeChunkToWorkers(uc)
+	rt.renter.heapWG.Wait()
+
+	var buf bytes.Buffer
+	if err := rt.renter.WriteMetrics(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, line := range []string{
+		"# TYPE sia_renter_chunks_uploaded_total counter\n",
+		"sia_renter_chunks_uploaded_total{kind=\"upload\"} 1\n",
+		fmt.Sprintf("sia_renter_upload_bytes_total{host=%q} %d\n", dc.contract.HostPublicKey.String(), modules.SectorSize),
+		"sia_renter_repair_backlog_chunks 0\n",
+		"# TYPE sia_hostdb_hosts gauge\n",
+	} {
+		if !strings.Contains(out, line) {
+			t.Errorf("metrics do not contain %q:\n%s", line, out)
+		}
+	}
+}
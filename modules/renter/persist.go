@@ -1,14 +1,18 @@
 package renter
 
 import (
+	"bufio"
 	"bytes"
 	"compress/gzip"
 	"encoding/base64"
 	"errors"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
+	"time"
 
 	"github.com/pachisi456/Sia/build"
 	"github.com/pachisi456/Sia/encoding"
@@ -19,6 +23,7 @@ import (
 
 const (
 	logFile         = modules.RenterDir + ".log"
+	logModule       = modules.RenterDir // module of structured log entries
 	PersistFilename = "renter.json"
 	ShareExtension  = ".sia"
 )
@@ -35,7 +40,15 @@ var (
 	}
 
 	shareHeader  = [15]byte{'S', 'i', 'a', ' ', 'S', 'h', 'a', 'r', 'e', 'd', ' ', 'F', 'i', 'l', 'e'}
-	shareVersion = "0.4"
+	shareVersion = "0.6"
+
+	// COMPATv0.5 - .sia files of version 0.5 don't store the modification
+	// time and tags of the file.
+	shareVersionNoMetadata = "0.5"
+
+	// COMPATv0.4 - .sia files of version 0.4 don't store the public keys of
+	// the hosts that the pieces are stored on.
+	shareVersionNoHostKeys = "0.4"
 )
 
 // MarshalSia implements the encoding.SiaMarshaller interface, writing the
@@ -65,13 +78,22 @@ func (f *file) MarshalSia(w io.Writer) error {
 	switch code := f.erasureCode.(type) {
 	case *rsCode:
 		err = enc.EncodeAll(
-			"Reed-Solomon",
+			rsCodeBackend,
 			uint64(code.dataPieces),
 			uint64(code.numPieces-code.dataPieces),
 		)
 		if err != nil {
 			return err
 		}
+	case *backendCode:
+		err = enc.EncodeAll(
+			code.backend,
+			uint64(code.dataPieces),
+			uint64(code.parityPieces),
+		)
+		if err != nil {
+			return err
+		}
 	default:
 		if build.DEBUG {
 			panic("unknown erasure code")
@@ -87,12 +109,32 @@ func (f *file) MarshalSia(w io.Writer) error {
 			return err
 		}
 	}
-	return nil
+
+	// encode metadata. The tags are sorted by key so that the encoding of a
+	// file is deterministic.
+	var modTime, modTimeNanos int64
+	if !f.modTime.IsZero() {
+		modTime, modTimeNanos = f.modTime.Unix(), int64(f.modTime.Nanosecond())
+	}
+	tags := make([]fileTag, 0, len(f.tags))
+	for key, value := range f.tags {
+		tags = append(tags, fileTag{Key: key, Value: value})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].Key < tags[j].Key
+	})
+	return enc.EncodeAll(modTime, modTimeNanos, tags)
 }
 
 // UnmarshalSia implements the encoding.SiaUnmarshaller interface,
 // reconstructing a file from the encoded bytes read from r.
 func (f *file) UnmarshalSia(r io.Reader) error {
+	return f.unmarshalVersion(r, shareVersion)
+}
+
+// unmarshalVersion reconstructs a file that was encoded with the given version
+// of the .sia format from the bytes read from r.
+func (f *file) unmarshalVersion(r io.Reader, version string) error {
 	dec := encoding.NewDecoder(r)
 
 	// COMPATv0.4.3 - decode bytesUploaded and chunksUploaded into dummy vars.
@@ -112,28 +154,23 @@ func (f *file) UnmarshalSia(r io.Reader) error {
 		return err
 	}
 
-	// Decode erasure coder.
+	// Decode erasure coder. Every backend is persisted as its name followed
+	// by the number of data and parity pieces.
 	var codeType string
-	if err := dec.Decode(&codeType); err != nil {
+	var nData, nParity uint64
+	err = dec.DecodeAll(
+		&codeType,
+		&nData,
+		&nParity,
+	)
+	if err != nil {
 		return err
 	}
-	switch codeType {
-	case "Reed-Solomon":
-		var nData, nParity uint64
-		err = dec.DecodeAll(
-			&nData,
-			&nParity,
-		)
-		if err != nil {
-			return err
-		}
-		rsc, err := NewRSCode(int(nData), int(nParity))
-		if err != nil {
-			return err
-		}
-		f.erasureCode = rsc
-	default:
+	f.erasureCode, err = NewErasureCoder(codeType, int(nData), int(nParity))
+	if err == ErrUnknownErasureCoder {
 		return errors.New("unrecognized erasure code type: " + codeType)
+	} else if err != nil {
+		return err
 	}
 
 	// Decode contracts.
@@ -142,34 +179,65 @@ func (f *file) UnmarshalSia(r io.Reader) error {
 		return err
 	}
 	f.contracts = make(map[types.FileContractID]fileContract)
-	var contract fileContract
 	for i := uint64(0); i < nContracts; i++ {
-		if err := dec.Decode(&contract); err != nil {
+		var contract fileContract
+		if version == shareVersionNoHostKeys {
+			err = dec.DecodeAll(&contract.ID, &contract.IP, &contract.Pieces, &contract.WindowStart)
+		} else {
+			err = dec.Decode(&contract)
+		}
+		if err != nil {
 			return err
 		}
 		f.contracts[contract.ID] = contract
 	}
+
+	// Decode metadata.
+	if version == shareVersionNoHostKeys || version == shareVersionNoMetadata {
+		return nil
+	}
+	var modTime, modTimeNanos int64
+	var tags []fileTag
+	if err := dec.DecodeAll(&modTime, &modTimeNanos, &tags); err != nil {
+		return err
+	}
+	if modTime != 0 || modTimeNanos != 0 {
+		f.modTime = time.Unix(modTime, modTimeNanos)
+	}
+	if len(tags) > 0 {
+		f.tags = make(map[string]string, len(tags))
+		for _, tag := range tags {
+			f.tags[tag.Key] = tag.Value
+		}
+	}
 	return nil
 }
 
 // saveFile saves a file to the renter directory.
 func (r *Renter) saveFile(f *file) error {
 	// Create directory structure specified in nickname.
-	fullPath := filepath.Join(r.persistDir, f.name+ShareExtension)
+	fullPath := r.siaFilePath(f.name)
 	err := os.MkdirAll(filepath.Dir(fullPath), 0700)
 	if err != nil {
 		return err
 	}
 
 	// Open SafeFile handle.
-	handle, err := persist.NewSafeFile(filepath.Join(r.persistDir, f.name+ShareExtension))
+	handle, err := persist.NewSafeFile(fullPath)
 	if err != nil {
 		return err
 	}
 	defer handle.Close()
 
-	// Write file data.
-	err = shareFiles([]*file{f}, handle)
+	// Write file data, encrypting it if the renter has a metadata key.
+	if r.metadataKey == nil {
+		err = shareFiles([]*file{f}, handle)
+	} else {
+		buf := new(bytes.Buffer)
+		if err = shareFiles([]*file{f}, buf); err == nil {
+			_, err = handle.Write(r.encryptFile(buf.Bytes()))
+		}
+	}
 	if err != nil {
 		return err
 	}
@@ -180,17 +248,42 @@ func (r *Renter) saveFile(f *file) error {
 
 // saveSync stores the current renter data to disk and then syncs to disk.
 func (r *Renter) saveSync() error {
+	threshold, throttledBandwidth := r.repairThrottle.managedThresholds()
+	maxDownloadSpeed, downloadFairness := r.downloadLimiter.managedLimits()
 	data := struct {
-		Tracking map[string]trackedFile
-	}{r.tracking}
-
-	return persist.SaveJSON(saveMetadata, data, filepath.Join(r.persistDir, PersistFilename))
+		Tracking                 map[string]trackedFile
+		RepairCheckpoints        map[string]repairCheckpoint
+		RepairThrottleThreshold  uint64
+		RepairThrottledBandwidth uint64
+		MaxDownloadSpeed         uint64
+		DownloadFairness         bool
+		DownloadCacheSize        uint64
+		DownloadCacheDiskSize    uint64
+		HostGracePeriod          time.Duration
+		WorkersPerContract       int
+		MaxRepairAttempts        uint64
+		MaxRepairTime            time.Duration
+		MaxStoredBytes           uint64
+		MaxStoredRedundantBytes  uint64
+		DownloadVerification     modules.DownloadVerification
+		DownloadOverdrive        int
+		LogDedupWindow           time.Duration
+		StructuredLogging        bool
+		MaxMemory                uint64
+		MaxUploadSpeed           uint64
+		Dirs                     map[string]struct{}
+	}{r.tracking, r.repairCheckpoints, threshold, throttledBandwidth, maxDownloadSpeed, downloadFairness, r.chunkCache.managedCapacity(), r.chunkCache.managedDiskCapacity(), r.hostGracePeriod, r.workersPerContract, r.maxRepairAttempts, r.maxRepairTime, r.maxStoredBytes, r.maxStoredRedundantBytes, r.downloadVerification, r.downloadOverdrive, r.dedupLog.managedWindow(), r.log.Structured(), r.maxMemory, r.uploadLimiter.managedMaxSpeed(), r.dirs}
+
+	return r.saveJSON(data)
 }
 
 // load fetches the saved renter data from disk.
 func (r *Renter) load() error {
 	// Recursively load all files found in renter directory. Errors
 	// encountered during loading are logged, but are not considered fatal.
+	// Plaintext files that are loaded although the renter has a metadata key
+	// are encrypted once loading is done.
+	var plaintextFiles []string
 	err := filepath.Walk(r.persistDir, func(path string, info os.FileInfo, err error) error {
 		// This error is non-nil if filepath.Walk couldn't stat a file or
 		// folder.
@@ -204,39 +297,124 @@ func (r *Renter) load() error {
 			return nil
 		}
 
-		// Open the file.
-		file, err := os.Open(path)
+		// Read and decrypt the file.
+		contents, err := ioutil.ReadFile(path)
 		if err != nil {
 			r.log.Println("ERROR: could not open .sia file:", err)
 			return nil
 		}
-		defer file.Close()
+		data, encrypted, err := r.decryptFile(contents)
+		if err != nil {
+			r.log.Println("ERROR: could not decrypt .sia file:", err)
+			return nil
+		}
+		if !encrypted && r.metadataKey != nil {
+			plaintextFiles = append(plaintextFiles, path)
+		}
 
-		// Load the file contents into the renter.
-		_, err = r.loadSharedFiles(file)
+		// Load the file contents into the renter. A file that was already
+		// loaded is a leftover of an interrupted migration to encrypted
+		// metadata.
+		files, err := decodeSharedFiles(bytes.NewReader(data))
 		if err != nil {
 			r.log.Println("ERROR: could not load .sia file:", err)
 			return nil
 		}
+		for _, f := range files {
+			if _, exists := r.files[f.name]; exists {
+				r.log.Println("WARN: skipping duplicate .sia file:", f.name)
+				continue
+			}
+			r.files[f.name] = f
+			r.addStorage(storedBytes(f))
+		}
 		return nil
 	})
 	if err != nil {
 		return err
 	}
 
-	// Load contracts, repair set, and entropy.
+	// Load contracts, repair set, and entropy. The bandwidth settings are
+	// initialized to their current values so that they are preserved when
+	// loading an older persist file.
+	threshold, throttledBandwidth := r.repairThrottle.managedThresholds()
+	maxDownloadSpeed, downloadFairness := r.downloadLimiter.managedLimits()
 	data := struct {
-		Tracking  map[string]trackedFile
-		Repairing map[string]string // COMPATv0.4.8
-	}{}
-	err = persist.LoadJSON(saveMetadata, &data, filepath.Join(r.persistDir, PersistFilename))
+		Tracking                 map[string]trackedFile
+		Repairing                map[string]string // COMPATv0.4.8
+		RepairCheckpoints        map[string]repairCheckpoint
+		RepairThrottleThreshold  uint64
+		RepairThrottledBandwidth uint64
+		MaxDownloadSpeed         uint64
+		DownloadFairness         bool
+		DownloadCacheSize        uint64
+		DownloadCacheDiskSize    uint64
+		HostGracePeriod          time.Duration
+		WorkersPerContract       int
+		MaxRepairAttempts        uint64
+		MaxRepairTime            time.Duration
+		MaxStoredBytes           uint64
+		MaxStoredRedundantBytes  uint64
+		DownloadVerification     modules.DownloadVerification
+		DownloadOverdrive        int
+		LogDedupWindow           time.Duration
+		StructuredLogging        bool
+		MaxMemory                uint64
+		MaxUploadSpeed           uint64
+		Dirs                     map[string]struct{}
+	}{
+		RepairThrottleThreshold:  threshold,
+		RepairThrottledBandwidth: throttledBandwidth,
+		MaxDownloadSpeed:         maxDownloadSpeed,
+		DownloadFairness:         downloadFairness,
+		DownloadCacheSize:        r.chunkCache.managedCapacity(),
+		DownloadCacheDiskSize:    r.chunkCache.managedDiskCapacity(),
+		HostGracePeriod:          r.hostGracePeriod,
+		WorkersPerContract:       r.workersPerContract,
+		MaxRepairAttempts:        r.maxRepairAttempts,
+		MaxRepairTime:            r.maxRepairTime,
+		LogDedupWindow:           r.dedupLog.managedWindow(),
+		StructuredLogging:        r.log.Structured(),
+		MaxMemory:                r.maxMemory,
+		MaxUploadSpeed:           r.uploadLimiter.managedMaxSpeed(),
+	}
+	err = r.loadJSON(&data)
 	if err != nil {
 		return err
 	}
 	if data.Tracking != nil {
 		r.tracking = data.Tracking
 	}
+	if data.RepairCheckpoints != nil {
+		r.repairCheckpoints = data.RepairCheckpoints
+	}
+	if data.Dirs != nil {
+		r.dirs = data.Dirs
+	}
+	r.repairThrottle.managedSetThresholds(data.RepairThrottleThreshold, data.RepairThrottledBandwidth)
+	r.downloadLimiter.managedSetLimits(data.MaxDownloadSpeed, data.DownloadFairness)
+	r.uploadLimiter.managedSetMaxSpeed(data.MaxUploadSpeed)
+	r.chunkCache.managedSetCapacity(data.DownloadCacheSize)
+	if err := r.chunkCache.managedSetDiskCapacity(data.DownloadCacheDiskSize); err != nil {
+		r.log.Println("WARN: could not enable the disk tier of the chunk cache:", err)
+	}
+	r.hostGracePeriod = data.HostGracePeriod
+	if data.WorkersPerContract > 0 {
+		r.workersPerContract = data.WorkersPerContract
+	}
+	r.maxRepairAttempts = data.MaxRepairAttempts
+	r.maxRepairTime = data.MaxRepairTime
+	r.maxStoredBytes = data.MaxStoredBytes
+	r.maxStoredRedundantBytes = data.MaxStoredRedundantBytes
+	r.downloadVerification = data.DownloadVerification
+	r.downloadOverdrive = data.DownloadOverdrive
+	r.dedupLog.managedSetWindow(data.LogDedupWindow)
+	r.log.SetStructured(data.StructuredLogging, logModule)
+	r.setMaxMemory(data.MaxMemory)
 
+	if r.metadataKey != nil {
+		return r.migrateMetadata(plaintextFiles)
+	}
 	return nil
 }
 
@@ -270,6 +448,11 @@ func shareFiles(files []*file, w io.Writer) error {
 
 // ShareFile saves the specified files to shareDest.
 func (r *Renter) ShareFiles(nicknames []string, shareDest string) error {
+	if err := r.tg.Add(); err != nil {
+		return ErrRenterShutdown
+	}
+	defer r.tg.Done()
+
 	lockID := r.mu.RLock()
 	defer r.mu.RUnlock(lockID)
 
@@ -291,6 +474,9 @@ func (r *Renter) ShareFiles(nicknames []string, shareDest string) error {
 		if !exists {
 			return ErrUnknownPath
 		}
+		f.mu.Lock()
+		r.recordHostKeys(f)
+		f.mu.Unlock()
 		files[i] = f
 	}
 
@@ -305,6 +491,11 @@ func (r *Renter) ShareFiles(nicknames []string, shareDest string) error {
 
 // ShareFilesAscii returns the specified files in ASCII format.
 func (r *Renter) ShareFilesAscii(nicknames []string) (string, error) {
+	if err := r.tg.Add(); err != nil {
+		return "", ErrRenterShutdown
+	}
+	defer r.tg.Done()
+
 	lockID := r.mu.RLock()
 	defer r.mu.RUnlock(lockID)
 
@@ -315,6 +506,9 @@ func (r *Renter) ShareFilesAscii(nicknames []string) (string, error) {
 		if !exists {
 			return "", ErrUnknownPath
 		}
+		f.mu.Lock()
+		r.recordHostKeys(f)
+		f.mu.Unlock()
 		files[i] = f
 	}
 
@@ -330,6 +524,45 @@ func (r *Renter) ShareFilesAscii(nicknames []string) (string, error) {
 // loadSharedFiles reads .sia data from reader and registers the contained
 // files in the renter. It returns the nicknames of the loaded files.
 func (r *Renter) loadSharedFiles(reader io.Reader) ([]string, error) {
+	files, err := decodeSharedFiles(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	// Make sure the files' names do not conflict with existing files.
+	for _, f := range files {
+		dupCount := 0
+		origName := f.name
+		for {
+			_, exists := r.files[f.name]
+			if !exists {
+				break
+			}
+			dupCount++
+			f.name = origName + "_" + strconv.Itoa(dupCount)
+		}
+	}
+
+	// Add files to renter. Pieces stored with hosts that the renter has a
+	// contract with are moved to that contract right away.
+	byHost := contractsByHost(r.hostContractor.Contracts())
+	names := make([]string, len(files))
+	for i, f := range files {
+		r.adoptForeignContracts(f, byHost)
+		r.files[f.name] = f
+		r.addStorage(storedBytes(f))
+		names[i] = f.name
+	}
+	// Save the files.
+	for _, f := range files {
+		r.saveFile(f)
+	}
+
+	return names, nil
+}
+
+// decodeSharedFiles reads the files written by shareFiles from reader.
+func decodeSharedFiles(reader io.Reader) ([]*file, error) {
 	// read header
 	var header [15]byte
 	var version string
@@ -343,51 +576,30 @@ func (r *Renter) loadSharedFiles(reader io.Reader) ([]string, error) {
 		return nil, err
 	} else if header != shareHeader {
 		return nil, ErrBadFile
-	} else if version != shareVersion {
+	} else if version != shareVersion && version != shareVersionNoMetadata && version != shareVersionNoHostKeys {
 		return nil, ErrIncompatible
 	}
 
-	// Create decompressor.
+	// Create decompressor. The gzip reader returns io.EOF together with the
+	// last bytes of the stream, which some decoders, e.g. the one of the host
+	// public key that ends the stream, treat as an error. Buffering defers
+	// io.EOF to the next read.
 	unzip, err := gzip.NewReader(reader)
 	if err != nil {
 		return nil, err
 	}
-	dec := encoding.NewDecoder(unzip)
+	buf := bufio.NewReader(unzip)
 
 	// Read each file.
 	files := make([]*file, numFiles)
 	for i := range files {
 		files[i] = new(file)
-		err := dec.Decode(files[i])
+		err := files[i].unmarshalVersion(buf, version)
 		if err != nil {
 			return nil, err
 		}
-
-		// Make sure the file's name does not conflict with existing files.
-		dupCount := 0
-		origName := files[i].name
-		for {
-			_, exists := r.files[files[i].name]
-			if !exists {
-				break
-			}
-			dupCount++
-			files[i].name = origName + "_" + strconv.Itoa(dupCount)
-		}
-	}
-
-	// Add files to renter.
-	names := make([]string, numFiles)
-	for i, f := range files {
-		r.files[f.name] = f
-		names[i] = f.name
-	}
-	// Save the files.
-	for _, f := range files {
-		r.saveFile(f)
 	}
-
-	return names, nil
+	return files, nil
 }
 
 // initPersist handles all of the persistence initialization, such as creating
@@ -404,6 +616,14 @@ func (r *Renter) initPersist() error {
 	if err != nil {
 		return err
 	}
+	r.dedupLog = newDedupLogger(r.log, defaultLogDedupWindow)
+
+	// Chunks left in the disk tier of the chunk cache by a previous run are
+	// unknown to the cache, so they are removed.
+	err = os.RemoveAll(filepath.Join(r.persistDir, chunkCacheDir))
+	if err != nil {
+		return err
+	}
 
 	// Load the prior persistence structures.
 	err = r.load()
@@ -416,6 +636,11 @@ func (r *Renter) initPersist() error {
 // LoadSharedFiles loads a .sia file into the renter. It returns the nicknames
 // of the loaded files.
 func (r *Renter) LoadSharedFiles(filename string) ([]string, error) {
+	if err := r.tg.Add(); err != nil {
+		return nil, ErrRenterShutdown
+	}
+	defer r.tg.Done()
+
 	lockID := r.mu.Lock()
 	defer r.mu.Unlock(lockID)
 
@@ -430,6 +655,11 @@ func (r *Renter) LoadSharedFiles(filename string) ([]string, error) {
 // LoadSharedFilesAscii loads an ASCII-encoded .sia file into the renter. It
 // returns the nicknames of the loaded files.
 func (r *Renter) LoadSharedFilesAscii(asciiSia string) ([]string, error) {
+	if err := r.tg.Add(); err != nil {
+		return nil, ErrRenterShutdown
+	}
+	defer r.tg.Done()
+
 	lockID := r.mu.Lock()
 	defer r.mu.Unlock(lockID)
 
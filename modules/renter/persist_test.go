@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/pachisi456/Sia/build"
 	"github.com/pachisi456/Sia/crypto"
@@ -27,6 +29,9 @@ func newTestingFile() *file {
 		masterKey:   crypto.GenerateTwofishKey(),
 		erasureCode: rsc,
 		pieceSize:   encoding.DecUint64(data[6:8]),
+		mode:        0644,
+		modTime:     time.Unix(int64(encoding.DecUint64(data[1:5]))+1, 0),
+		tags:        map[string]string{"foo": "bar"},
 	}
 }
 
@@ -47,6 +52,15 @@ func equalFiles(f1, f2 *file) error {
 	if f1.pieceSize != f2.pieceSize {
 		return fmt.Errorf("pieceSizes do not match: %v %v", f1.pieceSize, f2.pieceSize)
 	}
+	if f1.mode != f2.mode {
+		return fmt.Errorf("modes do not match: %v %v", f1.mode, f2.mode)
+	}
+	if !f1.modTime.Equal(f2.modTime) {
+		return fmt.Errorf("modification times do not match: %v %v", f1.modTime, f2.modTime)
+	}
+	if !reflect.DeepEqual(f1.tags, f2.tags) {
+		return fmt.Errorf("tags do not match: %v %v", f1.tags, f2.tags)
+	}
 	return nil
 }
 
@@ -28,7 +28,20 @@ type Downloader struct {
 // Sector retrieves the sector with the specified Merkle root, and revises
 // the underlying contract to pay the host proportionally to the data
 // retrieve.
-func (hd *Downloader) Sector(root crypto.Hash) (_ modules.RenterContract, _ []byte, err error) {
+func (hd *Downloader) Sector(root crypto.Hash) (modules.RenterContract, []byte, error) {
+	return hd.sector(root, true)
+}
+
+// UnverifiedSector is like Sector, but does not check that the data sent by
+// the host matches the Merkle root. The caller is responsible for detecting
+// corrupt data.
+func (hd *Downloader) UnverifiedSector(root crypto.Hash) (modules.RenterContract, []byte, error) {
+	return hd.sector(root, false)
+}
+
+// sector retrieves the sector with the specified Merkle root, checking the
+// data against the root if verify is set.
+func (hd *Downloader) sector(root crypto.Hash, verify bool) (_ modules.RenterContract, _ []byte, err error) {
 	defer extendDeadline(hd.conn, time.Hour) // reset deadline when finished
 
 	// calculate price
@@ -106,7 +119,7 @@ func (hd *Downloader) Sector(root crypto.Hash) (_ modules.RenterContract, _ []by
 	sector := sectors[0]
 	if uint64(len(sector)) != modules.SectorSize {
 		return modules.RenterContract{}, nil, errors.New("host did not send enough sector data")
-	} else if crypto.MerkleRoot(sector) != root {
+	} else if verify && crypto.MerkleRoot(sector) != root {
 		return modules.RenterContract{}, nil, errors.New("host sent bad sector data")
 	}
 
@@ -1,6 +1,7 @@
 package proto
 
 import (
+	"context"
 	"errors"
 	"net"
 	"sync"
@@ -10,6 +11,7 @@ import (
 	"github.com/pachisi456/Sia/crypto"
 	"github.com/pachisi456/Sia/encoding"
 	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/profile"
 	"github.com/pachisi456/Sia/types"
 )
 
@@ -111,6 +113,9 @@ func (he *Editor) runRevisionIteration(actions []modules.RevisionAction, rev typ
 	if err := encoding.WriteObject(he.conn, actions); err != nil {
 		return err
 	}
+	if len(actions) > 0 && actions[0].Type == modules.ActionInsert {
+		profile.TraceLog(context.Background(), "upload", "piece sent")
+	}
 
 	// send revision to host and exchange signatures
 	extendDeadline(he.conn, 2*time.Minute)
@@ -122,6 +127,7 @@ func (he *Editor) runRevisionIteration(actions []modules.RevisionAction, rev typ
 	} else if err != nil {
 		return err
 	}
+	profile.TraceLog(context.Background(), "upload", "revision signed")
 
 	// update host contract
 	he.contract.LastRevision = rev
@@ -16,14 +16,35 @@ const (
 	estTxnSize = 2048
 )
 
+var (
+	// ErrHostNotAcceptingContracts is returned by FormContract if the host
+	// reports that it is not accepting new contracts.
+	ErrHostNotAcceptingContracts = errors.New("host is not accepting contracts")
+)
+
+// FundingError is returned by FormContract if the renter was unable to fund
+// the contract. Funding happens before the host is contacted, so a
+// FundingError says nothing about the host.
+type FundingError struct {
+	Err error
+}
+
+// Error implements the error interface.
+func (fe FundingError) Error() string {
+	return fe.Err.Error()
+}
+
 // FormContract forms a contract with a host and submits the contract
 // transaction to tpool.
 func FormContract(params ContractParams, txnBuilder transactionBuilder, tpool transactionPool, hdb hostDB, cancel <-chan struct{}) (modules.RenterContract, error) {
 	// Extract vars from params, for convenience.
 	host, funding, startHeight, endHeight, refundAddress := params.Host, params.Funding, params.StartHeight, params.EndHeight, params.RefundAddress
 
-	// Create our key.
-	ourSK, ourPK := crypto.GenerateKeyPair()
+	// Create our key, unless one was supplied.
+	ourSK, ourPK := params.SecretKey, params.SecretKey.PublicKey()
+	if ourSK == (crypto.SecretKey{}) {
+		ourSK, ourPK = crypto.GenerateKeyPair()
+	}
 	// Create unlock conditions.
 	uc := types.UnlockConditions{
 		PublicKeys: []types.SiaPublicKey{
@@ -39,7 +60,7 @@ func FormContract(params ContractParams, txnBuilder transactionBuilder, tpool tr
 
 	// Underflow check.
 	if funding.Cmp(host.ContractPrice.Add(txnFee)) <= 0 {
-		return modules.RenterContract{}, errors.New("insufficient funds to cover contract fee and transaction fee during contract formation")
+		return modules.RenterContract{}, FundingError{errors.New("insufficient funds to cover contract fee and transaction fee during contract formation")}
 	}
 	// Divide by zero check.
 	if host.StoragePrice.IsZero() {
@@ -59,7 +80,7 @@ func FormContract(params ContractParams, txnBuilder transactionBuilder, tpool tr
 
 	// Check for negative currency.
 	if types.PostTax(startHeight, totalPayout).Cmp(hostPayout) < 0 {
-		return modules.RenterContract{}, errors.New("not enough money to pay both siafund fee and also host payout")
+		return modules.RenterContract{}, FundingError{errors.New("not enough money to pay both siafund fee and also host payout")}
 	}
 	// Create file contract.
 	fc := types.FileContract{
@@ -89,7 +110,7 @@ func FormContract(params ContractParams, txnBuilder transactionBuilder, tpool tr
 	// Build transaction containing fc, e.g. the File Contract.
 	err := txnBuilder.FundSiacoins(funding)
 	if err != nil {
-		return modules.RenterContract{}, err
+		return modules.RenterContract{}, FundingError{err}
 	}
 	txnBuilder.AddFileContract(fc)
 	// Add miner fee.
@@ -131,7 +152,7 @@ func FormContract(params ContractParams, txnBuilder transactionBuilder, tpool tr
 		return modules.RenterContract{}, err
 	}
 	if !host.AcceptingContracts {
-		return modules.RenterContract{}, errors.New("host is not accepting contracts")
+		return modules.RenterContract{}, ErrHostNotAcceptingContracts
 	}
 
 	// Allot time for negotiation.
@@ -66,38 +66,49 @@ func verifySettings(conn net.Conn, host modules.HostDBEntry) (modules.HostDBEntr
 	return host, nil
 }
 
-// verifyRecentRevision confirms that the host and contractor agree upon the current
-// state of the contract being revised.
-func verifyRecentRevision(conn net.Conn, contract modules.RenterContract, hostVersion string) error {
+// readRecentRevision proves to the host that the renter holds the secret key
+// of the contract with the given ID and reads the host's most recent revision
+// of the contract and the host's signatures of it.
+func readRecentRevision(conn net.Conn, id types.FileContractID, sk crypto.SecretKey, hostVersion string) (types.FileContractRevision, []types.TransactionSignature, error) {
 	// send contract ID
-	if err := encoding.WriteObject(conn, contract.ID); err != nil {
-		return errors.New("couldn't send contract ID: " + err.Error())
+	if err := encoding.WriteObject(conn, id); err != nil {
+		return types.FileContractRevision{}, nil, errors.New("couldn't send contract ID: " + err.Error())
 	}
 	// read challenge
 	var challenge crypto.Hash
 	if err := encoding.ReadObject(conn, &challenge, 32); err != nil {
-		return errors.New("couldn't read challenge: " + err.Error())
+		return types.FileContractRevision{}, nil, errors.New("couldn't read challenge: " + err.Error())
 	}
 	if build.VersionCmp(hostVersion, "1.3.0") >= 0 {
 		crypto.SecureWipe(challenge[:16])
 	}
 	// sign and return
-	sig := crypto.SignHash(challenge, contract.SecretKey)
+	sig := crypto.SignHash(challenge, sk)
 	if err := encoding.WriteObject(conn, sig); err != nil {
-		return errors.New("couldn't send challenge response: " + err.Error())
+		return types.FileContractRevision{}, nil, errors.New("couldn't send challenge response: " + err.Error())
 	}
 	// read acceptance
 	if err := modules.ReadNegotiationAcceptance(conn); err != nil {
-		return errors.New("host did not accept revision request: " + err.Error())
+		return types.FileContractRevision{}, nil, errors.New("host did not accept revision request: " + err.Error())
 	}
 	// read last revision and signatures
 	var lastRevision types.FileContractRevision
 	var hostSignatures []types.TransactionSignature
 	if err := encoding.ReadObject(conn, &lastRevision, 2048); err != nil {
-		return errors.New("couldn't read last revision: " + err.Error())
+		return types.FileContractRevision{}, nil, errors.New("couldn't read last revision: " + err.Error())
 	}
 	if err := encoding.ReadObject(conn, &hostSignatures, 2048); err != nil {
-		return errors.New("couldn't read host signatures: " + err.Error())
+		return types.FileContractRevision{}, nil, errors.New("couldn't read host signatures: " + err.Error())
+	}
+	return lastRevision, hostSignatures, nil
+}
+
+// verifyRecentRevision confirms that the host and contractor agree upon the current
+// state of the contract being revised.
+func verifyRecentRevision(conn net.Conn, contract modules.RenterContract, hostVersion string) error {
+	lastRevision, hostSignatures, err := readRecentRevision(conn, contract.ID, contract.SecretKey, hostVersion)
+	if err != nil {
+		return err
 	}
 	// Check that the unlock hashes match; if they do not, something is
 	// seriously wrong. Otherwise, check that the revision numbers match.
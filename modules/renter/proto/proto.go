@@ -41,7 +41,10 @@ type ContractParams struct {
 	StartHeight   types.BlockHeight
 	EndHeight     types.BlockHeight
 	RefundAddress types.UnlockHash
-	// TODO: add optional keypair
+
+	// SecretKey is the renter's key of the contract. If it is zero, a new
+	// key is generated.
+	SecretKey crypto.SecretKey
 }
 
 // A revisionSaver is called just before we send our revision signature to the host; this
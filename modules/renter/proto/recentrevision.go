@@ -0,0 +1,51 @@
+package proto
+
+import (
+	"errors"
+	"net"
+
+	"github.com/pachisi456/Sia/crypto"
+	"github.com/pachisi456/Sia/encoding"
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/types"
+)
+
+// RecentRevision asks the host for its most recent revision of the contract
+// with the given ID, proving that the renter holds sk, the renter's key of the
+// contract. It returns the transaction containing the revision and the
+// signatures of both parties, e.g. to recover a contract whose revisions were
+// lost.
+func RecentRevision(host modules.HostDBEntry, id types.FileContractID, sk crypto.SecretKey, cancel <-chan struct{}) (types.Transaction, error) {
+	conn, err := (&net.Dialer{
+		Cancel:  cancel,
+		Timeout: connTimeout,
+	}).Dial("tcp", string(host.NetAddress))
+	if err != nil {
+		return types.Transaction{}, err
+	}
+	// The host enters its revision loop after sending the revision, so the
+	// connection can't be closed gracefully.
+	defer conn.Close()
+
+	extendDeadline(conn, modules.NegotiateRecentRevisionTime)
+	if err := encoding.WriteObject(conn, modules.RPCDownload); err != nil {
+		return types.Transaction{}, errors.New("couldn't initiate RPC: " + err.Error())
+	}
+	rev, sigs, err := readRecentRevision(conn, id, sk, host.Version)
+	if err != nil {
+		return types.Transaction{}, err
+	}
+	if rev.ParentID != id {
+		return types.Transaction{}, errors.New("host sent a revision of the wrong contract")
+	}
+	// NOTE: we can fake the blockheight here because it doesn't affect
+	// verification; it just needs to be above the fork height and below the
+	// contract expiration.
+	if err := modules.VerifyFileContractRevisionTransactionSignatures(rev, sigs, rev.NewWindowStart-1); err != nil {
+		return types.Transaction{}, err
+	}
+	return types.Transaction{
+		FileContractRevisions: []types.FileContractRevision{rev},
+		TransactionSignatures: sigs,
+	}, nil
+}
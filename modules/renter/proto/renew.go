@@ -134,7 +134,7 @@ func Renew(contract modules.RenterContract, params ContractParams, txnBuilder tr
 		return modules.RenterContract{}, errors.New("settings exchange failed: " + err.Error())
 	}
 	if !host.AcceptingContracts {
-		return modules.RenterContract{}, errors.New("host is not accepting contracts")
+		return modules.RenterContract{}, ErrHostNotAcceptingContracts
 	}
 
 	// allot time for negotiation
@@ -17,21 +17,16 @@ package renter
 // renter itself can never interact with a worker while the renter is under
 // lock.
 
-// TODO: Change the upload loop to have an upload state, and make it so that
-// instead of occasionally rebuilding the whole file matrix it has just a
-// single matrix that it's constantly pulling chunks from. Have a separate loop
-// which goes through the files and adds them to the matrix. Have the loop
-// listen on the channel for new files, so that they can go directly into the
-// matrix.
-
-// TODO: Allow the 'baseMemory' to be set by the user.
-
 import (
 	"errors"
+	"path/filepath"
 	"reflect"
 	"sync"
+	"time"
 
 	"github.com/pachisi456/Sia/build"
+	"github.com/pachisi456/Sia/crypto"
+	"github.com/pachisi456/Sia/metrics"
 	"github.com/pachisi456/Sia/modules"
 	"github.com/pachisi456/Sia/modules/renter/contractor"
 	"github.com/pachisi456/Sia/modules/renter/hostdb"
@@ -43,6 +38,10 @@ import (
 )
 
 var (
+	// ErrRenterShutdown is returned by the renter's methods once the renter
+	// has started shutting down.
+	ErrRenterShutdown = errors.New("renter is shutting down")
+
 	errNilContractor = errors.New("cannot create renter with nil contractor")
 	errNilCS         = errors.New("cannot create renter with nil consensus set")
 	errNilHdb        = errors.New("cannot create renter with nil hostdb")
@@ -68,6 +67,9 @@ type hostDB interface {
 	// from.
 	ActiveHosts() []modules.HostDBEntry
 
+	// AddHosts inserts the provided entries into the hostdb.
+	AddHosts([]modules.HostDBEntry) error
+
 	// AllHosts returns the full list of hosts known to the hostdb, sorted in
 	// order of preference.
 	AllHosts() []modules.HostDBEntry
@@ -81,18 +83,31 @@ type hostDB interface {
 	// Host returns the HostDBEntry for a given host.
 	Host(types.SiaPublicKey) (modules.HostDBEntry, bool)
 
+	// QueryHosts returns the hosts that match the query, sorted by weight.
+	QueryHosts(modules.HostDBQuery) ([]modules.HostDBEntry, error)
+
 	// RandomHosts returns a set of random hosts, weighted by their estimated
 	// usefulness / attractiveness to the renter. RandomHosts will not return
 	// any offline or inactive hosts.
 	RandomHosts(int, []types.SiaPublicKey) []modules.HostDBEntry
 
+	// ScanStatus returns the state of the hostdb's scan queue.
+	ScanStatus() modules.HostDBScanStatus
+
 	// ScoreBreakdown returns a detailed explanation of the various properties
 	// of the host.
 	ScoreBreakdown(modules.HostDBEntry) modules.HostScoreBreakdown
 
+	// SetPriorityHosts sets the hosts that the renter has contracts with,
+	// which are scanned more often than the other hosts.
+	SetPriorityHosts([]types.SiaPublicKey)
+
 	// EstimateHostScore returns the estimated score breakdown of a host with the
 	// provided settings.
 	EstimateHostScore(modules.HostDBEntry) modules.HostScoreBreakdown
+
+	// Metrics returns the registry containing the hostdb's metrics.
+	Metrics() *metrics.Registry
 }
 
 // A hostContractor negotiates, revises, renews, and provides access to file
@@ -104,9 +119,17 @@ type hostContractor interface {
 	// soon as SetAllowance is called; that is, it may block.
 	SetAllowance(modules.Allowance) error
 
+	// ValidateAllowance returns the error that SetAllowance would return for
+	// the allowance, without setting it.
+	ValidateAllowance(modules.Allowance) error
+
 	// Allowance returns the current allowance
 	Allowance() modules.Allowance
 
+	// EstimateAllowance projects the fees and storage capacity of forming
+	// contracts for an allowance, without spending any money.
+	EstimateAllowance(modules.Allowance) (modules.AllowanceEstimate, error)
+
 	// Close closes the hostContractor.
 	Close() error
 
@@ -119,6 +142,10 @@ type hostContractor interface {
 	// ContractByID returns the contract associated with the file contract id.
 	ContractByID(types.FileContractID) (modules.RenterContract, bool)
 
+	// HasSector reports whether the contract associated with the file
+	// contract id stores a sector with the provided Merkle root.
+	HasSector(types.FileContractID, crypto.Hash) bool
+
 	// CurrentPeriod returns the height at which the current allowance period
 	// began.
 	CurrentPeriod() types.BlockHeight
@@ -131,6 +158,54 @@ type hostContractor interface {
 	// insertion, deletion, and modification of sectors.
 	Editor(types.FileContractID, <-chan struct{}) (contractor.Editor, error)
 
+	// ContractUtilization reports the utilization of every contract and the
+	// contract churn of the current period.
+	ContractUtilization() modules.ContractUtilizationReport
+
+	// FormationFailures returns the most recent contract formation failure
+	// of every host that a contract could not be formed with.
+	FormationFailures() []modules.FormationFailure
+
+	// Metrics returns the registry containing the contractor's metrics.
+	Metrics() *metrics.Registry
+
+	// Alerts returns the contracts whose formation transaction is in danger
+	// of never confirming.
+	Alerts() []modules.ContractAlert
+
+	// HostFilter returns the host filter mode and the filtered hosts.
+	HostFilter() (modules.HostFilterMode, []types.SiaPublicKey)
+
+	// SetHostFilter sets the hosts that contracts may be formed with.
+	SetHostFilter(modules.HostFilterMode, []types.SiaPublicKey) error
+
+	// MaxChurn returns the maximum number of contracts that may be replaced
+	// per period.
+	MaxChurn() uint64
+
+	// SetMaxChurn sets the maximum number of contracts that may be replaced
+	// per period.
+	SetMaxChurn(uint64) error
+
+	// SubnetDiversity returns whether contracts are only formed with hosts
+	// that do not share a subnet with the host of another contract.
+	SubnetDiversity() bool
+
+	// SetSubnetDiversity sets whether contracts are only formed with hosts
+	// that do not share a subnet with the host of another contract.
+	SetSubnetDiversity(bool) error
+
+	// MaxRevisions returns the maximum number of revisions per contract.
+	MaxRevisions() uint64
+
+	// SetMaxRevisions sets the maximum number of revisions per contract.
+	// Contracts approaching the limit are renewed.
+	SetMaxRevisions(uint64) error
+
+	// InGracePeriod reports whether the specified host does not yet have
+	// enough scan history to be used for uploads.
+	InGracePeriod(types.FileContractID, time.Duration) bool
+
 	// IsOffline reports whether the specified host is considered offline.
 	IsOffline(types.FileContractID) bool
 
@@ -145,6 +220,15 @@ type hostContractor interface {
 	// contract id. It is equivalent to calling 'ResolveID' and then using the
 	// result to call 'ContractByID'.
 	ResolveContract(types.FileContractID) (modules.RenterContract, bool)
+
+	// ImportContracts adds contracts that were formed by another contractor
+	// with the same wallet and returns the number of contracts added.
+	ImportContracts([]modules.RenterContract) (int, error)
+
+	// RecoverContracts scans the blockchain for contracts formed with keys
+	// derived from the wallet seed and returns the number of contracts
+	// recovered.
+	RecoverContracts() (int, error)
 }
 
 // A trackedFile contains metadata about files being tracked by the Renter.
@@ -154,6 +238,37 @@ type hostContractor interface {
 type trackedFile struct {
 	// location of original file on disk
 	RepairPath string
+
+	// RepairAttempts is the number of consecutive repair passes in which the
+	// file was incomplete, and FirstIncomplete is the time of the first of
+	// these passes. Failed is set once the file exceeds the renter's repair
+	// limits, after which the file is no longer repaired.
+	RepairAttempts  uint64
+	FirstIncomplete time.Time
+	Failed          bool
+
+	// Paused is set while the user has paused the upload of the file. Paused
+	// files are neither uploaded nor repaired.
+	Paused bool
+
+	// Uploading is set from the start of an upload until the file is fully
+	// uploaded for the first time. The pieces that were already uploaded are
+	// saved in the file, so uploads that were interrupted by a shutdown are
+	// resumed as soon as the renter starts again.
+	Uploading bool
+
+	// ChunkFailures contains the number of consecutive uploads of each chunk
+	// that ended below full redundancy. Chunks with stuckChunkFailures or
+	// more failures are stuck, see stuck.go.
+	ChunkFailures map[uint64]int
+}
+
+// pendingEstimation is a price estimation that is being computed. Callers
+// that find the estimation cache empty while an estimation is in progress
+// wait on done and then share est, instead of querying the hosts again.
+type pendingEstimation struct {
+	done chan struct{}
+	est  modules.RenterPriceEstimation
 }
 
 // A Renter is responsible for tracking all of the files that a user has
@@ -166,59 +281,227 @@ type Renter struct {
 	files    map[string]*file
 	tracking map[string]trackedFile // map from nickname to metadata
 
+	// repairCheckpoints contains the chunks of each file that have been
+	// repaired during the current repair pass. repairCheckpointsDirty is set
+	// while some of them have not been saved to disk yet, and
+	// lastCheckpointSave is when they were last saved.
+	repairCheckpoints      map[string]repairCheckpoint
+	repairCheckpointsDirty bool
+	lastCheckpointSave     time.Time
+
+	// dirs contains the directories that were created with CreateDir. All
+	// other directories exist implicitly as long as they contain a file.
+	dirs map[string]struct{}
+
 	// Work management.
 	//
-	// chunkQueue contains a list of incomplete work that the download loop acts
-	// upon. The chunkQueue is only ever modified by the main download loop
-	// thread, which means it can be accessed and updated without locks.
+	// chunkQueue contains the incomplete work that the download loop acts
+	// upon, ordered by priority. The chunkQueue is only ever modified by the
+	// main download loop thread, which means it can be accessed and updated
+	// without locks.
 	//
 	// downloadQueue contains a complete history of work that has been
 	// submitted to the download loop.
-	chunkQueue    []*chunkDownload // Accessed without locks.
-	downloadQueue []*download
-	newDownloads  chan *download
-	newUploads    chan *file
-	workerPool    map[types.FileContractID]*worker
+	//
+	// workerPool contains the workers of every contract. Each contract has
+	// workersPerContract workers that upload to the host in parallel, but only
+	// the first worker of each contract performs downloads.
+	chunkQueue         downloadChunkHeap // Accessed without locks.
+	downloadQueue      []*download
+	newDownloads       chan *download
+	newUploads         chan *file
+	workerPool         map[types.FileContractID][]*worker
+	workersPerContract int
+
+	// uploadHeap holds the chunks that are waiting to be uploaded, see
+	// threadedUploadLoop.
+	uploadHeap *uploadHeap
 
 	// Memory management - baseMemory tracks how much memory the renter is
 	// allowed to consume, memoryAvailable tracks how much more memory the
 	// renter can allocate before hitting the cap, and newMemory is a channel
 	// used to inform sleeping threads (the download loop and upload loop) that
 	// memory has become available.
-	baseMemory      uint64
-	memoryAvailable uint64
-	newMemory       chan struct{}
+	//
+	// maxMemory is the limit set by the user, zero meaning defaultMemory. If
+	// the limit is lowered while memory is in use, memoryOvercommitted tracks
+	// how far the memory in use exceeds baseMemory.
+	//
+	// memoryInUse breaks the memory in use down by what it is used for, and
+	// memoryClassInUse by on whose behalf it was allocated.
+	baseMemory          uint64
+	maxMemory           uint64
+	memoryAvailable     uint64
+	memoryInUse         [numMemoryCategories]uint64
+	memoryClassInUse    [numMemoryClasses]uint64
+	memoryOvercommitted uint64
+	newMemory           chan struct{}
+
+	// remoteRepairs limits the number of chunks that are downloaded from
+	// hosts for repairs at once. A chunk holds a slot while its logical data
+	// is being downloaded.
+	remoteRepairs chan struct{}
+
+	// repairThrottle limits the bandwidth consumed by repair traffic while
+	// there is a lot of foreground traffic.
+	repairThrottle *repairThrottle
+
+	// downloadLimiter enforces the MaxDownloadSpeed setting across all
+	// downloads.
+	downloadLimiter *downloadLimiter
+
+	// uploadLimiter enforces the MaxUploadSpeed setting across all workers.
+	uploadLimiter *uploadLimiter
+
+	// chunkCache holds recently recovered chunks.
+	chunkCache *chunkCache
+
+	// hostGracePeriod is the amount of time over which a host needs to have
+	// been scanned successfully before it is used for uploads.
+	hostGracePeriod time.Duration
+
+	// maxRepairAttempts and maxRepairTime limit how long a file that never
+	// reaches full redundancy is repaired before it is marked as failed.
+	// uploadFailureSubscribers receive an event for every failed file.
+	maxRepairAttempts        uint64
+	maxRepairTime            time.Duration
+	uploadFailureSubscribers map[chan modules.UploadFailure]struct{}
+
+	// uploadProgressSubscribers receive an event for every finished chunk of
+	// the file with the siapath that they subscribed to.
+	uploadProgressSubscribers map[chan modules.UploadProgress]string
+
+	// downloadVerification determines how many downloaded sectors are
+	// checked against their Merkle roots.
+	downloadVerification modules.DownloadVerification
+
+	// downloadOverdrive is the number of pieces that downloads request per
+	// chunk beyond the minimum needed to recover the chunk.
+	downloadOverdrive int
+
+	// maxStoredBytes and maxStoredRedundantBytes cap the logical and the
+	// on-host size of all files. Zero disables the respective cap.
+	maxStoredBytes          uint64
+	maxStoredRedundantBytes uint64
+
+	// storedBytes and storedRedundantBytes are the running totals of the
+	// logical and the on-host size of all files, including the space that is
+	// reserved by uploads in progress, see reserveStorage.
+	storedBytes          uint64
+	storedRedundantBytes uint64
+
+	// repairPasses counts the completed passes of the repair loop. The other
+	// fields describe the most recent pass, which ended at lastRepairPassTime.
+	repairPasses           uint64
+	lastRepairPassChunks   uint64
+	lastRepairPassDuration time.Duration
+	lastRepairPassTime     time.Time
+
+	// contractActivity counts the upload and download attempts of the
+	// workers of each contract.
+	contractActivity map[types.FileContractID]contractActivity
+
+	// dedupHits and dedupMisses count the piece uploads that were skipped
+	// because the host already stored the sector, and those that were not.
+	dedupHits       uint64
+	dedupMisses     uint64
+	dedupBytesSaved uint64
+
+	// streamingUploads holds the siapaths of the streams that are being
+	// uploaded. A stream's file is only added to the renter's files once the
+	// upload is complete.
+	streamingUploads map[string]struct{}
+
+	// uploadMetrics holds the per-stage timings of recently finished chunk
+	// uploads.
+	uploadMetrics uploadMetricsLog
+
+	// metrics and metricsRegistry contain the metrics exported by the
+	// renter, see WriteMetrics.
+	metrics         renterMetrics
+	metricsRegistry *metrics.Registry
+
+	// uploadTraceFile is the file that the upload trace is written to, or
+	// empty if no trace is being recorded, see StartUploadTrace.
+	uploadTraceFile string
+	uploadTraceMu   sync.Mutex
+
+	// metadataKey encrypts the .sia files and the persist data of the renter.
+	// If it is nil, the metadata is stored in plaintext.
+	metadataKey *crypto.TwofishKey
 
 	// Utilities.
 	cs             modules.ConsensusSet
 	hostContractor hostContractor
 	hostDB         hostDB
 	log            *persist.Logger
+	dedupLog       *dedupLogger
 	persistDir     string
 	mu             *siasync.RWMutex
 	heapWG         sync.WaitGroup // in-progress chunks join this waitgroup
+	drainChan      chan struct{}  // closed once the renter stops accepting new chunks
+	drainOnce      sync.Once
 	tg             threadgroup.ThreadGroup
 	tpool          modules.TransactionPool
+	wallet         modules.Wallet
+
+	lastEstimation    modules.RenterPriceEstimation // used to cache the last price estimation result
+	pendingEstimation *pendingEstimation            // shared by concurrent callers while the cache is empty
+
+	// estimationGeneration is incremented whenever the cached price
+	// estimation is invalidated. An estimation that was started in an earlier
+	// generation may be based on outdated settings, so it is not cached.
+	estimationGeneration uint64
 
-	lastEstimation modules.RenterPriceEstimation // used to cache the last price estimation result
+	// blockHeight is the current height of the consensus set, and
+	// contractExpirations caches how soon each contract expires at that
+	// height. Both are updated in ProcessConsensusChange.
+	blockHeight         types.BlockHeight
+	contractExpirations map[types.FileContractID]modules.ContractExpiry
 }
 
 // New returns an initialized renter.
 func New(g modules.Gateway, cs modules.ConsensusSet, wallet modules.Wallet, tpool modules.TransactionPool, persistDir string) (*Renter, error) {
-	hdb, err := hostdb.New(g, cs, persistDir)
+	return newWithMetadataKey(g, cs, wallet, tpool, persistDir, nil)
+}
+
+// NewWithMetadataKey returns an initialized renter that encrypts its metadata
+// with key. Plaintext metadata, e.g. of a renter that was previously started
+// without a key, is encrypted when the renter starts.
+func NewWithMetadataKey(g modules.Gateway, cs modules.ConsensusSet, wallet modules.Wallet, tpool modules.TransactionPool, persistDir string, key crypto.TwofishKey) (*Renter, error) {
+	return newWithMetadataKey(g, cs, wallet, tpool, persistDir, &key)
+}
+
+// newWithMetadataKey creates the renter's hostdb and contractor and returns
+// an initialized renter. The metadata of the renter, the persist data of the
+// hostdb and the journal of the contractor are encrypted if key is not nil.
+func newWithMetadataKey(g modules.Gateway, cs modules.ConsensusSet, wallet modules.Wallet, tpool modules.TransactionPool, persistDir string, key *crypto.TwofishKey) (*Renter, error) {
+	var hdb *hostdb.HostDB
+	var hc *contractor.Contractor
+	var err error
+	if key != nil {
+		hdb, err = hostdb.NewWithMetadataKey(g, cs, persistDir, *key)
+	} else {
+		hdb, err = hostdb.New(g, cs, persistDir)
+	}
 	if err != nil {
 		return nil, err
 	}
-	hc, err := contractor.New(cs, wallet, tpool, hdb, persistDir)
+	if key != nil {
+		hc, err = contractor.NewWithMetadataKey(cs, wallet, tpool, hdb, persistDir, *key)
+	} else {
+		hc, err = contractor.New(cs, wallet, tpool, hdb, persistDir)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	return newRenter(cs, tpool, hdb, hc, persistDir)
+	return newRenter(cs, tpool, wallet, hdb, hc, persistDir, key)
 }
 
-// newRenter initializes a renter and returns it.
-func newRenter(cs modules.ConsensusSet, tpool modules.TransactionPool, hdb hostDB, hc hostContractor, persistDir string) (*Renter, error) {
+// newRenter initializes a renter and returns it. The renter's metadata is
+// encrypted if metadataKey is not nil.
+func newRenter(cs modules.ConsensusSet, tpool modules.TransactionPool, wallet modules.Wallet, hdb hostDB, hc hostContractor, persistDir string, metadataKey *crypto.TwofishKey) (*Renter, error) {
 	if cs == nil {
 		return nil, errNilCS
 	}
@@ -236,26 +519,60 @@ func newRenter(cs modules.ConsensusSet, tpool modules.TransactionPool, hdb hostD
 		files:    make(map[string]*file),
 		tracking: make(map[string]trackedFile),
 
+		repairCheckpoints: make(map[string]repairCheckpoint),
+		dirs:              make(map[string]struct{}),
+
+		uploadFailureSubscribers:  make(map[chan modules.UploadFailure]struct{}),
+		uploadProgressSubscribers: make(map[chan modules.UploadProgress]string),
+		contractActivity:          make(map[types.FileContractID]contractActivity),
+		streamingUploads:          make(map[string]struct{}),
+
 		newDownloads: make(chan *download),
 		newUploads:   make(chan *file),
-		workerPool:   make(map[types.FileContractID]*worker),
+		drainChan:    make(chan struct{}),
+		uploadHeap:   newUploadHeap(),
+		workerPool:   make(map[types.FileContractID][]*worker),
+
+		workersPerContract: defaultWorkersPerContract,
 
 		baseMemory:      defaultMemory,
 		memoryAvailable: defaultMemory,
 		newMemory:       make(chan struct{}, 1),
+		remoteRepairs:   make(chan struct{}, maxScheduledDownloads),
+
+		repairThrottle:  newRepairThrottle(),
+		downloadLimiter: newDownloadLimiter(),
+		uploadLimiter:   newUploadLimiter(),
+		chunkCache:      newChunkCache(),
+
+		metricsRegistry: metrics.NewRegistry(),
 
 		cs:             cs,
 		hostDB:         hdb,
 		hostContractor: hc,
 		persistDir:     persistDir,
+		metadataKey:    metadataKey,
 		mu:             siasync.New(modules.SafeMutexDelay, 1),
 		tpool:          tpool,
+		wallet:         wallet,
+	}
+	r.metrics = newRenterMetrics(r.metricsRegistry)
+	r.chunkCache.reserve = func(amt uint64) bool {
+		return r.managedMemoryTryReserve(amt, memoryChunkCache, memoryClassUserDownload)
 	}
+	r.chunkCache.release = func(amt uint64) {
+		r.managedMemoryAvailableAdd(amt, memoryChunkCache, memoryClassUserDownload)
+	}
+	r.chunkCache.disk = newDiskChunkCache(filepath.Join(persistDir, chunkCacheDir))
 	if err := r.initPersist(); err != nil {
 		return nil, err
 	}
 
-	// Subscribe to the consensus set.
+	// Subscribe to the consensus set. The renter only receives changes made
+	// after subscribing, so the current height is fetched first.
+	r.blockHeight = cs.Height()
+	r.managedUpdateContractExpirations()
+	r.managedUpdatePriorityHosts()
 	err := cs.ConsensusSetSubscribe(r, modules.ConsensusChangeRecent, r.tg.StopChan())
 	if err != nil {
 		return nil, err
@@ -264,25 +581,157 @@ func newRenter(cs modules.ConsensusSet, tpool modules.TransactionPool, hdb hostD
 	// Spin up the workers for the work pool.
 	r.managedUpdateWorkerPool()
 	go r.threadedRepairScan()
+	go r.threadedUploadLoop()
+	go r.threadedStuckLoop()
+	go r.threadedQueueNewUploads()
 	go r.threadedDownloadLoop()
 
 	// Kill workers on shutdown.
 	r.tg.OnStop(func() error {
 		id := r.mu.RLock()
-		for _, worker := range r.workerPool {
-			close(worker.killChan)
+		for _, workers := range r.workerPool {
+			for _, worker := range workers {
+				close(worker.killChan)
+			}
 		}
 		r.mu.RUnlock(id)
 		return nil
 	})
+	// Save the repair checkpoints of the current batch on shutdown.
+	r.tg.OnStop(func() error {
+		return r.managedSaveRepairCheckpoints()
+	})
+	// Stop the upload trace on shutdown, so that the trace file is complete.
+	r.tg.OnStop(func() error {
+		if err := r.StopUploadTrace(); err != nil && err != errNoUploadTrace {
+			return err
+		}
+		return nil
+	})
 
 	return r, nil
 }
 
+// memoryCategory identifies what a portion of the renter's memory is being used
+// for. Every call to the memory accounting functions is tagged with a category
+// so that the renter can report a breakdown of its memory usage.
+type memoryCategory int
+
+const (
+	// memoryEncoding is memory used for chunk data that is being read from
+	// disk, erasure coded, and encrypted.
+	memoryEncoding memoryCategory = iota
+
+	// memoryUploadSectors is memory used for encrypted pieces that are
+	// waiting to be uploaded by workers.
+	memoryUploadSectors
+
+	// memoryDownloadSectors is memory used for chunk data that is being
+	// downloaded from hosts, for repairs or for user downloads.
+	memoryDownloadSectors
+
+	// memoryChunkCache is memory used for recovered chunks that are held by
+	// the chunk cache. It is reclaimed from the cache whenever memory is
+	// needed for anything else.
+	memoryChunkCache
+
+	numMemoryCategories
+)
+
+// memoryClass identifies on whose behalf a portion of the renter's memory is
+// allocated. Every class has a reserved minimum, a share of the renter's memory
+// that the other classes can't allocate, so that e.g. repairs can't starve
+// user downloads of memory.
+type memoryClass int
+
+const (
+	// memoryClassUserUpload is memory used for chunks of new uploads.
+	memoryClassUserUpload memoryClass = iota
+
+	// memoryClassUserDownload is memory used for user downloads, including
+	// the chunk cache.
+	memoryClassUserDownload
+
+	// memoryClassRepair is memory used for chunks that restore the
+	// redundancy of files that are already recoverable, and for chunks that
+	// are evacuated or stuck.
+	memoryClassRepair
+
+	numMemoryClasses
+)
+
+// memoryClassReserves are the fractions of the renter's memory that are
+// reserved for each class.
+var memoryClassReserves = [numMemoryClasses]float64{
+	memoryClassUserUpload:   0.25,
+	memoryClassUserDownload: 0.25,
+	memoryClassRepair:       0.1,
+}
+
+// String implements fmt.Stringer.
+func (c memoryClass) String() string {
+	switch c {
+	case memoryClassUserUpload:
+		return "userupload"
+	case memoryClassUserDownload:
+		return "userdownload"
+	case memoryClassRepair:
+		return "repair"
+	}
+	return "unknown"
+}
+
+// memoryClassReserve returns the amount of memory reserved for a class. The
+// renter's lock must be held.
+func (r *Renter) memoryClassReserve(class memoryClass) uint64 {
+	return uint64(float64(r.baseMemory) * memoryClassReserves[class])
+}
+
+// memoryAvailableForClass returns how much of 'avail' a class may allocate,
+// which is all of it except the unused reserves of the other classes. A class
+// that holds no memory may allocate all of it, so that a chunk that is larger
+// than the share of its class never waits forever. The renter's lock must be
+// held.
+func (r *Renter) memoryAvailableForClass(avail uint64, class memoryClass) uint64 {
+	if r.memoryClassInUse[class] == 0 {
+		return avail
+	}
+	var reserved uint64
+	for c := memoryClass(0); c < numMemoryClasses; c++ {
+		if reserve := r.memoryClassReserve(c); c != class && r.memoryClassInUse[c] < reserve {
+			reserved += reserve - r.memoryClassInUse[c]
+		}
+	}
+	if avail < reserved {
+		return 0
+	}
+	return avail - reserved
+}
+
 // managedMemoryAvailableAdd adds the amount provided to the renter's total
-// memory available.
-func (r *Renter) managedMemoryAvailableAdd(amt uint64) {
+// memory available, releasing it from the provided category and class.
+func (r *Renter) managedMemoryAvailableAdd(amt uint64, category memoryCategory, class memoryClass) {
 	id := r.mu.Lock()
+	if r.memoryInUse[category] < amt {
+		r.log.Critical("Memory in use is underflowing", category, r.memoryInUse[category], amt)
+		r.memoryInUse[category] = 0
+	} else {
+		r.memoryInUse[category] -= amt
+	}
+	if r.memoryClassInUse[class] < amt {
+		r.log.Critical("Memory in use is underflowing", class, r.memoryClassInUse[class], amt)
+		r.memoryClassInUse[class] = 0
+	} else {
+		r.memoryClassInUse[class] -= amt
+	}
+	// Memory that exceeds a lowered limit is not made available again.
+	if r.memoryOvercommitted >= amt {
+		r.memoryOvercommitted -= amt
+		amt = 0
+	} else {
+		amt -= r.memoryOvercommitted
+		r.memoryOvercommitted = 0
+	}
 	r.memoryAvailable += amt
 	if r.memoryAvailable > r.baseMemory {
 		r.mu.Unlock(id)
@@ -299,49 +748,412 @@ func (r *Renter) managedMemoryAvailableAdd(amt uint64) {
 }
 
 // managedMemoryAvailableGet returns the current amount of memory available to
-// the renter.
-func (r *Renter) managedMemoryAvailableGet() uint64 {
+// the class. Memory held by the chunk cache counts as available, as it is
+// reclaimed by managedMemoryAvailableSub when needed.
+func (r *Renter) managedMemoryAvailableGet(class memoryClass) uint64 {
 	id := r.mu.RLock()
 	memAvail := r.memoryAvailable
+	if cached := r.memoryInUse[memoryChunkCache]; cached > r.memoryOvercommitted {
+		memAvail += cached - r.memoryOvercommitted
+	}
+	memAvail = r.memoryAvailableForClass(memAvail, class)
 	r.mu.RUnlock(id)
 	return memAvail
 }
 
 // managedMemoryAvailableSub subtracts the amount provided from the renter's
-// total memory available.
-func (r *Renter) managedMemoryAvailableSub(amt uint64) {
+// total memory available, allocating it to the provided category and class.
+// If not enough memory is available, memory is reclaimed from the chunk
+// cache. Callers are expected to wait until managedMemoryAvailableGet reports
+// enough memory for their class first.
+func (r *Renter) managedMemoryAvailableSub(amt uint64, category memoryCategory, class memoryClass) {
 	id := r.mu.Lock()
+	for r.memoryAvailable < amt && r.memoryInUse[memoryChunkCache] > 0 {
+		shortfall := amt - r.memoryAvailable
+		r.mu.Unlock(id)
+		r.chunkCache.managedReclaim(shortfall)
+		id = r.mu.Lock()
+	}
 	if r.memoryAvailable < amt {
 		r.mu.Unlock(id)
 		r.log.Critical("Memory available is underflowing", r.memoryAvailable, amt)
 		return
 	}
 	r.memoryAvailable -= amt
+	r.memoryInUse[category] += amt
+	r.memoryClassInUse[class] += amt
+	r.mu.Unlock(id)
+}
+
+// managedMemoryTryReserve allocates the amount provided to the category and
+// class if that much memory is available to the class, without waiting or
+// reclaiming memory from the chunk cache. It returns false if the memory is
+// not available.
+func (r *Renter) managedMemoryTryReserve(amt uint64, category memoryCategory, class memoryClass) bool {
+	id := r.mu.Lock()
+	defer r.mu.Unlock(id)
+	if r.memoryAvailableForClass(r.memoryAvailable, class) < amt {
+		return false
+	}
+	r.memoryAvailable -= amt
+	r.memoryInUse[category] += amt
+	r.memoryClassInUse[class] += amt
+	return true
+}
+
+// managedMemoryMove moves memory that has already been allocated from one
+// category to another, without changing the amount of memory available.
+func (r *Renter) managedMemoryMove(amt uint64, from, to memoryCategory) {
+	id := r.mu.Lock()
+	defer r.mu.Unlock(id)
+	if r.memoryInUse[from] < amt {
+		r.log.Critical("Memory in use is underflowing", from, r.memoryInUse[from], amt)
+		return
+	}
+	r.memoryInUse[from] -= amt
+	r.memoryInUse[to] += amt
+}
+
+// managedSetMaxMemory changes the amount of memory that the renter is allowed
+// to consume, waking up any threads that are waiting for memory.
+func (r *Renter) managedSetMaxMemory(max uint64) {
+	id := r.mu.Lock()
+	r.setMaxMemory(max)
 	r.mu.Unlock(id)
+
+	select {
+	case r.newMemory <- struct{}{}:
+	default:
+	}
+}
+
+// setMaxMemory changes the amount of memory that the renter is allowed to
+// consume. A value of zero restores defaultMemory. Memory that is already in
+// use remains allocated; if it exceeds the new limit, nothing is allocated
+// until enough of it has been released.
+func (r *Renter) setMaxMemory(max uint64) {
+	r.maxMemory = max
+	if max == 0 {
+		max = defaultMemory
+	}
+	inUse := r.baseMemory - r.memoryAvailable + r.memoryOvercommitted
+	r.baseMemory = max
+	if inUse <= max {
+		r.memoryAvailable = max - inUse
+		r.memoryOvercommitted = 0
+	} else {
+		r.memoryAvailable = 0
+		r.memoryOvercommitted = inUse - max
+	}
+}
+
+// MemoryBreakdown reports how the renter's memory is being used.
+func (r *Renter) MemoryBreakdown() modules.RenterMemoryBreakdown {
+	id := r.mu.RLock()
+	defer r.mu.RUnlock(id)
+	return modules.RenterMemoryBreakdown{
+		Total:     r.baseMemory,
+		Available: r.memoryAvailable,
+
+		Encoding:        r.memoryInUse[memoryEncoding],
+		UploadSectors:   r.memoryInUse[memoryUploadSectors],
+		DownloadSectors: r.memoryInUse[memoryDownloadSectors],
+		ChunkCache:      r.memoryInUse[memoryChunkCache],
+
+		UserUploads: modules.MemoryClassUsage{
+			InUse:    r.memoryClassInUse[memoryClassUserUpload],
+			Reserved: r.memoryClassReserve(memoryClassUserUpload),
+		},
+		UserDownloads: modules.MemoryClassUsage{
+			InUse:    r.memoryClassInUse[memoryClassUserDownload],
+			Reserved: r.memoryClassReserve(memoryClassUserDownload),
+		},
+		Repairs: modules.MemoryClassUsage{
+			InUse:    r.memoryClassInUse[memoryClassRepair],
+			Reserved: r.memoryClassReserve(memoryClassRepair),
+		},
+	}
+}
+
+// IsShuttingDown returns true once the renter has started shutting down. After
+// that point, the renter's methods return ErrRenterShutdown.
+func (r *Renter) IsShuttingDown() bool {
+	select {
+	case <-r.tg.StopChan():
+		return true
+	default:
+		return false
+	}
 }
 
-// Close closes the Renter and its dependencies
+// Close closes the Renter and its dependencies.
+//
+// Before the workers are stopped, the renter stops accepting new chunks and
+// waits up to shutdownDrainTimeout for the chunks that were already handed to
+// the workers to finish uploading, so that the bandwidth spent on partially
+// uploaded chunks is not wasted. Every piece upload commits its contract
+// revision before the editor is closed, so the revisions of the drained pieces
+// are committed by the time the contractor is closed.
 func (r *Renter) Close() error {
+	r.drainOnce.Do(func() { close(r.drainChan) })
+	r.managedDrainChunks(shutdownDrainTimeout)
 	r.tg.Stop()
 	r.hostDB.Close()
 	return r.hostContractor.Close()
 }
 
-// PriceEstimation estimates the cost in siacoins of performing various storage
-// and data operations.
-//
-// TODO: Make this function line up with the actual settings in the renter.
-// Perhaps even make it so it uses the renter's actual contracts if it has any.
-func (r *Renter) PriceEstimation() modules.RenterPriceEstimation {
+// isDraining returns whether the renter is shutting down and no longer
+// accepts new chunks.
+func (r *Renter) isDraining() bool {
+	select {
+	case <-r.drainChan:
+		return true
+	default:
+		return false
+	}
+}
+
+// managedDrainChunks waits until the chunks that are being uploaded are done,
+// or until the timeout expires. It returns false if the timeout expired.
+func (r *Renter) managedDrainChunks(timeout time.Duration) bool {
+	drained := make(chan struct{})
+	go func() {
+		r.heapWG.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return true
+	case <-time.After(timeout):
+		r.log.Println("WARN: shutting down before all in-flight chunks finished uploading")
+		return false
+	}
+}
+
+// DownloadCostEstimate estimates the cost in siacoins of downloading the entire
+// file with the given nickname, based on the download bandwidth prices of the
+// hosts that store its pieces. Every chunk is assumed to be recovered from the
+// minimum number of full sectors, priced at the average of the online hosts
+// that hold a piece of the chunk.
+func (r *Renter) DownloadCostEstimate(nickname string) (modules.DownloadCostEstimate, error) {
+	if err := r.tg.Add(); err != nil {
+		return modules.DownloadCostEstimate{}, ErrRenterShutdown
+	}
+	defer r.tg.Done()
+
+	id := r.mu.RLock()
+	f, exists := r.files[nickname]
+	r.mu.RUnlock(id)
+	if !exists {
+		return modules.DownloadCostEstimate{}, ErrUnknownPath
+	}
+
+	// Collect the download prices of the online hosts that hold a piece of
+	// each chunk. Hosts that are offline or unknown to the hostdb cannot be
+	// priced.
+	f.mu.RLock()
+	numChunks := f.numChunks()
+	minPieces := f.erasureCode.MinPieces()
+	chunkPrices := make([][]types.Currency, numChunks)
+	for _, fc := range f.contracts {
+		contract, exists := r.hostContractor.ResolveContract(fc.ID)
+		if !exists || r.hostContractor.IsOffline(contract.ID) {
+			continue
+		}
+		host, exists := r.hostDB.Host(contract.HostPublicKey)
+		if !exists {
+			continue
+		}
+		for _, piece := range fc.Pieces {
+			if piece.Chunk < numChunks {
+				chunkPrices[piece.Chunk] = append(chunkPrices[piece.Chunk], host.DownloadBandwidthPrice)
+			}
+		}
+	}
+	f.mu.RUnlock()
+
+	var est modules.DownloadCostEstimate
+	for _, prices := range chunkPrices {
+		if len(prices) < minPieces {
+			est.UncostableChunks++
+			continue
+		}
+		var total types.Currency
+		for _, price := range prices {
+			total = total.Add(price)
+		}
+		chunkCost := total.Mul64(modules.SectorSize).Mul64(uint64(minPieces)).Div64(uint64(len(prices)))
+		est.Cost = est.Cost.Add(chunkCost)
+	}
+	return est, nil
+}
+
+// FileSpending attributes the spending of the renter's contracts to the file
+// with the given nickname. The spending of each contract that holds pieces of
+// the file is split in proportion to the number of its sectors that hold
+// them. Pieces stored under contracts that have since been renewed are
+// attributed to the renewed contract.
+func (r *Renter) FileSpending(nickname string) (modules.FileSpending, error) {
+	if err := r.tg.Add(); err != nil {
+		return modules.FileSpending{}, ErrRenterShutdown
+	}
+	defer r.tg.Done()
+
+	id := r.mu.RLock()
+	f, exists := r.files[nickname]
+	r.mu.RUnlock(id)
+	if !exists {
+		return modules.FileSpending{}, ErrUnknownPath
+	}
+
+	// Count the pieces of the file under each contract.
+	f.mu.RLock()
+	pieces := make(map[types.FileContractID]uint64)
+	contracts := make(map[types.FileContractID]modules.RenterContract)
+	for _, fc := range f.contracts {
+		contract, exists := r.hostContractor.ResolveContract(fc.ID)
+		if !exists {
+			continue
+		}
+		pieces[contract.ID] += uint64(len(fc.Pieces))
+		contracts[contract.ID] = contract
+	}
+	f.mu.RUnlock()
+
+	var fs modules.FileSpending
+	for id, contract := range contracts {
+		n := pieces[id]
+		if host, exists := r.hostDB.Host(contract.HostPublicKey); exists {
+			fs.MonthlyCost = fs.MonthlyCost.Add(host.StoragePrice.Mul64(modules.SectorSize).Mul64(n).Mul64(blocksPerMonth))
+		}
+		sectors := contract.LastRevision.NewFileSize / modules.SectorSize
+		if sectors == 0 {
+			continue
+		}
+		if n > sectors {
+			n = sectors
+		}
+		fees := contract.TxnFee.Add(contract.SiafundFee).Add(contract.ContractFee)
+		fs.StorageSpending = fs.StorageSpending.Add(contract.StorageSpending.Mul64(n).Div64(sectors))
+		fs.UploadSpending = fs.UploadSpending.Add(contract.UploadSpending.Mul64(n).Div64(sectors))
+		fs.FeeSpending = fs.FeeSpending.Add(fees.Mul64(n).Div64(sectors))
+	}
+	fs.Total = fs.StorageSpending.Add(fs.UploadSpending).Add(fs.FeeSpending)
+	return fs, nil
+}
+
+// MonthlyCostProjection estimates how much the renter's current contracts and
+// files cost per month. Contract fees are spread over the duration of each
+// contract, files are assumed to be stored at full redundancy, and pieces
+// that have not been uploaded yet are expected to be uploaded within the
+// month. Storage and upload costs use the average prices of the hosts that
+// the renter has contracts with.
+func (r *Renter) MonthlyCostProjection() modules.MonthlyCostProjection {
+	var proj modules.MonthlyCostProjection
+
+	// Add up the contract fees, and the prices of the contracted hosts.
+	var storagePrice, uploadPrice types.Currency
+	var numHosts uint64
+	for _, c := range r.hostContractor.Contracts() {
+		if c.EndHeight() > c.StartHeight {
+			fees := c.TxnFee.Add(c.SiafundFee).Add(c.ContractFee)
+			proj.ContractCost = proj.ContractCost.Add(fees.Mul64(blocksPerMonth).Div64(uint64(c.EndHeight() - c.StartHeight)))
+		}
+		host, exists := r.hostDB.Host(c.HostPublicKey)
+		if !exists {
+			continue
+		}
+		storagePrice = storagePrice.Add(host.StoragePrice)
+		uploadPrice = uploadPrice.Add(host.UploadBandwidthPrice)
+		numHosts++
+	}
+	if numHosts > 0 {
+		storagePrice = storagePrice.Div64(numHosts)
+		uploadPrice = uploadPrice.Div64(numHosts)
+	}
+
+	// Determine how much data is stored at full redundancy, and how much of
+	// it still has to be uploaded.
 	id := r.mu.RLock()
-	lastEstimation := r.lastEstimation
+	files := make([]*file, 0, len(r.files))
+	for _, f := range r.files {
+		files = append(files, f)
+	}
 	r.mu.RUnlock(id)
-	if !reflect.DeepEqual(lastEstimation, modules.RenterPriceEstimation{}) {
+	var desired, uploaded uint64
+	for _, f := range files {
+		f.mu.RLock()
+		fileDesired := f.pieceSize * uint64(f.erasureCode.NumPieces()) * f.numChunks()
+		var fileUploaded uint64
+		for _, fc := range f.contracts {
+			fileUploaded += uint64(len(fc.Pieces)) * f.pieceSize
+		}
+		f.mu.RUnlock()
+		if fileUploaded > fileDesired {
+			fileUploaded = fileDesired
+		}
+		desired += fileDesired
+		uploaded += fileUploaded
+	}
+
+	proj.StorageCost = storagePrice.Mul64(desired).Mul64(blocksPerMonth)
+	proj.UploadCost = uploadPrice.Mul64(desired - uploaded)
+	proj.Total = proj.ContractCost.Add(proj.StorageCost).Add(proj.UploadCost)
+	return proj
+}
+
+// PriceEstimation estimates the cost in siacoins of performing various storage
+// and data operations. Storage and upload costs include the redundancy of the
+// renter's files. Once the renter has contracts, the prices of their hosts are
+// used, and the cost of forming contracts follows the allowance.
+func (r *Renter) PriceEstimation() modules.RenterPriceEstimation {
+	// Return the cached estimation if there is one. Otherwise, either join
+	// an estimation that is already in progress, or start a new one that
+	// later callers can join.
+	id := r.mu.Lock()
+	if !reflect.DeepEqual(r.lastEstimation, modules.RenterPriceEstimation{}) {
+		lastEstimation := r.lastEstimation
+		r.mu.Unlock(id)
 		return lastEstimation
 	}
+	if pe := r.pendingEstimation; pe != nil {
+		r.mu.Unlock(id)
+		<-pe.done
+		return pe.est
+	}
+	pe := &pendingEstimation{done: make(chan struct{})}
+	r.pendingEstimation = pe
+	generation := r.estimationGeneration
+	r.mu.Unlock(id)
+
+	pe.est = r.managedEstimatePrices()
+	id = r.mu.Lock()
+	if r.estimationGeneration == generation {
+		r.lastEstimation = pe.est
+		r.pendingEstimation = nil
+	}
+	r.mu.Unlock(id)
+	close(pe.done)
+	return pe.est
+}
+
+// invalidateEstimation clears the cached price estimation. Estimations that
+// are in progress are neither cached nor joined by later callers.
+func (r *Renter) invalidateEstimation() {
+	r.lastEstimation = modules.RenterPriceEstimation{}
+	r.pendingEstimation = nil
+	r.estimationGeneration++
+}
 
+// managedEstimatePrices computes a price estimation from the prices of the
+// hosts that the renter has contracts with. Renters without contracts query a
+// random set of hosts instead.
+func (r *Renter) managedEstimatePrices() modules.RenterPriceEstimation {
 	// Grab hosts to perform the estimation.
-	hosts := r.hostDB.RandomHosts(priceEstimationScope, nil)
+	hosts := r.managedContractHosts()
+	if len(hosts) == 0 {
+		hosts = r.hostDB.RandomHosts(priceEstimationScope, nil)
+	}
 
 	// Check if there are zero hosts, which means no estimation can be made.
 	if len(hosts) == 0 {
@@ -366,8 +1178,9 @@ func (r *Renter) PriceEstimation() modules.RenterPriceEstimation {
 	totalUploadCost = totalUploadCost.Mul(modules.BytesPerTerabyte)
 
 	// Factor in redundancy.
-	totalStorageCost = totalStorageCost.Mul64(3) // TODO: follow file settings?
-	totalUploadCost = totalUploadCost.Mul64(3)   // TODO: follow file settings?
+	encoded, data := r.managedEstimationRedundancy()
+	totalStorageCost = totalStorageCost.Mul(encoded).Div(data)
+	totalUploadCost = totalUploadCost.Mul(encoded).Div(data)
 
 	// Perform averages.
 	totalContractCost = totalContractCost.Div64(uint64(len(hosts)))
@@ -375,57 +1188,224 @@ func (r *Renter) PriceEstimation() modules.RenterPriceEstimation {
 	totalStorageCost = totalStorageCost.Div64(uint64(len(hosts)))
 	totalUploadCost = totalUploadCost.Div64(uint64(len(hosts)))
 
-	// Take the average of the host set to estimate the overall cost of the
-	// contract forming.
-	totalContractCost = totalContractCost.Mul64(uint64(priceEstimationScope))
+	// Take the average of the host set to estimate the overall cost of
+	// forming as many contracts as the allowance asks for.
+	numContracts := r.hostContractor.Allowance().Hosts
+	if numContracts == 0 {
+		numContracts = uint64(priceEstimationScope)
+	}
+	totalContractCost = totalContractCost.Mul64(numContracts)
 
 	// Add the cost of paying the transaction fees for the first contract.
 	_, feePerByte := r.tpool.FeeEstimation()
-	totalContractCost = totalContractCost.Add(feePerByte.Mul64(1000).Mul64(uint64(priceEstimationScope)))
+	totalContractCost = totalContractCost.Add(feePerByte.Mul64(1000).Mul64(numContracts))
 
-	est := modules.RenterPriceEstimation{
+	return modules.RenterPriceEstimation{
 		FormContracts:        totalContractCost,
 		DownloadTerabyte:     totalDownloadCost,
 		StorageTerabyteMonth: totalStorageCost,
 		UploadTerabyte:       totalUploadCost,
 	}
+}
 
-	id = r.mu.Lock()
-	r.lastEstimation = est
-	r.mu.Unlock(id)
+// managedContractHosts returns the hosts of the contracts that the renter
+// uploads to, as currently known to the hostdb.
+func (r *Renter) managedContractHosts() []modules.HostDBEntry {
+	var hosts []modules.HostDBEntry
+	seen := make(map[string]struct{})
+	for _, c := range r.hostContractor.Contracts() {
+		if !c.GoodForUpload {
+			continue
+		}
+		if _, exists := seen[c.HostPublicKey.String()]; exists {
+			continue
+		}
+		seen[c.HostPublicKey.String()] = struct{}{}
+		if host, exists := r.hostDB.Host(c.HostPublicKey); exists {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// managedEstimationRedundancy returns the redundancy used by price
+// estimations as a fraction 'encoded/data'. It is the average redundancy of
+// the renter's files, weighted by their size. If the renter has no files, the
+// redundancy of the default erasure code is used.
+func (r *Renter) managedEstimationRedundancy() (encoded, data types.Currency) {
+	id := r.mu.RLock()
+	files := make([]*file, 0, len(r.files))
+	for _, f := range r.files {
+		files = append(files, f)
+	}
+	r.mu.RUnlock(id)
 
-	return est
+	for _, f := range files {
+		f.mu.RLock()
+		size := types.NewCurrency64(f.size)
+		encoded = encoded.Add(size.Mul64(uint64(f.erasureCode.NumPieces())))
+		data = data.Add(size.Mul64(uint64(f.erasureCode.MinPieces())))
+		f.mu.RUnlock()
+	}
+	if data.IsZero() {
+		return types.NewCurrency64(uint64(defaultDataPieces + defaultParityPieces)), types.NewCurrency64(uint64(defaultDataPieces))
+	}
+	return encoded, data
 }
 
-// SetSettings will update the settings for the renter.
+// SetSettings replaces all of the renter's settings with 's'. Fields that are
+// left at their zero value are reset, so callers that only change some
+// settings should start from Settings(). Every setting is validated before
+// any of them is applied; if one is invalid, none of them change.
 func (r *Renter) SetSettings(s modules.RenterSettings) error {
-	err := r.hostContractor.SetAllowance(s.Allowance)
-	if err != nil {
+	if err := r.tg.Add(); err != nil {
+		return ErrRenterShutdown
+	}
+	defer r.tg.Done()
+
+	switch s.DownloadVerification {
+	case "", modules.VerifyFull, modules.VerifySampled, modules.VerifyNone:
+	default:
+		return errUnknownVerification
+	}
+	if err := r.hostContractor.ValidateAllowance(s.Allowance); err != nil {
+		return err
+	}
+	if err := r.chunkCache.managedValidateDiskCapacity(s.DownloadCacheDiskSize); err != nil {
 		return err
 	}
 
+	// The settings are valid, so all of them are applied even if some of
+	// them fail to be saved.
+	err := r.hostContractor.SetAllowance(s.Allowance)
+	err = build.ComposeErrors(err, r.hostContractor.SetMaxRevisions(s.MaxContractRevisions))
+	err = build.ComposeErrors(err, r.hostContractor.SetMaxChurn(s.MaxContractChurn))
+	err = build.ComposeErrors(err, r.hostContractor.SetSubnetDiversity(s.HostSubnetDiversity))
+	r.repairThrottle.managedSetThresholds(s.RepairThrottleThreshold, s.RepairThrottledBandwidth)
+	r.downloadLimiter.managedSetLimits(s.MaxDownloadSpeed, s.DownloadFairness)
+	r.uploadLimiter.managedSetMaxSpeed(s.MaxUploadSpeed)
+	r.chunkCache.managedSetCapacity(s.DownloadCacheSize)
+	err = build.ComposeErrors(err, r.chunkCache.managedSetDiskCapacity(s.DownloadCacheDiskSize))
+	r.dedupLog.managedSetWindow(time.Duration(s.LogDedupWindow) * time.Second)
+	r.log.SetStructured(s.StructuredLogging, logModule)
+	r.managedSetMaxMemory(s.MaxMemory)
+	id := r.mu.Lock()
+	r.downloadVerification = s.DownloadVerification
+	r.downloadOverdrive = int(s.DownloadOverdrive)
+	r.hostGracePeriod = time.Duration(s.HostGracePeriod) * time.Second
+	r.maxRepairAttempts = s.MaxRepairAttempts
+	r.maxRepairTime = time.Duration(s.MaxRepairTime) * time.Second
+	r.maxStoredBytes = s.MaxStoredBytes
+	r.maxStoredRedundantBytes = s.MaxStoredRedundantBytes
+	r.invalidateEstimation() // the allowance may have changed
+	r.workersPerContract = int(s.WorkersPerContract)
+	if r.workersPerContract < 1 {
+		r.workersPerContract = 1
+	}
+	err = build.ComposeErrors(err, r.saveSync())
+	r.mu.Unlock(id)
+
 	r.managedUpdateWorkerPool()
-	return nil
+	r.managedUpdateContractExpirations()
+	return err
 }
 
 // hostdb passthroughs
 func (r *Renter) ActiveHosts() []modules.HostDBEntry                      { return r.hostDB.ActiveHosts() }
 func (r *Renter) AllHosts() []modules.HostDBEntry                         { return r.hostDB.AllHosts() }
+func (r *Renter) AddHosts(entries []modules.HostDBEntry) error {
+	if err := r.tg.Add(); err != nil {
+		return ErrRenterShutdown
+	}
+	defer r.tg.Done()
+	return r.hostDB.AddHosts(entries)
+}
 func (r *Renter) Host(spk types.SiaPublicKey) (modules.HostDBEntry, bool) { return r.hostDB.Host(spk) }
+func (r *Renter) QueryHosts(q modules.HostDBQuery) ([]modules.HostDBEntry, error) {
+	return r.hostDB.QueryHosts(q)
+}
 func (r *Renter) ScoreBreakdown(e modules.HostDBEntry) modules.HostScoreBreakdown {
 	return r.hostDB.ScoreBreakdown(e)
 }
 func (r *Renter) EstimateHostScore(e modules.HostDBEntry) modules.HostScoreBreakdown {
 	return r.hostDB.EstimateHostScore(e)
 }
+func (r *Renter) HostDBScanStatus() modules.HostDBScanStatus { return r.hostDB.ScanStatus() }
+
+// UncontractedHosts returns the active hosts that the renter does not have a
+// contract with.
+func (r *Renter) UncontractedHosts() []modules.HostDBEntry {
+	contracted := make(map[string]struct{})
+	for _, c := range r.hostContractor.Contracts() {
+		contracted[c.HostPublicKey.String()] = struct{}{}
+	}
+	var hosts []modules.HostDBEntry
+	for _, h := range r.hostDB.ActiveHosts() {
+		if _, exists := contracted[h.PublicKey.String()]; !exists {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
 
 // contractor passthroughs
 func (r *Renter) Contracts() []modules.RenterContract        { return r.hostContractor.Contracts() }
 func (r *Renter) CurrentPeriod() types.BlockHeight           { return r.hostContractor.CurrentPeriod() }
 func (r *Renter) PeriodSpending() modules.ContractorSpending { return r.hostContractor.PeriodSpending() }
+func (r *Renter) ContractUtilization() modules.ContractUtilizationReport {
+	return r.hostContractor.ContractUtilization()
+}
+func (r *Renter) EstimateAllowance(a modules.Allowance) (modules.AllowanceEstimate, error) {
+	return r.hostContractor.EstimateAllowance(a)
+}
+func (r *Renter) FormationFailures() []modules.FormationFailure {
+	return r.hostContractor.FormationFailures()
+}
+func (r *Renter) Alerts() []modules.ContractAlert { return r.hostContractor.Alerts() }
+func (r *Renter) RecoverContracts() (int, error)  { return r.hostContractor.RecoverContracts() }
+func (r *Renter) HostFilter() (modules.HostFilterMode, []types.SiaPublicKey) {
+	return r.hostContractor.HostFilter()
+}
+func (r *Renter) SetHostFilter(mode modules.HostFilterMode, hosts []types.SiaPublicKey) error {
+	return r.hostContractor.SetHostFilter(mode, hosts)
+}
 func (r *Renter) Settings() modules.RenterSettings {
+	threshold, throttledBandwidth := r.repairThrottle.managedThresholds()
+	maxDownloadSpeed, downloadFairness := r.downloadLimiter.managedLimits()
+	id := r.mu.RLock()
+	hostGracePeriod := r.hostGracePeriod
+	workersPerContract := r.workersPerContract
+	maxRepairAttempts := r.maxRepairAttempts
+	maxRepairTime := r.maxRepairTime
+	downloadVerification := r.downloadVerification
+	downloadOverdrive := r.downloadOverdrive
+	maxStoredBytes := r.maxStoredBytes
+	maxStoredRedundantBytes := r.maxStoredRedundantBytes
+	maxMemory := r.maxMemory
+	r.mu.RUnlock(id)
 	return modules.RenterSettings{
-		Allowance: r.hostContractor.Allowance(),
+		Allowance:                r.hostContractor.Allowance(),
+		DownloadCacheSize:        r.chunkCache.managedCapacity(),
+		DownloadCacheDiskSize:    r.chunkCache.managedDiskCapacity(),
+		DownloadFairness:         downloadFairness,
+		DownloadOverdrive:        uint64(downloadOverdrive),
+		DownloadVerification:     downloadVerification,
+		HostGracePeriod:          uint64(hostGracePeriod / time.Second),
+		HostSubnetDiversity:      r.hostContractor.SubnetDiversity(),
+		LogDedupWindow:           uint64(r.dedupLog.managedWindow() / time.Second),
+		MaxContractChurn:         r.hostContractor.MaxChurn(),
+		MaxContractRevisions:     r.hostContractor.MaxRevisions(),
+		MaxDownloadSpeed:         maxDownloadSpeed,
+		MaxMemory:                maxMemory,
+		MaxRepairAttempts:        maxRepairAttempts,
+		MaxRepairTime:            uint64(maxRepairTime / time.Second),
+		MaxStoredBytes:           maxStoredBytes,
+		MaxStoredRedundantBytes:  maxStoredRedundantBytes,
+		MaxUploadSpeed:           r.uploadLimiter.managedMaxSpeed(),
+		RepairThrottleThreshold:  threshold,
+		RepairThrottledBandwidth: throttledBandwidth,
+		StructuredLogging:        r.log.Structured(),
+		WorkersPerContract:       uint64(workersPerContract),
 	}
 }
 func (r *Renter) AllContracts() []modules.RenterContract {
@@ -435,8 +1415,69 @@ func (r *Renter) AllContracts() []modules.RenterContract {
 }
 func (r *Renter) ProcessConsensusChange(cc modules.ConsensusChange) {
 	id := r.mu.Lock()
-	r.lastEstimation = modules.RenterPriceEstimation{}
+	r.invalidateEstimation()
+	for _, block := range cc.RevertedBlocks {
+		if block.ID() != types.GenesisID {
+			r.blockHeight--
+		}
+	}
+	for _, block := range cc.AppliedBlocks {
+		if block.ID() != types.GenesisID {
+			r.blockHeight++
+		}
+	}
 	r.mu.Unlock(id)
+
+	r.managedUpdateContractExpirations()
+	r.managedUpdatePriorityHosts()
+
+	// The contractor processes consensus changes first. Stop using contracts
+	// that it found to be reorged out or double-spent right away, instead of
+	// letting uploads to them fail until the next repair scan.
+	r.managedDropUnusableWorkers()
+}
+
+// managedUpdatePriorityHosts has the hostdb keep a close eye on the hosts that
+// store the renter's data, by scanning them more often.
+func (r *Renter) managedUpdatePriorityHosts() {
+	contracts := r.hostContractor.Contracts()
+	hosts := make([]types.SiaPublicKey, 0, len(contracts))
+	for _, c := range contracts {
+		hosts = append(hosts, c.HostPublicKey)
+	}
+	r.hostDB.SetPriorityHosts(hosts)
+}
+
+// managedUpdateContractExpirations recomputes how soon each contract expires
+// at the current block height.
+func (r *Renter) managedUpdateContractExpirations() {
+	contracts := r.hostContractor.Contracts()
+	renewWindow := r.hostContractor.Allowance().RenewWindow
+
+	id := r.mu.Lock()
+	defer r.mu.Unlock(id)
+	r.contractExpirations = make(map[types.FileContractID]modules.ContractExpiry, len(contracts))
+	for _, c := range contracts {
+		var remaining types.BlockHeight
+		if c.EndHeight() > r.blockHeight {
+			remaining = c.EndHeight() - r.blockHeight
+		}
+		r.contractExpirations[c.ID] = modules.ContractExpiry{
+			BlocksRemaining: remaining,
+			ExpiryWarning:   remaining <= renewWindow,
+		}
+	}
+}
+
+// ContractExpirations returns how soon each of the renter's contracts expires.
+func (r *Renter) ContractExpirations() map[types.FileContractID]modules.ContractExpiry {
+	id := r.mu.RLock()
+	defer r.mu.RUnlock(id)
+	expirations := make(map[types.FileContractID]modules.ContractExpiry, len(r.contractExpirations))
+	for fcid, e := range r.contractExpirations {
+		expirations[fcid] = e
+	}
+	return expirations
 }
 
 // Enforce that Renter satisfies the modules.Renter interface.
@@ -3,10 +3,14 @@ package renter
 import (
 	"path/filepath"
 	"reflect"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/pachisi456/Sia/build"
 	"github.com/pachisi456/Sia/crypto"
+	"github.com/pachisi456/Sia/metrics"
 	"github.com/pachisi456/Sia/modules"
 	"github.com/pachisi456/Sia/modules/consensus"
 	"github.com/pachisi456/Sia/modules/gateway"
@@ -127,7 +131,7 @@ func newContractorTester(name string, hdb hostDB, hc hostContractor) (*renterTes
 	if err != nil {
 		return nil, err
 	}
-	r, err := newRenter(cs, tp, hdb, hc, filepath.Join(testdir, modules.RenterDir))
+	r, err := newRenter(cs, tp, w, hdb, hc, filepath.Join(testdir, modules.RenterDir), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -163,10 +167,14 @@ func newContractorTester(name string, hdb hostDB, hc hostContractor) (*renterTes
 type stubHostDB struct{}
 
 func (stubHostDB) ActiveHosts() []modules.HostDBEntry   { return nil }
+func (stubHostDB) AddHosts([]modules.HostDBEntry) error { return nil }
 func (stubHostDB) AllHosts() []modules.HostDBEntry      { return nil }
 func (stubHostDB) AverageContractPrice() types.Currency { return types.Currency{} }
 func (stubHostDB) Close() error                         { return nil }
 func (stubHostDB) IsOffline(modules.NetAddress) bool    { return true }
+func (stubHostDB) QueryHosts(modules.HostDBQuery) ([]modules.HostDBEntry, error) {
+	return nil, nil
+}
 func (stubHostDB) RandomHosts(int, []types.SiaPublicKey) []modules.HostDBEntry {
 	return []modules.HostDBEntry{}
 }
@@ -176,16 +184,20 @@ func (stubHostDB) EstimateHostScore(modules.HostDBEntry) modules.HostScoreBreakd
 func (stubHostDB) Host(types.SiaPublicKey) (modules.HostDBEntry, bool) {
 	return modules.HostDBEntry{}, false
 }
+func (stubHostDB) ScanStatus() modules.HostDBScanStatus { return modules.HostDBScanStatus{} }
 func (stubHostDB) ScoreBreakdown(modules.HostDBEntry) modules.HostScoreBreakdown {
 	return modules.HostScoreBreakdown{}
 }
+func (stubHostDB) SetPriorityHosts([]types.SiaPublicKey) {}
+func (stubHostDB) Metrics() *metrics.Registry            { return nil }
 
 // stubContractor is the minimal implementation of the hostContractor
 // interface.
 type stubContractor struct{}
 
-func (stubContractor) SetAllowance(modules.Allowance) error { return nil }
-func (stubContractor) Allowance() modules.Allowance         { return modules.Allowance{} }
+func (stubContractor) SetAllowance(modules.Allowance) error      { return nil }
+func (stubContractor) ValidateAllowance(modules.Allowance) error { return nil }
+func (stubContractor) Allowance() modules.Allowance              { return modules.Allowance{} }
 func (stubContractor) Contract(modules.NetAddress) (modules.RenterContract, bool) {
 	return modules.RenterContract{}, false
 }
@@ -249,3 +261,838 @@ func TestRenterPricesVolatility(t *testing.T) {
 		t.Fatal("expected renter price estimation to change after mining a block")
 	}
 }
+
+// TestRenterPricesRedundancy checks that the price estimation follows the
+// redundancy of the renter's files.
+func TestRenterPricesRedundancy(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	dbe := modules.HostDBEntry{}
+	dbe.StoragePrice = types.SiacoinPrecision
+	dbe.UploadBandwidthPrice = types.SiacoinPrecision
+	id := rt.renter.mu.Lock()
+	rt.renter.hostDB = &pricesStub{dbEntries: []modules.HostDBEntry{dbe}}
+	rt.renter.mu.Unlock(id)
+	storage := types.SiacoinPrecision.Mul(modules.BlockBytesPerMonthTerabyte)
+	upload := types.SiacoinPrecision.Mul(modules.BytesPerTerabyte)
+
+	// Without files, the redundancy of the default erasure code is used.
+	est := rt.renter.PriceEstimation()
+	defaultRedundancy := uint64(defaultDataPieces+defaultParityPieces) / uint64(defaultDataPieces)
+	if !est.StorageTerabyteMonth.Equals(storage.Mul64(defaultRedundancy)) || !est.UploadTerabyte.Equals(upload.Mul64(defaultRedundancy)) {
+		t.Fatal("estimation does not use the default redundancy:", est)
+	}
+
+	// Add two files of the same size, with 2x and 4x redundancy. The
+	// estimation should use the average redundancy of 3x.
+	rsc2, _ := NewRSCode(1, 1)
+	rsc4, _ := NewRSCode(1, 3)
+	id = rt.renter.mu.Lock()
+	rt.renter.files["foo"] = newFile("foo", rsc2, modules.SectorSize, 1e6)
+	rt.renter.files["bar"] = newFile("bar", rsc4, modules.SectorSize, 1e6)
+	rt.renter.lastEstimation = modules.RenterPriceEstimation{}
+	rt.renter.mu.Unlock(id)
+	est = rt.renter.PriceEstimation()
+	if !est.StorageTerabyteMonth.Equals(storage.Mul64(3)) || !est.UploadTerabyte.Equals(upload.Mul64(3)) {
+		t.Fatal("estimation does not follow the redundancy of the files:", est)
+	}
+}
+
+// allowanceContractor is a costContractor with a fixed allowance.
+type allowanceContractor struct {
+	costContractor
+	allowance modules.Allowance
+}
+
+func (ac allowanceContractor) Allowance() modules.Allowance { return ac.allowance }
+
+// randomCostHostDB is a costHostDB whose RandomHosts returns a fixed set of
+// hosts.
+type randomCostHostDB struct {
+	costHostDB
+	random []modules.HostDBEntry
+}
+
+func (rh randomCostHostDB) RandomHosts(int, []types.SiaPublicKey) []modules.HostDBEntry {
+	return rh.random
+}
+
+// TestRenterPricesContracts checks that the price estimation uses the prices
+// of the hosts the renter has contracts with, and the number of hosts of the
+// allowance, once it has contracts.
+func TestRenterPricesContracts(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Random hosts are cheap, the host of the contract is expensive.
+	random := modules.HostDBEntry{}
+	random.ContractPrice = types.SiacoinPrecision
+	random.StoragePrice = types.SiacoinPrecision
+	contracted := modules.HostDBEntry{PublicKey: types.SiaPublicKey{Key: []byte("contracted")}}
+	contracted.ContractPrice = types.SiacoinPrecision.Mul64(2)
+	contracted.StoragePrice = types.SiacoinPrecision.Mul64(2)
+	hdb := randomCostHostDB{
+		costHostDB: costHostDB{hosts: map[string]modules.HostDBEntry{contracted.PublicKey.String(): contracted}},
+		random:     []modules.HostDBEntry{random},
+	}
+	ac := allowanceContractor{costContractor: costContractor{
+		hostContractor: rt.renter.hostContractor,
+		contracts:      make(map[types.FileContractID]modules.RenterContract),
+	}}
+	id := rt.renter.mu.Lock()
+	rt.renter.hostDB = hdb
+	rt.renter.hostContractor = ac
+	rt.renter.mu.Unlock(id)
+	_, feePerByte := rt.tpool.FeeEstimation()
+	storage := types.SiacoinPrecision.Mul(modules.BlockBytesPerMonthTerabyte)
+	redundancy := uint64(defaultDataPieces+defaultParityPieces) / uint64(defaultDataPieces)
+
+	// Without contracts, random hosts are sampled.
+	est := rt.renter.PriceEstimation()
+	formContracts := types.SiacoinPrecision.Add(feePerByte.Mul64(1000)).Mul64(uint64(priceEstimationScope))
+	if !est.StorageTerabyteMonth.Equals(storage.Mul64(redundancy)) || !est.FormContracts.Equals(formContracts) {
+		t.Fatal("estimation does not use the random hosts:", est)
+	}
+
+	// With a contract, the prices of its host and the allowance are used.
+	ac.contracts[types.FileContractID{1}] = modules.RenterContract{
+		ID:            types.FileContractID{1},
+		HostPublicKey: contracted.PublicKey,
+		GoodForUpload: true,
+	}
+	ac.allowance.Hosts = 3
+	id = rt.renter.mu.Lock()
+	rt.renter.hostContractor = ac
+	rt.renter.lastEstimation = modules.RenterPriceEstimation{}
+	rt.renter.mu.Unlock(id)
+	est = rt.renter.PriceEstimation()
+	formContracts = types.SiacoinPrecision.Mul64(2).Add(feePerByte.Mul64(1000)).Mul64(3)
+	if !est.StorageTerabyteMonth.Equals(storage.Mul64(2*redundancy)) || !est.FormContracts.Equals(formContracts) {
+		t.Fatal("estimation does not use the contracts:", est)
+	}
+}
+
+// countingPricesStub is a pricesStub that counts how often hosts are
+// requested, taking a short time for each request.
+type countingPricesStub struct {
+	pricesStub
+	calls uint64
+}
+
+func (cps *countingPricesStub) RandomHosts(n int, exclude []types.SiaPublicKey) []modules.HostDBEntry {
+	atomic.AddUint64(&cps.calls, 1)
+	time.Sleep(100 * time.Millisecond)
+	return cps.pricesStub.RandomHosts(n, exclude)
+}
+
+// TestRenterPricesSingleFlight checks that concurrent callers of
+// PriceEstimation share a single estimation when the cache is empty.
+func TestRenterPricesSingleFlight(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	dbe := modules.HostDBEntry{}
+	dbe.ContractPrice = types.SiacoinPrecision
+	dbe.DownloadBandwidthPrice = types.SiacoinPrecision
+	dbe.StoragePrice = types.SiacoinPrecision
+	dbe.UploadBandwidthPrice = types.SiacoinPrecision
+	hdb := &countingPricesStub{pricesStub: pricesStub{dbEntries: []modules.HostDBEntry{dbe}}}
+	id := rt.renter.mu.Lock()
+	rt.renter.hostDB = hdb
+	rt.renter.mu.Unlock(id)
+
+	const callers = 10
+	estimations := make([]modules.RenterPriceEstimation, callers)
+	var wg sync.WaitGroup
+	for i := range estimations {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			estimations[i] = rt.renter.PriceEstimation()
+		}(i)
+	}
+	wg.Wait()
+
+	if calls := atomic.LoadUint64(&hdb.calls); calls != 1 {
+		t.Fatal("expected hosts to be gathered once, got", calls)
+	}
+	for _, est := range estimations {
+		if reflect.DeepEqual(est, modules.RenterPriceEstimation{}) || !reflect.DeepEqual(est, estimations[0]) {
+			t.Fatal("callers received different estimations:", estimations)
+		}
+	}
+}
+
+// TestRenterPricesInvalidation checks that an estimation that is in progress
+// while the cache is invalidated is not cached.
+func TestRenterPricesInvalidation(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	dbe := modules.HostDBEntry{}
+	dbe.ContractPrice = types.SiacoinPrecision
+	dbe.DownloadBandwidthPrice = types.SiacoinPrecision
+	dbe.StoragePrice = types.SiacoinPrecision
+	dbe.UploadBandwidthPrice = types.SiacoinPrecision
+	hdb := &countingPricesStub{pricesStub: pricesStub{dbEntries: []modules.HostDBEntry{dbe}}}
+	id := rt.renter.mu.Lock()
+	rt.renter.hostDB = hdb
+	rt.renter.invalidateEstimation()
+	rt.renter.mu.Unlock(id)
+
+	// Invalidate the cache while the hosts are gathered.
+	done := make(chan struct{})
+	go func() {
+		rt.renter.PriceEstimation()
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	id = rt.renter.mu.Lock()
+	rt.renter.invalidateEstimation()
+	rt.renter.mu.Unlock(id)
+	<-done
+
+	id = rt.renter.mu.RLock()
+	lastEstimation := rt.renter.lastEstimation
+	rt.renter.mu.RUnlock(id)
+	if !reflect.DeepEqual(lastEstimation, modules.RenterPriceEstimation{}) {
+		t.Fatal("estimation from before the invalidation was cached:", lastEstimation)
+	}
+	rt.renter.PriceEstimation()
+	if calls := atomic.LoadUint64(&hdb.calls); calls != 2 {
+		t.Fatal("expected hosts to be gathered twice, got", calls)
+	}
+}
+
+// TestRenterShutdown checks that the renter reports that it is shutting down
+// once Close has been called, and that its methods return ErrRenterShutdown.
+func TestRenterShutdown(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	if rt.renter.IsShuttingDown() {
+		t.Fatal("renter should not be shutting down before Close is called")
+	}
+	if err := rt.renter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !rt.renter.IsShuttingDown() {
+		t.Fatal("renter should be shutting down after Close is called")
+	}
+
+	if err := rt.renter.Upload(modules.FileUploadParams{SiaPath: "foo"}); err != ErrRenterShutdown {
+		t.Fatal("expected ErrRenterShutdown from Upload, got", err)
+	}
+	if err := rt.renter.Download(modules.RenterDownloadParameters{Siapath: "foo"}); err != ErrRenterShutdown {
+		t.Fatal("expected ErrRenterShutdown from Download, got", err)
+	}
+	if err := rt.renter.DeleteFile("foo"); err != ErrRenterShutdown {
+		t.Fatal("expected ErrRenterShutdown from DeleteFile, got", err)
+	}
+	if err := rt.renter.RenameFile("foo", "bar"); err != ErrRenterShutdown {
+		t.Fatal("expected ErrRenterShutdown from RenameFile, got", err)
+	}
+	if _, err := rt.renter.LoadSharedFilesAscii(""); err != ErrRenterShutdown {
+		t.Fatal("expected ErrRenterShutdown from LoadSharedFilesAscii, got", err)
+	}
+	if err := rt.renter.SetSettings(rt.renter.Settings()); err != ErrRenterShutdown {
+		t.Fatal("expected ErrRenterShutdown from SetSettings, got", err)
+	}
+}
+
+// costContractor is a hostContractor with a fixed set of contracts, some of
+// which may be offline.
+type costContractor struct {
+	hostContractor
+	contracts map[types.FileContractID]modules.RenterContract
+	offline   map[types.FileContractID]bool
+}
+
+func (cc costContractor) ResolveContract(id types.FileContractID) (modules.RenterContract, bool) {
+	c, ok := cc.contracts[id]
+	return c, ok
+}
+func (cc costContractor) IsOffline(id types.FileContractID) bool { return cc.offline[id] }
+func (cc costContractor) Contracts() []modules.RenterContract {
+	var contracts []modules.RenterContract
+	for _, c := range cc.contracts {
+		contracts = append(contracts, c)
+	}
+	return contracts
+}
+
+// costHostDB is a hostDB with a fixed set of hosts.
+type costHostDB struct {
+	stubHostDB
+	hosts map[string]modules.HostDBEntry
+}
+
+func (ch costHostDB) Host(spk types.SiaPublicKey) (modules.HostDBEntry, bool) {
+	h, ok := ch.hosts[spk.String()]
+	return h, ok
+}
+
+// TestRenterDownloadCostEstimate checks that the download cost estimate of a
+// file scales with its size, and that chunks stored only on offline hosts are
+// flagged as uncostable.
+func TestRenterDownloadCostEstimate(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Create an online and an offline host, each with a contract.
+	online := types.SiaPublicKey{Key: []byte("online")}
+	offline := types.SiaPublicKey{Key: []byte("offline")}
+	price := types.NewCurrency64(10)
+	cc := costContractor{
+		hostContractor: rt.renter.hostContractor,
+		contracts: map[types.FileContractID]modules.RenterContract{
+			{1}: {ID: types.FileContractID{1}, HostPublicKey: online},
+			{2}: {ID: types.FileContractID{2}, HostPublicKey: offline},
+		},
+		offline: map[types.FileContractID]bool{{2}: true},
+	}
+	hdb := costHostDB{hosts: make(map[string]modules.HostDBEntry)}
+	for _, spk := range []types.SiaPublicKey{online, offline} {
+		var h modules.HostDBEntry
+		h.PublicKey = spk
+		h.DownloadBandwidthPrice = price
+		hdb.hosts[spk.String()] = h
+	}
+	id := rt.renter.mu.Lock()
+	rt.renter.hostContractor = cc
+	rt.renter.hostDB = hdb
+	rt.renter.mu.Unlock(id)
+
+	// addFile adds a file with 'numChunks' chunks whose pieces are all stored
+	// under the contract 'fcid'.
+	rsc, _ := NewRSCode(1, 1)
+	addFile := func(name string, numChunks uint64, fcid types.FileContractID) {
+		f := newFile(name, rsc, modules.SectorSize, numChunks*modules.SectorSize)
+		fc := fileContract{ID: fcid}
+		for i := uint64(0); i < f.numChunks(); i++ {
+			fc.Pieces = append(fc.Pieces, pieceData{Chunk: i, Piece: 0})
+		}
+		f.contracts[fcid] = fc
+		id := rt.renter.mu.Lock()
+		rt.renter.files[name] = f
+		rt.renter.mu.Unlock(id)
+	}
+	addFile("small", 2, types.FileContractID{1})
+	addFile("large", 4, types.FileContractID{1})
+	addFile("offline", 2, types.FileContractID{2})
+
+	small, err := rt.renter.DownloadCostEstimate("small")
+	if err != nil {
+		t.Fatal(err)
+	}
+	large, err := rt.renter.DownloadCostEstimate("large")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if small.Cost.IsZero() || small.UncostableChunks != 0 {
+		t.Fatal("expected a complete estimate for the small file, got", small)
+	}
+	if large.Cost.Cmp(small.Cost.Mul64(2)) != 0 {
+		t.Fatalf("expected the estimate to scale with file size: %v vs %v", small.Cost, large.Cost)
+	}
+
+	// A file that is only stored on an offline host cannot be costed.
+	est, err := rt.renter.DownloadCostEstimate("offline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !est.Cost.IsZero() || est.UncostableChunks != 2 {
+		t.Fatal("expected both chunks of the offline file to be uncostable, got", est)
+	}
+
+	if _, err := rt.renter.DownloadCostEstimate("missing"); err != ErrUnknownPath {
+		t.Fatal("expected ErrUnknownPath, got", err)
+	}
+}
+
+// TestRenterFileSpending checks that contract spending is attributed to files
+// in proportion to the number of their pieces stored under each contract.
+func TestRenterFileSpending(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Create a contract holding four sectors.
+	host := types.SiaPublicKey{Key: []byte("host")}
+	contract := modules.RenterContract{
+		ID:              types.FileContractID{1},
+		HostPublicKey:   host,
+		StorageSpending: types.NewCurrency64(400),
+		UploadSpending:  types.NewCurrency64(40),
+		TxnFee:          types.NewCurrency64(4),
+		ContractFee:     types.NewCurrency64(4),
+	}
+	contract.LastRevision.NewFileSize = 4 * modules.SectorSize
+	cc := costContractor{
+		hostContractor: rt.renter.hostContractor,
+		contracts:      map[types.FileContractID]modules.RenterContract{{1}: contract},
+	}
+	var h modules.HostDBEntry
+	h.PublicKey = host
+	h.StoragePrice = types.NewCurrency64(1)
+	hdb := costHostDB{hosts: map[string]modules.HostDBEntry{host.String(): h}}
+	id := rt.renter.mu.Lock()
+	rt.renter.hostContractor = cc
+	rt.renter.hostDB = hdb
+	rt.renter.mu.Unlock(id)
+
+	// Add a file with one of the contract's four sectors.
+	rsc, _ := NewRSCode(1, 1)
+	f := newFile("file", rsc, modules.SectorSize, modules.SectorSize)
+	f.contracts[contract.ID] = fileContract{ID: contract.ID, Pieces: []pieceData{{Chunk: 0, Piece: 0}}}
+	id = rt.renter.mu.Lock()
+	rt.renter.files["file"] = f
+	rt.renter.mu.Unlock(id)
+
+	fs, err := rt.renter.FileSpending("file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fs.StorageSpending.Cmp64(100) != 0 || fs.UploadSpending.Cmp64(10) != 0 || fs.FeeSpending.Cmp64(2) != 0 {
+		t.Fatal("spending was not attributed by piece count:", fs)
+	}
+	if fs.Total.Cmp64(112) != 0 {
+		t.Fatal("wrong total spending:", fs.Total)
+	}
+	if fs.MonthlyCost.Cmp(h.StoragePrice.Mul64(modules.SectorSize).Mul64(blocksPerMonth)) != 0 {
+		t.Fatal("wrong monthly cost:", fs.MonthlyCost)
+	}
+
+	if _, err := rt.renter.FileSpending("missing"); err != ErrUnknownPath {
+		t.Fatal("expected ErrUnknownPath, got", err)
+	}
+}
+
+// TestRenterContractExpirations checks that mining blocks updates the cached
+// number of blocks remaining for each contract.
+func TestRenterContractExpirations(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Add a contract that ends two blocks from now.
+	pc := &parallelContractor{
+		hostContractor: rt.renter.hostContractor,
+		contract: modules.RenterContract{
+			ID: types.FileContractID{1},
+			LastRevision: types.FileContractRevision{
+				NewWindowStart: rt.cs.Height() + 2,
+			},
+		},
+	}
+	id := rt.renter.mu.Lock()
+	rt.renter.hostContractor = pc
+	rt.renter.mu.Unlock(id)
+	rt.renter.managedUpdateContractExpirations()
+
+	expiry := rt.renter.ContractExpirations()[pc.contract.ID]
+	if expiry.BlocksRemaining != 2 || expiry.ExpiryWarning {
+		t.Fatal("unexpected expiry before mining:", expiry)
+	}
+
+	// Each block should decrement the number of blocks remaining.
+	if _, err := rt.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	expiry = rt.renter.ContractExpirations()[pc.contract.ID]
+	if expiry.BlocksRemaining != 1 || expiry.ExpiryWarning {
+		t.Fatal("unexpected expiry after mining one block:", expiry)
+	}
+	if _, err := rt.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	expiry = rt.renter.ContractExpirations()[pc.contract.ID]
+	if expiry.BlocksRemaining != 0 || !expiry.ExpiryWarning {
+		t.Fatal("unexpected expiry after the contract ended:", expiry)
+	}
+
+	id = rt.renter.mu.RLock()
+	height := rt.renter.blockHeight
+	rt.renter.mu.RUnlock(id)
+	if height != rt.cs.Height() {
+		t.Fatalf("renter height %v does not match consensus height %v", height, rt.cs.Height())
+	}
+}
+
+// TestRenterMonthlyCostProjection checks that the monthly cost projection only
+// includes the contract fees when no data is stored, and that the storage and
+// upload costs scale with the amount of stored data.
+func TestRenterMonthlyCostProjection(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Use a single host with known prices and a contract lasting one month.
+	spk := types.SiaPublicKey{Key: []byte("host")}
+	cc := costContractor{
+		hostContractor: rt.renter.hostContractor,
+		contracts: map[types.FileContractID]modules.RenterContract{
+			{1}: {
+				ID:            types.FileContractID{1},
+				HostPublicKey: spk,
+				StartHeight:   10,
+				LastRevision:  types.FileContractRevision{NewWindowStart: 10 + blocksPerMonth},
+				ContractFee:   types.NewCurrency64(1000),
+			},
+		},
+	}
+	var h modules.HostDBEntry
+	h.PublicKey = spk
+	h.StoragePrice = types.NewCurrency64(2)
+	h.UploadBandwidthPrice = types.NewCurrency64(3)
+	hdb := costHostDB{hosts: map[string]modules.HostDBEntry{spk.String(): h}}
+	id := rt.renter.mu.Lock()
+	rt.renter.hostContractor = cc
+	rt.renter.hostDB = hdb
+	rt.renter.mu.Unlock(id)
+
+	// Without any files, only the contract fees are projected.
+	proj := rt.renter.MonthlyCostProjection()
+	if !proj.ContractCost.Equals(types.NewCurrency64(1000)) {
+		t.Fatal("expected the contract fee for one month, got", proj.ContractCost)
+	}
+	if !proj.StorageCost.IsZero() || !proj.UploadCost.IsZero() || !proj.Total.Equals(proj.ContractCost) {
+		t.Fatal("expected only contract costs without any files, got", proj)
+	}
+
+	// Storage and upload costs scale with the amount of stored data.
+	addFile := func(name string) {
+		rsc, _ := NewRSCode(1, 1)
+		f := newFile(name, rsc, modules.SectorSize, 4*modules.SectorSize)
+		id := rt.renter.mu.Lock()
+		rt.renter.files[f.name] = f
+		rt.renter.mu.Unlock(id)
+	}
+	addFile("one")
+	one := rt.renter.MonthlyCostProjection()
+	addFile("two")
+	two := rt.renter.MonthlyCostProjection()
+	if one.StorageCost.IsZero() || one.UploadCost.IsZero() {
+		t.Fatal("expected storage and upload costs for a stored file, got", one)
+	}
+	if !two.StorageCost.Equals(one.StorageCost.Mul64(2)) || !two.UploadCost.Equals(one.UploadCost.Mul64(2)) {
+		t.Fatalf("expected costs to double with twice the data: %v vs %v", one, two)
+	}
+	if !two.ContractCost.Equals(one.ContractCost) {
+		t.Fatal("contract costs should not depend on the amount of data")
+	}
+}
+
+// activeHostDB is a hostDB with a fixed set of active hosts.
+type activeHostDB struct {
+	stubHostDB
+	hosts []modules.HostDBEntry
+}
+
+func (ah activeHostDB) ActiveHosts() []modules.HostDBEntry { return ah.hosts }
+
+// TestRenterUncontractedHosts checks that the active hosts without a contract
+// are reported as uncontracted.
+func TestRenterUncontractedHosts(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Create four active hosts and form contracts with the first two.
+	var ah activeHostDB
+	for i := 0; i < 4; i++ {
+		var h modules.HostDBEntry
+		h.PublicKey = types.SiaPublicKey{Key: []byte{byte(i)}}
+		ah.hosts = append(ah.hosts, h)
+	}
+	cc := costContractor{
+		hostContractor: rt.renter.hostContractor,
+		contracts: map[types.FileContractID]modules.RenterContract{
+			{1}: {ID: types.FileContractID{1}, HostPublicKey: ah.hosts[0].PublicKey},
+			{2}: {ID: types.FileContractID{2}, HostPublicKey: ah.hosts[1].PublicKey},
+		},
+	}
+	id := rt.renter.mu.Lock()
+	rt.renter.hostContractor = cc
+	rt.renter.hostDB = ah
+	rt.renter.mu.Unlock(id)
+
+	hosts := rt.renter.UncontractedHosts()
+	if len(hosts) != 2 {
+		t.Fatal("expected 2 uncontracted hosts, got", len(hosts))
+	}
+	for i, h := range hosts {
+		if h.PublicKey.String() != ah.hosts[i+2].PublicKey.String() {
+			t.Fatal("unexpected uncontracted host:", h.PublicKey)
+		}
+	}
+}
+
+// TestRenterMaxMemory checks that the renter's memory limit can be changed
+// while memory is in use, and that it is persisted.
+func TestRenterMaxMemory(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Allocate more memory than the new limit allows, then lower the limit.
+	// No memory should be available until the excess has been released.
+	inUse := defaultMemory * 3 / 4
+	rt.renter.managedMemoryAvailableSub(inUse, memoryEncoding, memoryClassUserUpload)
+	settings := rt.renter.Settings()
+	settings.MaxMemory = defaultMemory / 2
+	if err := rt.renter.SetSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+	if mem := rt.renter.MemoryBreakdown(); mem.Total != defaultMemory/2 || mem.Available != 0 {
+		t.Fatal("unexpected memory after lowering the limit:", mem)
+	}
+	rt.renter.managedMemoryAvailableAdd(defaultMemory/4, memoryEncoding, memoryClassUserUpload)
+	if avail := rt.renter.managedMemoryAvailableGet(memoryClassUserUpload); avail != 0 {
+		t.Fatal("expected no memory to be available while over the limit, got", avail)
+	}
+	rt.renter.managedMemoryAvailableAdd(inUse-defaultMemory/4, memoryEncoding, memoryClassUserUpload)
+	if avail := rt.renter.managedMemoryAvailableGet(memoryClassUserUpload); avail != defaultMemory/2 {
+		t.Fatal("expected the full limit to be available, got", avail)
+	}
+
+	// The limit should survive a reload.
+	id := rt.renter.mu.Lock()
+	rt.renter.maxMemory = 0
+	err = rt.renter.load()
+	rt.renter.mu.Unlock(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if max := rt.renter.Settings().MaxMemory; max != defaultMemory/2 {
+		t.Fatal("expected the memory limit to be persisted, got", max)
+	}
+
+	// Zero restores the default.
+	settings.MaxMemory = 0
+	if err := rt.renter.SetSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+	if avail := rt.renter.managedMemoryAvailableGet(memoryClassUserUpload); avail != defaultMemory {
+		t.Fatal("expected the default memory to be available, got", avail)
+	}
+}
+
+// TestSetSettingsInvalid checks that SetSettings does not change any setting
+// if one of them is invalid.
+func TestSetSettingsInvalid(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Change several settings along with an allowance that has no hosts.
+	before := rt.renter.Settings()
+	settings := before
+	settings.MaxUploadSpeed = 1 << 20
+	settings.MaxRepairAttempts = 7
+	settings.HostGracePeriod = 3600
+	settings.Allowance = modules.Allowance{
+		Funds:       types.SiacoinPrecision,
+		Period:      10,
+		RenewWindow: 5,
+	}
+	if err := rt.renter.SetSettings(settings); err == nil {
+		t.Fatal("expected an allowance without hosts to be rejected")
+	}
+	if after := rt.renter.Settings(); !reflect.DeepEqual(after, before) {
+		t.Fatalf("settings changed after an invalid update:\n%+v\n%+v", before, after)
+	}
+
+	// The same is true for the settings that are checked by the renter.
+	settings = before
+	settings.MaxUploadSpeed = 1 << 20
+	settings.DownloadVerification = "bogus"
+	if err := rt.renter.SetSettings(settings); err != errUnknownVerification {
+		t.Fatal("expected errUnknownVerification, got", err)
+	}
+	if after := rt.renter.Settings(); !reflect.DeepEqual(after, before) {
+		t.Fatalf("settings changed after an invalid update:\n%+v\n%+v", before, after)
+	}
+}
+
+// TestMemoryClasses checks that the memory reserved for a class can't be
+// allocated by the other classes, and that usage is reported per class.
+func TestMemoryClasses(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+	r := rt.renter
+	reserve := uint64(float64(defaultMemory) * memoryClassReserves[memoryClassUserUpload])
+	if reserve != uint64(float64(defaultMemory)*memoryClassReserves[memoryClassUserDownload]) {
+		t.Fatal("test assumes that uploads and downloads reserve the same amount of memory")
+	}
+
+	// Once repairs hold memory, they can't allocate the reserves of uploads
+	// and downloads.
+	if !r.managedMemoryTryReserve(1, memoryEncoding, memoryClassRepair) {
+		t.Fatal("a class holding no memory should be able to allocate memory")
+	}
+	repairAvail := r.managedMemoryAvailableGet(memoryClassRepair)
+	if repairAvail != defaultMemory-1-2*reserve {
+		t.Fatal("wrong memory available to repairs:", repairAvail)
+	}
+	if r.managedMemoryTryReserve(repairAvail+1, memoryEncoding, memoryClassRepair) {
+		t.Fatal("repairs allocated the reserves of other classes")
+	}
+	if !r.managedMemoryTryReserve(repairAvail, memoryEncoding, memoryClassRepair) {
+		t.Fatal("repairs could not allocate the unreserved memory")
+	}
+
+	// Downloads can allocate their reserve, but not the reserve of uploads.
+	if avail := r.managedMemoryAvailableGet(memoryClassUserDownload); avail != 2*reserve {
+		t.Fatal("wrong memory available to downloads:", avail)
+	}
+	if !r.managedMemoryTryReserve(reserve, memoryDownloadSectors, memoryClassUserDownload) {
+		t.Fatal("downloads could not allocate their reserve")
+	}
+	if r.managedMemoryTryReserve(1, memoryDownloadSectors, memoryClassUserDownload) {
+		t.Fatal("downloads allocated the reserve of uploads")
+	}
+	if avail := r.managedMemoryAvailableGet(memoryClassUserUpload); avail != reserve {
+		t.Fatal("wrong memory available to uploads:", avail)
+	}
+
+	mb := r.MemoryBreakdown()
+	if mb.Repairs.InUse != repairAvail+1 || mb.UserDownloads.InUse != reserve || mb.UserUploads.InUse != 0 {
+		t.Fatal("wrong memory usage per class:", mb)
+	}
+	if mb.UserUploads.Reserved != reserve || mb.UserDownloads.Reserved != reserve {
+		t.Fatal("wrong reserves:", mb)
+	}
+
+	// Released memory is available to every class again.
+	r.managedMemoryAvailableAdd(repairAvail+1, memoryEncoding, memoryClassRepair)
+	r.managedMemoryAvailableAdd(reserve, memoryDownloadSectors, memoryClassUserDownload)
+	if avail := r.managedMemoryAvailableGet(memoryClassRepair); avail != defaultMemory {
+		t.Fatal("expected all memory to be available, got", avail)
+	}
+}
+
+// TestRenterDrainChunks checks that the renter waits for in-flight chunks
+// before shutting down, up to a timeout, and that no new chunks are started
+// while it is draining.
+func TestRenterDrainChunks(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+	r := rt.renter
+
+	// Draining should wait until the in-flight chunk is done.
+	r.heapWG.Add(1)
+	drained := make(chan bool)
+	go func() {
+		drained <- r.managedDrainChunks(time.Minute)
+	}()
+	select {
+	case <-drained:
+		t.Fatal("drain returned while a chunk was in flight")
+	case <-time.After(100 * time.Millisecond):
+	}
+	r.heapWG.Done()
+	select {
+	case ok := <-drained:
+		if !ok {
+			t.Fatal("drain reported a timeout")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("drain did not return once the chunk was done")
+	}
+
+	// Draining gives up after the timeout.
+	r.heapWG.Add(1)
+	if r.managedDrainChunks(10 * time.Millisecond) {
+		t.Fatal("drain did not time out")
+	}
+	r.heapWG.Done()
+
+	// No new chunks are started once the renter is draining.
+	r.drainOnce.Do(func() { close(r.drainChan) })
+	if !r.isDraining() {
+		t.Fatal("renter is not draining")
+	}
+	if r.managedEvacuateChunk(&unfinishedChunk{}) {
+		t.Fatal("chunk was started while draining")
+	}
+}
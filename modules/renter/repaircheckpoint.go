@@ -0,0 +1,120 @@
+package renter
+
+// Repair passes can take a long time on large datasets. To avoid starting over
+// after a restart, the renter checkpoints every chunk that has been fully
+// repaired during the current pass. Checkpointed chunks are skipped when the
+// chunk heap is built, until the pass completes and the checkpoints are
+// cleared.
+//
+// A checkpoint only applies to the version of the file that it was created
+// for. Its fingerprint covers the renter's metadata of the file as well as the
+// size and modification time of the local copy, so a file that changes
+// between runs is repaired from scratch.
+//
+// Checkpoints are kept in memory as chunks complete, and are saved to disk at
+// most once every repairCheckpointSaveInterval, as well as on shutdown. A
+// crash loses at most the checkpoints of the last interval, which only means
+// that those chunks are checked again.
+
+import (
+	"os"
+	"time"
+
+	"github.com/pachisi456/Sia/crypto"
+)
+
+// A repairCheckpoint records which chunks of a file have been repaired during
+// the current repair pass.
+type repairCheckpoint struct {
+	Fingerprint crypto.Hash
+	Chunks      map[uint64]struct{}
+}
+
+// repairFingerprint returns the fingerprint of the current version of a file.
+// The file's lock must be held.
+func repairFingerprint(f *file, repairPath string) crypto.Hash {
+	var localSize, localModTime int64
+	if info, err := os.Stat(repairPath); err == nil {
+		localSize = info.Size()
+		localModTime = info.ModTime().UnixNano()
+	}
+	return crypto.HashAll(f.masterKey, f.size, f.pieceSize, localSize, localModTime)
+}
+
+// checkpointedChunks returns the chunks of 'f' that have been repaired during
+// the current pass. The checkpoint of an older version of the file is
+// dropped. The renter's lock and the file's lock must be held.
+func (r *Renter) checkpointedChunks(f *file, fingerprint crypto.Hash) map[uint64]struct{} {
+	cp, exists := r.repairCheckpoints[f.name]
+	if !exists {
+		return nil
+	}
+	if cp.Fingerprint != fingerprint {
+		delete(r.repairCheckpoints, f.name)
+		return nil
+	}
+	return cp.Chunks
+}
+
+// managedCheckpointChunk records that a chunk has been fully repaired. The
+// checkpoint is saved with the next batch.
+func (r *Renter) managedCheckpointChunk(uc *unfinishedChunk) {
+	id := r.mu.Lock()
+	defer r.mu.Unlock(id)
+	uc.renterFile.mu.RLock()
+	name := uc.renterFile.name
+	uc.renterFile.mu.RUnlock()
+
+	cp, exists := r.repairCheckpoints[name]
+	if !exists || cp.Fingerprint != uc.fingerprint {
+		cp = repairCheckpoint{
+			Fingerprint: uc.fingerprint,
+			Chunks:      make(map[uint64]struct{}),
+		}
+		r.repairCheckpoints[name] = cp
+	}
+	cp.Chunks[uc.index] = struct{}{}
+	r.repairCheckpointsDirty = true
+	if time.Since(r.lastCheckpointSave) < repairCheckpointSaveInterval {
+		return
+	}
+	if err := r.saveRepairCheckpoints(); err != nil {
+		r.log.Println("WARN: unable to save repair checkpoints:", err)
+	}
+}
+
+// managedSaveRepairCheckpoints saves the checkpoints that have not been saved
+// yet. It is called on shutdown.
+func (r *Renter) managedSaveRepairCheckpoints() error {
+	id := r.mu.Lock()
+	defer r.mu.Unlock(id)
+	if !r.repairCheckpointsDirty {
+		return nil
+	}
+	return r.saveRepairCheckpoints()
+}
+
+// saveRepairCheckpoints saves the renter's persistent data, including the
+// checkpoints, and starts a new batch. The renter's lock must be held.
+func (r *Renter) saveRepairCheckpoints() error {
+	r.lastCheckpointSave = time.Now()
+	if err := r.saveSync(); err != nil {
+		return err
+	}
+	r.repairCheckpointsDirty = false
+	return nil
+}
+
+// managedClearRepairCheckpoints drops all checkpoints. It is called once a
+// repair pass has completed.
+func (r *Renter) managedClearRepairCheckpoints() {
+	id := r.mu.Lock()
+	defer r.mu.Unlock(id)
+	if len(r.repairCheckpoints) == 0 {
+		return
+	}
+	r.repairCheckpoints = make(map[string]repairCheckpoint)
+	if err := r.saveRepairCheckpoints(); err != nil {
+		r.log.Println("WARN: unable to clear repair checkpoints:", err)
+	}
+}
@@ -0,0 +1,124 @@
+package renter
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pachisi456/Sia/build"
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/persist"
+)
+
+// TestRepairCheckpoint checks that chunks repaired before an interruption are
+// skipped when the repair pass resumes, and that the checkpoint is discarded
+// once the file changes.
+func TestRepairCheckpoint(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Create a tracked file with several chunks and a local copy.
+	const numChunks = 4
+	rsc, _ := NewRSCode(1, 1)
+	f := newFile("checkpoint", rsc, modules.SectorSize, numChunks*modules.SectorSize)
+	localPath := filepath.Join(build.TempDir("renter", t.Name()), "checkpoint")
+	if err := ioutil.WriteFile(localPath, make([]byte, f.size), 0600); err != nil {
+		t.Fatal(err)
+	}
+	id := rt.renter.mu.Lock()
+	rt.renter.files[f.name] = f
+	rt.renter.tracking[f.name] = trackedFile{RepairPath: localPath}
+	rt.renter.mu.Unlock(id)
+
+	// unfinishedChunks returns the chunks of the file that need repair.
+	unfinishedChunks := func() []*unfinishedChunk {
+		id := rt.renter.mu.Lock()
+		defer rt.renter.mu.Unlock(id)
+		return rt.renter.buildUnfinishedChunks(f, nil)
+	}
+	chunks := unfinishedChunks()
+	if len(chunks) != numChunks {
+		t.Fatalf("expected %v unfinished chunks, got %v", numChunks, len(chunks))
+	}
+
+	// savedCheckpoint returns the checkpoint of the file that is saved on
+	// disk.
+	savedCheckpoint := func() repairCheckpoint {
+		var data struct {
+			RepairCheckpoints map[string]repairCheckpoint
+		}
+		err := persist.LoadJSON(saveMetadata, &data, filepath.Join(rt.renter.persistDir, PersistFilename))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data.RepairCheckpoints[f.name]
+	}
+
+	// Repair the first two chunks. The first checkpoint is saved right away,
+	// the second one is batched until the next save.
+	rt.renter.managedCheckpointChunk(chunks[0])
+	rt.renter.managedCheckpointChunk(chunks[1])
+	if n := len(savedCheckpoint().Chunks); n != 1 {
+		t.Fatal("expected 1 saved checkpoint before the batch is saved, got", n)
+	}
+
+	// Simulate a restart by saving the batch and reloading the renter's
+	// persisted state.
+	if err := rt.renter.managedSaveRepairCheckpoints(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(savedCheckpoint().Chunks); n != 2 {
+		t.Fatal("expected 2 saved checkpoints after the batch is saved, got", n)
+	}
+	id = rt.renter.mu.Lock()
+	rt.renter.repairCheckpoints = make(map[string]repairCheckpoint)
+	err = rt.renter.load()
+	rt.renter.mu.Unlock(id)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+
+	// The resumed pass should skip the repaired chunks.
+	chunks = unfinishedChunks()
+	if len(chunks) != numChunks-2 {
+		t.Fatalf("expected %v unfinished chunks after resuming, got %v", numChunks-2, len(chunks))
+	}
+	for _, uc := range chunks {
+		if uc.index < 2 {
+			t.Fatal("resumed repair pass includes repaired chunk", uc.index)
+		}
+	}
+
+	// Changing the local file invalidates the checkpoint.
+	if err := ioutil.WriteFile(localPath, make([]byte, f.size/2), 0600); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(localPath, future, future); err != nil {
+		t.Fatal(err)
+	}
+	if chunks = unfinishedChunks(); len(chunks) != numChunks {
+		t.Fatalf("expected %v unfinished chunks after the file changed, got %v", numChunks, len(chunks))
+	}
+	id = rt.renter.mu.RLock()
+	_, exists := rt.renter.repairCheckpoints[f.name]
+	rt.renter.mu.RUnlock(id)
+	if exists {
+		t.Fatal("checkpoint of the changed file was not discarded")
+	}
+
+	// Completing the pass clears all checkpoints.
+	rt.renter.managedCheckpointChunk(chunks[0])
+	rt.renter.managedClearRepairCheckpoints()
+	if cp := savedCheckpoint(); len(cp.Chunks) != 0 {
+		t.Fatal("repair checkpoints were not cleared on disk:", cp)
+	}
+}
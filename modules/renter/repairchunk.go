@@ -7,8 +7,10 @@ import (
 	"fmt"
 
 	"github.com/pachisi456/Sia/crypto"
+	"github.com/pachisi456/Sia/modules"
 
 	"github.com/NebulousLabs/errors"
+	"github.com/NebulousLabs/fastrand"
 	"bytes"
 	"strconv"
 	runtime "runtime"
@@ -22,13 +24,34 @@ func (r *Renter) managedDistributeChunkToWorkers(uc *unfinishedChunk) {
 	// renter is holding a lock, so we need to build a list of workers while
 	// under lock and then launch work jobs after that.
 	id := r.mu.RLock()
-	uc.workersRemaining += len(r.workerPool)
-	r.heapWG.Add(len(r.workerPool))
-	workers := make([]*worker, 0, len(r.workerPool))
-	for _, worker := range r.workerPool {
-		workers = append(workers, worker)
+	var workers []*worker
+	for _, contractWorkers := range r.workerPool {
+		workers = append(workers, contractWorkers...)
 	}
+	uc.workersRemaining += len(workers)
+	r.heapWG.Add(len(workers))
 	r.mu.RUnlock(id)
+
+	// Determine which hosts are slow, based on the recent throughput of their
+	// workers. Stuck chunks are offered to the workers in random order
+	// instead, so that they are not tied to the same hosts on every retry.
+	rates := make(map[string]uint64)
+	if uc.stuck {
+		shuffled := make([]*worker, len(workers))
+		for i, j := range fastrand.Perm(len(workers)) {
+			shuffled[i] = workers[j]
+		}
+		workers = shuffled
+	} else {
+		for _, worker := range workers {
+			rates[worker.hostPubKey.String()] += worker.uploadMeter.managedRate()
+		}
+	}
+	uc.mu.Lock()
+	uc.slowHosts = slowHosts(rates)
+	uc.distributedAt = time.Now()
+	uc.mu.Unlock()
+
 	for _, worker := range workers {
 		worker.managedQueueChunkRepair(uc)
 	}
@@ -37,6 +60,25 @@ func (r *Renter) managedDistributeChunkToWorkers(uc *unfinishedChunk) {
 	r.managedReleaseIdleChunkPieces(uc)
 }
 
+// slowHosts returns the hosts whose upload throughput is less than
+// slowHostThroughputRatio of the throughput of the fastest host. Hosts
+// without a recent throughput measurement are not considered slow.
+func slowHosts(rates map[string]uint64) map[string]struct{} {
+	var fastest uint64
+	for _, rate := range rates {
+		if rate > fastest {
+			fastest = rate
+		}
+	}
+	slow := make(map[string]struct{})
+	for host, rate := range rates {
+		if rate > 0 && float64(rate) < float64(fastest)*slowHostThroughputRatio {
+			slow[host] = struct{}{}
+		}
+	}
+	return slow
+}
+
 // managedDownloadLogicalChunkData will fetch the logical chunk data by sending a
 // download to the renter's downloader, and then using the data that gets
 // returned.
@@ -50,10 +92,24 @@ func (r *Renter) managedDownloadLogicalChunkData(chunk *unfinishedChunk) error {
 	// more than download memory, and if we need to allocate two times in a row
 	// from the same memory pool while other processes are asynchronously doing
 	// the same, we risk deadlock.
+	//
+	// Only maxScheduledDownloads chunks are downloaded at once, so that
+	// remote repairs cannot hold all of the renter's memory in downloaded
+	// data while the rest of the upload pipeline waits.
+	select {
+	case r.remoteRepairs <- struct{}{}:
+	case <-r.tg.StopChan():
+		return errors.New("repair download queing interrupted by stop call")
+	}
+	defer func() { <-r.remoteRepairs }()
 	buf := NewDownloadBufferWriter(chunk.length, chunk.offset)
 	// TODO: Should convert the inputs of newSectionDownload to use an int64 for
 	// the offset.
 	d := r.newSectionDownload(chunk.renterFile, buf, uint64(chunk.offset), chunk.length)
+	d.repair = true
+	d.priority = modules.PriorityBackground
+	r.managedMemoryMove(chunk.length, memoryEncoding, memoryDownloadSectors)
+	defer r.managedMemoryMove(chunk.length, memoryDownloadSectors, memoryEncoding)
 	select {
 	case r.newDownloads <- d:
 	case <-r.tg.StopChan():
@@ -77,15 +133,16 @@ func (r *Renter) managedDownloadLogicalChunkData(chunk *unfinishedChunk) error {
 // the physical pieces for the chunk, and then distribute them. The returned
 // bool indicates whether the chunk was successfully distributed to workers.
 func (r *Renter) managedFetchAndRepairChunk(chunk *unfinishedChunk) bool {
-	// Only download this file if more than 25% of the redundancy is missing.
+	// Only download this file if more than 25% of the redundancy is missing,
+	// or if the chunk is being evacuated.
 	minMissingPiecesToDownload := (chunk.piecesNeeded - chunk.minimumPieces) / 4
-	download := chunk.piecesCompleted+minMissingPiecesToDownload < chunk.piecesNeeded
+	download := chunk.evacuation || chunk.piecesCompleted+minMissingPiecesToDownload < chunk.piecesNeeded
 
 	// Fetch the logical data for the chunk.
 	err := r.managedFetchLogicalChunkData(chunk, download)
 	if err != nil {
 		// Logical data is not available, nothing to do.
-		r.log.Debugln("Fetching logical data of a chunk failed:", err)
+		r.dedupLog.Debugln("Fetching logical data of a chunk failed:", err)
 		return false
 	}
 
@@ -99,18 +156,19 @@ func (r *Renter) managedFetchAndRepairChunk(chunk *unfinishedChunk) bool {
 	chunk.physicalChunkData, err = chunk.renterFile.erasureCode.Encode(chunk.logicalChunkData)
 	memoryFreed := uint64(len(chunk.logicalChunkData))
 	chunk.logicalChunkData = nil
-	r.managedMemoryAvailableAdd(memoryFreed)
+	r.managedMemoryAvailableAdd(memoryFreed, memoryEncoding, chunk.memoryClass())
 	chunk.memoryReleased += memoryFreed
 	memoryFreed = 0
 	if err != nil {
 		// Logical data is not available, nothing to do.
-		r.log.Debugln("Fetching physical data of a chunk failed:", err)
+		r.dedupLog.Debugln("Fetching physical data of a chunk failed:", err)
 		return false
 	}
 
 	// measuring performance
 	rsElapsed := time.Since(chProcessingStart)
 	fmt.Println("> REED-SOLOMON ERASURE CODING OF A CHUNK TOOK", rsElapsed, "GOROUTINE ID:", getGID())
+	chunk.traceEvent("encoded")
 
 	// Sanity check - we should have at least as many physical data pieces as we
 	// do elements in our piece usage.
@@ -147,14 +205,21 @@ func (r *Renter) managedFetchAndRepairChunk(chunk *unfinishedChunk) bool {
 
 	// measuring performance
 	fmt.Println("> TWOFISH ENCRYPTION OF ALL PIECES OF A CHUNK TOOK", totalTwofishTime, "GOROUTINE ID:", getGID())
+	chunk.traceEvent("encrypted")
+	chunk.mu.Lock()
+	chunk.erasureCodingTime = rsElapsed
+	chunk.encryptionTime = totalTwofishTime
+	chunk.mu.Unlock()
 
 	// measuring performance
 	chElapsed := time.Since(chProcessingStart)
 	fmt.Println("PROCESSING OF A CHUNK (REED-SOLOMON + TWOFISH) TOOK", chElapsed, "GOROUTINE ID:", getGID())
 
-	// Return the released memory.
-	r.managedMemoryAvailableAdd(memoryFreed)
+	// Return the released memory. The remaining memory holds the pieces that
+	// are waiting to be uploaded.
+	r.managedMemoryAvailableAdd(memoryFreed, memoryEncoding, chunk.memoryClass())
 	chunk.memoryReleased += memoryFreed
+	r.managedMemoryMove(chunk.memoryNeeded-chunk.memoryReleased, memoryEncoding, memoryUploadSectors)
 
 	// Distribute the chunk to the workers.
 	r.managedDistributeChunkToWorkers(chunk)
@@ -167,6 +232,11 @@ func (r *Renter) managedFetchAndRepairChunk(chunk *unfinishedChunk) bool {
 // chunk.data should be passed as 'nil' to the download, to keep memory usage as
 // light as possible.
 func (r *Renter) managedFetchLogicalChunkData(chunk *unfinishedChunk, download bool) error {
+	// Streamed chunks arrive with their logical data.
+	if chunk.logicalChunkData != nil {
+		return nil
+	}
+
 	// Download the chunk if it's not on disk.
 	if chunk.localPath == "" && download {
 		return r.managedDownloadLogicalChunkData(chunk)
@@ -236,9 +306,23 @@ func (r *Renter) managedReleaseIdleChunkPieces(uc *unfinishedChunk) {
 	if uc.workersRemaining == 0 && uc.memoryReleased != uc.memoryNeeded {
 		r.log.Critical("No workers remaining, but not all memory released:", uc.workersRemaining, uc.memoryReleased, uc.memoryNeeded)
 	}
+	finished := uc.workersRemaining == 0 && !uc.finished
+	if finished {
+		uc.finished = true
+	}
 	uc.mu.Unlock()
 	if memoryReleased > 0 {
-		r.managedMemoryAvailableAdd(uint64(memoryReleased))
+		r.managedMemoryAvailableAdd(uint64(memoryReleased), memoryUploadSectors, uc.memoryClass())
+	}
+	if finished {
+		r.uploadHeap.managedDone(uc)
+		r.managedRecordChunkResult(uc)
+		r.managedRecordUploadMetrics(uc)
+		r.managedNotifyUploadProgress(uc)
+		uc.traceFinish()
+		if uc.done != nil {
+			close(uc.done)
+		}
 	}
 }
 
@@ -1,30 +1,30 @@
 package renter
 
-// TODO / NOTE: Once the filesystem is tree-based, instead of continually
-// looping through the whole filesystem we can add values to the file metadata
-// indicating the health of each folder + file, and the time of the last scan
-// for each folder + file, where the folder scan time is the least recent time
-// of any file in the folder, and the folder health is the lowest health of any
-// file in the folder. This will allow us to go one folder at a time and focus
-// on problem areas instead of doing everything all at once every iteration.
-// This should boost scalability.
+// TODO / NOTE: The directory tree is currently rebuilt from the siapaths on
+// every pass, with the folder health being the lowest health of any file in
+// the folder. Instead of continually looping through the whole filesystem we
+// can add values to the file metadata indicating the health of each folder +
+// file, and the time of the last scan for each folder + file, where the folder
+// scan time is the least recent time of any file in the folder. This will
+// allow us to go one folder at a time and focus on problem areas instead of
+// doing everything all at once every iteration. This should boost
+// scalability.
 
 // TODO / NOTE: We need to upgrade the contractor before we can do this, but we
 // need to be checking for every piece within a contract, and checking that the
 // piece is still available in the contract that we have, that the host did not
 // lose or nullify the piece.
 
-// TODO: Need to make sure that we wait for all standing upload jobs to
-// complete in some way before we send off the next round of upload jobs.
-// Otherwise we might end up with simultenously overlapping repair jobs.
-
 import (
 	"container/heap"
+	"context"
+	"os"
 	"sync"
 	"fmt"
 	"time"
 
 	"github.com/pachisi456/Sia/crypto"
+	"github.com/pachisi456/Sia/modules"
 )
 
 // ChunkHeap is a bunch of chunks sorted by percentage-completion for uploading.
@@ -54,6 +54,31 @@ type unfinishedChunk struct {
 	offset         int64
 	piecesNeeded   int // number of pieces to achieve a 100% complete upload
 
+	// repair indicates that the chunk was already recoverable from the network
+	// when it was scheduled, meaning that uploading it restores redundancy
+	// rather than making new data available. Repair uploads are subject to the
+	// renter's repair throttle.
+	repair bool
+
+	// evacuation indicates that the chunk is being moved off a contract. The
+	// chunk is downloaded if it is not available locally, no matter how much
+	// of its redundancy is missing.
+	evacuation bool
+
+	// stuck indicates that the chunk is being retried by the stuck loop. The
+	// pieces of stuck chunks are offered to the workers in random order,
+	// without preferring fast hosts.
+	stuck bool
+
+	// priority is the position of the chunk's file in the repair order of the
+	// directory tree, see dirNode.repairOrder. Lower values are repaired
+	// first among chunks that are equally complete.
+	priority int
+
+	// fingerprint identifies the version of the file that the chunk belongs
+	// to, see repairFingerprint.
+	fingerprint crypto.Hash
+
 	// The logical data is the data that is presented to the user when the user
 	// requests the chunk. The physical data is all of the pieces that get
 	// stored across the network.
@@ -67,12 +92,57 @@ type unfinishedChunk struct {
 	piecesRegistered int                 // number of pieces that are being uploaded, but aren't finished yet.
 	unusedHosts      map[string]struct{} // hosts that aren't yet storing any pieces
 	workersRemaining int                 // number of workers who have received the chunk, but haven't finished processing it.
+
+	// Timings of the stages of the upload pipeline, see
+	// modules.ChunkUploadMetrics. The negotiation and transfer times are
+	// summed over all pieces of the chunk. Protected by mu.
+	erasureCodingTime time.Duration
+	encryptionTime    time.Duration
+	negotiationTime   time.Duration
+	transferTime      time.Duration
+
+	// slowHosts contains the hosts that were slower than the other hosts
+	// when the chunk was distributed to the workers, and distributedAt is the
+	// time at which that happened. Slow hosts leave the pieces of the chunk to
+	// faster hosts for slowHostDeferral. Protected by mu.
+	slowHosts     map[string]struct{}
+	distributedAt time.Time
+
+	// pieceTimings contains the timings of every piece that was uploaded
+	// successfully. Protected by mu.
+	pieceTimings []pieceUploadTiming
+
+	// finished is set once every worker is done with the chunk. If done is
+	// not nil, it is closed at the same time. Protected by mu.
+	finished bool
+	done     chan struct{}
+
+	// traceCtx groups the events of the chunk in an upload trace, see
+	// traceEvent. The trace task is started by the first event that is logged
+	// while a trace is being recorded, and ended by traceEnd.
+	traceOnce sync.Once
+	traceCtx  context.Context
+	traceEnd  func()
+}
+
+// memoryClass returns the class of the renter's memory that the chunk is
+// allocated from.
+func (uc *unfinishedChunk) memoryClass() memoryClass {
+	if uc.repair || uc.evacuation || uc.stuck {
+		return memoryClassRepair
+	}
+	return memoryClassUserUpload
 }
 
 // Implementation of heap.Interface for chunkHeap.
 func (ch chunkHeap) Len() int { return len(ch) }
 func (ch chunkHeap) Less(i, j int) bool {
-	return float64(ch[i].piecesCompleted)/float64(ch[i].piecesNeeded) < float64(ch[j].piecesCompleted)/float64(ch[j].piecesNeeded)
+	ci := float64(ch[i].piecesCompleted) / float64(ch[i].piecesNeeded)
+	cj := float64(ch[j].piecesCompleted) / float64(ch[j].piecesNeeded)
+	if ci != cj {
+		return ci < cj
+	}
+	return ch[i].priority < ch[j].priority
 }
 func (ch chunkHeap) Swap(i, j int)       { ch[i], ch[j] = ch[j], ch[i] }
 func (ch *chunkHeap) Push(x interface{}) { *ch = append(*ch, x.(*unfinishedChunk)) }
@@ -94,9 +164,10 @@ func (r *Renter) buildUnfinishedChunks(f *file, hosts map[string]struct{}) []*un
 	f.mu.Lock()
 	defer f.mu.Unlock()
 
-	// If the file is not being tracked, don't repair it.
+	// If the file is not being tracked, the renter has given up on repairing
+	// it, or its upload is paused, don't repair it.
 	trackedFile, exists := r.tracking[f.name]
-	if !exists {
+	if !exists || trackedFile.Failed || trackedFile.Paused {
 		return nil
 	}
 
@@ -108,6 +179,11 @@ func (r *Renter) buildUnfinishedChunks(f *file, hosts map[string]struct{}) []*un
 	chunkCount := f.numChunks()
 	newUnfinishedChunks := make([]*unfinishedChunk, chunkCount)
 
+	// Chunks that were repaired earlier in the current repair pass, possibly
+	// before a restart, are skipped.
+	fingerprint := repairFingerprint(f, trackedFile.RepairPath)
+	checkpointed := r.checkpointedChunks(f, fingerprint)
+
 	// measuring performance
 	var splitting bool
 	var splittingStart time.Time
@@ -117,14 +193,22 @@ func (r *Renter) buildUnfinishedChunks(f *file, hosts map[string]struct{}) []*un
 		splitting = true
 	}
 
+	// If the local copy of the file is gone, the chunks are repaired from the
+	// pieces stored on the hosts instead.
+	localPath := trackedFile.RepairPath
+	if _, err := os.Stat(localPath); err != nil {
+		localPath = ""
+	}
+
 	for i := uint64(0); i < chunkCount; i++ {
 		newUnfinishedChunks[i] = &unfinishedChunk{
 			renterFile: f,
-			localPath:  trackedFile.RepairPath,
+			localPath:  localPath,
 
-			index:  i,
-			length: f.chunkSize(),
-			offset: int64(i * f.chunkSize()),
+			index:       i,
+			length:      f.chunkSize(),
+			offset:      int64(i * f.chunkSize()),
+			fingerprint: fingerprint,
 
 			// memoryNeeded has to also include the logical data, and also
 			// include the overhead for encryption.
@@ -199,10 +283,15 @@ func (r *Renter) buildUnfinishedChunks(f *file, hosts map[string]struct{}) []*un
 	}
 
 	// Iterate through the set of newUnfinishedChunks and remove any that are
-	// completed.
+	// completed. Chunks that can already be recovered from the network are
+	// marked as repairs.
 	incompleteChunks := newUnfinishedChunks[:0]
 	for i := 0; i < len(newUnfinishedChunks); i++ {
+		if _, repaired := checkpointed[newUnfinishedChunks[i].index]; repaired {
+			continue
+		}
 		if newUnfinishedChunks[i].piecesCompleted < newUnfinishedChunks[i].piecesNeeded {
+			newUnfinishedChunks[i].repair = newUnfinishedChunks[i].piecesCompleted >= newUnfinishedChunks[i].minimumPieces
 			incompleteChunks = append(incompleteChunks, newUnfinishedChunks[i])
 		}
 	}
@@ -212,60 +301,86 @@ func (r *Renter) buildUnfinishedChunks(f *file, hosts map[string]struct{}) []*un
 // managedBuildChunkHeap will iterate through all of the files in the renter and
 // construct a chunk heap.
 func (r *Renter) managedBuildChunkHeap(hosts map[string]struct{}) *chunkHeap {
-	// Loop through the whole set of files to build the chunk heap.
 	ch := new(chunkHeap)
 	heap.Init(ch)
+
+	// Loop through the whole set of files to build the chunk heap. Every pass
+	// counts as a repair attempt for the files that are still incomplete, and
+	// files that exceed the repair limits are left out.
+	//
+	// The files are visited by walking the directory tree, least healthy
+	// directory first. Chunks that are equally complete are repaired in that
+	// order.
 	id := r.mu.Lock()
-	for _, file := range r.files {
+	var save bool
+	for priority, file := range r.buildDirTree().repairOrder() {
 		unfinishedChunks := r.buildUnfinishedChunks(file, hosts)
+		changed, failed := r.recordRepairAttempt(file, len(unfinishedChunks) == 0)
+		save = save || changed
+		if failed {
+			continue
+		}
+		// Stuck chunks are left to the stuck loop.
+		tf := r.tracking[file.name]
 		for i := 0; i < len(unfinishedChunks); i++ {
+			if tf.isStuck(unfinishedChunks[i].index) {
+				continue
+			}
+			unfinishedChunks[i].priority = priority
 			heap.Push(ch, unfinishedChunks[i])
 		}
 	}
+	if save {
+		if err := r.saveSync(); err != nil {
+			r.log.Println("ERROR: unable to save the repair history of the renter's files:", err)
+		}
+	}
 	r.mu.Unlock(id)
 
 	// Init the heap.
 	return ch
 }
 
-// managedInsertFileIntoChunkHeap will insert all of the chunks of a file into the
-// chunk heap.
-func (r *Renter) managedInsertFileIntoChunkHeap(f *file, ch *chunkHeap, hosts map[string]struct{}) {
+// managedQueueFile adds the unfinished chunks of a file to the upload heap.
+func (r *Renter) managedQueueFile(f *file, hosts map[string]struct{}) {
 	id := r.mu.Lock()
 	unfinishedChunks := r.buildUnfinishedChunks(f, hosts)
-	for i := 0; i < len(unfinishedChunks); i++ {
-		heap.Push(ch, unfinishedChunks[i])
-	}
 	r.mu.Unlock(id)
+	r.uploadHeap.managedPush(unfinishedChunks)
 }
 
-// managedPrepareNextChunk takes the next chunk from the chunk heap and prepares
-// it for upload. Preparation includes blocking until enough memory is
-// available, fetching the logical data for the chunk (either from the disk or
-// from the network), erasure coding the logical data into the physical data,
-// and then finally passing the work onto the workers.
+// managedPrepareChunk prepares a chunk taken from the upload heap for upload.
+// Preparation includes blocking until enough memory is available, fetching
+// the logical data for the chunk (either from the disk or from the network),
+// erasure coding the logical data into the physical data, and then finally
+// passing the work onto the workers.
 //
 // TODO: Need to turn this into a smarter memory pool construction - this
 // construction as it stands has a race condition. Instead of blocking until a
 // memory refresh signal is received, it should just call 'AcquireMemory' on a
 // pool object or something, and then that object can worry about breaking and
 // stuff, and can also make sure that the memory goes to only one place.
-func (r *Renter) managedPrepareNextChunk(ch *chunkHeap, hosts map[string]struct{}) {
-	// Grab the next chunk, loop until we have enough memory, update the amount
-	// of memory available, and then spin up a thread to asynchronously handle
-	// the rest of the chunk tasks.
-	memoryAvailable := r.managedMemoryAvailableGet()
-	nextChunk := heap.Pop(ch).(*unfinishedChunk)
+func (r *Renter) managedPrepareChunk(nextChunk *unfinishedChunk) {
+	// Loop until we have enough memory, update the amount of memory
+	// available, and then spin up a thread to asynchronously handle the rest
+	// of the chunk tasks.
+	waitStart := time.Now()
+	memoryAvailable := r.managedMemoryAvailableGet(nextChunk.memoryClass())
 	for nextChunk.memoryNeeded > memoryAvailable {
 		select {
-		case newFile := <-r.newUploads:
-			r.managedInsertFileIntoChunkHeap(newFile, ch, hosts)
 		case <-r.newMemory:
-			memoryAvailable = r.managedMemoryAvailableGet()
+			memoryAvailable = r.managedMemoryAvailableGet(nextChunk.memoryClass())
+		case <-r.drainChan:
+			r.uploadHeap.managedDone(nextChunk)
+			return
 		case <-r.tg.StopChan():
+			r.uploadHeap.managedDone(nextChunk)
+			return
 		}
 	}
-	r.managedMemoryAvailableSub(nextChunk.memoryNeeded)
+	r.metrics.memoryWaitSeconds.Add(time.Since(waitStart).Seconds())
+	nextChunk.traceEvent("memory acquired")
+	r.managedMemoryAvailableSub(nextChunk.memoryNeeded, memoryEncoding, nextChunk.memoryClass())
 	// Add this thread to the waitgroup. This Add will be released once the
 	// worker threads have been added to the wg.
 	r.heapWG.Add(1)
@@ -273,8 +388,10 @@ func (r *Renter) managedPrepareNextChunk(ch *chunkHeap, hosts map[string]struct{
 		workDistributed := r.managedFetchAndRepairChunk(nextChunk)
 		r.heapWG.Done()
 		if !workDistributed {
-			// Release any data that did not get distributed to workers.
-			r.managedMemoryAvailableAdd(nextChunk.memoryNeeded - nextChunk.memoryReleased)
+			// Release any data that did not get distributed to workers. The
+			// chunk can be queued again by the next health scan.
+			r.managedMemoryAvailableAdd(nextChunk.memoryNeeded-nextChunk.memoryReleased, memoryEncoding, nextChunk.memoryClass())
+			r.uploadHeap.managedDone(nextChunk)
 		} else {
 			nextChunk.mu.Lock()
 			nextChunk.mu.Unlock()
@@ -297,12 +414,23 @@ func (r *Renter) managedRefreshHostsAndWorkers() map[string]struct{} {
 	//
 	// TODO / NOTE: This code can be removed once files store the HostPubKey
 	// of the hosts they are using, instead of just the FileContractID.
+	//
+	// Hosts that are still in their grace period are included, so that the
+	// pieces they store count towards redundancy. Their workers do not accept
+	// new pieces, see managedQueueChunkRepair.
 	currentContracts := r.hostContractor.Contracts()
 	hosts := make(map[string]struct{})
 	for _, contract := range currentContracts {
+		if !contract.Status.Usable() {
+			continue
+		}
 		hosts[contract.HostPublicKey.String()] = struct{}{}
 	}
 
+	// Move the pieces of imported files onto contracts that were formed with
+	// their hosts since the last pass.
+	r.managedAdoptForeignContracts(currentContracts)
+
 	// Refresh the worker pool as well.
 	r.managedUpdateWorkerPool()
 	return hosts
@@ -324,61 +452,153 @@ func (r *Renter) threadedRepairScan() {
 	defer r.tg.Done()
 
 	for {
-		// Return if the renter has shut down.
+		// Refresh the worker pool and get the set of hosts that are currently
+		// useful for uploading.
+		hosts := r.managedRefreshHostsAndWorkers()
+
+		// Add the chunks of all files that need work to the upload heap.
+		// Chunks that are already queued or being uploaded, e.g. because
+		// they belong to a new upload, are not added again.
+		passStart := time.Now()
+		passChunks := r.uploadHeap.managedPush(*r.managedBuildChunkHeap(hosts))
+		r.log.Println("Repairing", passChunks, "chunks")
+		rebuildHeapSignal := time.After(rebuildChunkHeapInterval)
+
+		// The pass is over once every chunk of the heap has been handed to the
+		// workers.
 		select {
+		case <-r.uploadHeap.managedEmpty():
 		case <-r.tg.StopChan():
 			return
-		default:
 		}
+		r.managedRecordRepairPass(passStart, passChunks)
 
-		// Refresh the worker pool and get the set of hosts that are currently
-		// useful for uploading.
-		hosts := r.managedRefreshHostsAndWorkers()
-
-		// Build a min-heap of chunks organized by upload progress.
-		chunkHeap := r.managedBuildChunkHeap(hosts)
-		r.log.Println("Repairing", chunkHeap.Len(), "chunks")
+		// Wait for the rebuild signal, and then for the workers to finish,
+		// before checking the health of all files again.
+		select {
+		case <-rebuildHeapSignal:
+		case <-r.tg.StopChan():
+			return
+		}
+		select {
+		case <-r.uploadHeap.managedIdle():
+		case <-r.tg.StopChan():
+			return
+		}
+		r.managedClearRepairCheckpoints()
+	}
+}
 
-		// Work through the heap. Chunks will be processed one at a time until
-		// the heap is whittled down. When the heap is empty, we wait for new
-		// files in a loop and then process those. When the rebuild signal is
-		// received, we start over with the outer loop that rebuilds the heap
-		// and re-checks the health of all the files.
-		rebuildHeapSignal := time.After(rebuildChunkHeapInterval)
+// threadedUploadLoop continuously takes chunks from the upload heap and
+// prepares them for upload.
+func (r *Renter) threadedUploadLoop() {
+	err := r.tg.Add()
+	if err != nil {
+		return
+	}
+	defer r.tg.Done()
 
-	LOOP:
-		for {
-			// Return if the renter has shut down.
+	for {
+		nextChunk := r.uploadHeap.managedPop()
+		if nextChunk == nil {
 			select {
+			case <-r.uploadHeap.newChunks:
+				continue
 			case <-r.tg.StopChan():
 				return
-			default:
 			}
+		}
 
-			if chunkHeap.Len() > 0 {
-				r.managedPrepareNextChunk(chunkHeap, hosts)
-			} else {
-				// Block until the rebuild signal is received.
-				select {
-				case newFile := <-r.newUploads:
-					// If a new file is received, add its chunks to the repair
-					// heap and loop to start working through those chunks.
-					// Update the worker pool before processing the file, as it
-					// may have been a while since the previous update.
-					hosts = r.managedRefreshHostsAndWorkers()
-					r.managedInsertFileIntoChunkHeap(newFile, chunkHeap, hosts)
-					continue
-				case <-rebuildHeapSignal:
-					// If the rebuild heap signal is received, break out to the
-					// outer loop which will check the health of all filess
-					// again and then rebuild the heap.
-					r.heapWG.Wait()
-					break LOOP
-				case <-r.tg.StopChan():
-					// If the stop signal is received, quit entirely.
-					return
-				}
-			}
+		// Chunks of files that were paused after the chunks were queued are
+		// dropped. They are queued again when the file is resumed. While the
+		// renter is shutting down, all chunks are dropped; they are resumed
+		// after the restart.
+		if r.isDraining() || r.managedUploadPaused(nextChunk.renterFile) {
+			r.uploadHeap.managedDone(nextChunk)
+			continue
 		}
+		r.managedPrepareChunk(nextChunk)
+	}
+}
+
+// threadedQueueNewUploads adds the chunks of new uploads to the upload heap as
+// soon as they are received, so that they do not have to wait for the next
+// health scan.
+func (r *Renter) threadedQueueNewUploads() {
+	err := r.tg.Add()
+	if err != nil {
+		return
+	}
+	defer r.tg.Done()
+
+	r.managedResumeUploads()
+	for {
+		select {
+		case newFile := <-r.newUploads:
+			// Update the worker pool before queuing the file, as it may
+			// have been a while since the previous update.
+			hosts := r.managedRefreshHostsAndWorkers()
+			r.managedQueueFile(newFile, hosts)
+		case <-r.tg.StopChan():
+			return
+		}
+	}
+}
+
+// managedResumeUploads adds the chunks of the uploads that were interrupted by
+// a shutdown to the upload heap, so that they continue where they left off
+// instead of waiting for the health scan.
+func (r *Renter) managedResumeUploads() {
+	id := r.mu.RLock()
+	files := r.interruptedUploads()
+	r.mu.RUnlock(id)
+	if len(files) == 0 {
+		return
+	}
+
+	hosts := r.managedRefreshHostsAndWorkers()
+	for _, f := range files {
+		r.managedQueueFile(f, hosts)
+	}
+	r.log.Println("Resuming", len(files), "interrupted uploads")
+}
+
+// interruptedUploads returns the files whose upload was interrupted and that
+// are neither paused nor failed. The renter's lock must be held.
+func (r *Renter) interruptedUploads() []*file {
+	var files []*file
+	for name, tf := range r.tracking {
+		f, exists := r.files[name]
+		if exists && tf.Uploading && !tf.Paused && !tf.Failed {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// managedRecordRepairPass records the completion of a repair pass that
+// started at 'start' and enqueued 'chunks' chunks.
+func (r *Renter) managedRecordRepairPass(start time.Time, chunks int) {
+	id := r.mu.Lock()
+	r.repairPasses++
+	r.lastRepairPassChunks = uint64(chunks)
+	r.lastRepairPassDuration = time.Since(start)
+	r.lastRepairPassTime = time.Now()
+	r.mu.Unlock(id)
+}
+
+// RepairStats reports how often the repair loop runs and how much work it
+// found during its most recent pass.
+func (r *Renter) RepairStats() modules.RepairStats {
+	id := r.mu.RLock()
+	defer r.mu.RUnlock(id)
+	stats := modules.RepairStats{
+		Passes:           r.repairPasses,
+		LastPassChunks:   r.lastRepairPassChunks,
+		LastPassDuration: r.lastRepairPassDuration,
+	}
+	if r.repairPasses > 0 {
+		stats.TimeSinceLastPass = time.Since(r.lastRepairPassTime)
 	}
+	return stats
 }
@@ -0,0 +1,190 @@
+package renter
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pachisi456/Sia/build"
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/types"
+
+	"github.com/NebulousLabs/fastrand"
+)
+
+// TestRepairStats checks that the repair stats count the passes of the
+// repair loop and the chunks enqueued during the most recent pass.
+func TestRepairStats(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// waitForPass waits until the repair loop has completed more than
+	// 'passes' passes and returns the resulting stats.
+	waitForPass := func(passes uint64) modules.RepairStats {
+		deadline := time.Now().Add(3 * rebuildChunkHeapInterval)
+		for time.Now().Before(deadline) {
+			if stats := rt.renter.RepairStats(); stats.Passes > passes {
+				return stats
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		t.Fatal("repair loop did not complete a pass after", passes, "passes")
+		return modules.RepairStats{}
+	}
+
+	// The repair loop scans the empty renter on startup.
+	stats := waitForPass(0)
+	if stats.LastPassChunks != 0 {
+		t.Fatal("expected the first pass to enqueue no chunks, got", stats.LastPassChunks)
+	}
+
+	// Track a file that needs repairs. The next pass should enqueue its
+	// only chunk.
+	rsc, _ := NewRSCode(1, 1)
+	f := newFile("foo", rsc, modules.SectorSize, modules.SectorSize)
+	id := rt.renter.mu.Lock()
+	rt.renter.files[f.name] = f
+	rt.renter.tracking[f.name] = trackedFile{RepairPath: "/does/not/exist"}
+	rt.renter.mu.Unlock(id)
+
+	stats = waitForPass(stats.Passes)
+	if stats.LastPassChunks != 1 {
+		t.Fatal("expected the pass to enqueue 1 chunk, got", stats.LastPassChunks)
+	}
+	if stats.TimeSinceLastPass > rebuildChunkHeapInterval {
+		t.Fatal("time since the last pass is too long:", stats.TimeSinceLastPass)
+	}
+}
+
+// TestRemoteRepairChunks checks that the chunks of a file whose local copy is
+// gone are repaired from the hosts, and that remote repairs are limited to
+// maxScheduledDownloads at once.
+func TestRemoteRepairChunks(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	rsc, _ := NewRSCode(1, 1)
+	f := newFile("foo", rsc, modules.SectorSize, modules.SectorSize)
+	localPath := filepath.Join(build.TempDir("renter", t.Name()), "foo")
+	if err := ioutil.WriteFile(localPath, make([]byte, f.size), 0600); err != nil {
+		t.Fatal(err)
+	}
+	id := rt.renter.mu.Lock()
+	rt.renter.files[f.name] = f
+	rt.renter.tracking[f.name] = trackedFile{RepairPath: localPath}
+	rt.renter.mu.Unlock(id)
+
+	// localPaths returns the local paths of the file's unfinished chunks.
+	localPaths := func() []string {
+		id := rt.renter.mu.Lock()
+		defer rt.renter.mu.Unlock(id)
+		var paths []string
+		for _, uc := range rt.renter.buildUnfinishedChunks(f, nil) {
+			paths = append(paths, uc.localPath)
+		}
+		return paths
+	}
+	if paths := localPaths(); len(paths) != 1 || paths[0] != localPath {
+		t.Fatal("expected the chunk to be repaired from the local copy, got", paths)
+	}
+	if err := os.Remove(localPath); err != nil {
+		t.Fatal(err)
+	}
+	if paths := localPaths(); len(paths) != 1 || paths[0] != "" {
+		t.Fatal("expected the chunk to be repaired from the hosts, got", paths)
+	}
+
+	// While all remote repair slots are taken, no repair download is queued.
+	for i := 0; i < maxScheduledDownloads; i++ {
+		rt.renter.remoteRepairs <- struct{}{}
+	}
+	uc := &unfinishedChunk{renterFile: f, length: f.chunkSize()}
+	done := make(chan struct{})
+	go func() {
+		rt.renter.managedDownloadLogicalChunkData(uc)
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("remote repair did not wait for a free slot")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Once a slot is freed, the chunk is downloaded. The download fails, as
+	// the file has no pieces on any host, and the slot is released again.
+	<-rt.renter.remoteRepairs
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("remote repair did not finish after a slot was freed")
+	}
+	if len(rt.renter.remoteRepairs) != maxScheduledDownloads-1 {
+		t.Fatal("remote repair did not release its slot")
+	}
+}
+
+// graceContractor is an evacuateContractor whose hosts are all in their grace
+// period.
+type graceContractor struct {
+	evacuateContractor
+}
+
+func (gc graceContractor) InGracePeriod(types.FileContractID, time.Duration) bool { return true }
+
+// TestRepairGracePeriodHosts checks that the pieces stored on hosts in their
+// grace period still count towards redundancy, so that a fully uploaded file
+// is not queued for repair once its hosts enter the grace period.
+func TestRepairGracePeriodHosts(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Create a file with two chunks, storing one piece of each chunk on
+	// each of two hosts.
+	rsc, _ := NewRSCode(1, 1)
+	f := newFile("foo", rsc, 64, 128)
+	vc := newVerifyContractor(rt.renter.hostContractor, f)
+	for i := range vc.contracts {
+		vc.contracts[i].GoodForUpload = true
+	}
+	for chunk := uint64(0); chunk < f.numChunks(); chunk++ {
+		vc.uploadChunk(t, f, chunk, fastrand.Bytes(int(f.chunkSize())))
+	}
+	gc := graceContractor{evacuateContractor{verifyContractor: vc}}
+	id := rt.renter.mu.Lock()
+	rt.renter.hostContractor = gc
+	rt.renter.hostGracePeriod = time.Hour
+	rt.renter.files[f.name] = f
+	rt.renter.tracking[f.name] = trackedFile{}
+	rt.renter.mu.Unlock(id)
+
+	hosts := rt.renter.managedRefreshHostsAndWorkers()
+	if len(hosts) != len(vc.contracts) {
+		t.Fatal("expected the hosts in their grace period to be included, got", hosts)
+	}
+	id = rt.renter.mu.Lock()
+	chunks := rt.renter.buildUnfinishedChunks(f, hosts)
+	rt.renter.mu.Unlock(id)
+	if len(chunks) != 0 {
+		t.Fatal("expected no chunks to be queued for repair, got", len(chunks))
+	}
+}
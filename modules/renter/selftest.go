@@ -0,0 +1,120 @@
+package renter
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pachisi456/Sia/modules"
+
+	"github.com/NebulousLabs/fastrand"
+)
+
+const (
+	// selfTestSiaPath is the siapath reserved for the probe file of the self
+	// test. Users cannot upload files to this siapath.
+	selfTestSiaPath = "__selftest__"
+
+	// selfTestProbeSize is the size of the probe file used by the self test.
+	selfTestProbeSize = 1 << 10
+)
+
+var (
+	errSelfTestNoContracts = errors.New("self test failed: the renter has no contracts")
+	errSelfTestMismatch    = errors.New("self test failed: downloaded data does not match the probe")
+	errSelfTestTimeout     = errors.New("self test failed: probe was not uploaded in time")
+)
+
+// SelfTest checks that the renter is able to store and retrieve data by
+// uploading a small probe file, downloading it again, and verifying that the
+// downloaded data is correct. The probe file is deleted afterwards. The time
+// taken by each step is returned along with any failure.
+func (r *Renter) SelfTest() (modules.RenterSelfTestReport, error) {
+	var report modules.RenterSelfTestReport
+	if err := r.tg.Add(); err != nil {
+		return report, ErrRenterShutdown
+	}
+	defer r.tg.Done()
+
+	// A probe can't be uploaded without any hosts to upload to.
+	if len(r.hostContractor.Contracts()) == 0 {
+		return report, errSelfTestNoContracts
+	}
+
+	// Write the probe to disk so that it can be uploaded.
+	probe := fastrand.Bytes(selfTestProbeSize)
+	probePath := filepath.Join(r.persistDir, selfTestSiaPath)
+	if err := ioutil.WriteFile(probePath, probe, 0600); err != nil {
+		return report, err
+	}
+	defer os.Remove(probePath)
+
+	// Remove any probe that was left behind by an interrupted self test, then
+	// upload the probe. A minimal erasure code is used so that the self test
+	// only depends on a single host being healthy.
+	ec, err := NewRSCode(1, 1)
+	if err != nil {
+		return report, err
+	}
+	r.DeleteFile(selfTestSiaPath)
+	start := time.Now()
+	_, err = r.managedUpload(modules.FileUploadParams{
+		Source:      probePath,
+		SiaPath:     selfTestSiaPath,
+		ErasureCode: ec,
+		Force:       true,
+	})
+	if err != nil {
+		return report, err
+	}
+	defer r.DeleteFile(selfTestSiaPath)
+
+	// Wait for the probe to become available.
+	lockID := r.mu.RLock()
+	f := r.files[selfTestSiaPath]
+	r.mu.RUnlock(lockID)
+	deadline := time.After(selfTestTimeout)
+	for {
+		f.mu.RLock()
+		available := f.available(r.isOffline)
+		f.mu.RUnlock()
+		if available {
+			break
+		}
+		select {
+		case <-time.After(100 * time.Millisecond):
+		case <-deadline:
+			return report, errSelfTestTimeout
+		case <-r.tg.StopChan():
+			return report, ErrRenterShutdown
+		}
+	}
+	report.UploadTime = time.Since(start)
+
+	// Download the probe and verify its contents.
+	start = time.Now()
+	buf := NewDownloadBufferWriter(selfTestProbeSize, 0)
+	d := r.newSectionDownload(f, buf, 0, selfTestProbeSize)
+	d.cache = nil // the probe must be fetched from the hosts
+	select {
+	case r.newDownloads <- d:
+	case <-r.tg.StopChan():
+		return report, ErrRenterShutdown
+	}
+	select {
+	case <-d.downloadFinished:
+	case <-r.tg.StopChan():
+		return report, ErrRenterShutdown
+	}
+	if err := d.Err(); err != nil {
+		return report, err
+	}
+	report.DownloadTime = time.Since(start)
+	if !bytes.Equal(buf.Bytes(), probe) {
+		return report, errSelfTestMismatch
+	}
+	return report, nil
+}
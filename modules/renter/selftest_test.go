@@ -0,0 +1,36 @@
+package renter
+
+import (
+	"testing"
+
+	"github.com/pachisi456/Sia/modules"
+)
+
+// TestRenterSelfTestNoHosts checks that the self test reports a failure when
+// the renter has no hosts to upload the probe to, and that the reserved siapath
+// cannot be used by regular uploads.
+func TestRenterSelfTestNoHosts(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	if _, err := rt.renter.SelfTest(); err != errSelfTestNoContracts {
+		t.Fatal("expected errSelfTestNoContracts, got", err)
+	}
+	if len(rt.renter.FileList()) != 0 {
+		t.Fatal("self test left a file behind")
+	}
+
+	err = rt.renter.Upload(modules.FileUploadParams{
+		Source:  rt.renter.persistDir,
+		SiaPath: selfTestSiaPath,
+	})
+	if err != errReservedSiapath {
+		t.Fatal("expected errReservedSiapath, got", err)
+	}
+}
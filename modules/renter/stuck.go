@@ -0,0 +1,159 @@
+package renter
+
+// Chunks whose uploads repeatedly end below full redundancy, e.g. because
+// there are not enough hosts that accept their pieces, are marked as stuck.
+// Stuck chunks are left out of the repair scan, so that they do not hold up
+// the repair of the renter's other chunks. Instead, the stuck loop retries a
+// random selection of them at a lower frequency, offering their pieces to the
+// workers in random order. A stuck chunk that reaches full redundancy is no
+// longer stuck.
+
+import (
+	"time"
+
+	"github.com/NebulousLabs/fastrand"
+)
+
+// isStuck reports whether the chunk with the given index is stuck.
+func (tf trackedFile) isStuck(index uint64) bool {
+	return tf.ChunkFailures[index] >= stuckChunkFailures
+}
+
+// stuckChunks returns the number of stuck chunks of the file.
+func (tf trackedFile) stuckChunks() uint64 {
+	var n uint64
+	for _, failures := range tf.ChunkFailures {
+		if failures >= stuckChunkFailures {
+			n++
+		}
+	}
+	return n
+}
+
+// managedRecordChunkResult updates the failure count of a chunk once the
+// workers are done with it. Chunks that did not reach full redundancy count
+// as failed, and chunks that did are no longer stuck.
+func (r *Renter) managedRecordChunkResult(uc *unfinishedChunk) {
+	uc.mu.Lock()
+	complete := uc.piecesCompleted >= uc.piecesNeeded
+	uc.mu.Unlock()
+
+	id := r.mu.Lock()
+	defer r.mu.Unlock(id)
+	uc.renterFile.mu.RLock()
+	name := uc.renterFile.name
+	uc.renterFile.mu.RUnlock()
+
+	// Chunks of files that were deleted or replaced in the meantime are
+	// ignored.
+	tf, exists := r.tracking[name]
+	if !exists || r.files[name] != uc.renterFile {
+		return
+	}
+	if complete {
+		if _, failed := tf.ChunkFailures[uc.index]; !failed {
+			return
+		}
+		delete(tf.ChunkFailures, uc.index)
+	} else {
+		if tf.ChunkFailures == nil {
+			tf.ChunkFailures = make(map[uint64]int)
+		}
+		tf.ChunkFailures[uc.index]++
+		if tf.ChunkFailures[uc.index] == stuckChunkFailures {
+			r.log.Printf("Chunk %v of %v is stuck after %v uploads below full redundancy", uc.index, name, stuckChunkFailures)
+		}
+	}
+	r.tracking[name] = tf
+	if err := r.saveSync(); err != nil {
+		r.log.Println("WARN: unable to save the failure count of a chunk:", err)
+	}
+}
+
+// managedQueueStuckChunks adds a random selection of at most
+// stuckChunksPerRetry stuck chunks to the upload heap. It returns the number
+// of chunks that were added.
+func (r *Renter) managedQueueStuckChunks(hosts map[string]struct{}) int {
+	id := r.mu.Lock()
+	var stuck []*unfinishedChunk
+	for name, tf := range r.tracking {
+		f, exists := r.files[name]
+		if !exists || tf.stuckChunks() == 0 {
+			continue
+		}
+		for _, uc := range r.buildUnfinishedChunks(f, hosts) {
+			if tf.isStuck(uc.index) {
+				uc.stuck = true
+				stuck = append(stuck, uc)
+			}
+		}
+	}
+	r.mu.Unlock(id)
+
+	if len(stuck) > stuckChunksPerRetry {
+		selected := make([]*unfinishedChunk, stuckChunksPerRetry)
+		for i, j := range fastrand.Perm(len(stuck))[:stuckChunksPerRetry] {
+			selected[i] = stuck[j]
+		}
+		stuck = selected
+	}
+	return r.uploadHeap.managedPush(stuck)
+}
+
+// threadedStuckLoop is a background thread that periodically retries stuck
+// chunks.
+func (r *Renter) threadedStuckLoop() {
+	err := r.tg.Add()
+	if err != nil {
+		return
+	}
+	defer r.tg.Done()
+
+	for {
+		select {
+		case <-time.After(stuckLoopInterval):
+		case <-r.tg.StopChan():
+			return
+		}
+		hosts := r.managedRefreshHostsAndWorkers()
+		if n := r.managedQueueStuckChunks(hosts); n > 0 {
+			r.log.Println("Retrying", n, "stuck chunks")
+		}
+	}
+}
+
+// RetryStuckChunks resets the failure counts of the chunks of a file, so that
+// its stuck chunks are repaired by the repair scan again, and queues the file
+// for repair immediately.
+func (r *Renter) RetryStuckChunks(siapath string) error {
+	if err := r.tg.Add(); err != nil {
+		return ErrRenterShutdown
+	}
+	defer r.tg.Done()
+
+	lockID := r.mu.Lock()
+	f, exists := r.files[siapath]
+	if !exists {
+		r.mu.Unlock(lockID)
+		return ErrUnknownPath
+	}
+	tf, tracked := r.tracking[siapath]
+	if !tracked {
+		r.mu.Unlock(lockID)
+		return errFileNotTracked
+	}
+	tf.ChunkFailures = nil
+	r.tracking[siapath] = tf
+	err := r.saveSync()
+	r.mu.Unlock(lockID)
+	if err != nil {
+		return err
+	}
+
+	// Send the file to the repair loop.
+	select {
+	case r.newUploads <- f:
+	case <-r.tg.StopChan():
+	}
+	return nil
+}
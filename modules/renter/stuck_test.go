@@ -0,0 +1,86 @@
+package renter
+
+import (
+	"testing"
+
+	"github.com/pachisi456/Sia/modules"
+)
+
+// TestStuckChunks checks that chunks are marked as stuck after repeatedly
+// failing to reach full redundancy, that stuck chunks are left to the stuck
+// loop, and that they are no longer stuck once they are retried.
+func TestStuckChunks(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	rsc, _ := NewRSCode(1, 1)
+	f := newFile("stuck", rsc, modules.SectorSize, 2*modules.SectorSize)
+	id := rt.renter.mu.Lock()
+	rt.renter.files[f.name] = f
+	rt.renter.tracking[f.name] = trackedFile{}
+	chunks := rt.renter.buildUnfinishedChunks(f, nil)
+	rt.renter.mu.Unlock(id)
+	if len(chunks) != 2 {
+		t.Fatal("expected 2 unfinished chunks, got", len(chunks))
+	}
+
+	// Fail the upload of the first chunk until it is stuck.
+	for i := 0; i < stuckChunkFailures; i++ {
+		rt.renter.managedRecordChunkResult(chunks[0])
+	}
+	fileInfo := func() modules.FileInfo {
+		for _, fi := range rt.renter.FileList() {
+			if fi.SiaPath == f.name {
+				return fi
+			}
+		}
+		t.Fatal("file not found")
+		return modules.FileInfo{}
+	}
+	if fi := fileInfo(); fi.StuckChunks != 1 {
+		t.Fatal("expected 1 stuck chunk, got", fi.StuckChunks)
+	}
+	if h := rt.renter.Health(); h.StuckChunks != 1 {
+		t.Fatal("expected health to report 1 stuck chunk, got", h.StuckChunks)
+	}
+
+	// The repair scan should skip the stuck chunk.
+	ch := rt.renter.managedBuildChunkHeap(nil)
+	for _, uc := range *ch {
+		if uc.renterFile == f && uc.index == 0 {
+			t.Fatal("stuck chunk was added to the chunk heap")
+		}
+	}
+
+	// The stuck loop should queue only the stuck chunk.
+	if n := rt.renter.managedQueueStuckChunks(nil); n != 1 {
+		t.Fatal("expected 1 stuck chunk to be queued, got", n)
+	}
+
+	// A chunk that reaches full redundancy is no longer stuck.
+	chunks[0].piecesCompleted = chunks[0].piecesNeeded
+	rt.renter.managedRecordChunkResult(chunks[0])
+	if fi := fileInfo(); fi.StuckChunks != 0 {
+		t.Fatal("expected no stuck chunks after a successful upload, got", fi.StuckChunks)
+	}
+
+	// Retrying resets the stuck chunks of the file.
+	for i := 0; i < stuckChunkFailures; i++ {
+		rt.renter.managedRecordChunkResult(chunks[1])
+	}
+	if err := rt.renter.RetryStuckChunks(f.name); err != nil {
+		t.Fatal(err)
+	}
+	if fi := fileInfo(); fi.StuckChunks != 0 {
+		t.Fatal("expected no stuck chunks after a retry, got", fi.StuckChunks)
+	}
+	if err := rt.renter.RetryStuckChunks("unknown"); err != ErrUnknownPath {
+		t.Fatal("expected ErrUnknownPath, got", err)
+	}
+}
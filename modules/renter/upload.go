@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/pachisi456/Sia/build"
@@ -39,13 +40,37 @@ var (
 		panic("undefined defaultParityPieces")
 	}()
 
+	errEmptyTagKey           = errors.New("tag keys must be nonempty")
 	errInsufficientContracts = errors.New("not enough contracts to upload file")
+	errReservedSiapath       = errors.New("siapath is reserved for use by the renter")
+	errUnknownPolicy         = errors.New("unknown upload collision policy")
+	errTagsTooLarge          = fmt.Errorf("tags may not exceed %v bytes", maxTagsSize)
 	errUploadDirectory       = errors.New("cannot upload directory")
 
 	// Erasure-coded piece size
 	pieceSize = modules.SectorSize - crypto.TwofishOverhead
 )
 
+// maxTagsSize is the maximum combined size of the keys and values of the tags
+// of a file. Tags are stored in the file's metadata, which is rewritten
+// whenever the file changes, so they are kept small.
+const maxTagsSize = 4096
+
+// validateTags checks that the tags supplied with an upload are valid.
+func validateTags(tags map[string]string) error {
+	var size int
+	for key, value := range tags {
+		if key == "" {
+			return errEmptyTagKey
+		}
+		size += len(key) + len(value)
+	}
+	if size > maxTagsSize {
+		return errTagsTooLarge
+	}
+	return nil
+}
+
 // validateSiapath checks that a Siapath is a legal filename.
 // ../ is disallowed to prevent directory traversal,
 // and paths must not begin with / or be empty.
@@ -86,57 +111,221 @@ func validateSource(sourcePath string) error {
 // Upload instructs the renter to start tracking a file. The renter will
 // automatically upload and repair tracked files using a background loop.
 func (r *Renter) Upload(up modules.FileUploadParams) error {
+	if err := r.tg.Add(); err != nil {
+		return ErrRenterShutdown
+	}
+	defer r.tg.Done()
+
+	// The self test and benchmark files can only be uploaded by the renter
+	// itself.
+	if isReservedSiapath(up.SiaPath) {
+		return errReservedSiapath
+	}
+	_, err := r.managedUpload(up)
+	return err
+}
+
+// isReservedSiapath returns whether siapath is reserved for files that are
+// uploaded by the renter itself.
+func isReservedSiapath(siapath string) bool {
+	return siapath == selfTestSiaPath || siapath == benchmarkSiaPath || isBackupSiapath(siapath)
+}
+
+// checkUploadContracts returns an error if the renter does not have enough
+// contracts to upload a file with the given erasure code. We need at least
+// (data + parity/2) contracts; since NumPieces = data + parity, we arrive at
+// the expression below.
+func (r *Renter) checkUploadContracts(ec modules.ErasureCoder) error {
+	needed := (ec.NumPieces() + ec.MinPieces()) / 2
+	if nContracts := len(r.hostContractor.Contracts()); nContracts < needed && build.Release != "testing" {
+		return fmt.Errorf("not enough contracts to upload file: got %v, needed %v", nContracts, needed)
+	}
+	return nil
+}
+
+// siapathInUse reports whether siapath belongs to a file of the renter, to a
+// stream that is being uploaded, or to a directory. The renter's lock must be
+// held.
+func (r *Renter) siapathInUse(siapath string) bool {
+	_, exists := r.files[siapath]
+	_, streaming := r.streamingUploads[siapath]
+	return exists || streaming || r.dirExists(siapath)
+}
+
+// storedBytes returns the logical size of f and the amount of data it occupies
+// on hosts once fully uploaded.
+func storedBytes(f *file) (logical, redundant uint64) {
+	return f.size, f.numChunks() * uint64(f.erasureCode.NumPieces()) * f.pieceSize
+}
+
+// reserveStorage adds 'logical' and 'redundant' bytes to the renter's running
+// totals of stored bytes. Unless 'force' is set, ErrStorageCapExceeded is
+// returned instead if the totals would exceed the storage caps. The file at
+// 'replaced', if any, is about to be deleted and does not count towards the
+// caps. The renter's lock must be held.
+func (r *Renter) reserveStorage(logical, redundant uint64, replaced string, force bool) error {
+	if !force {
+		var replacedLogical, replacedRedundant uint64
+		if f, exists := r.files[replaced]; exists {
+			replacedLogical, replacedRedundant = storedBytes(f)
+		}
+		if r.maxStoredBytes != 0 && r.storedBytes+logical-replacedLogical > r.maxStoredBytes {
+			return ErrStorageCapExceeded
+		}
+		if r.maxStoredRedundantBytes != 0 && r.storedRedundantBytes+redundant-replacedRedundant > r.maxStoredRedundantBytes {
+			return ErrStorageCapExceeded
+		}
+	}
+	r.addStorage(logical, redundant)
+	return nil
+}
+
+// addStorage adds 'logical' and 'redundant' bytes to the renter's running
+// totals of stored bytes, regardless of the storage caps. It is used for
+// files that are loaded rather than uploaded. The renter's lock must be held.
+func (r *Renter) addStorage(logical, redundant uint64) {
+	r.storedBytes += logical
+	r.storedRedundantBytes += redundant
+}
+
+// releaseStorage subtracts 'logical' and 'redundant' bytes from the renter's
+// running totals of stored bytes. The totals never drop below zero, as that
+// would disable the storage caps. The renter's lock must be held.
+func (r *Renter) releaseStorage(logical, redundant uint64) {
+	if logical > r.storedBytes || redundant > r.storedRedundantBytes {
+		build.Critical("releasing more storage than was reserved:", logical, redundant, r.storedBytes, r.storedRedundantBytes)
+	}
+	if logical > r.storedBytes {
+		logical = r.storedBytes
+	}
+	if redundant > r.storedRedundantBytes {
+		redundant = r.storedRedundantBytes
+	}
+	r.storedBytes -= logical
+	r.storedRedundantBytes -= redundant
+}
+
+// managedUpload starts tracking a file without checking whether the siapath is
+// reserved, and returns the file.
+func (r *Renter) managedUpload(up modules.FileUploadParams) (*file, error) {
 	// Enforce nickname rules.
 	if err := validateSiapath(up.SiaPath); err != nil {
-		return err
+		return nil, err
 	}
 	// Enforce source rules.
 	if err := validateSource(up.Source); err != nil {
-		return err
+		return nil, err
+	}
+	if err := validateTags(up.Tags); err != nil {
+		return nil, err
 	}
 
-	// Check for a nickname conflict.
+	// Check for a nickname conflict. Conflicts are resolved according to the
+	// collision policy: either the upload fails, the existing file is
+	// replaced, or the new file is uploaded under a suffixed nickname.
+	switch up.CollisionPolicy {
+	case "", modules.CollisionError, modules.CollisionOverwrite, modules.CollisionVersion:
+	default:
+		return nil, errUnknownPolicy
+	}
 	lockID := r.mu.RLock()
 	_, exists := r.files[up.SiaPath]
+	if exists && up.CollisionPolicy == modules.CollisionVersion {
+		origPath := up.SiaPath
+		for dupCount := 1; exists; dupCount++ {
+			up.SiaPath = origPath + "_" + strconv.Itoa(dupCount)
+			exists = r.siapathInUse(up.SiaPath)
+		}
+	}
+	_, streaming := r.streamingUploads[up.SiaPath]
+	isDir := r.dirExists(up.SiaPath)
 	r.mu.RUnlock(lockID)
-	if exists {
-		return ErrPathOverload
+	if streaming || isDir || (exists && up.CollisionPolicy != modules.CollisionOverwrite) {
+		return nil, ErrPathOverload
 	}
 
 	// Fill in any missing upload params with sensible defaults.
 	fileInfo, err := os.Stat(up.Source)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if up.ErasureCode == nil {
 		up.ErasureCode, _ = NewRSCode(defaultDataPieces, defaultParityPieces)
 	}
 
-	// Check that we have contracts to upload to. We need at least (data +
-	// parity/2) contracts; since NumPieces = data + parity, we arrive at the
-	// expression below.
-	if nContracts := len(r.hostContractor.Contracts()); nContracts < (up.ErasureCode.NumPieces()+up.ErasureCode.MinPieces())/2 && build.Release != "testing" {
-		return fmt.Errorf("not enough contracts to upload file: got %v, needed %v", nContracts, (up.ErasureCode.NumPieces()+up.ErasureCode.MinPieces())/2)
+	// Check that we have contracts to upload to.
+	if err := r.checkUploadContracts(up.ErasureCode); err != nil {
+		return nil, err
 	}
 
 	// Create file object.
 	f := newFile(up.SiaPath, up.ErasureCode, pieceSize, uint64(fileInfo.Size()))
 	f.mode = uint32(fileInfo.Mode())
+	f.modTime = fileInfo.ModTime()
+	if len(up.Tags) > 0 {
+		f.tags = make(map[string]string, len(up.Tags))
+		for key, value := range up.Tags {
+			f.tags[key] = value
+		}
+	}
 
-	// Add file to renter.
+	// Reserve the space of the file, checking that it fits within the storage
+	// caps. A file that is being overwritten does not count towards the caps.
+	// The space is reserved under the same lock as the check, so concurrent
+	// uploads cannot exceed the caps together.
+	logical, redundant := storedBytes(f)
+	lockID = r.mu.Lock()
+	err = r.reserveStorage(logical, redundant, up.SiaPath, up.Force)
+	r.mu.Unlock(lockID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Remove the file being overwritten. This is only done once all other
+	// checks have passed, so that a failed upload does not lose the existing
+	// file.
+	if exists {
+		if err := r.DeleteFile(up.SiaPath); err != nil && err != ErrUnknownPath {
+			lockID = r.mu.Lock()
+			r.releaseStorage(logical, redundant)
+			r.mu.Unlock(lockID)
+			return nil, err
+		}
+	}
+
+	// Add file to renter. Its space has already been reserved.
 	lockID = r.mu.Lock()
 	r.files[up.SiaPath] = f
 	r.tracking[up.SiaPath] = trackedFile{
 		RepairPath: up.Source,
+		Uploading:  true,
 	}
 	r.saveSync()
 	err = r.saveFile(f)
 	r.mu.Unlock(lockID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Send the upload to the repair loop.
-	r.newUploads <- f
-	return nil
+	select {
+	case r.newUploads <- f:
+	case <-r.tg.StopChan():
+		return nil, ErrRenterShutdown
+	}
+	return f, nil
+}
+
+// finishUploading clears the Uploading flag of f once the file is fully
+// uploaded. The renter's lock and the file's lock must be held.
+func (r *Renter) finishUploading(f *file) {
+	tf, exists := r.tracking[f.name]
+	if !exists || !tf.Uploading || f.uploadProgress() < 100 {
+		return
+	}
+	tf.Uploading = false
+	r.tracking[f.name] = tf
+	if err := r.saveSync(); err != nil {
+		r.log.Println("ERROR: unable to save the completed upload of", f.name, err)
+	}
 }
@@ -1,11 +1,19 @@
 package renter
 
 import (
+	"bytes"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/types"
+
+	"github.com/NebulousLabs/fastrand"
 )
 
 // TestRenterSiapathValidate verifies that the validateSiapath function correctly validates SiaPaths.
@@ -72,3 +80,385 @@ func TestRenterUploadInode(t *testing.T) {
 		t.Fatal("expected errUploadDirectory, got", err)
 	}
 }
+
+// TestRenterUploadCollisionPolicy checks that each collision policy is applied
+// when uploading to a siapath that is already in use.
+func TestRenterUploadCollisionPolicy(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Create two source files of different sizes so that the uploads can be
+	// told apart.
+	dir, err := ioutil.TempDir("", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	source1, source2 := filepath.Join(dir, "source1"), filepath.Join(dir, "source2")
+	if err := ioutil.WriteFile(source1, fastrand.Bytes(100), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(source2, fastrand.Bytes(200), 0600); err != nil {
+		t.Fatal(err)
+	}
+	upload := func(source string, policy modules.UploadCollisionPolicy) error {
+		return rt.renter.Upload(modules.FileUploadParams{
+			Source:          source,
+			SiaPath:         "test",
+			CollisionPolicy: policy,
+		})
+	}
+	fileSizes := func() map[string]uint64 {
+		sizes := make(map[string]uint64)
+		for _, fi := range rt.renter.FileList() {
+			sizes[fi.SiaPath] = fi.Filesize
+		}
+		return sizes
+	}
+	if err := upload(source1, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// The default and error policies should reject the upload.
+	if err := upload(source2, ""); err != ErrPathOverload {
+		t.Fatal("expected ErrPathOverload, got", err)
+	}
+	if err := upload(source2, modules.CollisionError); err != ErrPathOverload {
+		t.Fatal("expected ErrPathOverload, got", err)
+	}
+	if sizes := fileSizes(); len(sizes) != 1 || sizes["test"] != 100 {
+		t.Fatal("rejected upload modified the renter's files:", sizes)
+	}
+
+	// The version policy should keep the existing file and add a new one.
+	if err := upload(source2, modules.CollisionVersion); err != nil {
+		t.Fatal(err)
+	}
+	if err := upload(source2, modules.CollisionVersion); err != nil {
+		t.Fatal(err)
+	}
+	sizes := fileSizes()
+	if len(sizes) != 3 || sizes["test"] != 100 || sizes["test_1"] != 200 || sizes["test_2"] != 200 {
+		t.Fatal("version policy produced the wrong files:", sizes)
+	}
+
+	// The overwrite policy should replace the existing file.
+	if err := upload(source2, modules.CollisionOverwrite); err != nil {
+		t.Fatal(err)
+	}
+	sizes = fileSizes()
+	if len(sizes) != 3 || sizes["test"] != 200 {
+		t.Fatal("overwrite policy did not replace the file:", sizes)
+	}
+	id := rt.renter.mu.RLock()
+	repairPath := rt.renter.tracking["test"].RepairPath
+	rt.renter.mu.RUnlock(id)
+	if repairPath != source2 {
+		t.Fatal("overwritten file is not tracking the new source:", repairPath)
+	}
+
+	// Unknown policies should be rejected.
+	if err := upload(source2, "bogus"); err != errUnknownPolicy {
+		t.Fatal("expected errUnknownPolicy, got", err)
+	}
+}
+
+// TestRenterUploadMetadata checks that the modification time, mode and tags of
+// an uploaded file are returned by FileList and survive reloading the file.
+func TestRenterUploadMetadata(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	dir, err := ioutil.TempDir("", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	source := filepath.Join(dir, "source")
+	if err := ioutil.WriteFile(source, fastrand.Bytes(100), 0640); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Date(2018, 1, 1, 12, 0, 0, 500, time.UTC)
+	if err := os.Chtimes(source, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	// Invalid tags should be rejected.
+	err = rt.renter.Upload(modules.FileUploadParams{
+		Source:  source,
+		SiaPath: "test",
+		Tags:    map[string]string{"": "foo"},
+	})
+	if err != errEmptyTagKey {
+		t.Fatal("expected errEmptyTagKey, got", err)
+	}
+	err = rt.renter.Upload(modules.FileUploadParams{
+		Source:  source,
+		SiaPath: "test",
+		Tags:    map[string]string{"foo": string(make([]byte, maxTagsSize))},
+	})
+	if err != errTagsTooLarge {
+		t.Fatal("expected errTagsTooLarge, got", err)
+	}
+
+	tags := map[string]string{"owner": "backup", "empty": ""}
+	err = rt.renter.Upload(modules.FileUploadParams{
+		Source:  source,
+		SiaPath: "test",
+		Tags:    tags,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkMetadata := func(files []modules.FileInfo) {
+		if len(files) != 1 {
+			t.Fatal("expected 1 file, got", len(files))
+		}
+		fi := files[0]
+		if !fi.ModTime.Equal(modTime) {
+			t.Error("wrong modification time:", fi.ModTime)
+		}
+		if fi.Mode.Perm() != 0640 {
+			t.Error("wrong mode:", fi.Mode)
+		}
+		if len(fi.Tags) != len(tags) || fi.Tags["owner"] != "backup" || fi.Tags["empty"] != "" {
+			t.Error("wrong tags:", fi.Tags)
+		}
+	}
+	checkMetadata(rt.renter.FileList())
+
+	// The metadata should survive sharing the file.
+	id := rt.renter.mu.RLock()
+	f := rt.renter.files["test"]
+	rt.renter.mu.RUnlock(id)
+	buf := new(bytes.Buffer)
+	if err := shareFiles([]*file{f}, buf); err != nil {
+		t.Fatal(err)
+	}
+	files, err := decodeSharedFiles(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := equalFiles(f, files[0]); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestRenterUploadStorageCap checks that uploads exceeding the storage caps
+// are rejected unless forced, and that deleted files no longer count towards
+// the caps.
+func TestRenterUploadStorageCap(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	dir, err := ioutil.TempDir("", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	source := filepath.Join(dir, "source")
+	if err := ioutil.WriteFile(source, fastrand.Bytes(100), 0600); err != nil {
+		t.Fatal(err)
+	}
+	upload := func(siapath string, force bool) error {
+		return rt.renter.Upload(modules.FileUploadParams{
+			Source:  source,
+			SiaPath: siapath,
+			Force:   force,
+		})
+	}
+	setCaps := func(logical, redundant uint64) {
+		settings := rt.renter.Settings()
+		settings.MaxStoredBytes = logical
+		settings.MaxStoredRedundantBytes = redundant
+		if err := rt.renter.SetSettings(settings); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Only one of the files fits within the logical cap.
+	setCaps(150, 0)
+	if err := upload("foo", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := upload("bar", false); err != ErrStorageCapExceeded {
+		t.Fatal("expected ErrStorageCapExceeded, got", err)
+	}
+	if err := upload("bar", true); err != nil {
+		t.Fatal("forced upload failed:", err)
+	}
+
+	// Once both files are deleted, there is room for another file.
+	if err := rt.renter.DeleteFile("foo"); err != nil {
+		t.Fatal(err)
+	}
+	if err := rt.renter.DeleteFile("bar"); err != nil {
+		t.Fatal(err)
+	}
+	if err := upload("baz", false); err != nil {
+		t.Fatal("upload after deleting files failed:", err)
+	}
+
+	// The redundant cap counts every piece that is stored on a host.
+	setCaps(0, modules.SectorSize)
+	if err := upload("qux", false); err != ErrStorageCapExceeded {
+		t.Fatal("expected ErrStorageCapExceeded, got", err)
+	}
+	if s := rt.renter.Settings(); s.MaxStoredBytes != 0 || s.MaxStoredRedundantBytes != modules.SectorSize {
+		t.Fatal("storage caps were not applied:", s.MaxStoredBytes, s.MaxStoredRedundantBytes)
+	}
+}
+
+// TestRenterUploadStorageCapConcurrent checks that concurrent uploads cannot
+// exceed the storage caps together.
+func TestRenterUploadStorageCapConcurrent(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	dir, err := ioutil.TempDir("", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	source := filepath.Join(dir, "source")
+	if err := ioutil.WriteFile(source, fastrand.Bytes(100), 0600); err != nil {
+		t.Fatal(err)
+	}
+	settings := rt.renter.Settings()
+	settings.MaxStoredBytes = 150
+	if err := rt.renter.SetSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only one of the uploads fits within the cap.
+	const uploads = 10
+	errs := make([]error, uploads)
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = rt.renter.Upload(modules.FileUploadParams{
+				Source:  source,
+				SiaPath: "foo" + strconv.Itoa(i),
+			})
+		}(i)
+	}
+	wg.Wait()
+	var succeeded int
+	for _, err := range errs {
+		if err == nil {
+			succeeded++
+		} else if err != ErrStorageCapExceeded {
+			t.Fatal("expected ErrStorageCapExceeded, got", err)
+		}
+	}
+	if succeeded != 1 {
+		t.Fatal("expected 1 upload to succeed, got", succeeded)
+	}
+	id := rt.renter.mu.RLock()
+	stored := rt.renter.storedBytes
+	rt.renter.mu.RUnlock(id)
+	if stored != 100 {
+		t.Fatal("expected 100 stored bytes, got", stored)
+	}
+}
+
+// TestRenterInterruptedUploads checks that uploads are marked as interrupted
+// until the file is fully uploaded, and that the marks survive a restart.
+func TestRenterInterruptedUploads(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Track an incomplete file for each state of the tracking metadata.
+	rsc, _ := NewRSCode(1, 1)
+	states := map[string]trackedFile{
+		"uploading": {Uploading: true},
+		"paused":    {Uploading: true, Paused: true},
+		"failed":    {Uploading: true, Failed: true},
+		"uploaded":  {},
+	}
+	id := rt.renter.mu.Lock()
+	for name, tf := range states {
+		f := newFile(name, rsc, modules.SectorSize, modules.SectorSize)
+		rt.renter.files[name] = f
+		rt.renter.tracking[name] = tf
+		if err := rt.renter.saveFile(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = rt.renter.saveSync()
+	rt.renter.mu.Unlock(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// interrupted returns the names of the interrupted uploads after loading
+	// the renter from disk.
+	interrupted := func() []string {
+		id := rt.renter.mu.Lock()
+		defer rt.renter.mu.Unlock(id)
+		rt.renter.files = make(map[string]*file)
+		rt.renter.tracking = make(map[string]trackedFile)
+		if err := rt.renter.load(); err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, f := range rt.renter.interruptedUploads() {
+			names = append(names, f.name)
+		}
+		return names
+	}
+	if names := interrupted(); len(names) != 1 || names[0] != "uploading" {
+		t.Fatal("expected only the uploading file to be interrupted, got", names)
+	}
+
+	// Once all pieces of the file are uploaded, it is no longer interrupted.
+	id = rt.renter.mu.Lock()
+	f := rt.renter.files["uploading"]
+	f.mu.Lock()
+	f.contracts[types.FileContractID{1}] = fileContract{
+		ID:     types.FileContractID{1},
+		Pieces: []pieceData{{Chunk: 0, Piece: 0}, {Chunk: 0, Piece: 1}},
+	}
+	err = rt.renter.saveFile(f)
+	rt.renter.finishUploading(f)
+	f.mu.Unlock()
+	rt.renter.mu.Unlock(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := interrupted(); len(names) != 0 {
+		t.Fatal("expected no interrupted uploads, got", names)
+	}
+}
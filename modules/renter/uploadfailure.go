@@ -0,0 +1,100 @@
+package renter
+
+import (
+	"time"
+
+	"github.com/pachisi456/Sia/modules"
+)
+
+// uploadFailureBufferSize is the number of upload failures that are buffered
+// for each subscriber. Failures are dropped for subscribers that fall further
+// behind.
+const uploadFailureBufferSize = 64
+
+// recordRepairAttempt updates the repair history of a tracked file after a
+// repair pass, and marks the file as failed once it has been incomplete for
+// longer than the renter's repair limits allow. The first return value
+// reports whether the repair history changed and needs to be saved, and the
+// second whether the file is failed and should not be repaired anymore.
+func (r *Renter) recordRepairAttempt(f *file, complete bool) (changed, failed bool) {
+	tf, exists := r.tracking[f.name]
+	if !exists {
+		return false, false
+	}
+	if tf.Failed {
+		return false, true
+	}
+	if tf.Paused {
+		// Paused files are not repaired, so the pass does not count as an
+		// attempt.
+		return false, false
+	}
+	if complete {
+		if tf.RepairAttempts == 0 && len(tf.ChunkFailures) == 0 {
+			return false, false
+		}
+		tf.RepairAttempts = 0
+		tf.FirstIncomplete = time.Time{}
+		tf.ChunkFailures = nil
+		r.tracking[f.name] = tf
+		return true, false
+	}
+
+	if tf.RepairAttempts == 0 {
+		tf.FirstIncomplete = time.Now()
+	}
+	tf.RepairAttempts++
+	attemptsExceeded := r.maxRepairAttempts > 0 && tf.RepairAttempts >= r.maxRepairAttempts
+	timeExceeded := r.maxRepairTime > 0 && time.Since(tf.FirstIncomplete) >= r.maxRepairTime
+	if attemptsExceeded || timeExceeded {
+		tf.Failed = true
+		r.log.Printf("Giving up on repairing %v after %v attempts", f.name, tf.RepairAttempts)
+		r.notifyUploadFailure(modules.UploadFailure{
+			SiaPath:        f.name,
+			RepairAttempts: tf.RepairAttempts,
+			Time:           time.Now(),
+		})
+	}
+	r.tracking[f.name] = tf
+	return true, tf.Failed
+}
+
+// notifyUploadFailure sends an upload failure to every subscriber without
+// blocking.
+func (r *Renter) notifyUploadFailure(uf modules.UploadFailure) {
+	for c := range r.uploadFailureSubscribers {
+		select {
+		case c <- uf:
+		default:
+			r.log.Println("WARN: dropping upload failure event for a slow subscriber")
+		}
+	}
+}
+
+// SubscribeUploadFailures returns a channel that receives an UploadFailure
+// whenever the renter gives up on repairing a file. The channel is closed once
+// cancel is closed or the renter shuts down.
+func (r *Renter) SubscribeUploadFailures(cancel <-chan struct{}) <-chan modules.UploadFailure {
+	c := make(chan modules.UploadFailure, uploadFailureBufferSize)
+	if err := r.tg.Add(); err != nil {
+		close(c)
+		return c
+	}
+	defer r.tg.Done()
+
+	id := r.mu.Lock()
+	r.uploadFailureSubscribers[c] = struct{}{}
+	r.mu.Unlock(id)
+
+	go func() {
+		select {
+		case <-cancel:
+		case <-r.tg.StopChan():
+		}
+		id := r.mu.Lock()
+		delete(r.uploadFailureSubscribers, c)
+		r.mu.Unlock(id)
+		close(c)
+	}()
+	return c
+}
@@ -0,0 +1,84 @@
+package renter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pachisi456/Sia/modules"
+)
+
+// TestUploadFailure checks that a file that cannot be repaired is marked as
+// failed after the configured number of repair attempts, that subscribers are
+// notified, and that the file is no longer repaired.
+func TestUploadFailure(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	const maxAttempts = 3
+	settings := rt.renter.Settings()
+	settings.MaxRepairAttempts = maxAttempts
+	if err := rt.renter.SetSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+	cancel := make(chan struct{})
+	defer close(cancel)
+	failures := rt.renter.SubscribeUploadFailures(cancel)
+
+	// Track a file that has no local copy and no hosts, so that it can never
+	// be repaired.
+	rsc, _ := NewRSCode(1, 1)
+	f := newFile("hopeless", rsc, modules.SectorSize, modules.SectorSize)
+	id := rt.renter.mu.Lock()
+	rt.renter.files[f.name] = f
+	rt.renter.tracking[f.name] = trackedFile{RepairPath: "/does/not/exist"}
+	rt.renter.mu.Unlock(id)
+
+	// failed reports whether the file is listed as failed.
+	failed := func() bool {
+		for _, fi := range rt.renter.FileList() {
+			if fi.SiaPath == f.name {
+				return fi.Failed
+			}
+		}
+		t.Fatal("file is missing from the file list")
+		return false
+	}
+
+	// The file is repaired until it exceeds the number of attempts.
+	if ch := rt.renter.managedBuildChunkHeap(nil); ch.Len() == 0 {
+		t.Fatal("expected the file to be scheduled for repair")
+	}
+	if failed() {
+		t.Fatal("file was marked as failed after a single attempt")
+	}
+	for i := 1; i < maxAttempts && !failed(); i++ {
+		rt.renter.managedBuildChunkHeap(nil)
+	}
+	if !failed() {
+		t.Fatal("file was not marked as failed after", maxAttempts, "attempts")
+	}
+
+	select {
+	case uf := <-failures:
+		if uf.SiaPath != f.name || uf.RepairAttempts != maxAttempts {
+			t.Fatal("unexpected upload failure:", uf)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no upload failure event was received")
+	}
+
+	// A failed file is not repaired anymore, but it is still known to the
+	// renter.
+	if ch := rt.renter.managedBuildChunkHeap(nil); ch.Len() != 0 {
+		t.Fatal("failed file was scheduled for repair")
+	}
+	if !failed() {
+		t.Fatal("failed file is no longer listed as failed")
+	}
+}
@@ -0,0 +1,135 @@
+package renter
+
+import (
+	"container/heap"
+	"sync"
+)
+
+type (
+	// uploadChunkID identifies a chunk of a specific version of a file.
+	uploadChunkID struct {
+		file  *file
+		index uint64
+	}
+
+	// uploadHeap is the renter's persistent heap of chunks that are waiting
+	// to be uploaded. The upload loop pulls chunks from the heap
+	// continuously, while new uploads and the periodic health scan add chunks
+	// to it. A chunk is tracked from the moment it is added until the workers
+	// are done with it, and is not added again in the meantime, so that the
+	// same chunk is never uploaded twice at once.
+	uploadHeap struct {
+		heap   chunkHeap
+		active map[uploadChunkID]*unfinishedChunk
+
+		// empty is closed while the heap is empty, and idle is closed while
+		// no chunks are tracked at all. newChunks is signalled whenever
+		// chunks are added.
+		empty     chan struct{}
+		idle      chan struct{}
+		newChunks chan struct{}
+
+		mu sync.Mutex
+	}
+)
+
+// newUploadHeap returns an empty uploadHeap.
+func newUploadHeap() *uploadHeap {
+	uh := &uploadHeap{
+		active:    make(map[uploadChunkID]*unfinishedChunk),
+		empty:     make(chan struct{}),
+		idle:      make(chan struct{}),
+		newChunks: make(chan struct{}, 1),
+	}
+	close(uh.empty)
+	close(uh.idle)
+	return uh
+}
+
+// managedPush adds chunks to the heap, skipping chunks that are already in the
+// heap or being uploaded. It returns the number of chunks that were added.
+func (uh *uploadHeap) managedPush(chunks []*unfinishedChunk) int {
+	uh.mu.Lock()
+	var added []*unfinishedChunk
+	for _, uc := range chunks {
+		id := uploadChunkID{uc.renterFile, uc.index}
+		if _, exists := uh.active[id]; exists {
+			continue
+		}
+		if len(uh.active) == 0 {
+			uh.idle = make(chan struct{})
+		}
+		if len(uh.heap) == 0 {
+			uh.empty = make(chan struct{})
+		}
+		uh.active[id] = uc
+		heap.Push(&uh.heap, uc)
+		added = append(added, uc)
+	}
+	uh.mu.Unlock()
+
+	for _, uc := range added {
+		uc.traceEvent("chunk queued")
+	}
+	if len(added) > 0 {
+		select {
+		case uh.newChunks <- struct{}{}:
+		default:
+		}
+	}
+	return len(added)
+}
+
+// managedPop removes the least complete chunk from the heap, or returns nil if
+// the heap is empty. The chunk remains tracked until managedDone is called.
+func (uh *uploadHeap) managedPop() *unfinishedChunk {
+	uh.mu.Lock()
+	defer uh.mu.Unlock()
+	if len(uh.heap) == 0 {
+		return nil
+	}
+	uc := heap.Pop(&uh.heap).(*unfinishedChunk)
+	if len(uh.heap) == 0 {
+		close(uh.empty)
+	}
+	return uc
+}
+
+// managedDone stops tracking a chunk once the workers are done with it, or
+// once it turned out that the chunk cannot be uploaded. Chunks that were not
+// added to the heap are ignored.
+func (uh *uploadHeap) managedDone(uc *unfinishedChunk) {
+	uh.mu.Lock()
+	defer uh.mu.Unlock()
+	id := uploadChunkID{uc.renterFile, uc.index}
+	if uh.active[id] != uc {
+		return
+	}
+	delete(uh.active, id)
+	if len(uh.active) == 0 {
+		close(uh.idle)
+	}
+}
+
+// managedLen returns the number of chunks that are waiting in the heap, and
+// the number of chunks that are tracked, including those being uploaded.
+func (uh *uploadHeap) managedLen() (waiting, active int) {
+	uh.mu.Lock()
+	defer uh.mu.Unlock()
+	return len(uh.heap), len(uh.active)
+}
+
+// managedEmpty returns a channel that is closed once the heap is empty.
+func (uh *uploadHeap) managedEmpty() <-chan struct{} {
+	uh.mu.Lock()
+	defer uh.mu.Unlock()
+	return uh.empty
+}
+
+// managedIdle returns a channel that is closed once no chunks are in the heap
+// or being uploaded.
+func (uh *uploadHeap) managedIdle() <-chan struct{} {
+	uh.mu.Lock()
+	defer uh.mu.Unlock()
+	return uh.idle
+}
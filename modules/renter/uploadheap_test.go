@@ -0,0 +1,70 @@
+package renter
+
+import (
+	"testing"
+
+	"github.com/pachisi456/Sia/modules"
+)
+
+// TestUploadHeap checks that the upload heap hands out the least complete
+// chunks first, and that chunks are not queued again until they are done.
+func TestUploadHeap(t *testing.T) {
+	rsc, _ := NewRSCode(1, 1)
+	f := newFile("foo", rsc, modules.SectorSize, 2*modules.SectorSize)
+	chunk := func(index uint64, completed int) *unfinishedChunk {
+		return &unfinishedChunk{renterFile: f, index: index, piecesNeeded: 2, piecesCompleted: completed}
+	}
+	closed := func(c <-chan struct{}) bool {
+		select {
+		case <-c:
+			return true
+		default:
+			return false
+		}
+	}
+
+	uh := newUploadHeap()
+	if !closed(uh.managedEmpty()) || !closed(uh.managedIdle()) {
+		t.Fatal("new heap should be empty and idle")
+	}
+	if n := uh.managedPush([]*unfinishedChunk{chunk(0, 1), chunk(1, 0)}); n != 2 {
+		t.Fatal("expected 2 chunks to be added, got", n)
+	}
+	if closed(uh.managedEmpty()) || closed(uh.managedIdle()) {
+		t.Fatal("heap with chunks should be neither empty nor idle")
+	}
+
+	// The least complete chunk comes first.
+	first := uh.managedPop()
+	if first.index != 1 {
+		t.Fatal("expected chunk 1 to be popped first, got", first.index)
+	}
+
+	// Chunks that are queued or being uploaded are not added again.
+	if n := uh.managedPush([]*unfinishedChunk{chunk(0, 1), chunk(1, 0)}); n != 0 {
+		t.Fatal("expected no chunks to be added, got", n)
+	}
+	second := uh.managedPop()
+	if !closed(uh.managedEmpty()) || uh.managedPop() != nil {
+		t.Fatal("heap should be empty")
+	}
+
+	// A chunk with the same ID that is not tracked by the heap does not
+	// affect the tracked chunk.
+	uh.managedDone(chunk(1, 0))
+	if n := uh.managedPush([]*unfinishedChunk{chunk(1, 0)}); n != 0 {
+		t.Fatal("untracked chunk released a tracked chunk")
+	}
+
+	uh.managedDone(first)
+	if closed(uh.managedIdle()) {
+		t.Fatal("heap should not be idle while a chunk is being uploaded")
+	}
+	uh.managedDone(second)
+	if !closed(uh.managedIdle()) {
+		t.Fatal("heap should be idle once all chunks are done")
+	}
+	if n := uh.managedPush([]*unfinishedChunk{chunk(1, 0)}); n != 1 {
+		t.Fatal("expected a finished chunk to be queued again, got", n)
+	}
+}
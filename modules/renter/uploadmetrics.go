@@ -0,0 +1,97 @@
+package renter
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/persist"
+	"github.com/pachisi456/Sia/types"
+)
+
+// pieceUploadTiming contains the time taken to upload a single piece to the
+// host of a contract.
+type pieceUploadTiming struct {
+	contract        types.FileContractID
+	bytes           uint64
+	negotiationTime time.Duration
+	transferTime    time.Duration
+}
+
+// uploadMetricsLog keeps the timings of the most recently finished chunk
+// uploads, oldest first.
+type uploadMetricsLog struct {
+	metrics []modules.ChunkUploadMetrics
+	mu      sync.Mutex
+}
+
+// add appends the metrics of a finished chunk, dropping the oldest entry if
+// the log is full.
+func (l *uploadMetricsLog) add(m modules.ChunkUploadMetrics) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.metrics) >= uploadMetricsHistory {
+		l.metrics = append(l.metrics[:0], l.metrics[1:]...)
+	}
+	l.metrics = append(l.metrics, m)
+}
+
+// managedRecordUploadMetrics adds the timings of a chunk to the renter's
+// upload metrics. It is called once every worker has finished with the chunk.
+func (r *Renter) managedRecordUploadMetrics(uc *unfinishedChunk) {
+	uc.mu.Lock()
+	m := modules.ChunkUploadMetrics{
+		ChunkIndex:        uc.index,
+		Repair:            uc.repair,
+		PiecesUploaded:    uc.piecesCompleted,
+		ErasureCodingTime: uc.erasureCodingTime,
+		EncryptionTime:    uc.encryptionTime,
+		NegotiationTime:   uc.negotiationTime,
+		TransferTime:      uc.transferTime,
+		Completed:         time.Now(),
+	}
+	duration := time.Since(uc.distributedAt)
+	uc.mu.Unlock()
+	if m.Repair {
+		r.metrics.chunksUploaded.Inc("kind", "repair")
+	} else {
+		r.metrics.chunksUploaded.Inc("kind", "upload")
+	}
+
+	uc.renterFile.mu.RLock()
+	m.SiaPath = uc.renterFile.name
+	uc.renterFile.mu.RUnlock()
+	r.uploadMetrics.add(m)
+	r.log.Event("Chunk upload finished", persist.LogFields{
+		SiaPath:    m.SiaPath,
+		ChunkIndex: &m.ChunkIndex,
+		Duration:   duration,
+	})
+}
+
+// managedLogPieceEvent logs an event concerning the piece of uc that was sent
+// to the host of a contract. Piece events are only logged in structured mode,
+// as there are too many of them for a text log.
+func (r *Renter) managedLogPieceEvent(msg string, uc *unfinishedChunk, contract types.FileContractID, d time.Duration) {
+	if !r.log.Structured() {
+		return
+	}
+	uc.renterFile.mu.RLock()
+	siapath := uc.renterFile.name
+	uc.renterFile.mu.RUnlock()
+	index := uc.index
+	r.log.Event(msg, persist.LogFields{
+		ContractID: contract.String(),
+		SiaPath:    siapath,
+		ChunkIndex: &index,
+		Duration:   d,
+	})
+}
+
+// UploadMetrics returns the per-stage timings of the most recently finished
+// chunk uploads, oldest first.
+func (r *Renter) UploadMetrics() []modules.ChunkUploadMetrics {
+	r.uploadMetrics.mu.Lock()
+	defer r.uploadMetrics.mu.Unlock()
+	return append([]modules.ChunkUploadMetrics(nil), r.uploadMetrics.metrics...)
+}
@@ -0,0 +1,174 @@
+package renter
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pachisi456/Sia/crypto"
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/types"
+
+	"github.com/NebulousLabs/fastrand"
+)
+
+// TestUploadMetrics checks that the timings of a chunk are recorded once all
+// workers have finished with it.
+func TestUploadMetrics(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	dc := &dedupContractor{
+		hostContractor: rt.renter.hostContractor,
+		contract: modules.RenterContract{
+			ID:            types.FileContractID{1},
+			HostPublicKey: types.SiaPublicKey{Key: []byte("foo")},
+			GoodForUpload: true,
+		},
+		host: &mockHost{sectors: make(map[crypto.Hash][]byte)},
+	}
+	id := rt.renter.mu.Lock()
+	rt.renter.hostContractor = dc
+	rt.renter.mu.Unlock(id)
+	rt.renter.managedUpdateWorkerPool()
+
+	rsc, _ := NewRSCode(1, 1)
+	f := newFile("foo", rsc, modules.SectorSize, modules.SectorSize)
+	data := fastrand.Bytes(int(modules.SectorSize))
+	rt.renter.managedMemoryAvailableSub(uint64(len(data)), memoryUploadSectors, memoryClassUserUpload)
+	uc := &unfinishedChunk{
+		renterFile:        f,
+		index:             3,
+		memoryNeeded:      uint64(len(data)),
+		minimumPieces:     1,
+		piecesNeeded:      1,
+		physicalChunkData: [][]byte{data},
+		pieceUsage:        make([]bool, 1),
+		unusedHosts: map[string]struct{}{
+			dc.contract.HostPublicKey.String(): {},
+		},
+	}
+	rt.renter.managedDistributeChunkToWorkers(uc)
+	rt.renter.heapWG.Wait()
+
+	metrics := rt.renter.UploadMetrics()
+	if len(metrics) != 1 {
+		t.Fatal("expected metrics for 1 chunk, got", len(metrics))
+	}
+	m := metrics[0]
+	if m.SiaPath != "foo" || m.ChunkIndex != 3 || m.PiecesUploaded != 1 {
+		t.Fatal("metrics do not match the uploaded chunk:", m)
+	}
+	if m.TransferTime == 0 {
+		t.Fatal("expected a nonzero transfer time")
+	}
+
+	// Only the most recent chunks are kept.
+	for i := 0; i < uploadMetricsHistory; i++ {
+		rt.renter.uploadMetrics.add(modules.ChunkUploadMetrics{ChunkIndex: uint64(i)})
+	}
+	metrics = rt.renter.UploadMetrics()
+	if len(metrics) != uploadMetricsHistory || metrics[0].ChunkIndex != 0 {
+		t.Fatal("expected only the most recent chunks to be kept, got", metrics)
+	}
+}
+
+// TestStructuredUploadLog checks that the upload of a chunk is logged as JSON
+// lines with the chunk's fields while the log is in structured mode.
+func TestStructuredUploadLog(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	settings := rt.renter.Settings()
+	settings.StructuredLogging = true
+	if err := rt.renter.SetSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+	if !rt.renter.Settings().StructuredLogging {
+		t.Fatal("structured logging was not enabled")
+	}
+
+	dc := &dedupContractor{
+		hostContractor: rt.renter.hostContractor,
+		contract: modules.RenterContract{
+			ID:            types.FileContractID{1},
+			HostPublicKey: types.SiaPublicKey{Key: []byte("foo")},
+			GoodForUpload: true,
+		},
+		host: &mockHost{sectors: make(map[crypto.Hash][]byte)},
+	}
+	id := rt.renter.mu.Lock()
+	rt.renter.hostContractor = dc
+	rt.renter.mu.Unlock(id)
+	rt.renter.managedUpdateWorkerPool()
+
+	rsc, _ := NewRSCode(1, 1)
+	f := newFile("foo", rsc, modules.SectorSize, modules.SectorSize)
+	data := fastrand.Bytes(int(modules.SectorSize))
+	rt.renter.managedMemoryAvailableSub(uint64(len(data)), memoryUploadSectors, memoryClassUserUpload)
+	uc := &unfinishedChunk{
+		renterFile:        f,
+		index:             3,
+		memoryNeeded:      uint64(len(data)),
+		minimumPieces:     1,
+		piecesNeeded:      1,
+		physicalChunkData: [][]byte{data},
+		pieceUsage:        make([]bool, 1),
+		unusedHosts: map[string]struct{}{
+			dc.contract.HostPublicKey.String(): {},
+		},
+	}
+	rt.renter.managedDistributeChunkToWorkers(uc)
+	rt.renter.heapWG.Wait()
+
+	logData, err := ioutil.ReadFile(filepath.Join(rt.renter.persistDir, logFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := make(map[string]map[string]interface{})
+	for _, line := range strings.Split(string(logData), "\n") {
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatal("structured entry is not valid JSON:", line)
+		}
+		if entry["module"] != logModule {
+			t.Fatal("structured entry has the wrong module:", line)
+		}
+		events[entry["msg"].(string)] = entry
+	}
+
+	piece, ok := events["Piece uploaded"]
+	if !ok {
+		t.Fatal("piece upload was not logged:", string(logData))
+	}
+	if piece["contractID"] != dc.contract.ID.String() || piece["siapath"] != "foo" || piece["chunkIndex"] != float64(3) {
+		t.Fatal("piece upload has the wrong fields:", piece)
+	}
+	if _, ok := piece["durationMs"].(float64); !ok {
+		t.Fatal("piece upload has no duration:", piece)
+	}
+	chunk, ok := events["Chunk upload finished"]
+	if !ok {
+		t.Fatal("chunk upload was not logged:", string(logData))
+	}
+	if chunk["siapath"] != "foo" || chunk["chunkIndex"] != float64(3) {
+		t.Fatal("chunk upload has the wrong fields:", chunk)
+	}
+}
@@ -0,0 +1,79 @@
+package renter
+
+import (
+	"time"
+
+	"github.com/pachisi456/Sia/modules"
+)
+
+// uploadProgressBufferSize is the number of upload progress events that are
+// buffered for each subscriber. Events are dropped for subscribers that fall
+// further behind.
+const uploadProgressBufferSize = 64
+
+// managedNotifyUploadProgress sends the progress of the file of a finished
+// chunk to every subscriber of the file without blocking.
+func (r *Renter) managedNotifyUploadProgress(uc *unfinishedChunk) {
+	uc.mu.Lock()
+	up := modules.UploadProgress{
+		ChunkIndex:   uc.index,
+		PiecesStored: uc.piecesCompleted,
+		Time:         time.Now(),
+	}
+	uc.mu.Unlock()
+
+	id := r.mu.RLock()
+	defer r.mu.RUnlock(id)
+	f := uc.renterFile
+	f.mu.RLock()
+	up.SiaPath = f.name
+	var subscribed bool
+	for _, siapath := range r.uploadProgressSubscribers {
+		subscribed = subscribed || siapath == up.SiaPath
+	}
+	if subscribed {
+		up.BytesUploaded = f.uploadedBytes()
+		up.UploadProgress = f.uploadProgress()
+		up.Redundancy = f.redundancy(r.isOffline)
+	}
+	f.mu.RUnlock()
+
+	for c, siapath := range r.uploadProgressSubscribers {
+		if siapath != up.SiaPath {
+			continue
+		}
+		select {
+		case c <- up:
+		default:
+			r.log.Debugln("dropping upload progress event for a slow subscriber")
+		}
+	}
+}
+
+// SubscribeUploadProgress returns a channel that receives an UploadProgress
+// whenever a chunk of the file at siapath finishes uploading. The channel is
+// closed once cancel is closed or the renter shuts down.
+func (r *Renter) SubscribeUploadProgress(siapath string, cancel <-chan struct{}) <-chan modules.UploadProgress {
+	c := make(chan modules.UploadProgress, uploadProgressBufferSize)
+	if err := r.tg.Add(); err != nil {
+		close(c)
+		return c
+	}
+	defer r.tg.Done()
+
+	id := r.mu.Lock()
+	r.uploadProgressSubscribers[c] = siapath
+	r.mu.Unlock(id)
+
+	go func() {
+		select {
+		case <-cancel:
+		case <-r.tg.StopChan():
+		}
+		id := r.mu.Lock()
+		delete(r.uploadProgressSubscribers, c)
+		r.mu.Unlock(id)
+		close(c)
+	}()
+	return c
+}
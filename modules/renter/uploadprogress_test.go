@@ -0,0 +1,91 @@
+package renter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NebulousLabs/fastrand"
+	"github.com/pachisi456/Sia/crypto"
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/types"
+)
+
+// TestUploadProgress checks that subscribers receive an event for every
+// finished chunk of the file they subscribed to, and only for that file.
+func TestUploadProgress(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	sc := streamContractor{&dedupContractor{
+		hostContractor: rt.renter.hostContractor,
+		contract: modules.RenterContract{
+			ID:            types.FileContractID{1},
+			HostPublicKey: types.SiaPublicKey{Key: []byte("foo")},
+			GoodForUpload: true,
+		},
+		host: &mockHost{sectors: make(map[crypto.Hash][]byte)},
+	}}
+	id := rt.renter.mu.Lock()
+	rt.renter.hostContractor = sc
+	rt.renter.mu.Unlock(id)
+	rt.renter.managedUpdateWorkerPool()
+
+	cancel := make(chan struct{})
+	progress := rt.renter.SubscribeUploadProgress("foo", cancel)
+	otherCancel := make(chan struct{})
+	defer close(otherCancel)
+	other := rt.renter.SubscribeUploadProgress("bar", otherCancel)
+
+	// Upload one of the two pieces of the second chunk of the file.
+	rsc, _ := NewRSCode(1, 1)
+	f := newFile("foo", rsc, modules.SectorSize, 2*modules.SectorSize)
+	data := fastrand.Bytes(int(modules.SectorSize))
+	rt.renter.managedMemoryAvailableSub(uint64(len(data)), memoryUploadSectors, memoryClassUserUpload)
+	uc := &unfinishedChunk{
+		renterFile:        f,
+		index:             1,
+		memoryNeeded:      uint64(len(data)),
+		minimumPieces:     1,
+		piecesNeeded:      1,
+		physicalChunkData: [][]byte{data},
+		pieceUsage:        make([]bool, 1),
+		unusedHosts: map[string]struct{}{
+			sc.contract.HostPublicKey.String(): {},
+		},
+	}
+	rt.renter.managedDistributeChunkToWorkers(uc)
+
+	select {
+	case up := <-progress:
+		if up.SiaPath != "foo" || up.ChunkIndex != 1 || up.PiecesStored != 1 {
+			t.Fatal("wrong chunk in upload progress:", up)
+		}
+		if up.BytesUploaded != modules.SectorSize || up.UploadProgress != 25 {
+			t.Fatal("wrong file progress in upload progress:", up)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("no upload progress received")
+	}
+	select {
+	case up := <-other:
+		t.Fatal("subscriber of another file received upload progress:", up)
+	default:
+	}
+
+	// Once cancelled, the channel is closed.
+	close(cancel)
+	select {
+	case _, ok := <-progress:
+		if ok {
+			t.Fatal("received upload progress after cancelling")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("channel was not closed after cancelling")
+	}
+}
@@ -0,0 +1,226 @@
+package renter
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/pachisi456/Sia/crypto"
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/persist"
+)
+
+var (
+	errStreamEncoding   = errors.New("unable to encode a chunk of the stream")
+	errStreamIncomplete = errors.New("not enough pieces of the stream were uploaded to recover it")
+)
+
+// UploadStream uploads the data read from src to a new file at siapath,
+// without requiring the data to be stored on disk first. The data is read one
+// chunk at a time, and reading a chunk waits until the memory manager can
+// provide the memory needed to encode and upload it, so a stream never holds
+// more memory than a regular upload. A nil erasure coder selects the default
+// erasure code.
+//
+// UploadStream returns once the workers are done with every chunk. Only then
+// is the file added to the renter's files. As there is no local copy of the
+// data, the file is repaired using data downloaded from its hosts.
+func (r *Renter) UploadStream(siapath string, src io.Reader, ec modules.ErasureCoder) error {
+	if err := r.tg.Add(); err != nil {
+		return ErrRenterShutdown
+	}
+	defer r.tg.Done()
+
+	if isReservedSiapath(siapath) {
+		return errReservedSiapath
+	}
+	return r.managedUploadStream(siapath, src, ec)
+}
+
+// managedUploadStream uploads a stream to a new file at siapath without
+// checking whether the siapath is reserved, see UploadStream.
+func (r *Renter) managedUploadStream(siapath string, src io.Reader, ec modules.ErasureCoder) error {
+	if err := validateSiapath(siapath); err != nil {
+		return err
+	}
+	if ec == nil {
+		ec, _ = NewRSCode(defaultDataPieces, defaultParityPieces)
+	}
+	if err := r.checkUploadContracts(ec); err != nil {
+		return err
+	}
+
+	// Reserve the siapath for the duration of the upload, as well as the
+	// space of the empty file. The space is reserved as the file grows.
+	f := newFile(siapath, ec, pieceSize, 0)
+	logical, redundant := storedBytes(f)
+	id := r.mu.Lock()
+	if r.siapathInUse(siapath) {
+		r.mu.Unlock(id)
+		return ErrPathOverload
+	}
+	if err := r.reserveStorage(logical, redundant, "", false); err != nil {
+		r.mu.Unlock(id)
+		return err
+	}
+	r.streamingUploads[siapath] = struct{}{}
+	r.mu.Unlock(id)
+	defer func() {
+		id := r.mu.Lock()
+		delete(r.streamingUploads, siapath)
+		r.mu.Unlock(id)
+	}()
+
+	// Upload the stream and wait for the workers to finish with every chunk
+	// that was handed to them, so that the file is no longer modified by the
+	// workers.
+	chunks, err := r.managedUploadStreamChunks(f, src)
+	if err = r.managedWaitForStreamChunks(chunks, err); err != nil {
+		// The workers save the file as pieces are uploaded, so the partial
+		// file has to be removed from disk again.
+		r.managedRemoveStreamFile(f)
+		return err
+	}
+
+	// Add the file to the renter. The file is tracked without a repair path,
+	// and its space has already been reserved.
+	id = r.mu.Lock()
+	defer r.mu.Unlock(id)
+	r.files[siapath] = f
+	r.tracking[siapath] = trackedFile{}
+	r.saveSync()
+	return r.saveFile(f)
+}
+
+// managedUploadStreamChunks reads src one chunk at a time, growing f as data
+// is read, and hands every chunk to the workers. It returns the chunks that
+// were handed to the workers, including if an error occurred.
+func (r *Renter) managedUploadStreamChunks(f *file, src io.Reader) ([]*unfinishedChunk, error) {
+	hosts := r.managedRefreshHostsAndWorkers()
+	var chunks []*unfinishedChunk
+	for index := uint64(0); ; index++ {
+		uc := &unfinishedChunk{
+			renterFile: f,
+
+			index:  index,
+			length: f.chunkSize(),
+			offset: int64(index * f.chunkSize()),
+
+			memoryNeeded:  f.pieceSize*uint64(f.erasureCode.NumPieces()+f.erasureCode.MinPieces()) + uint64(f.erasureCode.NumPieces()*crypto.TwofishOverhead),
+			minimumPieces: f.erasureCode.MinPieces(),
+			piecesNeeded:  f.erasureCode.NumPieces(),
+			pieceUsage:    make([]bool, f.erasureCode.NumPieces()),
+			unusedHosts:   make(map[string]struct{}),
+
+			done: make(chan struct{}),
+		}
+		for host := range hosts {
+			uc.unusedHosts[host] = struct{}{}
+		}
+		uc.traceEvent("chunk queued")
+
+		// Wait for memory before reading the chunk, so that the reader is not
+		// drained faster than the data can be uploaded. No new chunks are
+		// started while the renter is shutting down.
+		if r.isDraining() {
+			return chunks, ErrRenterShutdown
+		}
+		waitStart := time.Now()
+		memoryAvailable := r.managedMemoryAvailableGet(uc.memoryClass())
+		for uc.memoryNeeded > memoryAvailable {
+			select {
+			case <-r.newMemory:
+				memoryAvailable = r.managedMemoryAvailableGet(uc.memoryClass())
+			case <-r.drainChan:
+				return chunks, ErrRenterShutdown
+			case <-r.tg.StopChan():
+				return chunks, ErrRenterShutdown
+			}
+		}
+		r.metrics.memoryWaitSeconds.Add(time.Since(waitStart).Seconds())
+		uc.traceEvent("memory acquired")
+		r.managedMemoryAvailableSub(uc.memoryNeeded, memoryEncoding, uc.memoryClass())
+
+		// Read the chunk. The last chunk is padded with zeros. Like an empty
+		// file, an empty stream still consists of a single chunk.
+		data := make([]byte, uc.length)
+		n, err := io.ReadFull(src, data)
+		if err == io.EOF && index > 0 {
+			r.managedMemoryAvailableAdd(uc.memoryNeeded, memoryEncoding, uc.memoryClass())
+			return chunks, nil
+		} else if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			r.managedMemoryAvailableAdd(uc.memoryNeeded, memoryEncoding, uc.memoryClass())
+			return chunks, err
+		}
+		uc.logicalChunkData = data
+
+		// Grow the file, reserving the additional space. If the file no
+		// longer fits within the storage caps, it keeps its previous size.
+		f.mu.Lock()
+		oldLogical, oldRedundant := storedBytes(f)
+		f.size += uint64(n)
+		logical, redundant := storedBytes(f)
+		f.mu.Unlock()
+		id := r.mu.Lock()
+		err = r.reserveStorage(logical-oldLogical, redundant-oldRedundant, "", false)
+		r.mu.Unlock(id)
+		if err != nil {
+			f.mu.Lock()
+			f.size -= uint64(n)
+			f.mu.Unlock()
+			r.managedMemoryAvailableAdd(uc.memoryNeeded, memoryEncoding, uc.memoryClass())
+			return chunks, err
+		}
+
+		// Encode the chunk and hand it to the workers.
+		r.heapWG.Add(1)
+		workDistributed := r.managedFetchAndRepairChunk(uc)
+		r.heapWG.Done()
+		if !workDistributed {
+			r.managedMemoryAvailableAdd(uc.memoryNeeded-uc.memoryReleased, memoryEncoding, uc.memoryClass())
+			return chunks, errStreamEncoding
+		}
+		chunks = append(chunks, uc)
+		if n < len(data) {
+			return chunks, nil
+		}
+	}
+}
+
+// managedWaitForStreamChunks waits for the workers to finish with the chunks
+// of a stream. 'err' is the error returned when the chunks were handed to the
+// workers; if it is nil, errStreamIncomplete is returned if any chunk did not
+// receive enough pieces to be recoverable.
+func (r *Renter) managedWaitForStreamChunks(chunks []*unfinishedChunk, err error) error {
+	for _, uc := range chunks {
+		select {
+		case <-uc.done:
+		case <-r.tg.StopChan():
+			return ErrRenterShutdown
+		}
+	}
+	for _, uc := range chunks {
+		if err != nil {
+			break
+		}
+		uc.mu.Lock()
+		if uc.piecesCompleted < uc.minimumPieces {
+			err = errStreamIncomplete
+		}
+		uc.mu.Unlock()
+	}
+	return err
+}
+
+// managedRemoveStreamFile removes the .sia file of a stream that is not
+// added to the renter from disk, and releases the space reserved by the
+// stream.
+func (r *Renter) managedRemoveStreamFile(f *file) {
+	id := r.mu.Lock()
+	err := persist.RemoveFile(r.siaFilePath(f.name))
+	r.releaseStorage(storedBytes(f))
+	r.mu.Unlock(id)
+	if err != nil {
+		r.log.Println("WARN: couldn't remove the .sia file of a stream:", err)
+	}
+}
@@ -0,0 +1,116 @@
+package renter
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/pachisi456/Sia/build"
+	"github.com/pachisi456/Sia/crypto"
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/modules/renter/contractor"
+	"github.com/pachisi456/Sia/types"
+
+	"github.com/NebulousLabs/fastrand"
+)
+
+// streamContractor is a dedupContractor that can also download from its
+// host.
+type streamContractor struct {
+	*dedupContractor
+}
+
+func (sc streamContractor) ResolveID(id types.FileContractID) types.FileContractID { return id }
+func (sc streamContractor) Downloader(types.FileContractID, <-chan struct{}) (contractor.Downloader, error) {
+	return sc.host, nil
+}
+
+// failingReader returns its data, followed by an error.
+type failingReader struct {
+	r io.Reader
+}
+
+func (fr *failingReader) Read(b []byte) (int, error) {
+	n, err := fr.r.Read(b)
+	if err == io.EOF {
+		return n, errors.New("stream failed")
+	}
+	return n, err
+}
+
+// TestUploadStream checks that data uploaded from a reader can be downloaded
+// again, and that the siapath of a stream cannot be reused.
+func TestUploadStream(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	sc := streamContractor{&dedupContractor{
+		hostContractor: rt.renter.hostContractor,
+		contract: modules.RenterContract{
+			ID:            types.FileContractID{1},
+			HostPublicKey: types.SiaPublicKey{Key: []byte("foo")},
+			GoodForUpload: true,
+		},
+		host: &mockHost{sectors: make(map[crypto.Hash][]byte)},
+	}}
+	id := rt.renter.mu.Lock()
+	rt.renter.hostContractor = sc
+	rt.renter.mu.Unlock(id)
+
+	// Upload two and a half chunks.
+	rsc, _ := NewRSCode(1, 1)
+	data := fastrand.Bytes(int(pieceSize * 5 / 2))
+	if err := rt.renter.UploadStream("foo", bytes.NewReader(data), rsc); err != nil {
+		t.Fatal(err)
+	}
+	files := rt.renter.FileList()
+	if len(files) != 1 || files[0].SiaPath != "foo" || files[0].Filesize != uint64(len(data)) {
+		t.Fatal("streamed file was not added correctly:", files)
+	}
+	sc.host.mu.Lock()
+	numSectors := len(sc.host.sectors)
+	sc.host.mu.Unlock()
+	if numSectors != 3 {
+		t.Fatal("expected 3 sectors on the host, got", numSectors)
+	}
+
+	// Download the file again.
+	dst := filepath.Join(build.TempDir("renter", t.Name()), "foo")
+	err = rt.renter.Download(modules.RenterDownloadParameters{
+		Siapath:     "foo",
+		Destination: dst,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	downloaded, err := ioutil.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(downloaded, data) {
+		t.Fatal("downloaded data does not match the stream")
+	}
+
+	// The siapath is taken now.
+	if err := rt.renter.UploadStream("foo", bytes.NewReader(data), rsc); err != ErrPathOverload {
+		t.Fatal("expected ErrPathOverload, got", err)
+	}
+
+	// A stream that fails is not added to the renter.
+	err = rt.renter.UploadStream("bar", &failingReader{bytes.NewReader(data)}, rsc)
+	if err == nil {
+		t.Fatal("expected the failing stream to fail")
+	}
+	if len(rt.renter.FileList()) != 1 {
+		t.Fatal("failed stream was added to the renter")
+	}
+}
@@ -0,0 +1,94 @@
+package renter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/pachisi456/Sia/profile"
+)
+
+// uploadTraceCategory is the category of the pipeline events that are logged
+// in an upload trace.
+const uploadTraceCategory = "upload"
+
+var errNoUploadTrace = errors.New("no upload trace is being recorded")
+
+// StartUploadTrace starts recording an execution trace of the renter, in
+// which the pipeline events of every chunk that is uploaded are grouped in a
+// task. The trace is written to a file in the renter's persist directory, and
+// can be viewed with 'go tool trace' once StopUploadTrace has been called. The
+// path of the file is returned.
+func (r *Renter) StartUploadTrace() (string, error) {
+	if err := r.tg.Add(); err != nil {
+		return "", ErrRenterShutdown
+	}
+	defer r.tg.Done()
+
+	r.uploadTraceMu.Lock()
+	defer r.uploadTraceMu.Unlock()
+	if r.uploadTraceFile != "" {
+		return "", errors.New("an upload trace is already being recorded")
+	}
+	filename := filepath.Join(r.persistDir, "upload-"+time.Now().UTC().Format("20060102T150405")+".trace")
+	if err := profile.StartTraceFile(filename); err != nil {
+		return "", err
+	}
+	r.uploadTraceFile = filename
+	return filename, nil
+}
+
+// StopUploadTrace stops recording the upload trace and closes its file.
+func (r *Renter) StopUploadTrace() error {
+	r.uploadTraceMu.Lock()
+	defer r.uploadTraceMu.Unlock()
+	if r.uploadTraceFile == "" {
+		return errNoUploadTrace
+	}
+	r.uploadTraceFile = ""
+	return profile.StopTrace()
+}
+
+// traceContext returns the context of the chunk's trace task, starting the
+// task if necessary.
+func (uc *unfinishedChunk) traceContext() context.Context {
+	uc.traceOnce.Do(func() {
+		uc.renterFile.mu.RLock()
+		siapath := uc.renterFile.name
+		uc.renterFile.mu.RUnlock()
+		uc.traceCtx, uc.traceEnd = profile.NewTraceTask(context.Background(), "uploadChunk")
+		profile.TraceLog(uc.traceCtx, uploadTraceCategory, fmt.Sprintf("chunk %v of %v", uc.index, siapath))
+	})
+	return uc.traceCtx
+}
+
+// traceEvent logs a pipeline event of the chunk in the upload trace, if one is
+// being recorded.
+func (uc *unfinishedChunk) traceEvent(event string) {
+	if !profile.TraceEnabled() {
+		return
+	}
+	profile.TraceLog(uc.traceContext(), uploadTraceCategory, event)
+}
+
+// traceRegion marks the start of a region of the calling goroutine in the
+// chunk's trace task, if an upload trace is being recorded. The returned
+// function marks its end.
+func (uc *unfinishedChunk) traceRegion(name string) (end func()) {
+	if !profile.TraceEnabled() {
+		return func() {}
+	}
+	return profile.StartTraceRegion(uc.traceContext(), name)
+}
+
+// traceFinish logs the final event of the chunk and ends its trace task. No
+// task is started for the chunk afterwards.
+func (uc *unfinishedChunk) traceFinish() {
+	uc.traceEvent("chunk finished")
+	uc.traceOnce.Do(func() {})
+	if uc.traceEnd != nil {
+		uc.traceEnd()
+	}
+}
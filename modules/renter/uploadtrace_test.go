@@ -0,0 +1,59 @@
+package renter
+
+import (
+	"os"
+	"testing"
+
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/profile"
+)
+
+// TestUploadTrace checks that an upload trace can be started and stopped, and
+// that the events of a chunk are grouped in a trace task while it runs.
+func TestUploadTrace(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	if err := rt.renter.StopUploadTrace(); err != errNoUploadTrace {
+		t.Fatal("expected errNoUploadTrace, got", err)
+	}
+	path, err := rt.renter.StartUploadTrace()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rt.renter.StartUploadTrace(); err == nil {
+		t.Fatal("started a second upload trace")
+	}
+
+	rsc, _ := NewRSCode(1, 1)
+	uc := &unfinishedChunk{
+		renterFile: newFile("foo", rsc, modules.SectorSize, modules.SectorSize),
+	}
+	uc.traceEvent("chunk queued")
+	end := uc.traceRegion("upload piece")
+	end()
+	if profile.TraceEnabled() && uc.traceCtx == nil {
+		t.Fatal("no trace task was started for the chunk")
+	}
+	uc.traceFinish()
+
+	if err := rt.renter.StopUploadTrace(); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() == 0 {
+		t.Fatal("trace file is empty")
+	}
+	if err := rt.renter.StopUploadTrace(); err != errNoUploadTrace {
+		t.Fatal("expected errNoUploadTrace, got", err)
+	}
+}
@@ -0,0 +1,81 @@
+package renter
+
+import (
+	"errors"
+	"time"
+
+	"github.com/pachisi456/Sia/modules"
+)
+
+var (
+	errInvalidWaitRedundancy = errors.New("redundancy to wait for must be positive")
+	errUploadWaitCanceled    = errors.New("waiting for the upload was canceled")
+	errUploadWaitDeleted     = errors.New("file was deleted before it reached the redundancy")
+	errUploadWaitFailed      = errors.New("renter gave up on uploading the file before it reached the redundancy")
+)
+
+// UploadWait uploads a file like Upload, but only returns once the file
+// reaches the given redundancy on online hosts, so that callers can implement
+// write-through semantics. A redundancy of 1 waits until the file can be
+// recovered from the network. Redundancies above the target redundancy of the
+// file's erasure code are capped at the target, so math.Inf(1) waits until the
+// file is fully uploaded. The file keeps uploading if waiting is canceled by
+// closing cancel.
+func (r *Renter) UploadWait(up modules.FileUploadParams, redundancy float64, cancel <-chan struct{}) error {
+	if err := r.tg.Add(); err != nil {
+		return ErrRenterShutdown
+	}
+	defer r.tg.Done()
+
+	if isReservedSiapath(up.SiaPath) {
+		return errReservedSiapath
+	}
+	if !(redundancy > 0) {
+		return errInvalidWaitRedundancy
+	}
+	f, err := r.managedUpload(up)
+	if err != nil {
+		return err
+	}
+	return r.managedWaitRedundancy(f, redundancy, cancel)
+}
+
+// managedWaitRedundancy blocks until f reaches the given redundancy. The file
+// is followed through renames. An error is returned if the file is deleted or
+// the renter gives up on uploading it first.
+func (r *Renter) managedWaitRedundancy(f *file, redundancy float64, cancel <-chan struct{}) error {
+	f.mu.RLock()
+	target := float64(f.erasureCode.NumPieces()) / float64(f.erasureCode.MinPieces())
+	f.mu.RUnlock()
+	if redundancy > target {
+		redundancy = target
+	}
+
+	for {
+		id := r.mu.RLock()
+		f.mu.RLock()
+		name := f.name
+		current := f.redundancy(r.isOffline)
+		f.mu.RUnlock()
+		deleted := r.files[name] != f
+		tf := r.tracking[name]
+		r.mu.RUnlock(id)
+
+		// Empty files have a redundancy of -1, but have nothing to upload.
+		if deleted {
+			return errUploadWaitDeleted
+		} else if current >= redundancy || current == -1 {
+			return nil
+		} else if tf.Failed {
+			return errUploadWaitFailed
+		}
+
+		select {
+		case <-time.After(uploadWaitPollInterval):
+		case <-cancel:
+			return errUploadWaitCanceled
+		case <-r.tg.StopChan():
+			return ErrRenterShutdown
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package renter
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/types"
+)
+
+// TestWaitRedundancy checks that managedWaitRedundancy returns once the file
+// reaches the requested redundancy, and that waiting can be canceled or ended
+// by deleting the file.
+func TestWaitRedundancy(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	rc := redundancyContractor{
+		hostContractor: rt.renter.hostContractor,
+		contracts:      make(map[types.FileContractID]modules.RenterContract),
+	}
+	for i := byte(1); i <= 2; i++ {
+		rc.contracts[types.FileContractID{i}] = modules.RenterContract{
+			ID:           types.FileContractID{i},
+			GoodForRenew: true,
+		}
+	}
+	rsc, _ := NewRSCode(1, 1)
+	f := newFile("wait", rsc, 64, 64)
+	id := rt.renter.mu.Lock()
+	rt.renter.hostContractor = rc
+	rt.renter.files[f.name] = f
+	rt.renter.mu.Unlock(id)
+
+	// storePiece stores a piece of the file's only chunk with a contract.
+	storePiece := func(piece uint64, fcid types.FileContractID) {
+		f.mu.Lock()
+		f.contracts[fcid] = fileContract{
+			ID:     fcid,
+			Pieces: []pieceData{{Chunk: 0, Piece: piece}},
+		}
+		f.mu.Unlock()
+	}
+
+	// Waiting for the file to become recoverable should return once the
+	// first piece is stored.
+	errChan := make(chan error)
+	go func() {
+		errChan <- rt.renter.managedWaitRedundancy(f, 1, nil)
+	}()
+	select {
+	case err := <-errChan:
+		t.Fatal("wait returned before the file was recoverable:", err)
+	case <-time.After(3 * uploadWaitPollInterval):
+	}
+	storePiece(0, types.FileContractID{1})
+	select {
+	case err := <-errChan:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * uploadWaitPollInterval):
+		t.Fatal("wait did not return once the file was recoverable")
+	}
+
+	// Waiting for full redundancy can be canceled.
+	cancel := make(chan struct{})
+	close(cancel)
+	if err := rt.renter.managedWaitRedundancy(f, math.Inf(1), cancel); err != errUploadWaitCanceled {
+		t.Fatal("expected errUploadWaitCanceled, got", err)
+	}
+
+	// Redundancies above the target are capped at the target.
+	storePiece(1, types.FileContractID{2})
+	if err := rt.renter.managedWaitRedundancy(f, math.Inf(1), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Deleting the file ends the wait.
+	id = rt.renter.mu.Lock()
+	delete(rt.renter.files, f.name)
+	rt.renter.mu.Unlock(id)
+	if err := rt.renter.managedWaitRedundancy(f, 1, nil); err != errUploadWaitDeleted {
+		t.Fatal("expected errUploadWaitDeleted, got", err)
+	}
+
+	// The redundancy must be positive.
+	if err := rt.renter.UploadWait(modules.FileUploadParams{}, 0, nil); err != errInvalidWaitRedundancy {
+		t.Fatal("expected errInvalidWaitRedundancy, got", err)
+	}
+}
@@ -0,0 +1,116 @@
+package renter
+
+import (
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/types"
+)
+
+type (
+	// verifyErasureCoder wraps the erasure code of a file so that every piece
+	// of a chunk is required to recover it. Downloads using it fetch and
+	// authenticate all pieces instead of stopping at the minimum needed.
+	verifyErasureCoder struct {
+		modules.ErasureCoder
+	}
+
+	// discardWriter is a DownloadWriter that throws away everything written
+	// to it.
+	discardWriter struct{}
+)
+
+// MinPieces returns the total number of pieces, so that no chunk is recovered
+// until all of its pieces have been fetched.
+func (vec verifyErasureCoder) MinPieces() int { return vec.NumPieces() }
+
+// Destination implements modules.DownloadWriter.
+func (discardWriter) Destination() string { return "discard" }
+
+// WriteAt implements modules.DownloadWriter.
+func (discardWriter) WriteAt(b []byte, off int64) (int, error) { return len(b), nil }
+
+// Close implements modules.DownloadWriter.
+func (discardWriter) Close() error { return nil }
+
+// VerifyDownloadable checks that every piece of a file can be fetched from
+// the hosts storing it and decrypts correctly, without keeping the data. Each
+// chunk is downloaded separately so that a bad chunk does not prevent the
+// remaining chunks from being checked. A file that fails verification is
+// reported through the returned VerifyReport rather than as an error.
+func (r *Renter) VerifyDownloadable(nickname string) (modules.VerifyReport, error) {
+	report := modules.VerifyReport{SiaPath: nickname}
+	if err := r.tg.Add(); err != nil {
+		return report, ErrRenterShutdown
+	}
+	defer r.tg.Done()
+
+	lockID := r.mu.RLock()
+	file, exists := r.files[nickname]
+	r.mu.RUnlock(lockID)
+	if !exists {
+		return report, ErrUnknownPath
+	}
+	file.mu.RLock()
+	fileSize := file.size
+	chunkSize := file.chunkSize()
+	numChunks := file.numChunks()
+	file.mu.RUnlock()
+	if fileSize == 0 {
+		report.Verified = true
+		return report, nil
+	}
+
+	// Queue a download for every chunk. The downloads are not added to the
+	// download queue and bypass the chunk cache, as the data is discarded.
+	downloads := make([]*download, numChunks)
+	for i := range downloads {
+		offset := uint64(i) * chunkSize
+		length := chunkSize
+		if offset+length > fileSize {
+			length = fileSize - offset
+		}
+		d := r.newSectionDownload(file, discardWriter{}, offset, length)
+		d.erasureCode = verifyErasureCoder{d.erasureCode}
+		d.cache = nil
+		downloads[i] = d
+		select {
+		case r.newDownloads <- d:
+		case <-r.tg.StopChan():
+			return report, ErrRenterShutdown
+		}
+	}
+
+	// Collect the results of every chunk and tally them by host.
+	report.Verified = true
+	hostIndex := make(map[string]int)
+	hostResult := func(spk types.SiaPublicKey) *modules.HostVerifyResult {
+		i, exists := hostIndex[spk.String()]
+		if !exists {
+			i = len(report.Hosts)
+			hostIndex[spk.String()] = i
+			report.Hosts = append(report.Hosts, modules.HostVerifyResult{HostPublicKey: spk})
+		}
+		return &report.Hosts[i]
+	}
+	for i, d := range downloads {
+		select {
+		case <-d.downloadFinished:
+		case <-r.tg.StopChan():
+			return report, ErrRenterShutdown
+		}
+		cr := modules.DownloadChunkResult{Index: uint64(i)}
+		if chunks := d.Result().Chunks; len(chunks) > 0 {
+			cr = chunks[0]
+		}
+		for _, spk := range cr.Hosts {
+			hostResult(spk).PiecesFetched++
+		}
+		for _, spk := range cr.FailedHosts {
+			hostResult(spk).PiecesFailed++
+		}
+		if !cr.Verified || len(cr.FailedHosts) > 0 {
+			report.Verified = false
+		}
+		report.Chunks = append(report.Chunks, cr)
+	}
+	return report, nil
+}
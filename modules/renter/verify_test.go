@@ -0,0 +1,119 @@
+package renter
+
+import (
+	"testing"
+
+	"github.com/pachisi456/Sia/crypto"
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/modules/renter/contractor"
+	"github.com/pachisi456/Sia/types"
+
+	"github.com/NebulousLabs/fastrand"
+)
+
+// verifyContractor is a hostContractor with a mockHost for each contract.
+type verifyContractor struct {
+	hostContractor
+	contracts []modules.RenterContract
+	hosts     map[types.FileContractID]*mockHost
+}
+
+func (vc *verifyContractor) Contracts() []modules.RenterContract { return vc.contracts }
+func (vc *verifyContractor) ResolveID(id types.FileContractID) types.FileContractID {
+	return id
+}
+func (vc *verifyContractor) Downloader(id types.FileContractID, _ <-chan struct{}) (contractor.Downloader, error) {
+	return vc.hosts[id], nil
+}
+
+// TestVerifyDownloadable checks that a file stored on healthy hosts verifies,
+// and that a host that has lost its pieces causes verification to fail.
+func TestVerifyDownloadable(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	// Create a file with two chunks, storing one piece of each chunk on
+	// each of two hosts.
+	const pieceSize = 64
+	rsc, _ := NewRSCode(1, 1)
+	f := newFile("foo", rsc, pieceSize, 2*pieceSize)
+	vc := &verifyContractor{
+		hostContractor: rt.renter.hostContractor,
+		hosts:          make(map[types.FileContractID]*mockHost),
+	}
+	for i := 0; i < rsc.NumPieces(); i++ {
+		contract := modules.RenterContract{
+			ID:            types.FileContractID{byte(i + 1)},
+			HostPublicKey: types.SiaPublicKey{Key: []byte{byte(i + 1)}},
+		}
+		vc.contracts = append(vc.contracts, contract)
+		vc.hosts[contract.ID] = &mockHost{sectors: make(map[crypto.Hash][]byte)}
+		f.contracts[contract.ID] = fileContract{ID: contract.ID}
+	}
+	for chunk := uint64(0); chunk < f.numChunks(); chunk++ {
+		pieces, err := rsc.Encode(fastrand.Bytes(int(f.chunkSize())))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for piece, data := range pieces {
+			key := deriveKey(f.masterKey, chunk, uint64(piece))
+			root, _ := vc.hosts[vc.contracts[piece].ID].Upload(key.EncryptBytes(data))
+			fc := f.contracts[vc.contracts[piece].ID]
+			fc.Pieces = append(fc.Pieces, pieceData{Chunk: chunk, Piece: uint64(piece), MerkleRoot: root})
+			f.contracts[fc.ID] = fc
+		}
+	}
+	id := rt.renter.mu.Lock()
+	rt.renter.hostContractor = vc
+	rt.renter.files[f.name] = f
+	rt.renter.mu.Unlock(id)
+
+	report, err := rt.renter.VerifyDownloadable(f.name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Verified || len(report.Chunks) != 2 {
+		t.Fatal("expected both chunks of the healthy file to verify, got", report)
+	}
+	if len(report.Hosts) != 2 {
+		t.Fatal("expected results for 2 hosts, got", report.Hosts)
+	}
+	for _, hr := range report.Hosts {
+		if hr.PiecesFetched != 2 || hr.PiecesFailed != 0 {
+			t.Fatal("expected every host to provide both of its pieces, got", hr)
+		}
+	}
+
+	// Remove the pieces from the second host.
+	corrupt := vc.hosts[vc.contracts[1].ID]
+	corrupt.mu.Lock()
+	corrupt.sectors = make(map[crypto.Hash][]byte)
+	corrupt.mu.Unlock()
+
+	report, err = rt.renter.VerifyDownloadable(f.name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Verified {
+		t.Fatal("file with a corrupt host passed verification")
+	}
+	var failed bool
+	for _, hr := range report.Hosts {
+		if hr.HostPublicKey.String() == vc.contracts[1].HostPublicKey.String() {
+			failed = hr.PiecesFailed > 0
+		}
+	}
+	if !failed {
+		t.Fatal("corrupt host was not reported as failing, got", report.Hosts)
+	}
+
+	if _, err := rt.renter.VerifyDownloadable("bar"); err != ErrUnknownPath {
+		t.Fatal("expected ErrUnknownPath, got", err)
+	}
+}
@@ -12,8 +12,9 @@ import (
 
 // A worker listens for work on a certain host.
 //
-// The mutex of the worker only protects the 'unprocessedChunks' and the
-// 'standbyChunks' fields of the worker. The rest of the fields are only
+// The mutex of the worker protects the 'unprocessedChunks' and the
+// 'standbyChunks' fields of the worker, as well as the failure statistics,
+// which are also read by WorkerPoolStatus. The rest of the fields are only
 // interacted with exclusively by the primary worker thread, and only one of
 // those ever exists at a time.
 type worker struct {
@@ -22,17 +23,26 @@ type worker struct {
 	hostPubKey types.SiaPublicKey
 	renter     *Renter
 
+	// editorMu is shared by all workers of the same contract. Workers acquire
+	// their editor one at a time, so that they share a single editor and the
+	// revisions of the contract are serialized.
+	editorMu *sync.Mutex
+
 	// Channels that inform the worker of kill signals and of new work.
 	downloadChan         chan downloadWork // higher priority than all uploads
 	killChan             chan struct{}     // highest priority
 	priorityDownloadChan chan downloadWork // higher priority than downloads (used for user-initiated downloads)
 	uploadChan           chan struct{}     // lowest priority
 
-	// Operation failure statistics for the worker.
+	// Operation failure statistics for the worker, protected by mu.
 	downloadRecentFailure     time.Time // Only modified by the primary download loop.
-	uploadRecentFailure       time.Time // Only modified by primary repair loop.
+	uploadRecentFailure       time.Time
 	uploadConsecutiveFailures int
 
+	// The recent throughput of the worker's successful transfers.
+	downloadMeter bandwidthMeter
+	uploadMeter   bandwidthMeter
+
 	// Two lists of chunks that relate to worker upload tasks. The first list is
 	// the set of chunks that the worker hasn't examined yet. The second list is
 	// the list of chunks that the worker examined, but was unable to process
@@ -121,39 +131,126 @@ func (r *Renter) managedUpdateWorkerPool() {
 	contractSlice := r.hostContractor.Contracts()
 	contractMap := make(map[types.FileContractID]modules.RenterContract)
 	for i := 0; i < len(contractSlice); i++ {
+		// Contracts whose formation transaction is not part of the
+		// blockchain get no workers.
+		if !contractSlice[i].Status.Usable() {
+			continue
+		}
 		contractMap[contractSlice[i].ID] = contractSlice[i]
 	}
 
-	// Add a worker for any contract that does not already have a worker.
+	// Add workers to any contract that has fewer than workersPerContract
+	// workers, and remove workers from any contract that has more.
 	for id, contract := range contractMap {
 		lockID := r.mu.Lock()
-		_, exists := r.workerPool[id]
-		if !exists {
+		workers := r.workerPool[id]
+		for len(workers) < r.workersPerContract {
+			editorMu := new(sync.Mutex)
+			if len(workers) > 0 {
+				editorMu = workers[0].editorMu
+			}
 			worker := &worker{
 				contract:   contract,
 				hostPubKey: contract.HostPublicKey,
+				editorMu:   editorMu,
 
 				downloadChan:         make(chan downloadWork, 1),
 				killChan:             make(chan struct{}),
 				priorityDownloadChan: make(chan downloadWork, 1),
 				uploadChan:           make(chan struct{}, 1),
 
+				downloadMeter: newBandwidthMeter(bandwidthMeasurementWindow),
+				uploadMeter:   newBandwidthMeter(bandwidthMeasurementWindow),
+
 				renter: r,
 			}
-			r.workerPool[id] = worker
+			workers = append(workers, worker)
 			go worker.threadedWorkLoop()
 		}
+		for len(workers) > r.workersPerContract {
+			close(workers[len(workers)-1].killChan)
+			workers = workers[:len(workers)-1]
+		}
+		r.workerPool[id] = workers
 		r.mu.Unlock(lockID)
 	}
 
-	// Remove a worker for any worker that is not in the set of new contracts.
+	// Remove the workers of any contract that is not in the set of new
+	// contracts.
 	lockID := r.mu.Lock()
-	for id, worker := range r.workerPool {
+	for id, workers := range r.workerPool {
 		_, exists := contractMap[id]
 		if !exists {
 			delete(r.workerPool, id)
-			close(worker.killChan)
+			for _, worker := range workers {
+				close(worker.killChan)
+			}
 		}
 	}
 	r.mu.Unlock(lockID)
 }
+
+// managedDropUnusableWorkers updates the worker pool if it contains workers
+// of contracts that can no longer be used, because their formation
+// transaction was reorged out or double-spent.
+func (r *Renter) managedDropUnusableWorkers() {
+	for _, contract := range r.hostContractor.Contracts() {
+		if contract.Status.Usable() {
+			continue
+		}
+		id := r.mu.RLock()
+		_, exists := r.workerPool[contract.ID]
+		r.mu.RUnlock(id)
+		if exists {
+			r.managedUpdateWorkerPool()
+			return
+		}
+	}
+}
+
+// managedStatus returns the current state of the worker.
+func (w *worker) managedStatus() modules.WorkerStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return modules.WorkerStatus{
+		HostPublicKey: w.hostPubKey,
+
+		UploadQueueDepth:          len(w.unprocessedChunks) + len(w.standbyChunks),
+		UploadThroughput:          w.uploadMeter.managedRate(),
+		UploadConsecutiveFailures: w.uploadConsecutiveFailures,
+		UploadCooldownRemaining:   cooldownRemaining(w.uploadRecentFailure, w.uploadCooldown()),
+
+		DownloadQueueDepth:        len(w.downloadChan) + len(w.priorityDownloadChan),
+		DownloadThroughput:        w.downloadMeter.managedRate(),
+		DownloadCooldownRemaining: cooldownRemaining(w.downloadRecentFailure, downloadFailureCooldown),
+	}
+}
+
+// cooldownRemaining returns how much of a cooldown that started at the time of
+// the most recent failure is left.
+func cooldownRemaining(recentFailure time.Time, cooldown time.Duration) time.Duration {
+	remaining := time.Until(recentFailure.Add(cooldown))
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// WorkerPoolStatus returns the state of every worker in the worker pool, keyed
+// by the ID of the worker's contract.
+func (r *Renter) WorkerPoolStatus() map[types.FileContractID][]modules.WorkerStatus {
+	id := r.mu.RLock()
+	pool := make(map[types.FileContractID][]*worker, len(r.workerPool))
+	for fcid, workers := range r.workerPool {
+		pool[fcid] = append([]*worker(nil), workers...)
+	}
+	r.mu.RUnlock(id)
+
+	status := make(map[types.FileContractID][]modules.WorkerStatus, len(pool))
+	for fcid, workers := range pool {
+		for _, w := range workers {
+			status[fcid] = append(status[fcid], w.managedStatus())
+		}
+	}
+	return status
+}
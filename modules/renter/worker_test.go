@@ -0,0 +1,363 @@
+package renter
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pachisi456/Sia/crypto"
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/modules/renter/contractor"
+	"github.com/pachisi456/Sia/types"
+
+	"github.com/NebulousLabs/fastrand"
+)
+
+// parallelEditor is a contractor.Editor that records the largest number of
+// uploads that were in progress at the same time.
+type parallelEditor struct {
+	active    int
+	maxActive int
+	mu        sync.Mutex
+}
+
+func (pe *parallelEditor) Upload(data []byte) (crypto.Hash, error) {
+	pe.mu.Lock()
+	pe.active++
+	if pe.active > pe.maxActive {
+		pe.maxActive = pe.active
+	}
+	pe.mu.Unlock()
+
+	time.Sleep(100 * time.Millisecond)
+
+	pe.mu.Lock()
+	pe.active--
+	pe.mu.Unlock()
+	return crypto.MerkleRoot(data), nil
+}
+func (pe *parallelEditor) Delete(crypto.Hash) error                              { return nil }
+func (pe *parallelEditor) Modify(crypto.Hash, crypto.Hash, uint64, []byte) error { return nil }
+func (pe *parallelEditor) Address() modules.NetAddress                           { return "" }
+func (pe *parallelEditor) ContractID() types.FileContractID                      { return types.FileContractID{} }
+func (pe *parallelEditor) EndHeight() types.BlockHeight                          { return 0 }
+func (pe *parallelEditor) Close() error                                          { return nil }
+
+// parallelContractor is a hostContractor with a single contract whose editor
+// is a parallelEditor.
+type parallelContractor struct {
+	hostContractor
+	contract modules.RenterContract
+	editor   *parallelEditor
+}
+
+func (pc *parallelContractor) SetAllowance(modules.Allowance) error      { return nil }
+func (pc *parallelContractor) ValidateAllowance(modules.Allowance) error { return nil }
+func (pc *parallelContractor) Contracts() []modules.RenterContract {
+	return []modules.RenterContract{pc.contract}
+}
+func (pc *parallelContractor) ContractByID(id types.FileContractID) (modules.RenterContract, bool) {
+	return pc.contract, id == pc.contract.ID
+}
+func (pc *parallelContractor) Editor(types.FileContractID, <-chan struct{}) (contractor.Editor, error) {
+	return pc.editor, nil
+}
+
+// TestWorkersPerContract checks that increasing the number of workers per
+// contract increases the number of concurrent uploads to a single host.
+func TestWorkersPerContract(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	pc := &parallelContractor{
+		hostContractor: rt.renter.hostContractor,
+		contract: modules.RenterContract{
+			ID:            types.FileContractID{1},
+			HostPublicKey: types.SiaPublicKey{Key: []byte("foo")},
+			GoodForUpload: true,
+		},
+		editor: new(parallelEditor),
+	}
+	id := rt.renter.mu.Lock()
+	rt.renter.hostContractor = pc
+	rt.renter.mu.Unlock(id)
+
+	// maxConcurrentUploads uploads several single-piece chunks to the host and
+	// returns the largest number of uploads that were in progress at the same
+	// time.
+	rsc, _ := NewRSCode(1, 1)
+	maxConcurrentUploads := func(workersPerContract uint64) int {
+		settings := rt.renter.Settings()
+		settings.WorkersPerContract = workersPerContract
+		if err := rt.renter.SetSettings(settings); err != nil {
+			t.Fatal(err)
+		}
+		pc.editor.mu.Lock()
+		pc.editor.maxActive = 0
+		pc.editor.mu.Unlock()
+
+		f := newFile(t.Name(), rsc, modules.SectorSize, 8*modules.SectorSize)
+		for i := uint64(0); i < 8; i++ {
+			rt.renter.managedMemoryAvailableSub(modules.SectorSize, memoryUploadSectors, memoryClassUserUpload)
+			uc := &unfinishedChunk{
+				renterFile:        f,
+				index:             i,
+				memoryNeeded:      modules.SectorSize,
+				minimumPieces:     1,
+				piecesNeeded:      1,
+				physicalChunkData: [][]byte{make([]byte, modules.SectorSize)},
+				pieceUsage:        make([]bool, 1),
+				unusedHosts: map[string]struct{}{
+					pc.contract.HostPublicKey.String(): {},
+				},
+			}
+			rt.renter.managedDistributeChunkToWorkers(uc)
+		}
+		rt.renter.heapWG.Wait()
+
+		pc.editor.mu.Lock()
+		defer pc.editor.mu.Unlock()
+		return pc.editor.maxActive
+	}
+
+	if n := maxConcurrentUploads(1); n != 1 {
+		t.Fatal("expected a single worker to upload one piece at a time, got", n)
+	}
+	if n := maxConcurrentUploads(4); n < 2 {
+		t.Fatal("expected multiple workers to upload to the host concurrently, got", n)
+	}
+	id = rt.renter.mu.RLock()
+	numWorkers := len(rt.renter.workerPool[pc.contract.ID])
+	rt.renter.mu.RUnlock(id)
+	if numWorkers != 4 {
+		t.Fatal("expected 4 workers for the contract, got", numWorkers)
+	}
+}
+
+// dedupContractor is a hostContractor with a single contract whose host is a
+// mockHost. The contract reports the sectors stored on the host as its Merkle
+// roots.
+type dedupContractor struct {
+	hostContractor
+	contract modules.RenterContract
+	host     *mockHost
+}
+
+func (dc *dedupContractor) Contracts() []modules.RenterContract {
+	return []modules.RenterContract{dc.contract}
+}
+func (dc *dedupContractor) ContractByID(id types.FileContractID) (modules.RenterContract, bool) {
+	contract := dc.contract
+	dc.host.mu.Lock()
+	for root := range dc.host.sectors {
+		contract.MerkleRoots = append(contract.MerkleRoots, root)
+	}
+	dc.host.mu.Unlock()
+	return contract, id == dc.contract.ID
+}
+func (dc *dedupContractor) HasSector(id types.FileContractID, root crypto.Hash) bool {
+	dc.host.mu.Lock()
+	defer dc.host.mu.Unlock()
+	_, stored := dc.host.sectors[root]
+	return stored && id == dc.contract.ID
+}
+func (dc *dedupContractor) Editor(types.FileContractID, <-chan struct{}) (contractor.Editor, error) {
+	return dc.host, nil
+}
+
+// TestUploadDedup checks that a piece is not uploaded again if the host
+// already stores an identical sector, and that the skipped upload is counted
+// as a deduplication hit.
+func TestUploadDedup(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	dc := &dedupContractor{
+		hostContractor: rt.renter.hostContractor,
+		contract: modules.RenterContract{
+			ID:            types.FileContractID{1},
+			HostPublicKey: types.SiaPublicKey{Key: []byte("foo")},
+			GoodForUpload: true,
+		},
+		host: &mockHost{sectors: make(map[crypto.Hash][]byte)},
+	}
+	id := rt.renter.mu.Lock()
+	rt.renter.hostContractor = dc
+	rt.renter.mu.Unlock(id)
+	rt.renter.managedUpdateWorkerPool()
+
+	// upload uploads 'data' as the only piece of the first chunk of f.
+	upload := func(f *file, data []byte) {
+		rt.renter.managedMemoryAvailableSub(uint64(len(data)), memoryUploadSectors, memoryClassUserUpload)
+		uc := &unfinishedChunk{
+			renterFile:        f,
+			memoryNeeded:      uint64(len(data)),
+			minimumPieces:     1,
+			piecesNeeded:      1,
+			physicalChunkData: [][]byte{append([]byte(nil), data...)},
+			pieceUsage:        make([]bool, 1),
+			unusedHosts: map[string]struct{}{
+				dc.contract.HostPublicKey.String(): {},
+			},
+		}
+		rt.renter.managedDistributeChunkToWorkers(uc)
+		rt.renter.heapWG.Wait()
+	}
+
+	// Upload the same piece for two files. The second upload should find the
+	// sector on the host.
+	rsc, _ := NewRSCode(1, 1)
+	data := fastrand.Bytes(int(modules.SectorSize))
+	f := newFile("foo", rsc, modules.SectorSize, modules.SectorSize)
+	g := newFile("bar", rsc, modules.SectorSize, modules.SectorSize)
+	upload(f, data)
+	upload(g, data)
+
+	stats := rt.renter.DedupStats()
+	if stats.Hits != 1 || stats.Misses != 1 || stats.BytesSaved != modules.SectorSize {
+		t.Fatal("expected one hit and one miss, got", stats)
+	}
+	dc.host.mu.Lock()
+	numSectors := len(dc.host.sectors)
+	dc.host.mu.Unlock()
+	if numSectors != 1 {
+		t.Fatal("expected the host to store 1 sector, got", numSectors)
+	}
+	for _, file := range []*file{f, g} {
+		file.mu.RLock()
+		pieces := len(file.contracts[dc.contract.ID].Pieces)
+		file.mu.RUnlock()
+		if pieces != 1 {
+			t.Fatal("expected", file.name, "to store 1 piece on the host, got", pieces)
+		}
+	}
+}
+
+// TestWorkerPoolStatus checks that the status of a worker reflects its recent
+// uploads and failures.
+func TestWorkerPoolStatus(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rt.Close()
+
+	dc := &dedupContractor{
+		hostContractor: rt.renter.hostContractor,
+		contract: modules.RenterContract{
+			ID:            types.FileContractID{1},
+			HostPublicKey: types.SiaPublicKey{Key: []byte("foo")},
+			GoodForUpload: true,
+		},
+		host: &mockHost{sectors: make(map[crypto.Hash][]byte)},
+	}
+	id := rt.renter.mu.Lock()
+	rt.renter.hostContractor = dc
+	rt.renter.mu.Unlock(id)
+	rt.renter.managedUpdateWorkerPool()
+
+	// Upload a single piece through the worker.
+	rsc, _ := NewRSCode(1, 1)
+	data := fastrand.Bytes(int(modules.SectorSize))
+	rt.renter.managedMemoryAvailableSub(uint64(len(data)), memoryUploadSectors, memoryClassUserUpload)
+	uc := &unfinishedChunk{
+		renterFile:        newFile("foo", rsc, modules.SectorSize, modules.SectorSize),
+		memoryNeeded:      uint64(len(data)),
+		minimumPieces:     1,
+		piecesNeeded:      1,
+		physicalChunkData: [][]byte{data},
+		pieceUsage:        make([]bool, 1),
+		unusedHosts: map[string]struct{}{
+			dc.contract.HostPublicKey.String(): {},
+		},
+	}
+	rt.renter.managedDistributeChunkToWorkers(uc)
+	rt.renter.heapWG.Wait()
+
+	pool := rt.renter.WorkerPoolStatus()
+	if len(pool) != 1 || len(pool[dc.contract.ID]) != 1 {
+		t.Fatal("expected a single worker for the contract, got", pool)
+	}
+	status := pool[dc.contract.ID][0]
+	if status.HostPublicKey.String() != dc.contract.HostPublicKey.String() {
+		t.Fatal("wrong host:", status.HostPublicKey)
+	}
+	if status.UploadThroughput == 0 || status.UploadQueueDepth != 0 {
+		t.Fatal("worker status does not reflect the upload:", status)
+	}
+	if status.UploadConsecutiveFailures != 0 || status.UploadCooldownRemaining != 0 || status.DownloadCooldownRemaining != 0 {
+		t.Fatal("worker without failures reported a failure:", status)
+	}
+
+	// After two consecutive failures, the cooldown is four times the base
+	// cooldown.
+	id = rt.renter.mu.RLock()
+	w := rt.renter.workerPool[dc.contract.ID][0]
+	rt.renter.mu.RUnlock(id)
+	w.mu.Lock()
+	w.uploadRecentFailure = time.Now()
+	w.uploadConsecutiveFailures = 2
+	w.mu.Unlock()
+	status = rt.renter.WorkerPoolStatus()[dc.contract.ID][0]
+	if status.UploadConsecutiveFailures != 2 {
+		t.Fatal("expected 2 consecutive failures, got", status.UploadConsecutiveFailures)
+	}
+	if status.UploadCooldownRemaining <= 3*uploadFailureCooldown || status.UploadCooldownRemaining > 4*uploadFailureCooldown {
+		t.Fatal("wrong cooldown:", status.UploadCooldownRemaining)
+	}
+}
+
+// TestSlowHostDeferral checks that slow hosts are identified by their
+// throughput, and that they leave the pieces of a chunk to faster hosts only
+// while enough fast hosts remain and the deferral has not passed.
+func TestSlowHostDeferral(t *testing.T) {
+	slow := slowHosts(map[string]uint64{"fast": 1000, "ok": 600, "slow": 400, "unknown": 0})
+	if len(slow) != 1 {
+		t.Fatal("expected exactly one slow host, got", slow)
+	}
+	if _, exists := slow["slow"]; !exists {
+		t.Fatal("expected the slow host to be slow, got", slow)
+	}
+
+	uc := &unfinishedChunk{
+		piecesNeeded:  2,
+		unusedHosts:   map[string]struct{}{"fast": {}, "ok": {}, "slow": {}},
+		slowHosts:     slow,
+		distributedAt: time.Now(),
+	}
+	if uc.deferToFasterHosts("fast") {
+		t.Fatal("fast host should not defer")
+	}
+	if !uc.deferToFasterHosts("slow") {
+		t.Fatal("slow host should defer while there are enough fast hosts")
+	}
+
+	// Once a fast host has dropped out, the slow host is needed to spread
+	// the pieces over distinct hosts.
+	delete(uc.unusedHosts, "ok")
+	if uc.deferToFasterHosts("slow") {
+		t.Fatal("slow host should not defer if it is needed")
+	}
+
+	// After the deferral, slow hosts upload pieces as usual.
+	uc.unusedHosts["ok"] = struct{}{}
+	uc.distributedAt = time.Now().Add(-slowHostDeferral)
+	if uc.deferToFasterHosts("slow") {
+		t.Fatal("slow host should not defer after the deferral has passed")
+	}
+}
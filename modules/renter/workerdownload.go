@@ -2,7 +2,10 @@ package renter
 
 import (
 	"github.com/pachisi456/Sia/crypto"
+	"github.com/pachisi456/Sia/modules"
 	"github.com/pachisi456/Sia/types"
+
+	"github.com/NebulousLabs/fastrand"
 )
 
 type (
@@ -21,6 +24,12 @@ type (
 		resultChan chan finishedDownload
 	}
 
+	// unverifiedDownloader is implemented by downloaders that can skip
+	// checking the data sent by the host against its Merkle root.
+	unverifiedDownloader interface {
+		UnverifiedSector(root crypto.Hash) ([]byte, error)
+	}
+
 	// finishedDownload contains the data and error from performing a download.
 	finishedDownload struct {
 		chunkDownload *chunkDownload
@@ -33,8 +42,30 @@ type (
 
 // download will perform some download work.
 func (w *worker) download(dw downloadWork) {
-	d, err := w.renter.hostContractor.Downloader(w.contract.ID, w.renter.tg.StopChan())
+	// Repair downloads have to wait for the repair throttle, and all
+	// downloads have to wait for their share of the download bandwidth.
+	repair := dw.chunkDownload.download.repair
+	if repair && !w.renter.managedThrottleRepair(modules.SectorSize) {
+		return
+	}
+	if !w.renter.managedThrottleDownload(dw.chunkDownload.download, modules.SectorSize) {
+		return
+	}
+
+	// Pieces of chunks that were recovered from other pieces while this
+	// piece was waiting are not fetched.
+	if w.managedSkipFinishedChunk(dw) {
+		return
+	}
+
+	cancel := w.renter.tg.StopChan()
+	if dw.chunkDownload.download.cancel != nil {
+		cancel = dw.chunkDownload.download.cancel
+	}
+	d, err := w.renter.hostContractor.Downloader(w.contract.ID, cancel)
 	if err != nil {
+		w.renter.managedRecordContractActivity(w.contract.ID, false, false)
+		w.renter.metrics.negotiationFailures.Inc("host", w.hostPubKey.String(), "op", "download")
 		go func() {
 			select {
 			case dw.resultChan <- finishedDownload{dw.chunkDownload, nil, err, dw.pieceIndex, w.contract.ID}:
@@ -44,8 +75,26 @@ func (w *worker) download(dw downloadWork) {
 		return
 	}
 	defer d.Close()
+	if w.managedSkipFinishedChunk(dw) {
+		return
+	}
 
-	data, err := d.Sector(dw.dataRoot)
+	var data []byte
+	if ud, ok := d.(unverifiedDownloader); ok && !repair && !w.renter.managedVerifySector() {
+		data, err = ud.UnverifiedSector(dw.dataRoot)
+	} else {
+		data, err = d.Sector(dw.dataRoot)
+	}
+	if err == nil {
+		w.downloadMeter.managedRecord(uint64(len(data)))
+		w.renter.metrics.downloadBytes.Add(float64(len(data)), "host", w.hostPubKey.String())
+	} else {
+		w.renter.metrics.downloadFailures.Inc("host", w.hostPubKey.String())
+	}
+	if err == nil && !repair {
+		w.renter.managedRecordForeground(uint64(len(data)))
+	}
+	w.renter.managedRecordContractActivity(w.contract.ID, false, err == nil)
 	go func() {
 		select {
 		case dw.resultChan <- finishedDownload{dw.chunkDownload, data, err, dw.pieceIndex, w.contract.ID}:
@@ -53,3 +102,39 @@ func (w *worker) download(dw downloadWork) {
 		}
 	}()
 }
+
+// managedSkipFinishedChunk returns true if the chunk of the download work has
+// already been recovered, reporting the piece as not needed to the download
+// loop.
+func (w *worker) managedSkipFinishedChunk(dw downloadWork) bool {
+	select {
+	case <-dw.chunkDownload.finished:
+	default:
+		return false
+	}
+	go func() {
+		select {
+		case dw.resultChan <- finishedDownload{dw.chunkDownload, nil, errChunkFinished, dw.pieceIndex, w.contract.ID}:
+		case <-w.renter.tg.StopChan():
+		}
+	}()
+	return true
+}
+
+// managedVerifySector reports whether a sector fetched by a download should be
+// checked against its Merkle root, according to the download verification
+// level. In sampled mode, each sector is picked at random so that a host that
+// corrupts data systematically is caught eventually.
+func (r *Renter) managedVerifySector() bool {
+	id := r.mu.RLock()
+	level := r.downloadVerification
+	r.mu.RUnlock(id)
+	switch level {
+	case modules.VerifyNone:
+		return false
+	case modules.VerifySampled:
+		return fastrand.Intn(sampledVerificationRate) == 0
+	default:
+		return true
+	}
+}
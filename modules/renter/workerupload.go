@@ -2,6 +2,10 @@ package renter
 
 import (
 	"time"
+
+	"github.com/pachisi456/Sia/crypto"
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/types"
 )
 
 // dropChunk will remove a worker from the responsibility of tracking a chunk.
@@ -82,9 +86,10 @@ func (w *worker) processChunk(uc *unfinishedChunk) (nextChunk *unfinishedChunk,
 	}
 
 	// If the chunk needs help from this worker, find a piece to upload and
-	// return the stats for that piece.
+	// return the stats for that piece. Slow hosts leave the piece to faster
+	// hosts for a while, taking the chunk on standby instead.
 	index := 0
-	if needsHelp {
+	if needsHelp && !uc.deferToFasterHosts(w.hostPubKey.String()) {
 		// Select a piece and mark that a piece has been selected.
 		for i := 0; i < len(uc.pieceUsage); i++ {
 			if !uc.pieceUsage[i] {
@@ -106,17 +111,46 @@ func (w *worker) processChunk(uc *unfinishedChunk) (nextChunk *unfinishedChunk,
 	return nil, 0
 }
 
+// deferToFasterHosts returns whether 'host' should leave the outstanding
+// pieces of the chunk to faster hosts. This is only the case while the chunk
+// is within its slowHostDeferral, and only if there are enough fast hosts
+// left to upload all of the outstanding pieces, so that the pieces are still
+// spread over distinct hosts. The chunk's lock must be held.
+func (uc *unfinishedChunk) deferToFasterHosts(host string) bool {
+	if _, slow := uc.slowHosts[host]; !slow || time.Since(uc.distributedAt) >= slowHostDeferral {
+		return false
+	}
+	var fastHosts int
+	for h := range uc.unusedHosts {
+		if _, slow := uc.slowHosts[h]; !slow {
+			fastHosts++
+		}
+	}
+	return fastHosts >= uc.piecesNeeded-uc.piecesCompleted-uc.piecesRegistered
+}
+
 // managedQueueChunkRepair will take a chunk and add it to the worker's repair stack.
 func (w *worker) managedQueueChunkRepair(uc *unfinishedChunk) {
-	// Check that the worker is allowed to be uploading.
+	// Check that the worker is allowed to be uploading. Hosts that are still
+	// in their grace period do not receive new pieces, but the pieces that
+	// they already store still count towards the chunk's redundancy. They
+	// are removed from the chunk's unused hosts, so that no other host waits
+	// for them to upload.
 	contract, exists := w.renter.hostContractor.ContractByID(w.contract.ID)
-	w.mu.Lock()
-	// Figure out how long the worker would need to be on cooldown.
-	requiredCooldown := uploadFailureCooldown
-	for i := 0; i < w.uploadConsecutiveFailures && i < maxConsecutivePenalty; i++ {
-		requiredCooldown *= 2
+	id := w.renter.mu.RLock()
+	gracePeriod := w.renter.hostGracePeriod
+	w.renter.mu.RUnlock(id)
+	if w.renter.hostContractor.InGracePeriod(w.contract.ID, gracePeriod) {
+		uc.mu.Lock()
+		delete(uc.unusedHosts, w.hostPubKey.String())
+		uc.mu.Unlock()
+		w.mu.Lock()
+		w.dropChunk(uc)
+		w.mu.Unlock()
+		return
 	}
-	onCooldown := time.Now().Before(w.uploadRecentFailure.Add(requiredCooldown))
+	w.mu.Lock()
+	onCooldown := time.Now().Before(w.uploadRecentFailure.Add(w.uploadCooldown()))
 	if !exists || !contract.GoodForUpload || w.terminated || onCooldown {
 		// The worker should not be uploading, remove the chunk.
 		w.dropChunk(uc)
@@ -133,6 +167,17 @@ func (w *worker) managedQueueChunkRepair(uc *unfinishedChunk) {
 	}
 }
 
+// uploadCooldown returns how long the worker has to wait after its most
+// recent upload failure before it may upload again. The cooldown doubles with
+// every consecutive failure.
+func (w *worker) uploadCooldown() time.Duration {
+	requiredCooldown := uploadFailureCooldown
+	for i := 0; i < w.uploadConsecutiveFailures && i < maxConsecutivePenalty; i++ {
+		requiredCooldown *= 2
+	}
+	return requiredCooldown
+}
+
 // uploadFailed is called if a worker failed to upload part of an unfinished
 // chunk.
 func (w *worker) uploadFailed(uc *unfinishedChunk, pieceIndex uint64) {
@@ -148,20 +193,71 @@ func (w *worker) uploadFailed(uc *unfinishedChunk, pieceIndex uint64) {
 
 // managedUpload will perform some upload work.
 func (w *worker) managedUpload(uc *unfinishedChunk, pieceIndex uint64) {
-	// Open an editing connection to the host.
+	// Skip the upload if the host already stores an identical sector. Pieces
+	// are encrypted with a key that is unique to the file, chunk and piece,
+	// so this only happens for pieces that were uploaded before, possibly by
+	// another file that shares the same data and key.
+	root := crypto.MerkleRoot(uc.physicalChunkData[pieceIndex])
+	if w.renter.hostContractor.HasSector(w.contract.ID, root) {
+		w.renter.managedRecordDedup(true, uint64(len(uc.physicalChunkData[pieceIndex])))
+		w.managedFinishUpload(uc, pieceIndex, root, w.contract.NetAddress, w.contract.EndHeight())
+		return
+	}
+
+	// Repair uploads have to wait for the repair throttle, and all uploads
+	// have to wait for the upload bandwidth limit.
+	n := uint64(len(uc.physicalChunkData[pieceIndex]))
+	if (uc.repair && !w.renter.managedThrottleRepair(n)) || !w.renter.managedThrottleUpload(n) {
+		w.mu.Lock()
+		w.uploadFailed(uc, pieceIndex)
+		w.mu.Unlock()
+		return
+	}
+
+	// Open an editing connection to the host. The editor is acquired under the
+	// contract's editor lock, so that workers of the same contract share an
+	// editor instead of competing to revise the contract.
+	negotiationStart := time.Now()
+	w.editorMu.Lock()
 	e, err := w.renter.hostContractor.Editor(w.contract.ID, w.renter.tg.StopChan())
+	w.editorMu.Unlock()
+	negotiationTime := time.Since(negotiationStart)
 	if err != nil {
-		w.renter.log.Debugln("Worker failed to acquire an editor:", err)
+		w.renter.dedupLog.Debugln("Worker failed to acquire an editor:", err)
+		w.renter.managedRecordContractActivity(w.contract.ID, true, false)
+		w.renter.metrics.negotiationFailures.Inc("host", w.hostPubKey.String(), "op", "upload")
+		w.renter.managedLogPieceEvent("Piece upload failed to acquire an editor: "+err.Error(), uc, w.contract.ID, negotiationTime)
+		w.mu.Lock()
 		w.uploadFailed(uc, pieceIndex)
+		w.mu.Unlock()
 		return
 	}
 	defer e.Close()
 
 	// Perform the upload, and update the failure stats based on the success of
 	// the upload attempt.
-	root, err := e.Upload(uc.physicalChunkData[pieceIndex])
+	transferStart := time.Now()
+	endRegion := uc.traceRegion("upload piece")
+	root, err = e.Upload(uc.physicalChunkData[pieceIndex])
+	endRegion()
+	transferTime := time.Since(transferStart)
+	uc.mu.Lock()
+	uc.negotiationTime += negotiationTime
+	uc.transferTime += transferTime
+	if err == nil {
+		uc.pieceTimings = append(uc.pieceTimings, pieceUploadTiming{
+			contract:        w.contract.ID,
+			bytes:           uint64(len(uc.physicalChunkData[pieceIndex])),
+			negotiationTime: negotiationTime,
+			transferTime:    transferTime,
+		})
+	}
+	uc.mu.Unlock()
 	if err != nil {
-		w.renter.log.Debugln("Worker failed to upload via the editor:", err)
+		w.renter.dedupLog.Debugln("Worker failed to upload via the editor:", err)
+		w.renter.managedRecordContractActivity(w.contract.ID, true, false)
+		w.renter.metrics.uploadFailures.Inc("host", w.hostPubKey.String())
+		w.renter.managedLogPieceEvent("Piece upload failed: "+err.Error(), uc, w.contract.ID, negotiationTime+transferTime)
 		w.mu.Lock()
 		w.uploadFailed(uc, pieceIndex)
 		w.mu.Unlock()
@@ -170,10 +266,21 @@ func (w *worker) managedUpload(uc *unfinishedChunk, pieceIndex uint64) {
 	w.mu.Lock()
 	w.uploadConsecutiveFailures = 0
 	w.mu.Unlock()
+	w.uploadMeter.managedRecord(uint64(len(uc.physicalChunkData[pieceIndex])))
+	w.renter.metrics.uploadBytes.Add(float64(len(uc.physicalChunkData[pieceIndex])), "host", w.hostPubKey.String())
+	w.renter.managedLogPieceEvent("Piece uploaded", uc, w.contract.ID, negotiationTime+transferTime)
+	w.renter.managedRecordContractActivity(w.contract.ID, true, true)
+	if !uc.repair {
+		w.renter.managedRecordForeground(uint64(len(uc.physicalChunkData[pieceIndex])))
+	}
+	w.renter.managedRecordDedup(false, uint64(len(uc.physicalChunkData[pieceIndex])))
+	w.managedFinishUpload(uc, pieceIndex, root, e.Address(), e.EndHeight())
+}
 
+// managedFinishUpload adds a piece that is stored on the worker's host to the
+// file's metadata and updates the state of the chunk.
+func (w *worker) managedFinishUpload(uc *unfinishedChunk, pieceIndex uint64, root crypto.Hash, addr modules.NetAddress, endHeight types.BlockHeight) {
 	// Update the renter metadata.
-	addr := e.Address()
-	endHeight := e.EndHeight()
 	id := w.renter.mu.Lock()
 	uc.renterFile.mu.Lock()
 	contract, exists := uc.renterFile.contracts[w.contract.ID]
@@ -184,6 +291,7 @@ func (w *worker) managedUpload(uc *unfinishedChunk, pieceIndex uint64) {
 			WindowStart: endHeight,
 		}
 	}
+	contract.HostPublicKey = w.hostPubKey
 	contract.Pieces = append(contract.Pieces, pieceData{
 		Chunk:      uc.index,
 		Piece:      pieceIndex,
@@ -191,6 +299,7 @@ func (w *worker) managedUpload(uc *unfinishedChunk, pieceIndex uint64) {
 	})
 	uc.renterFile.contracts[w.contract.ID] = contract
 	w.renter.saveFile(uc.renterFile)
+	w.renter.finishUploading(uc.renterFile)
 	uc.renterFile.mu.Unlock()
 	w.renter.mu.Unlock(id)
 
@@ -200,9 +309,39 @@ func (w *worker) managedUpload(uc *unfinishedChunk, pieceIndex uint64) {
 	releaseSize := len(uc.physicalChunkData[pieceIndex])
 	uc.piecesRegistered--
 	uc.piecesCompleted++
+	repaired := uc.piecesCompleted == uc.piecesNeeded
 	uc.physicalChunkData[pieceIndex] = nil
 	uc.memoryReleased += uint64(releaseSize)
 	uc.mu.Unlock()
-	w.renter.managedMemoryAvailableAdd(uint64(releaseSize))
+	w.renter.managedMemoryAvailableAdd(uint64(releaseSize), memoryUploadSectors, uc.memoryClass())
+	if repaired {
+		w.renter.managedCheckpointChunk(uc)
+	}
 	w.dropChunk(uc)
 }
+
+// managedRecordDedup records the outcome of a piece upload of 'n' bytes. A hit
+// means that the upload was skipped because the host already stored the
+// sector.
+func (r *Renter) managedRecordDedup(hit bool, n uint64) {
+	id := r.mu.Lock()
+	defer r.mu.Unlock(id)
+	if hit {
+		r.dedupHits++
+		r.dedupBytesSaved += n
+	} else {
+		r.dedupMisses++
+	}
+}
+
+// DedupStats reports how many piece uploads were skipped because the host
+// already stored an identical sector.
+func (r *Renter) DedupStats() modules.DedupStats {
+	id := r.mu.RLock()
+	defer r.mu.RUnlock(id)
+	return modules.DedupStats{
+		Hits:       r.dedupHits,
+		Misses:     r.dedupMisses,
+		BytesSaved: r.dedupBytesSaved,
+	}
+}
@@ -35,7 +35,7 @@ type (
 		SuccessfulWrites uint64 `json:"successfulwrites"`
 
 		// Certain operations on a storage folder can take a long time (Add,
-		// Remove, and Resize). The fields below indicate the progress of any
+		// Move, Remove, and Resize). The fields below indicate the progress of any
 		// long running operations that might be under way in the storage
 		// folder. Progress is always reported in bytes.
 		ProgressNumerator   uint64
@@ -80,6 +80,13 @@ type (
 		// requests to remove data.
 		DeleteSector(sectorRoot crypto.Hash) error
 
+		// MoveStorageFolder will move a storage folder to a new path. The
+		// data is migrated into a new storage folder of the same size while
+		// it can still be read, after which the old storage folder is
+		// removed. If the manager is unable to migrate all of the data, an
+		// error will be returned and both storage folders will be kept.
+		MoveStorageFolder(index uint16, newPath string) error
+
 		// ReadSector will read a sector from the storage manager, returning the
 		// bytes that match the input sector root.
 		ReadSector(sectorRoot crypto.Hash) ([]byte, error)
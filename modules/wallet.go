@@ -100,6 +100,51 @@ type (
 		Outputs []ProcessedOutput `json:"outputs"`
 	}
 
+	// A MultisigAccount is an M-of-N multisig address of the wallet, along
+	// with its unlock conditions and confirmed siacoin balance. One of the
+	// public keys of the unlock conditions belongs to the wallet.
+	MultisigAccount struct {
+		Address          types.UnlockHash       `json:"address"`
+		UnlockConditions types.UnlockConditions `json:"unlockconditions"`
+		SiacoinBalance   types.Currency         `json:"siacoinbalance"`
+	}
+
+	// An UnspentOutput is a confirmed siacoin output of the wallet. The
+	// ConfirmationHeight is the height at which the output became available,
+	// which is the maturity height for miner payouts. Outputs that do not
+	// appear in the transaction history of the wallet, such as storage proof
+	// outputs, have a ConfirmationHeight and Confirmations of 0.
+	UnspentOutput struct {
+		ID                 types.SiacoinOutputID `json:"id"`
+		UnlockHash         types.UnlockHash      `json:"unlockhash"`
+		Value              types.Currency        `json:"value"`
+		ConfirmationHeight types.BlockHeight     `json:"confirmationheight"`
+		Confirmations      types.BlockHeight     `json:"confirmations"`
+	}
+
+	// WalletRescanStatus reports the progress of a rescan of the blockchain.
+	// SeedScan indicates that a seed is being scanned for its addresses, in
+	// which case ScannedHeight and the key fields report the progress of
+	// the current scan iteration. Otherwise, ScannedHeight is the height of
+	// the wallet. TargetHeight is the height of the consensus set.
+	WalletRescanStatus struct {
+		Rescanning       bool              `json:"rescanning"`
+		SeedScan         bool              `json:"seedscan"`
+		ScannedHeight    types.BlockHeight `json:"scannedheight"`
+		TargetHeight     types.BlockHeight `json:"targetheight"`
+		KeysGenerated    uint64            `json:"keysgenerated"`
+		LargestIndexSeen uint64            `json:"largestindexseen"`
+		GapLimit         uint64            `json:"gaplimit"`
+	}
+
+	// A WatchedAddress is a watch-only address of the wallet, along with the
+	// confirmed balance of the address.
+	WatchedAddress struct {
+		Address        types.UnlockHash `json:"address"`
+		SiacoinBalance types.Currency   `json:"siacoinbalance"`
+		SiafundBalance types.Currency   `json:"siafundbalance"`
+	}
+
 	// TransactionBuilder is used to construct custom transactions. A transaction
 	// builder is initialized via 'RegisterTransaction' and then can be modified by
 	// adding funds or other fields. The transaction is completed by calling
@@ -328,9 +373,42 @@ type (
 		EncryptionManager
 		KeyManager
 
+		// BroadcastTransactionFile submits the signed transaction of a
+		// transaction file to the transaction pool.
+		BroadcastTransactionFile(filename string) (types.Transaction, error)
+
 		// Close permits clean shutdown during testing and serving.
 		Close() error
 
+		// AddMultisigAccount adds a multisig account that was created by a
+		// cosigner. One of the public keys of the unlock conditions must
+		// belong to the wallet.
+		AddMultisigAccount(types.UnlockConditions) (MultisigAccount, error)
+
+		// AddSignature adds the wallet's signatures to the inputs of a
+		// partially signed transaction that spend from its multisig
+		// accounts.
+		AddSignature(types.Transaction) (types.Transaction, error)
+
+		// BuildMultisigTransaction creates a transaction that sends the
+		// outputs from a multisig account of the wallet. The transaction is
+		// signed by the wallet, and needs to be completed by the cosigners
+		// using AddSignature.
+		BuildMultisigTransaction(addr types.UnlockHash, outputs []types.SiacoinOutput, fee types.Currency) (types.Transaction, error)
+
+		// CreateTransactionFile writes an unsigned transaction that sends
+		// the outputs from a watch-only address to a file, along with the
+		// data needed to sign it on an offline node.
+		CreateTransactionFile(filename string, addr types.UnlockHash, outputs []types.SiacoinOutput, fee types.Currency) error
+
+		// CreateMultisigAccount creates an M-of-N multisig account from the
+		// public keys of the cosigners and a new public key of the wallet.
+		CreateMultisigAccount(signaturesRequired uint64, cosignerKeys []types.SiaPublicKey) (MultisigAccount, error)
+
+		// MultisigAccounts returns the multisig accounts of the wallet along
+		// with their confirmed balances.
+		MultisigAccounts() ([]MultisigAccount, error)
+
 		// ConfirmedBalance returns the confirmed balance of the wallet, minus
 		// any outgoing transactions. ConfirmedBalance will include unconfirmed
 		// refund transactions.
@@ -373,6 +451,20 @@ type (
 		// blockchain.
 		Rescanning() bool
 
+		// RescanStatus reports the progress of a rescan of the blockchain,
+		// including the progress of a seed scan.
+		RescanStatus() (WalletRescanStatus, error)
+
+		// SetGapLimit sets the minimum number of consecutive unused
+		// addresses that are scanned for beyond the last used address of a
+		// seed when the seed is recovered.
+		SetGapLimit(gapLimit uint64) error
+
+		// SignTransactionFile signs the transaction of a transaction file
+		// created by CreateTransactionFile. The wallet does not need to be
+		// synced, so the file can be signed on an air-gapped node.
+		SignTransactionFile(filename string) error
+
 		// StartTransaction is a convenience method that calls
 		// RegisterTransaction(types.Transaction{}, nil)
 		StartTransaction() TransactionBuilder
@@ -386,15 +478,43 @@ type (
 		// SendSiacoinsMulti sends coins to multiple addresses.
 		SendSiacoinsMulti(outputs []types.SiacoinOutput) ([]types.Transaction, error)
 
+		// SendSiacoinsFromOutputs sends coins to an address, spending
+		// exactly the provided outputs of the wallet. Any value of the
+		// outputs that exceeds the amount and the fee is refunded to a new
+		// address of the wallet.
+		SendSiacoinsFromOutputs(amount types.Currency, dest types.UnlockHash, ids []types.SiacoinOutputID) ([]types.Transaction, error)
+
 		// SendSiafunds is a tool for sending siafunds from the wallet to an
 		// address. Sending money usually results in multiple transactions. The
 		// transactions are automatically given to the transaction pool, and
 		// are also returned to the caller.
 		SendSiafunds(amount types.Currency, dest types.UnlockHash) ([]types.Transaction, error)
 
+		// UnspentOutputs returns the confirmed siacoin outputs of the wallet.
+		UnspentOutputs() ([]UnspentOutput, error)
+
 		// DustThreshold returns the quantity per byte below which a Currency is
 		// considered to be Dust.
 		DustThreshold() types.Currency
+
+		// WatchAddresses adds addresses to the watch-only addresses of the
+		// wallet, whose balances and transactions are tracked without the
+		// wallet knowing their keys. The blockchain is rescanned to find the
+		// history of the addresses.
+		WatchAddresses([]types.UnlockHash) error
+
+		// UnwatchAddresses removes addresses from the watch-only addresses of
+		// the wallet.
+		UnwatchAddresses([]types.UnlockHash) error
+
+		// WatchedAddresses returns the watch-only addresses of the wallet
+		// along with their confirmed balances.
+		WatchedAddresses() ([]WatchedAddress, error)
+
+		// WatchedTransactions returns all of the transactions of the
+		// watch-only addresses that were confirmed at heights [startHeight,
+		// endHeight].
+		WatchedTransactions(startHeight types.BlockHeight, endHeight types.BlockHeight) ([]ProcessedTransaction, error)
 	}
 )
 
@@ -15,6 +15,9 @@ import (
 )
 
 var (
+	// bucketMultisigAccounts maps the address of a multisig account of the
+	// wallet to its multisigAccount.
+	bucketMultisigAccounts = []byte("bucketMultisigAccounts")
 	// bucketProcessedTransactions stores ProcessedTransactions in
 	// chronological order. Only transactions relevant to the wallet are
 	// stored. The key of this bucket is an autoincrementing integer.
@@ -35,13 +38,31 @@ var (
 	// bucketWallet contains various fields needed by the wallet, such as its
 	// UID, EncryptionVerification, and PrimarySeedFile.
 	bucketWallet = []byte("bucketWallet")
+	// bucketWatchedAddresses stores the watch-only addresses of the wallet,
+	// which are tracked without the wallet knowing their keys. The values of
+	// this bucket are empty.
+	bucketWatchedAddresses = []byte("bucketWatchedAddresses")
+	// bucketWatchedSiacoinOutputs maps a SiacoinOutputID to its
+	// SiacoinOutput. Only outputs of watched addresses are stored.
+	bucketWatchedSiacoinOutputs = []byte("bucketWatchedSiacoinOutputs")
+	// bucketWatchedSiafundOutputs maps a SiafundOutputID to its
+	// SiafundOutput. Only outputs of watched addresses are stored.
+	bucketWatchedSiafundOutputs = []byte("bucketWatchedSiafundOutputs")
+	// bucketWatchedTransactions stores the ProcessedTransactions of the
+	// watched addresses in the same way as bucketProcessedTransactions.
+	bucketWatchedTransactions = []byte("bucketWatchedTransactions")
 
 	dbBuckets = [][]byte{
+		bucketMultisigAccounts,
 		bucketProcessedTransactions,
 		bucketSiacoinOutputs,
 		bucketSiafundOutputs,
 		bucketSpentOutputs,
 		bucketWallet,
+		bucketWatchedAddresses,
+		bucketWatchedSiacoinOutputs,
+		bucketWatchedSiafundOutputs,
+		bucketWatchedTransactions,
 	}
 
 	errNoKey = errors.New("key does not exist")
@@ -51,6 +72,7 @@ var (
 	keyConsensusChange        = []byte("keyConsensusChange")
 	keyConsensusHeight        = []byte("keyConsensusHeight")
 	keyEncryptionVerification = []byte("keyEncryptionVerification")
+	keyGapLimit               = []byte("keyGapLimit")
 	keyPrimarySeedFile        = []byte("keyPrimarySeedFile")
 	keyPrimarySeedProgress    = []byte("keyPrimarySeedProgress")
 	keySiafundPool            = []byte("keySiafundPool")
@@ -113,6 +135,7 @@ func dbReset(tx *bolt.Tx) error {
 	wb.Put(keyConsensusHeight, encoding.Marshal(uint64(0)))
 	wb.Put(keyAuxiliarySeedFiles, encoding.Marshal([]seedFile{}))
 	wb.Put(keySpendableKeyFiles, encoding.Marshal([]spendableKeyFile{}))
+	wb.Put(keyGapLimit, encoding.Marshal(defaultGapLimit))
 	dbPutConsensusHeight(tx, 0)
 	dbPutConsensusChangeID(tx, modules.ConsensusChangeBeginning)
 	dbPutSiafundPool(tx, types.ZeroCurrency)
@@ -120,6 +143,24 @@ func dbReset(tx *bolt.Tx) error {
 	return nil
 }
 
+// dbResetHistory deletes the transaction history of the wallet and of its
+// watched addresses, and resets the consensus change ID and height in
+// preparation for a rescan of the blockchain, which recreates the history.
+func dbResetHistory(tx *bolt.Tx) error {
+	for _, bucket := range [][]byte{bucketProcessedTransactions, bucketWatchedTransactions} {
+		if err := tx.DeleteBucket(bucket); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucket(bucket); err != nil {
+			return err
+		}
+	}
+	if err := dbPutConsensusChangeID(tx, modules.ConsensusChangeBeginning); err != nil {
+		return err
+	}
+	return dbPutConsensusHeight(tx, 0)
+}
+
 // dbPut is a helper function for storing a marshalled key/value pair.
 func dbPut(b *bolt.Bucket, key, val interface{}) error {
 	return b.Put(encoding.Marshal(key), encoding.Marshal(val))
@@ -204,11 +245,57 @@ func dbDeleteSpentOutput(tx *bolt.Tx, id types.OutputID) error {
 	return dbDelete(tx.Bucket(bucketSpentOutputs), id)
 }
 
+func dbPutWatchedAddress(tx *bolt.Tx, uh types.UnlockHash) error {
+	return tx.Bucket(bucketWatchedAddresses).Put(encoding.Marshal(uh), nil)
+}
+func dbDeleteWatchedAddress(tx *bolt.Tx, uh types.UnlockHash) error {
+	return dbDelete(tx.Bucket(bucketWatchedAddresses), uh)
+}
+func dbForEachWatchedAddress(tx *bolt.Tx, fn func(types.UnlockHash)) error {
+	return tx.Bucket(bucketWatchedAddresses).ForEach(func(k, _ []byte) error {
+		var uh types.UnlockHash
+		if err := encoding.Unmarshal(k, &uh); err != nil {
+			return err
+		}
+		fn(uh)
+		return nil
+	})
+}
+
+func dbPutMultisigAccount(tx *bolt.Tx, uh types.UnlockHash, account multisigAccount) error {
+	return dbPut(tx.Bucket(bucketMultisigAccounts), uh, account)
+}
+func dbForEachMultisigAccount(tx *bolt.Tx, fn func(types.UnlockHash, multisigAccount)) error {
+	return dbForEach(tx.Bucket(bucketMultisigAccounts), fn)
+}
+
+func dbPutWatchedSiacoinOutput(tx *bolt.Tx, id types.SiacoinOutputID, output types.SiacoinOutput) error {
+	return dbPut(tx.Bucket(bucketWatchedSiacoinOutputs), id, output)
+}
+func dbDeleteWatchedSiacoinOutput(tx *bolt.Tx, id types.SiacoinOutputID) error {
+	return dbDelete(tx.Bucket(bucketWatchedSiacoinOutputs), id)
+}
+func dbForEachWatchedSiacoinOutput(tx *bolt.Tx, fn func(types.SiacoinOutputID, types.SiacoinOutput)) error {
+	return dbForEach(tx.Bucket(bucketWatchedSiacoinOutputs), fn)
+}
+
+func dbPutWatchedSiafundOutput(tx *bolt.Tx, id types.SiafundOutputID, output types.SiafundOutput) error {
+	return dbPut(tx.Bucket(bucketWatchedSiafundOutputs), id, output)
+}
+func dbDeleteWatchedSiafundOutput(tx *bolt.Tx, id types.SiafundOutputID) error {
+	return dbDelete(tx.Bucket(bucketWatchedSiafundOutputs), id)
+}
+func dbForEachWatchedSiafundOutput(tx *bolt.Tx, fn func(types.SiafundOutputID, types.SiafundOutput)) error {
+	return dbForEach(tx.Bucket(bucketWatchedSiafundOutputs), fn)
+}
+
 // bucketProcessedTransactions works a little differently: the key is
-// meaningless, only used to order the transactions chronologically.
+// meaningless, only used to order the transactions chronologically. The
+// same applies to bucketWatchedTransactions, which is why these helpers take
+// the bucket as an argument.
 
-func dbAppendProcessedTransaction(tx *bolt.Tx, pt modules.ProcessedTransaction) error {
-	b := tx.Bucket(bucketProcessedTransactions)
+func dbAppendProcessedTransaction(tx *bolt.Tx, bucket []byte, pt modules.ProcessedTransaction) error {
+	b := tx.Bucket(bucket)
 	key, err := b.NextSequence()
 	if err != nil {
 		return err
@@ -218,8 +305,8 @@ func dbAppendProcessedTransaction(tx *bolt.Tx, pt modules.ProcessedTransaction)
 	binary.BigEndian.PutUint64(keyBytes, key)
 	return b.Put(keyBytes, encoding.Marshal(pt))
 }
-func dbGetLastProcessedTransaction(tx *bolt.Tx) (pt modules.ProcessedTransaction, err error) {
-	_, val := tx.Bucket(bucketProcessedTransactions).Cursor().Last()
+func dbGetLastProcessedTransaction(tx *bolt.Tx, bucket []byte) (pt modules.ProcessedTransaction, err error) {
+	_, val := tx.Bucket(bucket).Cursor().Last()
 	err = encoding.Unmarshal(val, &pt)
 	if err != nil {
 		// COMPATv1.2.1: try decoding into old transaction type
@@ -229,11 +316,11 @@ func dbGetLastProcessedTransaction(tx *bolt.Tx) (pt modules.ProcessedTransaction
 	}
 	return
 }
-func dbDeleteLastProcessedTransaction(tx *bolt.Tx) error {
+func dbDeleteLastProcessedTransaction(tx *bolt.Tx, bucket []byte) error {
 	// delete the last entry in the bucket. Note that we don't need to
 	// decrement the sequence integer; we only care that the next integer is
 	// larger than the previous one.
-	b := tx.Bucket(bucketProcessedTransactions)
+	b := tx.Bucket(bucket)
 	key, _ := b.Cursor().Last()
 	return b.Delete(key)
 }
@@ -282,6 +369,14 @@ func dbProcessedTransactionsIterator(tx *bolt.Tx) *processedTransactionsIter {
 	}
 }
 
+// dbWatchedTransactionsIterator creates a new processedTransactionsIter that
+// iterates through the WatchedTransactions bucket.
+func dbWatchedTransactionsIterator(tx *bolt.Tx) *processedTransactionsIter {
+	return &processedTransactionsIter{
+		c: tx.Bucket(bucketWatchedTransactions).Cursor(),
+	}
+}
+
 // dbGetWalletUID returns the UID assigned to the wallet's primary seed.
 func dbGetWalletUID(tx *bolt.Tx) (uid uniqueID) {
 	copy(uid[:], tx.Bucket(bucketWallet).Get(keyUID))
@@ -300,6 +395,19 @@ func dbPutPrimarySeedProgress(tx *bolt.Tx, progress uint64) error {
 	return tx.Bucket(bucketWallet).Put(keyPrimarySeedProgress, encoding.Marshal(progress))
 }
 
+// dbGetGapLimit returns the gap limit used when scanning the blockchain for
+// the addresses of a seed.
+func dbGetGapLimit(tx *bolt.Tx) (gapLimit uint64, err error) {
+	err = encoding.Unmarshal(tx.Bucket(bucketWallet).Get(keyGapLimit), &gapLimit)
+	return
+}
+
+// dbPutGapLimit sets the gap limit used when scanning the blockchain for the
+// addresses of a seed.
+func dbPutGapLimit(tx *bolt.Tx, gapLimit uint64) error {
+	return tx.Bucket(bucketWallet).Put(keyGapLimit, encoding.Marshal(gapLimit))
+}
+
 // dbGetConsensusChangeID returns the ID of the last ConsensusChange processed by the wallet.
 func dbGetConsensusChangeID(tx *bolt.Tx) (cc modules.ConsensusChangeID) {
 	copy(cc[:], tx.Bucket(bucketWallet).Get(keyConsensusChange))
@@ -337,6 +337,8 @@ func (w *Wallet) Reset() error {
 	w.wipeSecrets()
 	w.keys = make(map[types.UnlockHash]spendableKey)
 	w.lookahead = make(map[types.UnlockHash]uint64)
+	w.watchedAddrs = make(map[types.UnlockHash]struct{})
+	w.multisigAccounts = make(map[types.UnlockHash]multisigAccount)
 	w.seeds = []modules.Seed{}
 	w.unconfirmedProcessedTransactions = []modules.ProcessedTransaction{}
 	w.unlocked = false
@@ -371,8 +373,8 @@ func (w *Wallet) InitFromSeed(masterKey crypto.TwofishKey, seed modules.Seed) er
 	defer w.scanLock.Unlock()
 
 	// estimate the primarySeedProgress by scanning the blockchain
-	s := newSeedScanner(seed, w.log)
-	if err := s.scan(w.cs, w.tg.StopChan()); err != nil {
+	s, err := w.managedScanSeed(seed, types.ZeroCurrency)
+	if err != nil {
 		return err
 	}
 	// NOTE: each time the wallet generates a key for index n, it sets its
@@ -386,7 +388,7 @@ func (w *Wallet) InitFromSeed(masterKey crypto.TwofishKey, seed modules.Seed) er
 	// initialize the wallet with the appropriate seed progress
 	w.mu.Lock()
 	defer w.mu.Unlock()
-	_, err := w.initEncryption(masterKey, seed, progress)
+	_, err = w.initEncryption(masterKey, seed, progress)
 	return err
 }
 
@@ -1,6 +1,9 @@
 package wallet
 
 import (
+	"bytes"
+	"sort"
+
 	"github.com/pachisi456/Sia/build"
 	"github.com/pachisi456/Sia/modules"
 	"github.com/pachisi456/Sia/types"
@@ -175,6 +178,111 @@ func (w *Wallet) SendSiacoinsMulti(outputs []types.SiacoinOutput) ([]types.Trans
 	return txnSet, nil
 }
 
+// SendSiacoinsFromOutputs creates a transaction sending 'amount' to 'dest'
+// that spends exactly the outputs specified by 'ids'. The value of the outputs
+// that exceeds the amount and the fee is refunded to a new address of the
+// wallet. The transaction is submitted to the transaction pool and is also
+// returned.
+func (w *Wallet) SendSiacoinsFromOutputs(amount types.Currency, dest types.UnlockHash, ids []types.SiacoinOutputID) ([]types.Transaction, error) {
+	if err := w.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer w.tg.Done()
+	if !w.unlocked {
+		w.log.Println("Attempt to send coins has failed - wallet is locked")
+		return nil, modules.ErrLockedWallet
+	}
+
+	_, tpoolFee := w.tpool.FeeEstimation()
+	tpoolFee = tpoolFee.Mul64(500 + 250*uint64(len(ids))) // Estimated transaction size in bytes
+	output := types.SiacoinOutput{
+		Value:      amount,
+		UnlockHash: dest,
+	}
+
+	w.mu.Lock()
+	txnBuilder := w.registerTransaction(types.Transaction{}, nil)
+	w.mu.Unlock()
+	err := txnBuilder.fundSiacoinsFromOutputs(ids, amount.Add(tpoolFee))
+	if err != nil {
+		txnBuilder.Drop()
+		w.log.Println("Attempt to send coins has failed - failed to fund transaction:", err)
+		return nil, build.ExtendErr("unable to fund transaction", err)
+	}
+	txnBuilder.AddMinerFee(tpoolFee)
+	txnBuilder.AddSiacoinOutput(output)
+	txnSet, err := txnBuilder.Sign(true)
+	if err != nil {
+		txnBuilder.Drop()
+		w.log.Println("Attempt to send coins has failed - failed to sign transaction:", err)
+		return nil, build.ExtendErr("unable to sign transaction", err)
+	}
+	err = w.tpool.AcceptTransactionSet(txnSet)
+	if err != nil {
+		// Return the outputs to the pool so that they can be selected again.
+		txnBuilder.Drop()
+		w.log.Println("Attempt to send coins has failed - transaction pool rejected transaction:", err)
+		return nil, build.ExtendErr("unable to get transaction accepted", err)
+	}
+	w.log.Println("Submitted a siacoin transfer transaction set spending", len(ids), "selected outputs for value", amount.HumanString(), "with fees", tpoolFee.HumanString(), "IDs:")
+	for _, txn := range txnSet {
+		w.log.Println("\t", txn.ID())
+	}
+	return txnSet, nil
+}
+
+// UnspentOutputs returns the confirmed siacoin outputs of the wallet, ordered
+// by confirmation height and then by ID. Outputs that have recently been
+// spent by an unconfirmed transaction of the wallet are included.
+func (w *Wallet) UnspentOutputs() ([]modules.UnspentOutput, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// ensure durability of reported outputs
+	w.syncDB()
+
+	consensusHeight, err := dbGetConsensusHeight(w.dbTx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Find the height at which each output became available from the
+	// transaction history.
+	heights := make(map[types.SiacoinOutputID]types.BlockHeight)
+	it := dbProcessedTransactionsIterator(w.dbTx)
+	for it.next() {
+		for _, output := range it.value().Outputs {
+			if output.FundType == types.SpecifierSiacoinOutput || output.FundType == types.SpecifierMinerPayout {
+				heights[types.SiacoinOutputID(output.ID)] = output.MaturityHeight
+			}
+		}
+	}
+
+	var uos []modules.UnspentOutput
+	err = dbForEachSiacoinOutput(w.dbTx, func(scoid types.SiacoinOutputID, sco types.SiacoinOutput) {
+		uo := modules.UnspentOutput{
+			ID:         scoid,
+			UnlockHash: sco.UnlockHash,
+			Value:      sco.Value,
+		}
+		if height, exists := heights[scoid]; exists && height <= consensusHeight {
+			uo.ConfirmationHeight = height
+			uo.Confirmations = consensusHeight - height + 1
+		}
+		uos = append(uos, uo)
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(uos, func(i, j int) bool {
+		if uos[i].ConfirmationHeight != uos[j].ConfirmationHeight {
+			return uos[i].ConfirmationHeight < uos[j].ConfirmationHeight
+		}
+		return bytes.Compare(uos[i].ID[:], uos[j].ID[:]) < 0
+	})
+	return uos, nil
+}
+
 // SendSiafunds creates a transaction sending 'amount' to 'dest'. The transaction
 // is submitted to the transaction pool and is also returned.
 func (w *Wallet) SendSiafunds(amount types.Currency, dest types.UnlockHash) ([]types.Transaction, error) {
@@ -2,6 +2,7 @@ package wallet
 
 import (
 	"sort"
+	"strings"
 	"testing"
 
 	"github.com/pachisi456/Sia/types"
@@ -183,3 +184,80 @@ func TestIntegrationSortedOutputsSorting(t *testing.T) {
 		}
 	}
 }
+
+// TestSendSiacoinsFromOutputs probes the SendSiacoinsFromOutputs and
+// UnspentOutputs methods of the wallet.
+func TestSendSiacoinsFromOutputs(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	// The wallet should have a single confirmed output from the matured
+	// miner payout.
+	uos, err := wt.wallet.UnspentOutputs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uos) != 1 {
+		t.Fatal("expected 1 unspent output, got", len(uos))
+	}
+	uo := uos[0]
+	if uo.Confirmations == 0 || !uo.Value.Equals(types.CalculateCoinbase(1)) {
+		t.Fatal("unexpected unspent output:", uo)
+	}
+
+	// Unknown and duplicate outputs should be rejected.
+	sendValue := types.SiacoinPrecision.Mul64(3)
+	var unknownID types.SiacoinOutputID
+	_, err = wt.wallet.SendSiacoinsFromOutputs(sendValue, types.UnlockHash{}, []types.SiacoinOutputID{unknownID})
+	if err == nil || !strings.Contains(err.Error(), errUnknownOutput.Error()) {
+		t.Fatal("expected errUnknownOutput, got", err)
+	}
+	_, err = wt.wallet.SendSiacoinsFromOutputs(sendValue, types.UnlockHash{}, []types.SiacoinOutputID{uo.ID, uo.ID})
+	if err == nil || !strings.Contains(err.Error(), errDuplicateOutput.Error()) {
+		t.Fatal("expected errDuplicateOutput, got", err)
+	}
+
+	// Send coins from the output. The transaction should spend the output
+	// directly, without a parent transaction.
+	txns, err := wt.wallet.SendSiacoinsFromOutputs(sendValue, types.UnlockHash{}, []types.SiacoinOutputID{uo.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txns) != 1 || len(txns[0].SiacoinInputs) != 1 || txns[0].SiacoinInputs[0].ParentID != uo.ID {
+		t.Fatal("transaction does not spend exactly the selected output")
+	}
+
+	// The output cannot be spent again until the transaction is confirmed.
+	_, err = wt.wallet.SendSiacoinsFromOutputs(sendValue, types.UnlockHash{}, []types.SiacoinOutputID{uo.ID})
+	if err == nil || !strings.Contains(err.Error(), errSpendHeightTooHigh.Error()) {
+		t.Fatal("expected errSpendHeightTooHigh, got", err)
+	}
+
+	// After confirming the transaction, the output should be replaced by the
+	// refund output.
+	if _, err := wt.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	uos, err = wt.wallet.UnspentOutputs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var refund bool
+	for _, o := range uos {
+		if o.ID == uo.ID {
+			t.Fatal("spent output is still unspent")
+		}
+		if o.Confirmations == 1 && o.Value.Cmp(uo.Value.Sub(sendValue)) < 0 {
+			refund = true
+		}
+	}
+	if !refund {
+		t.Fatal("refund output was not found:", uos)
+	}
+}
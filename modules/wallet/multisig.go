@@ -0,0 +1,308 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+
+	"github.com/pachisi456/Sia/crypto"
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/types"
+)
+
+var (
+	// errInvalidMultisig is returned if the unlock conditions of a multisig
+	// account are not a valid M-of-N multisig.
+	errInvalidMultisig = errors.New("multisig requires at least two distinct ed25519 public keys and between 1 and N required signatures")
+
+	// errKnownMultisigAccount is returned if a multisig account is added to
+	// the wallet twice.
+	errKnownMultisigAccount = errors.New("multisig account is already known to the wallet")
+
+	// errNoMultisigKey is returned if none of the public keys of a multisig
+	// account belongs to the wallet.
+	errNoMultisigKey = errors.New("none of the public keys of the multisig account belong to the wallet")
+
+	// errNothingToSign is returned if the wallet cannot add a signature to a
+	// transaction.
+	errNothingToSign = errors.New("transaction has no inputs that the wallet can sign")
+
+	// errUnknownMultisigAccount is returned if the multisig account of an
+	// address is not known to the wallet.
+	errUnknownMultisigAccount = errors.New("address is not a multisig account of the wallet")
+)
+
+// multisigAccount is an M-of-N multisig address of the wallet, along with the
+// wallet key that is one of its N public keys.
+type multisigAccount struct {
+	UnlockConditions types.UnlockConditions
+
+	// KeyAddress is the address of the wallet key that is used to sign for
+	// the account, and KeyIndex is the index of its public key in the unlock
+	// conditions of the account.
+	KeyAddress types.UnlockHash
+	KeyIndex   uint64
+}
+
+// checkMultisigConditions checks that uc is a valid M-of-N multisig.
+func checkMultisigConditions(uc types.UnlockConditions) error {
+	if len(uc.PublicKeys) < 2 || uc.SignaturesRequired == 0 || uc.SignaturesRequired > uint64(len(uc.PublicKeys)) {
+		return errInvalidMultisig
+	}
+	for i, pk := range uc.PublicKeys {
+		if pk.Algorithm != types.SignatureEd25519 || len(pk.Key) != crypto.PublicKeySize {
+			return errInvalidMultisig
+		}
+		for _, other := range uc.PublicKeys[:i] {
+			if bytes.Equal(pk.Key, other.Key) {
+				return errInvalidMultisig
+			}
+		}
+	}
+	return nil
+}
+
+// addMultisigAccount persists a multisig account. The address of the account
+// still needs to be watched to track its outputs.
+func (w *Wallet) addMultisigAccount(account multisigAccount) (modules.MultisigAccount, error) {
+	addr := account.UnlockConditions.UnlockHash()
+	if _, exists := w.multisigAccounts[addr]; exists {
+		return modules.MultisigAccount{}, errKnownMultisigAccount
+	}
+	if err := dbPutMultisigAccount(w.dbTx, addr, account); err != nil {
+		return modules.MultisigAccount{}, err
+	}
+	w.multisigAccounts[addr] = account
+	return modules.MultisigAccount{
+		Address:          addr,
+		UnlockConditions: account.UnlockConditions,
+	}, nil
+}
+
+// CreateMultisigAccount creates an M-of-N multisig account from the public
+// keys of the cosigners and a new public key of the wallet, which becomes the
+// first public key of the account. The account's address is tracked like a
+// watch-only address, and the wallet can sign for it.
+func (w *Wallet) CreateMultisigAccount(signaturesRequired uint64, cosignerKeys []types.SiaPublicKey) (modules.MultisigAccount, error) {
+	if err := w.tg.Add(); err != nil {
+		return modules.MultisigAccount{}, err
+	}
+	defer w.tg.Done()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.unlocked {
+		return modules.MultisigAccount{}, modules.ErrLockedWallet
+	}
+
+	// Check the cosigner keys before using up a key of the wallet. A
+	// placeholder is used for the wallet's public key.
+	placeholder := types.SiaPublicKey{
+		Algorithm: types.SignatureEd25519,
+		Key:       make([]byte, crypto.PublicKeySize),
+	}
+	err := checkMultisigConditions(types.UnlockConditions{
+		PublicKeys:         append([]types.SiaPublicKey{placeholder}, cosignerKeys...),
+		SignaturesRequired: signaturesRequired,
+	})
+	if err != nil {
+		return modules.MultisigAccount{}, err
+	}
+
+	uc, err := w.nextPrimarySeedAddress(w.dbTx)
+	if err != nil {
+		return modules.MultisigAccount{}, err
+	}
+	account := multisigAccount{
+		UnlockConditions: types.UnlockConditions{
+			PublicKeys:         append([]types.SiaPublicKey{uc.PublicKeys[0]}, cosignerKeys...),
+			SignaturesRequired: signaturesRequired,
+		},
+		KeyAddress: uc.UnlockHash(),
+		KeyIndex:   0,
+	}
+	if err := checkMultisigConditions(account.UnlockConditions); err != nil {
+		return modules.MultisigAccount{}, err
+	}
+	ma, err := w.addMultisigAccount(account)
+	if err != nil {
+		return modules.MultisigAccount{}, err
+	}
+
+	// The account contains a new key of the wallet, so it cannot have any
+	// history and its address can be watched without a rescan.
+	if err := dbPutWatchedAddress(w.dbTx, ma.Address); err != nil {
+		return modules.MultisigAccount{}, err
+	}
+	w.watchedAddrs[ma.Address] = struct{}{}
+	w.syncDB()
+	return ma, nil
+}
+
+// AddMultisigAccount adds a multisig account that was created by a cosigner.
+// One of the public keys of the unlock conditions must belong to the wallet.
+// If the wallet has already subscribed to the consensus set, the blockchain
+// is rescanned to find the history of the account.
+func (w *Wallet) AddMultisigAccount(uc types.UnlockConditions) (modules.MultisigAccount, error) {
+	if err := w.tg.Add(); err != nil {
+		return modules.MultisigAccount{}, err
+	}
+	defer w.tg.Done()
+	if err := checkMultisigConditions(uc); err != nil {
+		return modules.MultisigAccount{}, err
+	}
+
+	ma, err := func() (modules.MultisigAccount, error) {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if !w.unlocked {
+			return modules.MultisigAccount{}, modules.ErrLockedWallet
+		}
+
+		// Find the wallet key that is part of the account.
+		for addr, sk := range w.keys {
+			if len(sk.UnlockConditions.PublicKeys) != 1 || sk.UnlockConditions.SignaturesRequired != 1 {
+				continue
+			}
+			for i, pk := range uc.PublicKeys {
+				if bytes.Equal(pk.Key, sk.UnlockConditions.PublicKeys[0].Key) {
+					return w.addMultisigAccount(multisigAccount{
+						UnlockConditions: uc,
+						KeyAddress:       addr,
+						KeyIndex:         uint64(i),
+					})
+				}
+			}
+		}
+		return modules.MultisigAccount{}, errNoMultisigKey
+	}()
+	if err != nil {
+		return modules.MultisigAccount{}, err
+	}
+
+	// watch the address of the account, rescanning the blockchain for its
+	// history
+	if err := w.WatchAddresses([]types.UnlockHash{ma.Address}); err != nil {
+		return modules.MultisigAccount{}, err
+	}
+	return ma, nil
+}
+
+// MultisigAccounts returns the multisig accounts of the wallet along with
+// their confirmed siacoin balances. Accounts are returned sorted by address.
+func (w *Wallet) MultisigAccounts() ([]modules.MultisigAccount, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// ensure durability of reported balances
+	w.syncDB()
+
+	accounts := make(map[types.UnlockHash]*modules.MultisigAccount, len(w.multisigAccounts))
+	for addr, account := range w.multisigAccounts {
+		accounts[addr] = &modules.MultisigAccount{
+			Address:          addr,
+			UnlockConditions: account.UnlockConditions,
+		}
+	}
+	err := dbForEachWatchedSiacoinOutput(w.dbTx, func(_ types.SiacoinOutputID, sco types.SiacoinOutput) {
+		if ma, exists := accounts[sco.UnlockHash]; exists {
+			ma.SiacoinBalance = ma.SiacoinBalance.Add(sco.Value)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	mas := make([]modules.MultisigAccount, 0, len(accounts))
+	for _, ma := range accounts {
+		mas = append(mas, *ma)
+	}
+	sort.Slice(mas, func(i, j int) bool {
+		return bytes.Compare(mas[i].Address[:], mas[j].Address[:]) < 0
+	})
+	return mas, nil
+}
+
+// addMultisigSignature adds the wallet's signature for the input with the
+// provided parent ID to txn, unless the transaction already contains it. The
+// signature covers the whole transaction. It returns whether a signature was
+// added.
+func (w *Wallet) addMultisigSignature(txn *types.Transaction, account multisigAccount, parentID crypto.Hash) bool {
+	for _, sig := range txn.TransactionSignatures {
+		if sig.ParentID == parentID && sig.PublicKeyIndex == account.KeyIndex {
+			return false
+		}
+	}
+	sk, exists := w.keys[account.KeyAddress]
+	if !exists || len(sk.SecretKeys) == 0 {
+		return false
+	}
+	txn.TransactionSignatures = append(txn.TransactionSignatures, types.TransactionSignature{
+		ParentID:       parentID,
+		CoveredFields:  types.FullCoveredFields,
+		PublicKeyIndex: account.KeyIndex,
+	})
+	sigIndex := len(txn.TransactionSignatures) - 1
+	encodedSig := crypto.SignHash(txn.SigHash(sigIndex), sk.SecretKeys[0])
+	txn.TransactionSignatures[sigIndex].Signature = encodedSig[:]
+	return true
+}
+
+// BuildMultisigTransaction creates a transaction that sends the outputs from
+// the multisig account of addr, paying the provided miner fee. The confirmed
+// outputs of the account are used to fund the transaction, and any excess is
+// refunded to the account. The transaction is signed by the wallet and can be
+// completed by the cosigners with AddSignature.
+func (w *Wallet) BuildMultisigTransaction(addr types.UnlockHash, outputs []types.SiacoinOutput, fee types.Currency) (types.Transaction, error) {
+	if err := w.tg.Add(); err != nil {
+		return types.Transaction{}, err
+	}
+	defer w.tg.Done()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.unlocked {
+		return types.Transaction{}, modules.ErrLockedWallet
+	}
+	account, exists := w.multisigAccounts[addr]
+	if !exists {
+		return types.Transaction{}, errUnknownMultisigAccount
+	}
+	txn, _, err := w.buildWatchedTransaction(addr, account.UnlockConditions, outputs, fee)
+	if err != nil {
+		return types.Transaction{}, err
+	}
+	for _, sci := range txn.SiacoinInputs {
+		w.addMultisigSignature(&txn, account, crypto.Hash(sci.ParentID))
+	}
+	return txn, nil
+}
+
+// AddSignature adds the wallet's signatures to the inputs of a partially
+// signed transaction that spend from the multisig accounts of the wallet.
+// Inputs that the wallet has already signed are skipped.
+func (w *Wallet) AddSignature(txn types.Transaction) (types.Transaction, error) {
+	if err := w.tg.Add(); err != nil {
+		return types.Transaction{}, err
+	}
+	defer w.tg.Done()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.unlocked {
+		return types.Transaction{}, modules.ErrLockedWallet
+	}
+
+	var added bool
+	for _, sci := range txn.SiacoinInputs {
+		if account, exists := w.multisigAccounts[sci.UnlockConditions.UnlockHash()]; exists {
+			added = w.addMultisigSignature(&txn, account, crypto.Hash(sci.ParentID)) || added
+		}
+	}
+	for _, sfi := range txn.SiafundInputs {
+		if account, exists := w.multisigAccounts[sfi.UnlockConditions.UnlockHash()]; exists {
+			added = w.addMultisigSignature(&txn, account, crypto.Hash(sfi.ParentID)) || added
+		}
+	}
+	if !added {
+		return types.Transaction{}, errNothingToSign
+	}
+	return txn, nil
+}
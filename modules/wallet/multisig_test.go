@@ -0,0 +1,161 @@
+package wallet
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/pachisi456/Sia/crypto"
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/types"
+
+	"github.com/NebulousLabs/fastrand"
+)
+
+// TestMultisigAccounts probes a 2-of-2 multisig account shared by two
+// wallets.
+func TestMultisigAccounts(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	// Create a second wallet that shares the consensus set and transaction
+	// pool of the wallet tester.
+	cosigner, err := New(wt.cs, wt.tpool, filepath.Join(wt.persistDir, "cosigner"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		cosigner.Close()
+	}()
+	var masterKey crypto.TwofishKey
+	fastrand.Read(masterKey[:])
+	if _, err := cosigner.Encrypt(masterKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := cosigner.Unlock(masterKey); err != nil {
+		t.Fatal(err)
+	}
+	uc, err := cosigner.NextAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cosignerKey := uc.PublicKeys[0]
+
+	// Invalid multisig accounts should be rejected.
+	if _, err := wt.wallet.CreateMultisigAccount(1, nil); err != errInvalidMultisig {
+		t.Fatal("expected errInvalidMultisig, got", err)
+	}
+	if _, err := wt.wallet.CreateMultisigAccount(3, []types.SiaPublicKey{cosignerKey}); err != errInvalidMultisig {
+		t.Fatal("expected errInvalidMultisig, got", err)
+	}
+	if _, err := wt.wallet.CreateMultisigAccount(0, []types.SiaPublicKey{cosignerKey}); err != errInvalidMultisig {
+		t.Fatal("expected errInvalidMultisig, got", err)
+	}
+
+	// Create the account and fund it.
+	ma, err := wt.wallet.CreateMultisigAccount(2, []types.SiaPublicKey{cosignerKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ma.Address != ma.UnlockConditions.UnlockHash() {
+		t.Fatal("address of the account does not match its unlock conditions")
+	}
+	fundValue := types.SiacoinPrecision.Mul64(10)
+	if _, err := wt.wallet.SendSiacoins(fundValue, ma.Address); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	// checkBalance checks that w has the account with the provided balance.
+	checkBalance := func(w *Wallet, balance types.Currency) {
+		mas, err := w.MultisigAccounts()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(mas) != 1 || mas[0].Address != ma.Address {
+			t.Fatal("unexpected multisig accounts:", mas)
+		}
+		if !mas[0].SiacoinBalance.Equals(balance) {
+			t.Fatal("multisig account has the wrong balance:", mas[0].SiacoinBalance, balance)
+		}
+	}
+	checkBalance(wt.wallet, fundValue)
+
+	// The cosigner adds the account, which rescans the blockchain for its
+	// history.
+	if _, err := cosigner.AddMultisigAccount(ma.UnlockConditions); err != nil {
+		t.Fatal(err)
+	}
+	checkBalance(cosigner, fundValue)
+	if _, err := cosigner.AddMultisigAccount(ma.UnlockConditions); err != errKnownMultisigAccount {
+		t.Fatal("expected errKnownMultisigAccount, got", err)
+	}
+	var foreignKey types.SiaPublicKey
+	foreignKey.Algorithm = types.SignatureEd25519
+	foreignKey.Key = fastrand.Bytes(crypto.PublicKeySize)
+	foreignUC := types.UnlockConditions{
+		PublicKeys:         []types.SiaPublicKey{foreignKey, ma.UnlockConditions.PublicKeys[0]},
+		SignaturesRequired: 2,
+	}
+	if _, err := cosigner.AddMultisigAccount(foreignUC); err != errNoMultisigKey {
+		t.Fatal("expected errNoMultisigKey, got", err)
+	}
+
+	// Build a transaction spending from the account. It should not be valid
+	// until the cosigner has signed it.
+	var dest types.UnlockHash
+	fastrand.Read(dest[:])
+	sendValue := types.SiacoinPrecision.Mul64(4)
+	fee := types.SiacoinPrecision
+	outputs := []types.SiacoinOutput{{Value: sendValue, UnlockHash: dest}}
+	if _, err := wt.wallet.BuildMultisigTransaction(ma.Address, outputs, fundValue); err != modules.ErrLowBalance {
+		t.Fatal("expected ErrLowBalance, got", err)
+	}
+	txn, err := wt.wallet.BuildMultisigTransaction(ma.Address, outputs, fee)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.tpool.AcceptTransactionSet([]types.Transaction{txn}); err == nil {
+		t.Fatal("transaction with a single signature was accepted")
+	}
+	if _, err := wt.wallet.AddSignature(txn); err != errNothingToSign {
+		t.Fatal("expected errNothingToSign, got", err)
+	}
+	txn, err = cosigner.AddSignature(txn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.tpool.AcceptTransactionSet([]types.Transaction{txn}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	checkBalance(wt.wallet, fundValue.Sub(sendValue).Sub(fee))
+	checkBalance(cosigner, fundValue.Sub(sendValue).Sub(fee))
+
+	// Only known accounts can be spent from.
+	if _, err := wt.wallet.BuildMultisigTransaction(types.UnlockHash{}, outputs, fee); err != errUnknownMultisigAccount {
+		t.Fatal("expected errUnknownMultisigAccount, got", err)
+	}
+
+	// The account should survive a restart of the wallet.
+	if err := cosigner.Close(); err != nil {
+		t.Fatal(err)
+	}
+	cosigner, err = New(wt.cs, wt.tpool, filepath.Join(wt.persistDir, "cosigner"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cosigner.Unlock(masterKey); err != nil {
+		t.Fatal(err)
+	}
+	checkBalance(cosigner, fundValue.Sub(sendValue).Sub(fee))
+}
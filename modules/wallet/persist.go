@@ -70,10 +70,23 @@ func (w *Wallet) openDB(filename string) (err error) {
 		if wb.Get(keySiafundPool) == nil {
 			wb.Put(keySiafundPool, encoding.Marshal(types.ZeroCurrency))
 		}
+		if wb.Get(keyGapLimit) == nil {
+			wb.Put(keyGapLimit, encoding.Marshal(defaultGapLimit))
+		}
 
 		// check whether wallet is encrypted
 		w.encrypted = tx.Bucket(bucketWallet).Get(keyEncryptionVerification) != nil
-		return nil
+
+		// load the watch-only addresses and multisig accounts
+		err := dbForEachWatchedAddress(tx, func(uh types.UnlockHash) {
+			w.watchedAddrs[uh] = struct{}{}
+		})
+		if err != nil {
+			return err
+		}
+		return dbForEachMultisigAccount(tx, func(uh types.UnlockHash, account multisigAccount) {
+			w.multisigAccounts[uh] = account
+		})
 	})
 	return err
 }
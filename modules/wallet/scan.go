@@ -2,6 +2,7 @@ package wallet
 
 import (
 	"fmt"
+	"sync"
 
 	"github.com/pachisi456/Sia/build"
 	"github.com/pachisi456/Sia/modules"
@@ -11,7 +12,13 @@ import (
 
 const scanMultiplier = 4 // how many more keys to generate after each scan iteration
 
-var errMaxKeys = fmt.Errorf("refused to generate more than %v keys from seed", maxScanKeys)
+var (
+	errMaxKeys = fmt.Errorf("refused to generate more than %v keys from seed", maxScanKeys)
+
+	// errInvalidGapLimit is returned if a gap limit of zero or a gap limit
+	// that cannot be satisfied without exceeding maxScanKeys is set.
+	errInvalidGapLimit = fmt.Errorf("gap limit must be between 1 and %v", maxScanKeys-1)
+)
 
 // maxScanKeys is the number of maximum number of keys the seedScanner will
 // generate before giving up.
@@ -43,6 +50,12 @@ var numInitialKeys = func() uint64 {
 	}
 }()
 
+// defaultGapLimit is the default minimum number of consecutive unused keys
+// that the seedScanner generates beyond the largest index that has appeared
+// in the blockchain before it stops scanning. It matches the number of unused
+// keys that the first scan iteration requires.
+var defaultGapLimit = numInitialKeys / 2
+
 // A scannedOutput is an output found in the blockchain that was generated
 // from a given seed.
 type scannedOutput struct {
@@ -61,6 +74,18 @@ type seedScanner struct {
 	siacoinOutputs   map[types.SiacoinOutputID]scannedOutput
 	siafundOutputs   map[types.SiafundOutputID]scannedOutput
 
+	// gapLimit is the minimum number of consecutive unused keys beyond
+	// largestIndexSeen that must have been scanned for before the scan is
+	// complete.
+	gapLimit uint64
+
+	// scannedBlocks is the number of blocks that have been scanned in the
+	// current scan iteration. Because the progress of the scan is reported
+	// while it is running, scannedBlocks, largestIndexSeen, and the number of
+	// keys are only modified while holding mu.
+	scannedBlocks uint64
+	mu            sync.Mutex
+
 	log *persist.Logger
 }
 
@@ -71,11 +96,30 @@ func (s *seedScanner) numKeys() uint64 {
 // generateKeys generates n additional keys from the seedScanner's seed.
 func (s *seedScanner) generateKeys(n uint64) {
 	initialProgress := s.numKeys()
-	for i, k := range generateKeys(s.seed, initialProgress, n) {
+	keys := generateKeys(s.seed, initialProgress, n)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, k := range keys {
 		s.keys[k.UnlockConditions.UnlockHash()] = initialProgress + uint64(i)
 	}
 }
 
+// progress returns the number of blocks scanned in the current scan iteration,
+// the number of keys generated, and the largest index seen so far.
+func (s *seedScanner) progress() (scannedBlocks, numKeys, largestIndexSeen uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.scannedBlocks, s.numKeys(), s.largestIndexSeen
+}
+
+// scanComplete returns whether enough keys have been scanned for. The scan is
+// complete when no index in the upper half of the generated keys has
+// appeared in the blockchain, and at least gapLimit unused keys follow the
+// largest index seen.
+func (s *seedScanner) scanComplete() bool {
+	return s.largestIndexSeen < s.numKeys()/2 && s.numKeys()-s.largestIndexSeen-1 >= s.gapLimit
+}
+
 // ProcessConsensusChange scans the blockchain for information relevant to the
 // seedScanner.
 func (s *seedScanner) ProcessConsensusChange(cc modules.ConsensusChange) {
@@ -118,6 +162,10 @@ func (s *seedScanner) ProcessConsensusChange(cc modules.ConsensusChange) {
 	}
 
 	// update s.largestIndexSeen
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scannedBlocks -= uint64(len(cc.RevertedBlocks))
+	s.scannedBlocks += uint64(len(cc.AppliedBlocks))
 	for _, diff := range cc.SiacoinOutputDiffs {
 		index, exists := s.keys[diff.SiacoinOutput.UnlockHash]
 		if exists {
@@ -143,25 +191,39 @@ func (s *seedScanner) ProcessConsensusChange(cc modules.ConsensusChange) {
 // generated to find all the addresses.
 func (s *seedScanner) scan(cs modules.ConsensusSet, cancel <-chan struct{}) error {
 	// generate a bunch of keys and scan the blockchain looking for them. If
-	// none of the 'upper' half of the generated keys are found, we are done;
+	// none of the 'upper' half of the generated keys are found and at least
+	// gapLimit unused keys follow the largest index seen, we are done;
 	// otherwise, generate more keys and try again (bounded by a sane
 	// default).
 	//
 	// NOTE: since scanning is very slow, we aim to only scan once, which
 	// means generating many keys.
 	var numKeys uint64 = numInitialKeys
+	if numKeys <= s.gapLimit {
+		numKeys = s.gapLimit + 1
+	}
+	if numKeys > maxScanKeys {
+		numKeys = maxScanKeys
+	}
 	for s.numKeys() < maxScanKeys {
 		s.generateKeys(numKeys)
+		s.mu.Lock()
+		s.scannedBlocks = 0
+		s.mu.Unlock()
 		if err := cs.ConsensusSetSubscribe(s, modules.ConsensusChangeBeginning, cancel); err != nil {
 			return err
 		}
 		cs.Unsubscribe(s)
-		if s.largestIndexSeen < s.numKeys()/2 {
+		if s.scanComplete() {
 			return nil
 		}
-		// increase number of keys generated each iteration, capping so that
-		// we do not exceed maxScanKeys
+		// increase number of keys generated each iteration, making sure that
+		// enough keys are generated to satisfy the gap limit and capping so
+		// that we do not exceed maxScanKeys
 		numKeys *= scanMultiplier
+		if gapKeys := s.largestIndexSeen + 1 + s.gapLimit; gapKeys > s.numKeys()+numKeys {
+			numKeys = gapKeys - s.numKeys()
+		}
 		if numKeys > maxScanKeys-s.numKeys() {
 			numKeys = maxScanKeys - s.numKeys()
 		}
@@ -176,7 +238,90 @@ func newSeedScanner(seed modules.Seed, log *persist.Logger) *seedScanner {
 		keys:           make(map[types.UnlockHash]uint64, numInitialKeys),
 		siacoinOutputs: make(map[types.SiacoinOutputID]scannedOutput),
 		siafundOutputs: make(map[types.SiafundOutputID]scannedOutput),
+		gapLimit:       defaultGapLimit,
 
 		log: log,
 	}
 }
+
+// managedScanSeed scans the blockchain for the outputs of seed, using the gap
+// limit of the wallet. The progress of the scan is reported by RescanStatus
+// while it is running.
+func (w *Wallet) managedScanSeed(seed modules.Seed, dustThreshold types.Currency) (*seedScanner, error) {
+	s := newSeedScanner(seed, w.log)
+	s.dustThreshold = dustThreshold
+
+	w.mu.Lock()
+	gapLimit, err := dbGetGapLimit(w.dbTx)
+	if err == nil {
+		s.gapLimit = gapLimit
+		w.scanner = s
+	}
+	w.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		w.mu.Lock()
+		w.scanner = nil
+		w.mu.Unlock()
+	}()
+
+	if err := s.scan(w.cs, w.tg.StopChan()); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// RescanStatus reports the progress of a rescan of the blockchain, including
+// the progress of a seed scan if one is running.
+func (w *Wallet) RescanStatus() (modules.WalletRescanStatus, error) {
+	if err := w.tg.Add(); err != nil {
+		return modules.WalletRescanStatus{}, err
+	}
+	defer w.tg.Done()
+	// the consensus height has to be obtained separate from the lock
+	rescanning := w.Rescanning()
+	height := w.cs.Height()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	gapLimit, err := dbGetGapLimit(w.dbTx)
+	if err != nil {
+		return modules.WalletRescanStatus{}, err
+	}
+	status := modules.WalletRescanStatus{
+		Rescanning:   rescanning,
+		TargetHeight: height,
+		GapLimit:     gapLimit,
+	}
+	if w.scanner != nil {
+		scannedBlocks, numKeys, largestIndexSeen := w.scanner.progress()
+		status.SeedScan = true
+		status.KeysGenerated = numKeys
+		status.LargestIndexSeen = largestIndexSeen
+		if scannedBlocks > 0 {
+			status.ScannedHeight = types.BlockHeight(scannedBlocks - 1)
+		}
+		return status, nil
+	}
+	status.ScannedHeight, err = dbGetConsensusHeight(w.dbTx)
+	return status, err
+}
+
+// SetGapLimit sets the minimum number of consecutive unused addresses that
+// are scanned for beyond the last used address of a seed when the seed is
+// recovered. The gap limit is persisted and applies to subsequent calls to
+// InitFromSeed, LoadSeed, and SweepSeed.
+func (w *Wallet) SetGapLimit(gapLimit uint64) error {
+	if err := w.tg.Add(); err != nil {
+		return err
+	}
+	defer w.tg.Done()
+	if gapLimit == 0 || gapLimit >= maxScanKeys {
+		return errInvalidGapLimit
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return dbPutGapLimit(w.dbTx, gapLimit)
+}
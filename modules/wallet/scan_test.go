@@ -141,3 +141,79 @@ func TestScanLoop(t *testing.T) {
 		t.Errorf("expected largest index to be %v, got %v", indices[len(indices)-2]+2, ss.largestIndexSeen)
 	}
 }
+
+// TestScanGapLimit tests that the seedScanner scans for at least the gap
+// limit of unused keys beyond the largest index seen.
+func TestScanGapLimit(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	// send money to ourselves at an index that is beyond the keys generated
+	// by the first scan iteration.
+	index := numInitialKeys + numInitialKeys/5
+	wt.wallet.mu.Lock()
+	dbPutPrimarySeedProgress(wt.wallet.dbTx, index)
+	wt.wallet.mu.Unlock()
+	uc, err := wt.wallet.NextAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = wt.wallet.SendSiacoins(types.SiacoinPrecision, uc.UnlockHash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = wt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	seed, _, _ := wt.wallet.PrimarySeed()
+
+	// with the default gap limit, the index should not be found
+	s, err := wt.wallet.managedScanSeed(seed, types.ZeroCurrency)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.largestIndexSeen >= index {
+		t.Fatal("index was found with the default gap limit:", s.largestIndexSeen)
+	}
+
+	// invalid gap limits should be rejected
+	if err := wt.wallet.SetGapLimit(0); err != errInvalidGapLimit {
+		t.Fatal("expected errInvalidGapLimit, got", err)
+	}
+	if err := wt.wallet.SetGapLimit(maxScanKeys); err != errInvalidGapLimit {
+		t.Fatal("expected errInvalidGapLimit, got", err)
+	}
+
+	// with a larger gap limit, the index should be found and enough keys
+	// should be generated beyond it
+	gapLimit := 2 * numInitialKeys
+	if err := wt.wallet.SetGapLimit(gapLimit); err != nil {
+		t.Fatal(err)
+	}
+	s, err = wt.wallet.managedScanSeed(seed, types.ZeroCurrency)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.largestIndexSeen < index {
+		t.Fatal("index was not found with a larger gap limit:", s.largestIndexSeen)
+	}
+	if s.numKeys()-s.largestIndexSeen-1 < gapLimit {
+		t.Fatalf("only %v keys were generated beyond index %v", s.numKeys()-s.largestIndexSeen-1, s.largestIndexSeen)
+	}
+
+	// the gap limit should be reported by RescanStatus
+	status, err := wt.wallet.RescanStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.SeedScan || status.GapLimit != gapLimit || status.ScannedHeight != status.TargetHeight {
+		t.Fatal("unexpected rescan status:", status)
+	}
+}
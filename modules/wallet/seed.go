@@ -229,8 +229,8 @@ func (w *Wallet) LoadSeed(masterKey crypto.TwofishKey, seed modules.Seed) error
 	w.mu.RUnlock()
 
 	// scan blockchain to determine how many keys to generate for the seed
-	s := newSeedScanner(seed, w.log)
-	if err := s.scan(w.cs, w.tg.StopChan()); err != nil {
+	s, err := w.managedScanSeed(seed, types.ZeroCurrency)
+	if err != nil {
 		return err
 	}
 	// Add 4% as a buffer because the seed may have addresses in the wild
@@ -239,7 +239,7 @@ func (w *Wallet) LoadSeed(masterKey crypto.TwofishKey, seed modules.Seed) error
 	seedProgress += seedProgress / 25
 	w.log.Printf("INFO: found key index %v in blockchain. Setting auxiliary seed progress to %v", s.largestIndexSeen, seedProgress)
 
-	err := func() error {
+	err = func() error {
 		w.mu.Lock()
 		defer w.mu.Unlock()
 
@@ -266,22 +266,10 @@ func (w *Wallet) LoadSeed(masterKey crypto.TwofishKey, seed modules.Seed) error
 		w.integrateSeed(seed, seedProgress)
 		w.seeds = append(w.seeds, seed)
 
-		// delete the set of processed transactions; they will be recreated
-		// when we rescan
-		if err = w.dbTx.DeleteBucket(bucketProcessedTransactions); err != nil {
-			return err
-		}
-		if _, err = w.dbTx.CreateBucket(bucketProcessedTransactions); err != nil {
-			return err
-		}
+		// delete the set of processed transactions and reset the consensus
+		// change ID and height; they will be recreated when we rescan
 		w.unconfirmedProcessedTransactions = nil
-
-		// reset the consensus change ID and height in preparation for rescan
-		err = dbPutConsensusChangeID(w.dbTx, modules.ConsensusChangeBeginning)
-		if err != nil {
-			return err
-		}
-		return dbPutConsensusHeight(w.dbTx, 0)
+		return dbResetHistory(w.dbTx)
 	}()
 	if err != nil {
 		return err
@@ -339,12 +327,11 @@ func (w *Wallet) SweepSeed(seed modules.Seed) (coins, funds types.Currency, err
 
 	// scan blockchain for outputs, filtering out 'dust' (outputs that cost
 	// more in fees than they are worth)
-	s := newSeedScanner(seed, w.log)
 	_, maxFee := w.tpool.FeeEstimation()
 	const outputSize = 350 // approx. size in bytes of an output and accompanying signature
 	const maxOutputs = 50  // approx. number of outputs that a transaction can handle
-	s.dustThreshold = maxFee.Mul64(outputSize)
-	if err = s.scan(w.cs, w.tg.StopChan()); err != nil {
+	s, err := w.managedScanSeed(seed, maxFee.Mul64(outputSize))
+	if err != nil {
 		return
 	}
 
@@ -22,12 +22,20 @@ var (
 	// errDustOutput indicates an output is not spendable because it is dust.
 	errDustOutput = errors.New("output is too small")
 
+	// errDuplicateOutput indicates that an output was selected more than once
+	// to fund a transaction.
+	errDuplicateOutput = errors.New("output was selected more than once")
+
 	// errOutputTimelock indicates an output's timelock is still active.
 	errOutputTimelock = errors.New("wallet consensus set height is lower than the output timelock")
 
 	// errSpendHeightTooHigh indicates an output's spend height is greater than
 	// the allowed height.
 	errSpendHeightTooHigh = errors.New("output spend height exceeds the allowed height")
+
+	// errUnknownOutput indicates that a selected output is not a confirmed
+	// output of the wallet.
+	errUnknownOutput = errors.New("output is not a confirmed output of the wallet")
 )
 
 // transactionBuilder allows transactions to be manually constructed, including
@@ -251,6 +259,73 @@ func (tb *transactionBuilder) FundSiacoins(amount types.Currency) error {
 	return nil
 }
 
+// fundSiacoinsFromOutputs will add a siacoin input for each of the outputs
+// specified by 'ids' to the transaction, along with a refund output for the
+// value of the outputs that exceeds 'amount'. Unlike FundSiacoins, no parent
+// transaction is created, so only the selected outputs are spent. The siacoin
+// inputs will not be signed until 'Sign' is called on the transaction builder.
+func (tb *transactionBuilder) fundSiacoinsFromOutputs(ids []types.SiacoinOutputID, amount types.Currency) error {
+	// dustThreshold has to be obtained separate from the lock
+	dustThreshold := tb.wallet.DustThreshold()
+
+	tb.wallet.mu.Lock()
+	defer tb.wallet.mu.Unlock()
+
+	consensusHeight, err := dbGetConsensusHeight(tb.wallet.dbTx)
+	if err != nil {
+		return err
+	}
+
+	// Check that each of the outputs can be spent.
+	var fund types.Currency
+	outputs := make(map[types.SiacoinOutputID]types.SiacoinOutput)
+	for _, scoid := range ids {
+		if _, exists := outputs[scoid]; exists {
+			return errDuplicateOutput
+		}
+		sco, err := dbGetSiacoinOutput(tb.wallet.dbTx, scoid)
+		if err != nil {
+			return errUnknownOutput
+		}
+		if err := tb.wallet.checkOutput(tb.wallet.dbTx, consensusHeight, scoid, sco, dustThreshold); err != nil {
+			return err
+		}
+		outputs[scoid] = sco
+		fund = fund.Add(sco.Value)
+	}
+	if fund.Cmp(amount) < 0 {
+		return modules.ErrLowBalance
+	}
+
+	// Add a siacoin input for each output and mark the output as spent.
+	for _, scoid := range ids {
+		sci := types.SiacoinInput{
+			ParentID:         scoid,
+			UnlockConditions: tb.wallet.keys[outputs[scoid].UnlockHash].UnlockConditions,
+		}
+		tb.siacoinInputs = append(tb.siacoinInputs, len(tb.transaction.SiacoinInputs))
+		tb.transaction.SiacoinInputs = append(tb.transaction.SiacoinInputs, sci)
+		err = dbPutSpentOutput(tb.wallet.dbTx, types.OutputID(scoid), consensusHeight)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Create a refund output if needed.
+	if !amount.Equals(fund) {
+		refundUnlockConditions, err := tb.wallet.nextPrimarySeedAddress(tb.wallet.dbTx)
+		if err != nil {
+			return err
+		}
+		refundOutput := types.SiacoinOutput{
+			Value:      fund.Sub(amount),
+			UnlockHash: refundUnlockConditions.UnlockHash(),
+		}
+		tb.transaction.SiacoinOutputs = append(tb.transaction.SiacoinOutputs, refundOutput)
+	}
+	return nil
+}
+
 // FundSiafunds will add a siafund input of exactly 'amount' to the
 // transaction. A parent transaction may be needed to achieve an input with the
 // correct value. The siafund input will not be signed until 'Sign' is called
@@ -0,0 +1,160 @@
+package wallet
+
+import (
+	"errors"
+
+	"github.com/pachisi456/Sia/crypto"
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/persist"
+	"github.com/pachisi456/Sia/types"
+)
+
+var (
+	// transactionFileMetadata is the header of the files that hold
+	// transactions for cold signing.
+	transactionFileMetadata = persist.Metadata{
+		Header:  "Sia Transaction File",
+		Version: "1.0",
+	}
+
+	// errInvalidTransactionFile is returned if the spent outputs of a
+	// transaction file do not match the inputs of its transaction.
+	errInvalidTransactionFile = errors.New("transaction file has a different number of spent outputs and siacoin inputs")
+
+	// errNotWatched is returned if a transaction file is created for an
+	// address that is not a watch-only address of the wallet.
+	errNotWatched = errors.New("address is not a watch-only address of the wallet")
+
+	// errTransactionFileSigned is returned if a transaction file is signed
+	// twice.
+	errTransactionFileSigned = errors.New("transaction file has already been signed")
+
+	// errTransactionFileUnsigned is returned if an unsigned transaction file
+	// is broadcast.
+	errTransactionFileUnsigned = errors.New("transaction file has not been signed")
+
+	// errUnknownSpendKey is returned if the wallet does not have the key of
+	// an output spent by a transaction file.
+	errUnknownSpendKey = errors.New("wallet does not have the key of an output spent by the transaction")
+)
+
+// transactionFile is a transaction that is signed on an offline node. The
+// unlock conditions of its siacoin inputs are left empty until it is signed,
+// as the online node only knows the addresses of its outputs. SpentOutputs
+// holds the outputs spent by the siacoin inputs, in the same order, so that
+// the offline node can find the keys of the inputs and verify their values.
+type transactionFile struct {
+	Transaction  types.Transaction     `json:"transaction"`
+	SpentOutputs []types.SiacoinOutput `json:"spentoutputs"`
+}
+
+// CreateTransactionFile creates an unsigned transaction that sends the
+// outputs from the watch-only address addr, paying the provided miner fee,
+// and writes it to filename. The confirmed outputs of the address are used to
+// fund the transaction, and any excess is refunded to the address. The file
+// is signed by the wallet that owns the keys of the address using
+// SignTransactionFile, and then broadcast using BroadcastTransactionFile.
+func (w *Wallet) CreateTransactionFile(filename string, addr types.UnlockHash, outputs []types.SiacoinOutput, fee types.Currency) error {
+	if err := w.tg.Add(); err != nil {
+		return err
+	}
+	defer w.tg.Done()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.isWatchedAddress(addr) {
+		return errNotWatched
+	}
+
+	txn, parents, err := w.buildWatchedTransaction(addr, types.UnlockConditions{}, outputs, fee)
+	if err != nil {
+		return err
+	}
+	err = persist.SaveJSON(transactionFileMetadata, transactionFile{
+		Transaction:  txn,
+		SpentOutputs: parents,
+	}, filename)
+	if err != nil {
+		// the outputs were never handed out, so they can be spent again
+		for _, sci := range txn.SiacoinInputs {
+			dbDeleteSpentOutput(w.dbTx, types.OutputID(sci.ParentID))
+		}
+		return err
+	}
+	return nil
+}
+
+// SignTransactionFile signs the transaction of a transaction file created by
+// CreateTransactionFile, overwriting the file with the signed transaction.
+// The wallet must have the keys of all outputs spent by the transaction. As
+// signing only requires the keys, the wallet does not need to be synced,
+// which allows transaction files to be signed on an air-gapped node.
+func (w *Wallet) SignTransactionFile(filename string) error {
+	if err := w.tg.Add(); err != nil {
+		return err
+	}
+	defer w.tg.Done()
+
+	var tf transactionFile
+	if err := persist.LoadJSON(transactionFileMetadata, &tf, filename); err != nil {
+		return err
+	}
+	txn := tf.Transaction
+	if len(tf.SpentOutputs) != len(txn.SiacoinInputs) {
+		return errInvalidTransactionFile
+	}
+	if len(txn.TransactionSignatures) != 0 {
+		return errTransactionFileSigned
+	}
+
+	err := func() error {
+		w.mu.RLock()
+		defer w.mu.RUnlock()
+		if !w.unlocked {
+			return modules.ErrLockedWallet
+		}
+
+		// Fill in the unlock conditions of all inputs before adding any
+		// signatures, as the signatures cover the whole transaction.
+		keys := make([]spendableKey, len(txn.SiacoinInputs))
+		for i, sco := range tf.SpentOutputs {
+			sk, exists := w.keys[sco.UnlockHash]
+			if !exists {
+				return errUnknownSpendKey
+			}
+			txn.SiacoinInputs[i].UnlockConditions = sk.UnlockConditions
+			keys[i] = sk
+		}
+		for i, sci := range txn.SiacoinInputs {
+			addSignatures(&txn, types.FullCoveredFields, sci.UnlockConditions, crypto.Hash(sci.ParentID), keys[i])
+		}
+		return nil
+	}()
+	if err != nil {
+		return err
+	}
+
+	tf.Transaction = txn
+	return persist.SaveJSON(transactionFileMetadata, tf, filename)
+}
+
+// BroadcastTransactionFile submits the signed transaction of a transaction
+// file to the transaction pool, which broadcasts it to the network.
+func (w *Wallet) BroadcastTransactionFile(filename string) (types.Transaction, error) {
+	if err := w.tg.Add(); err != nil {
+		return types.Transaction{}, err
+	}
+	defer w.tg.Done()
+
+	var tf transactionFile
+	if err := persist.LoadJSON(transactionFileMetadata, &tf, filename); err != nil {
+		return types.Transaction{}, err
+	}
+	if len(tf.Transaction.TransactionSignatures) == 0 {
+		return types.Transaction{}, errTransactionFileUnsigned
+	}
+	err := w.tpool.AcceptTransactionSet([]types.Transaction{tf.Transaction})
+	if err != nil && err != modules.ErrDuplicateTransactionSet {
+		return types.Transaction{}, err
+	}
+	return tf.Transaction, nil
+}
@@ -0,0 +1,121 @@
+package wallet
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/pachisi456/Sia/crypto"
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/types"
+
+	"github.com/NebulousLabs/fastrand"
+)
+
+// TestTransactionFile probes the cold signing of a transaction file, where a
+// wallet that watches an address creates and broadcasts the transaction, and
+// a wallet that has the key of the address signs it.
+func TestTransactionFile(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	// Create the cold wallet. It shares the consensus set of the wallet
+	// tester, but signing does not depend on it.
+	cold, err := New(wt.cs, wt.tpool, filepath.Join(wt.persistDir, "cold"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cold.Close()
+	var masterKey crypto.TwofishKey
+	fastrand.Read(masterKey[:])
+	if _, err := cold.Encrypt(masterKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := cold.Unlock(masterKey); err != nil {
+		t.Fatal(err)
+	}
+	uc, err := cold.NextAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	coldAddr := uc.UnlockHash()
+
+	// Watch the cold address and fund it.
+	if err := wt.wallet.WatchAddresses([]types.UnlockHash{coldAddr}); err != nil {
+		t.Fatal(err)
+	}
+	fundValue := types.SiacoinPrecision.Mul64(10)
+	if _, err := wt.wallet.SendSiacoins(fundValue, coldAddr); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Create the transaction file.
+	var dest types.UnlockHash
+	fastrand.Read(dest[:])
+	sendValue := types.SiacoinPrecision.Mul64(4)
+	fee := types.SiacoinPrecision
+	outputs := []types.SiacoinOutput{{Value: sendValue, UnlockHash: dest}}
+	filename := filepath.Join(wt.persistDir, "transaction.json")
+	if err := wt.wallet.CreateTransactionFile(filename, dest, outputs, fee); err != errNotWatched {
+		t.Fatal("expected errNotWatched, got", err)
+	}
+	if err := wt.wallet.CreateTransactionFile(filename, coldAddr, outputs, fee); err != nil {
+		t.Fatal(err)
+	}
+
+	// The outputs of the cold address are spent by the file, so a second file
+	// cannot be funded.
+	if err := wt.wallet.CreateTransactionFile(filepath.Join(wt.persistDir, "other.json"), coldAddr, outputs, fee); err != modules.ErrLowBalance {
+		t.Fatal("expected ErrLowBalance, got", err)
+	}
+
+	// Only the cold wallet can sign the file, and only signed files can be
+	// broadcast.
+	if _, err := wt.wallet.BroadcastTransactionFile(filename); err != errTransactionFileUnsigned {
+		t.Fatal("expected errTransactionFileUnsigned, got", err)
+	}
+	if err := wt.wallet.SignTransactionFile(filename); err != errUnknownSpendKey {
+		t.Fatal("expected errUnknownSpendKey, got", err)
+	}
+	if err := cold.SignTransactionFile(filename); err != nil {
+		t.Fatal(err)
+	}
+	if err := cold.SignTransactionFile(filename); err != errTransactionFileSigned {
+		t.Fatal("expected errTransactionFileSigned, got", err)
+	}
+
+	// Broadcast the signed file.
+	txn, err := wt.wallet.BroadcastTransactionFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+	pts, err := wt.wallet.WatchedTransactions(0, ^types.BlockHeight(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var confirmed bool
+	for _, pt := range pts {
+		confirmed = confirmed || pt.TransactionID == txn.ID()
+	}
+	if !confirmed {
+		t.Fatal("broadcast transaction was not confirmed")
+	}
+	was, err := wt.wallet.WatchedAddresses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(was) != 1 || !was[0].SiacoinBalance.Equals(fundValue.Sub(sendValue).Sub(fee)) {
+		t.Fatal("cold address has the wrong balance:", was)
+	}
+}
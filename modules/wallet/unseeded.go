@@ -181,18 +181,8 @@ func (w *Wallet) LoadSiagKeys(masterKey crypto.TwofishKey, keyfiles []string) er
 			return err
 		}
 
-		if err = w.dbTx.DeleteBucket(bucketProcessedTransactions); err != nil {
-			return err
-		}
-		if _, err = w.dbTx.CreateBucket(bucketProcessedTransactions); err != nil {
-			return err
-		}
 		w.unconfirmedProcessedTransactions = nil
-		err = dbPutConsensusChangeID(w.dbTx, modules.ConsensusChangeBeginning)
-		if err != nil {
-			return err
-		}
-		return dbPutConsensusHeight(w.dbTx, 0)
+		return dbResetHistory(w.dbTx)
 	}()
 	if err != nil {
 		return err
@@ -251,18 +241,8 @@ func (w *Wallet) Load033xWallet(masterKey crypto.TwofishKey, filepath033x string
 			return errAllDuplicates
 		}
 
-		if err = w.dbTx.DeleteBucket(bucketProcessedTransactions); err != nil {
-			return err
-		}
-		if _, err = w.dbTx.CreateBucket(bucketProcessedTransactions); err != nil {
-			return err
-		}
 		w.unconfirmedProcessedTransactions = nil
-		err = dbPutConsensusChangeID(w.dbTx, modules.ConsensusChangeBeginning)
-		if err != nil {
-			return err
-		}
-		return dbPutConsensusHeight(w.dbTx, 0)
+		return dbResetHistory(w.dbTx)
 	}()
 	if err != nil {
 		return err
@@ -77,6 +77,13 @@ func (w *Wallet) isWalletAddress(uh types.UnlockHash) bool {
 	return exists
 }
 
+// isWatchedAddress is a helper function that checks if an UnlockHash is one
+// of the wallet's watch-only addresses.
+func (w *Wallet) isWatchedAddress(uh types.UnlockHash) bool {
+	_, exists := w.watchedAddrs[uh]
+	return exists
+}
+
 // updateLookahead uses a consensus change to update the seed progress if one of the outputs
 // contains an unlock hash of the lookahead set. Returns true if a blockchain rescan is required
 func (w *Wallet) updateLookahead(tx *bolt.Tx, cc modules.ConsensusChange) (bool, error) {
@@ -141,6 +148,34 @@ func (w *Wallet) updateConfirmedSet(tx *bolt.Tx, cc modules.ConsensusChange) err
 			w.log.Severe("Could not update siafund output:", err)
 		}
 	}
+	for _, diff := range cc.SiacoinOutputDiffs {
+		if !w.isWatchedAddress(diff.SiacoinOutput.UnlockHash) {
+			continue
+		}
+		var err error
+		if diff.Direction == modules.DiffApply {
+			err = dbPutWatchedSiacoinOutput(tx, diff.ID, diff.SiacoinOutput)
+		} else {
+			err = dbDeleteWatchedSiacoinOutput(tx, diff.ID)
+		}
+		if err != nil {
+			w.log.Severe("Could not update watched siacoin output:", err)
+		}
+	}
+	for _, diff := range cc.SiafundOutputDiffs {
+		if !w.isWatchedAddress(diff.SiafundOutput.UnlockHash) {
+			continue
+		}
+		var err error
+		if diff.Direction == modules.DiffApply {
+			err = dbPutWatchedSiafundOutput(tx, diff.ID, diff.SiafundOutput)
+		} else {
+			err = dbDeleteWatchedSiafundOutput(tx, diff.ID)
+		}
+		if err != nil {
+			w.log.Severe("Could not update watched siafund output:", err)
+		}
+	}
 	for _, diff := range cc.SiafundPoolDiffs {
 		var err error
 		if diff.Direction == modules.DiffApply {
@@ -155,37 +190,45 @@ func (w *Wallet) updateConfirmedSet(tx *bolt.Tx, cc modules.ConsensusChange) err
 	return nil
 }
 
-// revertHistory reverts any transaction history that was destroyed by reverted
-// blocks in the consensus change.
-func (w *Wallet) revertHistory(tx *bolt.Tx, reverted []types.Block) error {
-	for _, block := range reverted {
-		// Remove any transactions that have been reverted.
-		for i := len(block.Transactions) - 1; i >= 0; i-- {
-			// If the transaction is relevant to the wallet, it will be the
-			// most recent transaction in bucketProcessedTransactions.
-			txid := block.Transactions[i].ID()
-			pt, err := dbGetLastProcessedTransaction(tx)
-			if err != nil {
-				break // bucket is empty
-			}
-			if txid == pt.TransactionID {
-				w.log.Println("A wallet transaction has been reverted due to a reorg:", txid)
-				if err := dbDeleteLastProcessedTransaction(tx); err != nil {
-					w.log.Severe("Could not revert transaction:", err)
-				}
+// revertBlockHistory removes the transactions of a reverted block from a
+// bucket of ProcessedTransactions. isAddr reports whether an address is
+// tracked by the bucket.
+func (w *Wallet) revertBlockHistory(tx *bolt.Tx, bucket []byte, block types.Block, isAddr func(types.UnlockHash) bool) {
+	// Remove any transactions that have been reverted.
+	for i := len(block.Transactions) - 1; i >= 0; i-- {
+		// If the transaction is relevant to the wallet, it will be the
+		// most recent transaction in the bucket.
+		txid := block.Transactions[i].ID()
+		pt, err := dbGetLastProcessedTransaction(tx, bucket)
+		if err != nil {
+			break // bucket is empty
+		}
+		if txid == pt.TransactionID {
+			w.log.Println("A wallet transaction has been reverted due to a reorg:", txid)
+			if err := dbDeleteLastProcessedTransaction(tx, bucket); err != nil {
+				w.log.Severe("Could not revert transaction:", err)
 			}
 		}
+	}
 
-		// Remove the miner payout transaction if applicable.
-		for i, mp := range block.MinerPayouts {
-			if w.isWalletAddress(mp.UnlockHash) {
-				w.log.Println("Miner payout has been reverted due to a reorg:", block.MinerPayoutID(uint64(i)), "::", mp.Value.HumanString())
-				if err := dbDeleteLastProcessedTransaction(tx); err != nil {
-					w.log.Severe("Could not revert transaction:", err)
-				}
-				break // there will only ever be one miner transaction
+	// Remove the miner payout transaction if applicable.
+	for i, mp := range block.MinerPayouts {
+		if isAddr(mp.UnlockHash) {
+			w.log.Println("Miner payout has been reverted due to a reorg:", block.MinerPayoutID(uint64(i)), "::", mp.Value.HumanString())
+			if err := dbDeleteLastProcessedTransaction(tx, bucket); err != nil {
+				w.log.Severe("Could not revert transaction:", err)
 			}
+			break // there will only ever be one miner transaction
 		}
+	}
+}
+
+// revertHistory reverts any transaction history that was destroyed by reverted
+// blocks in the consensus change.
+func (w *Wallet) revertHistory(tx *bolt.Tx, reverted []types.Block) error {
+	for _, block := range reverted {
+		w.revertBlockHistory(tx, bucketProcessedTransactions, block, w.isWalletAddress)
+		w.revertBlockHistory(tx, bucketWatchedTransactions, block, w.isWatchedAddress)
 
 		// decrement the consensus height
 		if block.ID() != types.GenesisID {
@@ -229,14 +272,16 @@ func computeSpentSiafundOutputSet(diffs []modules.SiafundOutputDiff) spentSiafun
 
 // computeProcessedTransactionsFromBlock searches all the miner payouts and
 // transactions in a block and computes a ProcessedTransaction slice containing
-// all of the transactions processed for the given block.
-func (w *Wallet) computeProcessedTransactionsFromBlock(tx *bolt.Tx, block types.Block, spentSiacoinOutputs spentSiacoinOutputSet, spentSiafundOutputs spentSiafundOutputSet, consensusHeight types.BlockHeight) []modules.ProcessedTransaction {
+// all of the transactions processed for the given block. isAddr reports
+// whether an address is relevant, which is either a wallet address or a
+// watched address.
+func (w *Wallet) computeProcessedTransactionsFromBlock(tx *bolt.Tx, block types.Block, spentSiacoinOutputs spentSiacoinOutputSet, spentSiafundOutputs spentSiafundOutputSet, consensusHeight types.BlockHeight, isAddr func(types.UnlockHash) bool) []modules.ProcessedTransaction {
 	var pts []modules.ProcessedTransaction
 
 	// Find ProcessedTransactions from miner payouts.
 	relevant := false
 	for _, mp := range block.MinerPayouts {
-		relevant = relevant || w.isWalletAddress(mp.UnlockHash)
+		relevant = relevant || isAddr(mp.UnlockHash)
 	}
 	if relevant {
 		w.log.Println("Wallet has received new miner payouts:", block.ID())
@@ -253,7 +298,7 @@ func (w *Wallet) computeProcessedTransactionsFromBlock(tx *bolt.Tx, block types.
 				ID:             types.OutputID(block.MinerPayoutID(uint64(i))),
 				FundType:       types.SpecifierMinerPayout,
 				MaturityHeight: consensusHeight + types.MaturityDelay,
-				WalletAddress:  w.isWalletAddress(mp.UnlockHash),
+				WalletAddress:  isAddr(mp.UnlockHash),
 				RelatedAddress: mp.UnlockHash,
 				Value:          mp.Value,
 			})
@@ -266,16 +311,16 @@ func (w *Wallet) computeProcessedTransactionsFromBlock(tx *bolt.Tx, block types.
 		// Determine if transaction is relevant.
 		relevant := false
 		for _, sci := range txn.SiacoinInputs {
-			relevant = relevant || w.isWalletAddress(sci.UnlockConditions.UnlockHash())
+			relevant = relevant || isAddr(sci.UnlockConditions.UnlockHash())
 		}
 		for _, sco := range txn.SiacoinOutputs {
-			relevant = relevant || w.isWalletAddress(sco.UnlockHash)
+			relevant = relevant || isAddr(sco.UnlockHash)
 		}
 		for _, sfi := range txn.SiafundInputs {
-			relevant = relevant || w.isWalletAddress(sfi.UnlockConditions.UnlockHash())
+			relevant = relevant || isAddr(sfi.UnlockConditions.UnlockHash())
 		}
 		for _, sfo := range txn.SiafundOutputs {
-			relevant = relevant || w.isWalletAddress(sfo.UnlockHash)
+			relevant = relevant || isAddr(sfo.UnlockHash)
 		}
 
 		// Only create a ProcessedTransaction if transaction is relevant.
@@ -295,7 +340,7 @@ func (w *Wallet) computeProcessedTransactionsFromBlock(tx *bolt.Tx, block types.
 			pi := modules.ProcessedInput{
 				ParentID:       types.OutputID(sci.ParentID),
 				FundType:       types.SpecifierSiacoinInput,
-				WalletAddress:  w.isWalletAddress(sci.UnlockConditions.UnlockHash()),
+				WalletAddress:  isAddr(sci.UnlockConditions.UnlockHash()),
 				RelatedAddress: sci.UnlockConditions.UnlockHash(),
 				Value:          spentSiacoinOutputs[sci.ParentID].Value,
 			}
@@ -312,7 +357,7 @@ func (w *Wallet) computeProcessedTransactionsFromBlock(tx *bolt.Tx, block types.
 				ID:             types.OutputID(txn.SiacoinOutputID(uint64(i))),
 				FundType:       types.SpecifierSiacoinOutput,
 				MaturityHeight: consensusHeight,
-				WalletAddress:  w.isWalletAddress(sco.UnlockHash),
+				WalletAddress:  isAddr(sco.UnlockHash),
 				RelatedAddress: sco.UnlockHash,
 				Value:          sco.Value,
 			}
@@ -328,7 +373,7 @@ func (w *Wallet) computeProcessedTransactionsFromBlock(tx *bolt.Tx, block types.
 			pi := modules.ProcessedInput{
 				ParentID:       types.OutputID(sfi.ParentID),
 				FundType:       types.SpecifierSiafundInput,
-				WalletAddress:  w.isWalletAddress(sfi.UnlockConditions.UnlockHash()),
+				WalletAddress:  isAddr(sfi.UnlockConditions.UnlockHash()),
 				RelatedAddress: sfi.UnlockConditions.UnlockHash(),
 				Value:          spentSiafundOutputs[sfi.ParentID].Value,
 			}
@@ -349,7 +394,7 @@ func (w *Wallet) computeProcessedTransactionsFromBlock(tx *bolt.Tx, block types.
 				ID:             types.OutputID(sfi.ParentID),
 				FundType:       types.SpecifierClaimOutput,
 				MaturityHeight: consensusHeight + types.MaturityDelay,
-				WalletAddress:  w.isWalletAddress(sfi.UnlockConditions.UnlockHash()),
+				WalletAddress:  isAddr(sfi.UnlockConditions.UnlockHash()),
 				RelatedAddress: sfi.ClaimUnlockHash,
 				Value:          siafundPool.Sub(sfo.ClaimStart).Mul(sfo.Value),
 			}
@@ -365,7 +410,7 @@ func (w *Wallet) computeProcessedTransactionsFromBlock(tx *bolt.Tx, block types.
 				ID:             types.OutputID(txn.SiafundOutputID(uint64(i))),
 				FundType:       types.SpecifierSiafundOutput,
 				MaturityHeight: consensusHeight,
-				WalletAddress:  w.isWalletAddress(sfo.UnlockHash),
+				WalletAddress:  isAddr(sfo.UnlockHash),
 				RelatedAddress: sfo.UnlockHash,
 				Value:          sfo.Value,
 			}
@@ -407,13 +452,23 @@ func (w *Wallet) applyHistory(tx *bolt.Tx, cc modules.ConsensusChange) error {
 			}
 		}
 
-		pts := w.computeProcessedTransactionsFromBlock(tx, block, spentSiacoinOutputs, spentSiafundOutputs, consensusHeight)
+		pts := w.computeProcessedTransactionsFromBlock(tx, block, spentSiacoinOutputs, spentSiafundOutputs, consensusHeight, w.isWalletAddress)
 		for _, pt := range pts {
-			err := dbAppendProcessedTransaction(tx, pt)
+			err := dbAppendProcessedTransaction(tx, bucketProcessedTransactions, pt)
 			if err != nil {
 				return fmt.Errorf("could not put processed transaction: %v", err)
 			}
 		}
+		if len(w.watchedAddrs) == 0 {
+			continue
+		}
+		pts = w.computeProcessedTransactionsFromBlock(tx, block, spentSiacoinOutputs, spentSiafundOutputs, consensusHeight, w.isWatchedAddress)
+		for _, pt := range pts {
+			err := dbAppendProcessedTransaction(tx, bucketWatchedTransactions, pt)
+			if err != nil {
+				return fmt.Errorf("could not put watched transaction: %v", err)
+			}
+		}
 	}
 
 	return nil
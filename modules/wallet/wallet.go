@@ -72,6 +72,16 @@ type Wallet struct {
 	keys      map[types.UnlockHash]spendableKey
 	lookahead map[types.UnlockHash]uint64
 
+	// watchedAddrs are the watch-only addresses of the wallet. Their outputs
+	// and transactions are tracked separately from those of the wallet's
+	// keys, as the wallet cannot spend from them.
+	watchedAddrs map[types.UnlockHash]struct{}
+
+	// multisigAccounts are the multisig accounts of the wallet, keyed by
+	// their address. The address of each account is also a watch-only
+	// address.
+	multisigAccounts map[types.UnlockHash]multisigAccount
+
 	// unconfirmedProcessedTransactions tracks unconfirmed transactions.
 	//
 	// TODO: Replace this field with a linked list. Currently when a new
@@ -96,6 +106,10 @@ type Wallet struct {
 	// initialization.
 	scanLock siasync.TryMutex
 
+	// scanner is the seedScanner of a running seed scan, which is used to
+	// report the progress of the scan.
+	scanner *seedScanner
+
 	// The wallet's ThreadGroup tells tracked functions to shut down and
 	// blocks until they have all exited before returning from Close.
 	tg siasync.ThreadGroup
@@ -119,8 +133,11 @@ func New(cs modules.ConsensusSet, tpool modules.TransactionPool, persistDir stri
 		cs:    cs,
 		tpool: tpool,
 
-		keys:      make(map[types.UnlockHash]spendableKey),
-		lookahead: make(map[types.UnlockHash]uint64),
+		keys:         make(map[types.UnlockHash]spendableKey),
+		lookahead:    make(map[types.UnlockHash]uint64),
+		watchedAddrs: make(map[types.UnlockHash]struct{}),
+
+		multisigAccounts: make(map[types.UnlockHash]multisigAccount),
 
 		unconfirmedSets: make(map[modules.TransactionSetID][]types.TransactionID),
 
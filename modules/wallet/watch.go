@@ -0,0 +1,292 @@
+package wallet
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/pachisi456/Sia/modules"
+	"github.com/pachisi456/Sia/types"
+)
+
+// WatchAddresses adds addresses to the watch-only addresses of the wallet.
+// The outputs and transactions of watched addresses are tracked without the
+// wallet knowing their keys. If the wallet has already subscribed to the
+// consensus set, the blockchain is rescanned to find the history of the new
+// addresses.
+func (w *Wallet) WatchAddresses(addrs []types.UnlockHash) error {
+	if err := w.tg.Add(); err != nil {
+		return err
+	}
+	defer w.tg.Done()
+
+	// add the addresses and reset the consensus change ID and height in
+	// preparation for rescan
+	var rescan bool
+	err := func() error {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+
+		var added bool
+		for _, uh := range addrs {
+			if w.isWatchedAddress(uh) {
+				continue
+			}
+			if err := dbPutWatchedAddress(w.dbTx, uh); err != nil {
+				return err
+			}
+			w.watchedAddrs[uh] = struct{}{}
+			added = true
+		}
+		rescan = added && w.subscribed
+		if !rescan {
+			return nil
+		}
+		w.unconfirmedProcessedTransactions = nil
+		return dbResetHistory(w.dbTx)
+	}()
+	if err != nil || !rescan {
+		return err
+	}
+
+	// rescan the blockchain
+	w.cs.Unsubscribe(w)
+	w.tpool.Unsubscribe(w)
+
+	done := make(chan struct{})
+	go w.rescanMessage(done)
+	defer close(done)
+
+	err = w.cs.ConsensusSetSubscribe(w, modules.ConsensusChangeBeginning, w.tg.StopChan())
+	if err != nil {
+		return err
+	}
+	w.tpool.TransactionPoolSubscribe(w)
+	return nil
+}
+
+// UnwatchAddresses removes addresses from the watch-only addresses of the
+// wallet, along with their outputs. Transactions that do not involve any
+// remaining watched address are removed from the watched transactions.
+func (w *Wallet) UnwatchAddresses(addrs []types.UnlockHash) error {
+	if err := w.tg.Add(); err != nil {
+		return err
+	}
+	defer w.tg.Done()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, uh := range addrs {
+		if err := dbDeleteWatchedAddress(w.dbTx, uh); err != nil {
+			return err
+		}
+		delete(w.watchedAddrs, uh)
+	}
+
+	// delete the outputs of the addresses
+	var scoids []types.SiacoinOutputID
+	err := dbForEachWatchedSiacoinOutput(w.dbTx, func(id types.SiacoinOutputID, sco types.SiacoinOutput) {
+		if !w.isWatchedAddress(sco.UnlockHash) {
+			scoids = append(scoids, id)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	for _, id := range scoids {
+		if err := dbDeleteWatchedSiacoinOutput(w.dbTx, id); err != nil {
+			return err
+		}
+	}
+	var sfoids []types.SiafundOutputID
+	err = dbForEachWatchedSiafundOutput(w.dbTx, func(id types.SiafundOutputID, sfo types.SiafundOutput) {
+		if !w.isWatchedAddress(sfo.UnlockHash) {
+			sfoids = append(sfoids, id)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	for _, id := range sfoids {
+		if err := dbDeleteWatchedSiafundOutput(w.dbTx, id); err != nil {
+			return err
+		}
+	}
+
+	// rebuild the watched transactions from the transactions that are still
+	// relevant
+	var pts []modules.ProcessedTransaction
+	it := dbWatchedTransactionsIterator(w.dbTx)
+	for it.next() {
+		pt := it.value()
+		relevant := false
+		for i := range pt.Inputs {
+			pt.Inputs[i].WalletAddress = w.isWatchedAddress(pt.Inputs[i].RelatedAddress)
+			relevant = relevant || pt.Inputs[i].WalletAddress
+		}
+		for i := range pt.Outputs {
+			if pt.Outputs[i].FundType == types.SpecifierMinerFee {
+				continue
+			}
+			pt.Outputs[i].WalletAddress = w.isWatchedAddress(pt.Outputs[i].RelatedAddress)
+			relevant = relevant || pt.Outputs[i].WalletAddress
+		}
+		if relevant {
+			pts = append(pts, pt)
+		}
+	}
+	if err := w.dbTx.DeleteBucket(bucketWatchedTransactions); err != nil {
+		return err
+	}
+	if _, err := w.dbTx.CreateBucket(bucketWatchedTransactions); err != nil {
+		return err
+	}
+	for _, pt := range pts {
+		if err := dbAppendProcessedTransaction(w.dbTx, bucketWatchedTransactions, pt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WatchedAddresses returns the watch-only addresses of the wallet along with
+// their confirmed balances. Addresses are returned sorted in byte-order.
+func (w *Wallet) WatchedAddresses() ([]modules.WatchedAddress, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// ensure durability of reported balances
+	w.syncDB()
+
+	balances := make(map[types.UnlockHash]*modules.WatchedAddress, len(w.watchedAddrs))
+	for uh := range w.watchedAddrs {
+		balances[uh] = &modules.WatchedAddress{Address: uh}
+	}
+	err := dbForEachWatchedSiacoinOutput(w.dbTx, func(_ types.SiacoinOutputID, sco types.SiacoinOutput) {
+		if wa, exists := balances[sco.UnlockHash]; exists {
+			wa.SiacoinBalance = wa.SiacoinBalance.Add(sco.Value)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = dbForEachWatchedSiafundOutput(w.dbTx, func(_ types.SiafundOutputID, sfo types.SiafundOutput) {
+		if wa, exists := balances[sfo.UnlockHash]; exists {
+			wa.SiafundBalance = wa.SiafundBalance.Add(sfo.Value)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	was := make([]modules.WatchedAddress, 0, len(balances))
+	for _, wa := range balances {
+		was = append(was, *wa)
+	}
+	sort.Slice(was, func(i, j int) bool {
+		return bytes.Compare(was[i].Address[:], was[j].Address[:]) < 0
+	})
+	return was, nil
+}
+
+// WatchedTransactions returns all transactions relevant to the watch-only
+// addresses of the wallet that were confirmed in the range [startHeight,
+// endHeight].
+func (w *Wallet) WatchedTransactions(startHeight, endHeight types.BlockHeight) (pts []modules.ProcessedTransaction, err error) {
+	// ensure durability of reported transactions
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.syncDB()
+
+	height, err := dbGetConsensusHeight(w.dbTx)
+	if err != nil {
+		return
+	} else if startHeight > height || startHeight > endHeight {
+		return nil, errOutOfBounds
+	}
+
+	it := dbWatchedTransactionsIterator(w.dbTx)
+	for it.next() {
+		pt := it.value()
+		if pt.ConfirmationHeight < startHeight {
+			continue
+		} else if pt.ConfirmationHeight > endHeight {
+			// transactions are stored in chronological order, so we can
+			// break as soon as we are above endHeight
+			break
+		}
+		pts = append(pts, pt)
+	}
+	return
+}
+
+// buildWatchedTransaction creates a transaction that sends the outputs from
+// the watch-only address addr, paying the provided miner fee. The confirmed
+// outputs of the address that have not recently been spent are used to fund
+// the transaction, largest first, and any excess is refunded to addr. The
+// inputs use the provided unlock conditions and are marked as spent. The
+// transaction is returned along with the outputs spent by its inputs.
+func (w *Wallet) buildWatchedTransaction(addr types.UnlockHash, uc types.UnlockConditions, outputs []types.SiacoinOutput, fee types.Currency) (types.Transaction, []types.SiacoinOutput, error) {
+	consensusHeight, err := dbGetConsensusHeight(w.dbTx)
+	if err != nil {
+		return types.Transaction{}, nil, err
+	}
+
+	// Collect a value-sorted set of the outputs of the address that have not
+	// recently been spent.
+	var so sortedOutputs
+	err = dbForEachWatchedSiacoinOutput(w.dbTx, func(scoid types.SiacoinOutputID, sco types.SiacoinOutput) {
+		if sco.UnlockHash != addr {
+			return
+		}
+		spendHeight, err := dbGetSpentOutput(w.dbTx, types.OutputID(scoid))
+		if err == nil && spendHeight+RespendTimeout > consensusHeight {
+			return
+		}
+		so.ids = append(so.ids, scoid)
+		so.outputs = append(so.outputs, sco)
+	})
+	if err != nil {
+		return types.Transaction{}, nil, err
+	}
+	sort.Sort(sort.Reverse(so))
+
+	amount := fee
+	for _, sco := range outputs {
+		amount = amount.Add(sco.Value)
+	}
+	var txn types.Transaction
+	var parents []types.SiacoinOutput
+	var fund types.Currency
+	for i := range so.ids {
+		if fund.Cmp(amount) >= 0 {
+			break
+		}
+		txn.SiacoinInputs = append(txn.SiacoinInputs, types.SiacoinInput{
+			ParentID:         so.ids[i],
+			UnlockConditions: uc,
+		})
+		parents = append(parents, so.outputs[i])
+		fund = fund.Add(so.outputs[i].Value)
+	}
+	if fund.Cmp(amount) < 0 {
+		return types.Transaction{}, nil, modules.ErrLowBalance
+	}
+	txn.SiacoinOutputs = append(txn.SiacoinOutputs, outputs...)
+	if !fund.Equals(amount) {
+		txn.SiacoinOutputs = append(txn.SiacoinOutputs, types.SiacoinOutput{
+			Value:      fund.Sub(amount),
+			UnlockHash: addr,
+		})
+	}
+	if !fee.IsZero() {
+		txn.MinerFees = append(txn.MinerFees, fee)
+	}
+
+	for _, sci := range txn.SiacoinInputs {
+		err = dbPutSpentOutput(w.dbTx, types.OutputID(sci.ParentID), consensusHeight)
+		if err != nil {
+			return types.Transaction{}, nil, err
+		}
+	}
+	return txn, parents, nil
+}
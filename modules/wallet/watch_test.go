@@ -0,0 +1,107 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/pachisi456/Sia/types"
+
+	"github.com/NebulousLabs/fastrand"
+)
+
+// TestWatchAddresses probes the watch-only addresses of the wallet.
+func TestWatchAddresses(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	wt, err := createWalletTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wt.closeWt()
+
+	// Watch an address that the wallet does not own and send coins to it.
+	var addr types.UnlockHash
+	fastrand.Read(addr[:])
+	if err := wt.wallet.WatchAddresses([]types.UnlockHash{addr}); err != nil {
+		t.Fatal(err)
+	}
+	sendValue := types.SiacoinPrecision.Mul64(3)
+	if _, err := wt.wallet.SendSiacoins(sendValue, addr); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.miner.AddBlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	// checkWatched checks that the address is watched with the sent balance
+	// and a single transaction.
+	checkWatched := func() {
+		was, err := wt.wallet.WatchedAddresses()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var found bool
+		for _, wa := range was {
+			if wa.Address == addr {
+				found = true
+				if !wa.SiacoinBalance.Equals(sendValue) {
+					t.Fatal("watched address has the wrong balance:", wa.SiacoinBalance, sendValue)
+				}
+			}
+		}
+		if !found {
+			t.Fatal("address is not watched")
+		}
+		pts, err := wt.wallet.WatchedTransactions(0, ^types.BlockHeight(0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pts) != 1 {
+			t.Fatal("expected 1 watched transaction, got", len(pts))
+		}
+	}
+	checkWatched()
+
+	// Watching a second address rescans the blockchain, which should not
+	// duplicate the history of the first address.
+	var addr2 types.UnlockHash
+	fastrand.Read(addr2[:])
+	if err := wt.wallet.WatchAddresses([]types.UnlockHash{addr2}); err != nil {
+		t.Fatal(err)
+	}
+	checkWatched()
+	if was, err := wt.wallet.WatchedAddresses(); err != nil {
+		t.Fatal(err)
+	} else if len(was) != 2 {
+		t.Fatal("expected 2 watched addresses, got", len(was))
+	}
+
+	// The outputs of the watched address should only be marked as belonging
+	// to the wallet in the watched transactions.
+	for _, pt := range wt.wallet.AddressTransactions(addr) {
+		for _, output := range pt.Outputs {
+			if output.RelatedAddress == addr && output.WalletAddress {
+				t.Fatal("watched address was treated as a wallet address")
+			}
+		}
+	}
+
+	// Unwatch the first address.
+	if err := wt.wallet.UnwatchAddresses([]types.UnlockHash{addr}); err != nil {
+		t.Fatal(err)
+	}
+	was, err := wt.wallet.WatchedAddresses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(was) != 1 || was[0].Address != addr2 || !was[0].SiacoinBalance.IsZero() {
+		t.Fatal("unexpected watched addresses after unwatching:", was)
+	}
+	pts, err := wt.wallet.WatchedTransactions(0, ^types.BlockHeight(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pts) != 0 {
+		t.Fatal("transactions of an unwatched address are still tracked:", len(pts))
+	}
+}
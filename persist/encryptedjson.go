@@ -0,0 +1,94 @@
+package persist
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+
+	"github.com/pachisi456/Sia/crypto"
+)
+
+var (
+	// ErrBadEncryptionKey is returned if encrypted data can't be decrypted
+	// with the supplied key.
+	ErrBadEncryptionKey = errors.New("data can't be decrypted with the supplied key")
+
+	// ErrNoEncryptionKey is returned if data is encrypted, but no key was
+	// supplied to decrypt it.
+	ErrNoEncryptionKey = errors.New("data is encrypted, but no key was supplied")
+)
+
+// encryptedObject is the plaintext of a file written by SaveEncryptedJSON.
+type encryptedObject struct {
+	Metadata
+	Data json.RawMessage
+}
+
+// SaveEncryptedJSON saves a json object to encryptedFilename, encrypted with
+// key, in a durable, atomic way. Once the encrypted object is saved, the
+// plaintext file filename and its backup are deleted, so that data that was
+// saved before encryption was enabled does not remain on disk. If key is nil,
+// the object is saved to filename with SaveJSON instead.
+func SaveEncryptedJSON(meta Metadata, object interface{}, filename, encryptedFilename string, key *crypto.TwofishKey) error {
+	if key == nil {
+		return SaveJSON(meta, object, filename)
+	}
+
+	data, err := json.Marshal(object)
+	if err != nil {
+		return err
+	}
+	plaintext, err := json.Marshal(encryptedObject{meta, data})
+	if err != nil {
+		return err
+	}
+	handle, err := NewSafeFile(encryptedFilename)
+	if err != nil {
+		return err
+	}
+	defer handle.Close()
+	if _, err := handle.Write(key.EncryptBytes(plaintext)); err != nil {
+		return err
+	}
+	if err := handle.CommitSync(); err != nil {
+		return err
+	}
+	for _, path := range []string{filename, filename + tempSuffix} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadEncryptedJSON loads a json object saved by SaveEncryptedJSON. If no
+// encrypted object exists, e.g. because the object was saved before
+// encryption was enabled, the plaintext object is loaded from filename with
+// LoadJSON instead. ErrNoEncryptionKey is returned if the object is encrypted
+// and key is nil.
+func LoadEncryptedJSON(meta Metadata, object interface{}, filename, encryptedFilename string, key *crypto.TwofishKey) error {
+	ciphertext, err := ioutil.ReadFile(encryptedFilename)
+	if os.IsNotExist(err) {
+		return LoadJSON(meta, object, filename)
+	} else if err != nil {
+		return err
+	} else if key == nil {
+		return ErrNoEncryptionKey
+	}
+
+	plaintext, err := key.DecryptBytes(ciphertext)
+	if err != nil {
+		return ErrBadEncryptionKey
+	}
+	var eo encryptedObject
+	if err := json.Unmarshal(plaintext, &eo); err != nil {
+		return err
+	}
+	if eo.Header != meta.Header {
+		return ErrBadHeader
+	} else if eo.Version != meta.Version {
+		return ErrBadVersion
+	}
+	return json.Unmarshal(eo.Data, object)
+}
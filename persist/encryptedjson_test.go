@@ -0,0 +1,81 @@
+package persist
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pachisi456/Sia/build"
+	"github.com/pachisi456/Sia/crypto"
+)
+
+// TestSaveLoadEncryptedJSON checks that an object saved by SaveEncryptedJSON
+// can only be loaded with the right key, and that it replaces the plaintext
+// object.
+func TestSaveLoadEncryptedJSON(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	dir := filepath.Join(build.TempDir(persistDir), t.Name())
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	testMeta := Metadata{"Test Struct", "v1.2.1"}
+	type testStruct struct {
+		One string
+		Two uint64
+	}
+	obj1 := testStruct{"secret dog", 25}
+	filename := filepath.Join(dir, "obj.json")
+	encryptedFilename := filepath.Join(dir, "obj.enc")
+
+	// Save the object in plaintext, then encrypted.
+	if err := SaveEncryptedJSON(testMeta, obj1, filename, encryptedFilename, nil); err != nil {
+		t.Fatal(err)
+	}
+	var obj2 testStruct
+	key := crypto.GenerateTwofishKey()
+	if err := LoadEncryptedJSON(testMeta, &obj2, filename, encryptedFilename, &key); err != nil {
+		t.Fatal(err)
+	} else if obj2 != obj1 {
+		t.Fatal("persist mismatch:", obj2, obj1)
+	}
+	if err := SaveEncryptedJSON(testMeta, obj1, filename, encryptedFilename, &key); err != nil {
+		t.Fatal(err)
+	}
+
+	// The plaintext object should be gone.
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fi := range files {
+		contents, err := ioutil.ReadFile(filepath.Join(dir, fi.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(contents), "secret dog") {
+			t.Fatal("plaintext object found in", fi.Name())
+		}
+	}
+
+	// The object can only be loaded with the right key.
+	if err := LoadEncryptedJSON(testMeta, &obj2, filename, encryptedFilename, nil); err != ErrNoEncryptionKey {
+		t.Fatal("expected ErrNoEncryptionKey, got", err)
+	}
+	wrongKey := crypto.GenerateTwofishKey()
+	if err := LoadEncryptedJSON(testMeta, &obj2, filename, encryptedFilename, &wrongKey); err != ErrBadEncryptionKey {
+		t.Fatal("expected ErrBadEncryptionKey, got", err)
+	}
+	if err := LoadEncryptedJSON(Metadata{"Other Struct", "v1.2.1"}, &obj2, filename, encryptedFilename, &key); err != ErrBadHeader {
+		t.Fatal("expected ErrBadHeader, got", err)
+	}
+	obj2 = testStruct{}
+	if err := LoadEncryptedJSON(testMeta, &obj2, filename, encryptedFilename, &key); err != nil {
+		t.Fatal(err)
+	} else if obj2 != obj1 {
+		t.Fatal("persist mismatch:", obj2, obj1)
+	}
+}
@@ -1,21 +1,144 @@
 package persist
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/pachisi456/Sia/build"
 )
 
+// logFlags are the flags of the standard library logger in text mode.
+const logFlags = log.Ldate | log.Ltime | log.Lmicroseconds | log.Lshortfile | log.LUTC
+
 // Logger is a wrapper for the standard library logger that enforces logging
 // with the Sia-standard settings. It also supports a Close method, which
 // attempts to close the underlying io.Writer.
+//
+// A Logger can be switched to structured mode, see SetStructured. In
+// structured mode every entry is written as a single line containing a JSON
+// object, so that the log can be parsed by tools.
 type Logger struct {
 	*log.Logger
 	w io.Writer
+
+	// module is the name of the module that is included in structured
+	// entries, and structured indicates whether the logger is in structured
+	// mode. mu also serializes the writes of structured entries.
+	module     string
+	structured bool
+	mu         sync.Mutex
+}
+
+// LogFields are the fields of a structured log entry, see Event. Empty fields
+// are omitted, and ChunkIndex is only included if it is not nil.
+type LogFields struct {
+	ContractID string
+	SiaPath    string
+	ChunkIndex *uint64
+	Duration   time.Duration
+}
+
+// logEntry is a structured log entry as written in structured mode.
+type logEntry struct {
+	Time       string   `json:"time"`
+	Module     string   `json:"module,omitempty"`
+	Message    string   `json:"msg"`
+	ContractID string   `json:"contractID,omitempty"`
+	SiaPath    string   `json:"siapath,omitempty"`
+	ChunkIndex *uint64  `json:"chunkIndex,omitempty"`
+	DurationMs *float64 `json:"durationMs,omitempty"`
+}
+
+// structuredWriter turns the lines written by the standard library logger
+// into structured entries while the Logger is in structured mode.
+type structuredWriter struct {
+	l *Logger
+}
+
+// Write writes p, which contains a single log message, as a structured entry.
+func (sw structuredWriter) Write(p []byte) (int, error) {
+	err := sw.l.writeEntry(logEntry{Message: strings.TrimSuffix(string(p), "\n")})
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// writeEntry writes e as a single JSON line to the underlying io.Writer.
+func (l *Logger) writeEntry(e logEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e.Time = time.Now().UTC().Format(time.RFC3339Nano)
+	e.Module = l.module
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = l.w.Write(append(b, '\n'))
+	return err
+}
+
+// SetStructured switches the logger to structured mode, tagging every entry
+// with module, or back to text mode.
+func (l *Logger) SetStructured(structured bool, module string) {
+	l.mu.Lock()
+	l.structured = structured
+	l.module = module
+	l.mu.Unlock()
+	if structured {
+		l.SetFlags(0)
+		l.SetOutput(structuredWriter{l})
+	} else {
+		l.SetFlags(logFlags)
+		l.SetOutput(l.w)
+	}
+}
+
+// Structured reports whether the logger is in structured mode.
+func (l *Logger) Structured() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.structured
+}
+
+// Event logs msg together with fields. In structured mode the fields are
+// written as separate JSON fields, otherwise they are appended to msg as
+// key=value pairs.
+func (l *Logger) Event(msg string, fields LogFields) {
+	if l.Structured() {
+		e := logEntry{
+			Message:    msg,
+			ContractID: fields.ContractID,
+			SiaPath:    fields.SiaPath,
+			ChunkIndex: fields.ChunkIndex,
+		}
+		if fields.Duration != 0 {
+			ms := float64(fields.Duration) / float64(time.Millisecond)
+			e.DurationMs = &ms
+		}
+		l.writeEntry(e)
+		return
+	}
+
+	if fields.ContractID != "" {
+		msg += " contractID=" + fields.ContractID
+	}
+	if fields.SiaPath != "" {
+		msg += fmt.Sprintf(" siapath=%q", fields.SiaPath)
+	}
+	if fields.ChunkIndex != nil {
+		msg += fmt.Sprintf(" chunkIndex=%v", *fields.ChunkIndex)
+	}
+	if fields.Duration != 0 {
+		msg += " duration=" + fields.Duration.String()
+	}
+	l.Output(2, msg)
 }
 
 // Close logs a shutdown message and closes the Logger's underlying io.Writer,
@@ -74,9 +197,9 @@ func (l *Logger) Severe(v ...interface{}) {
 // NewLogger returns a logger that can be closed. Calls should not be made to
 // the logger after 'Close' has been called.
 func NewLogger(w io.Writer) *Logger {
-	l := log.New(w, "", log.Ldate|log.Ltime|log.Lmicroseconds|log.Lshortfile|log.LUTC)
+	l := log.New(w, "", logFlags)
 	l.Output(3, "STARTUP: Logging has started. Siad Version "+build.Version) // Call depth is 3 because NewLogger is usually called by NewFileLogger
-	return &Logger{l, w}
+	return &Logger{Logger: l, w: w}
 }
 
 // closeableFile wraps an os.File to perform sanity checks on its Write and
@@ -1,11 +1,13 @@
 package persist
 
 import (
+	"encoding/json"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/pachisi456/Sia/build"
 )
@@ -52,6 +54,91 @@ func TestLogger(t *testing.T) {
 	}
 }
 
+// TestLoggerStructured checks that the logger writes JSON lines in structured
+// mode, and returns to plain text afterwards.
+func TestLoggerStructured(t *testing.T) {
+	// Create a folder for the log file.
+	testdir := build.TempDir(persistDir, t.Name())
+	err := os.MkdirAll(testdir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create the logger.
+	logFilename := filepath.Join(testdir, "test.log")
+	fl, err := NewFileLogger(logFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Log a plain and a structured entry in structured mode, then switch
+	// back to text mode and close the logger.
+	fl.SetStructured(true, "renter")
+	if !fl.Structured() {
+		t.Fatal("logger is not in structured mode")
+	}
+	fl.Println("TEST: plain message")
+	index := uint64(0)
+	fl.Event("chunk uploaded", LogFields{
+		ContractID: "abc",
+		SiaPath:    "foo/bar",
+		ChunkIndex: &index,
+		Duration:   1500 * time.Microsecond,
+	})
+	fl.SetStructured(false, "")
+	fl.Event("chunk uploaded", LogFields{SiaPath: "foo/bar"})
+	err = fl.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fileData, err := ioutil.ReadFile(logFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileLines := strings.Split(string(fileData), "\n")
+	if len(fileLines) != 6 { // file ends with a newline
+		t.Fatal("logger did not create the correct number of lines:", len(fileLines))
+	}
+	if !strings.Contains(fileLines[0], "STARTUP") || !strings.Contains(fileLines[4], "SHUTDOWN") {
+		t.Error("text entries are missing")
+	}
+	if !strings.Contains(fileLines[3], `chunk uploaded siapath="foo/bar"`) {
+		t.Error("fields were not appended to the text entry:", fileLines[3])
+	}
+
+	var plain, event struct {
+		Time       string   `json:"time"`
+		Module     string   `json:"module"`
+		Message    string   `json:"msg"`
+		ContractID string   `json:"contractID"`
+		SiaPath    string   `json:"siapath"`
+		ChunkIndex *uint64  `json:"chunkIndex"`
+		DurationMs *float64 `json:"durationMs"`
+	}
+	if err := json.Unmarshal([]byte(fileLines[1]), &plain); err != nil {
+		t.Fatal("plain entry is not valid JSON:", err)
+	}
+	if plain.Module != "renter" || plain.Message != "TEST: plain message" || plain.Time == "" {
+		t.Error("plain entry has the wrong fields:", fileLines[1])
+	}
+	if plain.ChunkIndex != nil || plain.DurationMs != nil {
+		t.Error("plain entry has unexpected fields:", fileLines[1])
+	}
+	if err := json.Unmarshal([]byte(fileLines[2]), &event); err != nil {
+		t.Fatal("event is not valid JSON:", err)
+	}
+	if event.Module != "renter" || event.Message != "chunk uploaded" || event.ContractID != "abc" || event.SiaPath != "foo/bar" {
+		t.Error("event has the wrong fields:", fileLines[2])
+	}
+	if event.ChunkIndex == nil || *event.ChunkIndex != 0 {
+		t.Error("event has the wrong chunk index:", fileLines[2])
+	}
+	if event.DurationMs == nil || *event.DurationMs != 1.5 {
+		t.Error("event has the wrong duration:", fileLines[2])
+	}
+}
+
 // TestLoggerCritical prints a critical message from the logger.
 func TestLoggerCritical(t *testing.T) {
 	// Create a folder for the log file.
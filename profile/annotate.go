@@ -0,0 +1,34 @@
+// +build go1.11
+
+package profile
+
+import (
+	"context"
+	"runtime/trace"
+)
+
+// TraceEnabled reports whether an execution trace is being recorded.
+func TraceEnabled() bool {
+	return trace.IsEnabled()
+}
+
+// NewTraceTask creates a task in the execution trace. The events that are
+// logged with the returned context are grouped under the task in the trace
+// viewer. end has to be called once the task is done.
+func NewTraceTask(ctx context.Context, name string) (_ context.Context, end func()) {
+	ctx, task := trace.NewTask(ctx, name)
+	return ctx, task.End
+}
+
+// TraceLog logs an event with the given category and message in the
+// execution trace.
+func TraceLog(ctx context.Context, category, message string) {
+	trace.Log(ctx, category, message)
+}
+
+// StartTraceRegion marks the start of a region of the calling goroutine in
+// the execution trace. The returned function marks its end, and has to be
+// called from the same goroutine.
+func StartTraceRegion(ctx context.Context, name string) (end func()) {
+	return trace.StartRegion(ctx, name).End
+}
@@ -0,0 +1,30 @@
+// +build !go1.11
+
+package profile
+
+import (
+	"context"
+)
+
+// Execution traces can only be annotated since Go 1.11. With older versions
+// of Go, traces only contain the events of the runtime, and the annotation
+// functions are no-ops.
+
+// TraceEnabled reports whether an annotated execution trace is being
+// recorded, which is never the case.
+func TraceEnabled() bool {
+	return false
+}
+
+// NewTraceTask returns ctx and a no-op end function.
+func NewTraceTask(ctx context.Context, name string) (_ context.Context, end func()) {
+	return ctx, func() {}
+}
+
+// TraceLog is a no-op.
+func TraceLog(ctx context.Context, category, message string) {}
+
+// StartTraceRegion returns a no-op end function.
+func StartTraceRegion(ctx context.Context, name string) (end func()) {
+	return func() {}
+}
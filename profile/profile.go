@@ -23,6 +23,7 @@ var (
 	memActive   bool
 	memLock     sync.Mutex
 	traceActive bool
+	traceFile   *os.File
 	traceLock   sync.Mutex
 )
 
@@ -87,33 +88,47 @@ func SaveMemProfile(profileDir, identifier string) error {
 // StartTrace starts trace. An error will be returned if a trace
 // is already running.
 func StartTrace(traceDir, identifier string) error {
+	// Start trace into the trace dir, using the identifer. The timestamp
+	// of the start time of the trace will be included in the filename.
+	return StartTraceFile(filepath.Join(traceDir, "trace-"+identifier+"-"+time.Now().Format(time.RFC3339Nano)+".trace"))
+}
+
+// StartTraceFile starts a trace that is written to filename. An error will be
+// returned if a trace is already running. The file is closed by StopTrace.
+func StartTraceFile(filename string) error {
 	// Lock the trace lock so that only one profiler is running at a
 	// time.
 	traceLock.Lock()
+	defer traceLock.Unlock()
 	if traceActive {
-		traceLock.Unlock()
 		return errors.New("cannot start trace, it is already running")
 	}
-	traceActive = true
-	traceLock.Unlock()
 
-	// Start trace into the trace dir, using the identifer. The timestamp
-	// of the start time of the trace will be included in the filename.
-	traceFile, err := os.Create(filepath.Join(traceDir, "trace-"+identifier+"-"+time.Now().Format(time.RFC3339Nano)+".trace"))
+	f, err := os.Create(filename)
 	if err != nil {
 		return err
 	}
-	return trace.Start(traceFile)
+	if err := trace.Start(f); err != nil {
+		f.Close()
+		return err
+	}
+	traceActive = true
+	traceFile = f
+	return nil
 }
 
-// StopTrace stops trace.
-func StopTrace() {
+// StopTrace stops trace and closes the trace file.
+func StopTrace() error {
 	traceLock.Lock()
-	if traceActive {
-		trace.Stop()
-		traceActive = false
+	defer traceLock.Unlock()
+	if !traceActive {
+		return nil
 	}
-	traceLock.Unlock()
+	trace.Stop()
+	traceActive = false
+	err := traceFile.Close()
+	traceFile = nil
+	return err
 }
 
 // startContinuousLog creates dir and saves inexpensive logs periodically.